@@ -24,8 +24,15 @@ func main() {
 	w, err := r.Worktree()
 	CheckIfError(err)
 
-	err = w.Checkout(&git.CheckoutOptions{
-		SparseCheckoutDirectories: []string{path},
+	// The sparse checkout is kept by the following checkouts and pulls.
+	Info("git sparse-checkout set --sparse-index %s", path)
+	err = w.SparseCheckoutSet(&git.SparseCheckoutOptions{
+		Patterns:    []string{path},
+		SparseIndex: true,
 	})
 	CheckIfError(err)
+
+	Info("git checkout")
+	err = w.Checkout(&git.CheckoutOptions{})
+	CheckIfError(err)
 }
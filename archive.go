@@ -0,0 +1,585 @@
+package git
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/archive"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+const (
+	exportIgnoreAttr = "export-ignore"
+	exportSubstAttr  = "export-subst"
+	diffAttr         = "diff"
+
+	// defaultTarUmask is the default of the tar.umask option.
+	defaultTarUmask = 0o002
+)
+
+var (
+	// ErrArchivePathNotFound is returned by Archive when one of the paths
+	// of the options is not in the archived tree.
+	ErrArchivePathNotFound = errors.New("pathspec did not match any files")
+	// ErrNotTreeish is returned by Archive when the object to archive is
+	// not a tree, a commit, or a tag of one.
+	ErrNotTreeish = errors.New("not a tree object")
+)
+
+var archiveAttributes = []string{exportIgnoreAttr, exportSubstAttr, diffAttr}
+
+// Archive writes an archive of the tree of treeish to w, like git archive.
+// treeish is a revision resolving to a commit, or the hash of a tree, a
+// commit or a tag. When a commit is archived, its hash is recorded in the
+// archive and its committer time is the modification time of the entries.
+//
+// The export-ignore and export-subst attributes of the .gitattributes files
+// of the tree are honored, and the content of the files is converted as on
+// checkout.
+func (r *Repository) Archive(w io.Writer, treeish plumbing.Revision, o *ArchiveOptions) error {
+	if o == nil {
+		o = &ArchiveOptions{}
+	}
+
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	tree, commit, err := r.archiveTree(treeish)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range o.Paths {
+		p = strings.Trim(p, "/")
+		if p == "" {
+			continue
+		}
+
+		if _, err := tree.FindEntry(p); err != nil {
+			return fmt.Errorf("%w: %s", ErrArchivePathNotFound, p)
+		}
+	}
+
+	a, err := r.newArchiver(tree, commit, o)
+	if err != nil {
+		return err
+	}
+
+	defer a.converter.close()
+
+	a.writer, err = archive.NewWriter(w, a.writerOptions(o))
+	if err != nil {
+		return err
+	}
+
+	if err := a.writeTree(tree); err != nil {
+		return err
+	}
+
+	if err := a.writeExtraFiles(o.ExtraFiles); err != nil {
+		return err
+	}
+
+	return a.writer.Close()
+}
+
+// archiveTree returns the tree to archive for treeish, and its commit when
+// known.
+func (r *Repository) archiveTree(treeish plumbing.Revision) (*object.Tree, *object.Commit, error) {
+	if plumbing.IsHash(string(treeish)) {
+		obj, err := r.Object(plumbing.AnyObject, plumbing.NewHash(string(treeish)))
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, nil, err
+		}
+
+		for err == nil {
+			switch o := obj.(type) {
+			case *object.Tag:
+				obj, err = o.Object()
+			case *object.Commit:
+				tree, err := o.Tree()
+				return tree, o, err
+			case *object.Tree:
+				return o, nil, nil
+			default:
+				return nil, nil, fmt.Errorf("%w: %s", ErrNotTreeish, treeish)
+			}
+		}
+
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, nil, err
+		}
+	}
+
+	h, err := r.ResolveRevision(treeish)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	commit, err := r.CommitObject(*h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, err := commit.Tree()
+	return tree, commit, err
+}
+
+// archiver writes the entries of an archive.
+type archiver struct {
+	r         *Repository
+	writer    archive.Writer
+	commit    *object.Commit
+	matcher   gitattributes.Matcher
+	converter *contentConverter
+
+	prefix  string
+	paths   []string
+	modTime time.Time
+	umask   uint32
+
+	// pending are the directories not written yet, they are written
+	// before the first file inside them.
+	pending []*archive.Entry
+}
+
+func (r *Repository) newArchiver(tree *object.Tree, commit *object.Commit, o *ArchiveOptions) (*archiver, error) {
+	patterns, err := readTreeAttributes(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	wt := &Worktree{r: r, Filesystem: r.wt}
+	converter, err := wt.newContentConverter(nil, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &archiver{
+		r:         r,
+		commit:    commit,
+		matcher:   gitattributes.NewMatcher(patterns),
+		converter: converter,
+		prefix:    o.Prefix,
+		modTime:   o.ModTime,
+		umask:     defaultTarUmask,
+	}
+
+	for _, p := range o.Paths {
+		a.paths = append(a.paths, strings.Trim(p, "/"))
+	}
+
+	if a.modTime.IsZero() {
+		a.modTime = time.Now()
+		if commit != nil {
+			a.modTime = commit.Committer.When
+		}
+	}
+
+	// The entries are dated in the local time, like git does.
+	a.modTime = time.Unix(a.modTime.Unix(), 0)
+
+	cfg, err := r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	umask := cfg.Raw.Section("tar").Options.Get("umask")
+	if umask != "" && umask != "user" {
+		v, err := strconv.ParseUint(umask, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar.umask: %s", umask)
+		}
+
+		a.umask = uint32(v)
+	}
+
+	return a, nil
+}
+
+func (a *archiver) writerOptions(o *ArchiveOptions) *archive.Options {
+	wo := &archive.Options{
+		Format:           o.Format,
+		ModTime:          a.modTime,
+		Umask:            a.umask,
+		CompressionLevel: flate.DefaultCompression,
+	}
+
+	switch {
+	case o.CompressionLevel < 0:
+		wo.CompressionLevel = flate.NoCompression
+	case o.CompressionLevel > 0:
+		wo.CompressionLevel = o.CompressionLevel
+	}
+
+	if a.commit != nil {
+		wo.Commit = a.commit.Hash
+	}
+
+	return wo
+}
+
+// writeTree writes the entries of the archived tree, and the directory of
+// the prefix when it is one.
+func (a *archiver) writeTree(tree *object.Tree) error {
+	if base := strings.TrimRight(a.prefix, "/"); base != "" && strings.HasSuffix(a.prefix, "/") {
+		err := a.writer.WriteEntry(&archive.Entry{
+			Name: base + "/",
+			Mode: filemode.Dir | 0o777,
+			Hash: tree.Hash,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.writeEntries(tree, "")
+}
+
+func (a *archiver) writeEntries(tree *object.Tree, dir string) error {
+	for _, e := range tree.Entries {
+		name := path.Join(dir, e.Name)
+		attrs, _ := a.matcher.Match(strings.Split(name, "/"), archiveAttributes)
+		if attr := attrs[exportIgnoreAttr]; attr != nil && attr.IsSet() {
+			continue
+		}
+
+		if e.Mode == filemode.Dir {
+			if !a.includesDir(name) {
+				continue
+			}
+
+			sub, err := object.GetTree(a.r.Storer, e.Hash)
+			if err != nil {
+				return err
+			}
+
+			entry := &archive.Entry{Name: a.prefix + name + "/", Mode: e.Mode, Hash: e.Hash}
+			a.pending = append(a.pending, entry)
+
+			if err := a.writeEntries(sub, name); err != nil {
+				return err
+			}
+
+			if n := len(a.pending); n > 0 && a.pending[n-1] == entry {
+				a.pending = a.pending[:n-1]
+			}
+
+			continue
+		}
+
+		if !a.includes(name) {
+			continue
+		}
+
+		if err := a.writePending(); err != nil {
+			return err
+		}
+
+		entry, err := a.fileEntry(name, e, attrs)
+		if err != nil {
+			return err
+		}
+
+		if err := a.writer.WriteEntry(entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fileEntry returns the entry of the file, or the submodule, at name.
+func (a *archiver) fileEntry(name string, e object.TreeEntry, attrs map[string]gitattributes.Attribute) (*archive.Entry, error) {
+	entry := &archive.Entry{Name: a.prefix + name, Mode: e.Mode, Hash: e.Hash}
+	if e.Mode == filemode.Submodule {
+		entry.Name += "/"
+		return entry, nil
+	}
+
+	blob, err := a.r.BlobObject(e.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rd.Close()
+
+	content, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Mode != filemode.Symlink {
+		if content, err = a.converter.toWorktree(name, content); err != nil {
+			return nil, err
+		}
+
+		if attr := attrs[exportSubstAttr]; attr != nil && attr.IsSet() && a.commit != nil {
+			content = expandExportSubst(content, a.commit)
+		}
+
+		entry.Binary, err = isBinaryContent(content, attrs[diffAttr])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entry.Content = content
+	return entry, nil
+}
+
+// writePending writes the directories not written yet.
+func (a *archiver) writePending() error {
+	for _, e := range a.pending {
+		if err := a.writer.WriteEntry(e); err != nil {
+			return err
+		}
+	}
+
+	a.pending = a.pending[:0]
+	return nil
+}
+
+func (a *archiver) writeExtraFiles(files []ArchiveFile) error {
+	for _, f := range files {
+		mode := f.Mode
+		if mode == filemode.Empty {
+			mode = filemode.Regular
+		}
+
+		binary, err := isBinaryContent(f.Content, nil)
+		if err != nil {
+			return err
+		}
+
+		err = a.writer.WriteEntry(&archive.Entry{
+			Name:    a.prefix + f.Name,
+			Mode:    mode,
+			Hash:    plumbing.ZeroHash,
+			Content: f.Content,
+			Binary:  binary,
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// includes reports whether the file at name is included by the paths of the
+// options.
+func (a *archiver) includes(name string) bool {
+	if len(a.paths) == 0 {
+		return true
+	}
+
+	for _, p := range a.paths {
+		if p == "" || name == p || strings.HasPrefix(name, p+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// includesDir reports whether files under the directory at name may be
+// included by the paths of the options.
+func (a *archiver) includesDir(name string) bool {
+	if a.includes(name) {
+		return true
+	}
+
+	for _, p := range a.paths {
+		if strings.HasPrefix(p, name+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isBinaryContent reports whether the content is binary, as decided by the
+// diff attribute when specified.
+func isBinaryContent(content []byte, diff gitattributes.Attribute) (bool, error) {
+	switch {
+	case diff != nil && diff.IsUnset():
+		return true, nil
+	case diff != nil && diff.IsSet():
+		return false, nil
+	}
+
+	return binary.IsBinary(bytes.NewReader(content))
+}
+
+// expandExportSubst replaces the $Format:...$ placeholders of content with
+// the commit formatted with the given format.
+func expandExportSubst(content []byte, c *object.Commit) []byte {
+	var out []byte
+	for {
+		start := bytes.Index(content, []byte("$Format:"))
+		if start < 0 {
+			break
+		}
+
+		end := bytes.IndexByte(content[start+8:], '$')
+		if end < 0 {
+			break
+		}
+
+		end += start + 8
+		out = append(out, content[:start]...)
+		out = append(out, formatCommit(c, string(content[start+8:end]))...)
+		content = content[end+1:]
+	}
+
+	return append(out, content...)
+}
+
+// formatCommit formats the commit like git log --format does, supporting
+// the placeholders of the commit hashes, the author and committer
+// identities and dates, and the message. Unknown placeholders are kept as
+// is.
+func formatCommit(c *object.Commit, format string) string {
+	var sb strings.Builder
+	subject, body := splitCommitMessage(c.Message)
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+
+		n := formatPlaceholder(&sb, c, format[i+1:], subject, body)
+		if n == 0 {
+			sb.WriteByte('%')
+			continue
+		}
+
+		i += n
+	}
+
+	return sb.String()
+}
+
+// formatPlaceholder writes the placeholder at the start of p, without its
+// percent sign, returning its length or zero when unknown.
+func formatPlaceholder(sb *strings.Builder, c *object.Commit, p, subject, body string) int {
+	switch p[0] {
+	case '%':
+		sb.WriteByte('%')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'H':
+		sb.WriteString(c.Hash.String())
+	case 'h':
+		sb.WriteString(c.Hash.String()[:7])
+	case 'T':
+		sb.WriteString(c.TreeHash.String())
+	case 't':
+		sb.WriteString(c.TreeHash.String()[:7])
+	case 'P', 'p':
+		for i, h := range c.ParentHashes {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+
+			if p[0] == 'p' {
+				sb.WriteString(h.String()[:7])
+			} else {
+				sb.WriteString(h.String())
+			}
+		}
+	case 's':
+		sb.WriteString(subject)
+	case 'b':
+		sb.WriteString(body)
+	case 'B':
+		sb.WriteString(c.Message)
+	case 'a', 'c':
+		if len(p) < 2 {
+			return 0
+		}
+
+		sig := c.Author
+		if p[0] == 'c' {
+			sig = c.Committer
+		}
+
+		if !formatSignature(sb, sig, p[1]) {
+			return 0
+		}
+
+		return 2
+	default:
+		return 0
+	}
+
+	return 1
+}
+
+// formatSignature writes the field of the signature for the placeholder
+// letter, reporting whether it is known.
+func formatSignature(sb *strings.Builder, sig object.Signature, field byte) bool {
+	switch field {
+	case 'n':
+		sb.WriteString(sig.Name)
+	case 'e':
+		sb.WriteString(sig.Email)
+	case 'd':
+		sb.WriteString(sig.When.Format("Mon Jan 2 15:04:05 2006 -0700"))
+	case 'D':
+		sb.WriteString(sig.When.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+	case 't':
+		sb.WriteString(strconv.FormatInt(sig.When.Unix(), 10))
+	case 'i':
+		sb.WriteString(sig.When.Format("2006-01-02 15:04:05 -0700"))
+	case 'I':
+		sb.WriteString(sig.When.Format("2006-01-02T15:04:05-07:00"))
+	case 's':
+		sb.WriteString(sig.When.Format(time.DateOnly))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// splitCommitMessage returns the subject of the message, its first
+// paragraph joined in a line, and its body.
+func splitCommitMessage(msg string) (subject, body string) {
+	lines := strings.Split(msg, "\n")
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	var subjectLines []string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		subjectLines = append(subjectLines, strings.TrimRight(lines[i], " \t\r\v\f"))
+	}
+
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	return strings.Join(subjectLines, " "), strings.Join(lines[i:], "\n")
+}
@@ -0,0 +1,224 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/archive"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newArchiveTestRepository(t *testing.T) (*Repository, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	files := map[string]string{
+		"README":          "hello\n",
+		"version.txt":     "commit $Format:%H$ by $Format:%an <%ae>$\n",
+		"docs/public.md":  "public\n",
+		"docs/secret.md":  "secret\n",
+		"internal/foo.go": "package internal\n",
+		".gitattributes":  "version.txt export-subst\ndocs/secret.md export-ignore\ninternal export-ignore\n",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o755))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+
+	sig := &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Unix(1700000000, 0).In(time.FixedZone("", 2*3600))}
+	_, err = w.Commit("subject\n\nbody\n", &CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+
+	return r, dir
+}
+
+func readTarArchive(t *testing.T, data []byte) (names []string, contents map[string]string, comment string) {
+	t.Helper()
+
+	contents = make(map[string]string)
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		require.NoError(t, err)
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			comment = hdr.PAXRecords["comment"]
+			continue
+		}
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, hdr.Name)
+		contents[hdr.Name] = string(content)
+	}
+
+	return names, contents, comment
+}
+
+func TestArchive(t *testing.T) {
+	r, _ := newArchiveTestRepository(t)
+	head := mustHead(t, r)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Archive(&buf, "HEAD", nil))
+
+	names, contents, comment := readTarArchive(t, buf.Bytes())
+	assert.Equal(t, []string{".gitattributes", "README", "docs/", "docs/public.md", "run.sh", "version.txt"}, names)
+	assert.Equal(t, head.String(), comment)
+	assert.Equal(t, "commit "+head.String()+" by foo <foo@foo.foo>\n", contents["version.txt"])
+
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		require.NoError(t, err)
+		if hdr.Name == "run.sh" {
+			assert.Equal(t, int64(0o775), hdr.Mode)
+			assert.Equal(t, int64(1700000000), hdr.ModTime.Unix())
+			break
+		}
+	}
+}
+
+func TestArchiveOptions(t *testing.T) {
+	r, _ := newArchiveTestRepository(t)
+
+	var buf bytes.Buffer
+	err := r.Archive(&buf, "master", &ArchiveOptions{
+		Prefix:     "project-1.0/",
+		Paths:      []string{"docs", "README"},
+		ExtraFiles: []ArchiveFile{{Name: "VERSION", Content: []byte("1.0\n")}},
+	})
+	require.NoError(t, err)
+
+	names, contents, _ := readTarArchive(t, buf.Bytes())
+	assert.Equal(t, []string{"project-1.0/", "project-1.0/README", "project-1.0/docs/", "project-1.0/docs/public.md", "project-1.0/VERSION"}, names)
+	assert.Equal(t, "1.0\n", contents["project-1.0/VERSION"])
+
+	err = r.Archive(io.Discard, "HEAD", &ArchiveOptions{Paths: []string{"missing"}})
+	assert.ErrorIs(t, err, ErrArchivePathNotFound)
+
+	err = r.Archive(io.Discard, "HEAD", &ArchiveOptions{Format: "rar"})
+	assert.ErrorIs(t, err, archive.ErrUnknownFormat)
+}
+
+func TestArchiveTree(t *testing.T) {
+	r, _ := newArchiveTestRepository(t)
+	commit, err := r.CommitObject(mustHead(t, r))
+	require.NoError(t, err)
+
+	modTime := time.Unix(1600000000, 0)
+	var buf bytes.Buffer
+	err = r.Archive(&buf, plumbing.Revision(commit.TreeHash.String()), &ArchiveOptions{ModTime: modTime})
+	require.NoError(t, err)
+
+	// Without commit, there is no comment and nothing to substitute.
+	_, contents, comment := readTarArchive(t, buf.Bytes())
+	assert.Empty(t, comment)
+	assert.Equal(t, "commit $Format:%H$ by $Format:%an <%ae>$\n", contents["version.txt"])
+
+	err = r.Archive(io.Discard, plumbing.Revision(commit.Hash.String()), nil)
+	assert.NoError(t, err)
+
+	blob, err := commit.File("README")
+	require.NoError(t, err)
+	err = r.Archive(io.Discard, plumbing.Revision(blob.Hash.String()), nil)
+	assert.ErrorIs(t, err, ErrNotTreeish)
+}
+
+func TestArchiveZip(t *testing.T) {
+	r, _ := newArchiveTestRepository(t)
+	head := mustHead(t, r)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.Archive(&buf, "HEAD", &ArchiveOptions{Format: archive.Zip}))
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, head.String(), zr.Comment)
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+
+	assert.Equal(t, []string{".gitattributes", "README", "docs/", "docs/public.md", "run.sh", "version.txt"}, names)
+}
+
+func TestArchiveMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the executable bit is not recorded on windows")
+	}
+
+	r, dir := newArchiveTestRepository(t)
+	tests := []struct {
+		args []string
+		o    *ArchiveOptions
+	}{
+		{[]string{"--format=tar", "HEAD"}, nil},
+		{[]string{"--format=tar", "--prefix=project/", "HEAD", "docs"}, &ArchiveOptions{Prefix: "project/", Paths: []string{"docs"}}},
+		{[]string{"--format=zip", "-0", "HEAD"}, &ArchiveOptions{Format: archive.Zip, CompressionLevel: -1}},
+	}
+
+	for _, tc := range tests {
+		cmd := exec.Command("git", append([]string{"archive"}, tc.args...)...)
+		cmd.Dir = dir
+		expected, err := cmd.Output()
+		require.NoError(t, err)
+
+		var buf bytes.Buffer
+		require.NoError(t, r.Archive(&buf, "HEAD", tc.o))
+		assert.True(t, bytes.Equal(expected, buf.Bytes()), "git archive %v", tc.args)
+	}
+}
+
+func TestFormatCommit(t *testing.T) {
+	when := time.Date(2005, 4, 7, 22, 13, 13, 0, time.FixedZone("", 2*3600))
+	c := &object.Commit{
+		Hash:         plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+		TreeHash:     plumbing.NewHash("a8d315b2b1c615d43042c3a62402b8a54288cf5c"),
+		ParentHashes: []plumbing.Hash{plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")},
+		Author:       object.Signature{Name: "foo", Email: "foo@foo.foo", When: when},
+		Committer:    object.Signature{Name: "bar", Email: "bar@bar.bar", When: when},
+		Message:      "subject\nwrapped\n\nbody\n",
+	}
+
+	for format, expected := range map[string]string{
+		"%H %h %T %t %P %p": "6ecf0ef2c2dffb796033e5a02219af86ec6584e5 6ecf0ef a8d315b2b1c615d43042c3a62402b8a54288cf5c a8d315b e8d3ffab552895c19b9fcf7aa264d277cde33881 e8d3ffa",
+		"%an <%ae> %cn":     "foo <foo@foo.foo> bar",
+		"%ad|%aD|%at":       "Thu Apr 7 22:13:13 2005 +0200|Thu, 7 Apr 2005 22:13:13 +0200|1112904793",
+		"%ci|%cI|%cs":       "2005-04-07 22:13:13 +0200|2005-04-07T22:13:13+02:00|2005-04-07",
+		"%s%n%b":            "subject wrapped\nbody\n",
+		"%% %x %":           "% %x %",
+	} {
+		assert.Equal(t, expected, formatCommit(c, format), format)
+	}
+}
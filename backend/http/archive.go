@@ -0,0 +1,91 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/archive"
+	"github.com/go-git/go-git/v6/storage"
+)
+
+// archivePattern matches the paths of the archives of the revisions of the
+// repositories, served when enabled by Backend.Archive.
+var archivePattern = regexp.MustCompile(`(.*?)/archive/(.+)\.(tar\.gz|tgz|tar|zip)$`)
+
+var archiveService = service{archivePattern, http.MethodGet, getArchive, ""}
+
+var archiveFormats = map[string]struct {
+	format      archive.Format
+	contentType string
+}{
+	"tar":    {archive.Tar, "application/x-tar"},
+	"tar.gz": {archive.TarGzip, "application/gzip"},
+	"tgz":    {archive.TarGzip, "application/gzip"},
+	"zip":    {archive.Zip, "application/zip"},
+}
+
+// getArchive writes the archive of the requested revision, its entries
+// prefixed with the name of the repository and the revision, like
+// <repo>-<revision>/.
+func getArchive(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	st, ok := ctx.Value(contextKey("storer")).(storage.Storer)
+	if !ok {
+		renderStatusError(w, http.StatusInternalServerError)
+		return
+	}
+	errorLog, ok := ctx.Value(contextKey("errorLog")).(*log.Logger)
+	if !ok {
+		renderStatusError(w, http.StatusInternalServerError)
+		return
+	}
+	repo, ok := ctx.Value(contextKey("repo")).(string)
+	if !ok {
+		renderStatusError(w, http.StatusInternalServerError)
+		return
+	}
+
+	m := archivePattern.FindStringSubmatch(r.URL.Path)
+	if m == nil {
+		renderStatusError(w, http.StatusNotFound)
+		return
+	}
+
+	rev, ext := m[2], m[3]
+	format := archiveFormats[ext]
+	name := path.Base(strings.TrimSuffix(repo, ".git")) + "-" + strings.ReplaceAll(rev, "/", "-")
+
+	repository, err := git.Open(st, nil)
+	if err != nil {
+		logf(errorLog, "error opening repository: %v", err)
+		renderStatusError(w, http.StatusNotFound)
+		return
+	}
+
+	hdrNocache(w)
+	w.Header().Set("Content-Type", format.contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+ext))
+
+	err = repository.Archive(w, plumbing.Revision(rev), &git.ArchiveOptions{
+		Format: format.format,
+		Prefix: name + "/",
+	})
+
+	switch {
+	case err == nil:
+	case errors.Is(err, plumbing.ErrReferenceNotFound), errors.Is(err, plumbing.ErrObjectNotFound):
+		w.Header().Del("Content-Disposition")
+		renderStatusError(w, http.StatusNotFound)
+	default:
+		logf(errorLog, "error writing archive: %v", err)
+		w.Header().Del("Content-Disposition")
+		renderStatusError(w, http.StatusInternalServerError)
+	}
+}
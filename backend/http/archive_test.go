@@ -0,0 +1,59 @@
+package http
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	h := NewBackend(&fixturesLoader{t})
+
+	req := httptest.NewRequest("GET", "/basic.git/archive/master.tar.gz", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, 404, w.Result().StatusCode)
+
+	h.Archive = true
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	res := w.Result()
+	require.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "application/gzip", res.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="basic-master.tar.gz"`, res.Header.Get("Content-Disposition"))
+
+	gz, err := gzip.NewReader(res.Body)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "6ecf0ef2c2dffb796033e5a02219af86ec6584e5", hdr.PAXRecords["comment"])
+
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+
+		names = append(names, hdr.Name)
+	}
+
+	require.NotEmpty(t, names)
+	assert.Equal(t, "basic-master/", names[0])
+	for _, name := range names {
+		assert.True(t, strings.HasPrefix(name, "basic-master/"), name)
+	}
+
+	req = httptest.NewRequest("GET", "/basic.git/archive/missing.zip", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, 404, w.Result().StatusCode)
+	assert.Empty(t, w.Result().Header.Get("Content-Disposition"))
+}
@@ -55,6 +55,10 @@ type Backend struct {
 	// Prefix is a path prefix that will be stripped from the URL path before
 	// matching the service patterns.
 	Prefix string
+	// Archive enables the download of the archives of the revisions of the
+	// repositories, at <repo>/archive/<revision>.<format> where the format
+	// is one of tar, tar.gz, tgz and zip.
+	Archive bool
 }
 
 // NewBackend returns a Git HTTP handler that serves git repositories over
@@ -79,7 +83,7 @@ func NewBackend(loader transport.Loader) *Backend {
 func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	urlPath := r.URL.Path
 	urlPath = strings.TrimPrefix(urlPath, b.Prefix)
-	for _, s := range services {
+	for _, s := range b.services() {
 		if m := s.pattern.FindStringSubmatch(urlPath); m != nil {
 			if r.Method != s.method {
 				renderStatusError(w, http.StatusMethodNotAllowed)
@@ -119,6 +123,15 @@ func (b *Backend) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	renderStatusError(w, http.StatusNotFound)
 }
 
+// services returns the services enabled for the backend.
+func (b *Backend) services() []service {
+	if !b.Archive {
+		return services
+	}
+
+	return append(services[:len(services):len(services)], archiveService)
+}
+
 // logf logs the given message to the error log if it is set.
 func logf(logger *log.Logger, format string, v ...interface{}) {
 	if logger != nil {
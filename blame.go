@@ -3,17 +3,22 @@ package git
 import (
 	"bytes"
 	"container/heap"
+	"context"
 	"errors"
 	"fmt"
-	"io"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 	"unicode/utf8"
 
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/utils/diff"
-	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
 // BlameResult represents the result of a Blame operation.
@@ -24,6 +29,34 @@ type BlameResult struct {
 	Rev plumbing.Hash
 	// Lines contains every line with its authorship.
 	Lines []*Line
+	// Entries are the groups of consecutive lines blamed on the same
+	// commit, sorted by line.
+	Entries []*BlameEntry
+}
+
+// BlameEntry is a group of consecutive lines of the blamed file, blamed on
+// the same commit.
+type BlameEntry struct {
+	// Commit is the commit the lines are blamed on.
+	Commit *object.Commit
+	// Path is the path of the file with the lines in Commit.
+	Path string
+	// LineNumber is the number of the first line in the blamed file, from
+	// 1, and NumLines the number of lines.
+	LineNumber, NumLines int
+	// OrigLineNumber is the number of the first line in the file of
+	// Commit, from 1.
+	OrigLineNumber int
+	// Previous is the first parent of Commit with the file, the one where
+	// the lines were looked for, and PreviousPath the path of the file in
+	// it. In a reverse blame, it is a child.
+	Previous     *object.Commit
+	PreviousPath string
+	// Boundary is set when Commit is a root commit, or the blamed commit of
+	// a reverse blame, whose lines could not be passed any further.
+	Boundary bool
+	// Ignored and Unblamable are set like in Line.
+	Ignored, Unblamable bool
 }
 
 // Blame returns a BlameResult with the information about the last author of
@@ -33,87 +66,187 @@ func Blame(c *object.Commit, path string) (*BlameResult, error) {
 	// commit and path. commit is a Commit object obtained from a Repository. Path
 	// represents a path to a specific file contained in the repository.
 	//
-	// Blaming a file is done by walking the tree in reverse order trying to find where each line was last modified.
+	// Blaming a file is done like git does: the lines of the file are blamed on
+	// the commit, their suspect, which passes the blame for the lines it did
+	// not change to its parents. The commits are visited from the most recent
+	// one, and the lines left to a commit once its parents are checked are its
+	// own.
 	//
-	// When a diff is found it cannot immediately assume it came from that commit, as it may have come from 1 of its
-	// parents, so it will first try to resolve those diffs from its parents, if it couldn't find the change in its
-	// parents then it will assign the change to itself.
-	//
-	// When encountering 2 parents that have made the same change to a file it will choose the parent that was merged
-	// into the current branch first (this is determined by the order of the parents inside the commit).
-	//
-	// This currently works on a line by line basis, if performance becomes an issue it could be changed to work with
-	// hunks rather than lines. Then when encountering diff hunks it would need to split them where necessary.
+	// When 2 parents have made the same change to a file, the first parent
+	// takes the blame.
+	b := newBlame(context.Background(), &BlameOptions{})
+	return b.run(c, path)
+}
 
-	b := new(blame)
-	b.fRev = c
-	b.path = path
-	b.q = new(priorityQueue)
+// Blame is like the Blame function, with the given options: the lines may be
+// restricted to ranges, the lines moved or copied from other files may be
+// followed, some commits may be ignored, and the history may be walked
+// forward with Reverse.
+func (r *Repository) Blame(c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	return r.BlameContext(context.Background(), c, path, o)
+}
 
-	file, err := b.fRev.File(path)
+// BlameContext is like Blame, the history walk being cancelled with the
+// context.
+func (r *Repository) BlameContext(ctx context.Context, c *object.Commit, path string, o *BlameOptions) (*BlameResult, error) {
+	b, err := r.newBlame(ctx, c, path, o)
 	if err != nil {
 		return nil, err
 	}
-	finalLines, err := file.Lines()
+
+	return b.run(c, path)
+}
+
+// BlameIncremental blames the file like BlameContext, calling fn with each
+// entry as soon as its lines are blamed, like `git blame --incremental`:
+// the entries come in no particular order, the most recent commits being
+// usually blamed first. The blame stops when fn returns an error,
+// storer.ErrStop stopping it without error.
+func (r *Repository) BlameIncremental(ctx context.Context, c *object.Commit, path string, o *BlameOptions, fn func(*BlameEntry) error) error {
+	b, err := r.newBlame(ctx, c, path, o)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	finalLength := len(finalLines)
 
-	needsMap := make([]lineMap, finalLength)
-	for i := range needsMap {
-		needsMap[i] = lineMap{i, i, nil, -1}
+	b.emit = fn
+	_, err = b.run(c, path)
+	if err == storer.ErrStop {
+		return nil
 	}
-	contents, err := file.Contents()
-	if err != nil {
+
+	return err
+}
+
+// newBlame returns the blame of the file with the options, validated.
+func (r *Repository) newBlame(ctx context.Context, c *object.Commit, path string, o *BlameOptions) (*blame, error) {
+	if o == nil {
+		o = &BlameOptions{}
+	}
+
+	if err := o.Validate(r); err != nil {
 		return nil, err
 	}
-	b.q.Push(&queueItem{
-		nil,
-		nil,
-		c,
-		path,
-		contents,
-		needsMap,
-		0,
-		false,
-		0,
-	})
-	items := make([]*queueItem, 0)
-	for {
-		items = items[:0]
-		for {
-			if b.q.Len() == 0 {
-				return nil, errors.New("invalid state: no items left on the blame queue")
-			}
-			item := b.q.Pop()
-			items = append(items, item)
-			next := b.q.Peek()
-			if next == nil || next.Hash != item.Commit.Hash {
-				break
-			}
+
+	b := newBlame(ctx, o)
+	var err error
+	if b.ignored, err = r.blameIgnoredRevs(o); err != nil {
+		return nil, err
+	}
+
+	if o.Reverse {
+		end, err := r.CommitObject(o.End)
+		if err != nil {
+			return nil, err
 		}
-		finished, err := b.addBlames(items)
+
+		if b.children, err = blameChildren(c, end); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(o.Lines) != 0 {
+		drivers, err := r.formatPatchDrivers(c)
 		if err != nil {
 			return nil, err
 		}
-		if finished {
-			break
+
+		if d := drivers(path); d != nil {
+			b.funcName = d.FuncName
+		}
+	}
+
+	return b, nil
+}
+
+// blameIgnoredRevs returns the commits ignored by blame, from the options
+// and the files they list.
+func (r *Repository) blameIgnoredRevs(o *BlameOptions) (map[plumbing.Hash]bool, error) {
+	ignored := make(map[plumbing.Hash]bool)
+	hashes := append([]plumbing.Hash(nil), o.IgnoreRevs...)
+	for _, name := range o.IgnoreRevsFiles {
+		if r.wt == nil {
+			return nil, ErrIsBareRepository
+		}
+
+		content, err := util.ReadFile(r.wt, name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, line := range strings.Split(string(content), "\n") {
+			if i := strings.IndexByte(line, '#'); i >= 0 {
+				line = line[:i]
+			}
+
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+
+			h, ok := plumbing.FromHex(line)
+			if !ok || len(line) != h.HexSize() {
+				return nil, fmt.Errorf("%s: %w: %s", name, ErrBlameInvalidRev, line)
+			}
+
+			hashes = append(hashes, h)
 		}
 	}
 
-	b.lineToCommit = make([]*object.Commit, finalLength)
-	for i := range needsMap {
-		b.lineToCommit[i] = needsMap[i].Commit
+	// The tags are peeled to their commits.
+	for _, h := range hashes {
+		if tag, err := r.TagObject(h); err == nil {
+			c, err := tag.Commit()
+			if err != nil {
+				return nil, err
+			}
+
+			h = c.Hash
+		}
+
+		ignored[h] = true
 	}
 
-	lines := newLines(finalLines, b.lineToCommit)
+	return ignored, nil
+}
 
-	return &BlameResult{
-		Path:  path,
-		Rev:   c.Hash,
-		Lines: lines,
-	}, nil
+// blameChildren returns the children of the commits of the history going
+// from c to end, for a reverse blame.
+func blameChildren(c, end *object.Commit) (map[plumbing.Hash][]*object.Commit, error) {
+	// The commits reachable from c are not part of the history.
+	excluded := map[plumbing.Hash]bool{c.Hash: true}
+	err := object.NewCommitPreorderIter(c, nil, nil).ForEach(func(c *object.Commit) error {
+		excluded[c.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[plumbing.Hash][]*object.Commit)
+	seen := map[plumbing.Hash]bool{end.Hash: true}
+	queue := []*object.Commit{end}
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = queue[1:]
+		if excluded[child.Hash] {
+			continue
+		}
+
+		err := child.Parents().ForEach(func(p *object.Commit) error {
+			children[p.Hash] = append(children[p.Hash], child)
+			if !seen[p.Hash] {
+				seen[p.Hash] = true
+				queue = append(queue, p)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return children, nil
 }
 
 // Line values represent the contents and author of a line in BlamedResult values.
@@ -128,6 +261,21 @@ type Line struct {
 	Date time.Time
 	// Hash is the commit hash that introduced the original line
 	Hash plumbing.Hash
+	// LineNumber is the number of the line in the blamed file, from 1.
+	LineNumber int
+	// OrigPath is the path of the file in the commit that introduced the
+	// line, which differs from the blamed path when the file was renamed or
+	// the line was copied from another file.
+	OrigPath string
+	// OrigLineNumber is the number of the line, from 1, in the file of the
+	// commit that introduced it.
+	OrigLineNumber int
+	// Ignored is set when the line was changed by an ignored commit, and
+	// blamed on a previous commit that changed a similar line.
+	Ignored bool
+	// Unblamable is set when the line was changed by an ignored commit, and
+	// could not be blamed on a previous commit.
+	Unblamable bool
 }
 
 func newLine(author, authorName, text string, date time.Time, hash plumbing.Hash) *Line {
@@ -152,294 +300,886 @@ func newLines(contents []string, commits []*object.Commit) []*Line {
 	return result
 }
 
+// blameOrigin is a file in a commit, blamed for some lines of the final
+// file.
+type blameOrigin struct {
+	commit *object.Commit
+	path   string
+	hash   plumbing.Hash
+	mode   filemode.FileMode
+	// lines are the lines of the file, with their line feed, loaded when
+	// needed.
+	lines []string
+	// suspects are the entries blamed on the origin, sorted by their line
+	// in the file, which are not passed to its parents yet.
+	suspects []*blameEntry
+	// previous is the origin of the first parent with the file.
+	previous *blameOrigin
+}
+
+// blameEntry is a range of lines of the final file blamed on an origin.
+type blameEntry struct {
+	// lno is the first line of the entry in the final file, from 0, and
+	// num its number of lines.
+	lno, num int
+	origin   *blameOrigin
+	// sLno is the first line of the entry in the file of the origin.
+	sLno int
+	// score is the number of alphanumeric characters of the lines, when
+	// computed.
+	score int
+	// ignored is set when the lines were passed to a parent of an ignored
+	// commit by similarity, and unblamable when they could not be.
+	ignored, unblamable bool
+}
+
+// blameRenameScore is the similarity of the renamed files followed by
+// blame, the default one of git.
+const blameRenameScore = 50
+
 // this struct is internally used by the blame function to hold its
 // inputs, outputs and state.
 type blame struct {
-	// the path of the file to blame
-	path string
-	// the commit of the final revision of the file to blame
-	fRev *object.Commit
-	// resolved lines
-	lineToCommit []*object.Commit
+	ctx  context.Context
+	opts *BlameOptions
+	// emit is called with each entry once blamed, if set.
+	emit func(*BlameEntry) error
+	// start is the blamed commit.
+	start plumbing.Hash
+	// final are the lines of the blamed file, with their line feed.
+	final []string
+	// origins are the origins in each commit.
+	origins map[plumbing.Hash][]*blameOrigin
+	trees   map[plumbing.Hash]*object.Tree
 	// queue of commits that need resolving
-	q *priorityQueue
-}
-
-type lineMap struct {
-	Orig, Cur    int
-	Commit       *object.Commit
-	FromParentNo int
-}
-
-func (b *blame) addBlames(curItems []*queueItem) (bool, error) {
-	curItem := curItems[0]
-
-	// Simple optimisation to merge paths, there is potential to go a bit further here and check for any duplicates
-	// not only if they are all the same.
-	if len(curItems) == 1 {
-		curItems = nil
-	} else if curItem.IdenticalToChild {
-		allSame := true
-		lenCurItems := len(curItems)
-		lowestParentNo := curItem.ParentNo
-		for i := 1; i < lenCurItems; i++ {
-			if !curItems[i].IdenticalToChild || curItem.Child != curItems[i].Child {
-				allSame = false
-				break
+	q      *priorityQueue
+	queued map[plumbing.Hash]bool
+	// guilty are the entries blamed on their origin.
+	guilty []*blameEntry
+	// ignored are the ignored commits.
+	ignored map[plumbing.Hash]bool
+	// children are the children of the commits of a reverse blame, which
+	// are blamed instead of their parents.
+	children map[plumbing.Hash][]*object.Commit
+	// funcName finds the function lines of the -L :<funcname> ranges.
+	funcName *fdiff.FuncName
+}
+
+func newBlame(ctx context.Context, o *BlameOptions) *blame {
+	return &blame{
+		ctx:     ctx,
+		opts:    o,
+		origins: make(map[plumbing.Hash][]*blameOrigin),
+		trees:   make(map[plumbing.Hash]*object.Tree),
+		q:       &priorityQueue{reverse: o.Reverse},
+		queued:  make(map[plumbing.Hash]bool),
+	}
+}
+
+func (b *blame) run(c *object.Commit, path string) (*BlameResult, error) {
+	file, err := c.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	finalLines, err := file.Lines()
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	b.final = splitBlameLines(contents)
+	ranges, err := parseBlameRanges(b.opts.Lines, finalLines, b.funcName)
+	if err != nil {
+		return nil, err
+	}
+
+	b.start = c.Hash
+	o := b.origin(c, path)
+	o.hash, o.mode = file.Hash, file.Mode
+	var entries []*blameEntry
+	for _, r := range ranges {
+		entries = append(entries, &blameEntry{lno: r[0], num: r[1] - r[0], origin: o, sLno: r[0]})
+	}
+
+	b.queueBlames(o, entries)
+	for b.q.Len() > 0 {
+		if err := b.ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		commit := b.q.Pop()
+		delete(b.queued, commit.Hash)
+		for _, o := range b.origins[commit.Hash] {
+			if len(o.suspects) == 0 {
+				continue
 			}
-			lowestParentNo = min(lowestParentNo, curItems[i].ParentNo)
-		}
-		if allSame {
-			curItem.Child.numParentsNeedResolving = curItem.Child.numParentsNeedResolving - lenCurItems + 1
-			curItems = nil // free the memory
-			curItem.ParentNo = lowestParentNo
-
-			// Now check if we can remove the parent completely
-			for curItem.Child.IdenticalToChild && curItem.Child.MergedChildren == nil && curItem.Child.numParentsNeedResolving == 1 {
-				oldChild := curItem.Child
-				curItem.Child = oldChild.Child
-				curItem.ParentNo = oldChild.ParentNo
+
+			if err := b.pass(o); err != nil {
+				return nil, err
 			}
-		}
-	}
 
-	// if we have more than 1 item for this commit, create a single needsMap
-	if len(curItems) > 1 {
-		curItem.MergedChildren = make([]childToNeedsMap, len(curItems))
-		for i, c := range curItems {
-			curItem.MergedChildren[i] = childToNeedsMap{c.Child, c.NeedsMap, c.IdenticalToChild, c.ParentNo}
-		}
-		newNeedsMap := make([]lineMap, 0, len(curItem.NeedsMap))
-		newNeedsMap = append(newNeedsMap, curItems[0].NeedsMap...)
-
-		for i := 1; i < len(curItems); i++ {
-			cur := curItems[i].NeedsMap
-			n := 0 // position in newNeedsMap
-			c := 0 // position in current list
-			for c < len(cur) {
-				if n == len(newNeedsMap) {
-					newNeedsMap = append(newNeedsMap, cur[c:]...)
-					break
-				} else if newNeedsMap[n].Cur == cur[c].Cur {
-					n++
-					c++
-				} else if newNeedsMap[n].Cur < cur[c].Cur {
-					n++
-				} else {
-					newNeedsMap = append(newNeedsMap, cur[c])
-					newPos := len(newNeedsMap) - 1
-					for newPos > n {
-						newNeedsMap[newPos-1], newNeedsMap[newPos] = newNeedsMap[newPos], newNeedsMap[newPos-1]
-						newPos--
+			// The lines that were not passed to the parents are the
+			// ones of the origin.
+			if b.emit != nil {
+				for _, e := range o.suspects {
+					if err := b.emit(b.entry(e)); err != nil {
+						return nil, err
 					}
 				}
 			}
+
+			b.guilty = append(b.guilty, o.suspects...)
+			o.suspects = nil
+			o.lines = nil
 		}
-		curItem.NeedsMap = newNeedsMap
-		curItem.IdenticalToChild = false
-		curItem.Child = nil
-		curItems = nil // free the memory
 	}
 
-	parents, err := parentsContainingPath(curItem.path, curItem.Commit)
-	if err != nil {
-		return false, err
+	sort.Slice(b.guilty, func(i, j int) bool { return b.guilty[i].lno < b.guilty[j].lno })
+	var texts []string
+	var commits []*object.Commit
+	for _, e := range b.guilty {
+		for i := 0; i < e.num; i++ {
+			texts = append(texts, finalLines[e.lno+i])
+			commits = append(commits, e.origin.commit)
+		}
 	}
 
-	anyPushed := false
-	for parnetNo, prev := range parents {
-		currentHash, err := blobHash(curItem.path, curItem.Commit)
-		if err != nil {
-			return false, err
+	lines := newLines(texts, commits)
+	blamed := make([]*BlameEntry, len(b.guilty))
+	n := 0
+	for j, e := range b.guilty {
+		blamed[j] = b.entry(e)
+		for i := 0; i < e.num; i++ {
+			lines[n].LineNumber = e.lno + i + 1
+			lines[n].OrigPath = e.origin.path
+			lines[n].OrigLineNumber = e.sLno + i + 1
+			lines[n].Ignored, lines[n].Unblamable = e.ignored, e.unblamable
+			n++
 		}
-		prevHash, err := blobHash(prev.Path, prev.Commit)
-		if err != nil {
-			return false, err
-		}
-		if currentHash == prevHash {
-			if len(parents) == 1 && curItem.MergedChildren == nil && curItem.IdenticalToChild {
-				// commit that has 1 parent and 1 child and is the same as both, bypass it completely
-				b.q.Push(&queueItem{
-					Child:            curItem.Child,
-					Commit:           prev.Commit,
-					path:             prev.Path,
-					Contents:         curItem.Contents,
-					NeedsMap:         curItem.NeedsMap, // reuse the NeedsMap as we are throwing away this item
-					IdenticalToChild: true,
-					ParentNo:         curItem.ParentNo,
-				})
-			} else {
-				b.q.Push(&queueItem{
-					Child:            curItem,
-					Commit:           prev.Commit,
-					path:             prev.Path,
-					Contents:         curItem.Contents,
-					NeedsMap:         append([]lineMap(nil), curItem.NeedsMap...), // create new slice and copy
-					IdenticalToChild: true,
-					ParentNo:         parnetNo,
-				})
-				curItem.numParentsNeedResolving++
+	}
+
+	return &BlameResult{
+		Path:    path,
+		Rev:     c.Hash,
+		Lines:   lines,
+		Entries: blamed,
+	}, nil
+}
+
+// entry returns the BlameEntry of an entry blamed on its origin.
+func (b *blame) entry(e *blameEntry) *BlameEntry {
+	c := e.origin.commit
+	entry := &BlameEntry{
+		Commit:         c,
+		Path:           e.origin.path,
+		LineNumber:     e.lno + 1,
+		NumLines:       e.num,
+		OrigLineNumber: e.sLno + 1,
+		Boundary:       c.NumParents() == 0 || (b.opts.Reverse && c.Hash == b.start),
+		Ignored:        e.ignored,
+		Unblamable:     e.unblamable,
+	}
+
+	if p := e.origin.previous; p != nil {
+		entry.Previous, entry.PreviousPath = p.commit, p.path
+	}
+
+	return entry
+}
+
+// origin returns the origin of the file at path in the commit.
+func (b *blame) origin(c *object.Commit, path string) *blameOrigin {
+	for _, o := range b.origins[c.Hash] {
+		if o.path == path {
+			return o
+		}
+	}
+
+	o := &blameOrigin{commit: c, path: path}
+	b.origins[c.Hash] = append(b.origins[c.Hash], o)
+	return o
+}
+
+func (b *blame) tree(c *object.Commit) (*object.Tree, error) {
+	if t, ok := b.trees[c.Hash]; ok {
+		return t, nil
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	b.trees[c.Hash] = t
+	return t, nil
+}
+
+// lines returns the lines of the file of the origin.
+func (b *blame) lines(o *blameOrigin) ([]string, error) {
+	if o.lines != nil {
+		return o.lines, nil
+	}
+
+	t, err := b.tree(o.commit)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := t.TreeEntryFile(&object.TreeEntry{Name: o.path, Mode: o.mode, Hash: o.hash})
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := f.Contents()
+	if err != nil {
+		return nil, err
+	}
+
+	o.lines = splitBlameLines(contents)
+	return o.lines, nil
+}
+
+// scapegoats returns the commits the lines of c may be passed to: its
+// parents, or its children in a reverse blame.
+func (b *blame) scapegoats(c *object.Commit) ([]*object.Commit, error) {
+	if b.children != nil {
+		return b.children[c.Hash], nil
+	}
+
+	var parents []*object.Commit
+	err := c.Parents().ForEach(func(p *object.Commit) error {
+		parents = append(parents, p)
+		return nil
+	})
+
+	return parents, err
+}
+
+// queueBlames adds the entries to the suspects of the origin, and queues
+// its commit.
+func (b *blame) queueBlames(o *blameOrigin, entries []*blameEntry) {
+	if len(entries) == 0 {
+		return
+	}
+
+	for _, e := range entries {
+		e.origin = o
+	}
+
+	o.suspects = append(o.suspects, entries...)
+	sort.SliceStable(o.suspects, func(i, j int) bool { return o.suspects[i].sLno < o.suspects[j].sLno })
+	if !b.queued[o.commit.Hash] {
+		b.queued[o.commit.Hash] = true
+		b.q.Push(o.commit)
+	}
+}
+
+// pass passes the blame of the suspects of the origin to the origins of its
+// parents.
+func (b *blame) pass(o *blameOrigin) error {
+	parents, err := b.scapegoats(o.commit)
+	if err != nil {
+		return err
+	}
+
+	// The file is looked for at the same path in the parents first, and
+	// then among their renamed files. The parents with the same file as a
+	// previous one are skipped.
+	porigins := make([]*blameOrigin, len(parents))
+	for pass := 0; pass < 2; pass++ {
+		for i, p := range parents {
+			if porigins[i] != nil {
+				continue
+			}
+
+			find := b.findOrigin
+			if pass == 1 {
+				find = b.findRename
+			}
+
+			po, err := find(p, o)
+			if err != nil {
+				return err
+			}
+
+			if po == nil {
+				continue
+			}
+
+			if po.hash == o.hash {
+				b.passWhole(o, po)
+				return nil
+			}
+
+			same := false
+			for _, prev := range porigins[:i] {
+				same = same || (prev != nil && prev.hash == po.hash)
+			}
+
+			if !same {
+				porigins[i] = po
 			}
-			anyPushed = true
+		}
+	}
+
+	for _, po := range porigins {
+		if po == nil {
 			continue
 		}
 
-		// get the contents of the file
-		file, err := prev.Commit.File(prev.Path)
-		if err != nil {
-			return false, err
+		if o.previous == nil {
+			o.previous = po
 		}
-		prevContents, err := file.Contents()
-		if err != nil {
-			return false, err
-		}
-
-		hunks := diff.Do(prevContents, curItem.Contents)
-		prevl := -1
-		curl := -1
-		need := 0
-		getFromParent := make([]lineMap, 0)
-	out:
-		for h := range hunks {
-			hLines := countLines(hunks[h].Text)
-			for hl := 0; hl < hLines; hl++ {
-				switch hunks[h].Type {
-				case diffmatchpatch.DiffEqual:
-					prevl++
-					curl++
-					if curl == curItem.NeedsMap[need].Cur {
-						// add to needs
-						getFromParent = append(getFromParent, lineMap{curl, prevl, nil, -1})
-						// move to next need
-						need++
-						if need >= len(curItem.NeedsMap) {
-							break out
-						}
-					}
-				case diffmatchpatch.DiffInsert:
-					curl++
-					if curl == curItem.NeedsMap[need].Cur {
-						// the line we want is added, it may have been added here (or by another parent), skip it for now
-						need++
-						if need >= len(curItem.NeedsMap) {
-							break out
-						}
-					}
-				case diffmatchpatch.DiffDelete:
-					prevl += hLines
-					continue out
-				default:
-					return false, errors.New("invalid state: invalid hunk Type")
-				}
+
+		if err := b.passToParent(o, po, false); err != nil {
+			return err
+		}
+
+		if len(o.suspects) == 0 {
+			return nil
+		}
+	}
+
+	if b.ignored[o.commit.Hash] {
+		for _, po := range porigins {
+			if po == nil {
+				continue
+			}
+
+			if err := b.passToParent(o, po, true); err != nil {
+				return err
+			}
+
+			if len(o.suspects) == 0 {
+				return nil
 			}
 		}
+	}
 
-		if len(getFromParent) > 0 {
-			b.q.Push(&queueItem{
-				curItem,
-				nil,
-				prev.Commit,
-				prev.Path,
-				prevContents,
-				getFromParent,
-				0,
-				false,
-				parnetNo,
-			})
-			curItem.numParentsNeedResolving++
-			anyPushed = true
+	// The entries too small to be detected as moved or copied are left
+	// aside, and blamed on the origin in the end.
+	var small []*blameEntry
+	defer func() { o.suspects = append(small, o.suspects...) }()
+	if b.opts.DetectMoves {
+		o.suspects, small = b.filterSmall(o.suspects, b.opts.MoveScore)
+		for _, po := range porigins {
+			if po == nil || len(o.suspects) == 0 {
+				continue
+			}
+
+			s, err := b.findMoves(o, po)
+			if err != nil {
+				return err
+			}
+
+			small = append(small, s...)
 		}
 	}
 
-	curItem.Contents = "" // no longer need, free the memory
+	if b.opts.DetectCopies == NoBlameCopies {
+		return nil
+	}
 
-	if !anyPushed {
-		return finishNeeds(curItem)
+	var s []*blameEntry
+	if b.opts.CopyScore > b.opts.MoveScore {
+		o.suspects, s = b.filterSmall(o.suspects, b.opts.CopyScore)
+		small = append(small, s...)
+	} else if b.opts.CopyScore < b.opts.MoveScore {
+		o.suspects, small = b.filterSmall(append(o.suspects, small...), b.opts.CopyScore)
 	}
 
-	return false, nil
+	for i, p := range parents {
+		if len(o.suspects) == 0 {
+			break
+		}
+
+		s, err := b.findCopies(o, p, porigins[i])
+		if err != nil {
+			return err
+		}
+
+		small = append(small, s...)
+	}
+
+	return nil
 }
 
-func finishNeeds(curItem *queueItem) (bool, error) {
-	// any needs left in the needsMap must have come from this revision
-	for i := range curItem.NeedsMap {
-		if curItem.NeedsMap[i].Commit == nil {
-			curItem.NeedsMap[i].Commit = curItem.Commit
-			curItem.NeedsMap[i].FromParentNo = -1
+// findOrigin returns the origin of the file of o in the parent, at the same
+// path, if any.
+func (b *blame) findOrigin(parent *object.Commit, o *blameOrigin) (*blameOrigin, error) {
+	for _, po := range b.origins[parent.Hash] {
+		if po.path == o.path {
+			return po, nil
 		}
 	}
 
-	if curItem.Child == nil && curItem.MergedChildren == nil {
-		return true, nil
+	t, err := b.tree(parent)
+	if err != nil {
+		return nil, err
 	}
 
-	if curItem.MergedChildren == nil {
-		return applyNeeds(curItem.Child, curItem.NeedsMap, curItem.IdenticalToChild, curItem.ParentNo)
+	e, err := t.FindEntry(o.path)
+	if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
 	}
 
-	for _, ctn := range curItem.MergedChildren {
-		m := 0 // position in merged needs map
-		p := 0 // position in parent needs map
-		for p < len(ctn.NeedsMap) {
-			if ctn.NeedsMap[p].Cur == curItem.NeedsMap[m].Cur {
-				ctn.NeedsMap[p].Commit = curItem.NeedsMap[m].Commit
-				m++
-				p++
-			} else if ctn.NeedsMap[p].Cur < curItem.NeedsMap[m].Cur {
-				p++
-			} else {
-				m++
+	// The type of the file must not change.
+	if !e.Mode.IsFile() || (e.Mode == filemode.Symlink) != (o.mode == filemode.Symlink) {
+		return nil, nil
+	}
+
+	po := b.origin(parent, o.path)
+	po.hash, po.mode = e.Hash, e.Mode
+	return po, nil
+}
+
+// findRename returns the origin of the file of o in the parent, when it
+// was renamed.
+func (b *blame) findRename(parent *object.Commit, o *blameOrigin) (*blameOrigin, error) {
+	from, err := b.tree(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := b.tree(o.commit)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(b.ctx, from, to, &object.DiffTreeOptions{
+		DetectRenames: true,
+		RenameScore:   blameRenameScore,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ch := range changes {
+		if ch.To.Name != o.path || ch.From.Name == "" || ch.From.Name == o.path {
+			continue
+		}
+
+		po := b.origin(parent, ch.From.Name)
+		po.hash, po.mode = ch.From.TreeEntry.Hash, ch.From.TreeEntry.Mode
+		return po, nil
+	}
+
+	return nil, nil
+}
+
+// passWhole passes the blame of all the suspects of o to po, whose file is
+// the same.
+func (b *blame) passWhole(o, po *blameOrigin) {
+	if po.lines == nil {
+		po.lines = o.lines
+	}
+
+	entries := o.suspects
+	o.suspects = nil
+	b.queueBlames(po, entries)
+}
+
+// blameRegion is a range of lines of a file, unchanged from its parent or
+// changed by an edit.
+type blameRegion struct {
+	start, end int
+	// offset moves the unchanged lines to the lines of the parent.
+	offset int
+	edit   *diff.Edit
+}
+
+// passToParent passes the blame of the lines of the suspects of target that
+// are not changed from the file of parent. When ignoring its changes, the
+// changed lines are passed to the most similar lines of parent.
+func (b *blame) passToParent(target, parent *blameOrigin, ignore bool) error {
+	tl, err := b.lines(target)
+	if err != nil {
+		return err
+	}
+
+	pl, err := b.lines(parent)
+	if err != nil {
+		return err
+	}
+
+	edits := diff.Edits(pl, tl, nil)
+	var regions []blameRegion
+	pos, offset := 0, 0
+	for i := range edits {
+		e := &edits[i]
+		offset = e.Pos1 - e.Pos2
+		if e.Pos2 > pos {
+			regions = append(regions, blameRegion{start: pos, end: e.Pos2, offset: offset})
+		}
+
+		if e.Len2 > 0 {
+			regions = append(regions, blameRegion{start: e.Pos2, end: e.Pos2 + e.Len2, edit: e})
+		}
+
+		pos = e.Pos2 + e.Len2
+		offset = e.Pos1 + e.Len1 - pos
+	}
+
+	regions = append(regions, blameRegion{start: pos, end: len(tl) + 1, offset: offset})
+	// The lines of all the edits are guessed in order, as the parts of the
+	// lines of the parent matched are not matched again.
+	guesses := make(map[int][]int)
+	if ignore {
+		pf, tf := newBlameFingerprints(pl), newBlameFingerprints(tl)
+		for i, r := range regions {
+			if r.edit != nil {
+				guesses[i] = guessBlameLines(pf, tf, r.edit)
 			}
 		}
-		finished, err := applyNeeds(ctn.Child, ctn.NeedsMap, ctn.IdenticalToChild, ctn.ParentNo)
-		if finished || err != nil {
-			return finished, err
+	}
+
+	var passed, kept []*blameEntry
+	for _, e := range target.suspects {
+		end := e.sLno + e.num
+		for s := e.sLno; s < end; {
+			ri := sort.Search(len(regions), func(i int) bool { return regions[i].end > s })
+			r := regions[ri]
+			n := min(end, r.end) - s
+			piece := e.piece(s, n)
+			s += n
+
+			switch {
+			case r.edit == nil:
+				piece.sLno += r.offset
+				passed = append(passed, piece)
+			case ignore:
+				p, k := splitIgnored(piece, guesses[ri][piece.sLno-r.start:])
+				passed = append(passed, p...)
+				kept = append(kept, k...)
+			default:
+				kept = append(kept, piece)
+			}
 		}
 	}
 
-	return false, nil
+	target.suspects = kept
+	b.queueBlames(parent, passed)
+	return nil
 }
 
-func applyNeeds(child *queueItem, needsMap []lineMap, identicalToChild bool, parentNo int) (bool, error) {
-	if identicalToChild {
-		for i := range child.NeedsMap {
-			l := &child.NeedsMap[i]
-			if l.Cur != needsMap[i].Cur || l.Orig != needsMap[i].Orig {
-				return false, errors.New("needsMap isn't the same? Why not??")
-			}
-			if l.Commit == nil || parentNo < l.FromParentNo {
-				l.Commit = needsMap[i].Commit
-				l.FromParentNo = parentNo
+// piece returns the entry of the n lines of e from the line s of its file.
+func (e *blameEntry) piece(s, n int) *blameEntry {
+	if s == e.sLno && n == e.num {
+		return e
+	}
+
+	p := *e
+	p.lno, p.sLno, p.num, p.score = e.lno+s-e.sLno, s, n, 0
+	return &p
+}
+
+// splitIgnored splits the entry of lines changed by an ignored commit in
+// the entries passed to the lines of the parent guessed for them, and the
+// unblamable ones, kept on the commit.
+func splitIgnored(e *blameEntry, guesses []int) (passed, kept []*blameEntry) {
+	for i := 0; i < e.num; {
+		n := 1
+		for i+n < e.num && (guesses[i+n] < 0) == (guesses[i] < 0) &&
+			(guesses[i] < 0 || guesses[i+n] == guesses[i]+n) {
+			n++
+		}
+
+		p := e.piece(e.sLno+i, n)
+		if p == e {
+			p = &blameEntry{}
+			*p = *e
+		}
+
+		if guesses[i] < 0 {
+			p.unblamable = true
+			kept = append(kept, p)
+		} else {
+			p.ignored = true
+			p.sLno = guesses[i]
+			passed = append(passed, p)
+		}
+
+		i += n
+	}
+
+	return passed, kept
+}
+
+// score returns 1 plus the number of alphanumeric characters of the lines
+// of the entry, like git counts them, telling whether they are worth being
+// detected as moved or copied.
+func (b *blame) score(e *blameEntry) int {
+	if e.score != 0 {
+		return e.score
+	}
+
+	e.score = 1
+	for _, line := range b.final[e.lno : e.lno+e.num] {
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' {
+				e.score++
 			}
 		}
+	}
+
+	return e.score
+}
+
+// filterSmall returns the entries whose score is above min, and the other
+// ones.
+func (b *blame) filterSmall(entries []*blameEntry, min int) (large, small []*blameEntry) {
+	for _, e := range entries {
+		if b.score(e) <= min {
+			small = append(small, e)
+		} else {
+			large = append(large, e)
+		}
+	}
+
+	return large, small
+}
+
+// findCopyInBlob returns the best split of the entry whose lines are found
+// in the lines of the origin: the lines before them, the lines found, blamed
+// on the origin, and the lines after them. Like git, the lines looked for
+// are the ones of the final file, which differ from the ones of the origin
+// for the lines passed from an ignored commit.
+func (b *blame) findCopyInBlob(e *blameEntry, o *blameOrigin, lines []string) [3]*blameEntry {
+	var best [3]*blameEntry
+	tlno, plno := 0, 0
+	for _, ed := range diff.Edits(lines, b.final[e.lno:e.lno+e.num], nil) {
+		b.handleSplit(&best, e, tlno, plno, ed.Pos2, o)
+		plno, tlno = ed.Pos1+ed.Len1, ed.Pos2+ed.Len2
+	}
+
+	b.handleSplit(&best, e, tlno, plno, e.num, o)
+	return best
+}
+
+// handleSplit keeps the split of the entry whose lines from tlno to same,
+// relative to the entry, are the ones of the origin from plno, when it is
+// better than the best one.
+func (b *blame) handleSplit(best *[3]*blameEntry, e *blameEntry, tlno, plno, same int, o *blameOrigin) {
+	if e.num <= tlno || tlno >= same {
+		return
+	}
+
+	tlno, same = tlno+e.sLno, same+e.sLno
+	var split [3]*blameEntry
+	mid := &blameEntry{origin: o, unblamable: e.unblamable}
+	if e.sLno < tlno {
+		split[0] = &blameEntry{lno: e.lno, num: tlno - e.sLno, origin: e.origin, sLno: e.sLno, unblamable: e.unblamable}
+		mid.lno, mid.sLno = e.lno+tlno-e.sLno, plno
 	} else {
-		i := 0
-	out:
-		for j := range child.NeedsMap {
-			l := &child.NeedsMap[j]
-			for needsMap[i].Orig < l.Cur {
-				i++
-				if i == len(needsMap) {
-					break out
-				}
+		mid.lno, mid.sLno = e.lno, plno+e.sLno-tlno
+	}
+
+	end := e.lno + e.num
+	if same < e.sLno+e.num {
+		split[2] = &blameEntry{
+			lno: e.lno + same - e.sLno, num: e.sLno + e.num - same,
+			origin: e.origin, sLno: same, unblamable: e.unblamable,
+		}
+		end = split[2].lno
+	}
+
+	mid.num = end - mid.lno
+	if mid.num < 1 {
+		return
+	}
+
+	split[1] = mid
+	if best[1] != nil && b.score(mid) < b.score(best[1]) {
+		return
+	}
+
+	*best = split
+}
+
+// findMoves passes the blame of the suspects of target whose lines are
+// moved from other lines of the file of parent. It returns the entries too
+// small to be passed.
+func (b *blame) findMoves(target, parent *blameOrigin) ([]*blameEntry, error) {
+	pl, err := b.lines(parent)
+	if err != nil {
+		return nil, err
+	}
+
+	var passed, leftover, small []*blameEntry
+	unblamed := target.suspects
+	for len(unblamed) > 0 {
+		var next []*blameEntry
+		for _, e := range unblamed {
+			split := b.findCopyInBlob(e, parent, pl)
+			if split[1] == nil || b.score(split[1]) <= b.opts.MoveScore {
+				leftover = append(leftover, e)
+				continue
 			}
-			if l.Cur == needsMap[i].Orig {
-				if l.Commit == nil || parentNo < l.FromParentNo {
-					l.Commit = needsMap[i].Commit
-					l.FromParentNo = parentNo
+
+			passed = append(passed, split[1])
+			next = appendSplit(next, split)
+		}
+
+		var s []*blameEntry
+		unblamed, s = b.filterSmall(next, b.opts.MoveScore)
+		small = append(small, s...)
+	}
+
+	target.suspects = sortBlameEntries(leftover)
+	b.queueBlames(parent, passed)
+	return small, nil
+}
+
+// findCopies passes the blame of the suspects of target whose lines are
+// copied from other files of the parent commit, porigin being the origin of
+// the file of target in it, if any. It returns the entries too small to be
+// passed.
+func (b *blame) findCopies(target *blameOrigin, parent *object.Commit, porigin *blameOrigin) ([]*blameEntry, error) {
+	candidates, err := b.copyCandidates(target, parent, porigin)
+	if err != nil {
+		return nil, err
+	}
+
+	var leftover, small []*blameEntry
+	passed := make(map[*blameOrigin][]*blameEntry)
+	unblamed := target.suspects
+	for len(unblamed) > 0 {
+		best := make([][3]*blameEntry, len(unblamed))
+		for _, o := range candidates {
+			lines, err := b.lines(o)
+			if err != nil {
+				return nil, err
+			}
+
+			for i, e := range unblamed {
+				split := b.findCopyInBlob(e, o, lines)
+				if split[1] != nil && (best[i][1] == nil || b.score(split[1]) >= b.score(best[i][1])) {
+					best[i] = split
 				}
 			}
 		}
+
+		var next []*blameEntry
+		for i, e := range unblamed {
+			split := best[i]
+			if split[1] == nil || b.score(split[1]) <= b.opts.CopyScore {
+				leftover = append(leftover, e)
+				continue
+			}
+
+			passed[split[1].origin] = append(passed[split[1].origin], split[1])
+			next = appendSplit(next, split)
+		}
+
+		var s []*blameEntry
+		unblamed, s = b.filterSmall(next, b.opts.CopyScore)
+		small = append(small, s...)
+	}
+
+	target.suspects = sortBlameEntries(leftover)
+	for _, o := range candidates {
+		b.queueBlames(o, passed[o])
+	}
+
+	return small, nil
+}
+
+// copyCandidates returns the origins of the files of the parent commit the
+// lines of target may be copied from: the ones modified by its commit, or
+// all of them when looking harder.
+func (b *blame) copyCandidates(target *blameOrigin, parent *object.Commit, porigin *blameOrigin) ([]*blameOrigin, error) {
+	from, err := b.tree(parent)
+	if err != nil {
+		return nil, err
 	}
-	child.numParentsNeedResolving--
-	if child.numParentsNeedResolving == 0 {
-		finished, err := finishNeeds(child)
-		if finished || err != nil {
-			return finished, err
+
+	harder := b.opts.DetectCopies >= BlameCopiesFromAnyFile ||
+		(b.opts.DetectCopies >= BlameCopiesOnCreation && (porigin == nil || porigin.path != target.path))
+
+	var entries []object.TreeEntry
+	if harder {
+		err = from.Files().ForEach(func(f *object.File) error {
+			entries = append(entries, object.TreeEntry{Name: f.Name, Mode: f.Mode, Hash: f.Hash})
+			return nil
+		})
+	} else {
+		var to *object.Tree
+		if to, err = b.tree(target.commit); err != nil {
+			return nil, err
+		}
+
+		var changes object.Changes
+		if changes, err = object.DiffTree(from, to); err != nil {
+			return nil, err
+		}
+
+		for _, ch := range changes {
+			if ch.From.Name != "" {
+				entries = append(entries, object.TreeEntry{Name: ch.From.Name, Mode: ch.From.TreeEntry.Mode, Hash: ch.From.TreeEntry.Hash})
+			}
 		}
 	}
 
-	return false, nil
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []*blameOrigin
+	for _, e := range entries {
+		if e.Mode == filemode.Submodule || (porigin != nil && e.Name == porigin.path) {
+			continue
+		}
+
+		o := b.origin(parent, e.Name)
+		o.hash, o.mode = e.Hash, e.Mode
+		candidates = append(candidates, o)
+	}
+
+	return candidates, nil
+}
+
+// appendSplit appends the parts of a split left to the origin of the entry.
+func appendSplit(entries []*blameEntry, split [3]*blameEntry) []*blameEntry {
+	if split[0] != nil {
+		entries = append(entries, split[0])
+	}
+
+	if split[2] != nil {
+		entries = append(entries, split[2])
+	}
+
+	return entries
+}
+
+func sortBlameEntries(entries []*blameEntry) []*blameEntry {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].sLno < entries[j].sLno })
+	return entries
+}
+
+// splitBlameLines splits the contents in lines, keeping their line feed.
+func splitBlameLines(contents string) []string {
+	var lines []string
+	for contents != "" {
+		i := strings.IndexByte(contents, '\n')
+		if i < 0 {
+			return append(lines, contents)
+		}
+
+		lines = append(lines, contents[:i+1])
+		contents = contents[i+1:]
+	}
+
+	return lines
 }
 
 // String prints the results of a Blame using git-blame's style.
@@ -448,13 +1188,22 @@ func (b BlameResult) String() string {
 
 	// max line number length
 	mlnl := len(strconv.Itoa(len(b.Lines)))
+	if n := len(b.Lines); n > 0 && b.Lines[n-1].LineNumber > 0 {
+		mlnl = len(strconv.Itoa(b.Lines[n-1].LineNumber))
+	}
+
 	// max author length
 	mal := b.maxAuthorLength()
 	format := fmt.Sprintf("%%s (%%-%ds %%s %%%dd) %%s\n", mal, mlnl)
 
 	for ln := range b.Lines {
+		n := b.Lines[ln].LineNumber
+		if n == 0 {
+			n = ln + 1
+		}
+
 		_, _ = fmt.Fprintf(&buf, format, b.Lines[ln].Hash.String()[:8],
-			b.Lines[ln].AuthorName, b.Lines[ln].Date.Format("2006-01-02 15:04:05 -0700"), ln+1, b.Lines[ln].Text)
+			b.Lines[ln].AuthorName, b.Lines[ln].Date.Format("2006-01-02 15:04:05 -0700"), n, b.Lines[ln].Text)
 	}
 	return buf.String()
 }
@@ -483,102 +1232,37 @@ func max(a, b int) int {
 	return b
 }
 
-type childToNeedsMap struct {
-	Child            *queueItem
-	NeedsMap         []lineMap
-	IdenticalToChild bool
-	ParentNo         int
-}
-
-type queueItem struct {
-	Child                   *queueItem
-	MergedChildren          []childToNeedsMap
-	Commit                  *object.Commit
-	path                    string
-	Contents                string
-	NeedsMap                []lineMap
-	numParentsNeedResolving int
-	IdenticalToChild        bool
-	ParentNo                int
+// priorityQueueImp orders the commits from the most recent one, or from
+// the oldest one in reverse.
+type priorityQueueImp struct {
+	c       []*object.Commit
+	reverse bool
 }
 
-type priorityQueueImp []*queueItem
-
-func (pq *priorityQueueImp) Len() int { return len(*pq) }
+func (pq *priorityQueueImp) Len() int { return len(pq.c) }
 func (pq *priorityQueueImp) Less(i, j int) bool {
-	return !(*pq)[i].Commit.Less((*pq)[j].Commit)
+	if pq.reverse {
+		return pq.c[i].Less(pq.c[j])
+	}
+	return !pq.c[i].Less(pq.c[j])
 }
-func (pq *priorityQueueImp) Swap(i, j int) { (*pq)[i], (*pq)[j] = (*pq)[j], (*pq)[i] }
-func (pq *priorityQueueImp) Push(x any)    { *pq = append(*pq, x.(*queueItem)) }
+func (pq *priorityQueueImp) Swap(i, j int) { pq.c[i], pq.c[j] = pq.c[j], pq.c[i] }
+func (pq *priorityQueueImp) Push(x any)    { pq.c = append(pq.c, x.(*object.Commit)) }
 func (pq *priorityQueueImp) Pop() any {
-	n := len(*pq)
-	ret := (*pq)[n-1]
-	(*pq)[n-1] = nil // ovoid memory leak
-	*pq = (*pq)[0 : n-1]
+	n := len(pq.c)
+	ret := pq.c[n-1]
+	pq.c[n-1] = nil // ovoid memory leak
+	pq.c = pq.c[0 : n-1]
 
 	return ret
 }
-func (pq *priorityQueueImp) Peek() *object.Commit {
-	if len(*pq) == 0 {
-		return nil
-	}
-	return (*pq)[0].Commit
-}
 
 type priorityQueue priorityQueueImp
 
-func (pq *priorityQueue) Init()    { heap.Init((*priorityQueueImp)(pq)) }
 func (pq *priorityQueue) Len() int { return (*priorityQueueImp)(pq).Len() }
-func (pq *priorityQueue) Push(c *queueItem) {
+func (pq *priorityQueue) Push(c *object.Commit) {
 	heap.Push((*priorityQueueImp)(pq), c)
 }
-func (pq *priorityQueue) Pop() *queueItem {
-	return heap.Pop((*priorityQueueImp)(pq)).(*queueItem)
-}
-func (pq *priorityQueue) Peek() *object.Commit { return (*priorityQueueImp)(pq).Peek() }
-
-type parentCommit struct {
-	Commit *object.Commit
-	Path   string
-}
-
-func parentsContainingPath(path string, c *object.Commit) ([]parentCommit, error) {
-	// TODO: benchmark this method making git.object.Commit.parent public instead of using
-	// an iterator
-	var result []parentCommit
-	iter := c.Parents()
-	for {
-		parent, err := iter.Next()
-		if err == io.EOF {
-			return result, nil
-		}
-		if err != nil {
-			return nil, err
-		}
-		if _, err := parent.File(path); err == nil {
-			result = append(result, parentCommit{parent, path})
-		} else {
-			// look for renames
-			patch, err := parent.Patch(c)
-			if err != nil {
-				return nil, err
-			} else if patch != nil {
-				for _, fp := range patch.FilePatches() {
-					from, to := fp.Files()
-					if from != nil && to != nil && to.Path() == path {
-						result = append(result, parentCommit{parent, from.Path()})
-						break
-					}
-				}
-			}
-		}
-	}
-}
-
-func blobHash(path string, commit *object.Commit) (plumbing.Hash, error) {
-	file, err := commit.File(path)
-	if err != nil {
-		return plumbing.ZeroHash, err
-	}
-	return file.Hash, nil
+func (pq *priorityQueue) Pop() *object.Commit {
+	return heap.Pop((*priorityQueueImp)(pq)).(*object.Commit)
 }
@@ -0,0 +1,147 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+// BlamePorcelainEncoder encodes the result of a blame like
+// `git blame --porcelain`, or `git blame --line-porcelain`.
+type BlamePorcelainEncoder struct {
+	w             io.Writer
+	linePorcelain bool
+}
+
+// NewBlamePorcelainEncoder returns a BlamePorcelainEncoder writing to w.
+func NewBlamePorcelainEncoder(w io.Writer) *BlamePorcelainEncoder {
+	return &BlamePorcelainEncoder{w: w}
+}
+
+// SetLinePorcelain sets whether the details of the commit are repeated for
+// every line, like `git blame --line-porcelain`, instead of the first time
+// the commit appears.
+func (e *BlamePorcelainEncoder) SetLinePorcelain(linePorcelain bool) *BlamePorcelainEncoder {
+	e.linePorcelain = linePorcelain
+	return e
+}
+
+// Encode writes the entries of the result, with the text of their lines.
+func (e *BlamePorcelainEncoder) Encode(b *BlameResult) error {
+	// The path of a commit is repeated for every entry when the commit has
+	// several files with lines.
+	paths := make(map[plumbing.Hash]string)
+	morePaths := make(map[plumbing.Hash]bool)
+	for _, entry := range b.Entries {
+		if p, ok := paths[entry.Commit.Hash]; ok && p != entry.Path {
+			morePaths[entry.Commit.Hash] = true
+		}
+
+		paths[entry.Commit.Hash] = entry.Path
+	}
+
+	var sb strings.Builder
+	shown := make(map[plumbing.Hash]bool)
+	n := 0
+	for _, entry := range b.Entries {
+		if n+entry.NumLines > len(b.Lines) {
+			return fmt.Errorf("blame entry %d-%d out of the lines", entry.LineNumber, entry.LineNumber+entry.NumLines-1)
+		}
+
+		hash := entry.Commit.Hash.String()
+		for i := 0; i < entry.NumLines; i++ {
+			if i == 0 {
+				fmt.Fprintf(&sb, "%s %d %d %d\n", hash, entry.OrigLineNumber, entry.LineNumber, entry.NumLines)
+			} else {
+				fmt.Fprintf(&sb, "%s %d %d\n", hash, entry.OrigLineNumber+i, entry.LineNumber+i)
+			}
+
+			if i == 0 || e.linePorcelain {
+				details := e.linePorcelain || !shown[entry.Commit.Hash]
+				if details {
+					shown[entry.Commit.Hash] = true
+					writeBlameCommit(&sb, entry)
+				}
+
+				if details || morePaths[entry.Commit.Hash] {
+					writeBlameFilename(&sb, entry)
+				}
+			}
+
+			sb.WriteByte('\t')
+			sb.WriteString(b.Lines[n].Text)
+			sb.WriteByte('\n')
+			n++
+		}
+	}
+
+	_, err := io.WriteString(e.w, sb.String())
+	return err
+}
+
+// BlameIncrementalEncoder encodes the entries of a blame as they come, like
+// `git blame --incremental`. Its Encode method can be given as is to
+// Repository.BlameIncremental.
+type BlameIncrementalEncoder struct {
+	w     io.Writer
+	shown map[plumbing.Hash]bool
+}
+
+// NewBlameIncrementalEncoder returns a BlameIncrementalEncoder writing to w.
+func NewBlameIncrementalEncoder(w io.Writer) *BlameIncrementalEncoder {
+	return &BlameIncrementalEncoder{w: w, shown: make(map[plumbing.Hash]bool)}
+}
+
+// Encode writes the entry, with the details of its commit the first time
+// the commit appears.
+func (e *BlameIncrementalEncoder) Encode(entry *BlameEntry) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %d %d %d\n", entry.Commit.Hash, entry.OrigLineNumber, entry.LineNumber, entry.NumLines)
+	if !e.shown[entry.Commit.Hash] {
+		e.shown[entry.Commit.Hash] = true
+		writeBlameCommit(&sb, entry)
+	}
+
+	writeBlameFilename(&sb, entry)
+	_, err := io.WriteString(e.w, sb.String())
+	return err
+}
+
+func writeBlameCommit(sb *strings.Builder, entry *BlameEntry) {
+	c := entry.Commit
+	writeBlameSignature(sb, "author", c.Author)
+	writeBlameSignature(sb, "committer", c.Committer)
+	fmt.Fprintf(sb, "summary %s\n", blameSummary(c))
+	if entry.Boundary {
+		sb.WriteString("boundary\n")
+	}
+}
+
+func writeBlameSignature(sb *strings.Builder, role string, s object.Signature) {
+	fmt.Fprintf(sb, "%s %s\n", role, s.Name)
+	fmt.Fprintf(sb, "%s-mail <%s>\n", role, s.Email)
+	fmt.Fprintf(sb, "%s-time %d\n", role, s.When.Unix())
+	fmt.Fprintf(sb, "%s-tz %s\n", role, s.When.Format("-0700"))
+}
+
+func writeBlameFilename(sb *strings.Builder, entry *BlameEntry) {
+	if entry.Previous != nil {
+		fmt.Fprintf(sb, "previous %s %s\n", entry.Previous.Hash, fdiff.QuotePath(entry.PreviousPath))
+	}
+
+	fmt.Fprintf(sb, "filename %s\n", fdiff.QuotePath(entry.Path))
+}
+
+// blameSummary returns the first line of the message of the commit.
+func blameSummary(c *object.Commit) string {
+	msg := strings.TrimLeft(c.Message, "\n")
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+
+	return msg
+}
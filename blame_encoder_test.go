@@ -0,0 +1,133 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBlameEncoderTestRepository(t *testing.T) (*Repository, *BlameResult) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.txt": "one\ntwo\nthree\n"},
+		map[string]string{"a.txt": "one\n2\n3\nthree\n"},
+	)
+
+	b, err := r.Blame(commits[1], "a.txt", nil)
+	require.NoError(t, err)
+
+	return r, b
+}
+
+func blameEncoderTestCommit(b *BlameResult, i int) string {
+	return b.Entries[i].Commit.Hash.String()
+}
+
+func TestBlamePorcelainEncoder(t *testing.T) {
+	_, b := newBlameEncoderTestRepository(t)
+	c0, c1 := blameEncoderTestCommit(b, 0), blameEncoderTestCommit(b, 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, NewBlamePorcelainEncoder(&buf).Encode(b))
+	assert.Equal(t, fmt.Sprintf(`%[1]s 1 1 1
+author foo
+author-mail <foo@foo.foo>
+author-time 1577836800
+author-tz +0000
+committer foo
+committer-mail <foo@foo.foo>
+committer-time 1577836800
+committer-tz +0000
+summary commit 0
+boundary
+filename a.txt
+	one
+%[2]s 2 2 2
+author foo
+author-mail <foo@foo.foo>
+author-time 1577840400
+author-tz +0000
+committer foo
+committer-mail <foo@foo.foo>
+committer-time 1577840400
+committer-tz +0000
+summary commit 1
+previous %[1]s a.txt
+filename a.txt
+	2
+%[2]s 3 3
+	3
+%[1]s 3 4 1
+	three
+`, c0, c1), buf.String())
+}
+
+func TestBlameLinePorcelainEncoder(t *testing.T) {
+	_, b := newBlameEncoderTestRepository(t)
+	c0, c1 := blameEncoderTestCommit(b, 0), blameEncoderTestCommit(b, 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, NewBlamePorcelainEncoder(&buf).SetLinePorcelain(true).Encode(b))
+
+	header := func(c string, n int, previous string) string {
+		s := fmt.Sprintf(`author foo
+author-mail <foo@foo.foo>
+author-time %[1]d
+author-tz +0000
+committer foo
+committer-mail <foo@foo.foo>
+committer-time %[1]d
+committer-tz +0000
+summary commit %[2]d
+`, 1577836800+3600*n, n)
+		if previous == "" {
+			return s + "boundary\nfilename a.txt\n"
+		}
+
+		return s + "previous " + previous + " a.txt\nfilename a.txt\n"
+	}
+
+	assert.Equal(t, c0+" 1 1 1\n"+header(c0, 0, "")+"\tone\n"+
+		c1+" 2 2 2\n"+header(c1, 1, c0)+"\t2\n"+
+		c1+" 3 3\n"+header(c1, 1, c0)+"\t3\n"+
+		c0+" 3 4 1\n"+header(c0, 0, "")+"\tthree\n", buf.String())
+}
+
+func TestBlameIncrementalEncoder(t *testing.T) {
+	r, b := newBlameEncoderTestRepository(t)
+	c0, c1 := blameEncoderTestCommit(b, 0), blameEncoderTestCommit(b, 1)
+
+	var buf bytes.Buffer
+	err := r.BlameIncremental(context.Background(), b.Entries[1].Commit, "a.txt", nil, NewBlameIncrementalEncoder(&buf).Encode)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`%[2]s 2 2 2
+author foo
+author-mail <foo@foo.foo>
+author-time 1577840400
+author-tz +0000
+committer foo
+committer-mail <foo@foo.foo>
+committer-time 1577840400
+committer-tz +0000
+summary commit 1
+previous %[1]s a.txt
+filename a.txt
+%[1]s 1 1 1
+author foo
+author-mail <foo@foo.foo>
+author-time 1577836800
+author-tz +0000
+committer foo
+committer-mail <foo@foo.foo>
+committer-time 1577836800
+committer-tz +0000
+summary commit 0
+boundary
+filename a.txt
+%[1]s 3 4 1
+filename a.txt
+`, c0, c1), buf.String())
+}
@@ -0,0 +1,276 @@
+package git
+
+import (
+	"github.com/go-git/go-git/v6/utils/diff"
+)
+
+// The lines changed by an ignored commit are blamed on the lines of its
+// parent that look the most like them, like git does: the lines are
+// compared by their fingerprints, the pairs of characters they are made of,
+// and matched from the most certain match, in the same order.
+
+const (
+	blameCertainNothingMatches  = -2
+	blameCertaintyNotCalculated = -1
+	// blameMaxSearchDistance is the maximum distance between the line of
+	// the parent a changed line is matched with, and the line at the same
+	// relative position in the change.
+	blameMaxSearchDistance = 10
+	// blameFileThreshold is the similarity a line of the parent outside
+	// of the edit must reach to be matched.
+	blameFileThreshold = 10
+)
+
+// blameFingerprint counts the pairs of consecutive characters of a line,
+// lower cased, the spaces being 0.
+type blameFingerprint map[uint16]int
+
+func newBlameFingerprint(line string) blameFingerprint {
+	f := make(blameFingerprint)
+	var c0 uint16
+	for i := 0; i <= len(line); i++ {
+		var c1 uint16
+		if i < len(line) && !isBlameSpace(line[i]) {
+			c1 = uint16(toLowerASCII(line[i]))
+		}
+
+		if h := c0 | c1<<8; h != 0 {
+			f[h]++
+		}
+
+		c0 = c1
+	}
+
+	return f
+}
+
+// similarity returns the number of pairs of characters found in both lines.
+func (f blameFingerprint) similarity(other blameFingerprint) int {
+	n := 0
+	for h, c := range other {
+		n += min(f[h], c)
+	}
+
+	return n
+}
+
+// subtract removes the pairs of characters of other from f.
+func (f blameFingerprint) subtract(other blameFingerprint) {
+	for h, c := range other {
+		if f[h] <= c {
+			delete(f, h)
+		} else {
+			f[h] -= c
+		}
+	}
+}
+
+// blameGuess holds the state of the matching of the lines of an edit.
+type blameGuess struct {
+	// a and b are the fingerprints of the lines of the edit in the parent
+	// and in the target.
+	a, b []blameFingerprint
+	// distanceA is the maximum search distance in the parent, and
+	// distanceB the matching distance in the target.
+	distanceA, distanceB int
+	similarities         []int
+	certainties          []int
+	second, result       []int
+}
+
+// guessBlameLines returns, for each line added by the edit, the line of the
+// parent it is guessed to come from, or -1. The lines are first matched
+// with the lines removed by the edit, and then with any line of the parent.
+// The parts of the lines of the parent matched are removed from their
+// fingerprints.
+func guessBlameLines(parent, target []blameFingerprint, e *diff.Edit) []int {
+	result := make([]int, e.Len2)
+	for i := range result {
+		result[i] = -1
+	}
+
+	if e.Len1 > 0 {
+		g := &blameGuess{
+			a:         parent[e.Pos1 : e.Pos1+e.Len1],
+			b:         target[e.Pos2 : e.Pos2+e.Len2],
+			distanceA: min(blameMaxSearchDistance, e.Len1-1),
+			result:    result,
+		}
+
+		g.distanceB = ((2*g.distanceA+1)*e.Len2 - 1) / e.Len1
+		g.second = make([]int, e.Len2)
+		g.certainties = make([]int, e.Len2)
+		g.similarities = make([]int, e.Len2*(2*g.distanceA+1))
+		for i := range g.second {
+			g.second[i] = -1
+			g.certainties[i] = blameCertaintyNotCalculated
+		}
+
+		for i := range g.similarities {
+			g.similarities[i] = -1
+		}
+
+		g.match(0, 0, e.Len1, e.Len2)
+	}
+
+	for i, r := range result {
+		if r >= 0 {
+			result[i] = r + e.Pos1
+		} else {
+			result[i] = scanBlameLines(parent, target[e.Pos2+i], e.Pos2+i)
+		}
+	}
+
+	return result
+}
+
+// scanBlameLines returns the line of the parent the most similar to the
+// line t of the target, the closest one to t among the equally similar
+// ones, or -1 when none is similar enough.
+func scanBlameLines(parent []blameFingerprint, f blameFingerprint, t int) int {
+	best, bestIdx := blameFileThreshold, -1
+	for i := range parent {
+		s := parent[i].similarity(f)
+		if s < best || (s == best && bestIdx != -1 && abs(bestIdx-t) < abs(i-t)) {
+			continue
+		}
+
+		best, bestIdx = s, i
+	}
+
+	return bestIdx
+}
+
+func newBlameFingerprints(lines []string) []blameFingerprint {
+	fingerprints := make([]blameFingerprint, len(lines))
+	for i, line := range lines {
+		fingerprints[i] = newBlameFingerprint(line)
+	}
+
+	return fingerprints
+}
+
+// closest returns the line of the parent at the same relative position in
+// the edit as the line b of the target, both relative to the edit.
+func (g *blameGuess) closest(b int) int {
+	return (b*2 + 1) * len(g.a) / (len(g.b) * 2)
+}
+
+func (g *blameGuess) similarity(a, b, closest int) *int {
+	return &g.similarities[a-closest+g.distanceA+b*(2*g.distanceA+1)]
+}
+
+// bestMatch finds the lines of the parent, from startA to endA, matching
+// the line b of the target the best.
+func (g *blameGuess) bestMatch(startA, endA, b int) {
+	if g.certainties[b] != blameCertaintyNotCalculated {
+		return
+	}
+
+	closest := g.closest(b)
+	best, second := 0, 0
+	bestIdx, secondIdx := 0, 0
+	for i := max(closest-g.distanceA, startA); i < min(closest+g.distanceA+1, endA); i++ {
+		s := g.similarity(i, b, closest)
+		if *s == -1 {
+			*s = g.a[i].similarity(g.b[b]) * (1000 - abs(i-closest))
+		}
+
+		if *s > best {
+			second, secondIdx = best, bestIdx
+			best, bestIdx = *s, i
+		} else if *s > second {
+			second, secondIdx = *s, i
+		}
+	}
+
+	if best == 0 {
+		g.certainties[b] = blameCertainNothingMatches
+		g.result[b] = -1
+		return
+	}
+
+	// A line matching 2 lines well is still preferred to a line matching
+	// a single line poorly.
+	g.certainties[b] = best*2 - second
+	g.result[b] = bestIdx
+	g.second[b] = secondIdx
+}
+
+// match matches the lines of the target from startB to endB with the lines
+// of the parent from startA to endA, from the most certain match.
+func (g *blameGuess) match(startA, startB, endA, endB int) {
+	mostCertain, certainty := -1, -1
+	for b := startB; b < endB; b++ {
+		g.bestMatch(startA, endA, b)
+		if g.certainties[b] > certainty {
+			mostCertain, certainty = b, g.certainties[b]
+		}
+	}
+
+	if mostCertain == -1 {
+		return
+	}
+
+	a := g.result[mostCertain]
+	// The parts of the line of the parent are not matched twice, and the
+	// similarities computed with it are computed again.
+	g.a[a].subtract(g.b[mostCertain])
+	invalidateMin := max(mostCertain-g.distanceB, startB)
+	invalidateMax := min(mostCertain+g.distanceB+1, endB)
+	for b := invalidateMin; b < invalidateMax; b++ {
+		closest := g.closest(b)
+		if abs(a-closest) > g.distanceA {
+			continue
+		}
+
+		*g.similarity(a, b, closest) = -1
+	}
+
+	// The matches contradicting the order of the most certain one are
+	// discarded.
+	for b := mostCertain - 1; b >= invalidateMin; b-- {
+		if g.certainties[b] >= 0 && (g.result[b] >= a || g.second[b] >= a) {
+			g.certainties[b] = blameCertaintyNotCalculated
+		}
+	}
+
+	for b := mostCertain + 1; b < invalidateMax; b++ {
+		if g.certainties[b] >= 0 && (g.result[b] <= a || g.second[b] <= a) {
+			g.certainties[b] = blameCertaintyNotCalculated
+		}
+	}
+
+	if mostCertain > startB {
+		g.match(startA, startB, a+1, mostCertain)
+	}
+
+	if mostCertain+1 < endB {
+		g.match(a, mostCertain+1, endA, endB)
+	}
+}
+
+func isBlameSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\v', '\f', '\r':
+		return true
+	}
+
+	return false
+}
+
+func toLowerASCII(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -0,0 +1,235 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+)
+
+var (
+	// ErrBlameInvalidRange is returned when a line range of BlameOptions
+	// cannot be parsed.
+	ErrBlameInvalidRange = errors.New("invalid line range")
+	// ErrBlameRangeNotFound is returned when the regex of a line range of
+	// BlameOptions matches no line.
+	ErrBlameRangeNotFound = errors.New("no match for line range")
+	// ErrBlameRangeOutOfFile is returned when a line range of BlameOptions
+	// starts after the end of the file.
+	ErrBlameRangeOutOfFile = errors.New("line range starts after the end of the file")
+)
+
+// parseBlameRanges returns the ranges of lines, from 0 and with their end
+// excluded, given with the `git blame -L` syntax, sorted and merged. The
+// whole file is returned when there is no range.
+func parseBlameRanges(specs []string, lines []string, funcName *fdiff.FuncName) ([][2]int, error) {
+	if len(specs) == 0 {
+		if len(lines) == 0 {
+			return nil, nil
+		}
+
+		return [][2]int{{0, len(lines)}}, nil
+	}
+
+	var ranges [][2]int
+	// The regexes of a range are looked for from the end of the previous
+	// one, counting lines from 1.
+	anchor := 1
+	for _, spec := range specs {
+		anchor = min(max(anchor, 1), len(lines)+1)
+		begin, end, err := parseBlameRange(spec, lines, anchor, funcName)
+		if err != nil {
+			return nil, err
+		}
+
+		if (len(lines) == 0 && (begin > 0 || end > 0)) || begin > len(lines) {
+			return nil, fmt.Errorf("%w: %s: file has only %d lines", ErrBlameRangeOutOfFile, spec, len(lines))
+		}
+
+		begin = max(begin, 1)
+		if end < 1 || end > len(lines) {
+			end = len(lines)
+		}
+
+		ranges = append(ranges, [2]int{begin - 1, end})
+		anchor = end + 1
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if r[0] >= r[1] {
+			continue
+		}
+
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+
+		merged = append(merged, r)
+	}
+
+	return merged, nil
+}
+
+// parseBlameRange returns the first and last lines, from 1, of a range. A
+// line is 0 when not given.
+func parseBlameRange(spec string, lines []string, anchor int, funcName *fdiff.FuncName) (begin, end int, err error) {
+	if strings.HasPrefix(spec, ":") || strings.HasPrefix(spec, "^:") {
+		return parseBlameFuncRange(spec, lines, anchor, funcName)
+	}
+
+	rest, err := parseBlameLoc(spec, &begin, lines, -anchor)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if strings.HasPrefix(rest, ",") {
+		if rest, err = parseBlameLoc(rest[1:], &end, lines, begin+1); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if rest != "" {
+		return 0, 0, fmt.Errorf("%w: %s", ErrBlameInvalidRange, spec)
+	}
+
+	if begin != 0 && end != 0 && end < begin {
+		begin, end = end, begin
+	}
+
+	return begin, end, nil
+}
+
+// parseBlameLoc parses a line of a range at the start of spec, and returns
+// what follows it. from is the line following the start of the range for
+// its end, or the opposite of the anchor for its start.
+func parseBlameLoc(spec string, ret *int, lines []string, from int) (string, error) {
+	if from >= 1 && (strings.HasPrefix(spec, "+") || strings.HasPrefix(spec, "-")) {
+		digits := leadingDigits(spec[1:])
+		if digits == "" {
+			return spec, nil
+		}
+
+		n, _ := strconv.Atoi(digits)
+		switch {
+		case n == 0:
+			return "", fmt.Errorf("%w: empty range", ErrBlameInvalidRange)
+		case spec[0] == '+':
+			*ret = from + n - 2
+		default:
+			*ret = max(from-n, 1)
+		}
+
+		return spec[1+len(digits):], nil
+	}
+
+	if digits := leadingDigits(spec); digits != "" {
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return "", fmt.Errorf("%w: %s", ErrBlameInvalidRange, digits)
+		}
+
+		*ret = n
+		return spec[len(digits):], nil
+	}
+
+	if from < 0 {
+		from = -from
+		if strings.HasPrefix(spec, "^") {
+			from, spec = 1, spec[1:]
+		}
+	}
+
+	if !strings.HasPrefix(spec, "/") {
+		return spec, nil
+	}
+
+	term := 1
+	for ; term < len(spec) && spec[term] != '/'; term++ {
+		if spec[term] == '\\' {
+			term++
+		}
+	}
+
+	if term >= len(spec) {
+		return spec, nil
+	}
+
+	re, err := regexp.Compile("(?m)" + spec[1:term])
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrBlameInvalidRange, err)
+	}
+
+	from = max(from, 1)
+	n := findBlameLine(lines[min(from-1, len(lines)):], func(line string) bool { return re.MatchString(line) })
+	if n < 0 {
+		return "", fmt.Errorf("%w: %s starting at line %d", ErrBlameRangeNotFound, spec[1:term], from)
+	}
+
+	*ret = from + n
+	return spec[term+1:], nil
+}
+
+// parseBlameFuncRange returns the range of the function whose line matches
+// the regex of a :<funcname> spec, from the anchor, up to the next function
+// line.
+func parseBlameFuncRange(spec string, lines []string, anchor int, funcName *fdiff.FuncName) (begin, end int, err error) {
+	if strings.HasPrefix(spec, "^") {
+		anchor, spec = 1, spec[1:]
+	}
+
+	pattern := spec[1:]
+	if pattern == "" {
+		return 0, 0, fmt.Errorf("%w: %s", ErrBlameInvalidRange, spec)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", ErrBlameInvalidRange, err)
+	}
+
+	isFunc := func(line string) bool {
+		_, ok := funcName.Match(line)
+		return ok
+	}
+
+	from := min(anchor-1, len(lines))
+	n := findBlameLine(lines[from:], func(line string) bool { return re.MatchString(line) && isFunc(line) })
+	if n < 0 {
+		return 0, 0, fmt.Errorf("%w: %s starting at line %d", ErrBlameRangeNotFound, pattern, anchor)
+	}
+
+	begin = from + n
+	end = begin + 1
+	for end < len(lines) && !isFunc(lines[end]) {
+		end++
+	}
+
+	return begin + 1, end, nil
+}
+
+// findBlameLine returns the index of the first line matching, or -1.
+func findBlameLine(lines []string, match func(string) bool) int {
+	for i, line := range lines {
+		if match(line) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func leadingDigits(s string) string {
+	i := 0
+	for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+		i++
+	}
+
+	return s[:i]
+}
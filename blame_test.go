@@ -1,11 +1,19 @@
 package git
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	fixtures "github.com/go-git/go-git-fixtures/v5"
@@ -60,6 +68,16 @@ func (s *BlameSuite) TestBlame() {
 
 		obt, err := Blame(commit, t.path)
 		s.NoError(err)
+		s.Require().Len(obt.Lines, len(exp.Lines))
+
+		// The fixtures do not record where the lines come from, nor the
+		// entries they are grouped in.
+		for i, l := range obt.Lines {
+			exp.Lines[i].OrigPath, exp.Lines[i].OrigLineNumber = l.OrigPath, l.OrigLineNumber
+		}
+
+		exp.Entries = obt.Entries
+
 		s.Equal(exp, obt)
 
 		for i, l := range obt.Lines {
@@ -89,6 +107,7 @@ func (s *BlameSuite) mockBlame(t blameTest, r *Repository) (blame *BlameResult)
 			Text:       lines[i],
 			Date:       commit.Author.When,
 			Hash:       commit.Hash,
+			LineNumber: i + 1,
 		}
 		blamedLines = append(blamedLines, l)
 	}
@@ -554,3 +573,249 @@ var blameTests = [...]blameTest{
 		repeat("a24001f6938d425d0e7504bdf5d27fc866a85c3d", 20),
 	)},
 }
+
+// newBlameTestRepository commits each set of files in turn, the files
+// mapped to an empty content being removed, and returns the commits.
+func newBlameTestRepository(t *testing.T, commits ...map[string]string) (*Repository, []*object.Commit) {
+	t.Helper()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	var result []*object.Commit
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, files := range commits {
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if content == "" {
+				_, err = w.Remove(name)
+				require.NoError(t, err)
+				continue
+			}
+
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+			_, err = w.Add(name)
+			require.NoError(t, err)
+		}
+
+		h, err := w.Commit(fmt.Sprintf("commit %d", i), &CommitOptions{
+			Author: &object.Signature{Name: "foo", Email: "foo@foo.foo", When: when.Add(time.Duration(i) * time.Hour)},
+		})
+		require.NoError(t, err)
+
+		c, err := r.CommitObject(h)
+		require.NoError(t, err)
+		result = append(result, c)
+	}
+
+	return r, result
+}
+
+func blameHashes(b *BlameResult) []plumbing.Hash {
+	var hashes []plumbing.Hash
+	for _, l := range b.Lines {
+		hashes = append(hashes, l.Hash)
+	}
+
+	return hashes
+}
+
+func TestBlameLineRanges(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"main.go": "package main\n\nfunc foo() {\n\treturn\n}\n\nfunc bar() {\n\treturn\n}\n"},
+		map[string]string{"main.go": "package main\n\nfunc foo() {\n\treturn\n}\n\nfunc bar() {\n\tprintln()\n}\n"},
+	)
+
+	b, err := r.Blame(commits[1], "main.go", &BlameOptions{Lines: []string{"2,+2", "/bar/,9"}})
+	require.NoError(t, err)
+	require.Len(t, b.Lines, 5)
+	assert.Equal(t, []int{2, 3, 7, 8, 9}, []int{
+		b.Lines[0].LineNumber, b.Lines[1].LineNumber, b.Lines[2].LineNumber, b.Lines[3].LineNumber, b.Lines[4].LineNumber,
+	})
+	assert.Equal(t, commits[1].Hash, b.Lines[3].Hash)
+	assert.Equal(t, commits[0].Hash, b.Lines[4].Hash)
+	assert.Equal(t, 9, b.Lines[4].OrigLineNumber)
+
+	b, err = r.Blame(commits[1], "main.go", &BlameOptions{Lines: []string{":bar"}})
+	require.NoError(t, err)
+	require.Len(t, b.Lines, 3)
+	assert.Equal(t, "func bar() {", b.Lines[0].Text)
+	assert.Equal(t, 7, b.Lines[0].LineNumber)
+
+	_, err = r.Blame(commits[1], "main.go", &BlameOptions{Lines: []string{"20,21"}})
+	assert.ErrorIs(t, err, ErrBlameRangeOutOfFile)
+	_, err = r.Blame(commits[1], "main.go", &BlameOptions{Lines: []string{"/qux/"}})
+	assert.ErrorIs(t, err, ErrBlameRangeNotFound)
+	_, err = r.Blame(commits[1], "main.go", &BlameOptions{Lines: []string{"1,x"}})
+	assert.ErrorIs(t, err, ErrBlameInvalidRange)
+}
+
+const blameTestBlock1 = "func first(values []string) string {\n\treturn values[0]\n}\n"
+const blameTestBlock2 = "func second(values []string) string {\n\treturn values[1]\n}\n"
+
+func TestBlameDetectMoves(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.go": "package a\n\n" + blameTestBlock1 + "\n" + blameTestBlock2},
+		map[string]string{"a.go": "package a\n\n" + blameTestBlock2 + "\n" + blameTestBlock1},
+	)
+
+	b, err := r.Blame(commits[1], "a.go", nil)
+	require.NoError(t, err)
+	assert.Contains(t, blameHashes(b), commits[1].Hash)
+
+	b, err = r.Blame(commits[1], "a.go", &BlameOptions{DetectMoves: true})
+	require.NoError(t, err)
+	require.Len(t, b.Lines, 9)
+	for _, l := range b.Lines {
+		assert.Equal(t, commits[0].Hash, l.Hash, l.Text)
+	}
+
+	assert.Equal(t, 7, b.Lines[2].OrigLineNumber)
+	assert.Equal(t, 3, b.Lines[6].OrigLineNumber)
+}
+
+func TestBlameDetectCopies(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.go": "package a\n\n" + blameTestBlock1, "b.go": "package a\n"},
+		map[string]string{"a.go": "package a\n", "b.go": "package a\n\n" + blameTestBlock1},
+	)
+
+	b, err := r.Blame(commits[1], "b.go", &BlameOptions{DetectMoves: true})
+	require.NoError(t, err)
+	assert.Equal(t, commits[1].Hash, b.Lines[2].Hash)
+
+	b, err = r.Blame(commits[1], "b.go", &BlameOptions{DetectCopies: BlameCopiesFromModifiedFiles})
+	require.NoError(t, err)
+	require.Len(t, b.Lines, 5)
+	assert.Equal(t, commits[0].Hash, b.Lines[0].Hash)
+	assert.Equal(t, "b.go", b.Lines[0].OrigPath)
+	for _, l := range b.Lines[2:] {
+		assert.Equal(t, commits[0].Hash, l.Hash)
+		assert.Equal(t, "a.go", l.OrigPath)
+	}
+
+	assert.Equal(t, 3, b.Lines[2].OrigLineNumber)
+
+	_, err = r.Blame(commits[1], "b.go", &BlameOptions{MoveScore: -1})
+	assert.ErrorIs(t, err, ErrBlameScore)
+}
+
+func TestBlameIgnoreRevs(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.go": "package a\n\nvar foo = 1\nvar bar = 2\n"},
+		map[string]string{"a.go": "package a\n\nvar foo = 10\nvar bar = 2\n"},
+		map[string]string{"a.go": "package a\n\nvar Foo = 10\nvar bar = 2\n"},
+	)
+
+	b, err := r.Blame(commits[2], "a.go", &BlameOptions{IgnoreRevs: []plumbing.Hash{commits[2].Hash}})
+	require.NoError(t, err)
+	require.Len(t, b.Lines, 4)
+	assert.Equal(t, commits[1].Hash, b.Lines[2].Hash)
+	assert.True(t, b.Lines[2].Ignored)
+	assert.False(t, b.Lines[3].Ignored)
+
+	b, err = r.Blame(commits[2], "a.go", &BlameOptions{IgnoreRevs: []plumbing.Hash{commits[1].Hash, commits[2].Hash}})
+	require.NoError(t, err)
+	assert.Equal(t, commits[0].Hash, b.Lines[2].Hash)
+	assert.True(t, b.Lines[2].Ignored)
+}
+
+func TestBlameIgnoreRevsFile(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.go": "package a\n\nvar foo = 1\n"},
+		map[string]string{"a.go": "package a\n\nvar foo = 10\n"},
+	)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	content := "# formatting\n" + commits[1].Hash.String() + "\n"
+	require.NoError(t, util.WriteFile(w.Filesystem, ".git-blame-ignore-revs", []byte(content), 0o644))
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Blame.IgnoreRevsFiles = []string{".git-blame-ignore-revs"}
+	require.NoError(t, r.SetConfig(cfg))
+
+	b, err := r.Blame(commits[1], "a.go", &BlameOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, commits[0].Hash, b.Lines[2].Hash)
+	assert.True(t, b.Lines[2].Ignored)
+
+	// An empty list disables the files of the configuration.
+	b, err = r.Blame(commits[1], "a.go", &BlameOptions{IgnoreRevsFiles: []string{}})
+	require.NoError(t, err)
+	assert.Equal(t, commits[1].Hash, b.Lines[2].Hash)
+
+	require.NoError(t, util.WriteFile(w.Filesystem, ".git-blame-ignore-revs", []byte("1234\n"), 0o644))
+	_, err = r.Blame(commits[1], "a.go", &BlameOptions{})
+	assert.ErrorIs(t, err, ErrBlameInvalidRev)
+}
+
+func TestBlameReverse(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.txt": "one\ntwo\nthree\n"},
+		map[string]string{"a.txt": "one\nthree\n"},
+		map[string]string{"a.txt": "one\nthree\nfour\n"},
+	)
+
+	b, err := r.Blame(commits[0], "a.txt", &BlameOptions{Reverse: true})
+	require.NoError(t, err)
+	assert.Equal(t, []plumbing.Hash{commits[2].Hash, commits[0].Hash, commits[2].Hash}, blameHashes(b))
+	assert.Equal(t, 2, b.Lines[2].OrigLineNumber)
+
+	b, err = r.Blame(commits[0], "a.txt", &BlameOptions{Reverse: true, End: commits[1].Hash})
+	require.NoError(t, err)
+	assert.Equal(t, []plumbing.Hash{commits[1].Hash, commits[0].Hash, commits[1].Hash}, blameHashes(b))
+}
+
+func TestBlameIncremental(t *testing.T) {
+	r, commits := newBlameTestRepository(t,
+		map[string]string{"a.txt": "one\ntwo\nthree\n"},
+		map[string]string{"b.txt": "one\nTWO\nthree\nfour\n", "a.txt": ""},
+	)
+
+	var entries []*BlameEntry
+	err := r.BlameIncremental(context.Background(), commits[1], "b.txt", nil, func(e *BlameEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	// The lines of the most recent commit come first.
+	assert.Equal(t, commits[1].Hash, entries[0].Commit.Hash)
+	assert.Equal(t, 2, entries[0].LineNumber)
+	assert.Equal(t, 1, entries[0].NumLines)
+	assert.Equal(t, commits[0].Hash, entries[0].Previous.Hash)
+	assert.Equal(t, "a.txt", entries[0].PreviousPath)
+	assert.False(t, entries[0].Boundary)
+
+	assert.Equal(t, commits[0].Hash, entries[2].Commit.Hash)
+	assert.Equal(t, "a.txt", entries[2].Path)
+	assert.True(t, entries[2].Boundary)
+	assert.Nil(t, entries[2].Previous)
+
+	b, err := r.Blame(commits[1], "b.txt", nil)
+	require.NoError(t, err)
+	require.Len(t, b.Entries, 4)
+	assert.Equal(t, []int{1, 2, 3, 4}, []int{
+		b.Entries[0].LineNumber, b.Entries[1].LineNumber, b.Entries[2].LineNumber, b.Entries[3].LineNumber,
+	})
+
+	n := 0
+	err = r.BlameIncremental(context.Background(), commits[1], "b.txt", nil, func(e *BlameEntry) error {
+		n++
+		return storer.ErrStop
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = r.BlameIncremental(ctx, commits[1], "b.txt", nil, func(e *BlameEntry) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+}
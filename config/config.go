@@ -8,8 +8,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v6/internal/url"
@@ -47,13 +49,34 @@ var (
 // Scope defines the scope of a config file, such as local, global or system.
 type Scope int
 
-// Available ConfigScope's
+// Available ConfigScope's. The worktree and command scopes are only part of
+// the layered View, they can not be used with LoadConfig.
 const (
 	LocalScope Scope = iota
 	GlobalScope
 	SystemScope
+	WorktreeScope
+	CommandScope
 )
 
+// String returns the name of the scope, as shown by git config --show-scope.
+func (s Scope) String() string {
+	switch s {
+	case LocalScope:
+		return "local"
+	case GlobalScope:
+		return "global"
+	case SystemScope:
+		return "system"
+	case WorktreeScope:
+		return "worktree"
+	case CommandScope:
+		return "command"
+	}
+
+	return "unknown"
+}
+
 // Config contains the repository configuration
 // https://www.kernel.org/pub/software/scm/git/docs/git-config.html#FILES
 type Config struct {
@@ -68,6 +91,30 @@ type Config struct {
 		CommentChar string
 		// RepositoryFormatVersion identifies the repository format and layout version.
 		RepositoryFormatVersion format.RepositoryFormatVersion
+		// AutoCRLF controls the end-of-line conversion of files without text
+		// attributes: "true" converts to CRLF on checkout, "input" only
+		// normalizes to LF when adding and "false" disables the conversion.
+		AutoCRLF string
+		// EOL sets the line ending of text files in the working tree when
+		// no eol attribute applies: "lf", "crlf" or "native", the default.
+		EOL string
+		// SafeCRLF controls the reaction to irreversible end-of-line
+		// conversions: "true" rejects them, "warn" reports them and "false"
+		// ignores them.
+		SafeCRLF string
+		// SparseCheckout enables the sparse checkout: only the files
+		// matching the patterns of $GIT_DIR/info/sparse-checkout are
+		// checked out.
+		SparseCheckout bool
+		// SparseCheckoutCone sets the cone mode of the sparse checkout,
+		// where the patterns are restricted to directories.
+		SparseCheckoutCone bool
+	}
+
+	Index struct {
+		// Sparse writes the index as a sparse index in cone mode, where
+		// the directories out of the sparse checkout are collapsed.
+		Sparse bool
 	}
 
 	User struct {
@@ -98,6 +145,51 @@ type Config struct {
 		Window uint
 	}
 
+	Fetch struct {
+		// RecurseSubmodules controls whether the populated submodules are
+		// fetched along with the repository, RecurseSubmodulesOnDemand
+		// fetching only the ones whose recorded commits are missing.
+		RecurseSubmodules RecurseSubmodules
+	}
+
+	Push struct {
+		// RecurseSubmodules controls whether the commits recorded for the
+		// submodules by the pushed commits must be on a remote,
+		// RecurseSubmodulesCheck refusing the push otherwise and
+		// RecurseSubmodulesOnDemand pushing the submodules first.
+		RecurseSubmodules RecurseSubmodules
+	}
+
+	Diff struct {
+		// Algorithm is the algorithm of the line diffs: "myers", the
+		// default, "minimal", "patience" or "histogram".
+		Algorithm string
+		// NoIndentHeuristic disables the indent heuristic, which shifts
+		// the groups of added or deleted lines to make them easier to
+		// read. It is set by diff.indentHeuristic=false.
+		NoIndentHeuristic bool
+		// WordRegex is the extended regular expression matching the words
+		// of the word diffs, for the paths whose diff driver has none.
+		WordRegex string
+		// RenameLimit is the maximum number of files compared when
+		// detecting the renames and the copies, 0 when not set. It is
+		// set by diff.renameLimit.
+		RenameLimit uint
+	}
+
+	Blame struct {
+		// IgnoreRevsFiles are the files listing the commits ignored by
+		// blame, one full hash per line, relative to the root of the
+		// worktree. An empty value of blame.ignoreRevsFile resets the list.
+		IgnoreRevsFiles []string
+	}
+
+	LFS struct {
+		// URL is the URL of the LFS server, overriding the one derived
+		// from the URL of the remote.
+		URL string
+	}
+
 	Init struct {
 		// DefaultBranch Allows overriding the default branch name
 		// e.g. when initializing a new repository or when cloning
@@ -114,6 +206,10 @@ type Config struct {
 		// This setting must not be changed after repository initialization
 		// (e.g. clone or init).
 		ObjectFormat format.ObjectFormat
+
+		// WorktreeConfig enables the config.worktree file of each
+		// worktree, holding the config of the worktree scope.
+		WorktreeConfig bool
 	}
 
 	Protocol struct {
@@ -142,6 +238,12 @@ type Config struct {
 	// URLs list of url rewrite rules, if repo url starts with URL.InsteadOf value, it will be replaced with the
 	// key instead.
 	URLs map[string]*URL
+	// Filters list of filter drivers, the key is the driver name and should
+	// equal Filter.Name.
+	Filters map[string]*Filter
+	// DiffDrivers list of diff drivers, the key is the driver name and
+	// should equal DiffDriver.Name.
+	DiffDrivers map[string]*DiffDriver
 	// Raw contains the raw information of a config file. The main goal is
 	// preserve the parsed information from the original format, to avoid
 	// dropping unsupported fields.
@@ -151,11 +253,13 @@ type Config struct {
 // NewConfig returns a new empty Config.
 func NewConfig() *Config {
 	config := &Config{
-		Remotes:    make(map[string]*RemoteConfig),
-		Submodules: make(map[string]*Submodule),
-		Branches:   make(map[string]*Branch),
-		URLs:       make(map[string]*URL),
-		Raw:        format.New(),
+		Remotes:     make(map[string]*RemoteConfig),
+		Submodules:  make(map[string]*Submodule),
+		Branches:    make(map[string]*Branch),
+		URLs:        make(map[string]*URL),
+		Filters:     make(map[string]*Filter),
+		DiffDrivers: make(map[string]*DiffDriver),
+		Raw:         format.New(),
 	}
 
 	config.Pack.Window = DefaultPackWindow
@@ -179,10 +283,34 @@ func ReadConfig(r io.Reader) (*Config, error) {
 	return cfg, nil
 }
 
+// ReadConfigWithIncludes reads a config file from a io.Reader, expanding the
+// files included by its include and includeIf sections.
+func ReadConfigWithIncludes(r io.Reader, o *format.IncludeOptions) (*Config, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := NewConfig()
+	if err = cfg.UnmarshalWithIncludes(b, o); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
 // LoadConfig loads a config file from a given scope. The returned Config,
-// contains exclusively information from the given scope. If it couldn't find a
-// config file to the given scope, an empty one is returned.
+// contains exclusively information from the given scope, and the files it
+// includes unconditionally. If it couldn't find a config file to the given
+// scope, an empty one is returned.
 func LoadConfig(scope Scope) (*Config, error) {
+	return LoadConfigWithIncludes(scope, &format.IncludeOptions{})
+}
+
+// LoadConfigWithIncludes loads a config file from a given scope, like
+// LoadConfig, evaluating the conditions of its includeIf sections against
+// the repository described by o. The includes are not expanded when o is nil.
+func LoadConfigWithIncludes(scope Scope, o *format.IncludeOptions) (*Config, error) {
 	if scope == LocalScope {
 		return nil, fmt.Errorf("LocalScope should be read from the a ConfigStorer")
 	}
@@ -203,17 +331,29 @@ func LoadConfig(scope Scope) (*Config, error) {
 		}
 
 		defer f.Close()
-		return ReadConfig(f)
+		if o == nil {
+			return ReadConfig(f)
+		}
+
+		fo := *o
+		fo.Path = file
+		return ReadConfigWithIncludes(f, &fo)
 	}
 
 	return NewConfig(), nil
 }
 
-// Paths returns the config file location for a given scope.
+// Paths returns the config file location for a given scope. The locations
+// can be overridden with the GIT_CONFIG_GLOBAL, GIT_CONFIG_SYSTEM and
+// GIT_CONFIG_NOSYSTEM environment variables.
 func Paths(scope Scope) ([]string, error) {
 	var files []string
 	switch scope {
 	case GlobalScope:
+		if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+			return []string{path}, nil
+		}
+
 		xdg := os.Getenv("XDG_CONFIG_HOME")
 		if xdg != "" {
 			files = append(files, filepath.Join(xdg, "git/config"))
@@ -229,6 +369,14 @@ func Paths(scope Scope) ([]string, error) {
 			filepath.Join(home, ".config/git/config"),
 		)
 	case SystemScope:
+		if noSystem, _ := ParseBool(os.Getenv("GIT_CONFIG_NOSYSTEM"), false); noSystem {
+			return nil, nil
+		}
+
+		if path := os.Getenv("GIT_CONFIG_SYSTEM"); path != "" {
+			return []string{path}, nil
+		}
+
 		files = append(files, "/etc/gitconfig")
 	}
 
@@ -257,6 +405,26 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	for name, f := range c.Filters {
+		if f.Name != name {
+			return ErrInvalid
+		}
+
+		if err := f.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for name, d := range c.DiffDrivers {
+		if d.Name != name {
+			return ErrInvalid
+		}
+
+		if err := d.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -273,12 +441,25 @@ const (
 	urlSection                 = "url"
 	extensionsSection          = "extensions"
 	protocolSection            = "protocol"
+	filterSection              = "filter"
+	lfsSection                 = "lfs"
+	indexSection               = "index"
+	fetchSection               = "fetch"
+	pushSection                = "push"
+	diffSection                = "diff"
+	blameSection               = "blame"
 	fetchKey                   = "fetch"
 	urlKey                     = "url"
 	pushurlKey                 = "pushurl"
 	bareKey                    = "bare"
 	worktreeKey                = "worktree"
 	commentCharKey             = "commentChar"
+	autocrlfKey                = "autocrlf"
+	eolKey                     = "eol"
+	safecrlfKey                = "safecrlf"
+	sparseCheckoutKey          = "sparseCheckout"
+	sparseCheckoutConeKey      = "sparseCheckoutCone"
+	sparseKey                  = "sparse"
 	windowKey                  = "window"
 	mergeKey                   = "merge"
 	rebaseKey                  = "rebase"
@@ -288,8 +469,14 @@ const (
 	defaultBranchKey           = "defaultBranch"
 	repositoryFormatVersionKey = "repositoryformatversion"
 	objectFormat               = "objectformat"
+	worktreeConfigKey          = "worktreeConfig"
 	mirrorKey                  = "mirror"
 	versionKey                 = "version"
+	recurseSubmodulesKey       = "recurseSubmodules"
+	algorithmKey               = "algorithm"
+	indentHeuristicKey         = "indentHeuristic"
+	renameLimitKey             = "renameLimit"
+	ignoreRevsFileKey          = "ignoreRevsFile"
 
 	// DefaultPackWindow holds the number of previous objects used to
 	// generate deltas. The value 10 is the same used by git command.
@@ -298,17 +485,43 @@ const (
 
 // Unmarshal parses a git-config file and stores it.
 func (c *Config) Unmarshal(b []byte) error {
-	r := bytes.NewBuffer(b)
-	d := format.NewDecoder(r)
+	return c.unmarshal(b, nil)
+}
+
+// UnmarshalWithIncludes parses a git-config file and stores it, expanding
+// the files included by its include and includeIf sections.
+func (c *Config) UnmarshalWithIncludes(b []byte, o *format.IncludeOptions) error {
+	if o == nil {
+		o = &format.IncludeOptions{}
+	}
+
+	return c.unmarshal(b, o)
+}
+
+func (c *Config) unmarshal(b []byte, o *format.IncludeOptions) error {
+	d := format.NewDecoder(bytes.NewBuffer(b))
 
 	c.Raw = format.New()
-	if err := d.Decode(c.Raw); err != nil {
+	var err error
+	if o != nil {
+		err = d.DecodeWithIncludes(c.Raw, o)
+	} else {
+		err = d.Decode(c.Raw)
+	}
+
+	if err != nil {
 		return err
 	}
 
 	c.unmarshalCore()
+	c.unmarshalIndex()
 	c.unmarshalUser()
 	c.unmarshalInit()
+	c.unmarshalLFS()
+	c.unmarshalDiff()
+	c.unmarshalBlame()
+	c.unmarshalRecurseSubmodules()
+	c.unmarshalExtensions()
 	if err := c.unmarshalPack(); err != nil {
 		return err
 	}
@@ -322,6 +535,10 @@ func (c *Config) Unmarshal(b []byte) error {
 		return err
 	}
 
+	if err := c.unmarshalFilters(); err != nil {
+		return err
+	}
+
 	if err := c.unmarshalProtocol(); err != nil {
 		return err
 	}
@@ -337,6 +554,29 @@ func (c *Config) unmarshalCore() {
 
 	c.Core.Worktree = s.Options.Get(worktreeKey)
 	c.Core.CommentChar = s.Options.Get(commentCharKey)
+	c.Core.AutoCRLF = s.Options.Get(autocrlfKey)
+	c.Core.EOL = s.Options.Get(eolKey)
+	c.Core.SafeCRLF = s.Options.Get(safecrlfKey)
+	c.Core.SparseCheckout = boolOption(s, sparseCheckoutKey)
+	c.Core.SparseCheckoutCone = boolOption(s, sparseCheckoutConeKey)
+}
+
+func (c *Config) unmarshalIndex() {
+	s := c.Raw.Section(indexSection)
+	c.Index.Sparse = boolOption(s, sparseKey)
+}
+
+// boolOption returns the boolean value of the option key of s, false when
+// missing or invalid.
+func boolOption(s *format.Section, key string) bool {
+	if !s.HasOption(key) {
+		return false
+	}
+
+	// Options without value are stored as empty, meaning true.
+	v := s.Options.Get(key)
+	b, _ := ParseBool(v, v == "")
+	return b
 }
 
 func (c *Config) unmarshalUser() {
@@ -401,6 +641,20 @@ func (c *Config) unmarshalURLs() error {
 	return nil
 }
 
+func (c *Config) unmarshalFilters() error {
+	s := c.Raw.Section(filterSection)
+	for _, sub := range s.Subsections {
+		f := &Filter{}
+		if err := f.unmarshal(sub); err != nil {
+			return err
+		}
+
+		c.Filters[f.Name] = f
+	}
+
+	return nil
+}
+
 func unmarshalSubmodules(fc *format.Config, submodules map[string]*Submodule) {
 	s := fc.Section(submoduleSection)
 	for _, sub := range s.Subsections {
@@ -452,9 +706,63 @@ func (c *Config) unmarshalInit() {
 	c.Init.DefaultBranch = s.Options.Get(defaultBranchKey)
 }
 
+func (c *Config) unmarshalExtensions() {
+	s := c.Raw.Section(extensionsSection)
+	if s.HasOption(worktreeConfigKey) {
+		// Options without value are stored as empty, meaning true.
+		v := s.Options.Get(worktreeConfigKey)
+		c.Extensions.WorktreeConfig, _ = ParseBool(v, v == "")
+	}
+}
+
+func (c *Config) unmarshalLFS() {
+	s := c.Raw.Section(lfsSection)
+	c.LFS.URL = s.Options.Get(urlKey)
+}
+
+func (c *Config) unmarshalDiff() {
+	s := c.Raw.Section(diffSection)
+	c.Diff.Algorithm = s.Options.Get(algorithmKey)
+	c.Diff.WordRegex = s.Options.Get(wordRegexKey)
+	if s.HasOption(indentHeuristicKey) {
+		v := s.Options.Get(indentHeuristicKey)
+		if enabled, err := ParseBool(v, v == ""); err == nil {
+			c.Diff.NoIndentHeuristic = !enabled
+		}
+	}
+
+	if limit, err := strconv.ParseUint(s.Options.Get(renameLimitKey), 10, 32); err == nil {
+		c.Diff.RenameLimit = uint(limit)
+	}
+
+	for _, sub := range s.Subsections {
+		d := &DiffDriver{}
+		d.unmarshal(sub)
+		c.DiffDrivers[d.Name] = d
+	}
+}
+
+func (c *Config) unmarshalBlame() {
+	c.Blame.IgnoreRevsFiles = nil
+	for _, file := range c.Raw.Section(blameSection).Options.GetAll(ignoreRevsFileKey) {
+		if file == "" {
+			c.Blame.IgnoreRevsFiles = nil
+			continue
+		}
+
+		c.Blame.IgnoreRevsFiles = append(c.Blame.IgnoreRevsFiles, file)
+	}
+}
+
+func (c *Config) unmarshalRecurseSubmodules() {
+	c.Fetch.RecurseSubmodules = parseRecurseSubmodules(c.Raw.Section(fetchSection).Options.Get(recurseSubmodulesKey))
+	c.Push.RecurseSubmodules = parseRecurseSubmodules(c.Raw.Section(pushSection).Options.Get(recurseSubmodulesKey))
+}
+
 // Marshal returns Config encoded as a git-config file.
 func (c *Config) Marshal() ([]byte, error) {
 	c.marshalCore()
+	c.marshalIndex()
 	c.marshalExtensions()
 	c.marshalUser()
 	c.marshalPack()
@@ -462,8 +770,13 @@ func (c *Config) Marshal() ([]byte, error) {
 	c.marshalSubmodules()
 	c.marshalBranches()
 	c.marshalURLs()
+	c.marshalFilters()
 	c.marshalProtocol()
 	c.marshalInit()
+	c.marshalLFS()
+	c.marshalDiff()
+	c.marshalBlame()
+	c.marshalRecurseSubmodules()
 
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(c.Raw); err != nil {
@@ -483,6 +796,39 @@ func (c *Config) marshalCore() {
 	if c.Core.Worktree != "" {
 		s.SetOption(worktreeKey, c.Core.Worktree)
 	}
+
+	if c.Core.AutoCRLF != "" {
+		s.SetOption(autocrlfKey, c.Core.AutoCRLF)
+	}
+
+	if c.Core.EOL != "" {
+		s.SetOption(eolKey, c.Core.EOL)
+	}
+
+	if c.Core.SafeCRLF != "" {
+		s.SetOption(safecrlfKey, c.Core.SafeCRLF)
+	}
+
+	setBoolOption(s, sparseCheckoutKey, c.Core.SparseCheckout)
+	setBoolOption(s, sparseCheckoutConeKey, c.Core.SparseCheckoutCone)
+}
+
+func (c *Config) marshalIndex() {
+	if !c.Index.Sparse && !c.Raw.HasSection(indexSection) {
+		return
+	}
+
+	setBoolOption(c.Raw.Section(indexSection), sparseKey, c.Index.Sparse)
+}
+
+// setBoolOption sets the option key of s to true when value is set, and to
+// false when unset and present.
+func setBoolOption(s *format.Section, key string, value bool) {
+	if value {
+		s.SetOption(key, "true")
+	} else if s.HasOption(key) {
+		s.SetOption(key, "false")
+	}
 }
 
 func (c *Config) marshalExtensions() {
@@ -492,6 +838,12 @@ func (c *Config) marshalExtensions() {
 		s := c.Raw.Section(extensionsSection)
 		s.SetOption(objectFormat, c.Extensions.ObjectFormat.String())
 	}
+
+	if c.Extensions.WorktreeConfig {
+		c.Raw.Section(extensionsSection).SetOption(worktreeConfigKey, "true")
+	} else if c.Raw.HasSection(extensionsSection) {
+		c.Raw.Section(extensionsSection).RemoveOption(worktreeConfigKey)
+	}
 }
 
 func (c *Config) marshalUser() {
@@ -613,6 +965,37 @@ func (c *Config) marshalURLs() {
 	}
 }
 
+func (c *Config) marshalFilters() {
+	if len(c.Filters) == 0 && !c.Raw.HasSection(filterSection) {
+		return
+	}
+
+	s := c.Raw.Section(filterSection)
+	newSubsections := make(format.Subsections, 0, len(c.Filters))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if filter, ok := c.Filters[subsection.Name]; ok {
+			newSubsections = append(newSubsections, filter.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(c.Filters))
+	for name := range c.Filters {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !added[name] {
+			newSubsections = append(newSubsections, c.Filters[name].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
 func (c *Config) marshalProtocol() {
 	// Only marshal protocol section if a version was set.
 	if c.Protocol.Version != DefaultProtocolVersion {
@@ -628,6 +1011,154 @@ func (c *Config) marshalInit() {
 	}
 }
 
+func (c *Config) marshalLFS() {
+	if c.LFS.URL == "" && !c.Raw.HasSection(lfsSection) {
+		return
+	}
+
+	s := c.Raw.Section(lfsSection)
+	if c.LFS.URL != "" {
+		s.SetOption(urlKey, c.LFS.URL)
+	} else {
+		s.RemoveOption(urlKey)
+	}
+}
+
+func (c *Config) marshalDiff() {
+	if c.Diff.Algorithm == "" && !c.Diff.NoIndentHeuristic && c.Diff.WordRegex == "" &&
+		c.Diff.RenameLimit == 0 && len(c.DiffDrivers) == 0 && !c.Raw.HasSection(diffSection) {
+		return
+	}
+
+	s := c.Raw.Section(diffSection)
+	if c.Diff.Algorithm != "" {
+		s.SetOption(algorithmKey, c.Diff.Algorithm)
+	} else {
+		s.RemoveOption(algorithmKey)
+	}
+
+	if c.Diff.NoIndentHeuristic {
+		s.SetOption(indentHeuristicKey, "false")
+	} else if s.HasOption(indentHeuristicKey) {
+		s.SetOption(indentHeuristicKey, "true")
+	}
+
+	if c.Diff.WordRegex != "" {
+		s.SetOption(wordRegexKey, c.Diff.WordRegex)
+	} else {
+		s.RemoveOption(wordRegexKey)
+	}
+
+	if c.Diff.RenameLimit != 0 {
+		s.SetOption(renameLimitKey, strconv.FormatUint(uint64(c.Diff.RenameLimit), 10))
+	} else {
+		s.RemoveOption(renameLimitKey)
+	}
+
+	newSubsections := make(format.Subsections, 0, len(c.DiffDrivers))
+	added := make(map[string]bool)
+	for _, subsection := range s.Subsections {
+		if d, ok := c.DiffDrivers[subsection.Name]; ok {
+			newSubsections = append(newSubsections, d.marshal())
+			added[subsection.Name] = true
+		}
+	}
+
+	names := make([]string, 0, len(c.DiffDrivers))
+	for name := range c.DiffDrivers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !added[name] {
+			newSubsections = append(newSubsections, c.DiffDrivers[name].marshal())
+		}
+	}
+
+	s.Subsections = newSubsections
+}
+
+// RecurseSubmodules defines whether the submodules are fetched, or pushed,
+// along with the repository.
+type RecurseSubmodules string
+
+const (
+	// RecurseSubmodulesNo never recurses into the submodules.
+	RecurseSubmodulesNo RecurseSubmodules = "no"
+	// RecurseSubmodulesYes fetches all the populated submodules.
+	RecurseSubmodulesYes RecurseSubmodules = "yes"
+	// RecurseSubmodulesOnDemand fetches the submodules missing the commits
+	// recorded by the fetched commits, or pushes the submodules whose
+	// commits recorded by the pushed commits are not on a remote.
+	RecurseSubmodulesOnDemand RecurseSubmodules = "on-demand"
+	// RecurseSubmodulesCheck refuses to push commits recording submodule
+	// commits which are not on a remote.
+	RecurseSubmodulesCheck RecurseSubmodules = "check"
+)
+
+// parseRecurseSubmodules parses a recurseSubmodules value, the booleans
+// being RecurseSubmodulesYes and RecurseSubmodulesNo.
+func parseRecurseSubmodules(v string) RecurseSubmodules {
+	switch r := RecurseSubmodules(strings.ToLower(v)); r {
+	case "", RecurseSubmodulesOnDemand, RecurseSubmodulesCheck:
+		return r
+	}
+
+	if b, err := ParseBool(v, false); err == nil {
+		if b {
+			return RecurseSubmodulesYes
+		}
+
+		return RecurseSubmodulesNo
+	}
+
+	return RecurseSubmodules(v)
+}
+
+func (c *Config) marshalBlame() {
+	if len(c.Blame.IgnoreRevsFiles) == 0 && !c.Raw.HasSection(blameSection) {
+		return
+	}
+
+	// The options are kept as they are when they give the same files,
+	// with their resets.
+	files := c.Blame.IgnoreRevsFiles
+	c.unmarshalBlame()
+	if slices.Equal(files, c.Blame.IgnoreRevsFiles) {
+		return
+	}
+
+	c.Blame.IgnoreRevsFiles = files
+	s := c.Raw.Section(blameSection)
+	s.RemoveOption(ignoreRevsFileKey)
+	for _, file := range files {
+		s.AddOption(ignoreRevsFileKey, file)
+	}
+}
+
+func (c *Config) marshalRecurseSubmodules() {
+	setRecurseSubmodulesOption(c.Raw, fetchSection, c.Fetch.RecurseSubmodules)
+	setRecurseSubmodulesOption(c.Raw, pushSection, c.Push.RecurseSubmodules)
+}
+
+// setRecurseSubmodulesOption sets the recurseSubmodules option of the
+// section, keeping the spelling of the value when equivalent.
+func setRecurseSubmodulesOption(raw *format.Config, section string, value RecurseSubmodules) {
+	if value == "" && !raw.HasSection(section) {
+		return
+	}
+
+	s := raw.Section(section)
+	switch {
+	case value == "":
+		s.RemoveOption(recurseSubmodulesKey)
+	case parseRecurseSubmodules(s.Options.Get(recurseSubmodulesKey)) != value:
+		s.SetOption(recurseSubmodulesKey, string(value))
+	}
+}
+
 // RemoteConfig contains the configuration for a given remote repository.
 type RemoteConfig struct {
 	// Name of the remote
@@ -8,6 +8,7 @@ import (
 
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-billy/v5/util"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/protocol"
 	"github.com/stretchr/testify/suite"
@@ -245,6 +246,36 @@ func (s *ConfigSuite) TestLoadConfigXDG() {
 	s.Equal("foo@foo.com", cfg.User.Email)
 }
 
+func (s *ConfigSuite) TestLoadConfigIncludes() {
+	home := s.T().TempDir()
+	s.T().Setenv("HOME", home)
+	s.T().Setenv("XDG_CONFIG_HOME", "")
+
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(`[user]
+	name = foo
+[include]
+	path = ~/.gitconfig-user
+[includeIf "onbranch:main"]
+	path = .gitconfig-main
+`), 0o644))
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig-user"), []byte("[user]\n\temail = foo@example.com\n"), 0o644))
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig-main"), []byte("[user]\n\tname = main\n"), 0o644))
+
+	cfg, err := LoadConfig(GlobalScope)
+	s.NoError(err)
+	s.Equal("foo", cfg.User.Name)
+	s.Equal("foo@example.com", cfg.User.Email)
+	s.Len(cfg.Raw.Includes, 1)
+
+	cfg, err = LoadConfigWithIncludes(GlobalScope, &format.IncludeOptions{Branch: "main"})
+	s.NoError(err)
+	s.Equal("main", cfg.User.Name)
+
+	cfg, err = LoadConfigWithIncludes(GlobalScope, nil)
+	s.NoError(err)
+	s.Equal("", cfg.User.Email)
+}
+
 func (s *ConfigSuite) TestValidateConfig() {
 	config := &Config{
 		Remotes: map[string]*RemoteConfig{
@@ -398,6 +429,109 @@ func (s *ConfigSuite) TestProtocol() {
 	s.NoError(err)
 }
 
+func (s *ConfigSuite) TestLFS() {
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal([]byte("[lfs]\n\turl = https://example.com/lfs\n")))
+	s.Equal("https://example.com/lfs", cfg.LFS.URL)
+
+	cfg.LFS.URL = ""
+	buf, err := cfg.Marshal()
+	s.NoError(err)
+	s.NotContains(string(buf), "url")
+
+	buf, err = NewConfig().Marshal()
+	s.NoError(err)
+	s.NotContains(string(buf), "[lfs]")
+}
+
+func (s *ConfigSuite) TestDiff() {
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal([]byte("[diff]\n\talgorithm = histogram\n\tindentHeuristic = false\n")))
+	s.Equal("histogram", cfg.Diff.Algorithm)
+	s.True(cfg.Diff.NoIndentHeuristic)
+
+	cfg.Diff.Algorithm = "patience"
+	cfg.Diff.NoIndentHeuristic = false
+	buf, err := cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(buf), "[diff]\n\talgorithm = patience\n\tindentHeuristic = true\n")
+
+	buf, err = NewConfig().Marshal()
+	s.NoError(err)
+	s.NotContains(string(buf), "[diff]")
+}
+
+func (s *ConfigSuite) TestDiffRenameLimit() {
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal([]byte("[diff]\n\trenameLimit = 400\n")))
+	s.Equal(uint(400), cfg.Diff.RenameLimit)
+
+	cfg.Diff.RenameLimit = 20
+	buf, err := cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(buf), "[diff]\n\trenameLimit = 20\n")
+
+	cfg.Diff.RenameLimit = 0
+	buf, err = cfg.Marshal()
+	s.NoError(err)
+	s.NotContains(string(buf), "renameLimit")
+}
+
+func (s *ConfigSuite) TestDiffDrivers() {
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal([]byte("[diff]\n\twordRegex = [^ ]+\n[diff \"go\"]\n\txfuncname = ^func (.*)$\n\ttextconv = cat\n")))
+	s.Equal("[^ ]+", cfg.Diff.WordRegex)
+	s.Require().Contains(cfg.DiffDrivers, "go")
+	s.Equal("^func (.*)$", cfg.DiffDrivers["go"].XFuncName)
+	s.NoError(cfg.Validate())
+
+	cfg.DiffDrivers["go"].WordRegex = "[a-z]+"
+	cfg.DiffDrivers["py"] = &DiffDriver{Name: "py", XFuncName: "^def .*"}
+	buf, err := cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(buf), "[diff \"go\"]\n\txfuncname = ^func (.*)$\n\ttextconv = cat\n\twordRegex = [a-z]+\n")
+	s.Contains(string(buf), "[diff \"py\"]\n\txfuncname = ^def .*\n")
+
+	cfg.DiffDrivers["rb"] = &DiffDriver{Name: "ruby"}
+	s.ErrorIs(cfg.Validate(), ErrInvalid)
+}
+
+func (s *ConfigSuite) TestBlame() {
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal([]byte("[blame]\n\tignoreRevsFile = old\n\tignoreRevsFile =\n\tignoreRevsFile = .git-blame-ignore-revs\n")))
+	s.Equal([]string{".git-blame-ignore-revs"}, cfg.Blame.IgnoreRevsFiles)
+
+	buf, err := cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(buf), "[blame]\n\tignoreRevsFile = old\n\tignoreRevsFile = \n")
+
+	cfg.Blame.IgnoreRevsFiles = []string{"a", "b"}
+	buf, err = cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(buf), "[blame]\n\tignoreRevsFile = a\n\tignoreRevsFile = b\n")
+
+	buf, err = NewConfig().Marshal()
+	s.NoError(err)
+	s.NotContains(string(buf), "[blame]")
+}
+
+func (s *ConfigSuite) TestRecurseSubmodules() {
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal([]byte("[fetch]\n\trecurseSubmodules = true\n[push]\n\trecurseSubmodules = on-demand\n")))
+	s.Equal(RecurseSubmodulesYes, cfg.Fetch.RecurseSubmodules)
+	s.Equal(RecurseSubmodulesOnDemand, cfg.Push.RecurseSubmodules)
+
+	cfg.Push.RecurseSubmodules = RecurseSubmodulesCheck
+	buf, err := cfg.Marshal()
+	s.NoError(err)
+	s.Contains(string(buf), "[fetch]\n\trecurseSubmodules = true\n")
+	s.Contains(string(buf), "[push]\n\trecurseSubmodules = check\n")
+
+	buf, err = NewConfig().Marshal()
+	s.NoError(err)
+	s.NotContains(string(buf), "recurseSubmodules")
+}
+
 func (s *ConfigSuite) TestUnmarshalRemotes() {
 	input := []byte(`[core]
 	bare = true
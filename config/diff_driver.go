@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+var (
+	errDiffDriverEmptyName = errors.New("diff driver config: empty name")
+)
+
+// DiffDriver contains the configuration of a diff driver, referenced by the
+// diff gitattribute.
+// https://git-scm.com/docs/gitattributes#_generating_diff_text
+type DiffDriver struct {
+	// Name of the diff driver.
+	Name string
+	// XFuncName are the extended regular expressions, separated by new
+	// lines, matching the function lines shown in the hunk headers.
+	XFuncName string
+	// WordRegex is the extended regular expression matching the words of
+	// the word diffs.
+	WordRegex string
+
+	raw *format.Subsection
+}
+
+// Validate validates fields of diff driver
+func (d *DiffDriver) Validate() error {
+	if d.Name == "" {
+		return errDiffDriverEmptyName
+	}
+
+	return nil
+}
+
+const (
+	xfuncnameKey = "xfuncname"
+	wordRegexKey = "wordRegex"
+)
+
+func (d *DiffDriver) unmarshal(s *format.Subsection) {
+	d.raw = s
+
+	d.Name = s.Name
+	d.XFuncName = s.Options.Get(xfuncnameKey)
+	d.WordRegex = s.Options.Get(wordRegexKey)
+}
+
+func (d *DiffDriver) marshal() *format.Subsection {
+	if d.raw == nil {
+		d.raw = &format.Subsection{}
+	}
+
+	d.raw.Name = d.Name
+
+	if d.XFuncName == "" {
+		d.raw.RemoveOption(xfuncnameKey)
+	} else {
+		d.raw.SetOption(xfuncnameKey, d.XFuncName)
+	}
+
+	if d.WordRegex == "" {
+		d.raw.RemoveOption(wordRegexKey)
+	} else {
+		d.raw.SetOption(wordRegexKey, d.WordRegex)
+	}
+
+	return d.raw
+}
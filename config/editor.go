@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+// ErrScopeNotWritable is returned when editing a scope without a config file.
+var ErrScopeNotWritable = errors.New("config: scope is not writable")
+
+// EditFile edits the config file at path in place with fn, keeping its
+// comments and formatting. The file is created if it does not exist.
+func EditFile(path string, fn func(*format.Editor) error) error {
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	e, err := format.NewEditor(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := fn(e); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, e.Bytes(), 0o644)
+}
+
+// EditScope edits the config file of the global or system scope in place with
+// fn, see EditFile. The first existing file of the scope is edited, as git
+// does, the default one being created otherwise.
+func EditScope(scope Scope, fn func(*format.Editor) error) error {
+	path, err := writablePath(scope)
+	if err != nil {
+		return err
+	}
+
+	return EditFile(path, fn)
+}
+
+// writablePath returns the config file written for scope.
+func writablePath(scope Scope) (string, error) {
+	if scope != GlobalScope && scope != SystemScope {
+		return "", fmt.Errorf("%w: %s", ErrScopeNotWritable, scope)
+	}
+
+	files, err := Paths(scope)
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrScopeNotWritable, scope)
+	}
+
+	// Like git, ~/.gitconfig is preferred to the XDG file, which is only
+	// written when it is the only one existing.
+	if scope == GlobalScope && os.Getenv("GIT_CONFIG_GLOBAL") == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+
+		files = append([]string{filepath.Join(home, ".gitconfig")}, files...)
+	}
+
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			return file, nil
+		}
+	}
+
+	return files[0], nil
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"errors"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+var (
+	errFilterEmptyName = errors.New("filter config: empty name")
+)
+
+// Filter contains the configuration of a filter driver, referenced by the
+// filter gitattribute.
+// https://git-scm.com/docs/gitattributes#_filter
+type Filter struct {
+	// Name of the filter driver.
+	Name string
+	// Clean is the command converting the content of a worktree file to
+	// the content stored in the object database. Occurrences of %f are
+	// replaced with the path of the file.
+	Clean string
+	// Smudge is the command converting the content of a blob to the
+	// content of the worktree file.
+	Smudge string
+	// Process is the command of a long-running filter process, used instead
+	// of Clean and Smudge when set.
+	Process string
+	// Required makes a failure of the filter, or a missing command, an
+	// error instead of leaving the content untouched.
+	Required bool
+
+	raw *format.Subsection
+}
+
+// Validate validates fields of filter
+func (f *Filter) Validate() error {
+	if f.Name == "" {
+		return errFilterEmptyName
+	}
+
+	return nil
+}
+
+const (
+	cleanKey    = "clean"
+	smudgeKey   = "smudge"
+	processKey  = "process"
+	requiredKey = "required"
+)
+
+func (f *Filter) unmarshal(s *format.Subsection) error {
+	f.raw = s
+
+	f.Name = s.Name
+	f.Clean = s.Options.Get(cleanKey)
+	f.Smudge = s.Options.Get(smudgeKey)
+	f.Process = s.Options.Get(processKey)
+
+	// Options without value are stored as empty, meaning true.
+	if s.Options.Has(requiredKey) {
+		v := s.Options.Get(requiredKey)
+		f.Required, _ = ParseBool(v, v == "")
+	}
+
+	return nil
+}
+
+func (f *Filter) marshal() *format.Subsection {
+	if f.raw == nil {
+		f.raw = &format.Subsection{}
+	}
+
+	f.raw.Name = f.Name
+
+	if f.Clean == "" {
+		f.raw.RemoveOption(cleanKey)
+	} else {
+		f.raw.SetOption(cleanKey, f.Clean)
+	}
+
+	if f.Smudge == "" {
+		f.raw.RemoveOption(smudgeKey)
+	} else {
+		f.raw.SetOption(smudgeKey, f.Smudge)
+	}
+
+	if f.Process == "" {
+		f.raw.RemoveOption(processKey)
+	} else {
+		f.raw.SetOption(processKey, f.Process)
+	}
+
+	if f.Required {
+		f.raw.SetOption(requiredKey, "true")
+	} else {
+		f.raw.RemoveOption(requiredKey)
+	}
+
+	return f.raw
+}
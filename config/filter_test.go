@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FilterSuite struct {
+	suite.Suite
+}
+
+func TestFilterSuite(t *testing.T) {
+	suite.Run(t, new(FilterSuite))
+}
+
+func (s *FilterSuite) TestValidateName() {
+	s.NoError((&Filter{Name: "lfs"}).Validate())
+	s.ErrorIs((&Filter{Clean: "cat"}).Validate(), errFilterEmptyName)
+}
+
+func (s *FilterSuite) TestUnmarshal() {
+	input := []byte(`[filter "lfs"]
+	clean = git-lfs clean -- %f
+	smudge = git-lfs smudge -- %f
+	process = git-lfs filter-process
+	required = true
+[filter "upper"]
+	clean = tr a-z A-Z
+`)
+
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal(input))
+	s.Len(cfg.Filters, 2)
+
+	lfs := cfg.Filters["lfs"]
+	s.Equal("lfs", lfs.Name)
+	s.Equal("git-lfs clean -- %f", lfs.Clean)
+	s.Equal("git-lfs smudge -- %f", lfs.Smudge)
+	s.Equal("git-lfs filter-process", lfs.Process)
+	s.True(lfs.Required)
+
+	s.False(cfg.Filters["upper"].Required)
+	s.NoError(cfg.Validate())
+}
+
+func (s *FilterSuite) TestUnmarshalGitBooleans() {
+	input := []byte(`[filter "yes"]
+	required = yes
+[filter "off"]
+	required = off
+[filter "bare"]
+	required
+[filter "bad"]
+	required = maybe
+`)
+
+	cfg := NewConfig()
+	s.NoError(cfg.Unmarshal(input))
+	s.True(cfg.Filters["yes"].Required)
+	s.False(cfg.Filters["off"].Required)
+	s.True(cfg.Filters["bare"].Required)
+	s.False(cfg.Filters["bad"].Required)
+}
+
+func (s *FilterSuite) TestMarshal() {
+	expected := []byte(`[core]
+	bare = false
+[filter "crypt"]
+	clean = crypt encrypt
+	smudge = crypt decrypt
+	required = true
+`)
+
+	cfg := NewConfig()
+	cfg.Filters["crypt"] = &Filter{
+		Name:     "crypt",
+		Clean:    "crypt encrypt",
+		Smudge:   "crypt decrypt",
+		Required: true,
+	}
+
+	b, err := cfg.Marshal()
+	s.NoError(err)
+	s.Equal(string(expected), string(b))
+}
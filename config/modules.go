@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"regexp"
+	"sort"
 
 	format "github.com/go-git/go-git/v6/plumbing/format/config"
 )
@@ -39,6 +40,25 @@ func NewModules() *Modules {
 const (
 	pathKey   = "path"
 	branchKey = "branch"
+	ignoreKey = "ignore"
+)
+
+// SubmoduleIgnore defines which changes of a submodule are ignored by the
+// status and the diffs of the superproject.
+type SubmoduleIgnore string
+
+const (
+	// SubmoduleIgnoreNone reports the new commits, the modified content and
+	// the untracked content of the submodule, the default.
+	SubmoduleIgnoreNone SubmoduleIgnore = "none"
+	// SubmoduleIgnoreUntracked ignores the untracked content of the
+	// submodule.
+	SubmoduleIgnoreUntracked SubmoduleIgnore = "untracked"
+	// SubmoduleIgnoreDirty ignores the modified and the untracked content of
+	// the submodule, only reporting its new commits.
+	SubmoduleIgnoreDirty SubmoduleIgnore = "dirty"
+	// SubmoduleIgnoreAll ignores every change of the submodule.
+	SubmoduleIgnoreAll SubmoduleIgnore = "all"
 )
 
 // Unmarshal parses a git-config file and stores it.
@@ -57,15 +77,32 @@ func (m *Modules) Unmarshal(b []byte) error {
 
 // Marshal returns Modules encoded as a git-config file.
 func (m *Modules) Marshal() ([]byte, error) {
+	// The submodules are kept in the order of the file, the new ones being
+	// appended sorted by name.
 	s := m.raw.Section(submoduleSection)
-	s.Subsections = make(format.Subsections, len(m.Submodules))
+	subsections := make(format.Subsections, 0, len(m.Submodules))
+	added := make(map[string]bool)
+	for _, sub := range s.Subsections {
+		if r, ok := m.Submodules[sub.Name]; ok && !added[sub.Name] {
+			subsections = append(subsections, r.marshal())
+			added[sub.Name] = true
+		}
+	}
 
-	var i int
-	for _, r := range m.Submodules {
-		s.Subsections[i] = r.marshal()
-		i++
+	names := make([]string, 0, len(m.Submodules))
+	for name := range m.Submodules {
+		if !added[name] {
+			names = append(names, name)
+		}
 	}
 
+	sort.Strings(names)
+	for _, name := range names {
+		subsections = append(subsections, m.Submodules[name].marshal())
+	}
+
+	s.Subsections = subsections
+
 	buf := bytes.NewBuffer(nil)
 	if err := format.NewEncoder(buf).Encode(m.raw); err != nil {
 		return nil, err
@@ -86,6 +123,9 @@ type Submodule struct {
 	// Branch is a remote branch name for tracking updates in the upstream
 	// submodule. Optional value.
 	Branch string
+	// Ignore defines which changes of the submodule are ignored by the
+	// status and the diffs. Optional value.
+	Ignore SubmoduleIgnore
 
 	// raw representation of the subsection, filled by marshal or unmarshal are
 	// called.
@@ -116,6 +156,7 @@ func (m *Submodule) unmarshal(s *format.Subsection) {
 	m.Path = m.raw.Option(pathKey)
 	m.URL = m.raw.Option(urlKey)
 	m.Branch = m.raw.Option(branchKey)
+	m.Ignore = SubmoduleIgnore(m.raw.Option(ignoreKey))
 }
 
 func (m *Submodule) marshal() *format.Subsection {
@@ -133,6 +174,14 @@ func (m *Submodule) marshal() *format.Subsection {
 
 	if m.Branch != "" {
 		m.raw.SetOption(branchKey, m.Branch)
+	} else {
+		m.raw.RemoveOption(branchKey)
+	}
+
+	if m.Ignore != "" {
+		m.raw.SetOption(ignoreKey, string(m.Ignore))
+	} else {
+		m.raw.RemoveOption(ignoreKey)
 	}
 
 	return m.raw
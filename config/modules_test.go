@@ -102,3 +102,54 @@ func (s *ModulesSuite) TestUnmarshalMarshal() {
 	s.NoError(err)
 	s.Equal(string(input), string(output))
 }
+
+func (s *ModulesSuite) TestUnmarshalIgnore() {
+	input := []byte(`[submodule "qux"]
+	path = qux
+	url = https://github.com/foo/qux.git
+	ignore = dirty
+`)
+
+	cfg := NewModules()
+	s.NoError(cfg.Unmarshal(input))
+	s.Equal(SubmoduleIgnoreDirty, cfg.Submodules["qux"].Ignore)
+
+	cfg.Submodules["qux"].Ignore = ""
+	output, err := cfg.Marshal()
+	s.NoError(err)
+	s.NotContains(string(output), "ignore")
+}
+
+func (s *ModulesSuite) TestUnmarshalMarshalKeepsOrder() {
+	input := []byte(`[submodule "qux"]
+	path = qux
+	url = https://github.com/foo/qux.git
+	branch = dev
+[submodule "bar"]
+	path = bar
+	url = https://github.com/foo/bar.git
+`)
+
+	cfg := NewModules()
+	s.NoError(cfg.Unmarshal(input))
+
+	cfg.Submodules["qux"].Branch = ""
+	cfg.Submodules["baz"] = &Submodule{Name: "baz", Path: "baz", URL: "https://github.com/foo/baz.git"}
+	cfg.Submodules["aaa"] = &Submodule{Name: "aaa", Path: "aaa", URL: "https://github.com/foo/aaa.git"}
+
+	output, err := cfg.Marshal()
+	s.NoError(err)
+	s.Equal(`[submodule "qux"]
+	path = qux
+	url = https://github.com/foo/qux.git
+[submodule "bar"]
+	path = bar
+	url = https://github.com/foo/bar.git
+[submodule "aaa"]
+	path = aaa
+	url = https://github.com/foo/aaa.git
+[submodule "baz"]
+	path = baz
+	url = https://github.com/foo/baz.git
+`, string(output))
+}
@@ -0,0 +1,278 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidValue is returned when a config value can not be parsed as
+	// the requested type.
+	ErrInvalidValue = errors.New("config: invalid value")
+)
+
+// ParseBool parses a boolean config value following git: true, yes and on
+// are true, false, no, off and the empty string are false, and integers are
+// true when not zero. noValue is set for options without `=`, which are true.
+func ParseBool(value string, noValue bool) (bool, error) {
+	if noValue {
+		return true, nil
+	}
+
+	switch strings.ToLower(value) {
+	case "true", "yes", "on":
+		return true, nil
+	case "false", "no", "off", "":
+		return false, nil
+	}
+
+	n, err := ParseInt(value)
+	if err != nil {
+		return false, fmt.Errorf("%w: bad boolean %q", ErrInvalidValue, value)
+	}
+
+	return n != 0, nil
+}
+
+// ParseInt parses an integer config value following git, with an optional
+// unit suffix: k, m or g, scaling the value by 1024, 1024² or 1024³.
+func ParseInt(value string) (int64, error) {
+	v := strings.TrimSpace(value)
+	var factor int64 = 1
+	if v != "" {
+		switch v[len(v)-1] {
+		case 'k', 'K':
+			factor = 1 << 10
+		case 'm', 'M':
+			factor = 1 << 20
+		case 'g', 'G':
+			factor = 1 << 30
+		}
+	}
+
+	if factor != 1 {
+		v = v[:len(v)-1]
+	}
+
+	n, err := strconv.ParseInt(v, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad numeric value %q", ErrInvalidValue, value)
+	}
+
+	if n > math.MaxInt64/factor || n < math.MinInt64/factor {
+		return 0, fmt.Errorf("%w: numeric value %q out of range", ErrInvalidValue, value)
+	}
+
+	return n * factor, nil
+}
+
+// ExpandPath expands a path config value following git: a leading `~/` is
+// replaced by the home directory of the current user, and `~user/` by the
+// one of the given user.
+func ExpandPath(value string) (string, error) {
+	if !strings.HasPrefix(value, "~") {
+		return value, nil
+	}
+
+	name, rest, _ := strings.Cut(value[1:], "/")
+
+	var home string
+	if name == "" {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return "", err
+		}
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("%w: failed to expand user dir in %q: %w", ErrInvalidValue, value, err)
+		}
+
+		home = u.HomeDir
+	}
+
+	return filepath.Join(home, rest), nil
+}
+
+var colorNames = []string{"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white"}
+
+var colorAttributes = map[string]int{
+	"bold": 1, "dim": 2, "italic": 3, "ul": 4, "blink": 5, "reverse": 7, "strike": 9,
+}
+
+// colorAttributesOff are the codes turning the attributes off.
+var colorAttributesOff = map[string]int{
+	"bold": 22, "dim": 22, "italic": 23, "ul": 24, "blink": 25, "reverse": 27, "strike": 29,
+}
+
+// ParseColor parses a color config value following git, returning the ANSI
+// escape sequence it stands for. The value lists up to two colors, the
+// foreground and the background, and attributes. Colors are names, with an
+// optional bright prefix, 256 color numbers or #RRGGBB values. Attributes
+// are bold, dim, italic, ul, blink, reverse and strike, turned off with a
+// no or no- prefix, and reset.
+func ParseColor(value string) (string, error) {
+	var fg, bg string
+	var attrs []string
+	var colors int
+	for _, word := range strings.Fields(value) {
+		word = strings.ToLower(word)
+		if f, b, ok := parseColorWord(word); ok {
+			switch colors {
+			case 0:
+				fg = f
+			case 1:
+				bg = b
+			default:
+				return "", fmt.Errorf("%w: bad color %q", ErrInvalidValue, value)
+			}
+
+			colors++
+			continue
+		}
+
+		if word == "reset" {
+			attrs = append(attrs, "0")
+			continue
+		}
+
+		off := strings.TrimPrefix(strings.TrimPrefix(word, "no"), "-")
+		if code, ok := colorAttributes[word]; ok {
+			attrs = append(attrs, strconv.Itoa(code))
+		} else if code, ok := colorAttributesOff[off]; ok && off != word {
+			attrs = append(attrs, strconv.Itoa(code))
+		} else {
+			return "", fmt.Errorf("%w: bad color %q", ErrInvalidValue, value)
+		}
+	}
+
+	codes := attrs
+	for _, c := range []string{fg, bg} {
+		if c != "" {
+			codes = append(codes, c)
+		}
+	}
+
+	if len(codes) == 0 {
+		return "", nil
+	}
+
+	if len(codes) == 1 && codes[0] == "0" {
+		return "\x1b[m", nil
+	}
+
+	return "\x1b[" + strings.Join(codes, ";") + "m", nil
+}
+
+// parseColorWord parses a color, returning its codes as foreground and as
+// background, empty for normal.
+func parseColorWord(word string) (fg, bg string, ok bool) {
+	switch word {
+	case "normal":
+		return "", "", true
+	case "default":
+		return "39", "49", true
+	}
+
+	for i, name := range colorNames {
+		switch word {
+		case name:
+			return strconv.Itoa(30 + i), strconv.Itoa(40 + i), true
+		case "bright" + name:
+			return strconv.Itoa(90 + i), strconv.Itoa(100 + i), true
+		}
+	}
+
+	if n, err := strconv.Atoi(word); err == nil && n >= 0 && n <= 255 {
+		return fmt.Sprintf("38;5;%d", n), fmt.Sprintf("48;5;%d", n), true
+	}
+
+	if len(word) == 7 && word[0] == '#' {
+		if rgb, err := strconv.ParseUint(word[1:], 16, 32); err == nil {
+			c := fmt.Sprintf("2;%d;%d;%d", rgb>>16, (rgb>>8)&0xff, rgb&0xff)
+			return "38;" + c, "48;" + c, true
+		}
+	}
+
+	return "", "", false
+}
+
+// ExpireNever and ExpireAll are the expiry dates of the never and all, or
+// now, values: nothing is older than ExpireNever, and everything is older
+// than ExpireAll.
+var (
+	ExpireNever = time.Time{}
+	ExpireAll   = time.Unix(1<<62, 0)
+)
+
+var relativeDate = regexp.MustCompile(`^(\d+)[ .]*(second|minute|hour|day|week|month|year)s?[ .]*(ago)?$`)
+
+// ParseExpiryDate parses an expiry date config value following git, relative
+// to now: never and false are ExpireNever, all and now are ExpireAll, and
+// other values are absolute dates or relative ones such as 2.weeks.ago.
+func ParseExpiryDate(value string, now time.Time) (time.Time, error) {
+	v := strings.ToLower(strings.TrimSpace(value))
+	switch v {
+	case "never", "false":
+		return ExpireNever, nil
+	case "all", "now":
+		return ExpireAll, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	if m := relativeDate.FindStringSubmatch(v); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: bad expiry date %q", ErrInvalidValue, value)
+		}
+
+		switch m[2] {
+		case "second":
+			return now.Add(-time.Duration(n) * time.Second), nil
+		case "minute":
+			return now.Add(-time.Duration(n) * time.Minute), nil
+		case "hour":
+			return now.Add(-time.Duration(n) * time.Hour), nil
+		case "day":
+			return now.AddDate(0, 0, -n), nil
+		case "week":
+			return now.AddDate(0, 0, -7*n), nil
+		case "month":
+			return now.AddDate(0, -n, 0), nil
+		default:
+			return now.AddDate(-n, 0, 0), nil
+		}
+	}
+
+	if strings.HasPrefix(v, "@") {
+		if sec, err := strconv.ParseInt(v[1:], 10, 64); err == nil {
+			return time.Unix(sec, 0), nil
+		}
+	}
+
+	for _, layout := range []string{
+		time.RFC3339,
+		time.RFC1123Z,
+		time.RFC1123,
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"Mon Jan 2 15:04:05 2006 -0700",
+	} {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(value), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: bad expiry date %q", ErrInvalidValue, value)
+}
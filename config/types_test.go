@@ -0,0 +1,103 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBool(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"true": true, "Yes": true, "on": true, "1": true, "-2": true,
+		"false": false, "NO": false, "off": false, "": false, "0": false,
+	} {
+		v, err := ParseBool(value, false)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, v, value)
+	}
+
+	v, err := ParseBool("", true)
+	require.NoError(t, err)
+	assert.True(t, v)
+
+	_, err = ParseBool("maybe", false)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+}
+
+func TestParseInt(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"0": 0, "42": 42, "-3": -3, "1k": 1024, "2M": 2 << 20, "1g": 1 << 30, "0x10": 16,
+	} {
+		v, err := ParseInt(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, v, value)
+	}
+
+	for _, value := range []string{"", "k", "1x", "9999999999g"} {
+		_, err := ParseInt(value)
+		assert.ErrorIs(t, err, ErrInvalidValue, value)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	p, err := ExpandPath("~/foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, home+"/foo/bar", p)
+
+	p, err = ExpandPath("/foo/~")
+	require.NoError(t, err)
+	assert.Equal(t, "/foo/~", p)
+}
+
+func TestParseColor(t *testing.T) {
+	for value, expected := range map[string]string{
+		"":                  "",
+		"normal":            "",
+		"reset":             "\x1b[m",
+		"red":               "\x1b[31m",
+		"bold red":          "\x1b[1;31m",
+		"red blue":          "\x1b[31;44m",
+		"brightgreen ul":    "\x1b[4;92m",
+		"normal black":      "\x1b[40m",
+		"208 #ff0000":       "\x1b[38;5;208;48;2;255;0;0m",
+		"nobold no-reverse": "\x1b[22;27m",
+	} {
+		v, err := ParseColor(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, v, value)
+	}
+
+	for _, value := range []string{"red blue green", "purple", "256"} {
+		_, err := ParseColor(value)
+		assert.ErrorIs(t, err, ErrInvalidValue, value)
+	}
+}
+
+func TestParseExpiryDate(t *testing.T) {
+	now := time.Date(2020, 3, 15, 12, 0, 0, 0, time.UTC)
+	for value, expected := range map[string]time.Time{
+		"never":        ExpireNever,
+		"false":        ExpireNever,
+		"all":          ExpireAll,
+		"now":          ExpireAll,
+		"yesterday":    now.AddDate(0, 0, -1),
+		"2.weeks.ago":  now.AddDate(0, 0, -14),
+		"90 days ago":  now.AddDate(0, 0, -90),
+		"1.hour.ago":   now.Add(-time.Hour),
+		"3.months.ago": now.AddDate(0, -3, 0),
+		"@1500000000":  time.Unix(1500000000, 0),
+		"2019-01-02":   time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC),
+	} {
+		v, err := ParseExpiryDate(value, now)
+		require.NoError(t, err, value)
+		assert.True(t, expected.Equal(v), "%s: %s", value, v)
+	}
+
+	_, err := ParseExpiryDate("someday", now)
+	assert.ErrorIs(t, err, ErrInvalidValue)
+}
@@ -0,0 +1,331 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+// ErrKeyNotFound is returned when a key has no value in a View.
+var ErrKeyNotFound = errors.New("config: key not found")
+
+// Origin is where a config value was read from.
+type Origin struct {
+	Scope Scope
+	// File is the path of the config file, empty for the values of the
+	// command scope, set through the environment.
+	File string
+	// Line is the line of the value in File, starting at 1.
+	Line int
+}
+
+// String returns the origin as shown by git config --show-origin.
+func (o Origin) String() string {
+	if o.Scope == CommandScope {
+		return "command line:"
+	}
+
+	return "file:" + o.File
+}
+
+// Value is a value of a config key, along with its origin.
+type Value struct {
+	// Key is the canonical name of the key: the section and the name in
+	// lower case, and the subsection if any, separated by dots.
+	Key   string
+	Value string
+	// NoValue is set for the options without `=`, which are boolean true.
+	NoValue bool
+	Origin  Origin
+}
+
+// View is a read-only view of the config of every scope, in the order they
+// are read by git: system, global, local, worktree and command. It keeps
+// every value of a key along with its origin, the last one taking
+// precedence.
+type View struct {
+	values []*Value
+}
+
+// NewView returns an empty View.
+func NewView() *View {
+	return &View{}
+}
+
+// AddFile adds the values of a config file of the given scope, expanding its
+// includes as described by o when not nil. path is the path of the file,
+// used as origin of its values.
+func (v *View) AddFile(scope Scope, path string, r io.Reader, o *format.IncludeOptions) error {
+	if o != nil {
+		fo := *o
+		fo.Path = path
+		o = &fo
+	}
+
+	entries, err := format.ReadEntries(r, o)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		file := e.File
+		if file == "" {
+			file = path
+		}
+
+		v.values = append(v.values, &Value{
+			Key:     canonicalKey(e.Section, e.Subsection, e.Key),
+			Value:   e.Value,
+			NoValue: e.NoValue,
+			Origin:  Origin{Scope: scope, File: file, Line: e.Line},
+		})
+	}
+
+	return nil
+}
+
+// AddScope adds the values of the config files of the system or global
+// scope. Every existing file of the scope is read, as git does, and the
+// environment variables GIT_CONFIG_SYSTEM, GIT_CONFIG_GLOBAL and
+// GIT_CONFIG_NOSYSTEM are honored.
+func (v *View) AddScope(scope Scope, o *format.IncludeOptions) error {
+	files, err := layeredPaths(scope)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if err := v.addPath(scope, file, o); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *View) addPath(scope Scope, path string, o *format.IncludeOptions) error {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer f.Close()
+	return v.AddFile(scope, path, f, o)
+}
+
+// layeredPaths returns the config files of scope in the order git reads them.
+func layeredPaths(scope Scope) ([]string, error) {
+	switch scope {
+	case SystemScope:
+		return Paths(scope)
+	case GlobalScope:
+		if path := os.Getenv("GIT_CONFIG_GLOBAL"); path != "" {
+			return []string{path}, nil
+		}
+
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+
+		xdg := filepath.Join(home, ".config")
+		if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+			xdg = dir
+		}
+
+		return []string{
+			filepath.Join(xdg, "git/config"),
+			filepath.Join(home, ".gitconfig"),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("config: scope %s has no files", scope)
+}
+
+// AddEnv adds the values of the command scope set in environ, a list of
+// key=value pairs like os.Environ, through GIT_CONFIG_COUNT and the
+// GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n> variables.
+func (v *View) AddEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if k, val, ok := strings.Cut(kv, "="); ok {
+			env[k] = val
+		}
+	}
+
+	count, ok := env["GIT_CONFIG_COUNT"]
+	if !ok || count == "" {
+		return nil
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%w: bogus count in GIT_CONFIG_COUNT: %q", ErrInvalidValue, count)
+	}
+
+	for i := 0; i < n; i++ {
+		key, ok := env[fmt.Sprintf("GIT_CONFIG_KEY_%d", i)]
+		if !ok {
+			return fmt.Errorf("%w: missing config key GIT_CONFIG_KEY_%d", ErrInvalidValue, i)
+		}
+
+		value, ok := env[fmt.Sprintf("GIT_CONFIG_VALUE_%d", i)]
+		if !ok {
+			return fmt.Errorf("%w: missing config value GIT_CONFIG_VALUE_%d", ErrInvalidValue, i)
+		}
+
+		section, subsection, name, err := SplitKey(key)
+		if err != nil {
+			return err
+		}
+
+		v.values = append(v.values, &Value{
+			Key:    canonicalKey(section, subsection, name),
+			Value:  value,
+			Origin: Origin{Scope: CommandScope},
+		})
+	}
+
+	return nil
+}
+
+// SplitKey splits a key in its section, subsection and name: the section is
+// up to the first dot and the name after the last one.
+func SplitKey(key string) (section, subsection, name string, err error) {
+	first := strings.IndexByte(key, '.')
+	last := strings.LastIndexByte(key, '.')
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", fmt.Errorf("%w: invalid key %q", ErrInvalidValue, key)
+	}
+
+	section, name = key[:first], key[last+1:]
+	if first != last {
+		subsection = key[first+1 : last]
+	}
+
+	return section, subsection, name, nil
+}
+
+func canonicalKey(section, subsection, name string) string {
+	if subsection == "" {
+		return strings.ToLower(section) + "." + strings.ToLower(name)
+	}
+
+	return strings.ToLower(section) + "." + subsection + "." + strings.ToLower(name)
+}
+
+// Values returns every value of the view in order, like git config --list.
+func (v *View) Values() []*Value {
+	return v.values
+}
+
+// GetAll returns every value of the key in order, like git config --get-all.
+func (v *View) GetAll(key string) []*Value {
+	section, subsection, name, err := SplitKey(key)
+	if err != nil {
+		return nil
+	}
+
+	key = canonicalKey(section, subsection, name)
+
+	var values []*Value
+	for _, val := range v.values {
+		if val.Key == key {
+			values = append(values, val)
+		}
+	}
+
+	return values
+}
+
+// Get returns the value of the key taking precedence, the last one.
+func (v *View) Get(key string) (*Value, bool) {
+	values := v.GetAll(key)
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	return values[len(values)-1], true
+}
+
+func (v *View) get(key string) (*Value, error) {
+	val, ok := v.Get(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, key)
+	}
+
+	return val, nil
+}
+
+// String returns the value of the key, see Get.
+func (v *View) String(key string) (string, error) {
+	val, err := v.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	return val.Value, nil
+}
+
+// Bool returns the value of the key as a boolean, see ParseBool.
+func (v *View) Bool(key string) (bool, error) {
+	val, err := v.get(key)
+	if err != nil {
+		return false, err
+	}
+
+	return ParseBool(val.Value, val.NoValue)
+}
+
+// Int returns the value of the key as an integer, see ParseInt.
+func (v *View) Int(key string) (int64, error) {
+	val, err := v.get(key)
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseInt(val.Value)
+}
+
+// Path returns the value of the key as a path, see ExpandPath.
+func (v *View) Path(key string) (string, error) {
+	val, err := v.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	return ExpandPath(val.Value)
+}
+
+// Color returns the value of the key as an ANSI escape sequence, see
+// ParseColor.
+func (v *View) Color(key string) (string, error) {
+	val, err := v.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	return ParseColor(val.Value)
+}
+
+// ExpiryDate returns the value of the key as an expiry date relative to now,
+// see ParseExpiryDate.
+func (v *View) ExpiryDate(key string, now time.Time) (time.Time, error) {
+	val, err := v.get(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return ParseExpiryDate(val.Value, now)
+}
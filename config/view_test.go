@@ -0,0 +1,152 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/stretchr/testify/suite"
+)
+
+type ViewSuite struct {
+	suite.Suite
+	home string
+}
+
+func TestViewSuite(t *testing.T) {
+	suite.Run(t, new(ViewSuite))
+}
+
+func (s *ViewSuite) SetupTest() {
+	s.home = s.T().TempDir()
+	s.T().Setenv("HOME", s.home)
+	s.T().Setenv("XDG_CONFIG_HOME", "")
+	s.T().Setenv("GIT_CONFIG_GLOBAL", "")
+	s.T().Setenv("GIT_CONFIG_NOSYSTEM", "true")
+}
+
+func (s *ViewSuite) write(name, content string) string {
+	path := filepath.Join(s.home, name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func (s *ViewSuite) TestAddScope() {
+	xdg := s.write(".config/git/config", "[core]\n\teditor = vi\n")
+	home := s.write(".gitconfig", "[user]\n\tname = foo\n[core]\n\teditor = emacs\n[include]\n\tpath = inc\n")
+	inc := s.write("inc", "[user]\n\temail = foo@example.com\n")
+
+	v := NewView()
+	s.Require().NoError(v.AddScope(SystemScope, &format.IncludeOptions{}))
+	s.Require().NoError(v.AddScope(GlobalScope, &format.IncludeOptions{}))
+
+	values := v.GetAll("Core.Editor")
+	s.Require().Len(values, 2)
+	s.Equal(Origin{Scope: GlobalScope, File: xdg, Line: 2}, values[0].Origin)
+	s.Equal(Origin{Scope: GlobalScope, File: home, Line: 4}, values[1].Origin)
+
+	editor, err := v.String("core.editor")
+	s.NoError(err)
+	s.Equal("emacs", editor)
+
+	email, ok := v.Get("user.email")
+	s.Require().True(ok)
+	s.Equal("foo@example.com", email.Value)
+	s.Equal("file:"+inc, email.Origin.String())
+
+	_, err = v.String("user.signingkey")
+	s.ErrorIs(err, ErrKeyNotFound)
+}
+
+func (s *ViewSuite) TestGlobalEnv() {
+	path := s.write("custom", "[user]\n\tname = custom\n")
+	s.write(".gitconfig", "[user]\n\tname = foo\n")
+	s.T().Setenv("GIT_CONFIG_GLOBAL", path)
+
+	v := NewView()
+	s.Require().NoError(v.AddScope(GlobalScope, nil))
+
+	name, err := v.String("user.name")
+	s.NoError(err)
+	s.Equal("custom", name)
+}
+
+func (s *ViewSuite) TestAddEnv() {
+	v := NewView()
+	s.Require().NoError(v.AddFile(LocalScope, "config", strings.NewReader(
+		"[core]\n\tbare\n[pack]\n\twindow = 1k\n[remote \"Origin.x\"]\n\turl = a\n"), nil))
+	s.Require().NoError(v.AddEnv([]string{
+		"GIT_CONFIG_COUNT=2",
+		"GIT_CONFIG_KEY_0=core.bare",
+		"GIT_CONFIG_VALUE_0=no",
+		"GIT_CONFIG_KEY_1=remote.Origin.x.URL",
+		"GIT_CONFIG_VALUE_1=b",
+	}))
+
+	bare := v.GetAll("core.bare")
+	s.Require().Len(bare, 2)
+	s.True(bare[0].NoValue)
+	s.Equal(Origin{Scope: LocalScope, File: "config", Line: 2}, bare[0].Origin)
+	s.Equal("command line:", bare[1].Origin.String())
+
+	b, err := v.Bool("core.bare")
+	s.NoError(err)
+	s.False(b)
+
+	n, err := v.Int("pack.window")
+	s.NoError(err)
+	s.Equal(int64(1024), n)
+
+	urls := v.GetAll("remote.Origin.x.url")
+	s.Len(urls, 2)
+	s.Len(v.GetAll("remote.origin.x.url"), 0)
+	s.Len(v.Values(), 5)
+
+	s.Error(NewView().AddEnv([]string{"GIT_CONFIG_COUNT=1"}))
+	s.Error(NewView().AddEnv([]string{"GIT_CONFIG_COUNT=x"}))
+}
+
+func (s *ViewSuite) TestEditScope() {
+	path := s.write(".gitconfig", "# mine\n[user]\n\tname = foo\n")
+
+	s.Require().NoError(EditScope(GlobalScope, func(e *format.Editor) error {
+		e.SetOption("user", "", "email", "foo@example.com")
+		return nil
+	}))
+
+	b, err := os.ReadFile(path)
+	s.NoError(err)
+	s.Equal("# mine\n[user]\n\tname = foo\n\temail = foo@example.com\n", string(b))
+
+	// ~/.gitconfig is preferred to the XDG file when both exist.
+	xdg := s.write(".config/git/config", "[core]\n\teditor = vi\n")
+	s.Require().NoError(EditScope(GlobalScope, func(e *format.Editor) error {
+		e.SetOption("user", "", "name", "bar")
+		return nil
+	}))
+
+	b, err = os.ReadFile(path)
+	s.NoError(err)
+	s.Equal("# mine\n[user]\n\tname = bar\n\temail = foo@example.com\n", string(b))
+
+	b, err = os.ReadFile(xdg)
+	s.NoError(err)
+	s.Equal("[core]\n\teditor = vi\n", string(b))
+
+	// The XDG file is written when it is the only one.
+	s.Require().NoError(os.Remove(path))
+	s.Require().NoError(EditScope(GlobalScope, func(e *format.Editor) error {
+		e.SetOption("user", "", "name", "baz")
+		return nil
+	}))
+
+	b, err = os.ReadFile(xdg)
+	s.NoError(err)
+	s.Equal("[core]\n\teditor = vi\n[user]\n\tname = baz\n", string(b))
+
+	s.ErrorIs(EditScope(SystemScope, func(*format.Editor) error { return nil }), ErrScopeNotWritable)
+	s.ErrorIs(EditScope(LocalScope, func(*format.Editor) error { return nil }), ErrScopeNotWritable)
+}
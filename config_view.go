@@ -0,0 +1,230 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/config"
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+const (
+	configFile         = "config"
+	worktreeConfigFile = "config.worktree"
+)
+
+// ErrWorktreeConfigDisabled is returned when editing the worktree config of a
+// repository without the extensions.worktreeConfig extension.
+var ErrWorktreeConfigDisabled = errors.New("worktree config requires extensions.worktreeConfig")
+
+// ConfigView returns every value of the config of the repository along with
+// its origin, reading the system, global, local and worktree config files and
+// the GIT_CONFIG_COUNT, GIT_CONFIG_KEY_<n> and GIT_CONFIG_VALUE_<n>
+// environment variables, as git does.
+func (r *Repository) ConfigView() (*config.View, error) {
+	o, err := r.includeOptions(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	v := config.NewView()
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope} {
+		if err := v.AddScope(scope, o); err != nil {
+			return nil, err
+		}
+	}
+
+	b, path, err := r.readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := v.AddFile(config.LocalScope, path, bytes.NewReader(b), o); err != nil {
+		return nil, err
+	}
+
+	if err := r.addWorktreeConfig(v, o); err != nil {
+		return nil, err
+	}
+
+	if err := v.AddEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func (r *Repository) addWorktreeConfig(v *config.View, o *formatcfg.IncludeOptions) error {
+	ok, err := r.worktreeConfigEnabled()
+	if err != nil || !ok {
+		return err
+	}
+
+	b, path, err := r.readConfigFile(worktreeConfigFile)
+	if err != nil {
+		return err
+	}
+
+	return v.AddFile(config.WorktreeScope, path, bytes.NewReader(b), o)
+}
+
+func (r *Repository) worktreeConfigEnabled() (bool, error) {
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return false, err
+	}
+
+	return cfg.Extensions.WorktreeConfig, nil
+}
+
+// configFilesystem returns the filesystem of the storage, if any.
+func (r *Repository) configFilesystem() (billy.Filesystem, bool) {
+	fs, ok := r.Storer.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return nil, false
+	}
+
+	return fs.Filesystem(), true
+}
+
+// readConfigFile returns the content of a config file of the storage, along
+// with its path. The config of storages without filesystem is marshalled,
+// and the worktree config is empty.
+func (r *Repository) readConfigFile(name string) ([]byte, string, error) {
+	fs, ok := r.configFilesystem()
+	if !ok {
+		if name != configFile {
+			return nil, "", nil
+		}
+
+		cfg, err := r.Storer.Config()
+		if err != nil {
+			return nil, "", err
+		}
+
+		b, err := cfg.Marshal()
+		return b, "", err
+	}
+
+	path := name
+	if root := fs.Root(); root != "" {
+		path = filepath.Join(root, name)
+	}
+
+	b, err := util.ReadFile(fs, name)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, "", err
+	}
+
+	return b, path, nil
+}
+
+// overlayConfig returns cfg overridden by the worktree config and the config
+// set through the environment, as described in ConfigView. cfg is returned as
+// is if there is none.
+func (r *Repository) overlayConfig(cfg *config.Config, o *formatcfg.IncludeOptions) (*config.Config, error) {
+	v := config.NewView()
+	if err := r.addWorktreeConfig(v, o); err != nil {
+		return nil, err
+	}
+
+	if err := v.AddEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+
+	if len(v.Values()) == 0 {
+		return cfg, nil
+	}
+
+	b, err := cfg.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	out := config.NewConfig()
+	if err := out.Unmarshal(b); err != nil {
+		return nil, err
+	}
+
+	for _, val := range v.Values() {
+		section, subsection, name, err := config.SplitKey(val.Key)
+		if err != nil {
+			return nil, err
+		}
+
+		out.Raw.AddOption(section, subsection, name, val.Value)
+	}
+
+	var buf bytes.Buffer
+	if err := formatcfg.NewEncoder(&buf).Encode(out.Raw); err != nil {
+		return nil, err
+	}
+
+	if err := out.Unmarshal(buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// EditConfig edits the config file of the given scope in place with fn,
+// keeping its comments and formatting. The local scope is the config of the
+// repository, and the worktree scope its config.worktree file, which requires
+// the extensions.worktreeConfig extension. The global and system scopes are
+// edited as with config.EditScope.
+func (r *Repository) EditConfig(scope config.Scope, fn func(*formatcfg.Editor) error) error {
+	switch scope {
+	case config.GlobalScope, config.SystemScope:
+		return config.EditScope(scope, fn)
+	case config.LocalScope:
+		return r.editConfigFile(configFile, fn)
+	case config.WorktreeScope:
+		ok, err := r.worktreeConfigEnabled()
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return ErrWorktreeConfigDisabled
+		}
+
+		return r.editConfigFile(worktreeConfigFile, fn)
+	}
+
+	return fmt.Errorf("%w: %s", config.ErrScopeNotWritable, scope)
+}
+
+func (r *Repository) editConfigFile(name string, fn func(*formatcfg.Editor) error) error {
+	b, _, err := r.readConfigFile(name)
+	if err != nil {
+		return err
+	}
+
+	e, err := formatcfg.NewEditor(b)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(e); err != nil {
+		return err
+	}
+
+	// The result is validated before being written, since the storage
+	// parses it on every read.
+	cfg, err := config.ReadConfig(bytes.NewReader(e.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	fs, ok := r.configFilesystem()
+	if !ok {
+		return r.Storer.SetConfig(cfg)
+	}
+
+	return util.WriteFile(fs, name, e.Bytes(), 0o666)
+}
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v6/config"
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupConfigEnv(t *testing.T) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "true")
+	t.Setenv("GIT_CONFIG_COUNT", "")
+	return home
+}
+
+func TestConfigView(t *testing.T) {
+	home := setupConfigEnv(t)
+	global := filepath.Join(home, ".gitconfig")
+	require.NoError(t, os.WriteFile(global, []byte("[user]\n\tname = global\n\temail = global@example.com\n"), 0o644))
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+
+	require.NoError(t, r.EditConfig(config.LocalScope, func(e *formatcfg.Editor) error {
+		e.SetOption("user", "", "name", "local")
+		e.SetOption("extensions", "", "worktreeConfig", "true")
+		return nil
+	}))
+
+	require.NoError(t, r.EditConfig(config.WorktreeScope, func(e *formatcfg.Editor) error {
+		e.SetOption("user", "", "email", "worktree@example.com")
+		return nil
+	}))
+
+	t.Setenv("GIT_CONFIG_COUNT", "1")
+	t.Setenv("GIT_CONFIG_KEY_0", "core.editor")
+	t.Setenv("GIT_CONFIG_VALUE_0", "vi")
+
+	v, err := r.ConfigView()
+	require.NoError(t, err)
+
+	names := v.GetAll("user.name")
+	require.Len(t, names, 2)
+	assert.Equal(t, config.Origin{Scope: config.GlobalScope, File: global, Line: 2}, names[0].Origin)
+	assert.Equal(t, config.LocalScope, names[1].Origin.Scope)
+	assert.Equal(t, filepath.Join(dir, GitDirName, "config"), names[1].Origin.File)
+
+	email, ok := v.Get("user.email")
+	require.True(t, ok)
+	assert.Equal(t, "worktree@example.com", email.Value)
+	assert.Equal(t, config.WorktreeScope, email.Origin.Scope)
+	assert.Equal(t, filepath.Join(dir, GitDirName, "config.worktree"), email.Origin.File)
+
+	editor, ok := v.Get("core.editor")
+	require.True(t, ok)
+	assert.Equal(t, config.CommandScope, editor.Origin.Scope)
+
+	cfg, err := r.ConfigScoped(config.GlobalScope)
+	require.NoError(t, err)
+	assert.Equal(t, "local", cfg.User.Name)
+	assert.Equal(t, "worktree@example.com", cfg.User.Email)
+	assert.Equal(t, "vi", cfg.Raw.Section("core").Option("editor"))
+
+	// The overrides are not part of the local config.
+	cfg, err = r.Config()
+	require.NoError(t, err)
+	assert.Equal(t, "", cfg.User.Email)
+}
+
+func TestEditConfigKeepsComments(t *testing.T) {
+	setupConfigEnv(t)
+
+	dir := t.TempDir()
+	_, err := PlainInit(dir, false)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, GitDirName, "config")
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, append([]byte("# keep me\n"), b...), 0o644))
+
+	r, err := PlainOpen(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, r.EditConfig(config.LocalScope, func(e *formatcfg.Editor) error {
+		e.AddOption("remote", "origin", "url", "https://example.com/foo")
+		return nil
+	}))
+
+	b, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "# keep me\n")
+
+	remote, err := r.Remote("origin")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://example.com/foo"}, remote.Config().URLs)
+
+	err = r.EditConfig(config.WorktreeScope, func(*formatcfg.Editor) error { return nil })
+	assert.ErrorIs(t, err, ErrWorktreeConfigDisabled)
+
+	err = r.EditConfig(config.CommandScope, func(*formatcfg.Editor) error { return nil })
+	assert.ErrorIs(t, err, config.ErrScopeNotWritable)
+}
+
+func TestEditConfigMemory(t *testing.T) {
+	setupConfigEnv(t)
+
+	r, err := Init(memory.NewStorage())
+	require.NoError(t, err)
+
+	require.NoError(t, r.EditConfig(config.LocalScope, func(e *formatcfg.Editor) error {
+		e.SetOption("user", "", "name", "foo")
+		return nil
+	}))
+
+	v, err := r.ConfigView()
+	require.NoError(t, err)
+
+	name, err := v.String("user.name")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", name)
+}
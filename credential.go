@@ -0,0 +1,49 @@
+package git
+
+import (
+	"net/url"
+
+	"github.com/go-git/go-git/v6/config"
+	formatcfg "github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+	"github.com/go-git/go-git/v6/plumbing/transport/http"
+)
+
+// authMethod returns the auth method used with the remote ep. When auth is
+// nil, ep is an http remote without password and useHelpers is set, the
+// credential helpers configured in credential.helper are asked for the
+// credentials, as git does.
+func (r *Remote) authMethod(ep *transport.Endpoint, auth transport.AuthMethod, useHelpers bool) (transport.AuthMethod, error) {
+	if auth != nil || !useHelpers || ep.Password != "" || (ep.Protocol != "http" && ep.Protocol != "https") {
+		return auth, nil
+	}
+
+	u, err := url.Parse(ep.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var cfgs []*formatcfg.Config
+	for _, scope := range []config.Scope{config.SystemScope, config.GlobalScope} {
+		cfg, err := config.LoadConfig(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		cfgs = append(cfgs, cfg.Raw)
+	}
+
+	local, err := r.s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	cfgs = append(cfgs, local.Raw)
+	a, err := http.NewCredentialHelperAuth(credential.NewConfig(u, cfgs...))
+	if err != nil || a == nil {
+		return nil, err
+	}
+
+	return a, nil
+}
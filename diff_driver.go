@@ -0,0 +1,88 @@
+package git
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v6/config"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+)
+
+// diffDrivers returns the function giving the diff driver of a path,
+// selected by its diff attribute, as matched by the given patterns, among
+// the diff.<driver> sections of the config. The paths without driver get
+// the word regex of diff.wordRegex, if any.
+func (r *Repository) diffDrivers(patterns []gitattributes.MatchAttribute) (func(path string) *fdiff.Driver, error) {
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	var wordRegex *regexp.Regexp
+	if cfg.Diff.WordRegex != "" {
+		if wordRegex, err = regexp.Compile(cfg.Diff.WordRegex); err != nil {
+			return nil, fmt.Errorf("diff.wordRegex: %w", err)
+		}
+	}
+
+	drivers := make(map[string]*fdiff.Driver, len(cfg.DiffDrivers))
+	for name, d := range cfg.DiffDrivers {
+		driver := &fdiff.Driver{WordRegex: wordRegex}
+		if d.XFuncName != "" {
+			if driver.FuncName, err = fdiff.ParseFuncName(d.XFuncName); err != nil {
+				return nil, fmt.Errorf("diff.%s.xfuncname: %w", name, err)
+			}
+		}
+
+		if d.WordRegex != "" {
+			if driver.WordRegex, err = regexp.Compile(d.WordRegex); err != nil {
+				return nil, fmt.Errorf("diff.%s.wordRegex: %w", name, err)
+			}
+		}
+
+		drivers[name] = driver
+	}
+
+	var defaultDriver *fdiff.Driver
+	if wordRegex != nil {
+		defaultDriver = &fdiff.Driver{WordRegex: wordRegex}
+	}
+
+	matcher := gitattributes.NewMatcher(patterns)
+	return func(path string) *fdiff.Driver {
+		attrs, _ := matcher.Match(strings.Split(path, "/"), []string{diffAttr})
+		if attr, ok := attrs[diffAttr]; ok && attr.IsValueSet() {
+			if driver, ok := drivers[attr.Value()]; ok {
+				return driver
+			}
+		}
+
+		return defaultDriver
+	}, nil
+}
+
+// binaryAttr is the builtin macro attribute of the binary files, unsetting
+// their diff attribute.
+const binaryAttr = "binary"
+
+// diffBinary returns the function telling whether the files are binary, as
+// matched by the given patterns, from their diff attribute: the files with
+// the attribute unset are binary, the ones with the attribute set are text,
+// and the content of the other ones decides.
+func diffBinary(patterns []gitattributes.MatchAttribute) func(path string) (binary, ok bool) {
+	matcher := gitattributes.NewMatcher(patterns)
+	return func(path string) (bool, bool) {
+		attrs, _ := matcher.Match(strings.Split(path, "/"), []string{diffAttr, binaryAttr})
+		if attr, ok := attrs[diffAttr]; ok {
+			return attr.IsUnset(), attr.IsUnset() || attr.IsSet()
+		}
+
+		if attr, ok := attrs[binaryAttr]; ok && attr.IsSet() {
+			return true, true
+		}
+
+		return false, false
+	}
+}
@@ -0,0 +1,377 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/format/mbox"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+const (
+	// formatPatchStatWidth is the width of the diffstat of the patches.
+	formatPatchStatWidth = 72
+	// formatPatchAbbrev is the length of the hashes of the index lines.
+	formatPatchAbbrev = 7
+)
+
+var (
+	// ErrEmptyPatchSeries is returned by FormatPatch when there is no
+	// commit to format.
+	ErrEmptyPatchSeries = errors.New("no commits to format")
+	// ErrBaseNotParent is returned by FormatPatch when the base commit of
+	// the options is not the parent of the first patch of the series.
+	ErrBaseNotParent = errors.New("base commit is not the parent of the first patch")
+)
+
+// FormatPatch writes the commits of a series as mbox messages to w, like
+// git format-patch --stdout, so they can be applied with git am. The series
+// is made of the non-merge commits reachable from the head of the options
+// and not from its upstream, the oldest first.
+//
+// The hunk headers show the function lines of the diff drivers selected by
+// the diff attributes of the last commit of the series. The renames are
+// detected unless disabled by the options, and the changes of the binary
+// files are written as binary patches.
+func (r *Repository) FormatPatch(w io.Writer, o *FormatPatchOptions) error {
+	if o == nil {
+		o = &FormatPatchOptions{}
+	}
+
+	if err := o.Validate(r); err != nil {
+		return err
+	}
+
+	commits, err := r.formatPatchCommits(o)
+	if err != nil {
+		return err
+	}
+
+	if !o.Base.IsZero() {
+		if ps := commits[0].ParentHashes; len(ps) == 0 || ps[0] != o.Base {
+			return ErrBaseNotParent
+		}
+	}
+
+	var base string
+	if !o.Base.IsZero() {
+		base = fmt.Sprintf("\nbase-commit: %s\n", o.Base)
+	}
+
+	drivers, err := r.formatPatchDrivers(commits[len(commits)-1])
+	if err != nil {
+		return err
+	}
+
+	e := mbox.NewEncoder(w)
+	numbered := o.Numbered || len(commits) > 1
+	if o.CoverLetter {
+		m, err := coverLetter(commits, o, numbered)
+		if err != nil {
+			return err
+		}
+
+		m.Diff += base
+		base = ""
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	for i, c := range commits {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+
+		from, err := firstParentTree(c)
+		if err != nil {
+			return err
+		}
+
+		to, err := c.Tree()
+		if err != nil {
+			return err
+		}
+
+		d, err := formatPatchDiff(from, to, o, drivers)
+		if err != nil {
+			return err
+		}
+
+		subject, body := splitCommitMessage(c.Message)
+		m := &mbox.Message{
+			Hash:          c.Hash,
+			Name:          c.Author.Name,
+			Email:         c.Author.Email,
+			Date:          c.Author.When,
+			SubjectPrefix: subjectPrefix(o.SubjectPrefix, numbered, i+1, len(commits)),
+			Subject:       subject,
+			Body:          body,
+			Diff:          d,
+			Signature:     o.Signature,
+		}
+
+		if i == 0 {
+			m.Diff += base
+		}
+
+		if err := e.Encode(m); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatPatchCommits returns the commits of the series, the oldest first.
+func (r *Repository) formatPatchCommits(o *FormatPatchOptions) ([]*object.Commit, error) {
+	excluded := make(map[plumbing.Hash]bool)
+	if !o.Upstream.IsZero() {
+		upstream, err := r.CommitObject(o.Upstream)
+		if err != nil {
+			return nil, err
+		}
+
+		err = object.NewCommitPreorderIter(upstream, nil, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	head, err := r.CommitObject(o.Head)
+	if err != nil {
+		return nil, err
+	}
+
+	var commits []*object.Commit
+	err = object.NewCommitIterCTime(head, excluded, nil).ForEach(func(c *object.Commit) error {
+		if c.NumParents() > 1 {
+			return nil
+		}
+
+		commits = append(commits, c)
+		if o.MaxCount > 0 && len(commits) == o.MaxCount {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(commits) == 0 {
+		return nil, ErrEmptyPatchSeries
+	}
+
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+
+	return commits, nil
+}
+
+// coverLetter returns the cover letter of the series, with the shortlog of
+// the commits and the diffstat of the whole series.
+func coverLetter(commits []*object.Commit, o *FormatPatchOptions, numbered bool) (*mbox.Message, error) {
+	from, err := firstParentTree(commits[0])
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := commits[len(commits)-1].Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := formatPatchChanges(from, to, o)
+	if err != nil {
+		return nil, err
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("\n")
+	writeShortlog(sb, commits)
+	if err := fdiff.NewStatEncoder(sb).SetWidth(formatPatchStatWidth).Encode(p); err != nil {
+		return nil, err
+	}
+
+	if err := fdiff.NewSummaryEncoder(sb).Encode(p); err != nil {
+		return nil, err
+	}
+
+	sb.WriteString("\n")
+
+	eightBit := !isASCIIString(o.Sender.Name)
+	for _, c := range commits {
+		eightBit = eightBit || !isASCIIString(c.Author.Name) ||
+			!isASCIIString(c.Committer.Name) || !isASCIIString(c.Message)
+	}
+
+	return &mbox.Message{
+		Hash:          commits[len(commits)-1].Hash,
+		Name:          o.Sender.Name,
+		Email:         o.Sender.Email,
+		Date:          o.Sender.When,
+		SubjectPrefix: subjectPrefix(o.SubjectPrefix, numbered, 0, len(commits)),
+		Subject:       "*** SUBJECT HERE ***",
+		Body:          "*** BLURB HERE ***",
+		Diff:          sb.String(),
+		Signature:     o.Signature,
+		EightBit:      eightBit,
+		RawFrom:       true,
+	}, nil
+}
+
+// writeShortlog writes the subjects of the commits grouped by author, like
+// git shortlog.
+func writeShortlog(sb *strings.Builder, commits []*object.Commit) {
+	subjects := make(map[string][]string)
+	var authors []string
+	for _, c := range commits {
+		if _, ok := subjects[c.Author.Name]; !ok {
+			authors = append(authors, c.Author.Name)
+		}
+
+		subject, _ := splitCommitMessage(c.Message)
+		subjects[c.Author.Name] = append(subjects[c.Author.Name], subject)
+	}
+
+	sort.Strings(authors)
+	for _, a := range authors {
+		fmt.Fprintf(sb, "%s (%d):\n", a, len(subjects[a]))
+		for _, s := range subjects[a] {
+			sb.WriteString(mbox.WrapText(s, 2, 4, 76))
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString("\n")
+	}
+}
+
+// formatPatchChanges returns the patch of the changes between the trees.
+func formatPatchChanges(from, to *object.Tree, o *FormatPatchOptions) (*object.Patch, error) {
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, &object.DiffTreeOptions{
+		DetectRenames: !o.NoRenames,
+		RenameScore:   o.RenameScore,
+		RenameLimit:   o.RenameLimit,
+		LineDiff:      o.LineDiff,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortChangesByDestination(changes)
+	return changes.Patch()
+}
+
+// sortChangesByDestination sorts the changes by their destination path, the
+// deletions by their source path, as git orders the files of its patches.
+func sortChangesByDestination(changes object.Changes) {
+	path := func(c *object.Change) string {
+		if c.To.Name != "" {
+			return c.To.Name
+		}
+
+		return c.From.Name
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return path(changes[i]) < path(changes[j])
+	})
+}
+
+// formatPatchDiff returns the diffstat, the summary and the patch of the
+// changes between the trees, as written after the body of a patch.
+func formatPatchDiff(from, to *object.Tree, o *FormatPatchOptions, drivers func(string) *fdiff.Driver) (string, error) {
+	p, err := formatPatchChanges(from, to, o)
+	if err != nil {
+		return "", err
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString("---\n")
+	if err := fdiff.NewStatEncoder(sb).SetWidth(formatPatchStatWidth).Encode(p); err != nil {
+		return "", err
+	}
+
+	if err := fdiff.NewSummaryEncoder(sb).Encode(p); err != nil {
+		return "", err
+	}
+
+	sb.WriteString("\n")
+	ue := fdiff.NewUnifiedEncoder(sb, fdiff.DefaultContextLines).
+		SetAbbrev(formatPatchAbbrev).
+		SetLineDiff(o.LineDiff).
+		SetDrivers(drivers).
+		SetBinary(true)
+	if err := ue.Encode(p); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// formatPatchDrivers returns the diff drivers of the paths, selected by the
+// attributes of the tree of c.
+func (r *Repository) formatPatchDrivers(c *object.Commit) (func(string) *fdiff.Driver, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := readTreeAttributes(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.diffDrivers(patterns)
+}
+
+// firstParentTree returns the tree of the first parent of c, or an empty
+// tree for a root commit.
+func firstParentTree(c *object.Commit) (*object.Tree, error) {
+	if c.NumParents() == 0 {
+		return &object.Tree{}, nil
+	}
+
+	parent, err := c.Parent(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return parent.Tree()
+}
+
+// subjectPrefix returns the bracketed prefix of the subject of the n-th
+// message of a series of total patches.
+func subjectPrefix(prefix string, numbered bool, n, total int) string {
+	if !numbered {
+		return "[" + prefix + "]"
+	}
+
+	width := len(fmt.Sprint(total))
+	return fmt.Sprintf("[%s %0*d/%d]", prefix, width, n, total)
+}
+
+func isASCIIString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+
+	return true
+}
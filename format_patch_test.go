@@ -0,0 +1,316 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFormatPatchTestRepository(t *testing.T) (*Repository, string, []plumbing.Hash) {
+	t.Helper()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	when := time.Unix(1700000000, 0).In(time.FixedZone("", 2*3600))
+	commit := func(name, msg string, files map[string]string, removed ...string) plumbing.Hash {
+		for file, content := range files {
+			mode := os.FileMode(0o644)
+			if strings.HasSuffix(file, ".sh") {
+				mode = 0o755
+			}
+
+			require.NoError(t, os.WriteFile(filepath.Join(dir, file), []byte(content), mode))
+		}
+
+		for _, file := range removed {
+			require.NoError(t, os.Remove(filepath.Join(dir, file)))
+		}
+
+		require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+
+		when = when.Add(time.Hour)
+		sig := &object.Signature{Name: name, Email: "dev@example.com", When: when}
+		h, err := w.Commit(msg, &CommitOptions{Author: sig, Committer: sig})
+		require.NoError(t, err)
+		return h
+	}
+
+	hashes := []plumbing.Hash{
+		commit("A U Thor", "base\n", map[string]string{"a.txt": "one\n", "b.txt": "1\n2\n3\n4\n5\n6\n7\n8\n"}),
+		commit("Jörg Müller", "Add a script with a rather long subject line that should be wrapped by format-patch\n\nThe body explains\nthe change.\n",
+			map[string]string{"run.sh": "#!/bin/sh\necho hi\n"}),
+		commit("A U Thor", "second: update (test) [x]\n", map[string]string{"b.txt": "1\n2\nthree\n4\n5\n6\n7\neight\n"}),
+		commit("A U Thor", "remove a\n", nil, "a.txt"),
+	}
+
+	return r, dir, hashes
+}
+
+func TestFormatPatchSingle(t *testing.T) {
+	r, _, hashes := newFormatPatchTestRepository(t)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.FormatPatch(&buf, nil))
+	assert.Equal(t, "From "+hashes[3].String()+" Mon Sep 17 00:00:00 2001\n"+
+		"From: A U Thor <dev@example.com>\n"+
+		"Date: Wed, 15 Nov 2023 04:13:20 +0200\n"+
+		"Subject: [PATCH] remove a\n"+
+		"\n"+
+		"---\n"+
+		" a.txt | 1 -\n"+
+		" 1 file changed, 1 deletion(-)\n"+
+		" delete mode 100644 a.txt\n"+
+		"\n"+
+		"diff --git a/a.txt b/a.txt\n"+
+		"deleted file mode 100644\n"+
+		"index 5626abf..0000000\n"+
+		"--- a/a.txt\n"+
+		"+++ /dev/null\n"+
+		"@@ -1 +0,0 @@\n"+
+		"-one\n", buf.String())
+}
+
+func TestFormatPatchSeries(t *testing.T) {
+	r, _, hashes := newFormatPatchTestRepository(t)
+
+	var buf bytes.Buffer
+	err := r.FormatPatch(&buf, &FormatPatchOptions{Upstream: hashes[1], Signature: "sig"})
+	require.NoError(t, err)
+
+	out := buf.String()
+	assert.Equal(t, 2, strings.Count(out, " Mon Sep 17 00:00:00 2001\n"))
+	assert.Contains(t, out, "Subject: [PATCH 1/2] second: update (test) [x]\n")
+	assert.Contains(t, out, "Subject: [PATCH 2/2] remove a\n")
+	assert.Contains(t, out, "-- \nsig\n\n\nFrom "+hashes[3].String())
+
+	buf.Reset()
+	err = r.FormatPatch(&buf, &FormatPatchOptions{MaxCount: 1, Numbered: true, SubjectPrefix: "RFC PATCH"})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "Subject: [RFC PATCH 1/1] remove a\n")
+}
+
+func TestFormatPatchBase(t *testing.T) {
+	r, _, hashes := newFormatPatchTestRepository(t)
+
+	var buf bytes.Buffer
+	err := r.FormatPatch(&buf, &FormatPatchOptions{Upstream: hashes[2], Base: hashes[2]})
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(buf.String(), "-one\n\nbase-commit: "+hashes[2].String()+"\n"))
+
+	err = r.FormatPatch(&buf, &FormatPatchOptions{Upstream: hashes[2], Base: hashes[0]})
+	assert.ErrorIs(t, err, ErrBaseNotParent)
+}
+
+func TestFormatPatchEmptySeries(t *testing.T) {
+	r, _, hashes := newFormatPatchTestRepository(t)
+
+	err := r.FormatPatch(&bytes.Buffer{}, &FormatPatchOptions{Upstream: hashes[3]})
+	assert.ErrorIs(t, err, ErrEmptyPatchSeries)
+}
+
+func TestFormatPatchMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the executable bit is not recorded on windows")
+	}
+
+	r, dir, hashes := newFormatPatchTestRepository(t)
+	sender := &object.Signature{
+		Name:  "Zoë Sender",
+		Email: "sender@example.com",
+		When:  time.Unix(1800000000, 0).In(time.FixedZone("", -5*3600)),
+	}
+
+	tests := []struct {
+		args []string
+		o    *FormatPatchOptions
+	}{
+		{[]string{"-1"}, &FormatPatchOptions{}},
+		{[]string{hashes[0].String()}, &FormatPatchOptions{Upstream: hashes[0]}},
+		{[]string{"--root"}, &FormatPatchOptions{MaxCount: 4}},
+		{[]string{"-n", "-2"}, &FormatPatchOptions{MaxCount: 2}},
+		{
+			[]string{"--cover-letter", "--base=" + hashes[0].String(), "--subject-prefix=RFC", hashes[0].String()},
+			&FormatPatchOptions{Upstream: hashes[0], CoverLetter: true, Base: hashes[0], SubjectPrefix: "RFC", Sender: sender},
+		},
+	}
+
+	for _, tc := range tests {
+		args := append([]string{"-c", "core.abbrev=7", "format-patch", "--stdout", "--signature=2.39.5"}, tc.args...)
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_COMMITTER_NAME="+sender.Name,
+			"GIT_COMMITTER_EMAIL="+sender.Email,
+			"GIT_COMMITTER_DATE=1800000000 -0500",
+		)
+		expected, err := cmd.Output()
+		require.NoError(t, err)
+
+		tc.o.Signature = "2.39.5"
+		var buf bytes.Buffer
+		require.NoError(t, r.FormatPatch(&buf, tc.o))
+		assert.Equal(t, string(expected), buf.String(), "git format-patch %v", tc.args)
+	}
+}
+
+func TestFormatPatchRenameOrderMatchesGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	r, dir, _ := newFormatPatchTestRepository(t)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	// The rename is ordered by its destination, c.txt, after bin.dat.
+	sig := &object.Signature{Name: "A U Thor", Email: "dev@example.com", When: time.Unix(1700100000, 0).UTC()}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin.dat"), []byte("data\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d.txt"), []byte("one\n"), 0o644))
+	require.NoError(t, os.Rename(filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	_, err = w.Commit("rename\n", &CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+
+	cmd := exec.Command("git", "-c", "core.abbrev=7", "format-patch", "--stdout", "--signature=2.39.5", "-1")
+	cmd.Dir = dir
+	expected, err := cmd.Output()
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.FormatPatch(&buf, &FormatPatchOptions{Signature: "2.39.5"}))
+	assert.Equal(t, string(expected), buf.String())
+	assert.Less(t, strings.Index(buf.String(), "diff --git a/bin.dat"), strings.Index(buf.String(), "diff --git a/b.txt b/c.txt"))
+}
+
+func TestFormatPatchDiffDriver(t *testing.T) {
+	r, dir, _ := newFormatPatchTestRepository(t)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("*.txt diff=digits\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("1\n2\nthree\n4\n5\n6\nseven\neight\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	sig := &object.Signature{Name: "A U Thor", Email: "dev@example.com", When: time.Unix(1700100000, 0).UTC()}
+	_, err = w.Commit("seven\n", &CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.FormatPatch(&buf, nil))
+	assert.Contains(t, buf.String(), "@@ -4,5 +4,5 @@ three\n")
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.DiffDrivers["digits"] = &config.DiffDriver{Name: "digits", XFuncName: "^([0-9])$"}
+	require.NoError(t, r.SetConfig(cfg))
+
+	buf.Reset()
+	require.NoError(t, r.FormatPatch(&buf, nil))
+	assert.Contains(t, buf.String(), "@@ -4,5 +4,5 @@ 2\n")
+
+	cfg.DiffDrivers["digits"].XFuncName = "("
+	require.NoError(t, r.SetConfig(cfg))
+	assert.ErrorIs(t, r.FormatPatch(&buf, nil), fdiff.ErrInvalidFuncName)
+}
+
+func TestFormatPatchLineDiffConfig(t *testing.T) {
+	r, _, _ := newFormatPatchTestRepository(t)
+
+	o, err := r.lineDiffOptions()
+	require.NoError(t, err)
+	assert.Equal(t, diff.DefaultOptions, o)
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Diff.Algorithm = "histogram"
+	cfg.Diff.NoIndentHeuristic = true
+	require.NoError(t, r.SetConfig(cfg))
+
+	o, err = r.lineDiffOptions()
+	require.NoError(t, err)
+	assert.Equal(t, &diff.Options{Algorithm: diff.Histogram}, o)
+
+	cfg.Diff.Algorithm = "foo"
+	require.NoError(t, r.SetConfig(cfg))
+	err = r.FormatPatch(&bytes.Buffer{}, nil)
+	assert.ErrorIs(t, err, diff.ErrUnknownAlgorithm)
+}
+
+func TestFormatPatchBinaryAndRename(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	r, dir, _ := newFormatPatchTestRepository(t)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	image := bytes.Repeat([]byte("\x89PNG\x00\x01\x02\x03"), 200)
+	sig := &object.Signature{Name: "A U Thor", Email: "dev@example.com", When: time.Unix(1700100000, 0).UTC()}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), image, 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	base, err := w.Commit("add image\n", &CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.png"), append(image, 0xff), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.bin"), []byte{0, 1, 2}, 0o644))
+	require.NoError(t, os.Rename(filepath.Join(dir, "b.txt"), filepath.Join(dir, "c.txt")))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	head, err := w.Commit("binary and rename\n", &CommitOptions{Author: sig, Committer: sig})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, r.FormatPatch(&buf, nil))
+	out := buf.String()
+	assert.Contains(t, out, "diff --git a/b.txt b/c.txt\nsimilarity index 100%\nrename from b.txt\nrename to c.txt\n")
+	assert.Contains(t, out, "\nGIT binary patch\ndelta ")
+	assert.Contains(t, out, "\nGIT binary patch\nliteral 3\n")
+	assert.NotContains(t, out, "Binary files")
+
+	buf.Reset()
+	require.NoError(t, r.FormatPatch(&buf, &FormatPatchOptions{NoRenames: true}))
+	assert.Contains(t, buf.String(), "diff --git a/c.txt b/c.txt\nnew file mode 100644\n")
+
+	// The patch applies with git am on top of its parent.
+	amDir := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = amDir
+		cmd.Stdin = strings.NewReader(out)
+		cmd.Env = append(os.Environ(), "GIT_COMMITTER_NAME=a", "GIT_COMMITTER_EMAIL=a@example.com")
+		output, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(output))
+		return strings.TrimSpace(string(output))
+	}
+
+	_, err = PlainClone(amDir, &CloneOptions{URL: dir})
+	require.NoError(t, err)
+	git("checkout", "-q", base.String())
+	git("am", "-q")
+
+	c, err := r.CommitObject(head)
+	require.NoError(t, err)
+	assert.Equal(t, c.TreeHash.String(), git("rev-parse", "HEAD^{tree}"))
+}
@@ -0,0 +1,159 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// FSMonitor is a file system monitor watching the worktree, queried by
+// Worktree.StatusWithOptions for the files changed since a previous query
+// so the other ones are not checked, as the core.fsmonitor hook of git.
+type FSMonitor interface {
+	// Query returns the token of this query and the paths changed since
+	// the query that returned token, relative to the root of the worktree.
+	// Paths with a trailing slash are directories whose whole content
+	// changed, and the "/" path means that anything may have changed, as
+	// when token is empty or unknown to the monitor.
+	Query(token string) (string, []string, error)
+}
+
+// fsMonitorAll is the path returned by an FSMonitor when anything may have
+// changed.
+const fsMonitorAll = "/"
+
+// HookFSMonitor is an FSMonitor running a hook implementing the version 2
+// of the fsmonitor hook protocol, as configured in core.fsmonitor.
+// https://git-scm.com/docs/githooks#_fsmonitor_watchman
+type HookFSMonitor struct {
+	// Command is the hook, run by the shell with the version of the
+	// protocol and the token as arguments.
+	Command string
+	// Dir is the working directory of the hook, the root of the worktree.
+	Dir string
+}
+
+// Query runs the hook, which writes the new token and the changed paths to
+// its standard output, each one followed by a NUL character.
+func (m *HookFSMonitor) Query(token string) (string, []string, error) {
+	cmd := exec.Command("sh", "-c", m.Command+` "$@"`, m.Command, "2", token)
+	cmd.Dir = m.Dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", nil, fmt.Errorf("fsmonitor hook %q: %w: %s", m.Command, err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	out := strings.TrimSuffix(stdout.String(), "\x00")
+	token, rest, _ := strings.Cut(out, "\x00")
+	if token == "" {
+		return "", nil, fmt.Errorf("fsmonitor hook %q: missing token", m.Command)
+	}
+
+	var paths []string
+	if rest != "" {
+		paths = strings.Split(rest, "\x00")
+	}
+
+	return token, paths, nil
+}
+
+// fsMonitorChanges are the changes reported by an FSMonitor.
+type fsMonitorChanges struct {
+	// all is set when anything may have changed.
+	all   bool
+	paths map[string]struct{}
+	// trees are the directories whose whole content may have changed.
+	trees map[string]struct{}
+	// dirs are the directories whose list of files may have changed.
+	dirs map[string]struct{}
+}
+
+func newFSMonitorChanges(token string, paths []string) *fsMonitorChanges {
+	c := &fsMonitorChanges{
+		all:   token == "",
+		paths: make(map[string]struct{}),
+		trees: make(map[string]struct{}),
+		dirs:  make(map[string]struct{}),
+	}
+
+	for _, p := range paths {
+		if p == fsMonitorAll {
+			c.all = true
+			continue
+		}
+
+		p, isTree := strings.CutSuffix(p, "/")
+		if isTree {
+			c.trees[p] = struct{}{}
+		} else {
+			// A path reported as a file may be a directory.
+			c.paths[p] = struct{}{}
+		}
+
+		c.dirs[parentDir(p)] = struct{}{}
+	}
+
+	return c
+}
+
+// changed reports whether the file at path may have changed.
+func (c *fsMonitorChanges) changed(path string) bool {
+	if c.all {
+		return true
+	}
+
+	if _, ok := c.paths[path]; ok {
+		return true
+	}
+
+	return c.inTree(parentDir(path))
+}
+
+// dirChanged reports whether the list of files of the directory at path may
+// have changed.
+func (c *fsMonitorChanges) dirChanged(path string) bool {
+	if c.all {
+		return true
+	}
+
+	if _, ok := c.dirs[path]; ok {
+		return true
+	}
+
+	return c.inTree(path)
+}
+
+// inTree reports whether dir is, or is in, a directory whose whole content
+// may have changed.
+func (c *fsMonitorChanges) inTree(dir string) bool {
+	for {
+		if _, ok := c.trees[dir]; ok {
+			return true
+		}
+
+		if _, ok := c.paths[dir]; ok {
+			return true
+		}
+
+		if dir == "" {
+			return false
+		}
+
+		dir = parentDir(dir)
+	}
+}
+
+// parentDir returns the directory of path, empty for the root.
+func parentDir(path string) string {
+	i := strings.LastIndexByte(path, '/')
+	if i < 0 {
+		return ""
+	}
+
+	return path[:i]
+}
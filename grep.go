@@ -0,0 +1,727 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// GrepResult is structure of a grep result.
+type GrepResult struct {
+	// FileName is the name of file which contains match.
+	FileName string
+	// LineNumber is the line number of a file at which a match was found.
+	LineNumber int
+	// Content is the content of the file at the matching line.
+	Content string
+	// TreeName is the name of the tree (reference name/commit hash) at
+	// which the match was performed. It is empty for the index and the
+	// worktree.
+	TreeName string
+	// Context is set when the line is a line of context around the
+	// matching lines.
+	Context bool
+	// Separator is set, when some context is returned, on the first line of
+	// a group of lines not following the previous result, which git grep
+	// separates from it with a "--" line.
+	Separator bool
+	// Count is the number of matching lines of the file, when counted.
+	Count int
+	// Binary is set when the file is binary and has matching lines, which
+	// are not returned.
+	Binary bool
+}
+
+// String returns the result as written by git grep, the lines starting a
+// group of lines being preceded by their "--" separator line.
+func (gr GrepResult) String() string {
+	name := gr.FileName
+	if gr.TreeName != "" {
+		name = gr.TreeName + ":" + name
+	}
+
+	separator := ""
+	if gr.Separator {
+		separator = "--\n"
+	}
+
+	switch {
+	case gr.Binary:
+		return fmt.Sprintf("Binary file %s matches", name)
+	case gr.Count != 0:
+		return fmt.Sprintf("%s:%d", name, gr.Count)
+	case gr.LineNumber == 0:
+		return name
+	case gr.Context:
+		return fmt.Sprintf("%s%s-%d-%s", separator, name, gr.LineNumber, gr.Content)
+	}
+
+	return fmt.Sprintf("%s%s:%d:%s", separator, name, gr.LineNumber, gr.Content)
+}
+
+// GrepExpression is a boolean expression of patterns matched with the
+// lines by grep: GrepPattern, GrepAnd, GrepOr or GrepNot.
+type GrepExpression interface {
+	compile(o *GrepOptions) (grepMatcher, error)
+}
+
+// GrepPattern matches the lines containing the pattern, a regular
+// expression unless GrepOptions.FixedStrings is set, like `git grep -e`.
+type GrepPattern string
+
+// GrepAnd matches the lines matching all its expressions, like
+// `git grep --and`.
+type GrepAnd []GrepExpression
+
+// GrepOr matches the lines matching any of its expressions, like
+// `git grep --or`.
+type GrepOr []GrepExpression
+
+// GrepNot matches the lines not matching its expression, like
+// `git grep --not`.
+type GrepNot struct {
+	GrepExpression
+}
+
+func (p GrepPattern) compile(o *GrepOptions) (grepMatcher, error) {
+	expr := string(p)
+	if o.FixedStrings {
+		expr = regexp.QuoteMeta(expr)
+	}
+
+	if o.IgnoreCase {
+		expr = "(?i)" + expr
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grepRegexp{re: re, word: o.WordRegexp}, nil
+}
+
+func (a GrepAnd) compile(o *GrepOptions) (grepMatcher, error) {
+	m, err := compileGrepExpressions(a, o)
+	return grepAnd(m), err
+}
+
+func (or GrepOr) compile(o *GrepOptions) (grepMatcher, error) {
+	m, err := compileGrepExpressions(or, o)
+	return grepOr(m), err
+}
+
+func (n GrepNot) compile(o *GrepOptions) (grepMatcher, error) {
+	if n.GrepExpression == nil {
+		return grepNot{grepOr(nil)}, nil
+	}
+
+	m, err := n.GrepExpression.compile(o)
+	return grepNot{m}, err
+}
+
+func compileGrepExpressions(exprs []GrepExpression, o *GrepOptions) ([]grepMatcher, error) {
+	matchers := make([]grepMatcher, 0, len(exprs))
+	for _, e := range exprs {
+		m, err := e.compile(o)
+		if err != nil {
+			return nil, err
+		}
+
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
+
+// grepMatcher matches the lines with a compiled GrepExpression.
+type grepMatcher interface {
+	match(line string) bool
+}
+
+type grepRegexp struct {
+	re   *regexp.Regexp
+	word bool
+}
+
+type (
+	grepAnd []grepMatcher
+	grepOr  []grepMatcher
+	grepNot struct{ grepMatcher }
+)
+
+func (m *grepRegexp) match(line string) bool {
+	if !m.word {
+		return m.re.MatchString(line)
+	}
+
+	// Like git, the following matches are looked for from the next start
+	// of a word when a match is not a whole word.
+	for start := 0; start < len(line); {
+		loc := m.re.FindStringIndex(line[start:])
+		if loc == nil {
+			return false
+		}
+
+		begin, end := start+loc[0], start+loc[1]
+		if begin != end && (begin == 0 || !isGrepWordChar(line[begin-1])) &&
+			(end == len(line) || !isGrepWordChar(line[end])) {
+			return true
+		}
+
+		start = begin + 1
+		for start < len(line) && isGrepWordChar(line[start-1]) {
+			start++
+		}
+	}
+
+	return false
+}
+
+func (m grepAnd) match(line string) bool {
+	for _, sub := range m {
+		if !sub.match(line) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m grepOr) match(line string) bool {
+	for _, sub := range m {
+		if sub.match(line) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m grepNot) match(line string) bool {
+	return !m.grepMatcher.match(line)
+}
+
+func isGrepWordChar(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// matcher returns the matcher of the patterns or of the expression of the
+// options.
+func (o *GrepOptions) matcher() (grepMatcher, error) {
+	if o.Expression != nil {
+		return o.Expression.compile(o)
+	}
+
+	var m grepOr
+	for _, re := range o.Patterns {
+		if re == nil {
+			continue
+		}
+
+		if o.IgnoreCase {
+			re = regexp.MustCompile("(?i)" + re.String())
+		}
+
+		m = append(m, &grepRegexp{re: re, word: o.WordRegexp})
+	}
+
+	return m, nil
+}
+
+// Grep performs grep on a repository.
+func (r *Repository) Grep(opts *GrepOptions) ([]GrepResult, error) {
+	var results []GrepResult
+	err := r.GrepEach(context.Background(), opts, func(gr GrepResult) error {
+		results = append(results, gr)
+		return nil
+	})
+
+	return results, err
+}
+
+// GrepEach performs grep on a repository, calling fn with each result, in
+// the order of the files, the files being matched in parallel. The grep
+// stops when fn returns an error, storer.ErrStop stopping it without
+// error.
+func (r *Repository) GrepEach(ctx context.Context, opts *GrepOptions, fn func(GrepResult) error) error {
+	if err := opts.validate(r); err != nil {
+		return err
+	}
+
+	m, err := opts.matcher()
+	if err != nil {
+		return err
+	}
+
+	var files func(context.Context, func(*grepFile) error) error
+	if opts.Cached || opts.Worktree {
+		w, err := r.Worktree()
+		if err != nil && !(opts.Cached && err == ErrIsBareRepository) {
+			return err
+		}
+
+		files = func(ctx context.Context, yield func(*grepFile) error) error {
+			return r.grepIndex(ctx, w, opts, yield)
+		}
+	} else {
+		files = func(ctx context.Context, yield func(*grepFile) error) error {
+			return r.grepTrees(ctx, opts, yield)
+		}
+	}
+
+	err = grepFiles(ctx, opts, m, files, fn)
+	if err == storer.ErrStop {
+		return nil
+	}
+
+	return err
+}
+
+// Grep performs grep on a worktree.
+func (w *Worktree) Grep(opts *GrepOptions) ([]GrepResult, error) {
+	return w.r.Grep(opts)
+}
+
+// GrepEach performs grep on a worktree, like Repository.GrepEach.
+func (w *Worktree) GrepEach(ctx context.Context, opts *GrepOptions, fn func(GrepResult) error) error {
+	return w.r.GrepEach(ctx, opts, fn)
+}
+
+// grepFile is a file searched by grep.
+type grepFile struct {
+	treeName string
+	path     string
+	content  []byte
+	// binary tells whether the file is binary, when ok, from its
+	// attributes.
+	binary, ok bool
+	results    []GrepResult
+}
+
+// grepFiles matches the files given to yield by files in parallel, and
+// calls fn with their results in order.
+func grepFiles(ctx context.Context, opts *GrepOptions, m grepMatcher, files func(context.Context, func(*grepFile) error) error, fn func(GrepResult) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type job struct {
+		file *grepFile
+		done chan struct{}
+	}
+
+	jobs := make(chan job)
+	// pending are the jobs in the order of the files, bounding the number
+	// of files loaded at once.
+	pending := make(chan job, opts.Threads*4)
+	for i := 0; i < opts.Threads; i++ {
+		go func() {
+			for j := range jobs {
+				opts.grep(j.file, m)
+				close(j.done)
+			}
+		}()
+	}
+
+	var filesErr error
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		filesErr = files(ctx, func(f *grepFile) error {
+			j := job{file: f, done: make(chan struct{})}
+			for _, c := range []chan job{pending, jobs} {
+				select {
+				case c <- j:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+
+			return nil
+		})
+	}()
+
+	// separate is set once lines were returned with some context, the first
+	// group of lines of the next files being separated from them.
+	separate := false
+	var err error
+	for j := range pending {
+		if err != nil {
+			continue
+		}
+
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			err = ctx.Err()
+			continue
+		}
+
+		if results := j.file.results; len(results) != 0 && results[0].LineNumber != 0 &&
+			(opts.BeforeContext > 0 || opts.AfterContext > 0) {
+			results[0].Separator = separate
+			separate = true
+		}
+
+		for _, gr := range j.file.results {
+			if err = fn(gr); err != nil {
+				cancel()
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return filesErr
+}
+
+// grep matches the lines of the file, setting its results.
+func (o *GrepOptions) grep(f *grepFile, m grepMatcher) {
+	binary := f.binary
+	if !f.ok {
+		binary = bytes.IndexByte(f.content[:min(len(f.content), 8000)], 0) >= 0
+	}
+
+	if binary && o.Binary == GrepBinaryIgnore {
+		return
+	}
+
+	content := string(f.content)
+	content = strings.TrimSuffix(content, "\n")
+	var lines []string
+	if content != "" || len(f.content) != 0 {
+		lines = strings.Split(content, "\n")
+	}
+
+	result := func(i int, context bool) GrepResult {
+		return GrepResult{
+			FileName:   f.path,
+			LineNumber: i + 1,
+			Content:    lines[i],
+			TreeName:   f.treeName,
+			Context:    context,
+		}
+	}
+
+	count := 0
+	// printed is the last line returned, and after the number of lines of
+	// context left to return after it.
+	printed, after := -1, 0
+	withContext := o.BeforeContext > 0 || o.AfterContext > 0
+	add := func(i int, isContext bool) {
+		r := result(i, isContext)
+		r.Separator = withContext && printed >= 0 && i != printed+1
+		f.results = append(f.results, r)
+		printed = i
+	}
+
+	for i, line := range lines {
+		if m.match(line) == o.InvertMatch {
+			if after > 0 && !o.Count && !o.FilesWithMatches && !o.FilesWithoutMatch {
+				add(i, true)
+				after--
+			}
+
+			continue
+		}
+
+		count++
+		switch {
+		case o.FilesWithMatches:
+			f.results = []GrepResult{{FileName: f.path, TreeName: f.treeName}}
+			return
+		case o.FilesWithoutMatch:
+			return
+		case o.Count:
+			continue
+		case binary && o.Binary == GrepBinaryMatches:
+			f.results = []GrepResult{{FileName: f.path, TreeName: f.treeName, Binary: true}}
+			return
+		}
+
+		for j := max(printed+1, i-o.BeforeContext); j < i; j++ {
+			add(j, true)
+		}
+
+		add(i, false)
+		after = o.AfterContext
+	}
+
+	switch {
+	case o.FilesWithoutMatch:
+		f.results = []GrepResult{{FileName: f.path, TreeName: f.treeName}}
+	case o.Count && count != 0:
+		f.results = []GrepResult{{FileName: f.path, TreeName: f.treeName, Count: count}}
+	}
+}
+
+// matchPath reports whether the file at the path is to be searched.
+func (o *GrepOptions) matchPath(spec *pathspec, path string) bool {
+	if !spec.match(path) {
+		return false
+	}
+
+	// When no pathspecs are provided, search all the files.
+	if len(o.PathSpecs) == 0 {
+		return true
+	}
+
+	for _, pathSpec := range o.PathSpecs {
+		if pathSpec != nil && pathSpec.MatchString(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// grepTrees gives the files of the trees of the options to yield.
+func (r *Repository) grepTrees(ctx context.Context, opts *GrepOptions, yield func(*grepFile) error) error {
+	type namedTree struct {
+		name string
+		hash plumbing.Hash
+	}
+
+	// The names of the trees are the values of the TreeName of the
+	// results.
+	var trees []namedTree
+	if opts.ReferenceName != "" {
+		ref, err := r.Reference(opts.ReferenceName, true)
+		if err != nil {
+			return err
+		}
+
+		trees = append(trees, namedTree{opts.ReferenceName.String(), ref.Hash()})
+	} else if !opts.CommitHash.IsZero() {
+		trees = append(trees, namedTree{opts.CommitHash.String(), opts.CommitHash})
+	}
+
+	for _, rev := range opts.Revisions {
+		h, err := r.ResolveRevision(rev)
+		if err != nil {
+			return err
+		}
+
+		trees = append(trees, namedTree{rev.String(), *h})
+	}
+
+	spec := newPathspec(opts.Paths)
+	for _, t := range trees {
+		tree, err := r.getTreeFromCommitHash(t.hash)
+		if err != nil {
+			return err
+		}
+
+		patterns, err := readTreeAttributes(tree)
+		if err != nil {
+			return err
+		}
+
+		binary := diffBinary(patterns)
+		err = tree.Files().ForEach(func(file *object.File) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			if file.Mode != filemode.Regular && file.Mode != filemode.Executable {
+				return nil
+			}
+
+			if !opts.matchPath(spec, file.Name) {
+				return nil
+			}
+
+			content, err := readGrepBlob(file.Blob.Reader())
+			if err != nil {
+				return err
+			}
+
+			f := &grepFile{treeName: t.name, path: file.Name, content: content}
+			f.binary, f.ok = binary(file.Name)
+			return yield(f)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// grepIndex gives the files of the index to yield, read from the worktree
+// unless opts.Cached is set, along with the untracked files if
+// opts.Untracked is set.
+func (r *Repository) grepIndex(ctx context.Context, w *Worktree, opts *GrepOptions, yield func(*grepFile) error) error {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	var patterns []gitattributes.MatchAttribute
+	if w != nil {
+		patterns, err = gitattributes.ReadPatterns(w.Filesystem, nil)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	binary := diffBinary(patterns)
+	spec := newPathspec(opts.Paths)
+	var entries []*index.Entry
+	seen := make(map[string]bool)
+	for _, e := range idx.Entries {
+		if seen[e.Name] || (e.Mode != filemode.Regular && e.Mode != filemode.Executable) {
+			continue
+		}
+
+		// Only the worktree has the files of the unmerged and the
+		// intent-to-add entries.
+		if opts.Cached && (e.Stage != 0 || e.IntentToAdd) {
+			continue
+		}
+
+		seen[e.Name] = true
+		if opts.matchPath(spec, e.Name) {
+			entries = append(entries, e)
+		}
+	}
+
+	var untracked []string
+	if opts.Untracked {
+		if untracked, err = w.grepUntracked(opts, spec, seen); err != nil {
+			return err
+		}
+	}
+
+	// The untracked files are searched along with the tracked ones, in the
+	// order of their paths.
+	paths := make([]string, 0, len(entries)+len(untracked))
+	byPath := make(map[string]*index.Entry, len(entries))
+	for _, e := range entries {
+		paths = append(paths, e.Name)
+		byPath[e.Name] = e
+	}
+
+	paths = append(paths, untracked...)
+	if len(untracked) != 0 {
+		sort.Strings(paths)
+	}
+
+	for _, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var content []byte
+		if e := byPath[p]; e != nil && (opts.Cached || e.SkipWorktree) {
+			blob, err := r.BlobObject(e.Hash)
+			if err != nil {
+				return err
+			}
+
+			content, err = readGrepBlob(blob.Reader())
+			if err != nil {
+				return err
+			}
+		} else {
+			content, err = util.ReadFile(w.Filesystem, p)
+			if os.IsNotExist(err) {
+				continue
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+
+		f := &grepFile{path: p, content: content}
+		f.binary, f.ok = binary(p)
+		if err := yield(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// grepUntracked returns the paths of the regular files of the worktree not
+// tracked, skipping the ignored ones unless opts.NoExcludeStandard is set.
+func (w *Worktree) grepUntracked(opts *GrepOptions, spec *pathspec, tracked map[string]bool) ([]string, error) {
+	var m gitignore.Matcher
+	if !opts.NoExcludeStandard {
+		patterns, err := gitignore.ReadPatterns(w.Filesystem, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		m = gitignore.NewMatcher(append(patterns, w.Excludes...))
+	}
+
+	var paths []string
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		infos, err := w.Filesystem.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, fi := range infos {
+			p := path.Join(dir, fi.Name())
+			if dir == "" {
+				p = fi.Name()
+			}
+
+			if p == GitDirName || (m != nil && m.Match(strings.Split(p, "/"), fi.IsDir())) {
+				continue
+			}
+
+			if fi.IsDir() {
+				// The nested repositories are not searched.
+				if _, err := w.Filesystem.Lstat(path.Join(p, GitDirName)); err == nil {
+					continue
+				}
+
+				if err := walk(p); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			if fi.Mode().IsRegular() && !tracked[p] && opts.matchPath(spec, p) {
+				paths = append(paths, p)
+			}
+		}
+
+		return nil
+	}
+
+	return paths, walk("")
+}
+
+func readGrepBlob(r io.ReadCloser, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return io.ReadAll(r)
+}
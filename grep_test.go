@@ -0,0 +1,264 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGrepTestRepository(t *testing.T) (*Worktree, string) {
+	return newStatusTestRepository(t, map[string]string{
+		"a.txt":          "foo\nbar\nfoo bar\nbaz\nqux\nfoobar\n",
+		"b/c.txt":        "Foo\n",
+		"bin.dat":        "foo\x00\n",
+		"text.dat":       "foo\x00\n",
+		".gitattributes": "text.dat diff\n",
+	})
+}
+
+func grepStrings(results []GrepResult) []string {
+	var s []string
+	for _, r := range results {
+		s = append(s, r.String())
+	}
+
+	return s
+}
+
+func TestGrepExpression(t *testing.T) {
+	w, _ := newGrepTestRepository(t)
+
+	results, err := w.Grep(&GrepOptions{
+		Expression: GrepAnd{GrepPattern("foo"), GrepNot{GrepPattern("bar")}},
+		Paths:      []string{"a.txt"},
+	})
+	require.NoError(t, err)
+	head := results[0].TreeName
+	assert.Equal(t, []string{head + ":a.txt:1:foo"}, grepStrings(results))
+
+	results, err = w.Grep(&GrepOptions{
+		Expression: GrepOr{GrepPattern("foo"), GrepPattern("qux")},
+		WordRegexp: true,
+		IgnoreCase: true,
+		Paths:      []string{"a.txt", "b"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		head + ":a.txt:1:foo",
+		head + ":a.txt:3:foo bar",
+		head + ":a.txt:5:qux",
+		head + ":b/c.txt:1:Foo",
+	}, grepStrings(results))
+
+	results, err = w.Grep(&GrepOptions{
+		Expression:   GrepPattern("o b"),
+		FixedStrings: true,
+		InvertMatch:  true,
+		PathSpecs:    []*regexp.Regexp{regexp.MustCompile(`^a\.txt$`)},
+	})
+	require.NoError(t, err)
+	assert.Len(t, results, 5)
+
+	_, err = w.Grep(&GrepOptions{Expression: GrepPattern("(")})
+	assert.Error(t, err)
+	_, err = w.Grep(&GrepOptions{Expression: GrepPattern("a"), Patterns: []*regexp.Regexp{regexp.MustCompile("a")}})
+	assert.ErrorIs(t, err, ErrGrepPatterns)
+}
+
+func TestGrepContext(t *testing.T) {
+	w, _ := newGrepTestRepository(t)
+
+	results, err := w.Grep(&GrepOptions{
+		Expression:    GrepPattern("^ba"),
+		BeforeContext: 1,
+		AfterContext:  1,
+		Cached:        true,
+		Paths:         []string{"a.txt"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a.txt-1-foo",
+		"a.txt:2:bar",
+		"a.txt-3-foo bar",
+		"a.txt:4:baz",
+		"a.txt-5-qux",
+	}, grepStrings(results))
+	assert.True(t, results[0].Context)
+	assert.False(t, results[1].Context)
+}
+
+func TestGrepContextSeparator(t *testing.T) {
+	w, _ := newStatusTestRepository(t, map[string]string{
+		"a.txt": "1\n2\nx\n4\n5\n6\n7\n8\nx\n10\n11\n12\nx\n",
+		"b.txt": "x\n2\n",
+		"c.txt": "1\n2\n3\nx\n",
+	})
+
+	results, err := w.Grep(&GrepOptions{
+		Expression:    GrepPattern("x"),
+		BeforeContext: 2,
+		AfterContext:  1,
+		Cached:        true,
+	})
+	require.NoError(t, err)
+
+	// Like git grep -n -A1 -B2 x.
+	assert.Equal(t, ""+
+		"a.txt-1-1\n"+
+		"a.txt-2-2\n"+
+		"a.txt:3:x\n"+
+		"a.txt-4-4\n"+
+		"--\n"+
+		"a.txt-7-7\n"+
+		"a.txt-8-8\n"+
+		"a.txt:9:x\n"+
+		"a.txt-10-10\n"+
+		"a.txt-11-11\n"+
+		"a.txt-12-12\n"+
+		"a.txt:13:x\n"+
+		"--\n"+
+		"b.txt:1:x\n"+
+		"b.txt-2-2\n"+
+		"--\n"+
+		"c.txt-2-2\n"+
+		"c.txt-3-3\n"+
+		"c.txt:4:x", strings.Join(grepStrings(results), "\n"))
+	assert.True(t, results[4].Separator)
+	assert.False(t, results[5].Separator)
+
+	// Without context, there is no separator.
+	results, err = w.Grep(&GrepOptions{Expression: GrepPattern("x"), Cached: true})
+	require.NoError(t, err)
+	for _, r := range results {
+		assert.False(t, r.Separator)
+	}
+}
+
+func TestGrepCountAndFiles(t *testing.T) {
+	w, _ := newGrepTestRepository(t)
+
+	results, err := w.Grep(&GrepOptions{Expression: GrepPattern("foo"), Count: true, Cached: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:3", "bin.dat:1", "text.dat:1"}, grepStrings(results))
+
+	results, err = w.Grep(&GrepOptions{Expression: GrepPattern("(?i)foo"), FilesWithMatches: true, Cached: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "b/c.txt", "bin.dat", "text.dat"}, grepStrings(results))
+
+	results, err = w.Grep(&GrepOptions{Expression: GrepPattern("foo"), FilesWithoutMatch: true, Cached: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{".gitattributes", "b/c.txt"}, grepStrings(results))
+
+	_, err = w.Grep(&GrepOptions{Expression: GrepPattern("foo"), Count: true, FilesWithMatches: true})
+	assert.ErrorIs(t, err, ErrGrepOutput)
+}
+
+func TestGrepBinary(t *testing.T) {
+	w, _ := newGrepTestRepository(t)
+
+	results, err := w.Grep(&GrepOptions{Expression: GrepPattern("foo"), Cached: true, Paths: []string{"*.dat"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Binary file bin.dat matches", "text.dat:1:foo\x00"}, grepStrings(results))
+
+	results, err = w.Grep(&GrepOptions{Expression: GrepPattern("foo"), Cached: true, Paths: []string{"*.dat"}, Binary: GrepBinaryIgnore})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"text.dat:1:foo\x00"}, grepStrings(results))
+
+	results, err = w.Grep(&GrepOptions{Expression: GrepPattern("foo"), Cached: true, Paths: []string{"*.dat"}, Binary: GrepBinaryText})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bin.dat:1:foo\x00", "text.dat:1:foo\x00"}, grepStrings(results))
+}
+
+func TestGrepIndexAndWorktree(t *testing.T) {
+	w, dir := newGrepTestRepository(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b", "c.txt"), []byte("Foo\nfoo staged\n"), 0o644))
+	_, err := w.Add("b/c.txt")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b", "c.txt"), []byte("Foo\nfoo modified\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("foo new\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.log"), []byte("foo ignored\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0o644))
+
+	opts := func(o GrepOptions) *GrepOptions {
+		o.Expression = GrepPattern("foo ")
+		return &o
+	}
+
+	results, err := w.Grep(opts(GrepOptions{Cached: true}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:3:foo bar", "b/c.txt:2:foo staged"}, grepStrings(results))
+
+	results, err = w.Grep(opts(GrepOptions{Worktree: true}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:3:foo bar", "b/c.txt:2:foo modified"}, grepStrings(results))
+
+	results, err = w.Grep(opts(GrepOptions{Untracked: true}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:3:foo bar", "b/c.txt:2:foo modified", "new.txt:1:foo new"}, grepStrings(results))
+
+	results, err = w.Grep(opts(GrepOptions{Untracked: true, NoExcludeStandard: true}))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"a.txt:3:foo bar", "b/c.txt:2:foo modified", "ignored.log:1:foo ignored", "new.txt:1:foo new",
+	}, grepStrings(results))
+
+	_, err = w.Grep(opts(GrepOptions{Cached: true, Revisions: []plumbing.Revision{"HEAD"}}))
+	assert.ErrorIs(t, err, ErrGrepSources)
+}
+
+func TestGrepRevisions(t *testing.T) {
+	w, dir := newGrepTestRepository(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("foo changed\n"), 0o644))
+	_, err := w.Add("a.txt")
+	require.NoError(t, err)
+	_, err = w.Commit("change", &CommitOptions{Author: &object.Signature{Name: "foo", Email: "foo@foo.foo"}})
+	require.NoError(t, err)
+
+	results, err := w.Grep(&GrepOptions{
+		Expression: GrepPattern("^foo"),
+		Revisions:  []plumbing.Revision{"HEAD~1", "HEAD"},
+		Paths:      []string{"a.txt"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"HEAD~1:a.txt:1:foo",
+		"HEAD~1:a.txt:3:foo bar",
+		"HEAD~1:a.txt:6:foobar",
+		"HEAD:a.txt:1:foo changed",
+	}, grepStrings(results))
+}
+
+func TestGrepEach(t *testing.T) {
+	w, _ := newGrepTestRepository(t)
+
+	var results []GrepResult
+	err := w.GrepEach(context.Background(), &GrepOptions{Expression: GrepPattern("foo"), Cached: true, Threads: 3}, func(r GrepResult) error {
+		results = append(results, r)
+		if len(results) == 2 {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt:1:foo", "a.txt:3:foo bar"}, grepStrings(results))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = w.GrepEach(ctx, &GrepOptions{Expression: GrepPattern("foo"), Cached: true}, func(GrepResult) error { return nil })
+	assert.ErrorIs(t, err, context.Canceled)
+
+	_, err = w.Grep(&GrepOptions{Expression: GrepPattern("foo"), Threads: -1})
+	assert.ErrorIs(t, err, ErrGrepNegative)
+}
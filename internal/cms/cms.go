@@ -0,0 +1,506 @@
+// Package cms implements the subset of the Cryptographic Message Syntax
+// (RFC 5652) used by git for X.509 signatures, as produced by tools such as
+// gpgsm, smimesign and gitsign: detached SignedData structures with a single
+// signer, armored as "SIGNED MESSAGE" PEM blocks.
+package cms
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// PEMType is the PEM block type used by git for X.509 signatures.
+const PEMType = "SIGNED MESSAGE"
+
+var (
+	// ErrNoSignature is returned when the input does not contain a
+	// "SIGNED MESSAGE" PEM block.
+	ErrNoSignature = errors.New("cms: no signed message found")
+	// ErrNotDetached is returned when the SignedData embeds its content.
+	ErrNotDetached = errors.New("cms: signature is not detached")
+	// ErrNoSigner is returned when the SignedData has no signer information.
+	ErrNoSigner = errors.New("cms: no signer info")
+	// ErrSignerNotFound is returned when the certificate of the signer is not
+	// included in the SignedData.
+	ErrSignerNotFound = errors.New("cms: signer certificate not found")
+	// ErrDigestMismatch is returned when the message digest attribute does
+	// not match the signed content.
+	ErrDigestMismatch = errors.New("cms: message digest mismatch")
+	// ErrUnsupportedAlgorithm is returned for unknown digest algorithms or
+	// key types.
+	ErrUnsupportedAlgorithm = errors.New("cms: unsupported algorithm")
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningTime   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSAEncryption   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// SignedData is a parsed detached CMS signature.
+type SignedData struct {
+	// Certificates holds every certificate embedded in the signature.
+	Certificates []*x509.Certificate
+	// Signer is the certificate that produced the signature.
+	Signer *x509.Certificate
+	// SigningTime is the value of the signing-time attribute, if any.
+	SigningTime time.Time
+
+	hash          crypto.Hash
+	signedAttrs   []byte
+	messageDigest []byte
+	signature     []byte
+}
+
+// Parse decodes a PEM armored detached signature.
+func Parse(armored []byte) (*SignedData, error) {
+	block, _ := pem.Decode(armored)
+	if block == nil || block.Type != PEMType {
+		return nil, ErrNoSignature
+	}
+
+	return ParseDER(block.Bytes)
+}
+
+// ParseDER decodes a DER encoded detached signature.
+func ParseDER(der []byte) (*SignedData, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("cms: %w", err)
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, fmt.Errorf("cms: unexpected content type %s", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("cms: %w", err)
+	}
+
+	if len(sd.EncapContentInfo.EContent.Bytes) != 0 {
+		return nil, ErrNotDetached
+	}
+
+	if len(sd.SignerInfos) == 0 {
+		return nil, ErrNoSigner
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("cms: %w", err)
+	}
+
+	si := sd.SignerInfos[0]
+	signer, err := findSigner(si.SID, certs)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := hashForOID(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &SignedData{
+		Certificates: certs,
+		Signer:       signer,
+		hash:         h,
+		signature:    si.Signature,
+	}
+
+	if len(si.SignedAttrs.FullBytes) != 0 {
+		if err := out.parseSignedAttrs(si.SignedAttrs); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+func (sd *SignedData) parseSignedAttrs(raw asn1.RawValue) error {
+	// The signature is computed over the DER encoding of the attributes
+	// as a SET OF, not over the IMPLICIT [0] tagged value.
+	attrs := make([]byte, len(raw.FullBytes))
+	copy(attrs, raw.FullBytes)
+	attrs[0] = 0x31
+	sd.signedAttrs = attrs
+
+	var list []attribute
+	if _, err := asn1.UnmarshalWithParams(attrs, &list, "set"); err != nil {
+		return fmt.Errorf("cms: %w", err)
+	}
+
+	for _, a := range list {
+		switch {
+		case a.Type.Equal(oidMessageDigest):
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &sd.messageDigest); err != nil {
+				return fmt.Errorf("cms: %w", err)
+			}
+		case a.Type.Equal(oidSigningTime):
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &sd.SigningTime); err != nil {
+				return fmt.Errorf("cms: %w", err)
+			}
+		case a.Type.Equal(oidContentType):
+			var ct asn1.ObjectIdentifier
+			if _, err := asn1.Unmarshal(a.Values.Bytes, &ct); err != nil {
+				return fmt.Errorf("cms: %w", err)
+			}
+			if !ct.Equal(oidData) {
+				return fmt.Errorf("cms: unexpected content type %s", ct)
+			}
+		}
+	}
+
+	if sd.messageDigest == nil {
+		return errors.New("cms: missing message digest attribute")
+	}
+
+	return nil
+}
+
+// CheckSignature verifies that the signature is valid for content and was
+// produced by the key of the signer certificate. It does not validate the
+// certificate chain, see Verify.
+func (sd *SignedData) CheckSignature(content []byte) error {
+	signed := content
+	if sd.signedAttrs != nil {
+		h := sd.hash.New()
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), sd.messageDigest) {
+			return ErrDigestMismatch
+		}
+
+		signed = sd.signedAttrs
+	}
+
+	algo, err := signatureAlgorithm(sd.Signer.PublicKey, sd.hash)
+	if err != nil {
+		return err
+	}
+
+	return sd.Signer.CheckSignature(algo, signed, sd.signature)
+}
+
+// Verify checks the signature over content and validates the signer
+// certificate chain with opts. Certificates embedded in the signature are
+// added to a copy of the intermediates. When opts.CurrentTime is zero, the
+// chain is validated at the current time.
+//
+// It returns the validated chains on success.
+func (sd *SignedData) Verify(content []byte, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	if err := sd.CheckSignature(content); err != nil {
+		return nil, err
+	}
+
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+	} else {
+		opts.Intermediates = opts.Intermediates.Clone()
+	}
+
+	for _, c := range sd.Certificates {
+		if c != sd.Signer {
+			opts.Intermediates.AddCert(c)
+		}
+	}
+
+	if opts.CurrentTime.IsZero() {
+		opts.CurrentTime = time.Now()
+	}
+
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	return sd.Signer.Verify(opts)
+}
+
+// VerifyAtSigningTime is like Verify, but validates the chain at the
+// signing time attribute when opts.CurrentTime is zero, so signatures made
+// with short-lived certificates remain valid after they expire. The signing
+// time is chosen by the signer: it must only be trusted when the signer is.
+func (sd *SignedData) VerifyAtSigningTime(content []byte, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	if opts.CurrentTime.IsZero() {
+		opts.CurrentTime = sd.SigningTime
+	}
+
+	return sd.Verify(content, opts)
+}
+
+// Sign produces a PEM armored detached signature of content, using key and
+// the certificate chain, the first of which must be the certificate of key.
+func Sign(content []byte, key crypto.Signer, chain []*x509.Certificate, signingTime time.Time) ([]byte, error) {
+	if len(chain) == 0 {
+		return nil, ErrSignerNotFound
+	}
+	leaf := chain[0]
+
+	h, sigAlgo, err := signingAlgorithms(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := h.New()
+	hasher.Write(content)
+
+	attrs, err := marshalSignedAttrs(hasher.Sum(nil), signingTime)
+	if err != nil {
+		return nil, err
+	}
+
+	var sig []byte
+	if _, ok := key.Public().(ed25519.PublicKey); ok {
+		sig, err = key.Sign(rand.Reader, attrs, crypto.Hash(0))
+	} else {
+		hasher = h.New()
+		hasher.Write(attrs)
+		sig, err = key.Sign(rand.Reader, hasher.Sum(nil), h)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerialNumber{
+		Issuer:       asn1.RawValue{FullBytes: leaf.RawIssuer},
+		SerialNumber: leaf.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace the SET OF tag with the IMPLICIT [0] tag of SignerInfo.
+	attrs[0] = 0xa0
+
+	var rawCerts []byte
+	for _, c := range chain {
+		rawCerts = append(rawCerts, c.Raw...)
+	}
+
+	digestAlgo := pkix.AlgorithmIdentifier{Algorithm: oidForHash(h)}
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgo},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: rawCerts,
+		},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    digestAlgo,
+			SignedAttrs:        asn1.RawValue{FullBytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: sigAlgo},
+			Signature:          sig,
+		}},
+	}
+
+	inner, err := asn1.Marshal(sd)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: inner,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMType, Bytes: der}), nil
+}
+
+func marshalSignedAttrs(digest []byte, signingTime time.Time) ([]byte, error) {
+	attrs := make([]attribute, 0, 3)
+	add := func(oid asn1.ObjectIdentifier, v interface{}) error {
+		b, err := asn1.Marshal(v)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, attribute{
+			Type: oid,
+			Values: asn1.RawValue{
+				Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: b,
+			},
+		})
+		return nil
+	}
+
+	if err := add(oidContentType, oidData); err != nil {
+		return nil, err
+	}
+	if !signingTime.IsZero() {
+		if err := add(oidSigningTime, signingTime.UTC()); err != nil {
+			return nil, err
+		}
+	}
+	if err := add(oidMessageDigest, digest); err != nil {
+		return nil, err
+	}
+
+	return asn1.MarshalWithParams(attrs, "set")
+}
+
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	// SubjectKeyIdentifier, [0] IMPLICIT OCTET STRING.
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+
+		return nil, ErrSignerNotFound
+	}
+
+	var ias issuerAndSerialNumber
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("cms: %w", err)
+	}
+
+	for _, c := range certs {
+		if c.SerialNumber.Cmp(ias.SerialNumber) == 0 &&
+			bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) {
+			return c, nil
+		}
+	}
+
+	return nil, ErrSignerNotFound
+}
+
+func hashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+
+	return 0, fmt.Errorf("%w: digest %s", ErrUnsupportedAlgorithm, oid)
+}
+
+func oidForHash(h crypto.Hash) asn1.ObjectIdentifier {
+	switch h {
+	case crypto.SHA1:
+		return oidSHA1
+	case crypto.SHA384:
+		return oidSHA384
+	case crypto.SHA512:
+		return oidSHA512
+	}
+
+	return oidSHA256
+}
+
+func signingAlgorithms(pub crypto.PublicKey) (crypto.Hash, asn1.ObjectIdentifier, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return crypto.SHA256, oidRSAEncryption, nil
+	case *ecdsa.PublicKey:
+		return crypto.SHA256, oidECDSAWithSHA256, nil
+	case ed25519.PublicKey:
+		return crypto.SHA512, oidEd25519, nil
+	}
+
+	return 0, nil, fmt.Errorf("%w: key type %T", ErrUnsupportedAlgorithm, pub)
+}
+
+func signatureAlgorithm(pub crypto.PublicKey, h crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		switch h {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA, nil
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case *ecdsa.PublicKey:
+		switch h {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1, nil
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	}
+
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("%w: key type %T", ErrUnsupportedAlgorithm, pub)
+}
@@ -0,0 +1,163 @@
+package cms
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type CMSSuite struct {
+	suite.Suite
+	root    *x509.Certificate
+	rootKey crypto.Signer
+}
+
+func TestCMSSuite(t *testing.T) {
+	suite.Run(t, new(CMSSuite))
+}
+
+func (s *CMSSuite) SetupSuite() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "go-git test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	s.root = s.certificate(tmpl, tmpl, key.Public(), key)
+	s.rootKey = key
+}
+
+func (s *CMSSuite) certificate(tmpl, parent *x509.Certificate, pub crypto.PublicKey, key crypto.Signer) *x509.Certificate {
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, pub, key)
+	s.Require().NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	s.Require().NoError(err)
+	return cert
+}
+
+func (s *CMSSuite) leaf(key crypto.Signer, notBefore, notAfter time.Time) *x509.Certificate {
+	return s.certificate(&x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		Subject:        pkix.Name{CommonName: "go-git"},
+		EmailAddresses: []string{"go-git@example.com"},
+		NotBefore:      notBefore,
+		NotAfter:       notAfter,
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, s.root, key.Public(), s.rootKey)
+}
+
+func (s *CMSSuite) roots() x509.VerifyOptions {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.root)
+	return x509.VerifyOptions{Roots: pool}
+}
+
+func (s *CMSSuite) TestSignVerify() {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	s.Require().NoError(err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	s.Require().NoError(err)
+
+	content := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nmessage\n")
+	for _, key := range []crypto.Signer{ecKey, rsaKey, edKey} {
+		cert := s.leaf(key, time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+
+		armored, err := Sign(content, key, []*x509.Certificate{cert}, time.Now())
+		s.NoError(err)
+
+		sd, err := Parse(armored)
+		s.NoError(err)
+		s.Equal(cert.Raw, sd.Signer.Raw)
+
+		chains, err := sd.Verify(content, s.roots())
+		s.NoError(err)
+		s.Len(chains, 1)
+
+		s.ErrorIs(sd.CheckSignature([]byte("tampered")), ErrDigestMismatch)
+	}
+}
+
+func (s *CMSSuite) TestVerifyUsesSigningTime() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+
+	signedAt := time.Now().Add(-30 * time.Minute)
+	cert := s.leaf(key, signedAt.Add(-time.Minute), signedAt.Add(10*time.Minute))
+
+	content := []byte("content")
+	armored, err := Sign(content, key, []*x509.Certificate{cert}, signedAt)
+	s.NoError(err)
+
+	sd, err := Parse(armored)
+	s.NoError(err)
+	s.Equal(signedAt.Unix(), sd.SigningTime.Unix())
+
+	_, err = sd.VerifyAtSigningTime(content, s.roots())
+	s.NoError(err)
+
+	// The signing time is only used when asked for.
+	_, err = sd.Verify(content, s.roots())
+	s.Error(err)
+
+	opts := s.roots()
+	opts.CurrentTime = time.Now()
+	_, err = sd.VerifyAtSigningTime(content, opts)
+	s.Error(err)
+}
+
+func (s *CMSSuite) TestVerifyKeepsIntermediates() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	cert := s.leaf(key, time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+
+	armored, err := Sign([]byte("content"), key, []*x509.Certificate{cert, s.root}, time.Now())
+	s.NoError(err)
+
+	sd, err := Parse(armored)
+	s.NoError(err)
+
+	opts := s.roots()
+	opts.Intermediates = x509.NewCertPool()
+	_, err = sd.Verify([]byte("content"), opts)
+	s.NoError(err)
+	s.True(opts.Intermediates.Equal(x509.NewCertPool()))
+}
+
+func (s *CMSSuite) TestVerifyUnknownRoot() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	s.Require().NoError(err)
+	cert := s.leaf(key, time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+
+	armored, err := Sign([]byte("content"), key, []*x509.Certificate{cert}, time.Now())
+	s.NoError(err)
+
+	sd, err := Parse(armored)
+	s.NoError(err)
+
+	_, err = sd.Verify([]byte("content"), x509.VerifyOptions{Roots: x509.NewCertPool()})
+	s.Error(err)
+}
+
+func (s *CMSSuite) TestParseNoSignature() {
+	_, err := Parse([]byte("-----BEGIN PGP SIGNATURE-----\n\n-----END PGP SIGNATURE-----\n"))
+	s.ErrorIs(err, ErrNoSignature)
+}
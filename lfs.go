@@ -0,0 +1,392 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/convert"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+// ErrLFSNotSupported is returned when the storage of the repository can not
+// hold LFS objects, only the storages backed by a filesystem can.
+var ErrLFSNotSupported = errors.New("lfs: storage not supported")
+
+// lfsStorage returns the storage of the LFS objects of s, in the lfs
+// directory of its filesystem.
+func lfsStorage(s storage.Storer) (*lfs.Storage, bool) {
+	fs, ok := s.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return nil, false
+	}
+
+	dir, err := fs.Filesystem().Chroot(lfs.Path)
+	if err != nil {
+		return nil, false
+	}
+
+	return lfs.NewStorage(dir), true
+}
+
+// lfsRemote is the remote whose LFS server is used, with the credentials and
+// the transport options to reach it.
+type lfsRemote struct {
+	url             string
+	auth            transport.AuthMethod
+	insecureSkipTLS bool
+	caBundle        []byte
+	proxyOptions    transport.ProxyOptions
+}
+
+// newLFSClient returns the client of the LFS server set by lfs.url, or else
+// the one derived from the URL of the remote. The server of SSH remotes, and
+// the credentials to use with it, are given by git-lfs-authenticate for the
+// operation.
+func newLFSClient(ctx context.Context, s storage.Storer, remote *lfsRemote, operation string) (*lfs.Client, error) {
+	cfg, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := cfg.LFS.URL
+	var auth lfs.AuthMethod
+	if endpoint == "" {
+		if endpoint, err = lfs.Endpoint(remote.url); err != nil {
+			return nil, err
+		}
+
+		if ep, err := transport.NewEndpoint(remote.url); err == nil && ep.Protocol == "ssh" {
+			ep.Proxy = remote.proxyOptions
+			a, err := lfs.Authenticate(ctx, ep, remote.auth, operation)
+			if err != nil {
+				trace.General.Printf("warning: %s failed: %s", lfs.AuthenticateCommand, err)
+				a = &lfs.Action{}
+			}
+
+			if a.Href != "" {
+				endpoint = a.Href
+			}
+
+			if len(a.Header) != 0 {
+				auth = lfs.HeaderAuth(a.Header)
+			}
+		}
+	}
+
+	ep, err := transport.NewEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	ep.InsecureSkipTLS = remote.insecureSkipTLS
+	ep.CaBundle = remote.caBundle
+	ep.Proxy = remote.proxyOptions
+
+	c := lfs.NewClient(endpoint, remote.auth)
+	if auth != nil {
+		c.Auth = auth
+	}
+
+	// The requests are sent with the http client of the transport, set up
+	// with the TLS and proxy options like for the remote.
+	t, err := transport.Get(ep.Protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := t.NewSession(nil, ep, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if hs, ok := sess.(*http.HTTPSession); ok {
+		c.Client = hs.Client()
+	}
+
+	return c, nil
+}
+
+// lfsRemoteURL returns the URL of the remote with the given name, or an
+// empty string when there is no such remote.
+func lfsRemoteURL(cfg *config.Config, name string) string {
+	if rc, ok := cfg.Remotes[name]; ok && len(rc.URLs) > 0 {
+		return rc.URLs[0]
+	}
+
+	return ""
+}
+
+// FetchLFS downloads the LFS objects of the files of a commit, the ones with
+// the `filter=lfs` attribute, missing from the local storage.
+func (r *Repository) FetchLFS(ctx context.Context, o *FetchLFSOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	s, ok := lfsStorage(r.Storer)
+	if !ok {
+		return ErrLFSNotSupported
+	}
+
+	commit := o.Commit
+	if commit.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		commit = head.Hash()
+	}
+
+	c, err := r.CommitObject(commit)
+	if err != nil {
+		return err
+	}
+
+	t, err := c.Tree()
+	if err != nil {
+		return err
+	}
+
+	pointers, err := r.lfsPointers(t)
+	if err != nil || len(pointers) == 0 {
+		return err
+	}
+
+	cfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	client, err := newLFSClient(ctx, r.Storer, &lfsRemote{
+		url:             lfsRemoteURL(cfg, o.RemoteName),
+		auth:            o.Auth,
+		insecureSkipTLS: o.InsecureSkipTLS,
+		caBundle:        o.CABundle,
+		proxyOptions:    o.ProxyOptions,
+	}, lfs.OperationDownload)
+	if err != nil {
+		return err
+	}
+
+	return client.Download(ctx, s, pointers)
+}
+
+// fetchLFS fetches the LFS objects of commit before it is checked out. LFS
+// is skipped when the storage or the remote do not support it, the pointer
+// files being checked out instead.
+func (r *Repository) fetchLFS(ctx context.Context, o *FetchLFSOptions) error {
+	if _, ok := lfsStorage(r.Storer); !ok {
+		return nil
+	}
+
+	err := r.FetchLFS(ctx, o)
+	if errors.Is(err, lfs.ErrUnsupportedEndpoint) {
+		trace.General.Printf("warning: %s", err)
+		return nil
+	}
+
+	return err
+}
+
+// lfsPointers returns the pointers of the files of t with the `filter=lfs`
+// attribute.
+func (r *Repository) lfsPointers(t *object.Tree) ([]*lfs.Pointer, error) {
+	patterns, err := readTreeAttributes(t)
+	if err != nil || len(patterns) == 0 {
+		return nil, err
+	}
+
+	m := gitattributes.NewMatcher(patterns)
+	attrs := []string{convert.FilterAttr}
+
+	var pointers []*lfs.Pointer
+	err = t.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() || f.Size >= lfs.MaxPointerSize {
+			return nil
+		}
+
+		a, _ := m.Match(strings.Split(f.Name, "/"), attrs)
+		if v := a[convert.FilterAttr]; v == nil || !v.IsValueSet() || v.Value() != lfs.FilterName {
+			return nil
+		}
+
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+
+		if p, err := lfs.ParsePointer([]byte(content)); err == nil {
+			pointers = append(pointers, p)
+		}
+
+		return nil
+	})
+
+	return pointers, err
+}
+
+// pushLFS uploads the LFS objects referenced by the pointers among the
+// objects being pushed.
+func (r *Remote) pushLFS(ctx context.Context, hashes []plumbing.Hash, o *PushOptions) error {
+	s, ok := lfsStorage(r.s)
+	if !ok {
+		return nil
+	}
+
+	var pointers []*lfs.Pointer
+	for _, h := range hashes {
+		p, err := readLFSPointer(r.s, h)
+		if err != nil {
+			return err
+		}
+
+		if p != nil {
+			pointers = append(pointers, p)
+		}
+	}
+
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	client, err := newLFSClient(ctx, r.s, &lfsRemote{
+		url:             o.RemoteURL,
+		auth:            o.Auth,
+		insecureSkipTLS: o.InsecureSkipTLS,
+		caBundle:        o.CABundle,
+		proxyOptions:    o.ProxyOptions,
+	}, lfs.OperationUpload)
+	if errors.Is(err, lfs.ErrUnsupportedEndpoint) {
+		trace.General.Printf("warning: %s", err)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return client.Upload(ctx, s, pointers)
+}
+
+// readLFSPointer returns the pointer stored in the object h, or nil if it is
+// not a blob holding a pointer file.
+func readLFSPointer(s storage.Storer, h plumbing.Hash) (*lfs.Pointer, error) {
+	obj, err := s.EncodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj.Type() != plumbing.BlobObject || obj.Size() >= lfs.MaxPointerSize {
+		return nil, nil
+	}
+
+	rd, err := obj.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer rd.Close()
+
+	content, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := lfs.ParsePointer(content)
+	if err != nil {
+		return nil, nil
+	}
+
+	return p, nil
+}
+
+// lfsFilter is the native implementation of the lfs filter driver, storing
+// the content of the files in the LFS storage of the repository and
+// replacing it with a pointer in the object database.
+type lfsFilter struct {
+	r *Repository
+	s *lfs.Storage
+	// auth authenticates the downloads of the missing objects.
+	auth transport.AuthMethod
+
+	// hashOnly computes the pointers without storing the content, used
+	// when comparing the worktree with the index.
+	hashOnly bool
+}
+
+// Clean implements convert.Filter.
+func (f *lfsFilter) Clean(_ string, content []byte) ([]byte, error) {
+	if lfs.IsPointer(content) {
+		return content, nil
+	}
+
+	var p *lfs.Pointer
+	var err error
+	if f.hashOnly {
+		p, err = lfs.NewPointer(bytes.NewReader(content))
+	} else {
+		p, err = f.s.Store(bytes.NewReader(content))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Bytes(), nil
+}
+
+// Smudge implements convert.Filter. Objects missing from the storage are
+// downloaded from the LFS server of the default remote.
+func (f *lfsFilter) Smudge(path string, content []byte) ([]byte, error) {
+	p, err := lfs.ParsePointer(content)
+	if err != nil {
+		return content, nil
+	}
+
+	if !f.s.Has(p) {
+		if err := f.download(p); err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", convert.ErrFilterFailed, path, err)
+		}
+	}
+
+	rd, err := f.s.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rd.Close()
+	return io.ReadAll(rd)
+}
+
+func (f *lfsFilter) download(p *lfs.Pointer) error {
+	cfg, err := f.r.Config()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := newLFSClient(ctx, f.r.Storer, &lfsRemote{
+		url:  lfsRemoteURL(cfg, DefaultRemoteName),
+		auth: f.auth,
+	}, lfs.OperationDownload)
+	if err != nil {
+		return err
+	}
+
+	return client.Download(ctx, f.s, []*lfs.Pointer{p})
+}
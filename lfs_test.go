@@ -0,0 +1,199 @@
+package git
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+func newLFSTestRepository(t *testing.T, lfsURL string) (*Repository, *lfs.Storage) {
+	st := filesystem.NewStorage(memfs.New(), cache.NewObjectLRUDefault())
+	r, err := Init(st, WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.LFS.URL = lfsURL
+	require.NoError(t, r.SetConfig(cfg))
+
+	s, ok := lfsStorage(st)
+	require.True(t, ok)
+	return r, s
+}
+
+func TestLFSRoundTrip(t *testing.T) {
+	server := lfs.NewStorage(memfs.New())
+	srv := httptest.NewServer(lfs.NewServer(server))
+	defer srv.Close()
+
+	remote := t.TempDir()
+	_, err := PlainInit(remote, true)
+	require.NoError(t, err)
+
+	r, s := newLFSTestRepository(t, srv.URL)
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{remote}})
+	require.NoError(t, err)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	content := strings.Repeat("large content\n", 1024)
+	require.NoError(t, util.WriteFile(w.Filesystem, ".gitattributes", []byte("*.bin filter=lfs diff=lfs merge=lfs -text\n"), 0o644))
+	require.NoError(t, util.WriteFile(w.Filesystem, "asset.bin", []byte(content), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+
+	p, err := lfs.ParsePointer([]byte(stagedContent(t, r, "asset.bin")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(content)), p.Size)
+	assert.True(t, s.Has(p))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Added, status.File("asset.bin").Staging)
+	assert.Equal(t, Unmodified, status.File("asset.bin").Worktree)
+
+	commitAll(t, w)
+	require.NoError(t, r.Push(&PushOptions{}))
+	assert.True(t, server.Has(p))
+
+	clone, cs := newLFSTestRepository(t, srv.URL)
+	require.NoError(t, clone.clone(context.Background(), &CloneOptions{URL: remote}))
+	assert.True(t, cs.Has(p))
+
+	cw, err := clone.Worktree()
+	require.NoError(t, err)
+	checkedOut, err := util.ReadFile(cw.Filesystem, "asset.bin")
+	require.NoError(t, err)
+	assert.Equal(t, content, string(checkedOut))
+
+	status, err = cw.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+}
+
+func TestLFSCheckoutMissingObject(t *testing.T) {
+	srv := httptest.NewServer(lfs.NewServer(lfs.NewStorage(memfs.New())))
+	defer srv.Close()
+
+	r, s := newLFSTestRepository(t, srv.URL)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(w.Filesystem, ".gitattributes", []byte("*.bin filter=lfs\n"), 0o644))
+	require.NoError(t, util.WriteFile(w.Filesystem, "asset.bin", []byte("foo"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	commitAll(t, w)
+
+	pointer := stagedContent(t, r, "asset.bin")
+	p, err := lfs.ParsePointer([]byte(pointer))
+	require.NoError(t, err)
+
+	// Without the object, neither locally nor in the server, the pointer
+	// file is checked out.
+	lfsFS, err := r.Storer.(*filesystem.Storage).Filesystem().Chroot(lfs.Path)
+	require.NoError(t, err)
+	require.NoError(t, util.RemoveAll(lfsFS, "objects"))
+	require.False(t, s.Has(p))
+
+	require.NoError(t, w.Filesystem.Remove("asset.bin"))
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+
+	checkedOut, err := util.ReadFile(w.Filesystem, "asset.bin")
+	require.NoError(t, err)
+	assert.Equal(t, pointer, string(checkedOut))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+}
+
+func TestFetchLFSNotSupported(t *testing.T) {
+	r, err := Init(memory.NewStorage())
+	require.NoError(t, err)
+
+	err = r.FetchLFS(context.Background(), &FetchLFSOptions{})
+	assert.ErrorIs(t, err, ErrLFSNotSupported)
+}
+
+// commitMissingLFSObject commits a LFS file whose object is only stored in
+// server, returning its pointer.
+func commitMissingLFSObject(t *testing.T, r *Repository, s, server *lfs.Storage) *lfs.Pointer {
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(w.Filesystem, ".gitattributes", []byte("*.bin filter=lfs\n"), 0o644))
+	require.NoError(t, util.WriteFile(w.Filesystem, "asset.bin", []byte("foo"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	commitAll(t, w)
+
+	p, err := server.Store(strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	lfsFS, err := r.Storer.(*filesystem.Storage).Filesystem().Chroot(lfs.Path)
+	require.NoError(t, err)
+	require.NoError(t, util.RemoveAll(lfsFS, "objects"))
+	require.False(t, s.Has(p))
+	return p
+}
+
+func TestFetchLFSTransportOptions(t *testing.T) {
+	server := lfs.NewStorage(memfs.New())
+	srv := httptest.NewTLSServer(lfs.NewServer(server))
+	defer srv.Close()
+
+	r, s := newLFSTestRepository(t, srv.URL)
+	p := commitMissingLFSObject(t, r, s, server)
+
+	ctx := context.Background()
+	require.Error(t, r.FetchLFS(ctx, &FetchLFSOptions{}))
+	require.False(t, s.Has(p))
+
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, r.FetchLFS(ctx, &FetchLFSOptions{CABundle: caBundle}))
+	assert.True(t, s.Has(p))
+}
+
+func TestLFSCheckoutAuth(t *testing.T) {
+	server := lfs.NewStorage(memfs.New())
+	handler := lfs.NewServer(server)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	r, s := newLFSTestRepository(t, srv.URL)
+	p := commitMissingLFSObject(t, r, s, server)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Filesystem.Remove("asset.bin"))
+	require.NoError(t, w.Reset(&ResetOptions{
+		Mode: HardReset,
+		Auth: &githttp.BasicAuth{Username: "user", Password: "secret"},
+	}))
+	assert.True(t, s.Has(p))
+
+	checkedOut, err := util.ReadFile(w.Filesystem, "asset.bin")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", string(checkedOut))
+}
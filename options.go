@@ -1,19 +1,24 @@
 package git
 
 import (
+	"compress/flate"
 	"errors"
 	"fmt"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
 	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/archive"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp"
 	"github.com/go-git/go-git/v6/plumbing/protocol/packp/sideband"
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/diff"
 )
 
 // SubmoduleRecursivity defines how depth will affect any submodule recursive
@@ -38,6 +43,11 @@ type CloneOptions struct {
 	URL string
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// UseCredentialHelpers, when Auth is nil, asks the credential helpers
+	// configured in credential.helper for the credentials of http remotes,
+	// as git does. The helpers that are not registered in process are run
+	// as external commands, see credential.NewExecHelper.
+	UseCredentialHelpers bool
 	// Name of the remote to be added, by default `origin`.
 	RemoteName string
 	// Remote branch to clone.
@@ -147,6 +157,11 @@ type PullOptions struct {
 	Depth int
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// UseCredentialHelpers, when Auth is nil, asks the credential helpers
+	// configured in credential.helper for the credentials of http remotes,
+	// as git does. The helpers that are not registered in process are run
+	// as external commands, see credential.NewExecHelper.
+	UseCredentialHelpers bool
 	// RecurseSubmodules controls if new commits of all populated submodules
 	// should be fetched too.
 	RecurseSubmodules SubmoduleRecursivity
@@ -203,6 +218,11 @@ type FetchOptions struct {
 	Depth int
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// UseCredentialHelpers, when Auth is nil, asks the credential helpers
+	// configured in credential.helper for the credentials of http remotes,
+	// as git does. The helpers that are not registered in process are run
+	// as external commands, see credential.NewExecHelper.
+	UseCredentialHelpers bool
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored and the capability (if supported)
 	// no-progress, is sent to the server to avoid send this information.
@@ -225,6 +245,11 @@ type FetchOptions struct {
 	// Filter requests that the server to send only a subset of the objects.
 	// See https://git-scm.com/docs/git-clone#Documentation/git-clone.txt-code--filterltfilter-specgtcode
 	Filter packp.Filter
+	// RecurseSubmodules controls whether the populated submodules are
+	// fetched too, only the ones missing commits recorded by the fetched
+	// commits with config.RecurseSubmodulesOnDemand. Defaults to
+	// fetch.recurseSubmodules, no recursion when unset.
+	RecurseSubmodules config.RecurseSubmodules
 }
 
 // Validate validates the fields and sets the default values.
@@ -246,6 +271,32 @@ func (o *FetchOptions) Validate() error {
 	return nil
 }
 
+// FetchLFSOptions describes how the LFS objects of a commit are fetched.
+type FetchLFSOptions struct {
+	// RemoteName is the name of the remote whose LFS server the objects are
+	// downloaded from, unless lfs.url is set. Defaults to origin.
+	RemoteName string
+	// Auth credentials, if required, to use with the LFS server.
+	Auth transport.AuthMethod
+	// Commit whose files are fetched. Defaults to HEAD.
+	Commit plumbing.Hash
+	// InsecureSkipTLS skips ssl verify if protocol is https
+	InsecureSkipTLS bool
+	// CABundle specify additional ca bundle with system cert pool
+	CABundle []byte
+	// ProxyOptions provides info required for connecting to a proxy.
+	ProxyOptions transport.ProxyOptions
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FetchLFSOptions) Validate() error {
+	if o.RemoteName == "" {
+		o.RemoteName = DefaultRemoteName
+	}
+
+	return nil
+}
+
 // PushOptions describes how a push should be performed.
 type PushOptions struct {
 	// RemoteName is the name of the remote to be pushed to.
@@ -263,6 +314,11 @@ type PushOptions struct {
 	RefSpecs []config.RefSpec
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// UseCredentialHelpers, when Auth is nil, asks the credential helpers
+	// configured in credential.helper for the credentials of http remotes,
+	// as git does. The helpers that are not registered in process are run
+	// as external commands, see credential.NewExecHelper.
+	UseCredentialHelpers bool
 	// Progress is where the human readable information sent by the server is
 	// stored, if nil nothing is stored.
 	Progress sideband.Progress
@@ -290,6 +346,13 @@ type PushOptions struct {
 	Atomic bool
 	// ProxyOptions provides info required for connecting to a proxy.
 	ProxyOptions transport.ProxyOptions
+	// RecurseSubmodules controls whether the commits recorded for the
+	// submodules by the pushed commits must be on a remote of their
+	// repository: config.RecurseSubmodulesCheck refuses the push with
+	// ErrSubmoduleNotPushed otherwise, config.RecurseSubmodulesOnDemand
+	// pushes the submodules first. Defaults to push.recurseSubmodules, no
+	// check when unset.
+	RecurseSubmodules config.RecurseSubmodules
 }
 
 // ForceWithLease sets fields on the lease
@@ -344,6 +407,31 @@ type SubmoduleUpdateOptions struct {
 	Depth int
 }
 
+// SubmoduleAddOptions describes how a submodule is added, see
+// Worktree.AddSubmodule.
+type SubmoduleAddOptions struct {
+	// Name of the submodule, its path by default.
+	Name string
+	// Branch of the repository checked out, and recorded in the .gitmodules
+	// file. The remote HEAD is checked out by default.
+	Branch string
+	// Depth limit fetching to the specified number of commits.
+	Depth int
+	// Auth credentials, if required, to use with the remote repository.
+	Auth transport.AuthMethod
+	// Progress is where the human readable information sent by the server is
+	// stored, if nil nothing is stored.
+	Progress sideband.Progress
+}
+
+// SubmoduleDeinitOptions describes how a submodule is deinitialized, see
+// Submodule.Deinit.
+type SubmoduleDeinitOptions struct {
+	// Force the removal of the worktree of the submodule even if it holds
+	// local modifications.
+	Force bool
+}
+
 var (
 	ErrBranchHashExclusive  = errors.New("Branch and Hash are mutually exclusive")
 	ErrCreateRequiresBranch = errors.New("Branch is mandatory when Create is used")
@@ -369,6 +457,9 @@ type CheckoutOptions struct {
 	Keep bool
 	// SparseCheckoutDirectories
 	SparseCheckoutDirectories []string
+	// Auth credentials, if required, to use with the LFS server when the LFS
+	// objects of the files checked out are missing.
+	Auth transport.AuthMethod
 }
 
 // Validate validates the fields and sets the default values.
@@ -388,6 +479,33 @@ func (o *CheckoutOptions) Validate() error {
 	return nil
 }
 
+var ErrSparseIndexNoCone = errors.New("sparse index requires the cone mode")
+
+// SparseCheckoutOptions describes how the sparse checkout of a worktree is
+// set, see Worktree.SparseCheckoutSet.
+type SparseCheckoutOptions struct {
+	// Patterns are the directories checked out in cone mode, along with the
+	// files of the root directory and of their leading directories. When
+	// NoCone is set, they are the gitignore-like patterns of the files
+	// checked out.
+	Patterns []string
+	// NoCone disables the cone mode (core.sparseCheckoutCone).
+	NoCone bool
+	// SparseIndex writes the index as a sparse index (index.sparse), where
+	// the directories out of the sparse checkout are stored as tree entries.
+	// It requires the cone mode.
+	SparseIndex bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *SparseCheckoutOptions) Validate() error {
+	if o.NoCone && o.SparseIndex {
+		return ErrSparseIndexNoCone
+	}
+
+	return nil
+}
+
 // ResetMode defines the mode of a reset operation.
 type ResetMode int8
 
@@ -431,6 +549,10 @@ type ResetOptions struct {
 
 	// SkipSparseDirValidation will skip the validation for SparseDirs.
 	SkipSparseDirValidation bool
+
+	// Auth credentials, if required, to use with the LFS server when the LFS
+	// objects of the files checked out are missing.
+	Auth transport.AuthMethod
 }
 
 // Validate validates the fields and sets the default values.
@@ -524,6 +646,11 @@ type AddOptions struct {
 	// Notice that when passing an ignored path it will be added anyway.
 	// When true it can speed up adding files to the worktree in very large repositories.
 	SkipStatus bool
+	// Renormalize equivalent to `git add --renormalize`, applies the
+	// end-of-line conversions again to all the tracked files under `Path`, or
+	// the entire working tree when no `Path` is given. It is useful after
+	// changing the core.autocrlf option or the text and eol attributes.
+	Renormalize bool
 }
 
 // Validate validates the fields and sets the default values.
@@ -532,6 +659,10 @@ func (o *AddOptions) Validate(r *Repository) error {
 		return fmt.Errorf("fields Path and Glob are mutual exclusive")
 	}
 
+	if o.Renormalize && (o.Glob != "" || o.All) {
+		return fmt.Errorf("field Renormalize is incompatible with Glob and All")
+	}
+
 	return nil
 }
 
@@ -655,6 +786,10 @@ type CreateTagOptions struct {
 	// SignKey denotes a key to sign the tag with. A nil value here means the tag
 	// will not be signed. The private key must be present and already decrypted.
 	SignKey *openpgp.Entity
+	// Signer denotes a cryptographic signer to sign the tag with.
+	// A nil value here means the tag will not be signed.
+	// Takes precedence over SignKey.
+	Signer Signer
 }
 
 // Validate validates the fields and sets the default values.
@@ -708,6 +843,11 @@ func (o *CreateTagOptions) loadConfigTagger(r *Repository) error {
 type ListOptions struct {
 	// Auth credentials, if required, to use with the remote repository.
 	Auth transport.AuthMethod
+	// UseCredentialHelpers, when Auth is nil, asks the credential helpers
+	// configured in credential.helper for the credentials of http remotes,
+	// as git does. The helpers that are not registered in process are run
+	// as external commands, see credential.NewExecHelper.
+	UseCredentialHelpers bool
 	// InsecureSkipTLS skips ssl verify if protocol is https
 	InsecureSkipTLS bool
 	// CABundle specify additional ca bundle with system cert pool
@@ -746,18 +886,99 @@ type CleanOptions struct {
 type GrepOptions struct {
 	// Patterns are compiled Regexp objects to be matched.
 	Patterns []*regexp.Regexp
+	// Expression is a boolean expression of patterns to be matched, like
+	// the patterns of `git grep` combined with --and, --or and --not. It
+	// cannot be used along with Patterns.
+	Expression GrepExpression
 	// InvertMatch selects non-matching lines.
 	InvertMatch bool
+	// IgnoreCase matches the patterns ignoring the case, like
+	// `git grep -i`.
+	IgnoreCase bool
+	// FixedStrings matches the GrepPattern of Expression as literal
+	// strings instead of regular expressions, like `git grep -F`.
+	FixedStrings bool
+	// WordRegexp only matches the patterns at word boundaries, like
+	// `git grep -w`.
+	WordRegexp bool
 	// CommitHash is the hash of the commit from which worktree should be derived.
 	CommitHash plumbing.Hash
 	// ReferenceName is the branch or tag name from which worktree should be derived.
 	ReferenceName plumbing.ReferenceName
+	// Revisions are the commits whose trees are searched, one after the
+	// other, the revisions being the names of their results.
+	Revisions []plumbing.Revision
+	// Cached searches the blobs of the index instead of a tree, like
+	// `git grep --cached`.
+	Cached bool
+	// Worktree searches the files of the worktree tracked in the index
+	// instead of a tree, like `git grep` without revision.
+	Worktree bool
+	// Untracked also searches the untracked files of the worktree, not
+	// ignored unless NoExcludeStandard is set, like `git grep --untracked`.
+	// It implies Worktree.
+	Untracked bool
+	// NoExcludeStandard searches the ignored untracked files too, like
+	// `git grep --no-exclude-standard`.
+	NoExcludeStandard bool
 	// PathSpecs are compiled Regexp objects of pathspec to use in the matching.
 	PathSpecs []*regexp.Regexp
+	// Paths are the pathspecs of the searched files, like the paths given
+	// to `git grep`.
+	Paths []string
+	// BeforeContext and AfterContext are the numbers of lines of context
+	// returned before and after the matching lines, like `git grep -B` and
+	// `git grep -A`.
+	BeforeContext, AfterContext int
+	// Count returns the number of matching lines of each file instead of
+	// the lines, like `git grep --count`.
+	Count bool
+	// FilesWithMatches only returns the names of the files with matching
+	// lines, like `git grep --files-with-matches`.
+	FilesWithMatches bool
+	// FilesWithoutMatch only returns the names of the files without
+	// matching lines, like `git grep --files-without-match`.
+	FilesWithoutMatch bool
+	// Binary tells how the binary files are searched. The files are binary
+	// when their diff attribute is unset, or when they contain a NUL byte
+	// and their diff attribute is not set.
+	Binary GrepBinary
+	// Threads is the number of files matched in parallel, the number of
+	// CPUs by default.
+	Threads int
 }
 
+// GrepBinary tells how grep searches the binary files.
+type GrepBinary int
+
+const (
+	// GrepBinaryMatches returns a single result telling whether the binary
+	// files match.
+	GrepBinaryMatches GrepBinary = iota
+	// GrepBinaryIgnore skips the binary files, like `git grep -I`.
+	GrepBinaryIgnore
+	// GrepBinaryText searches the binary files like text files, like
+	// `git grep --text`.
+	GrepBinaryText
+)
+
 var ErrHashOrReference = errors.New("ambiguous options, only one of CommitHash or ReferenceName can be passed")
 
+var (
+	// ErrGrepSources is returned when several of the trees, the index and
+	// the worktree are to be searched by grep.
+	ErrGrepSources = errors.New("ambiguous options, only one of the trees, the index or the worktree can be searched")
+	// ErrGrepPatterns is returned when both Patterns and Expression are
+	// given to grep.
+	ErrGrepPatterns = errors.New("ambiguous options, only one of Patterns or Expression can be passed")
+	// ErrGrepOutput is returned when several of Count, FilesWithMatches
+	// and FilesWithoutMatch are set.
+	ErrGrepOutput = errors.New("ambiguous options, only one of Count, FilesWithMatches or FilesWithoutMatch can be set")
+	// ErrGrepNegative is returned when a context or a number of threads
+	// of grep is negative.
+	ErrGrepNegative = errors.New("grep context and threads cannot be negative")
+)
+
 // Validate validates the fields and sets the default values.
 //
 // TODO: deprecate in favor of Validate(r *Repository) in v6.
@@ -770,9 +991,41 @@ func (o *GrepOptions) validate(r *Repository) error {
 		return ErrHashOrReference
 	}
 
-	// If none of CommitHash and ReferenceName are provided, set commit hash of
+	if len(o.Patterns) != 0 && o.Expression != nil {
+		return ErrGrepPatterns
+	}
+
+	n := 0
+	for _, set := range []bool{o.Count, o.FilesWithMatches, o.FilesWithoutMatch} {
+		if set {
+			n++
+		}
+	}
+
+	if n > 1 {
+		return ErrGrepOutput
+	}
+
+	if o.BeforeContext < 0 || o.AfterContext < 0 || o.Threads < 0 {
+		return ErrGrepNegative
+	}
+
+	if o.Threads == 0 {
+		o.Threads = runtime.NumCPU()
+	}
+
+	if o.Untracked {
+		o.Worktree = true
+	}
+
+	trees := !o.CommitHash.IsZero() || o.ReferenceName != "" || len(o.Revisions) != 0
+	if (trees && (o.Cached || o.Worktree)) || (o.Cached && o.Worktree) {
+		return ErrGrepSources
+	}
+
+	// If no tree, index or worktree is to be searched, set commit hash of
 	// the repository's head.
-	if o.CommitHash.IsZero() && o.ReferenceName == "" {
+	if !trees && !o.Cached && !o.Worktree {
 		ref, err := r.Head()
 		if err != nil {
 			return err
@@ -817,3 +1070,444 @@ func (o *RestoreOptions) Validate() error {
 
 	return nil
 }
+
+// ArchiveOptions describes how an archive should be written.
+type ArchiveOptions struct {
+	// Format is the format of the archive, archive.Tar by default.
+	Format archive.Format
+	// Prefix is prepended to the paths of the archive entries, like the
+	// --prefix flag of git archive. It usually ends with a slash.
+	Prefix string
+	// Paths limits the archive to the files at or under the given paths,
+	// relative to the archived tree.
+	Paths []string
+	// ExtraFiles are added to the archive after the files of the tree.
+	ExtraFiles []ArchiveFile
+	// ModTime is the modification time of the entries. It defaults to the
+	// committer time of the archived commit, or to the current time when a
+	// tree is archived.
+	ModTime time.Time
+	// CompressionLevel is the level of compression of the zip and tar.gz
+	// formats, from 1 (best speed) to 9 (best compression). Zero uses the
+	// default level and a negative value stores the files uncompressed.
+	CompressionLevel int
+}
+
+// ArchiveFile is a file added to an archive, see ArchiveOptions.
+type ArchiveFile struct {
+	// Name is the path of the file, under the prefix of the archive.
+	Name string
+	// Mode is the mode of the file, filemode.Regular by default.
+	Mode filemode.FileMode
+	// Content is the content of the file.
+	Content []byte
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ArchiveOptions) Validate() error {
+	switch o.Format {
+	case "":
+		o.Format = archive.Tar
+	case archive.Tar, archive.TarGzip, archive.Zip:
+	default:
+		return fmt.Errorf("%w: %s", archive.ErrUnknownFormat, o.Format)
+	}
+
+	if o.CompressionLevel > flate.BestCompression {
+		return fmt.Errorf("invalid compression level: %d", o.CompressionLevel)
+	}
+
+	return nil
+}
+
+// FormatPatchOptions describes how the patches of a series of commits should
+// be formatted.
+type FormatPatchOptions struct {
+	// Head is the last commit of the series, HEAD by default.
+	Head plumbing.Hash
+	// Upstream excludes the commits reachable from it from the series, like
+	// the <since> argument of git format-patch.
+	Upstream plumbing.Hash
+	// MaxCount limits the series to the given number of commits, the most
+	// recent ones being kept. When Upstream is not set it defaults to 1.
+	MaxCount int
+	// Numbered numbers the patches even if there is only one of them. A
+	// series of several patches is always numbered.
+	Numbered bool
+	// SubjectPrefix is written in the brackets of the subjects, "PATCH" by
+	// default.
+	SubjectPrefix string
+	// CoverLetter writes a cover letter before the patches, with a shortlog
+	// and the diffstat of the whole series.
+	CoverLetter bool
+	// Sender is the author of the cover letter. It defaults to the
+	// committer of the configuration, with the current time.
+	Sender *object.Signature
+	// Base is the commit the series applies to, written as a base-commit
+	// trailer. It must be the parent of the first patch of the series.
+	Base plumbing.Hash
+	// Signature is written at the end of every message. git writes its
+	// version there by default, nothing is written when it is empty.
+	Signature string
+	// LineDiff are the options of the line diffs of the patches, like the
+	// diff algorithm. They default to the diff.algorithm and
+	// diff.indentHeuristic config options.
+	LineDiff *diff.Options
+	// NoRenames disables the detection of the renames, which are otherwise
+	// written as such, like git format-patch --no-renames.
+	NoRenames bool
+	// RenameScore is the minimum similarity of the renames, from 0 to 100.
+	// It defaults to the one of object.DefaultDiffTreeOptions.
+	RenameScore uint
+	// RenameLimit is the maximum number of files compared when detecting
+	// the renames by their content. It defaults to the diff.renameLimit
+	// config option, no limit when unset.
+	RenameLimit uint
+}
+
+// Validate validates the fields and sets the default values.
+func (o *FormatPatchOptions) Validate(r *Repository) error {
+	if o.Head.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.Head = head.Hash()
+	}
+
+	if o.MaxCount < 0 {
+		return fmt.Errorf("invalid max count: %d", o.MaxCount)
+	}
+
+	if o.MaxCount == 0 && o.Upstream.IsZero() {
+		o.MaxCount = 1
+	}
+
+	if o.RenameScore > 100 {
+		return ErrDiffRenameScore
+	}
+
+	if o.RenameScore == 0 {
+		o.RenameScore = object.DefaultDiffTreeOptions.RenameScore
+	}
+
+	if o.SubjectPrefix == "" {
+		o.SubjectPrefix = "PATCH"
+	}
+
+	if o.LineDiff == nil {
+		var err error
+		if o.LineDiff, err = r.lineDiffOptions(); err != nil {
+			return err
+		}
+	}
+
+	if o.CoverLetter && o.Sender == nil {
+		cfg, err := r.ConfigScoped(config.SystemScope)
+		if err != nil {
+			return err
+		}
+
+		name, email := cfg.Committer.Name, cfg.Committer.Email
+		if name == "" || email == "" {
+			name, email = cfg.User.Name, cfg.User.Email
+		}
+
+		if name == "" || email == "" {
+			return ErrMissingAuthor
+		}
+
+		o.Sender = &object.Signature{Name: name, Email: email, When: time.Now()}
+	}
+
+	if o.RenameLimit == 0 && !o.NoRenames {
+		cfg, err := r.ConfigScoped(config.SystemScope)
+		if err != nil {
+			return err
+		}
+
+		o.RenameLimit = cfg.Diff.RenameLimit
+	}
+
+	return nil
+}
+
+// DiffOptions describes how Worktree.Diff should compare the worktree, the
+// index and the trees.
+type DiffOptions struct {
+	// Cached compares the index instead of the worktree, with the tree of
+	// HEAD by default, like `git diff --cached`.
+	Cached bool
+	// Tree is compared with the worktree, or with the index when Cached,
+	// instead of the index or the tree of HEAD, like `git diff <tree-ish>`.
+	Tree *object.Tree
+	// Paths limits the diff to the files matching the pathspecs: the files
+	// at or under the given paths, or matching them as glob patterns whose
+	// wildcards also match slashes. The pathspecs starting with ":!" or ":^"
+	// exclude the files they match instead.
+	Paths []string
+	// DetectRenames detects the renames among the files of the diff.
+	DetectRenames bool
+	// RenameScore is the minimum similarity of the renames and the copies,
+	// from 0 to 100. It defaults to the one of object.DefaultDiffTreeOptions.
+	RenameScore uint
+	// RenameLimit is the maximum number of files compared when detecting
+	// the renames and the copies by their content. It defaults to the
+	// diff.renameLimit config option, no limit when unset.
+	RenameLimit uint
+	// DetectCopies detects the copies of the deleted and modified files
+	// among the files of the diff, and the renames, like `git diff -C`.
+	DetectCopies bool
+	// FindCopiesHarder also detects the copies of the unmodified files,
+	// like `git diff --find-copies-harder`.
+	FindCopiesHarder bool
+	// BreakRewrites breaks the modifications rewriting most of a file, so
+	// they can be the sources of the renames and copies, and shows them as
+	// rewrites, like `git diff -B`. See object.DiffTreeOptions.
+	BreakRewrites bool
+	// BreakScore and RewriteScore are the dissimilarities, from 0 to 100,
+	// above which the modifications are broken and from which they are
+	// rewrites, the n and m of `git diff -B<n>/<m>`. They default to 50
+	// and 60.
+	BreakScore, RewriteScore uint
+	// LineDiff are the options of the line diffs of the patches, like the
+	// diff algorithm. They default to the diff.algorithm and
+	// diff.indentHeuristic config options.
+	LineDiff *diff.Options
+}
+
+var (
+	// ErrDiffRenameScore is returned when the rename score of the
+	// DiffOptions is above 100.
+	ErrDiffRenameScore = errors.New("rename score must be at most 100")
+	// ErrDiffBreakScore is returned when the break or rewrite score of the
+	// DiffOptions is above 100.
+	ErrDiffBreakScore = errors.New("break and rewrite scores must be at most 100")
+)
+
+// Validate validates the fields and sets the default values.
+func (o *DiffOptions) Validate(r *Repository) error {
+	if o.RenameScore > 100 {
+		return ErrDiffRenameScore
+	}
+
+	if o.BreakScore > 100 || o.RewriteScore > 100 {
+		return ErrDiffBreakScore
+	}
+
+	if o.RenameScore == 0 {
+		o.RenameScore = object.DefaultDiffTreeOptions.RenameScore
+	}
+
+	if o.LineDiff == nil {
+		var err error
+		if o.LineDiff, err = r.lineDiffOptions(); err != nil {
+			return err
+		}
+	}
+
+	if o.RenameLimit == 0 && (o.DetectRenames || o.DetectCopies || o.BreakRewrites) {
+		cfg, err := r.ConfigScoped(config.SystemScope)
+		if err != nil {
+			return err
+		}
+
+		o.RenameLimit = cfg.Diff.RenameLimit
+	}
+
+	return nil
+}
+
+var (
+	// ErrApplyRejectThreeWay is returned when both Reject and ThreeWay are
+	// set in ApplyOptions.
+	ErrApplyRejectThreeWay = errors.New("reject and three-way cannot be used together")
+	// ErrApplyFuzz is returned when the fuzz of the ApplyOptions is
+	// negative.
+	ErrApplyFuzz = errors.New("fuzz must not be negative")
+)
+
+// ApplyOptions describes how Worktree.Apply applies a patch.
+type ApplyOptions struct {
+	// Cached applies the patch to the index only, the worktree being left
+	// untouched, like `git apply --cached`.
+	Cached bool
+	// Index applies the patch to both the worktree and the index, whose
+	// files must match, like `git apply --index`. By default, only the
+	// worktree is patched.
+	Index bool
+	// ThreeWay falls back to a three-way merge when the patch does not
+	// apply, from the blobs of its index lines, like `git apply --3way`.
+	// The conflicts are left in the worktree and the index. It implies
+	// Index unless Cached is set.
+	ThreeWay bool
+	// Reverse applies the patch in reverse, like `git apply --reverse`.
+	Reverse bool
+	// Check only checks whether the patch applies, nothing being written,
+	// like `git apply --check`.
+	Check bool
+	// Reject applies the hunks that apply, the files being patched even if
+	// some of their hunks do not apply, like `git apply --reject`. The
+	// hunks that do not apply are still reported by the error.
+	Reject bool
+	// Fuzz is the maximum number of lines of context ignored at the start
+	// and at the end of the hunks that do not apply otherwise.
+	Fuzz int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *ApplyOptions) Validate() error {
+	if o.Reject && o.ThreeWay {
+		return ErrApplyRejectThreeWay
+	}
+
+	if o.Fuzz < 0 {
+		return ErrApplyFuzz
+	}
+
+	if o.ThreeWay && !o.Cached {
+		o.Index = true
+	}
+
+	return nil
+}
+
+// AmOptions describes how Worktree.Am applies the patches of a mailbox.
+type AmOptions struct {
+	// ThreeWay falls back to a three-way merge when a patch does not
+	// apply, like `git am --3way`.
+	ThreeWay bool
+	// Committer is the committer of the commits, taken from the config by
+	// default, or the author of each patch without identity in the config.
+	Committer *object.Signature
+}
+
+// Validate validates the fields and sets the default values.
+func (o *AmOptions) Validate(r *Repository) error {
+	if o.Committer != nil {
+		return nil
+	}
+
+	co := &CommitOptions{}
+	err := co.loadConfigAuthorAndCommitter(r)
+	if err != nil && err != ErrMissingAuthor {
+		return err
+	}
+
+	o.Committer = co.Committer
+	if o.Committer == nil {
+		o.Committer = co.Author
+	}
+
+	return nil
+}
+
+// BlameCopies tells where Repository.Blame looks for the lines copied from
+// other files.
+type BlameCopies int
+
+const (
+	// NoBlameCopies does not detect the copied lines.
+	NoBlameCopies BlameCopies = iota
+	// BlameCopiesFromModifiedFiles detects the lines copied from the files
+	// modified by the same commit, like `git blame -C`.
+	BlameCopiesFromModifiedFiles
+	// BlameCopiesOnCreation also detects the lines copied from any file
+	// when the file is created, like `git blame -C -C`.
+	BlameCopiesOnCreation
+	// BlameCopiesFromAnyFile detects the lines copied from any file of the
+	// parent commits, like `git blame -C -C -C`.
+	BlameCopiesFromAnyFile
+)
+
+const (
+	// DefaultBlameMoveScore is the default score of BlameOptions.MoveScore.
+	DefaultBlameMoveScore = 20
+	// DefaultBlameCopyScore is the default score of BlameOptions.CopyScore.
+	DefaultBlameCopyScore = 40
+)
+
+var (
+	// ErrBlameInvalidRev is returned when an ignored revision listed in a
+	// file is not a full hash.
+	ErrBlameInvalidRev = errors.New("invalid object name")
+	// ErrBlameScore is returned when a score of BlameOptions is negative.
+	ErrBlameScore = errors.New("score must not be negative")
+)
+
+// BlameOptions describes how Repository.Blame blames the lines of a file.
+type BlameOptions struct {
+	// Lines restricts the blamed lines to ranges, each one given like with
+	// `git blame -L`: "<start>,<end>", where start and end are line
+	// numbers from 1, /<regex>/ patterns, or an end of +<n> or -<n> lines,
+	// or ":<funcname>", the function whose line matches the regex.
+	Lines []string
+	// DetectMoves detects the lines moved within the file, like
+	// `git blame -M`.
+	DetectMoves bool
+	// DetectCopies detects the lines copied from other files, like
+	// `git blame -C`. It implies DetectMoves.
+	DetectCopies BlameCopies
+	// MoveScore is the score a group of lines must exceed to be detected
+	// as moved, 1 plus their number of alphanumeric characters.
+	// DefaultBlameMoveScore by default.
+	MoveScore int
+	// CopyScore is the score a group of lines must exceed to be detected
+	// as copied, 1 plus their number of alphanumeric characters.
+	// DefaultBlameCopyScore by default.
+	CopyScore int
+	// IgnoreRevs are the commits whose changes are ignored, like
+	// `git blame --ignore-rev`: their lines are blamed on the previous
+	// commits that changed similar lines.
+	IgnoreRevs []plumbing.Hash
+	// IgnoreRevsFiles are the files of the worktree listing the ignored
+	// commits, like `git blame --ignore-revs-file`. When nil, the files of
+	// blame.ignoreRevsFile are used.
+	IgnoreRevsFiles []string
+	// Reverse walks the history forward, like `git blame --reverse`: the
+	// lines of the file of the blamed commit are blamed on the last commit
+	// where they existed, up to End.
+	Reverse bool
+	// End is the last commit of a reverse blame, HEAD by default.
+	End plumbing.Hash
+}
+
+// Validate validates the fields and sets the default values.
+func (o *BlameOptions) Validate(r *Repository) error {
+	if o.MoveScore < 0 || o.CopyScore < 0 {
+		return ErrBlameScore
+	}
+
+	if o.MoveScore == 0 {
+		o.MoveScore = DefaultBlameMoveScore
+	}
+
+	if o.CopyScore == 0 {
+		o.CopyScore = DefaultBlameCopyScore
+	}
+
+	if o.DetectCopies != NoBlameCopies {
+		o.DetectMoves = true
+	}
+
+	if o.IgnoreRevsFiles == nil {
+		cfg, err := r.ConfigScoped(config.SystemScope)
+		if err != nil {
+			return err
+		}
+
+		o.IgnoreRevsFiles = cfg.Blame.IgnoreRevsFiles
+	}
+
+	if o.Reverse && o.End.IsZero() {
+		head, err := r.Head()
+		if err != nil {
+			return err
+		}
+
+		o.End = head.Hash()
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package git
+
+import (
+	"regexp"
+	"strings"
+)
+
+// pathspec matches paths against a list of pathspecs, like git does: a
+// pathspec matches the path it names, the files under it when it is a
+// directory, and the paths it matches as a glob pattern, whose wildcards
+// also match slashes. The pathspecs starting with ":!", ":^" or
+// ":(exclude)" exclude the paths they match. An empty list matches every
+// path.
+type pathspec struct {
+	include, exclude []pathspecItem
+}
+
+type pathspecItem struct {
+	path string
+	// glob is the glob pattern of the pathspec, nil when it has no
+	// wildcard.
+	glob *regexp.Regexp
+}
+
+func newPathspec(specs []string) *pathspec {
+	p := &pathspec{}
+	for _, spec := range specs {
+		exclude := false
+		for _, prefix := range []string{":!", ":^", ":(exclude)"} {
+			if rest, ok := strings.CutPrefix(spec, prefix); ok {
+				spec, exclude = rest, true
+				break
+			}
+		}
+
+		item := pathspecItem{path: strings.Trim(spec, "/")}
+		if item.path == "." {
+			item.path = ""
+		}
+
+		if strings.ContainsAny(item.path, "*?[") {
+			item.glob = compileGlob(item.path)
+		}
+
+		if exclude {
+			p.exclude = append(p.exclude, item)
+		} else {
+			p.include = append(p.include, item)
+		}
+	}
+
+	// Only excluding pathspecs exclude from all the paths.
+	if len(p.include) == 0 && len(p.exclude) != 0 {
+		p.include = []pathspecItem{{}}
+	}
+
+	return p
+}
+
+// match reports whether the path matches the pathspecs.
+func (p *pathspec) match(path string) bool {
+	if len(p.include) == 0 {
+		return true
+	}
+
+	return matchPathspecItems(p.include, path) && !matchPathspecItems(p.exclude, path)
+}
+
+func matchPathspecItems(items []pathspecItem, path string) bool {
+	for _, item := range items {
+		if item.match(path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i pathspecItem) match(path string) bool {
+	if i.path == "" || path == i.path || strings.HasPrefix(path, i.path+"/") {
+		return true
+	}
+
+	return i.glob != nil && i.glob.MatchString(path)
+}
+
+// compileGlob compiles the glob pattern, its `*` and `?` matching slashes.
+func compileGlob(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case c == '*':
+			b.WriteString(".*")
+		case c == '?':
+			b.WriteString(".")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				break
+			}
+
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+
+	return re
+}
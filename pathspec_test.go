@@ -0,0 +1,35 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathspecMatch(t *testing.T) {
+	for _, tc := range []struct {
+		specs []string
+		path  string
+		want  bool
+	}{
+		{nil, "a/b.txt", true},
+		{[]string{"."}, "a/b.txt", true},
+		{[]string{"a"}, "a/b.txt", true},
+		{[]string{"a/"}, "a/b.txt", true},
+		{[]string{"a"}, "ab.txt", false},
+		{[]string{"a/b.txt"}, "a/b.txt", true},
+		{[]string{"*.txt"}, "a/b.txt", true},
+		{[]string{"a/*.go"}, "a/b.txt", false},
+		{[]string{"?/b.txt"}, "a/b.txt", true},
+		{[]string{"[ab]/b.txt"}, "a/b.txt", true},
+		{[]string{"[!ab]/b.txt"}, "a/b.txt", false},
+		{[]string{`a/b\*`}, "a/b*", true},
+		{[]string{`a/b\*`}, "a/bc", false},
+		{[]string{":!a"}, "a/b.txt", false},
+		{[]string{":^a"}, "c.txt", true},
+		{[]string{"a", ":(exclude)*.txt"}, "a/b.txt", false},
+		{[]string{"a", ":(exclude)*.txt"}, "a/b.go", true},
+	} {
+		assert.Equal(t, tc.want, newPathspec(tc.specs).match(tc.path), "%v %s", tc.specs, tc.path)
+	}
+}
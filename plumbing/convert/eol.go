@@ -0,0 +1,438 @@
+// Package convert implements the content conversions git applies to files
+// on their way between the working tree and the object database, driven by
+// gitattributes and the core.autocrlf, core.eol and core.safecrlf options.
+//
+// See https://git-scm.com/docs/gitattributes#_checking_out_and_checking_in
+// and git's convert.c, which this package follows closely.
+package convert
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+)
+
+// Attribute names consulted for end-of-line conversion.
+const (
+	TextAttr = "text"
+	EOLAttr  = "eol"
+	CRLFAttr = "crlf"
+)
+
+// EOLAttributes are the attributes needed by Config.Action.
+var EOLAttributes = []string{TextAttr, EOLAttr, CRLFAttr}
+
+// AutoCRLF is the value of the core.autocrlf option.
+type AutoCRLF int8
+
+const (
+	AutoCRLFFalse AutoCRLF = iota
+	AutoCRLFTrue
+	AutoCRLFInput
+)
+
+// ParseAutoCRLF parses a core.autocrlf value, defaulting to AutoCRLFFalse.
+func ParseAutoCRLF(s string) AutoCRLF {
+	switch strings.ToLower(s) {
+	case "input":
+		return AutoCRLFInput
+	case "true", "yes", "on", "1":
+		return AutoCRLFTrue
+	}
+	return AutoCRLFFalse
+}
+
+// EOL is a line ending style.
+type EOL int8
+
+const (
+	// EOLUnset means no conversion is applied.
+	EOLUnset EOL = iota
+	EOLLF
+	EOLCRLF
+	// EOLNative is CRLF on Windows and LF everywhere else.
+	EOLNative
+)
+
+// ParseEOL parses a core.eol value, defaulting to EOLNative.
+func ParseEOL(s string) EOL {
+	switch strings.ToLower(s) {
+	case "lf":
+		return EOLLF
+	case "crlf":
+		return EOLCRLF
+	}
+	return EOLNative
+}
+
+// SafeCRLF is the value of the core.safecrlf option.
+type SafeCRLF int8
+
+const (
+	// SafeCRLFWarn reports irreversible conversions, the default.
+	SafeCRLFWarn SafeCRLF = iota
+	SafeCRLFFalse
+	// SafeCRLFTrue rejects irreversible conversions with an error.
+	SafeCRLFTrue
+)
+
+// ParseSafeCRLF parses a core.safecrlf value, defaulting to SafeCRLFWarn.
+func ParseSafeCRLF(s string) SafeCRLF {
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return SafeCRLFTrue
+	case "false", "no", "off", "0":
+		return SafeCRLFFalse
+	}
+	return SafeCRLFWarn
+}
+
+// CRLFAction is the end-of-line conversion applied to a path.
+type CRLFAction int8
+
+const (
+	CRLFUndefined CRLFAction = iota
+	// CRLFBinary disables any conversion.
+	CRLFBinary
+	// CRLFText normalizes to LF and checks out with the configured EOL.
+	CRLFText
+	// CRLFTextInput normalizes to LF and checks out with LF.
+	CRLFTextInput
+	// CRLFTextCRLF normalizes to LF and checks out with CRLF.
+	CRLFTextCRLF
+	// CRLFAuto is CRLFText for files detected as text.
+	CRLFAuto
+	// CRLFAutoInput is CRLFTextInput for files detected as text.
+	CRLFAutoInput
+	// CRLFAutoCRLF is CRLFTextCRLF for files detected as text.
+	CRLFAutoCRLF
+)
+
+func (a CRLFAction) isAuto() bool {
+	return a == CRLFAuto || a == CRLFAutoInput || a == CRLFAutoCRLF
+}
+
+// Stats holds the character statistics git uses to tell text from binary
+// content and to decide on line ending conversions.
+type Stats struct {
+	NUL          int
+	LoneCR       int
+	LoneLF       int
+	CRLF         int
+	Printable    int
+	NonPrintable int
+}
+
+// GatherStats computes the Stats of b.
+func GatherStats(b []byte) Stats {
+	var s Stats
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == '\r':
+			if i+1 < len(b) && b[i+1] == '\n' {
+				s.CRLF++
+				i++
+			} else {
+				s.LoneCR++
+			}
+		case c == '\n':
+			s.LoneLF++
+		case c == 127:
+			s.NonPrintable++
+		case c < 32:
+			switch c {
+			case '\b', '\t', '\033', '\014':
+				s.Printable++
+			case 0:
+				s.NUL++
+				s.NonPrintable++
+			default:
+				s.NonPrintable++
+			}
+		default:
+			s.Printable++
+		}
+	}
+
+	// A trailing EOF (^Z) is not counted as non-printable.
+	if len(b) > 0 && b[len(b)-1] == '\032' {
+		s.NonPrintable--
+	}
+
+	return s
+}
+
+// IsBinary reports whether the content is considered binary by git.
+func (s Stats) IsBinary() bool {
+	return s.LoneCR > 0 || s.NUL > 0 || (s.Printable>>7) < s.NonPrintable
+}
+
+// SafeCRLFError reports a conversion that would not survive a round trip
+// between the working tree and the object database.
+type SafeCRLFError struct {
+	Path string
+	// From and To are the line endings before and after the round trip.
+	From, To string
+}
+
+func (e *SafeCRLFError) Error() string {
+	return fmt.Sprintf("in the working copy of '%s', %s will be replaced by %s the next time Git touches it",
+		e.Path, e.From, e.To)
+}
+
+// Config holds the end-of-line configuration of a repository.
+type Config struct {
+	AutoCRLF AutoCRLF
+	EOL      EOL
+	SafeCRLF SafeCRLF
+	// Warn receives the round trip warnings when SafeCRLF is SafeCRLFWarn.
+	// They are discarded when nil.
+	Warn func(error)
+}
+
+// NewConfig returns the Config for the given option values, as found in
+// the core section of the git config.
+func NewConfig(autocrlf, eol, safecrlf string) Config {
+	return Config{
+		AutoCRLF: ParseAutoCRLF(autocrlf),
+		EOL:      ParseEOL(eol),
+		SafeCRLF: ParseSafeCRLF(safecrlf),
+	}
+}
+
+// TextEOLIsCRLF reports whether text files are checked out with CRLF when
+// no eol attribute applies.
+func (c *Config) TextEOLIsCRLF() bool {
+	switch c.AutoCRLF {
+	case AutoCRLFTrue:
+		return true
+	case AutoCRLFInput:
+		return false
+	}
+
+	switch c.EOL {
+	case EOLCRLF:
+		return true
+	case EOLNative:
+		return runtime.GOOS == "windows"
+	}
+
+	return false
+}
+
+func attrAction(a gitattributes.Attribute) CRLFAction {
+	switch {
+	case a == nil:
+		return CRLFUndefined
+	case a.IsSet():
+		return CRLFText
+	case a.IsUnset():
+		return CRLFBinary
+	case a.IsValueSet() && a.Value() == "input":
+		return CRLFTextInput
+	case a.IsValueSet() && a.Value() == "auto":
+		return CRLFAuto
+	}
+	return CRLFUndefined
+}
+
+// Action returns the conversion for a path given its text, eol and crlf
+// attributes, as returned by a gitattributes.Matcher.
+func (c *Config) Action(attrs map[string]gitattributes.Attribute) CRLFAction {
+	action := attrAction(attrs[TextAttr])
+	if action == CRLFUndefined {
+		action = attrAction(attrs[CRLFAttr])
+	}
+
+	if action != CRLFBinary {
+		var eol EOL
+		if a := attrs[EOLAttr]; a != nil && a.IsValueSet() {
+			switch a.Value() {
+			case "lf":
+				eol = EOLLF
+			case "crlf":
+				eol = EOLCRLF
+			}
+		}
+
+		switch {
+		case action == CRLFAuto && eol == EOLLF:
+			action = CRLFAutoInput
+		case action == CRLFAuto && eol == EOLCRLF:
+			action = CRLFAutoCRLF
+		case eol == EOLLF:
+			action = CRLFTextInput
+		case eol == EOLCRLF:
+			action = CRLFTextCRLF
+		}
+	}
+
+	if action == CRLFText {
+		if c.TextEOLIsCRLF() {
+			action = CRLFTextCRLF
+		} else {
+			action = CRLFTextInput
+		}
+	}
+
+	if action == CRLFUndefined {
+		switch c.AutoCRLF {
+		case AutoCRLFTrue:
+			action = CRLFAutoCRLF
+		case AutoCRLFInput:
+			action = CRLFAutoInput
+		default:
+			action = CRLFBinary
+		}
+	}
+
+	return action
+}
+
+// OutputEOL returns the line ending used when checking out with action.
+func (c *Config) OutputEOL(action CRLFAction) EOL {
+	switch action {
+	case CRLFBinary:
+		return EOLUnset
+	case CRLFTextCRLF, CRLFAutoCRLF:
+		return EOLCRLF
+	case CRLFTextInput, CRLFAutoInput:
+		return EOLLF
+	case CRLFText, CRLFAuto:
+		if c.TextEOLIsCRLF() {
+			return EOLCRLF
+		}
+		return EOLLF
+	}
+	return EOLUnset
+}
+
+func (c *Config) willConvertLFToCRLF(s Stats, action CRLFAction) bool {
+	if c.OutputEOL(action) != EOLCRLF || s.LoneLF == 0 {
+		return false
+	}
+
+	if action.isAuto() {
+		// Files with any CR or CRLF are left untouched, as is binary
+		// content.
+		if s.LoneCR > 0 || s.CRLF > 0 || s.IsBinary() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ToGit converts the content of a working tree file to the content stored
+// in the object database, replacing CRLF with LF. indexHasCRLF tells
+// whether the version of the file in the index has CRLF line endings, in
+// which case files with automatic conversion are left untouched, unless
+// renormalize is set, as `git add --renormalize` does.
+//
+// The returned slice is src itself when no conversion is needed. An error
+// is only returned when SafeCRLF is SafeCRLFTrue and the conversion would
+// not survive a round trip.
+func (c *Config) ToGit(path string, src []byte, action CRLFAction, indexHasCRLF, renormalize bool) ([]byte, error) {
+	if action == CRLFBinary || len(src) == 0 {
+		return src, nil
+	}
+
+	s := GatherStats(src)
+	convert := s.CRLF > 0
+
+	if action.isAuto() {
+		if s.IsBinary() {
+			return src, nil
+		}
+
+		if !renormalize && indexHasCRLF {
+			convert = false
+		}
+	}
+
+	if c.SafeCRLF != SafeCRLFFalse {
+		if err := c.checkRoundTrip(path, s, action, convert); err != nil {
+			return nil, err
+		}
+	}
+
+	if !convert {
+		return src, nil
+	}
+
+	return bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n")), nil
+}
+
+func (c *Config) checkRoundTrip(path string, s Stats, action CRLFAction, convert bool) error {
+	// Simulate git add followed by git checkout.
+	n := s
+	if convert {
+		n.LoneLF += n.CRLF
+		n.CRLF = 0
+	}
+	if c.willConvertLFToCRLF(n, action) {
+		n.CRLF += n.LoneLF
+		n.LoneLF = 0
+	}
+
+	var err *SafeCRLFError
+	switch {
+	case s.CRLF > 0 && n.CRLF == 0:
+		err = &SafeCRLFError{Path: path, From: "CRLF", To: "LF"}
+	case s.LoneLF > 0 && n.LoneLF == 0:
+		err = &SafeCRLFError{Path: path, From: "LF", To: "CRLF"}
+	default:
+		return nil
+	}
+
+	if c.SafeCRLF == SafeCRLFTrue {
+		return err
+	}
+
+	if c.Warn != nil {
+		c.Warn(err)
+	}
+
+	return nil
+}
+
+// ToWorktree converts content from the object database to the content
+// written to the working tree, replacing lone LF with CRLF when the output
+// line ending is CRLF. The returned slice is src itself when no conversion
+// is needed.
+func (c *Config) ToWorktree(src []byte, action CRLFAction) []byte {
+	if action == CRLFBinary || len(src) == 0 {
+		return src
+	}
+
+	s := GatherStats(src)
+	if !c.willConvertLFToCRLF(s, action) {
+		return src
+	}
+
+	dst := make([]byte, 0, len(src)+s.LoneLF)
+	for i, b := range src {
+		if b == '\n' && (i == 0 || src[i-1] != '\r') {
+			dst = append(dst, '\r')
+		}
+		dst = append(dst, b)
+	}
+
+	return dst
+}
+
+// HasCRLF reports whether b is text content with CRLF line endings. It is
+// used on the index version of a file to decide whether automatic
+// conversion applies.
+func HasCRLF(b []byte) bool {
+	if bytes.IndexByte(b, '\r') < 0 {
+		return false
+	}
+
+	s := GatherStats(b)
+	return s.CRLF > 0 && !s.IsBinary()
+}
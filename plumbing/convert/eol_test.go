@@ -0,0 +1,122 @@
+package convert
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+)
+
+func attributes(t *testing.T, line string) map[string]gitattributes.Attribute {
+	t.Helper()
+
+	ma, err := gitattributes.ReadAttributes(strings.NewReader(line), nil, true)
+	require.NoError(t, err)
+
+	m := gitattributes.NewMatcher(ma)
+	attrs, _ := m.Match([]string{"file"}, EOLAttributes)
+	return attrs
+}
+
+func TestGatherStats(t *testing.T) {
+	s := GatherStats([]byte("a\r\nb\nc\rd\x00"))
+	assert.Equal(t, Stats{NUL: 1, LoneCR: 1, LoneLF: 1, CRLF: 1, Printable: 4, NonPrintable: 1}, s)
+	assert.True(t, s.IsBinary())
+
+	assert.False(t, GatherStats([]byte("foo\r\nbar\r\n")).IsBinary())
+	assert.False(t, GatherStats([]byte("foo\n\x1a")).IsBinary())
+}
+
+func TestAction(t *testing.T) {
+	tests := []struct {
+		autocrlf, eol string
+		line          string
+		action        CRLFAction
+	}{
+		{"false", "", "file foo", CRLFBinary},
+		{"true", "", "file foo", CRLFAutoCRLF},
+		{"input", "", "file foo", CRLFAutoInput},
+		{"true", "", "file -text", CRLFBinary},
+		{"false", "lf", "file text", CRLFTextInput},
+		{"false", "crlf", "file text", CRLFTextCRLF},
+		{"input", "crlf", "file text", CRLFTextInput},
+		{"false", "", "file text=auto", CRLFAuto},
+		{"false", "", "file text=auto eol=crlf", CRLFAutoCRLF},
+		{"false", "", "file eol=lf", CRLFTextInput},
+		{"false", "", "file crlf=input", CRLFTextInput},
+		{"true", "", "file -crlf", CRLFBinary},
+	}
+
+	for _, tc := range tests {
+		c := NewConfig(tc.autocrlf, tc.eol, "")
+		assert.Equal(t, tc.action, c.Action(attributes(t, tc.line)), "%s %s %q", tc.autocrlf, tc.eol, tc.line)
+	}
+}
+
+func TestToGit(t *testing.T) {
+	c := NewConfig("true", "", "false")
+
+	out, err := c.ToGit("file", []byte("foo\r\nbar\r\n"), CRLFAutoCRLF, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(out))
+
+	out, err = c.ToGit("file", []byte("foo\r\nbar\r\n"), CRLFAutoCRLF, true, false)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\r\nbar\r\n", string(out))
+
+	out, err = c.ToGit("file", []byte("foo\r\nbar\r\n"), CRLFAutoCRLF, true, true)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\nbar\n", string(out))
+
+	out, err = c.ToGit("file", []byte("foo\r\n\x00"), CRLFAutoCRLF, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\r\n\x00", string(out))
+
+	out, err = c.ToGit("file", []byte("foo\r\n\x00"), CRLFTextInput, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\n\x00", string(out))
+}
+
+func TestToGitSafeCRLF(t *testing.T) {
+	c := NewConfig("input", "", "true")
+
+	_, err := c.ToGit("file", []byte("foo\r\nbar\r\n"), CRLFAutoInput, false, false)
+	var serr *SafeCRLFError
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, &SafeCRLFError{Path: "file", From: "CRLF", To: "LF"}, serr)
+
+	c = NewConfig("true", "", "true")
+	_, err = c.ToGit("file", []byte("foo\r\nbar\r\n"), CRLFAutoCRLF, false, false)
+	assert.NoError(t, err)
+
+	_, err = c.ToGit("file", []byte("foo\nbar\n"), CRLFTextCRLF, false, false)
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, "LF", serr.From)
+
+	c = NewConfig("false", "lf", "")
+	var warnings []error
+	c.Warn = func(err error) { warnings = append(warnings, err) }
+	out, err := c.ToGit("file", []byte("foo\r\n"), CRLFTextInput, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, "foo\n", string(out))
+	assert.Len(t, warnings, 1)
+}
+
+func TestToWorktree(t *testing.T) {
+	c := NewConfig("true", "", "")
+	assert.Equal(t, "foo\r\nbar\r\n", string(c.ToWorktree([]byte("foo\nbar\n"), CRLFAutoCRLF)))
+	assert.Equal(t, "foo\nbar\r\n", string(c.ToWorktree([]byte("foo\nbar\r\n"), CRLFAutoCRLF)))
+	assert.Equal(t, "foo\r\nbar\r\n", string(c.ToWorktree([]byte("foo\nbar\r\n"), CRLFTextCRLF)))
+	assert.Equal(t, "foo\n\x00", string(c.ToWorktree([]byte("foo\n\x00"), CRLFAutoCRLF)))
+	assert.Equal(t, "foo\nbar\n", string(c.ToWorktree([]byte("foo\nbar\n"), CRLFAutoInput)))
+	assert.Equal(t, "foo\nbar\n", string(c.ToWorktree([]byte("foo\nbar\n"), CRLFBinary)))
+}
+
+func TestHasCRLF(t *testing.T) {
+	assert.True(t, HasCRLF([]byte("foo\r\n")))
+	assert.False(t, HasCRLF([]byte("foo\n")))
+	assert.False(t, HasCRLF([]byte("foo\r\n\x00")))
+}
@@ -0,0 +1,119 @@
+package convert
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// FilterAttr is the attribute naming the filter driver of a path.
+const FilterAttr = "filter"
+
+// ErrFilterFailed is returned when a filter driver fails to convert the
+// content of a file.
+var ErrFilterFailed = errors.New("filter failed")
+
+// Filter is a filter driver, converting the content of files as configured
+// by the filter attribute. A worktree calls its filters from several
+// goroutines at once when it hashes files in parallel, so the filters keeping
+// any state must be safe for concurrent use.
+// https://git-scm.com/docs/gitattributes#_filter
+type Filter interface {
+	// Clean converts the content of the worktree file at path to the
+	// content stored in the object database.
+	Clean(path string, content []byte) ([]byte, error)
+	// Smudge converts the content of the blob of path to the content of the
+	// worktree file.
+	Smudge(path string, content []byte) ([]byte, error)
+}
+
+// registry of the in-process filter drivers.
+var (
+	registry = map[string]Filter{}
+	mtx      sync.RWMutex
+)
+
+// RegisterFilter adds or replaces the in-process filter driver with the
+// given name. Registered drivers take precedence over the filter.<name>
+// options of the git config, without spawning any process. f must be safe
+// for concurrent use.
+func RegisterFilter(name string, f Filter) {
+	mtx.Lock()
+	registry[name] = f
+	mtx.Unlock()
+}
+
+// UnregisterFilter removes the in-process filter driver with the given name.
+func UnregisterFilter(name string) {
+	mtx.Lock()
+	delete(registry, name)
+	mtx.Unlock()
+}
+
+// LookupFilter returns the in-process filter driver with the given name.
+func LookupFilter(name string) (Filter, bool) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	f, ok := registry[name]
+	return f, ok && f != nil
+}
+
+// CommandFilter is a filter driver running one command per file, as
+// configured by filter.<driver>.clean and filter.<driver>.smudge. The content
+// is written to the standard input of the command, which writes the
+// converted content to its standard output. Occurrences of %f in the
+// commands are replaced with the quoted path of the file.
+type CommandFilter struct {
+	// CleanCommand and SmudgeCommand are run by the shell. When empty,
+	// the content is left untouched.
+	CleanCommand, SmudgeCommand string
+	// Dir is the working directory of the commands, usually the root of
+	// the worktree.
+	Dir string
+}
+
+// Clean runs the clean command.
+func (f *CommandFilter) Clean(path string, content []byte) ([]byte, error) {
+	return f.run(f.CleanCommand, path, content)
+}
+
+// Smudge runs the smudge command.
+func (f *CommandFilter) Smudge(path string, content []byte) ([]byte, error) {
+	return f.run(f.SmudgeCommand, path, content)
+}
+
+func (f *CommandFilter) run(command, path string, content []byte) ([]byte, error) {
+	if command == "" {
+		return content, nil
+	}
+
+	command = strings.ReplaceAll(command, "%f", shellQuote(path))
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = f.Dir
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+
+		return nil, fmt.Errorf("%w: %s: %s: %s", ErrFilterFailed, command, path, msg)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+var shellQuoteReplacer = strings.NewReplacer("'", `'\''`, "!", `'\!'`)
+
+// shellQuote quotes s for sh, as done by git's sq_quote_buf.
+func shellQuote(s string) string {
+	return "'" + shellQuoteReplacer.Replace(s) + "'"
+}
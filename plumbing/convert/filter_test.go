@@ -0,0 +1,205 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+func skipIfNoShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("filter commands require a POSIX shell")
+	}
+}
+
+type upperFilter struct{}
+
+func (upperFilter) Clean(_ string, content []byte) ([]byte, error) {
+	return bytes.ToUpper(content), nil
+}
+
+func (upperFilter) Smudge(_ string, content []byte) ([]byte, error) {
+	return bytes.ToLower(content), nil
+}
+
+func TestRegisterFilter(t *testing.T) {
+	RegisterFilter("upper", upperFilter{})
+	defer UnregisterFilter("upper")
+
+	f, ok := LookupFilter("upper")
+	require.True(t, ok)
+
+	out, err := f.Clean("foo", []byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, "FOO", string(out))
+
+	UnregisterFilter("upper")
+	_, ok = LookupFilter("upper")
+	assert.False(t, ok)
+}
+
+func TestCommandFilter(t *testing.T) {
+	skipIfNoShell(t)
+
+	f := &CommandFilter{
+		CleanCommand:  "tr a-z A-Z",
+		SmudgeCommand: "printf '%s:' %f; cat",
+	}
+
+	out, err := f.Clean("foo", []byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "BAR", string(out))
+
+	out, err = f.Smudge("it's!", []byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "it's!:bar", string(out))
+
+	f.CleanCommand = "echo oops >&2; exit 1"
+	_, err = f.Clean("foo", []byte("bar"))
+	assert.ErrorIs(t, err, ErrFilterFailed)
+	assert.ErrorContains(t, err, "oops")
+
+	f.CleanCommand = ""
+	out, err = f.Clean("foo", []byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(out))
+}
+
+// TestFilterProcessHelper is not a real test, it is run as a long-running
+// filter process by TestProcessFilter.
+func TestFilterProcessHelper(t *testing.T) {
+	if os.Getenv("GO_GIT_FILTER_PROCESS") != "1" {
+		t.Skip("helper process")
+	}
+
+	serveFilterProcess(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func serveFilterProcess(r io.Reader, w io.Writer) {
+	in := bufio.NewReader(r)
+	readList := func() []string {
+		var lines []string
+		for {
+			l, p, err := pktline.ReadLine(in)
+			if err != nil {
+				// git closed the standard input.
+				os.Exit(0)
+			}
+			if l == pktline.Flush {
+				return lines
+			}
+			lines = append(lines, strings.TrimSuffix(string(p), "\n"))
+		}
+	}
+	writeList := func(lines ...string) {
+		for _, l := range lines {
+			pktline.Writeln(w, l)
+		}
+		pktline.WriteFlush(w)
+	}
+
+	readList()
+	writeList("git-filter-server", "version=2")
+	readList()
+	writeList("capability=clean")
+
+	for {
+		var command, path string
+		for _, l := range readList() {
+			if v, ok := strings.CutPrefix(l, "command="); ok {
+				command = v
+			}
+			if v, ok := strings.CutPrefix(l, "pathname="); ok {
+				path = v
+			}
+		}
+
+		var content []byte
+		for {
+			l, p, _ := pktline.ReadLine(in)
+			if l == pktline.Flush {
+				break
+			}
+			content = append(content, p...)
+		}
+
+		switch path {
+		case "error", "abort":
+			writeList("status=" + path)
+			continue
+		}
+
+		writeList("status=success")
+		if command == "clean" {
+			content = bytes.ToUpper(content)
+		}
+		for len(content) > 0 {
+			n := min(len(content), pktline.MaxPayloadSize)
+			pktline.Write(w, content[:n])
+			content = content[n:]
+		}
+		pktline.WriteFlush(w)
+		writeList()
+	}
+}
+
+func TestProcessFilter(t *testing.T) {
+	skipIfNoShell(t)
+
+	f := NewProcessFilter(fmt.Sprintf("GO_GIT_FILTER_PROCESS=1 %s -test.run='^TestFilterProcessHelper$'",
+		shellQuote(os.Args[0])), "")
+	defer f.Close()
+
+	out, err := f.Clean("foo", []byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, "FOO", string(out))
+
+	large := bytes.Repeat([]byte("x"), pktline.MaxPayloadSize*2+10)
+	out, err = f.Clean("large", large)
+	require.NoError(t, err)
+	assert.Equal(t, bytes.ToUpper(large), out)
+
+	// The process lacks the smudge capability.
+	out, err = f.Smudge("foo", []byte("FOO"))
+	require.NoError(t, err)
+	assert.Equal(t, "FOO", string(out))
+
+	_, err = f.Clean("error", []byte("foo"))
+	assert.ErrorIs(t, err, ErrFilterFailed)
+
+	out, err = f.Clean("bar", []byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "BAR", string(out))
+
+	_, err = f.Clean("abort", []byte("foo"))
+	assert.ErrorIs(t, err, ErrFilterFailed)
+
+	out, err = f.Clean("bar", []byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "bar", string(out))
+
+	require.NoError(t, f.Close())
+	_, err = f.Clean("foo", []byte("foo"))
+	assert.ErrorIs(t, err, ErrFilterFailed)
+}
+
+func TestProcessFilterStartFailure(t *testing.T) {
+	skipIfNoShell(t)
+
+	f := NewProcessFilter("exit 1", "")
+	defer f.Close()
+
+	_, err := f.Clean("foo", []byte("foo"))
+	assert.ErrorIs(t, err, ErrFilterFailed)
+}
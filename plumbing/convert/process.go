@@ -0,0 +1,297 @@
+package convert
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v6/plumbing/format/pktline"
+)
+
+const (
+	cleanCapability  = "clean"
+	smudgeCapability = "smudge"
+
+	statusSuccess = "success"
+	statusAbort   = "abort"
+)
+
+// ProcessFilter is a filter driver backed by a long-running process, as
+// configured by filter.<driver>.process. A single process is started on the
+// first use and converts all the files, speaking git's long-running filter
+// process protocol over its standard input and output.
+// https://git-scm.com/docs/gitattributes#_long_running_filter_process
+//
+// A ProcessFilter is safe for concurrent use. Close must be called to stop
+// the process.
+type ProcessFilter struct {
+	// Command is run by the shell to start the process.
+	Command string
+	// Dir is the working directory of the process, usually the root of the
+	// worktree.
+	Dir string
+
+	mu   sync.Mutex
+	cmd  *exec.Cmd
+	in   *bufio.Writer
+	inc  io.Closer
+	out  *bufio.Reader
+	caps map[string]bool
+	err  error
+}
+
+// NewProcessFilter returns a ProcessFilter running command in dir.
+func NewProcessFilter(command, dir string) *ProcessFilter {
+	return &ProcessFilter{Command: command, Dir: dir}
+}
+
+// Clean sends the content to the process with the clean command. The content
+// is returned untouched if the process lacks the clean capability.
+func (f *ProcessFilter) Clean(path string, content []byte) ([]byte, error) {
+	return f.filter(cleanCapability, path, content)
+}
+
+// Smudge sends the content to the process with the smudge command. The
+// content is returned untouched if the process lacks the smudge capability.
+func (f *ProcessFilter) Smudge(path string, content []byte) ([]byte, error) {
+	return f.filter(smudgeCapability, path, content)
+}
+
+// Close stops the process, closing its standard input and waiting for it to
+// exit.
+func (f *ProcessFilter) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cmd == nil {
+		return nil
+	}
+
+	err := f.inc.Close()
+	if werr := f.cmd.Wait(); err == nil {
+		err = werr
+	}
+
+	f.cmd = nil
+	if f.err == nil {
+		f.err = fmt.Errorf("%w: %s: process closed", ErrFilterFailed, f.Command)
+	}
+
+	return err
+}
+
+func (f *ProcessFilter) filter(command, path string, content []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cmd == nil && f.err == nil {
+		f.err = f.start()
+	}
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if !f.caps[command] {
+		return content, nil
+	}
+
+	result, status, err := f.request(command, path, content)
+	if err != nil {
+		// The process is in an unknown state, so it is not used anymore.
+		f.err = fmt.Errorf("%w: %s: %w", ErrFilterFailed, f.Command, err)
+		return nil, f.err
+	}
+
+	switch status {
+	case statusSuccess:
+		return result, nil
+	case statusAbort:
+		// The process does not want to handle the command anymore.
+		delete(f.caps, command)
+	}
+
+	return nil, fmt.Errorf("%w: %s: %s: status=%s", ErrFilterFailed, f.Command, path, status)
+}
+
+func (f *ProcessFilter) start() error {
+	cmd := exec.Command("sh", "-c", f.Command)
+	cmd.Dir = f.Dir
+
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrFilterFailed, f.Command, err)
+	}
+
+	f.cmd, f.inc = cmd, in
+	f.in = bufio.NewWriter(in)
+	f.out = bufio.NewReader(out)
+
+	if err := f.handshake(); err != nil {
+		_ = in.Close()
+		_ = cmd.Wait()
+		f.cmd = nil
+		return fmt.Errorf("%w: %s: %w", ErrFilterFailed, f.Command, err)
+	}
+
+	return nil
+}
+
+func (f *ProcessFilter) handshake() error {
+	if err := f.writeList("git-filter-client", "version=2"); err != nil {
+		return err
+	}
+
+	lines, err := f.readList()
+	if err != nil {
+		return err
+	}
+
+	if len(lines) == 0 || lines[0] != "git-filter-server" {
+		return errors.New("invalid filter process welcome message")
+	}
+
+	if !slices.Contains(lines[1:], "version=2") {
+		return errors.New("unsupported filter process protocol version")
+	}
+
+	err = f.writeList("capability="+cleanCapability, "capability="+smudgeCapability)
+	if err != nil {
+		return err
+	}
+
+	lines, err = f.readList()
+	if err != nil {
+		return err
+	}
+
+	f.caps = make(map[string]bool)
+	for _, l := range lines {
+		if c, ok := strings.CutPrefix(l, "capability="); ok {
+			f.caps[c] = true
+		}
+	}
+
+	return nil
+}
+
+// request sends a command with the content of path and returns the filtered
+// content and the status reported by the process.
+func (f *ProcessFilter) request(command, path string, content []byte) ([]byte, string, error) {
+	if err := f.writeList("command="+command, "pathname="+path); err != nil {
+		return nil, "", err
+	}
+
+	if err := f.writeContent(content); err != nil {
+		return nil, "", err
+	}
+
+	status, err := f.readStatus(statusSuccess)
+	if err != nil || status != statusSuccess {
+		return nil, status, err
+	}
+
+	result, err := f.readContent()
+	if err != nil {
+		return nil, "", err
+	}
+
+	// An empty list keeps the status sent before the content.
+	status, err = f.readStatus(status)
+	return result, status, err
+}
+
+func (f *ProcessFilter) writeList(lines ...string) error {
+	for _, l := range lines {
+		if _, err := pktline.Writeln(f.in, l); err != nil {
+			return err
+		}
+	}
+
+	if err := pktline.WriteFlush(f.in); err != nil {
+		return err
+	}
+
+	return f.in.Flush()
+}
+
+func (f *ProcessFilter) writeContent(content []byte) error {
+	for len(content) > 0 {
+		n := min(len(content), pktline.MaxPayloadSize)
+		if _, err := pktline.Write(f.in, content[:n]); err != nil {
+			return err
+		}
+
+		content = content[n:]
+	}
+
+	if err := pktline.WriteFlush(f.in); err != nil {
+		return err
+	}
+
+	return f.in.Flush()
+}
+
+func (f *ProcessFilter) readList() ([]string, error) {
+	var lines []string
+	for {
+		l, p, err := pktline.ReadLine(f.out)
+		if err != nil {
+			return nil, err
+		}
+
+		if l == pktline.Flush {
+			return lines, nil
+		}
+
+		lines = append(lines, strings.TrimSuffix(string(p), "\n"))
+	}
+}
+
+func (f *ProcessFilter) readStatus(status string) (string, error) {
+	lines, err := f.readList()
+	if err != nil {
+		return "", err
+	}
+
+	for _, l := range lines {
+		if s, ok := strings.CutPrefix(l, "status="); ok {
+			status = s
+		}
+	}
+
+	return status, nil
+}
+
+func (f *ProcessFilter) readContent() ([]byte, error) {
+	var content []byte
+	for {
+		l, p, err := pktline.ReadLine(f.out)
+
+		// Content starting with "ERR " is not an error line here.
+		var errLine *pktline.ErrorLine
+		if err != nil && !errors.As(err, &errLine) {
+			return nil, err
+		}
+
+		if l == pktline.Flush {
+			return content, nil
+		}
+
+		content = append(content, p...)
+	}
+}
@@ -0,0 +1,122 @@
+package archive
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+// Format is the format of an archive.
+type Format string
+
+const (
+	// Tar is the POSIX tar format with pax extended headers.
+	Tar Format = "tar"
+	// TarGzip is the Tar format compressed with gzip.
+	TarGzip Format = "tar.gz"
+	// Zip is the zip format.
+	Zip Format = "zip"
+)
+
+var (
+	// ErrUnknownFormat is returned for a format not supported.
+	ErrUnknownFormat = errors.New("archive: unknown format")
+	// ErrUnsupportedMode is returned for an entry of a mode that can not
+	// be archived.
+	ErrUnsupportedMode = errors.New("archive: unsupported file mode")
+	// ErrTooLarge is returned when a zip archive would need the zip64
+	// extensions, which are not supported.
+	ErrTooLarge = errors.New("archive: zip64 not supported")
+)
+
+// Options are the options of a Writer.
+type Options struct {
+	// Format is the format of the archive, Tar when empty.
+	Format Format
+	// Commit is the hash of the archived commit, written in the pax
+	// global header of the tar archives and in the comment of the zip
+	// ones. It is zero when a tree is archived.
+	Commit plumbing.Hash
+	// ModTime is the modification time of the entries. The local time of
+	// the zip entries is computed in its location.
+	ModTime time.Time
+	// Umask is applied to the permissions of the regular files and the
+	// directories of the tar archives, git uses 0002 by default.
+	Umask uint32
+	// CompressionLevel is the level of compression of the zip and tar.gz
+	// formats, as defined by compress/flate.
+	CompressionLevel int
+}
+
+// Entry is an entry of an archive.
+type Entry struct {
+	// Name is the path of the entry in the archive, the ones of the
+	// directories ending with a slash.
+	Name string
+	// Mode is the git mode of the entry. Submodules are archived as
+	// directories.
+	Mode filemode.FileMode
+	// Hash is the hash of the object of the entry, used to name the pax
+	// extended headers of the tar archives.
+	Hash plumbing.Hash
+	// Content is the content of a file, or the target of a symlink.
+	Content []byte
+	// Binary is set for the binary files, which are not flagged as text
+	// in the zip archives.
+	Binary bool
+}
+
+// Writer writes the entries of an archive.
+type Writer interface {
+	// WriteEntry writes the given entry to the archive.
+	WriteEntry(e *Entry) error
+	// Close writes the end of the archive. It does not close the
+	// underlying io.Writer.
+	Close() error
+}
+
+// NewWriter returns a Writer of an archive of the given options to w.
+func NewWriter(w io.Writer, o *Options) (Writer, error) {
+	if o == nil {
+		o = &Options{}
+	}
+
+	switch o.Format {
+	case "", Tar:
+		return newTarWriter(w, nil, o)
+	case TarGzip:
+		gz, err := gzip.NewWriterLevel(w, o.CompressionLevel)
+		if err != nil {
+			return nil, err
+		}
+
+		// Like gzip -n, without name and modification time.
+		gz.Header.OS = 3
+		return newTarWriter(gz, gz, o)
+	case Zip:
+		if o.CompressionLevel < flate.HuffmanOnly || o.CompressionLevel > flate.BestCompression {
+			return nil, fmt.Errorf("archive: invalid compression level: %d", o.CompressionLevel)
+		}
+
+		return newZipWriter(w, o), nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, o.Format)
+	}
+}
+
+// isDir reports whether entries of mode m are archived as directories, any
+// directory mode being accepted.
+func isDir(m filemode.FileMode) bool {
+	return uint32(m)&typeMask == uint32(filemode.Dir) || m == filemode.Submodule
+}
+
+// isRegular reports whether m is the mode of a regular file.
+func isRegular(m filemode.FileMode) bool {
+	return m.IsRegular() || m == filemode.Executable
+}
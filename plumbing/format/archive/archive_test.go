@@ -0,0 +1,169 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testCommit  = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	testModTime = time.Unix(1700000000, 0).UTC()
+	longDir     = strings.Repeat("d", 80) + "/" + strings.Repeat("e", 80) + "/"
+	longName    = strings.Repeat("f", 160)
+)
+
+func testEntries() []*Entry {
+	return []*Entry{
+		{Name: "README", Mode: filemode.Regular, Content: []byte("hello\n")},
+		{Name: "run.sh", Mode: filemode.Executable, Content: []byte("#!/bin/sh\n")},
+		{Name: "link", Mode: filemode.Symlink, Content: []byte("README")},
+		{Name: "lib/", Mode: filemode.Submodule},
+		{Name: longDir, Mode: filemode.Dir},
+		{Name: longDir + "file", Mode: filemode.Regular, Content: []byte("deep\n")},
+		{Name: longName, Mode: filemode.Regular, Hash: plumbing.NewHash("e8d3ffab552895c19b9fcf7aa264d277cde33881")},
+		{Name: "data.bin", Mode: filemode.Regular, Content: []byte{0, 1, 2}, Binary: true},
+	}
+}
+
+func writeArchive(t *testing.T, o *Options) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, o)
+	require.NoError(t, err)
+	for _, e := range testEntries() {
+		require.NoError(t, w.WriteEntry(e))
+	}
+
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestTar(t *testing.T) {
+	data := writeArchive(t, &Options{Commit: testCommit, ModTime: testModTime, Umask: 0o002})
+	assert.Zero(t, len(data)%blockSize)
+
+	tr := tar.NewReader(bytes.NewReader(data))
+	hdr, err := tr.Next()
+	require.NoError(t, err)
+	assert.Equal(t, byte(tar.TypeXGlobalHeader), hdr.Typeflag)
+	assert.Equal(t, testCommit.String(), hdr.PAXRecords["comment"])
+
+	expected := []struct {
+		name     string
+		mode     int64
+		typeflag byte
+		content  string
+		link     string
+	}{
+		{"README", 0o664, tar.TypeReg, "hello\n", ""},
+		{"run.sh", 0o775, tar.TypeReg, "#!/bin/sh\n", ""},
+		{"link", 0o777, tar.TypeSymlink, "", "README"},
+		{"lib/", 0o775, tar.TypeDir, "", ""},
+		{longDir, 0o775, tar.TypeDir, "", ""},
+		{longDir + "file", 0o664, tar.TypeReg, "deep\n", ""},
+		{longName, 0o664, tar.TypeReg, "", ""},
+		{"data.bin", 0o664, tar.TypeReg, "\x00\x01\x02", ""},
+	}
+
+	for _, e := range expected {
+		hdr, err := tr.Next()
+		require.NoError(t, err)
+		assert.Equal(t, e.name, hdr.Name)
+		assert.Equal(t, e.mode, hdr.Mode, e.name)
+		assert.Equal(t, e.typeflag, hdr.Typeflag, e.name)
+		assert.Equal(t, e.link, hdr.Linkname, e.name)
+		assert.Equal(t, testModTime.Unix(), hdr.ModTime.Unix(), e.name)
+		assert.Equal(t, "root", hdr.Uname)
+
+		content, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		assert.Equal(t, e.content, string(content), e.name)
+	}
+
+	_, err = tr.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestTarLongNames(t *testing.T) {
+	data := writeArchive(t, &Options{})
+
+	// The directory fits in the prefix field, the long file name does not
+	// and is named after its hash.
+	assert.True(t, bytes.Contains(data, []byte(strings.Repeat("e", 80)+"/\x00")))
+	assert.True(t, bytes.Contains(data, []byte("e8d3ffab552895c19b9fcf7aa264d277cde33881.paxheader\x00")))
+	assert.True(t, bytes.Contains(data, []byte("e8d3ffab552895c19b9fcf7aa264d277cde33881.data\x00")))
+	assert.True(t, bytes.Contains(data, []byte("170 path="+longName+"\n")))
+
+	// Without commit, there is no global header.
+	assert.Equal(t, "README", string(data[:6]))
+}
+
+func TestTarGzip(t *testing.T) {
+	data := writeArchive(t, &Options{Format: TarGzip, Commit: testCommit, ModTime: testModTime, CompressionLevel: flate.BestCompression})
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, byte(3), gz.Header.OS)
+	assert.True(t, gz.Header.ModTime.IsZero())
+
+	uncompressed, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, writeArchive(t, &Options{Commit: testCommit, ModTime: testModTime}), uncompressed)
+}
+
+func TestZip(t *testing.T) {
+	for _, level := range []int{flate.NoCompression, flate.DefaultCompression} {
+		data := writeArchive(t, &Options{Format: Zip, Commit: testCommit, ModTime: testModTime, CompressionLevel: level})
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		assert.Equal(t, testCommit.String(), zr.Comment)
+		require.Len(t, zr.File, 8)
+
+		names := make([]string, 0, len(zr.File))
+		for _, f := range zr.File {
+			names = append(names, f.Name)
+			assert.Equal(t, testModTime.Unix(), f.Modified.Unix(), f.Name)
+		}
+
+		assert.Equal(t, []string{"README", "run.sh", "link", "lib/", longDir, longDir + "file", longName, "data.bin"}, names)
+		assert.Equal(t, uint32(0o100755)<<16, zr.File[1].ExternalAttrs)
+		assert.Equal(t, uint32(0o120777)<<16, zr.File[2].ExternalAttrs)
+		assert.Equal(t, uint32(16), zr.File[3].ExternalAttrs)
+		assert.Equal(t, uint16(0x0317), zr.File[1].CreatorVersion)
+		assert.Equal(t, uint16(0), zr.File[0].CreatorVersion)
+
+		rc, err := zr.File[5].Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		assert.Equal(t, "deep\n", string(content))
+
+		// The files are stored when the deflate stream is not smaller.
+		assert.Equal(t, zip.Store, zr.File[0].Method)
+	}
+}
+
+func TestUnsupportedMode(t *testing.T) {
+	for _, f := range []Format{Tar, Zip} {
+		w, err := NewWriter(io.Discard, &Options{Format: f})
+		require.NoError(t, err)
+		err = w.WriteEntry(&Entry{Name: "foo", Mode: filemode.Empty})
+		assert.ErrorIs(t, err, ErrUnsupportedMode)
+	}
+
+	_, err := NewWriter(io.Discard, &Options{Format: "rar"})
+	assert.ErrorIs(t, err, ErrUnknownFormat)
+}
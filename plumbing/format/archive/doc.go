@@ -0,0 +1,9 @@
+// Package archive implements the tar and zip archive formats written by
+// git archive.
+//
+// The entries are encoded the way git encodes them, the tar archives being
+// byte for byte identical to the ones written by git for the same entries,
+// options and modification time. The deflate streams of the zip and
+// tar.gz archives are not produced by zlib, they are deterministic but
+// differ from the ones of git.
+package archive
@@ -0,0 +1,299 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+const (
+	recordSize = 512
+	blockSize  = recordSize * 20
+
+	// ustarMax is the largest size and modification time of the ustar
+	// headers, larger ones are written in pax extended headers.
+	ustarMax = 0o77777777777
+
+	typeRegular        = '0'
+	typeSymlink        = '2'
+	typeDir            = '5'
+	typeExtendedHeader = 'x'
+	typeGlobalHeader   = 'g'
+
+	// paxMode is the mode of the pax extended headers.
+	paxMode = 0o100666
+	// typeMask and regularType are the S_IFMT and S_IFREG bits of the
+	// modes.
+	typeMask    = 0o170000
+	regularType = 0o100000
+)
+
+// ustarHeader is a ustar header, see the offsets of its fields below.
+type ustarHeader [recordSize]byte
+
+const (
+	nameOffset     = 0
+	nameSize       = 100
+	modeOffset     = 100
+	uidOffset      = 108
+	gidOffset      = 116
+	sizeOffset     = 124
+	mtimeOffset    = 136
+	chksumOffset   = 148
+	chksumSize     = 8
+	typeOffset     = 156
+	linkOffset     = 157
+	linkSize       = 100
+	magicOffset    = 257
+	versionOffset  = 263
+	unameOffset    = 265
+	gnameOffset    = 297
+	devmajorOffset = 329
+	devminorOffset = 337
+	prefixOffset   = 345
+	prefixSize     = 155
+)
+
+// tarWriter writes tar archives like git archive does.
+type tarWriter struct {
+	w      io.Writer
+	closer io.Closer
+	n      int64
+
+	mtime int64
+	umask uint32
+}
+
+func newTarWriter(w io.Writer, closer io.Closer, o *Options) (*tarWriter, error) {
+	t := &tarWriter{w: w, closer: closer, umask: o.Umask}
+	if !o.ModTime.IsZero() {
+		t.mtime = o.ModTime.Unix()
+	}
+
+	if err := t.writeGlobalHeader(o); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// writeGlobalHeader writes the pax global header holding the hash of the
+// commit, and the modification time when too large for the ustar headers.
+func (t *tarWriter) writeGlobalHeader(o *Options) error {
+	var ext []byte
+	if !o.Commit.IsZero() {
+		ext = appendExtHeader(ext, "comment", []byte(o.Commit.String()))
+	}
+
+	if t.mtime > ustarMax {
+		ext = appendExtHeader(ext, "mtime", []byte(strconv.FormatInt(t.mtime, 10)))
+		t.mtime = ustarMax
+	}
+
+	if len(ext) == 0 {
+		return nil
+	}
+
+	var h ustarHeader
+	h[typeOffset] = typeGlobalHeader
+	copy(h[nameOffset:], "pax_global_header")
+	t.prepareHeader(&h, paxMode, uint64(len(ext)))
+	if err := t.writeBlocked(h[:]); err != nil {
+		return err
+	}
+
+	return t.writeBlocked(ext)
+}
+
+// WriteEntry implements Writer.
+func (t *tarWriter) WriteEntry(e *Entry) error {
+	var h ustarHeader
+	var ext []byte
+
+	mode := uint32(e.Mode)
+	switch {
+	case isDir(e.Mode):
+		h[typeOffset] = typeDir
+		mode = (mode | 0o777) &^ t.umask
+	case e.Mode == filemode.Symlink:
+		h[typeOffset] = typeSymlink
+		mode |= 0o777
+	case isRegular(e.Mode):
+		h[typeOffset] = typeRegular
+		perm := uint32(0o666)
+		if mode&0o100 != 0 {
+			perm = 0o777
+		}
+
+		mode = (mode | perm) &^ t.umask
+	default:
+		return fmt.Errorf("%w: %s %s", ErrUnsupportedMode, e.Mode, e.Name)
+	}
+
+	if len(e.Name) > nameSize {
+		plen := pathPrefix(e.Name, prefixSize)
+		rest := len(e.Name) - plen - 1
+		if plen > 0 && rest <= nameSize {
+			copy(h[prefixOffset:], e.Name[:plen])
+			copy(h[nameOffset:], e.Name[plen+1:])
+		} else {
+			copy(h[nameOffset:], e.Hash.String()+".data")
+			ext = appendExtHeader(ext, "path", []byte(e.Name))
+		}
+	} else {
+		copy(h[nameOffset:], e.Name)
+	}
+
+	if h[typeOffset] == typeSymlink {
+		if len(e.Content) > linkSize {
+			copy(h[linkOffset:], "see "+e.Hash.String()+".paxheader")
+			ext = appendExtHeader(ext, "linkpath", e.Content)
+		} else {
+			copy(h[linkOffset:], e.Content)
+		}
+	}
+
+	size := uint64(len(e.Content))
+	if mode&typeMask == regularType && size > ustarMax {
+		ext = appendExtHeader(ext, "size", []byte(strconv.FormatUint(size, 10)))
+		size = 0
+	}
+
+	t.prepareHeader(&h, mode, size)
+	if len(ext) > 0 {
+		if err := t.writeExtendedHeader(e, ext); err != nil {
+			return err
+		}
+	}
+
+	if err := t.writeBlocked(h[:]); err != nil {
+		return err
+	}
+
+	if h[typeOffset] == typeRegular && len(e.Content) > 0 {
+		return t.writeBlocked(e.Content)
+	}
+
+	return nil
+}
+
+// writeExtendedHeader writes the pax extended header of the entry.
+func (t *tarWriter) writeExtendedHeader(e *Entry, ext []byte) error {
+	var h ustarHeader
+	h[typeOffset] = typeExtendedHeader
+	copy(h[nameOffset:], e.Hash.String()+".paxheader")
+	t.prepareHeader(&h, paxMode, uint64(len(ext)))
+	if err := t.writeBlocked(h[:]); err != nil {
+		return err
+	}
+
+	return t.writeBlocked(ext)
+}
+
+// prepareHeader fills the fields common to all the headers.
+func (t *tarWriter) prepareHeader(h *ustarHeader, mode uint32, size uint64) {
+	if mode&typeMask != regularType {
+		size = 0
+	}
+
+	copy(h[modeOffset:], fmt.Sprintf("%07o", mode&0o7777))
+	copy(h[sizeOffset:], fmt.Sprintf("%011o", size))
+	copy(h[mtimeOffset:], fmt.Sprintf("%011o", t.mtime))
+	copy(h[uidOffset:], fmt.Sprintf("%07o", 0))
+	copy(h[gidOffset:], fmt.Sprintf("%07o", 0))
+	copy(h[unameOffset:], "root")
+	copy(h[gnameOffset:], "root")
+	copy(h[devmajorOffset:], fmt.Sprintf("%07o", 0))
+	copy(h[devminorOffset:], fmt.Sprintf("%07o", 0))
+	copy(h[magicOffset:], "ustar\x00")
+	copy(h[versionOffset:], "00")
+
+	var sum uint32
+	for i, b := range h {
+		if i >= chksumOffset && i < chksumOffset+chksumSize {
+			b = ' '
+		}
+
+		sum += uint32(b)
+	}
+
+	copy(h[chksumOffset:], fmt.Sprintf("%07o\x00", sum))
+}
+
+// writeBlocked writes p padded to a multiple of the record size.
+func (t *tarWriter) writeBlocked(p []byte) error {
+	if err := t.write(p); err != nil {
+		return err
+	}
+
+	if tail := len(p) % recordSize; tail != 0 {
+		return t.write(make([]byte, recordSize-tail))
+	}
+
+	return nil
+}
+
+func (t *tarWriter) write(p []byte) error {
+	n, err := t.w.Write(p)
+	t.n += int64(n)
+	return err
+}
+
+// Close implements Writer. It pads the archive to a multiple of the block
+// size, with at least two zero records at its end.
+func (t *tarWriter) Close() error {
+	tail := blockSize - t.n%blockSize
+	if tail < 2*recordSize {
+		tail += blockSize
+	}
+
+	if err := t.write(make([]byte, tail)); err != nil {
+		return err
+	}
+
+	if t.closer != nil {
+		return t.closer.Close()
+	}
+
+	return nil
+}
+
+// appendExtHeader appends a record of a pax extended header to b, its
+// length including the digits of the length itself.
+func appendExtHeader(b []byte, key string, value []byte) []byte {
+	n := 1 + 1 + len(key) + 1 + len(value) + 1
+	for tmp := 1; n/10 >= tmp; tmp *= 10 {
+		n++
+	}
+
+	b = strconv.AppendInt(b, int64(n), 10)
+	b = append(b, ' ')
+	b = append(b, key...)
+	b = append(b, '=')
+	b = append(b, value...)
+	return append(b, '\n')
+}
+
+// pathPrefix returns the length of the part of name fitting in the prefix
+// field of the ustar headers, up to a slash.
+func pathPrefix(name string, max int) int {
+	i := len(name)
+	if i > 1 && name[i-1] == '/' {
+		i--
+	}
+
+	if i > max {
+		i = max
+	}
+
+	for {
+		i--
+		if i <= 0 || name[i] == '/' {
+			break
+		}
+	}
+
+	return i
+}
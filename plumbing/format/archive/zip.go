@@ -0,0 +1,249 @@
+package archive
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+const (
+	zipLocalHeaderSignature = 0x04034b50
+	zipDirHeaderSignature   = 0x02014b50
+	zipDirTrailerSignature  = 0x06054b50
+
+	zipVersionNeeded = 10
+	// zipUnixCreator is the version made by of the symlinks and the
+	// executables, which external attributes hold unix modes.
+	zipUnixCreator = 0x0317
+	zipFlagUTF8    = 0x0800
+	zipStore       = 0
+	zipDeflate     = 8
+	zipDirAttr     = 16
+
+	// zipExtraMTime is the id of the extended timestamp extra field.
+	zipExtraMTime     = 0x5455
+	zipExtraMTimeSize = 9
+)
+
+// zipWriter writes zip archives like git archive does.
+type zipWriter struct {
+	w      io.Writer
+	offset uint64
+	dir    bytes.Buffer
+	count  int
+
+	commit  plumbing.Hash
+	mtime   int64
+	dosDate uint16
+	dosTime uint16
+	level   int
+}
+
+func newZipWriter(w io.Writer, o *Options) *zipWriter {
+	z := &zipWriter{w: w, commit: o.Commit, level: o.CompressionLevel}
+	if !o.ModTime.IsZero() {
+		z.mtime = o.ModTime.Unix()
+		z.dosDate, z.dosTime = dosTime(o.ModTime)
+	} else {
+		z.dosDate, z.dosTime = dosTime(time.Unix(0, 0).UTC())
+	}
+
+	return z
+}
+
+// WriteEntry implements Writer.
+func (z *zipWriter) WriteEntry(e *Entry) error {
+	if len(e.Name) > math.MaxUint16 {
+		return fmt.Errorf("archive: path too long: %s", e.Name)
+	}
+
+	var flags uint16
+	if !isASCII(e.Name) && utf8.ValidString(e.Name) {
+		flags |= zipFlagUTF8
+	}
+
+	var (
+		method  uint16 = zipStore
+		creator uint16
+		attr    uint32
+		crc     uint32
+		text    uint16
+		data    []byte
+	)
+
+	mode := uint32(e.Mode)
+	switch {
+	case isDir(e.Mode):
+		attr = zipDirAttr
+	case isRegular(e.Mode) || e.Mode == filemode.Symlink:
+		switch {
+		case e.Mode == filemode.Symlink:
+			attr = (mode | 0o777) << 16
+			creator = zipUnixCreator
+		case mode&0o111 != 0:
+			attr = mode << 16
+			creator = zipUnixCreator
+		}
+
+		data = e.Content
+		crc = crc32.ChecksumIEEE(data)
+		if !e.Binary {
+			text = 1
+		}
+
+		if isRegular(e.Mode) && z.level != flate.NoCompression && len(data) > 0 {
+			deflated, err := deflate(data, z.level)
+			if err != nil {
+				return err
+			}
+
+			if len(deflated) < len(data) {
+				data = deflated
+				method = zipDeflate
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %s %s", ErrUnsupportedMode, e.Mode, e.Name)
+	}
+
+	size := uint64(len(e.Content))
+	if size > math.MaxUint32 || z.offset > math.MaxUint32 || z.count == math.MaxUint16 {
+		return ErrTooLarge
+	}
+
+	extra := make([]byte, 0, zipExtraMTimeSize)
+	extra = binary.LittleEndian.AppendUint16(extra, zipExtraMTime)
+	extra = binary.LittleEndian.AppendUint16(extra, zipExtraMTimeSize-4)
+	extra = append(extra, 1)
+	extra = binary.LittleEndian.AppendUint32(extra, uint32(z.mtime))
+
+	h := make([]byte, 0, 30+len(e.Name)+len(extra))
+	h = binary.LittleEndian.AppendUint32(h, zipLocalHeaderSignature)
+	h = binary.LittleEndian.AppendUint16(h, zipVersionNeeded)
+	h = binary.LittleEndian.AppendUint16(h, flags)
+	h = binary.LittleEndian.AppendUint16(h, method)
+	h = binary.LittleEndian.AppendUint16(h, z.dosTime)
+	h = binary.LittleEndian.AppendUint16(h, z.dosDate)
+	h = binary.LittleEndian.AppendUint32(h, crc)
+	h = binary.LittleEndian.AppendUint32(h, uint32(len(data)))
+	h = binary.LittleEndian.AppendUint32(h, uint32(size))
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(e.Name)))
+	h = binary.LittleEndian.AppendUint16(h, uint16(len(extra)))
+	h = append(h, e.Name...)
+	h = append(h, extra...)
+
+	d := make([]byte, 0, 46+len(e.Name)+len(extra))
+	d = binary.LittleEndian.AppendUint32(d, zipDirHeaderSignature)
+	d = binary.LittleEndian.AppendUint16(d, creator)
+	d = binary.LittleEndian.AppendUint16(d, zipVersionNeeded)
+	d = binary.LittleEndian.AppendUint16(d, flags)
+	d = binary.LittleEndian.AppendUint16(d, method)
+	d = binary.LittleEndian.AppendUint16(d, z.dosTime)
+	d = binary.LittleEndian.AppendUint16(d, z.dosDate)
+	d = binary.LittleEndian.AppendUint32(d, crc)
+	d = binary.LittleEndian.AppendUint32(d, uint32(len(data)))
+	d = binary.LittleEndian.AppendUint32(d, uint32(size))
+	d = binary.LittleEndian.AppendUint16(d, uint16(len(e.Name)))
+	d = binary.LittleEndian.AppendUint16(d, uint16(len(extra)))
+	d = binary.LittleEndian.AppendUint16(d, 0) // comment length
+	d = binary.LittleEndian.AppendUint16(d, 0) // disk
+	d = binary.LittleEndian.AppendUint16(d, text)
+	d = binary.LittleEndian.AppendUint32(d, attr)
+	d = binary.LittleEndian.AppendUint32(d, uint32(z.offset))
+	d = append(d, e.Name...)
+	d = append(d, extra...)
+
+	if err := z.write(h); err != nil {
+		return err
+	}
+
+	if err := z.write(data); err != nil {
+		return err
+	}
+
+	z.dir.Write(d)
+	z.count++
+	return nil
+}
+
+func (z *zipWriter) write(p []byte) error {
+	n, err := z.w.Write(p)
+	z.offset += uint64(n)
+	return err
+}
+
+// Close implements Writer. It writes the central directory and its end
+// record, commented with the hash of the commit.
+func (z *zipWriter) Close() error {
+	if z.offset > math.MaxUint32 || z.dir.Len() > math.MaxUint32 {
+		return ErrTooLarge
+	}
+
+	var comment string
+	if !z.commit.IsZero() {
+		comment = z.commit.String()
+	}
+
+	t := make([]byte, 0, 22+len(comment))
+	t = binary.LittleEndian.AppendUint32(t, zipDirTrailerSignature)
+	t = binary.LittleEndian.AppendUint16(t, 0) // disk
+	t = binary.LittleEndian.AppendUint16(t, 0) // directory start disk
+	t = binary.LittleEndian.AppendUint16(t, uint16(z.count))
+	t = binary.LittleEndian.AppendUint16(t, uint16(z.count))
+	t = binary.LittleEndian.AppendUint32(t, uint32(z.dir.Len()))
+	t = binary.LittleEndian.AppendUint32(t, uint32(z.offset))
+	t = binary.LittleEndian.AppendUint16(t, uint16(len(comment)))
+	t = append(t, comment...)
+
+	if _, err := z.w.Write(z.dir.Bytes()); err != nil {
+		return err
+	}
+
+	_, err := z.w.Write(t)
+	return err
+}
+
+// deflate returns the raw deflate stream of data.
+func deflate(data []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := fw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// dosTime returns the MS-DOS date and time of t, in its location.
+func dosTime(t time.Time) (date, tm uint16) {
+	date = uint16(t.Day() + int(t.Month())*32 + (t.Year()-1980)*512)
+	tm = uint16(t.Second()/2 + t.Minute()*32 + t.Hour()*2048)
+	return date, tm
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
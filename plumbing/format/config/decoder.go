@@ -19,19 +19,43 @@ func NewDecoder(r io.Reader) *Decoder {
 // Decode reads the whole config from its input and stores it in the
 // value pointed to by config.
 func (d *Decoder) Decode(config *Config) error {
+	return decode(d, []*Config{config}, nil, 0)
+}
+
+// DecodeWithIncludes reads the whole config from its input and stores it in
+// the value pointed to by config, expanding the files included by the
+// include and includeIf sections in place. The included files are recorded
+// in the Includes of config.
+func (d *Decoder) DecodeWithIncludes(config *Config, o *IncludeOptions) error {
+	if o == nil {
+		o = &IncludeOptions{}
+	}
+
+	return decode(d, []*Config{config}, o, 0)
+}
+
+// decode decodes r into every config of targets, the last one being the
+// config of the file being read and the others the ones of the files
+// including it.
+func decode(r io.Reader, targets []*Config, o *IncludeOptions, depth int) error {
 	cb := func(s string, ss string, k string, v string, bv bool) error {
-		if ss == "" && k == "" {
-			config.Section(s)
-			return nil
+		for _, config := range targets {
+			switch {
+			case ss == "" && k == "":
+				config.Section(s)
+			case ss != "" && k == "":
+				config.Section(s).Subsection(ss)
+			default:
+				config.AddOption(s, ss, k, v)
+			}
 		}
 
-		if ss != "" && k == "" {
-			config.Section(s).Subsection(ss)
+		if o == nil || k == "" {
 			return nil
 		}
 
-		config.AddOption(s, ss, k, v)
-		return nil
+		return o.include(targets, s, ss, k, v, depth)
 	}
-	return gcfg.ReadWithCallback(d, cb)
+
+	return gcfg.ReadWithCallback(r, cb)
 }
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Editor edits the content of a config file in place, keeping its comments,
+// formatting and the order of its sections and options untouched except for
+// the edited options.
+//
+// Sections are matched case-insensitively, subsections and keys as git does:
+// subsections are case sensitive and keys are not.
+type Editor struct {
+	b []byte
+}
+
+// NewEditor returns an Editor of the given config file content.
+func NewEditor(b []byte) (*Editor, error) {
+	if _, err := scan(b); err != nil {
+		return nil, err
+	}
+
+	return &Editor{b: bytes.Clone(b)}, nil
+}
+
+// Bytes returns the edited content.
+func (e *Editor) Bytes() []byte {
+	return e.b
+}
+
+func (e *Editor) items() []*item {
+	// The content is valid, it was checked by NewEditor and only valid
+	// content is inserted.
+	items, _ := scan(e.b)
+	return items
+}
+
+// SetOption sets the value of an option, replacing the first of its current
+// values in place and removing the others. The option is added as with
+// AddOption when it does not exist.
+func (e *Editor) SetOption(section, subsection, key, value string) {
+	matches := e.options(section, subsection, key)
+	if len(matches) == 0 {
+		e.AddOption(section, subsection, key, value)
+		return
+	}
+
+	// The options are edited from the last one, keeping the offsets of the
+	// previous ones valid.
+	for i := len(matches) - 1; i > 0; i-- {
+		e.remove(matches[i])
+	}
+
+	e.replace(matches[0].start, matches[0].end, formatOption(key, value))
+}
+
+// AddOption adds a value to an option, after its last value. The option is
+// added at the end of the last matching section otherwise, or of a new
+// section at the end of the file.
+func (e *Editor) AddOption(section, subsection, key, value string) {
+	var last, header, option *item
+	for _, it := range e.items() {
+		switch {
+		case it.kind == sectionItem && it.isSection(section, subsection):
+			header, last = it, it
+		case it.kind == sectionItem:
+			header = nil
+		case header != nil:
+			last = it
+			if it.matches(section, subsection, key) {
+				option = it
+			}
+		}
+	}
+
+	if option != nil {
+		last = option
+	}
+
+	if last == nil {
+		e.appendSection(section, subsection)
+		e.appendLine("\t" + formatOption(key, value))
+		return
+	}
+
+	e.insertLine(e.lineEnd(last.end), "\t"+formatOption(key, value))
+}
+
+// RemoveOption removes every value of an option, returning how many were
+// removed.
+func (e *Editor) RemoveOption(section, subsection, key string) int {
+	matches := e.options(section, subsection, key)
+	for i := len(matches) - 1; i >= 0; i-- {
+		e.remove(matches[i])
+	}
+
+	return len(matches)
+}
+
+// RemoveSection removes every section with the given name and subsection,
+// along with their options and the comments they contain, reporting whether
+// there was one.
+func (e *Editor) RemoveSection(section, subsection string) bool {
+	type span struct{ start, end int }
+
+	var spans []span
+	var current *span
+	for _, it := range e.items() {
+		if it.kind != sectionItem {
+			continue
+		}
+
+		if current != nil {
+			current.end = e.lineStart(it.start)
+			spans = append(spans, *current)
+			current = nil
+		}
+
+		if it.isSection(section, subsection) {
+			current = &span{start: e.lineStart(it.start), end: len(e.b)}
+		}
+	}
+
+	if current != nil {
+		spans = append(spans, *current)
+	}
+
+	for i := len(spans) - 1; i >= 0; i-- {
+		e.replace(spans[i].start, spans[i].end, "")
+	}
+
+	return len(spans) > 0
+}
+
+// options returns the values of an option, in order.
+func (e *Editor) options(section, subsection, key string) []*item {
+	var matches []*item
+	for _, it := range e.items() {
+		if it.matches(section, subsection, key) {
+			matches = append(matches, it)
+		}
+	}
+
+	return matches
+}
+
+func (it *item) isSection(section, subsection string) bool {
+	return strings.EqualFold(it.section, section) && it.subsection == subsection
+}
+
+func (it *item) matches(section, subsection, key string) bool {
+	return it.kind == optionItem && it.isSection(section, subsection) &&
+		strings.EqualFold(it.key, key)
+}
+
+// remove removes an option, along with its line when it is alone on it.
+func (e *Editor) remove(it *item) {
+	start, end := it.start, it.end
+	if ls := e.lineStart(start); len(bytes.TrimSpace(e.b[ls:start])) == 0 {
+		start = ls
+		if end < len(e.b) {
+			end++
+		}
+	}
+
+	e.replace(start, end, "")
+}
+
+func (e *Editor) replace(start, end int, s string) {
+	b := make([]byte, 0, len(e.b)-(end-start)+len(s))
+	b = append(b, e.b[:start]...)
+	b = append(b, s...)
+	e.b = append(b, e.b[end:]...)
+}
+
+// lineStart returns the offset of the start of the line of offset.
+func (e *Editor) lineStart(offset int) int {
+	return bytes.LastIndexByte(e.b[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset of the end of the line of offset, after its
+// newline.
+func (e *Editor) lineEnd(offset int) int {
+	i := bytes.IndexByte(e.b[offset:], '\n')
+	if i < 0 {
+		return len(e.b)
+	}
+
+	return offset + i + 1
+}
+
+// insertLine inserts a line at offset, the start of a line or the end of the
+// content.
+func (e *Editor) insertLine(offset int, line string) {
+	if offset == len(e.b) {
+		e.appendLine(line)
+		return
+	}
+
+	e.replace(offset, offset, line+"\n")
+}
+
+func (e *Editor) appendLine(line string) {
+	if len(e.b) > 0 && e.b[len(e.b)-1] != '\n' {
+		e.b = append(e.b, '\n')
+	}
+
+	e.b = append(e.b, line+"\n"...)
+}
+
+func (e *Editor) appendSection(section, subsection string) {
+	if subsection == "" {
+		e.appendLine(fmt.Sprintf("[%s]", section))
+		return
+	}
+
+	e.appendLine(fmt.Sprintf("[%s \"%s\"]", section, subsectionReplacer.Replace(subsection)))
+}
+
+func formatOption(key, value string) string {
+	return fmt.Sprintf("%s = %s", key, quoteValue(value))
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EditorSuite struct {
+	suite.Suite
+}
+
+func TestEditorSuite(t *testing.T) {
+	suite.Run(t, new(EditorSuite))
+}
+
+const editorFixture = `# global comment
+[core]
+	bare = false # keep me
+	; about remotes
+[remote "origin"]
+	url = https://example.com/a
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	url = https://example.com/b
+[user]
+	name = John
+`
+
+func (s *EditorSuite) editor() *Editor {
+	e, err := NewEditor([]byte(editorFixture))
+	s.Require().NoError(err)
+	return e
+}
+
+func (s *EditorSuite) TestSetOption() {
+	e := s.editor()
+	e.SetOption("Remote", "origin", "URL", "https://example.com/c d")
+	e.SetOption("core", "", "bare", "true")
+
+	s.Equal(`# global comment
+[core]
+	bare = true
+	; about remotes
+[remote "origin"]
+	URL = https://example.com/c d
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[user]
+	name = John
+`, string(e.Bytes()))
+}
+
+func (s *EditorSuite) TestSetOptionMissing() {
+	e := s.editor()
+	e.SetOption("core", "", "filemode", "false")
+	e.SetOption("branch", "main", "remote", "origin")
+
+	s.Equal(`# global comment
+[core]
+	bare = false # keep me
+	filemode = false
+	; about remotes
+[remote "origin"]
+	url = https://example.com/a
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	url = https://example.com/b
+[user]
+	name = John
+[branch "main"]
+	remote = origin
+`, string(e.Bytes()))
+}
+
+func (s *EditorSuite) TestAddOption() {
+	e := s.editor()
+	e.AddOption("remote", "origin", "url", "https://example.com/c")
+	e.AddOption("user", "", "email", "john@example.com")
+
+	s.Equal(`# global comment
+[core]
+	bare = false # keep me
+	; about remotes
+[remote "origin"]
+	url = https://example.com/a
+	fetch = +refs/heads/*:refs/remotes/origin/*
+	url = https://example.com/b
+	url = https://example.com/c
+[user]
+	name = John
+	email = john@example.com
+`, string(e.Bytes()))
+}
+
+func (s *EditorSuite) TestRemoveOption() {
+	e := s.editor()
+	s.Equal(2, e.RemoveOption("remote", "origin", "url"))
+	s.Equal(0, e.RemoveOption("remote", "Origin", "url"))
+
+	s.Equal(`# global comment
+[core]
+	bare = false # keep me
+	; about remotes
+[remote "origin"]
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[user]
+	name = John
+`, string(e.Bytes()))
+}
+
+func (s *EditorSuite) TestRemoveSection() {
+	e := s.editor()
+	s.True(e.RemoveSection("remote", "origin"))
+	s.False(e.RemoveSection("branch", "main"))
+
+	s.Equal(`# global comment
+[core]
+	bare = false # keep me
+	; about remotes
+[user]
+	name = John
+`, string(e.Bytes()))
+}
+
+func (s *EditorSuite) TestNewEditorInvalid() {
+	_, err := NewEditor([]byte("[core\n"))
+	s.Error(err)
+}
+
+func (s *EditorSuite) TestEmpty() {
+	e, err := NewEditor(nil)
+	s.Require().NoError(err)
+
+	e.SetOption("core", "", "bare", "true")
+	s.Equal("[core]\n\tbare = true\n", string(e.Bytes()))
+}
@@ -61,14 +61,7 @@ func (e *Encoder) encodeSubsection(sectionName string, s *Subsection) error {
 
 func (e *Encoder) encodeOptions(opts Options) error {
 	for _, o := range opts {
-		var value string
-		if strings.ContainsAny(o.Value, "#;\"\t\n\\") || strings.HasPrefix(o.Value, " ") || strings.HasSuffix(o.Value, " ") {
-			value = `"`+valueReplacer.Replace(o.Value)+`"`
-		} else {
-			value = o.Value
-		}
-
-		if err := e.printf("\t%s = %s\n", o.Key, value); err != nil {
+		if err := e.printf("\t%s = %s\n", o.Key, quoteValue(o.Value)); err != nil {
 			return err
 		}
 	}
@@ -76,6 +69,16 @@ func (e *Encoder) encodeOptions(opts Options) error {
 	return nil
 }
 
+// quoteValue returns the value as written in a config file, quoted when it
+// contains special characters or leading or trailing spaces.
+func quoteValue(v string) string {
+	if strings.ContainsAny(v, "#;\"\t\n\\") || strings.HasPrefix(v, " ") || strings.HasSuffix(v, " ") {
+		return `"` + valueReplacer.Replace(v) + `"`
+	}
+
+	return v
+}
+
 func (e *Encoder) printf(msg string, args ...interface{}) error {
 	_, err := fmt.Fprintf(e.w, msg, args...)
 	return err
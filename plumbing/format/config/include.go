@@ -0,0 +1,257 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// MaxIncludeDepth is the maximum depth of nested includes, as in git.
+const MaxIncludeDepth = 10
+
+// ErrIncludeDepth is returned when the includes are nested deeper than
+// MaxIncludeDepth, usually because of an include cycle.
+var ErrIncludeDepth = errors.New("exceeded maximum include depth")
+
+const (
+	includeSection   = "include"
+	includeIfSection = "includeIf"
+	pathKey          = "path"
+
+	gitdirCondition       = "gitdir:"
+	gitdirFoldCondition   = "gitdir/i:"
+	onbranchCondition     = "onbranch:"
+	hasconfigURLCondition = "hasconfig:remote.*.url:"
+)
+
+// IncludeOptions describes how the include and includeIf sections are
+// expanded, and the repository the conditions of the includeIf sections are
+// evaluated against.
+type IncludeOptions struct {
+	// Path is the path of the config file being decoded. The relative
+	// include paths are resolved against its directory, they are ignored
+	// when it is empty.
+	Path string
+	// Home is the directory `~` expands to, the home of the current user
+	// when empty.
+	Home string
+	// GitDir is the path of the git directory of the repository, the
+	// gitdir conditions never match when it is empty.
+	GitDir string
+	// Branch is the short name of the branch checked out in the
+	// repository, the onbranch conditions never match when it is empty.
+	Branch string
+	// RemoteURLs are the URLs of the remotes of the repository, used by
+	// the hasconfig:remote.*.url conditions.
+	RemoteURLs []string
+}
+
+// include expands the file included by the option k of the section s and
+// subsection ss, if any.
+func (o *IncludeOptions) include(targets []*Config, s, ss, k, v string, depth int) error {
+	if !o.isInclude(s, ss, k, v) {
+		return nil
+	}
+
+	if depth >= MaxIncludeDepth {
+		return fmt.Errorf("%w: %s", ErrIncludeDepth, v)
+	}
+
+	path, ok := o.resolve(v)
+	if !ok {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		// Missing included files are ignored.
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer f.Close()
+
+	included := &Include{Path: path, Config: New()}
+	parent := targets[len(targets)-1]
+	parent.Includes = append(parent.Includes, included)
+
+	child := *o
+	child.Path = path
+	return decode(f, append(targets[:len(targets):len(targets)], included.Config), &child, depth+1)
+}
+
+// isInclude reports whether the option k of the section s and subsection ss
+// includes a file: it is the path of an include section, or of an includeIf
+// section whose condition holds.
+func (o *IncludeOptions) isInclude(s, ss, k, v string) bool {
+	if !strings.EqualFold(k, pathKey) || v == "" {
+		return false
+	}
+
+	switch {
+	case strings.EqualFold(s, includeSection):
+		return ss == ""
+	case strings.EqualFold(s, includeIfSection):
+		return o.matches(ss)
+	}
+
+	return false
+}
+
+// resolve returns the path of an included file, expanding `~` and resolving
+// relative paths against the directory of the including file.
+func (o *IncludeOptions) resolve(path string) (string, bool) {
+	path, ok := o.expandHome(path)
+	if !ok {
+		return "", false
+	}
+
+	if filepath.IsAbs(path) {
+		return path, true
+	}
+
+	if o.Path == "" {
+		return "", false
+	}
+
+	return filepath.Join(filepath.Dir(o.Path), path), true
+}
+
+func (o *IncludeOptions) expandHome(path string) (string, bool) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, true
+	}
+
+	home := o.Home
+	if home == "" {
+		var err error
+		if home, err = os.UserHomeDir(); err != nil {
+			return "", false
+		}
+	}
+
+	return filepath.Join(home, path[1:]), true
+}
+
+// matches reports whether the condition of an includeIf section holds.
+func (o *IncludeOptions) matches(condition string) bool {
+	switch {
+	case strings.HasPrefix(condition, gitdirCondition):
+		return o.matchesGitDir(strings.TrimPrefix(condition, gitdirCondition), false)
+	case strings.HasPrefix(condition, gitdirFoldCondition):
+		return o.matchesGitDir(strings.TrimPrefix(condition, gitdirFoldCondition), true)
+	case strings.HasPrefix(condition, onbranchCondition):
+		pattern := strings.TrimPrefix(condition, onbranchCondition)
+		if strings.HasSuffix(pattern, "/") {
+			pattern += "**"
+		}
+
+		return o.Branch != "" && matchGlob(pattern, o.Branch, false)
+	case strings.HasPrefix(condition, hasconfigURLCondition):
+		pattern := strings.TrimPrefix(condition, hasconfigURLCondition)
+		for _, url := range o.RemoteURLs {
+			if matchGlob(pattern, url, false) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (o *IncludeOptions) matchesGitDir(pattern string, fold bool) bool {
+	if o.GitDir == "" || pattern == "" {
+		return false
+	}
+
+	// A trailing slash matches everything inside the directory.
+	dir := strings.HasSuffix(pattern, "/")
+
+	if strings.HasPrefix(pattern, "./") {
+		if o.Path == "" {
+			return false
+		}
+
+		pattern = filepath.ToSlash(filepath.Dir(o.Path)) + pattern[1:]
+	} else {
+		var ok bool
+		if pattern, ok = o.expandHome(pattern); !ok {
+			return false
+		}
+
+		pattern = filepath.ToSlash(pattern)
+	}
+
+	if !strings.HasPrefix(pattern, "/") && !filepath.IsAbs(pattern) {
+		pattern = "**/" + pattern
+	}
+
+	if dir {
+		pattern = strings.TrimSuffix(pattern, "/") + "/**"
+	}
+
+	gitdir := filepath.Clean(o.GitDir)
+	if matchGlob(pattern, filepath.ToSlash(gitdir), fold) {
+		return true
+	}
+
+	real, err := filepath.EvalSymlinks(gitdir)
+	return err == nil && real != gitdir && matchGlob(pattern, filepath.ToSlash(real), fold)
+}
+
+// matchGlob reports whether s matches the wildmatch pattern, where `*` and
+// `?` do not match slashes and `**` does.
+func matchGlob(pattern, s string, fold bool) bool {
+	var b strings.Builder
+	if fold {
+		b.WriteString("(?i)")
+	}
+
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == '*' && strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(pattern[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(pattern[i:]))
+				i = len(pattern)
+				break
+			}
+
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+
+	b.WriteString("$")
+
+	re, err := regexp.Compile(b.String())
+	return err == nil && re.MatchString(s)
+}
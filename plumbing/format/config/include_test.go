@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type IncludeSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestIncludeSuite(t *testing.T) {
+	suite.Run(t, new(IncludeSuite))
+}
+
+func (s *IncludeSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+func (s *IncludeSuite) write(name, content string) string {
+	path := filepath.Join(s.dir, name)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0o755))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func (s *IncludeSuite) decode(content string, o *IncludeOptions) *Config {
+	cfg := New()
+	s.Require().NoError(NewDecoder(bytes.NewBufferString(content)).DecodeWithIncludes(cfg, o))
+	return cfg
+}
+
+func (s *IncludeSuite) TestInclude() {
+	s.write("inc/user", "[user]\n\tname = included\n\temail = included@example.com\n[include]\n\tpath = nested\n")
+	s.write("inc/nested", "[core]\n\tbare = true\n")
+	path := filepath.Join(s.dir, "config")
+
+	cfg := s.decode(`[user]
+	name = main
+[include]
+	path = inc/user
+	path = missing
+[user]
+	email = main@example.com
+`, &IncludeOptions{Path: path})
+
+	// The included file is expanded in place.
+	s.Equal("included", cfg.Section("user").Option("name"))
+	s.Equal("main@example.com", cfg.Section("user").Option("email"))
+	s.Equal("true", cfg.Section("core").Option("bare"))
+
+	s.Len(cfg.Includes, 1)
+	s.Equal(filepath.Join(s.dir, "inc/user"), cfg.Includes[0].Path)
+	s.Equal("included", cfg.Includes[0].Config.Section("user").Option("name"))
+	s.Len(cfg.Includes[0].Config.Includes, 1)
+}
+
+func (s *IncludeSuite) TestIncludeHome() {
+	s.write("home/.gitconfig-work", "[user]\n\temail = work@example.com\n")
+
+	cfg := s.decode("[include]\n\tpath = ~/.gitconfig-work\n", &IncludeOptions{Home: filepath.Join(s.dir, "home")})
+	s.Equal("work@example.com", cfg.Section("user").Option("email"))
+}
+
+func (s *IncludeSuite) TestIncludeRelativeWithoutPath() {
+	s.write("foo", "[user]\n\tname = foo\n")
+
+	cfg := s.decode("[include]\n\tpath = foo\n", nil)
+	s.False(cfg.Section("user").HasOption("name"))
+}
+
+func (s *IncludeSuite) TestIncludeCycle() {
+	path := s.write("config", "[include]\n\tpath = config\n")
+
+	cfg := New()
+	err := NewDecoder(bytes.NewBufferString("[include]\n\tpath = config\n")).
+		DecodeWithIncludes(cfg, &IncludeOptions{Path: path})
+	s.ErrorIs(err, ErrIncludeDepth)
+}
+
+func (s *IncludeSuite) TestDecodeIgnoresIncludes() {
+	s.write("foo", "[user]\n\tname = foo\n")
+
+	cfg := New()
+	s.NoError(NewDecoder(bytes.NewBufferString("[include]\n\tpath = " + filepath.Join(s.dir, "foo") + "\n")).Decode(cfg))
+	s.False(cfg.Section("user").HasOption("name"))
+	s.Empty(cfg.Includes)
+}
+
+func (s *IncludeSuite) TestIncludeIf() {
+	inc := s.write("inc", "[user]\n\tname = included\n")
+	gitdir := filepath.ToSlash(filepath.Join(s.dir, "work", "project", ".git"))
+
+	for _, tc := range []struct {
+		condition string
+		o         IncludeOptions
+		matches   bool
+	}{
+		{"gitdir:" + filepath.ToSlash(s.dir) + "/work/", IncludeOptions{GitDir: gitdir}, true},
+		{"gitdir:" + filepath.ToSlash(s.dir) + "/other/", IncludeOptions{GitDir: gitdir}, false},
+		{"gitdir:project/.git", IncludeOptions{GitDir: gitdir}, true},
+		{"gitdir:work/", IncludeOptions{GitDir: gitdir}, true},
+		{"gitdir:~/project/", IncludeOptions{GitDir: gitdir, Home: filepath.Join(s.dir, "work")}, true},
+		{"gitdir:./work/", IncludeOptions{GitDir: gitdir, Path: filepath.Join(s.dir, "config")}, true},
+		{"gitdir:WORK/", IncludeOptions{GitDir: gitdir}, false},
+		{"gitdir/i:WORK/", IncludeOptions{GitDir: gitdir}, true},
+		{"gitdir:work/", IncludeOptions{}, false},
+		{"onbranch:main", IncludeOptions{Branch: "main"}, true},
+		{"onbranch:main", IncludeOptions{Branch: "maint"}, false},
+		{"onbranch:feature/", IncludeOptions{Branch: "feature/foo/bar"}, true},
+		{"onbranch:feature/*", IncludeOptions{Branch: "feature/foo/bar"}, false},
+		{"onbranch:main", IncludeOptions{}, false},
+		{"hasconfig:remote.*.url:https://example.com/**", IncludeOptions{
+			RemoteURLs: []string{"git@github.com:foo/bar", "https://example.com/foo/bar"},
+		}, true},
+		{"hasconfig:remote.*.url:https://example.com/*", IncludeOptions{
+			RemoteURLs: []string{"https://example.com/foo/bar"},
+		}, false},
+		{"unknown:foo", IncludeOptions{}, false},
+	} {
+		cfg := s.decode("[includeIf \""+tc.condition+"\"]\n\tpath = "+inc+"\n", &tc.o)
+		s.Equal(tc.matches, cfg.Section("user").HasOption("name"), tc.condition)
+	}
+}
+
+func (s *IncludeSuite) TestMatchGlob() {
+	for _, tc := range []struct {
+		pattern, s string
+		matches    bool
+	}{
+		{"foo", "foo", true},
+		{"f?o", "foo", true},
+		{"f*", "foo", true},
+		{"f*", "foo/bar", false},
+		{"**/bar", "bar", true},
+		{"**/bar", "foo/baz/bar", true},
+		{"foo/**", "foo/bar/baz", true},
+		{"foo/**/baz", "foo/baz", true},
+		{"foo/**/baz", "foo/a/b/baz", true},
+		{"f[a-o]o", "foo", true},
+		{"f[!a-o]o", "foo", false},
+		{`f\*o`, "f*o", true},
+		{`f\*o`, "foo", false},
+		{"a.c", "abc", false},
+	} {
+		s.Equal(tc.matches, matchGlob(tc.pattern, tc.s, false), "%s %s", tc.pattern, tc.s)
+	}
+}
@@ -0,0 +1,425 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Entry is an option of a config file along with its position in the file,
+// as read by ReadEntries.
+type Entry struct {
+	Section    string
+	Subsection string
+	Key        string
+	Value      string
+	// NoValue is set for the options without `=`, meaning boolean true.
+	NoValue bool
+
+	// File is the path of the file the option was read from, and Line its
+	// line, starting at 1.
+	File string
+	Line int
+}
+
+// ReadEntries reads the options of a config file in order, along with their
+// position. The files included by its include and includeIf sections are
+// expanded in place when o is not nil, the position of their options being
+// the one in the included file.
+func ReadEntries(r io.Reader, o *IncludeOptions) ([]*Entry, error) {
+	var path string
+	if o != nil {
+		path = o.Path
+	}
+
+	var entries []*Entry
+	err := readEntries(r, path, o, 0, func(e *Entry) {
+		entries = append(entries, e)
+	})
+
+	return entries, err
+}
+
+func readEntries(r io.Reader, path string, o *IncludeOptions, depth int, fn func(*Entry)) error {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	items, err := scan(b)
+	if err != nil {
+		if path != "" {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		return err
+	}
+
+	for _, it := range items {
+		if it.kind != optionItem {
+			continue
+		}
+
+		fn(&Entry{
+			Section:    it.section,
+			Subsection: it.subsection,
+			Key:        it.key,
+			Value:      it.value,
+			NoValue:    it.noValue,
+			File:       path,
+			Line:       it.line,
+		})
+
+		if o == nil {
+			continue
+		}
+
+		if err := o.includeEntries(it, depth, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// includeEntries reads the entries of the file included by the option it, if
+// any.
+func (o *IncludeOptions) includeEntries(it *item, depth int, fn func(*Entry)) error {
+	if !o.isInclude(it.section, it.subsection, it.key, it.value) {
+		return nil
+	}
+
+	if depth >= MaxIncludeDepth {
+		return fmt.Errorf("%w: %s", ErrIncludeDepth, it.value)
+	}
+
+	path, ok := o.resolve(it.value)
+	if !ok {
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	defer f.Close()
+
+	child := *o
+	child.Path = path
+	return readEntries(f, path, &child, depth+1, fn)
+}
+
+type itemKind int
+
+const (
+	sectionItem itemKind = iota
+	optionItem
+)
+
+// item is a section header or an option of a config file. It spans the bytes
+// [start, end) of the file, end being the end of its last line.
+type item struct {
+	kind       itemKind
+	section    string
+	subsection string
+	key        string
+	value      string
+	noValue    bool
+
+	line       int
+	start, end int
+}
+
+// scan splits a config file into its section headers and options, following
+// the syntax accepted by git.
+func scan(b []byte) ([]*item, error) {
+	s := &scanner{b: b, line: 1}
+	if bytes.HasPrefix(b, []byte("\xef\xbb\xbf")) {
+		s.pos = 3
+	}
+
+	var items []*item
+	var section, subsection string
+	for {
+		c, ok := s.peek()
+		if !ok {
+			return items, nil
+		}
+
+		switch {
+		case c == '\n' || isSpace(c):
+			s.next()
+		case c == '#' || c == ';':
+			s.skipLine()
+		case c == '[':
+			it, err := s.section()
+			if err != nil {
+				return nil, err
+			}
+
+			section, subsection = it.section, it.subsection
+			items = append(items, it)
+		case isAlpha(c):
+			if section == "" {
+				return nil, s.errorf("option outside of a section")
+			}
+
+			it, err := s.option()
+			if err != nil {
+				return nil, err
+			}
+
+			it.section, it.subsection = section, subsection
+			items = append(items, it)
+		default:
+			return nil, s.errorf("unexpected character %q", c)
+		}
+	}
+}
+
+type scanner struct {
+	b    []byte
+	pos  int
+	line int
+}
+
+func (s *scanner) errorf(format string, args ...any) error {
+	return fmt.Errorf("bad config line %d: %s", s.line, fmt.Sprintf(format, args...))
+}
+
+func (s *scanner) peek() (byte, bool) {
+	if s.pos >= len(s.b) {
+		return 0, false
+	}
+
+	return s.b[s.pos], true
+}
+
+func (s *scanner) next() (byte, bool) {
+	c, ok := s.peek()
+	if ok {
+		s.pos++
+		if c == '\n' {
+			s.line++
+		}
+	}
+
+	return c, ok
+}
+
+// skipLine skips the rest of the line, leaving the newline unread.
+func (s *scanner) skipLine() {
+	for {
+		c, ok := s.peek()
+		if !ok || c == '\n' {
+			return
+		}
+
+		s.pos++
+	}
+}
+
+func (s *scanner) section() (*item, error) {
+	it := &item{kind: sectionItem, line: s.line, start: s.pos}
+	s.next() // [
+
+	var name strings.Builder
+	for {
+		c, ok := s.next()
+		switch {
+		case !ok || c == '\n':
+			return nil, s.errorf("unterminated section header")
+		case c == ']':
+			it.section, it.subsection = legacySection(name.String())
+			if it.section == "" {
+				return nil, s.errorf("empty section name")
+			}
+
+			it.end = s.pos
+			return it, nil
+		case isSpace(c):
+			return s.subsection(it, name.String())
+		case isAlnum(c) || c == '-' || c == '.':
+			name.WriteByte(c)
+		default:
+			return nil, s.errorf("invalid character %q in section name", c)
+		}
+	}
+}
+
+// legacySection splits the deprecated [section.subsection] syntax.
+func legacySection(name string) (string, string) {
+	section, subsection, _ := strings.Cut(name, ".")
+	return section, strings.ToLower(subsection)
+}
+
+func (s *scanner) subsection(it *item, name string) (*item, error) {
+	if name == "" || strings.Contains(name, ".") {
+		return nil, s.errorf("invalid section name %q", name)
+	}
+
+	it.section = name
+	for {
+		c, ok := s.next()
+		if !ok || c == '\n' {
+			return nil, s.errorf("unterminated section header")
+		}
+
+		if isSpace(c) {
+			continue
+		}
+
+		if c != '"' {
+			return nil, s.errorf("invalid subsection")
+		}
+
+		break
+	}
+
+	var sub strings.Builder
+	for {
+		c, ok := s.next()
+		switch {
+		case !ok || c == '\n':
+			return nil, s.errorf("unterminated subsection")
+		case c == '\\':
+			c, ok = s.next()
+			if !ok || c == '\n' {
+				return nil, s.errorf("unterminated subsection")
+			}
+
+			sub.WriteByte(c)
+		case c == '"':
+			if c, _ := s.next(); c != ']' {
+				return nil, s.errorf("invalid subsection")
+			}
+
+			it.subsection = sub.String()
+			it.end = s.pos
+			return it, nil
+		default:
+			sub.WriteByte(c)
+		}
+	}
+}
+
+func (s *scanner) option() (*item, error) {
+	it := &item{kind: optionItem, line: s.line, start: s.pos}
+
+	var key strings.Builder
+	for {
+		c, ok := s.peek()
+		if !ok || !(isAlnum(c) || c == '-') {
+			break
+		}
+
+		key.WriteByte(c)
+		s.pos++
+	}
+
+	it.key = key.String()
+	for {
+		c, ok := s.peek()
+		switch {
+		case ok && isSpace(c):
+			s.pos++
+			continue
+		case !ok || c == '\n':
+			it.noValue = true
+			it.end = s.pos
+			return it, nil
+		case c == '#' || c == ';':
+			it.noValue = true
+			s.skipLine()
+			it.end = s.pos
+			return it, nil
+		case c == '=':
+			s.pos++
+			value, err := s.value()
+			if err != nil {
+				return nil, err
+			}
+
+			it.value = value
+			it.end = s.pos
+			return it, nil
+		default:
+			return nil, s.errorf("invalid key %q", it.key+string(c))
+		}
+	}
+}
+
+// value reads the value of an option, leaving the newline ending it unread.
+func (s *scanner) value() (string, error) {
+	var v []byte
+	var quoted bool
+	// trimmed is the length of v without its trailing unquoted spaces.
+	trimmed := 0
+	for {
+		c, ok := s.peek()
+		if !ok || c == '\n' {
+			if quoted {
+				return "", s.errorf("unterminated quoted value")
+			}
+
+			return string(v[:trimmed]), nil
+		}
+
+		s.pos++
+		switch {
+		case !quoted && (c == '#' || c == ';'):
+			s.skipLine()
+		case !quoted && isSpace(c):
+			if len(v) > 0 {
+				v = append(v, c)
+			}
+		case c == '"':
+			quoted = !quoted
+			trimmed = len(v)
+		case c == '\\':
+			e, ok := s.next()
+			if !ok {
+				return "", s.errorf("unterminated escape")
+			}
+
+			switch e {
+			case '\n':
+				continue
+			case 'n':
+				v = append(v, '\n')
+			case 't':
+				v = append(v, '\t')
+			case 'b':
+				v = append(v, '\b')
+			case '"', '\\':
+				v = append(v, e)
+			default:
+				return "", s.errorf("invalid escape \\%c", e)
+			}
+
+			trimmed = len(v)
+		default:
+			v = append(v, c)
+			trimmed = len(v)
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlnum(c byte) bool {
+	return isAlpha(c) || (c >= '0' && c <= '9')
+}
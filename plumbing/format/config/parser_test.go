@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ParserSuite struct {
+	suite.Suite
+}
+
+func TestParserSuite(t *testing.T) {
+	suite.Run(t, new(ParserSuite))
+}
+
+func (s *ParserSuite) TestReadEntries() {
+	input := "\xef\xbb\xbf# comment\n" +
+		"[core]\n" +
+		"\tbare = false ; comment\n" +
+		"\tfilemode\n" +
+		"[remote \"Origin\"]\n" +
+		"\turl = \"https://example.com/a b\"  \n" +
+		"\tfetch = +refs/heads/*:\\\n" +
+		"refs/remotes/origin/*\n" +
+		"[branch.Main] merge = refs/heads/main\n" +
+		"[alias]\n" +
+		"\tlg = \"log \\\"x\\\"\\t#y\"\n"
+
+	entries, err := ReadEntries(bytes.NewBufferString(input), nil)
+	s.Require().NoError(err)
+
+	expected := []*Entry{
+		{Section: "core", Key: "bare", Value: "false", Line: 3},
+		{Section: "core", Key: "filemode", NoValue: true, Line: 4},
+		{Section: "remote", Subsection: "Origin", Key: "url", Value: "https://example.com/a b", Line: 6},
+		{Section: "remote", Subsection: "Origin", Key: "fetch", Value: "+refs/heads/*:refs/remotes/origin/*", Line: 7},
+		{Section: "branch", Subsection: "main", Key: "merge", Value: "refs/heads/main", Line: 9},
+		{Section: "alias", Key: "lg", Value: "log \"x\"\t#y", Line: 11},
+	}
+
+	s.Equal(expected, entries)
+}
+
+func (s *ParserSuite) TestReadEntriesInvalid() {
+	for _, input := range []string{
+		"key = value\n",
+		"[core\n",
+		"[core \"sub]\n",
+		"[core]\n\tkey = \"value\n",
+		"[core]\n\tkey = \\x\n",
+		"[core]\n\tkey! = value\n",
+	} {
+		_, err := ReadEntries(bytes.NewBufferString(input), nil)
+		s.Error(err, input)
+	}
+}
+
+func (s *ParserSuite) TestReadEntriesIncludes() {
+	dir := s.T().TempDir()
+	included := filepath.Join(dir, "included")
+	s.Require().NoError(os.WriteFile(included, []byte("\n[user]\n\tname = included\n"), 0o644))
+
+	input := "[user]\n\tname = main\n[include]\n\tpath = included\n[core]\n\tbare = true\n"
+	path := filepath.Join(dir, "config")
+	entries, err := ReadEntries(bytes.NewBufferString(input), &IncludeOptions{Path: path})
+	s.Require().NoError(err)
+
+	s.Equal([]*Entry{
+		{Section: "user", Key: "name", Value: "main", File: path, Line: 2},
+		{Section: "include", Key: "path", Value: "included", File: path, Line: 4},
+		{Section: "user", Key: "name", Value: "included", File: included, Line: 3},
+		{Section: "core", Key: "bare", Value: "true", File: path, Line: 6},
+	}, entries)
+}
@@ -0,0 +1,296 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+)
+
+var (
+	// ErrHunkFailed is wrapped by the HunkErrors of the hunks that do not
+	// apply.
+	ErrHunkFailed = errors.New("hunk does not apply")
+	// ErrBinaryNoData is returned when applying the patch of a binary file
+	// without binary hunk, or without reverse one when it is reversed.
+	ErrBinaryNoData = errors.New("binary patch without data")
+	// ErrPreimageMismatch is returned when the content a binary patch is
+	// applied to is not the one it was made from.
+	ErrPreimageMismatch = errors.New("binary patch does not match the content")
+)
+
+// HunkError is the error of a hunk that does not apply.
+type HunkError struct {
+	// Hunk is the number of the hunk in the FileDiff, from 1.
+	Hunk int
+	// Line is the first line of the hunk in the old file.
+	Line int
+}
+
+func (e *HunkError) Error() string {
+	return fmt.Sprintf("hunk #%d at line %d does not apply", e.Hunk, e.Line)
+}
+
+func (e *HunkError) Unwrap() error {
+	return ErrHunkFailed
+}
+
+// ApplyError is the error of a FileDiff whose hunks do not all apply.
+type ApplyError struct {
+	// Path is the path of the file of the FileDiff.
+	Path string
+	// Hunks are the errors of the hunks that do not apply.
+	Hunks []*HunkError
+}
+
+func (e *ApplyError) Error() string {
+	msgs := make([]string, len(e.Hunks))
+	for i, h := range e.Hunks {
+		msgs[i] = h.Error()
+	}
+
+	return fmt.Sprintf("patch failed: %s: %s", e.Path, strings.Join(msgs, ", "))
+}
+
+func (e *ApplyError) Unwrap() []error {
+	errs := make([]error, len(e.Hunks))
+	for i, h := range e.Hunks {
+		errs[i] = h
+	}
+
+	return errs
+}
+
+// HunkResult tells how a hunk of a FileDiff was applied.
+type HunkResult struct {
+	// Line is the line of the new file, from 1, where the hunk was applied,
+	// or the first line of the hunk in the old file when it does not apply.
+	Line int
+	// Offset is the number of lines between the position of the hunk given
+	// by the patch and the one it was applied at.
+	Offset int
+	// Fuzz is the number of lines of context ignored at the start and at
+	// the end of the hunk to apply it.
+	Fuzz int
+	// Err is the error of the hunk when it does not apply.
+	Err *HunkError
+}
+
+// Reverse returns the FileDiff undoing the changes of f.
+func (f *FileDiff) Reverse() *FileDiff {
+	r := *f
+	r.OldPath, r.NewPath = f.NewPath, f.OldPath
+	r.OldMode, r.NewMode = f.NewMode, f.OldMode
+	r.OldHash, r.NewHash = f.NewHash, f.OldHash
+	r.BinaryHunk, r.ReverseBinaryHunk = f.ReverseBinaryHunk, f.BinaryHunk
+	r.Hunks = make([]*Hunk, len(f.Hunks))
+	for i, h := range f.Hunks {
+		rh := &Hunk{
+			OldStart: h.NewStart, OldLines: h.NewLines,
+			NewStart: h.OldStart, NewLines: h.OldLines,
+			Section: h.Section,
+			Lines:   make([]HunkLine, len(h.Lines)),
+		}
+
+		for j, l := range h.Lines {
+			switch l.Op {
+			case Add:
+				l.Op = Delete
+			case Delete:
+				l.Op = Add
+			}
+
+			rh.Lines[j] = l
+		}
+
+		r.Hunks[i] = rh
+	}
+
+	return &r
+}
+
+// Apply applies the changes of f to src, the content of the old file, and
+// returns the content of the new file, with the results of the hunks of a
+// text file.
+//
+// The hunks are applied at the closest position where their lines match,
+// the following ones being moved by the same offset. With a fuzz, up to
+// that many lines of context may be ignored at the start and at the end of
+// the hunks that do not apply otherwise. When some hunks do not apply, the
+// error is an *ApplyError and the returned content has the other hunks
+// applied.
+//
+// The patches of binary files need their binary hunks, which are checked
+// against the hashes of their index lines.
+func (f *FileDiff) Apply(src []byte, fuzz int) ([]byte, []HunkResult, error) {
+	if f.Binary {
+		dst, err := f.applyBinary(src)
+		return dst, nil, err
+	}
+
+	lines := splitLines(string(src))
+	results := make([]HunkResult, len(f.Hunks))
+	var failed []*HunkError
+
+	// The hunks are applied in order, after the lines of the previous ones.
+	minPos := 0
+	for i, h := range f.Hunks {
+		pos, pre, post, fz, ok := h.match(lines, minPos, fuzz)
+		if !ok {
+			results[i] = HunkResult{Line: h.OldStart, Err: &HunkError{Hunk: i + 1, Line: h.OldStart}}
+			failed = append(failed, results[i].Err)
+			continue
+		}
+
+		applied := make([]string, 0, len(lines)-len(pre)+len(post))
+		applied = append(applied, lines[:pos]...)
+		applied = append(applied, post...)
+		lines = append(applied, lines[pos+len(pre):]...)
+
+		// The position of the hunk includes its ignored context.
+		start := pos - min(fz, h.leadingContext())
+		results[i] = HunkResult{Line: start + 1, Offset: start - h.expected(), Fuzz: fz}
+		minPos = pos + len(post)
+	}
+
+	dst := []byte(strings.Join(lines, ""))
+	if len(failed) != 0 {
+		return dst, results, &ApplyError{Path: f.Path(), Hunks: failed}
+	}
+
+	return dst, results, nil
+}
+
+// applyBinary applies the binary hunk of f to src.
+func (f *FileDiff) applyBinary(src []byte) ([]byte, error) {
+	if f.BinaryHunk == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBinaryNoData, f.Path())
+	}
+
+	if !matchHash(f.OldHash, src) {
+		return nil, fmt.Errorf("%w: %s", ErrPreimageMismatch, f.Path())
+	}
+
+	dst := f.BinaryHunk.Data
+	if f.BinaryHunk.Type == BinaryDelta {
+		var err error
+		if dst, err = packfile.PatchDelta(src, f.BinaryHunk.Data); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Path(), err)
+		}
+	}
+
+	if !matchHash(f.NewHash, dst) {
+		return nil, fmt.Errorf("%w: %s", ErrPreimageMismatch, f.Path())
+	}
+
+	return dst, nil
+}
+
+// matchHash reports whether the hash of the blob of content starts with
+// the given abbreviated hash, which is ignored when empty or zero.
+func matchHash(abbrev string, content []byte) bool {
+	if strings.Trim(abbrev, "0") == "" {
+		return true
+	}
+
+	return strings.HasPrefix(plumbing.ComputeHash(plumbing.BlobObject, content).String(), abbrev)
+}
+
+// expected returns the position, from 0, where the hunk is expected in the
+// file being patched, its previous hunks being applied.
+func (h *Hunk) expected() int {
+	return max(h.NewStart-1, 0)
+}
+
+// leadingContext returns the number of context lines at the start of h.
+func (h *Hunk) leadingContext() int {
+	n := 0
+	for n < len(h.Lines) && h.Lines[n].Op == Equal {
+		n++
+	}
+
+	return n
+}
+
+// trailingContext returns the number of context lines at the end of h.
+func (h *Hunk) trailingContext() int {
+	n := 0
+	for n < len(h.Lines) && h.Lines[len(h.Lines)-1-n].Op == Equal {
+		n++
+	}
+
+	return n
+}
+
+// trim returns the lines of h with up to fuzz lines of context removed at
+// its start and at its end.
+func (h *Hunk) trim(fuzz int) []HunkLine {
+	lead, trail := min(fuzz, h.leadingContext()), min(fuzz, h.trailingContext())
+	if lead+trail >= len(h.Lines) {
+		return nil
+	}
+
+	return h.Lines[lead : len(h.Lines)-trail]
+}
+
+// match returns the position of the closest lines, from minPos, matching
+// the old lines of h, with the lines replacing them and the fuzz needed.
+//
+// Like git, a hunk starting at the first line must match at the start of
+// the file, and a hunk without trailing context must match at its end,
+// unless its context is ignored or it has none, like the patches without
+// context.
+func (h *Hunk) match(lines []string, minPos, fuzz int) (pos int, pre, post []string, fz int, ok bool) {
+	leading, trailing := h.leadingContext(), h.trailingContext()
+	noContext := leading == 0 && trailing == 0
+	for fz = 0; fz <= fuzz; fz++ {
+		if fz > 0 && fz > leading && fz > trailing {
+			break
+		}
+
+		pre, post = nil, nil
+		for _, l := range h.trim(fz) {
+			if l.Op != Add {
+				pre = append(pre, l.Text)
+			}
+
+			if l.Op != Delete {
+				post = append(post, l.Text)
+			}
+		}
+
+		matchStart := !noContext && fz == 0 && h.OldStart <= 1
+		matchEnd := !noContext && fz == 0 && trailing == 0
+		want := h.expected() + min(fz, leading)
+		last := len(lines) - len(pre)
+		for d := 0; want-d >= minPos || want+d <= last; d++ {
+			for _, p := range []int{want + d, want - d} {
+				if d == 0 && p != want {
+					continue
+				}
+
+				if p < minPos || p > last || (matchStart && p != 0) || (matchEnd && p != last) {
+					continue
+				}
+
+				if matchLines(lines[p:p+len(pre)], pre) {
+					return p, pre, post, fz, true
+				}
+			}
+		}
+	}
+
+	return 0, nil, nil, 0, false
+}
+
+func matchLines(lines, pre []string) bool {
+	for i, l := range pre {
+		if lines[i] != l {
+			return false
+		}
+	}
+
+	return true
+}
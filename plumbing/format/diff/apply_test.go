@@ -0,0 +1,210 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeOne(t *testing.T, patch string) *FileDiff {
+	t.Helper()
+	files, err := NewDecoder(strings.NewReader(patch)).Decode()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	return files[0]
+}
+
+func numberedLines(from, to int) string {
+	var sb strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&sb, "%d\n", i)
+	}
+
+	return sb.String()
+}
+
+const twoHunksPatch = `diff --git a/f b/f
+--- a/f
++++ b/f
+@@ -2,3 +2,3 @@
+ 2
+-3
++three
+ 4
+@@ -8,3 +8,3 @@
+ 8
+-9
++nine
+ 10
+`
+
+func TestApply(t *testing.T) {
+	f := decodeOne(t, twoHunksPatch)
+	dst, results, err := f.Apply([]byte(numberedLines(1, 10)), 0)
+	require.NoError(t, err)
+	assert.Equal(t, strings.NewReplacer("3\n", "three\n", "9\n", "nine\n").Replace(numberedLines(1, 10)), string(dst))
+	assert.Equal(t, []HunkResult{{Line: 2}, {Line: 8}}, results)
+
+	// The reversed patch restores the original content.
+	src, _, err := f.Reverse().Apply(dst, 0)
+	require.NoError(t, err)
+	assert.Equal(t, numberedLines(1, 10), string(src))
+}
+
+func TestApplyOffset(t *testing.T) {
+	f := decodeOne(t, twoHunksPatch)
+	dst, results, err := f.Apply([]byte("a\nb\nc\n"+numberedLines(1, 10)), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\nc\n"+strings.NewReplacer("3\n", "three\n", "9\n", "nine\n").Replace(numberedLines(1, 10)), string(dst))
+	assert.Equal(t, []HunkResult{{Line: 5, Offset: 3}, {Line: 11, Offset: 3}}, results)
+}
+
+func TestApplyFuzz(t *testing.T) {
+	f := decodeOne(t, twoHunksPatch)
+	src := strings.Replace(numberedLines(1, 10), "10\n", "ten\n", 1)
+
+	_, _, err := f.Apply([]byte(src), 0)
+	require.ErrorIs(t, err, ErrHunkFailed)
+
+	dst, results, err := f.Apply([]byte(src), 1)
+	require.NoError(t, err)
+	assert.Equal(t, strings.NewReplacer("3\n", "three\n", "9\n", "nine\n").Replace(src), string(dst))
+	assert.Equal(t, []HunkResult{{Line: 2}, {Line: 8, Fuzz: 1}}, results)
+}
+
+func TestApplyRejects(t *testing.T) {
+	f := decodeOne(t, twoHunksPatch)
+	src := strings.Replace(numberedLines(1, 10), "3\n", "THREE\n", 1)
+	dst, results, err := f.Apply([]byte(src), 0)
+
+	var applyErr *ApplyError
+	require.ErrorAs(t, err, &applyErr)
+	assert.Equal(t, "f", applyErr.Path)
+	assert.Equal(t, []*HunkError{{Hunk: 1, Line: 2}}, applyErr.Hunks)
+	assert.EqualError(t, err, "patch failed: f: hunk #1 at line 2 does not apply")
+
+	// The other hunks are applied.
+	assert.Equal(t, strings.Replace(src, "9\n", "nine\n", 1), string(dst))
+	require.Len(t, results, 2)
+	assert.Equal(t, 2, results[0].Line)
+	assert.Equal(t, applyErr.Hunks[0], results[0].Err)
+	assert.Nil(t, results[1].Err)
+}
+
+func TestApplyStartAndEnd(t *testing.T) {
+	f := decodeOne(t, `--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
++0
+ 1
+ 2
+`)
+
+	// A hunk at the start of the file must match there.
+	_, _, err := f.Apply([]byte("x\n1\n2\n"), 0)
+	assert.ErrorIs(t, err, ErrHunkFailed)
+
+	dst, _, err := f.Apply([]byte("1\n2\n"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, "0\n1\n2\n", string(dst))
+
+	f = decodeOne(t, `--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ 1
+ 2
++3
+`)
+
+	// A hunk without trailing context must match at the end of the file.
+	_, _, err = f.Apply([]byte("1\n2\nx\n"), 0)
+	assert.ErrorIs(t, err, ErrHunkFailed)
+}
+
+func TestApplyNewAndDeleted(t *testing.T) {
+	f := decodeOne(t, `diff --git a/new b/new
+new file mode 100644
+--- /dev/null
++++ b/new
+@@ -0,0 +1,2 @@
++a
++b
+\ No newline at end of file
+`)
+	dst, _, err := f.Apply(nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb", string(dst))
+
+	dst, _, err = f.Reverse().Apply(dst, 0)
+	require.NoError(t, err)
+	assert.Empty(t, dst)
+}
+
+func TestApplyBinary(t *testing.T) {
+	files, err := NewDecoder(strings.NewReader(binaryPatch)).Decode()
+	require.NoError(t, err)
+
+	dst, results, err := files[0].Apply([]byte("hello\x00world\n"), 0)
+	require.NoError(t, err)
+	assert.Nil(t, results)
+	assert.Equal(t, "hello\x00there world\n", string(dst))
+
+	dst, _, err = files[0].Reverse().Apply(dst, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "hello\x00world\n", string(dst))
+
+	_, _, err = files[0].Apply([]byte("other\n"), 0)
+	assert.ErrorIs(t, err, ErrPreimageMismatch)
+
+	dst, _, err = files[1].Apply(nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, dst)
+}
+
+func TestApplyBinaryDelta(t *testing.T) {
+	f := decodeOne(t, `diff --git a/c.bin b/c.bin
+index 545f69f6be9b35797266c4d45678eec252f53b4e..b5a68a972bcf290022322acb09e822b6e614a6e4 100644
+GIT binary patch
+delta 20
+bcmbQuJBxRNI5T^4Mq*xiYRYDL<{D-IL(B$0
+
+delta 21
+ccmbQmJDYcdI5S61W?rg-p@G3>Ip!K>07VJ~&Hw-a
+
+`)
+	require.Equal(t, BinaryDelta, f.BinaryHunk.Type)
+
+	var src, want strings.Builder
+	src.WriteByte(0)
+	want.WriteByte(0)
+	for i := range 200 {
+		fmt.Fprintf(&src, "line %d\n", i)
+		if i == 100 {
+			want.WriteString("changed\n")
+		} else {
+			fmt.Fprintf(&want, "line %d\n", i)
+		}
+	}
+
+	dst, _, err := f.Apply([]byte(src.String()), 0)
+	require.NoError(t, err)
+	assert.Equal(t, want.String(), string(dst))
+
+	dst, _, err = f.Reverse().Apply(dst, 0)
+	require.NoError(t, err)
+	assert.Equal(t, src.String(), string(dst))
+}
+
+func TestApplyBinaryNoData(t *testing.T) {
+	f := decodeOne(t, `diff --git a/a.bin b/a.bin
+index 8e5da76..319a97e 100644
+Binary files a/a.bin and b/a.bin differ
+`)
+	assert.True(t, f.Binary)
+
+	_, _, err := f.Apply([]byte("hello\x00world\n"), 0)
+	assert.ErrorIs(t, err, ErrBinaryNoData)
+}
@@ -0,0 +1,534 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+// CombinedPatch is the combined diff of a merge against all its parents,
+// like `git diff -c`, or `git diff --cc` when it is dense.
+type CombinedPatch interface {
+	// FilePatches returns the combined diffs of the files which differ
+	// from all the parents.
+	FilePatches() []CombinedFilePatch
+	// Message returns an optional message that can be at the top of the
+	// Patch representation.
+	Message() string
+	// Dense reports whether the hunks whose changes come from only one
+	// parent, the result matching the other ones, are left out, like
+	// `git diff --cc`.
+	Dense() bool
+}
+
+// CombinedFilePatch is the combined diff of a file against the parents.
+type CombinedFilePatch interface {
+	// Files returns the file in each of the parents, nil where it is
+	// absent, and the resulting file, nil when it is deleted.
+	Files() (parents []File, result File)
+	// IsBinary reports whether one of the files is binary.
+	IsBinary() bool
+	// Lines returns the lines of the resulting file, followed by a line
+	// without content holding the lines lost at the end of the file.
+	Lines() []CombinedLine
+}
+
+// CombinedLine is a line of the resulting file of a combined diff, with the
+// lines of the parents removed before it.
+type CombinedLine struct {
+	// Content is the content of the line, without its line feed.
+	Content string
+	// Added tells, for each parent, whether the line is absent from it.
+	Added []bool
+	// Lost are the lines of the parents removed before the line.
+	Lost []CombinedLostLine
+}
+
+// CombinedLostLine is a line of some parents removed from the resulting
+// file of a combined diff.
+type CombinedLostLine struct {
+	// Content is the content of the line, without its line feed.
+	Content string
+	// Parents tells, for each parent, whether it had the line.
+	Parents []bool
+}
+
+// EncodeCombined encodes the combined patch with the multi-column hunks of
+// git, like `git diff -c` or `git diff --cc`. The hunks are preceded by the
+// last line starting like a function before them, like git does for the
+// combined diffs, instead of using the diff drivers.
+func (e *UnifiedEncoder) EncodeCombined(patch CombinedPatch) error {
+	sb := &strings.Builder{}
+
+	if message := patch.Message(); message != "" {
+		sb.WriteString(message)
+		if !strings.HasSuffix(message, "\n") {
+			sb.WriteByte('\n')
+		}
+	}
+
+	for _, filePatch := range patch.FilePatches() {
+		e.writeCombinedFilePatch(sb, filePatch, patch.Dense())
+	}
+
+	_, err := e.Write([]byte(sb.String()))
+	return err
+}
+
+// writeCombinedFilePatch writes the combined diff of the file, unless no
+// hunk is left and the modes are unchanged.
+func (e *UnifiedEncoder) writeCombinedFilePatch(sb *strings.Builder, filePatch CombinedFilePatch, dense bool) {
+	parents, result := filePatch.Files()
+
+	var path string
+	modeDiffers := false
+	for _, f := range append([]File{result}, parents...) {
+		if f != nil && path == "" {
+			path = f.Path()
+		}
+
+		if combinedMode(f) != combinedMode(result) {
+			modeDiffers = true
+		}
+	}
+
+	var g *combinedHunks
+	if !filePatch.IsBinary() {
+		g = newCombinedHunks(filePatch.Lines(), len(parents), e.contextLines)
+		if !g.make(dense) && !modeDiffers {
+			return
+		}
+	}
+
+	format := "combined"
+	if dense {
+		format = "cc"
+	}
+
+	header := []string{fmt.Sprintf("diff --%s %s", format, path)}
+
+	hashes := make([]string, len(parents))
+	for i, f := range parents {
+		hashes[i] = e.abbrevHash(combinedHash(f))
+	}
+
+	header = append(header, fmt.Sprintf("index %s..%s", strings.Join(hashes, ","), e.abbrevHash(combinedHash(result))))
+
+	added := result != nil
+	for _, f := range parents {
+		added = added && f == nil
+	}
+
+	if modeDiffers {
+		if added {
+			header = append(header, fmt.Sprintf("new file mode %06o", uint32(result.Mode())))
+		} else {
+			modes := make([]string, len(parents))
+			for i, f := range parents {
+				modes[i] = fmt.Sprintf("%06o", uint32(combinedMode(f)))
+			}
+
+			line := "mode " + strings.Join(modes, ",")
+			if result == nil {
+				line = "deleted file " + line
+			} else {
+				line += fmt.Sprintf("..%06o", uint32(result.Mode()))
+			}
+
+			header = append(header, line)
+		}
+	}
+
+	if filePatch.IsBinary() {
+		header = append(header, "Binary files differ")
+	} else {
+		from, to := e.srcPrefix+path, e.dstPrefix+path
+		if added {
+			from = "/dev/null"
+		}
+
+		if result == nil {
+			to = "/dev/null"
+		}
+
+		header = append(header, "--- "+from, "+++ "+to)
+	}
+
+	sb.WriteString(e.color[Meta])
+	sb.WriteString(strings.Join(header, "\n"))
+	sb.WriteString(e.color.Reset(Meta))
+	sb.WriteByte('\n')
+
+	if g != nil {
+		g.writeTo(sb, e.color)
+	}
+}
+
+func combinedMode(f File) filemode.FileMode {
+	if f == nil {
+		return filemode.Empty
+	}
+
+	return f.Mode()
+}
+
+func combinedHash(f File) plumbing.Hash {
+	if f == nil {
+		return plumbing.ZeroHash
+	}
+
+	return f.Hash()
+}
+
+// combinedHunks finds the hunks of a combined diff, like git's
+// combine-diff.c.
+type combinedHunks struct {
+	lines   []CombinedLine
+	parents int
+	context int
+	// shown tells whether the lines are shown in the hunks, and noLost
+	// whether their lost lines are not, the lines being context lines
+	// before the first interesting one.
+	shown, noLost []bool
+}
+
+func newCombinedHunks(lines []CombinedLine, parents, context int) *combinedHunks {
+	return &combinedHunks{
+		lines:   lines,
+		parents: parents,
+		context: context,
+		shown:   make([]bool, len(lines)),
+		noLost:  make([]bool, len(lines)),
+	}
+}
+
+// added returns the parents the line i is absent from, as a bit mask.
+func (g *combinedHunks) added(i int) uint64 {
+	var mask uint64
+	for p, added := range g.lines[i].Added {
+		if added {
+			mask |= 1 << p
+		}
+	}
+
+	return mask
+}
+
+// interesting reports whether the line i differs from a parent or has lost
+// lines.
+func (g *combinedHunks) interesting(i int) bool {
+	return g.added(i) != 0 || len(g.lines[i].Lost) != 0
+}
+
+// make marks the lines shown in the hunks, leaving out the hunks whose
+// changes come from only one parent when dense, and reports whether any
+// line is shown.
+func (g *combinedHunks) make(dense bool) bool {
+	last := len(g.lines) - 1
+	for i := range g.lines {
+		g.shown[i] = g.interesting(i)
+	}
+
+	if !dense {
+		return g.giveContext()
+	}
+
+	all := uint64(1)<<g.parents - 1
+	for i := 0; i <= last; {
+		for i <= last && !g.shown[i] {
+			i++
+		}
+
+		if i > last {
+			break
+		}
+
+		begin := i
+		end := i + 1
+		for ; end <= last; end++ {
+			if g.shown[end] {
+				continue
+			}
+
+			// Look beyond the end of the hunk for an interesting line
+			// within the context.
+			la := g.adjustTail(begin, end) + g.context
+			if la > last+1 {
+				la = last + 1
+			}
+
+			contin := false
+			for la > 0 && end <= la-1 {
+				la--
+				if g.shown[la] {
+					contin = true
+					break
+				}
+			}
+
+			if !contin {
+				break
+			}
+
+			end = la
+		}
+
+		// The hunk is interesting when its lines differ from different
+		// parents, or when the result differs from all of them.
+		var same uint64
+		interesting := false
+		for j := begin; j < end && !interesting; j++ {
+			diffs := []uint64{g.added(j)}
+			for _, l := range g.lines[j].Lost {
+				diffs = append(diffs, parentsMask(l.Parents))
+			}
+
+			for k, d := range diffs {
+				if d == 0 && k == 0 {
+					continue
+				}
+
+				if same == 0 {
+					same = d
+				} else if same != d {
+					interesting = true
+					break
+				}
+			}
+		}
+
+		if !interesting && same != all {
+			for j := begin; j < end; j++ {
+				g.shown[j] = false
+			}
+		}
+
+		i = end
+	}
+
+	return g.giveContext()
+}
+
+func parentsMask(parents []bool) uint64 {
+	var mask uint64
+	for p, ok := range parents {
+		if ok {
+			mask |= 1 << p
+		}
+	}
+
+	return mask
+}
+
+// adjustTail returns the end of the hunk from begin to end, without its
+// last line when it is only interesting for its lost lines, shown with it.
+func (g *combinedHunks) adjustTail(begin, end int) int {
+	if begin+1 <= end && g.added(end-1) == 0 {
+		return end - 1
+	}
+
+	return end
+}
+
+// next returns the next line from i, shown or not as asked, or the number
+// of lines when there is none.
+func (g *combinedHunks) next(i int, shown bool) int {
+	for i < len(g.lines) && g.shown[i] != shown {
+		i++
+	}
+
+	return i
+}
+
+// giveContext marks the context lines of the shown lines, joining the
+// hunks separated by a few lines, and reports whether any line is shown.
+func (g *combinedHunks) giveContext() bool {
+	last := len(g.lines) - 1
+	i := g.next(0, true)
+	if i > last {
+		return false
+	}
+
+	for i <= last {
+		for j := max(i-g.context, 0); j < i; j++ {
+			if !g.shown[j] {
+				g.noLost[j] = true
+			}
+
+			g.shown[j] = true
+		}
+
+		for {
+			j := g.next(i, false)
+			if j > last {
+				return true
+			}
+
+			k := g.next(j, true)
+			j = g.adjustTail(i, j)
+			if k < j+g.context {
+				for ; j < k; j++ {
+					g.shown[j] = true
+				}
+
+				i = k
+				continue
+			}
+
+			i = k
+			for end := min(j+g.context, last+1); j < end; j++ {
+				g.shown[j] = true
+			}
+
+			break
+		}
+	}
+
+	return true
+}
+
+// parentLine returns the number of the line of the parent p, from 1, at
+// which a hunk starting at the line i starts.
+func (g *combinedHunks) parentLine(i, p int) int {
+	n := 1
+	for j := 0; j < i; j++ {
+		for _, l := range g.lines[j].Lost {
+			if l.Parents[p] {
+				n++
+			}
+		}
+
+		if j < len(g.lines)-1 && !g.lines[j].Added[p] {
+			n++
+		}
+	}
+
+	return n
+}
+
+// isCombinedFuncLine reports whether the line starts like a function, like
+// the hunk comments of git's combined diffs.
+func isCombinedFuncLine(line string) bool {
+	if line == "" {
+		return false
+	}
+
+	c := line[0]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+func (g *combinedHunks) writeTo(sb *strings.Builder, color ColorConfig) {
+	last := len(g.lines) - 1
+	markers := strings.Repeat("@", g.parents+1)
+	for i := 0; ; {
+		comment := ""
+		for i <= last && !g.shown[i] {
+			if i < last && isCombinedFuncLine(g.lines[i].Content) {
+				comment = g.lines[i].Content
+			}
+
+			i++
+		}
+
+		if i > last {
+			return
+		}
+
+		end := i + 1
+		for end <= last && g.shown[end] {
+			end++
+		}
+
+		results := end - i
+		if end > last {
+			// The last line only holds the lost lines.
+			results--
+		}
+
+		// Without context, the unchanged lines are only there to hold
+		// the lost lines, and are not shown. The last line only holds the lines
+		// lost at the end, it is not one of the file.
+		hidden := 0
+		if g.context == 0 {
+			for j := i; j < end && j < last; j++ {
+				if g.added(j) == 0 {
+					hidden++
+				}
+			}
+
+			results -= hidden
+		}
+
+		sb.WriteString(color[Frag])
+		sb.WriteString(markers)
+		for p := 0; p < g.parents; p++ {
+			start := g.parentLine(i, p)
+			fmt.Fprintf(sb, " -%d,%d", start, g.parentLine(end, p)-start-hidden)
+		}
+
+		fmt.Fprintf(sb, " +%d,%d ", i+1, results)
+		sb.WriteString(markers)
+		sb.WriteString(color.Reset(Frag))
+		if c := combinedComment(comment); c != "" {
+			sb.WriteString(color[Context])
+			sb.WriteByte(' ')
+			sb.WriteString(color.Reset(Context))
+			sb.WriteString(color[Func])
+			sb.WriteString(c)
+			sb.WriteString(color.Reset(Func))
+		}
+
+		sb.WriteByte('\n')
+		for ; i < end; i++ {
+			line := g.lines[i]
+			if !g.noLost[i] {
+				for _, l := range line.Lost {
+					writeCombinedLine(sb, color, Old, '-', l.Parents, l.Content)
+				}
+			}
+
+			if i == last {
+				continue
+			}
+
+			switch {
+			case g.added(i) != 0:
+				writeCombinedLine(sb, color, New, '+', line.Added, line.Content)
+			case g.context != 0:
+				writeCombinedLine(sb, color, Context, '+', line.Added, line.Content)
+			}
+		}
+	}
+}
+
+// writeCombinedLine writes a line of a combined hunk, with the marker in
+// the columns of the parents set.
+func writeCombinedLine(sb *strings.Builder, color ColorConfig, key ColorKey, marker byte, parents []bool, content string) {
+	sb.WriteString(color[key])
+	for _, ok := range parents {
+		if ok {
+			sb.WriteByte(marker)
+		} else {
+			sb.WriteByte(' ')
+		}
+	}
+
+	sb.WriteString(content)
+	sb.WriteString(color.Reset(key))
+	sb.WriteByte('\n')
+}
+
+// combinedComment returns the hunk comment of git's combined diffs from
+// the line: its first 40 bytes, up to the last non-space one excluded.
+func combinedComment(line string) string {
+	end := 0
+	for i := 0; i < len(line) && i < 40; i++ {
+		if line[i] != ' ' && line[i] != '\t' && line[i] != '\r' && line[i] != '\v' && line[i] != '\f' {
+			end = i
+		}
+	}
+
+	return line[:end]
+}
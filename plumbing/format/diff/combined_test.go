@@ -0,0 +1,249 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCombinedPatch struct {
+	dense       bool
+	filePatches []testCombinedFilePatch
+}
+
+func (t testCombinedPatch) FilePatches() []CombinedFilePatch {
+	var result []CombinedFilePatch
+	for _, f := range t.filePatches {
+		result = append(result, f)
+	}
+
+	return result
+}
+
+func (t testCombinedPatch) Message() string {
+	return ""
+}
+
+func (t testCombinedPatch) Dense() bool {
+	return t.dense
+}
+
+type testCombinedFilePatch struct {
+	parents []*testFile
+	result  *testFile
+	lines   []CombinedLine
+}
+
+func (t testCombinedFilePatch) IsBinary() bool {
+	return t.lines == nil
+}
+
+func (t testCombinedFilePatch) Files() ([]File, File) {
+	parents := make([]File, len(t.parents))
+	for i, f := range t.parents {
+		if f != nil {
+			parents[i] = f
+		}
+	}
+
+	if t.result == nil {
+		return parents, nil
+	}
+
+	return parents, t.result
+}
+
+func (t testCombinedFilePatch) Lines() []CombinedLine {
+	return t.lines
+}
+
+// combinedLine returns a line of the result, the marks telling for each
+// parent whether it is added, like the columns of the combined diffs.
+func combinedLine(marks, content string, lost ...CombinedLostLine) CombinedLine {
+	l := CombinedLine{Content: content, Lost: lost}
+	for _, m := range marks {
+		l.Added = append(l.Added, m == '+')
+	}
+
+	return l
+}
+
+// combinedLost returns a lost line, the marks telling for each parent
+// whether it had it.
+func combinedLost(marks, content string) CombinedLostLine {
+	l := CombinedLostLine{Content: content}
+	for _, m := range marks {
+		l.Parents = append(l.Parents, m == '-')
+	}
+
+	return l
+}
+
+// conflictFilePatch is a file whose line is changed differently in the two
+// parents and in the result, and whose other change comes from the second
+// parent only.
+var conflictFilePatch = testCombinedFilePatch{
+	parents: []*testFile{
+		{mode: filemode.Regular, path: "main.c", seed: "ours"},
+		{mode: filemode.Regular, path: "main.c", seed: "theirs"},
+	},
+	result: &testFile{mode: filemode.Regular, path: "main.c", seed: "merged"},
+	lines: []CombinedLine{
+		combinedLine("  ", "int main() {",
+			combinedLost("--", "#include <stdio.h>"),
+		),
+		combinedLine("++", "\treturn 3;",
+			combinedLost("- ", "\treturn 2;"),
+			combinedLost(" -", "\treturn 1;"),
+		),
+		combinedLine("  ", "}"),
+		combinedLine("  ", ""),
+		combinedLine("  ", "void f() {"),
+		combinedLine("  ", "\ta();"),
+		combinedLine("  ", "\tb();"),
+		combinedLine("  ", "\tc();"),
+		combinedLine(" +", "\td();",
+			combinedLost(" -", "\tD();"),
+		),
+		combinedLine("  ", "}"),
+		{},
+	},
+}
+
+func TestEncodeCombined(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewUnifiedEncoder(&buf, 1)
+	e.SetAbbrev(7)
+	p := testCombinedPatch{filePatches: []testCombinedFilePatch{conflictFilePatch}}
+	require.NoError(t, e.EncodeCombined(p))
+	assert.Equal(t, ""+
+		"diff --combined main.c\n"+
+		"index 424860e,228068d..57cfab4\n"+
+		"--- a/main.c\n"+
+		"+++ b/main.c\n"+
+		"@@@ -1,4 -1,4 +1,3 @@@\n"+
+		"--#include <stdio.h>\n"+
+		"  int main() {\n"+
+		"- \treturn 2;\n"+
+		" -\treturn 1;\n"+
+		"++\treturn 3;\n"+
+		"  }\n"+
+		"@@@ -9,3 -9,3 +8,3 @@@ void f() \n"+
+		"  \tc();\n"+
+		" -\tD();\n"+
+		" +\td();\n"+
+		"  }\n", buf.String())
+}
+
+func TestEncodeCombinedDense(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewUnifiedEncoder(&buf, 1)
+	e.SetAbbrev(7)
+	p := testCombinedPatch{dense: true, filePatches: []testCombinedFilePatch{conflictFilePatch}}
+	require.NoError(t, e.EncodeCombined(p))
+	assert.Equal(t, ""+
+		"diff --cc main.c\n"+
+		"index 424860e,228068d..57cfab4\n"+
+		"--- a/main.c\n"+
+		"+++ b/main.c\n"+
+		"@@@ -1,4 -1,4 +1,3 @@@\n"+
+		"--#include <stdio.h>\n"+
+		"  int main() {\n"+
+		"- \treturn 2;\n"+
+		" -\treturn 1;\n"+
+		"++\treturn 3;\n"+
+		"  }\n", buf.String())
+}
+
+func TestEncodeCombinedHeaders(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewUnifiedEncoder(&buf, 3)
+	e.SetAbbrev(7)
+	p := testCombinedPatch{dense: true, filePatches: []testCombinedFilePatch{{
+		parents: []*testFile{nil, nil},
+		result:  &testFile{mode: filemode.Regular, path: "new.txt", seed: "new"},
+		lines:   []CombinedLine{combinedLine("++", "new"), {}},
+	}, {
+		parents: []*testFile{
+			{mode: filemode.Regular, path: "old.txt", seed: "old"},
+			{mode: filemode.Regular, path: "old.txt", seed: "old"},
+		},
+		lines: []CombinedLine{{Lost: []CombinedLostLine{combinedLost("--", "old")}}},
+	}, {
+		parents: []*testFile{
+			{mode: filemode.Regular, path: "run.sh", seed: "run"},
+			{mode: filemode.Regular, path: "run.sh", seed: "run"},
+		},
+		result: &testFile{mode: filemode.Executable, path: "run.sh", seed: "run"},
+		lines:  []CombinedLine{combinedLine("  ", "run"), {}},
+	}, {
+		parents: []*testFile{nil, {mode: filemode.Regular, path: "image.png", seed: "a"}},
+		result:  &testFile{mode: filemode.Regular, path: "image.png", seed: "b"},
+	}, {
+		// Unchanged but for the first parent: left out.
+		parents: []*testFile{
+			{mode: filemode.Regular, path: "same.txt", seed: "a"},
+			{mode: filemode.Regular, path: "same.txt", seed: "b"},
+		},
+		result: &testFile{mode: filemode.Regular, path: "same.txt", seed: "b"},
+		lines:  []CombinedLine{combinedLine("+ ", "b", combinedLost("- ", "a")), {}},
+	}}}
+	require.NoError(t, e.EncodeCombined(p))
+	assert.Equal(t, ""+
+		"diff --cc new.txt\n"+
+		"index 0000000,0000000..3e5126c\n"+
+		"new file mode 100644\n"+
+		"--- /dev/null\n"+
+		"+++ b/new.txt\n"+
+		"@@@ -1,0 -1,0 +1,1 @@@\n"+
+		"++new\n"+
+		"diff --cc old.txt\n"+
+		"index 489ce0f,489ce0f..0000000\n"+
+		"deleted file mode 100644,100644\n"+
+		"--- a/old.txt\n"+
+		"+++ /dev/null\n"+
+		"@@@ -1,1 -1,1 +1,0 @@@\n"+
+		"--old\n"+
+		"diff --cc run.sh\n"+
+		"index e5224d5,e5224d5..e5224d5\n"+
+		"mode 100644,100644..100755\n"+
+		"--- a/run.sh\n"+
+		"+++ b/run.sh\n"+
+		"diff --cc image.png\n"+
+		"index 0000000,2e65efe..63d8dbd\n"+
+		"mode 000000,100644..100644\n"+
+		"Binary files differ\n", buf.String())
+}
+
+func TestEncodeCombinedNoContext(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewUnifiedEncoder(&buf, 0)
+	e.SetAbbrev(7)
+	p := testCombinedPatch{filePatches: []testCombinedFilePatch{{
+		parents: []*testFile{
+			{mode: filemode.Regular, path: "a.txt", seed: "a"},
+			{mode: filemode.Regular, path: "a.txt", seed: "b"},
+		},
+		result: &testFile{mode: filemode.Regular, path: "a.txt", seed: "c"},
+		lines: []CombinedLine{
+			combinedLine("  ", "a", combinedLost("- ", "x")),
+			combinedLine("  ", "b"),
+			combinedLine("++", "c"),
+			{Lost: []CombinedLostLine{combinedLost(" -", "y")}},
+		},
+	}}}
+	require.NoError(t, e.EncodeCombined(p))
+	assert.Equal(t, ""+
+		"diff --combined a.txt\n"+
+		"index 2e65efe,63d8dbd..3410062\n"+
+		"--- a/a.txt\n"+
+		"+++ b/a.txt\n"+
+		"@@@ -1,1 -1,0 +1,0 @@@\n"+
+		"- x\n"+
+		"@@@ -4,0 -3,1 +3,1 @@@\n"+
+		"++c\n"+
+		" -y\n", buf.String())
+}
@@ -0,0 +1,691 @@
+package diff
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+// ErrCorruptPatch is returned by Decoder when a patch is malformed.
+var ErrCorruptPatch = errors.New("corrupt patch")
+
+// devNull is the name of the missing files of the patches of created and
+// deleted files.
+const devNull = "/dev/null"
+
+// FileDiff is the change of a file read from a patch, in the unified diff
+// format extended by git with the modes, renames, copies and binary patches
+// of the files.
+type FileDiff struct {
+	// OldPath is the path of the file before the change, empty when it is
+	// created. NewPath is its path after the change, empty when it is
+	// deleted.
+	OldPath, NewPath string
+	// OldMode and NewMode are the modes of the file, zero when they are not
+	// given by the patch.
+	OldMode, NewMode filemode.FileMode
+	// OldHash and NewHash are the hashes of the blobs of the file, as given
+	// by the index line of the patch, usually abbreviated. They are empty
+	// without index line.
+	OldHash, NewHash string
+	// Copy is set when the file at NewPath is a copy of the one at OldPath,
+	// which is kept, instead of a rename.
+	Copy bool
+	// Similarity is the similarity of the files of a rename or a copy, from
+	// 0 to 100.
+	Similarity int
+	// Binary is set for the binary files. Their changes are given by
+	// BinaryHunk, and the reverse ones by ReverseBinaryHunk, when the patch
+	// has them.
+	Binary                        bool
+	BinaryHunk, ReverseBinaryHunk *BinaryHunk
+	// Hunks are the changes of the text files.
+	Hunks []*Hunk
+}
+
+// Path returns the path of the file after the change, or before it when it
+// is deleted.
+func (f *FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+
+	return f.OldPath
+}
+
+// IsNew reports whether the file is created.
+func (f *FileDiff) IsNew() bool {
+	return f.OldPath == ""
+}
+
+// IsDelete reports whether the file is deleted.
+func (f *FileDiff) IsDelete() bool {
+	return f.NewPath == ""
+}
+
+// IsRename reports whether the file is renamed.
+func (f *FileDiff) IsRename() bool {
+	return !f.Copy && !f.IsNew() && !f.IsDelete() && f.OldPath != f.NewPath
+}
+
+// Hunk is a hunk of the changes of a text file.
+type Hunk struct {
+	// OldStart and OldLines are the first line, from 1, and the number of
+	// lines of the hunk in the old file. NewStart and NewLines are the ones
+	// in the new file. The start is the line before the hunk when it has
+	// no lines.
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Section is the text following the ranges of the hunk header, like
+	// the function the hunk is in.
+	Section string
+	// Lines are the lines of the hunk.
+	Lines []HunkLine
+}
+
+// HunkLine is a line of a hunk.
+type HunkLine struct {
+	Op Operation
+	// Text is the content of the line, with its line feed unless it is the
+	// last line of a file not ending with one.
+	Text string
+}
+
+// BinaryHunkType is the type of a BinaryHunk.
+type BinaryHunkType int
+
+const (
+	// BinaryLiteral hunks hold the whole content of the new file.
+	BinaryLiteral BinaryHunkType = iota
+	// BinaryDelta hunks hold the delta from the old file to the new one.
+	BinaryDelta
+)
+
+// BinaryHunk is the change of a binary file, from a "GIT binary patch".
+type BinaryHunk struct {
+	Type BinaryHunkType
+	// Data is the content of the new file or the delta, inflated.
+	Data []byte
+}
+
+// Decoder reads the FileDiffs of patches in the unified diff format, as
+// written by git diff and git format-patch, from a stream. The text around
+// the patches, like the messages of the emails, is skipped.
+type Decoder struct {
+	r *bufio.Reader
+
+	// line is the current line and n its number, from 1.
+	line string
+	n    int
+	eof  bool
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads all the FileDiffs of the stream.
+func (d *Decoder) Decode() ([]*FileDiff, error) {
+	if err := d.next(); err != nil {
+		return nil, err
+	}
+
+	var files []*FileDiff
+	for !d.eof {
+		var f *FileDiff
+		var err error
+		switch {
+		case strings.HasPrefix(d.line, "diff --git "):
+			f, err = d.decodeGitDiff()
+		case strings.HasPrefix(d.line, "--- "):
+			f, err = d.decodeTraditionalDiff()
+		default:
+			err = d.next()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+// next reads the next line, without its line feed.
+func (d *Decoder) next() error {
+	line, err := d.r.ReadString('\n')
+	if err == io.EOF {
+		if line == "" {
+			d.line, d.eof = "", true
+			return nil
+		}
+	} else if err != nil {
+		return err
+	}
+
+	d.line = line
+	d.n++
+	return nil
+}
+
+func (d *Decoder) corrupt(format string, args ...any) error {
+	return fmt.Errorf("%w at line %d: %s", ErrCorruptPatch, d.n, fmt.Sprintf(format, args...))
+}
+
+// text returns the current line without its line feed.
+func (d *Decoder) text() string {
+	return strings.TrimSuffix(strings.TrimSuffix(d.line, "\n"), "\r")
+}
+
+// decodeGitDiff reads the FileDiff starting with the current "diff --git"
+// line.
+func (d *Decoder) decodeGitDiff() (*FileDiff, error) {
+	f := &FileDiff{}
+	f.OldPath, f.NewPath = parseGitDiffNames(strings.TrimPrefix(d.text(), "diff --git "))
+	isNew, isDelete := false, false
+
+headers:
+	for {
+		if err := d.next(); err != nil {
+			return nil, err
+		}
+
+		if d.eof {
+			break
+		}
+
+		line := d.text()
+		var err error
+		switch {
+		case cutPrefix(&line, "old mode "):
+			f.OldMode, err = filemode.New(line)
+		case cutPrefix(&line, "new mode "):
+			f.NewMode, err = filemode.New(line)
+		case cutPrefix(&line, "deleted file mode "):
+			f.OldMode, err = filemode.New(line)
+			isDelete = true
+		case cutPrefix(&line, "new file mode "):
+			f.NewMode, err = filemode.New(line)
+			isNew = true
+		case cutPrefix(&line, "copy from "):
+			f.OldPath, err = unquotePath(line)
+			f.Copy = true
+		case cutPrefix(&line, "copy to "):
+			f.NewPath, err = unquotePath(line)
+			f.Copy = true
+		case cutPrefix(&line, "rename from "), cutPrefix(&line, "rename old "):
+			f.OldPath, err = unquotePath(line)
+		case cutPrefix(&line, "rename to "), cutPrefix(&line, "rename new "):
+			f.NewPath, err = unquotePath(line)
+		case cutPrefix(&line, "similarity index "):
+			f.Similarity, err = strconv.Atoi(strings.TrimSuffix(line, "%"))
+		case strings.HasPrefix(line, "dissimilarity index "):
+		case cutPrefix(&line, "index "):
+			err = f.parseIndex(line)
+		case cutPrefix(&line, "--- "):
+			if line == devNull {
+				isNew = true
+			} else if line, err = parsePatchName(line); err == nil {
+				f.OldPath = line
+			}
+		case cutPrefix(&line, "+++ "):
+			if line == devNull {
+				isDelete = true
+			} else if line, err = parsePatchName(line); err == nil {
+				f.NewPath = line
+			}
+		case strings.HasPrefix(line, "Binary files ") && strings.HasSuffix(line, " differ"):
+			f.Binary = true
+		case line == "GIT binary patch":
+			f.Binary = true
+			if err := d.decodeBinaryPatch(f); err != nil {
+				return nil, err
+			}
+
+			break headers
+		case strings.HasPrefix(line, "@@ -"):
+			if err := d.decodeHunks(f); err != nil {
+				return nil, err
+			}
+
+			break headers
+		default:
+			break headers
+		}
+
+		if err != nil {
+			return nil, d.corrupt("%s", err)
+		}
+	}
+
+	if isNew {
+		f.OldPath = ""
+	}
+
+	if isDelete {
+		f.NewPath = ""
+	}
+
+	if f.OldPath == "" && f.NewPath == "" {
+		return nil, d.corrupt("missing file name")
+	}
+
+	return f, nil
+}
+
+// decodeTraditionalDiff reads the FileDiff starting with the current "---"
+// line, of a patch without git headers. The current line is skipped when it
+// does not start a patch.
+func (d *Decoder) decodeTraditionalDiff() (*FileDiff, error) {
+	oldName := strings.TrimPrefix(d.text(), "--- ")
+	if err := d.next(); err != nil {
+		return nil, err
+	}
+
+	newName, ok := strings.CutPrefix(d.text(), "+++ ")
+	if !ok {
+		return nil, nil
+	}
+
+	if err := d.next(); err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(d.line, "@@ -") {
+		return nil, nil
+	}
+
+	f := &FileDiff{}
+	var err error
+	if oldName != devNull {
+		if f.OldPath, err = parsePatchName(oldName); err != nil {
+			return nil, d.corrupt("%s", err)
+		}
+	}
+
+	if newName != devNull {
+		if f.NewPath, err = parsePatchName(newName); err != nil {
+			return nil, d.corrupt("%s", err)
+		}
+	}
+
+	return f, d.decodeHunks(f)
+}
+
+// parseIndex parses the index line of the patch of f.
+func (f *FileDiff) parseIndex(line string) error {
+	hashes, mode, hasMode := strings.Cut(line, " ")
+	oldHash, newHash, ok := strings.Cut(hashes, "..")
+	if !ok {
+		return fmt.Errorf("invalid index line %q", line)
+	}
+
+	f.OldHash, f.NewHash = oldHash, newHash
+	if !hasMode {
+		return nil
+	}
+
+	m, err := filemode.New(mode)
+	if err != nil {
+		return err
+	}
+
+	if f.OldMode == filemode.Empty {
+		f.OldMode = m
+	}
+
+	if f.NewMode == filemode.Empty {
+		f.NewMode = m
+	}
+
+	return nil
+}
+
+// decodeHunks reads the hunks of the text file of f, starting at the
+// current line.
+func (d *Decoder) decodeHunks(f *FileDiff) error {
+	for !d.eof && strings.HasPrefix(d.line, "@@ -") {
+		h, err := parseHunkHeader(d.text())
+		if err != nil {
+			return d.corrupt("%s", err)
+		}
+
+		oldLines, newLines := h.OldLines, h.NewLines
+		for oldLines > 0 || newLines > 0 {
+			if err := d.next(); err != nil {
+				return err
+			}
+
+			if d.eof {
+				return d.corrupt("truncated hunk")
+			}
+
+			line := HunkLine{Text: d.line[min(1, len(d.line)):]}
+			switch d.line[0] {
+			case ' ':
+				oldLines--
+				newLines--
+			case '\n', '\r':
+				// An empty context line, its space stripped by a mailer.
+				line.Text = d.line
+				oldLines--
+				newLines--
+			case '-':
+				line.Op = Delete
+				oldLines--
+			case '+':
+				line.Op = Add
+				newLines--
+			case '\\':
+				if err := h.noNewline(); err != nil {
+					return d.corrupt("%s", err)
+				}
+
+				continue
+			default:
+				return d.corrupt("unexpected line %q", d.text())
+			}
+
+			if oldLines < 0 || newLines < 0 {
+				return d.corrupt("hunk longer than its header")
+			}
+
+			h.Lines = append(h.Lines, line)
+		}
+
+		f.Hunks = append(f.Hunks, h)
+		if err := d.next(); err != nil {
+			return err
+		}
+
+		if strings.HasPrefix(d.line, "\\") {
+			if err := h.noNewline(); err != nil {
+				return d.corrupt("%s", err)
+			}
+
+			if err := d.next(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// noNewline removes the line feed of the last line of h, followed by a "\ No
+// newline at end of file" line.
+func (h *Hunk) noNewline() error {
+	if len(h.Lines) == 0 {
+		return errors.New("no newline marker without line")
+	}
+
+	last := &h.Lines[len(h.Lines)-1]
+	last.Text = strings.TrimSuffix(last.Text, "\n")
+	return nil
+}
+
+// parseHunkHeader parses a "@@ -l,s +l,s @@ section" line.
+func parseHunkHeader(line string) (*Hunk, error) {
+	rest, ok := strings.CutPrefix(line, "@@ -")
+	ranges, section, found := strings.Cut(rest, " @@")
+	oldRange, newRange, hasNew := strings.Cut(ranges, " +")
+	if !ok || !found || !hasNew {
+		return nil, fmt.Errorf("invalid hunk header %q", line)
+	}
+
+	h := &Hunk{Section: strings.TrimPrefix(section, " ")}
+	var err error
+	if h.OldStart, h.OldLines, err = parseHunkRange(oldRange); err != nil {
+		return nil, err
+	}
+
+	if h.NewStart, h.NewLines, err = parseHunkRange(newRange); err != nil {
+		return nil, err
+	}
+
+	return h, nil
+}
+
+func parseHunkRange(r string) (start, lines int, err error) {
+	s, l, hasLines := strings.Cut(r, ",")
+	if start, err = strconv.Atoi(s); err != nil {
+		return 0, 0, err
+	}
+
+	lines = 1
+	if hasLines {
+		lines, err = strconv.Atoi(l)
+	}
+
+	return start, lines, err
+}
+
+// decodeBinaryPatch reads the binary hunks of f following the current "GIT
+// binary patch" line.
+func (d *Decoder) decodeBinaryPatch(f *FileDiff) error {
+	var err error
+	if f.BinaryHunk, err = d.decodeBinaryHunk(); err != nil {
+		return err
+	}
+
+	if f.BinaryHunk == nil {
+		return d.corrupt("missing binary hunk")
+	}
+
+	f.ReverseBinaryHunk, err = d.decodeBinaryHunk()
+	return err
+}
+
+// decodeBinaryHunk reads the binary hunk starting at the next line, if any.
+func (d *Decoder) decodeBinaryHunk() (*BinaryHunk, error) {
+	if err := d.next(); err != nil {
+		return nil, err
+	}
+
+	h := &BinaryHunk{}
+	line := d.text()
+	switch {
+	case cutPrefix(&line, "literal "):
+	case cutPrefix(&line, "delta "):
+		h.Type = BinaryDelta
+	default:
+		return nil, nil
+	}
+
+	size, err := strconv.Atoi(line)
+	if err != nil {
+		return nil, d.corrupt("invalid binary hunk size %q", line)
+	}
+
+	var data []byte
+	for {
+		if err := d.next(); err != nil {
+			return nil, err
+		}
+
+		line := d.text()
+		if d.eof || line == "" {
+			break
+		}
+
+		decoded, err := decodeBinaryLine(line)
+		if err != nil {
+			return nil, d.corrupt("%s", err)
+		}
+
+		data = append(data, decoded...)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, d.corrupt("binary hunk: %s", err)
+	}
+
+	if h.Data, err = io.ReadAll(zr); err != nil {
+		return nil, d.corrupt("binary hunk: %s", err)
+	}
+
+	if len(h.Data) != size {
+		return nil, d.corrupt("binary hunk of %d bytes instead of %d", len(h.Data), size)
+	}
+
+	return h, nil
+}
+
+// decodeBinaryLine decodes a line of a binary hunk: its length, from 'A'
+// for 1 to 'z' for 52, followed by the base85 encoding of its bytes.
+func decodeBinaryLine(line string) ([]byte, error) {
+	var n int
+	switch c := line[0]; {
+	case 'A' <= c && c <= 'Z':
+		n = int(c-'A') + 1
+	case 'a' <= c && c <= 'z':
+		n = int(c-'a') + 27
+	default:
+		return nil, fmt.Errorf("invalid binary line length %q", c)
+	}
+
+	if len(line)-1 != (n+3)/4*5 {
+		return nil, fmt.Errorf("invalid binary line %q", line)
+	}
+
+	data, err := decodeBase85(line[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	return data[:n], nil
+}
+
+// base85Alphabet is the alphabet of the base85 encoding of git.
+const base85Alphabet = "0123456789" +
+	"ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz" +
+	"!#$%&()*+-;<=>?@^_`{|}~"
+
+var base85Values = func() (values [256]int) {
+	for i := range values {
+		values[i] = -1
+	}
+
+	for i := 0; i < len(base85Alphabet); i++ {
+		values[base85Alphabet[i]] = i
+	}
+
+	return values
+}()
+
+// decodeBase85 decodes the groups of 5 characters of s to 4 bytes each.
+func decodeBase85(s string) ([]byte, error) {
+	data := make([]byte, 0, len(s)/5*4)
+	for ; len(s) >= 5; s = s[5:] {
+		var acc uint64
+		for i := 0; i < 5; i++ {
+			v := base85Values[s[i]]
+			if v < 0 {
+				return nil, fmt.Errorf("invalid base85 character %q", s[i])
+			}
+
+			acc = acc*85 + uint64(v)
+		}
+
+		if acc > 0xffffffff {
+			return nil, fmt.Errorf("invalid base85 sequence %q", s[:5])
+		}
+
+		data = append(data, byte(acc>>24), byte(acc>>16), byte(acc>>8), byte(acc))
+	}
+
+	return data, nil
+}
+
+// parseGitDiffNames returns the paths of the "diff --git" line, without
+// their prefix. They are only known for sure when they are quoted or the
+// same, like for the mode changes, the other headers giving them otherwise.
+func parseGitDiffNames(names string) (oldPath, newPath string) {
+	if strings.HasPrefix(names, `"`) {
+		if quoted, err := strconv.QuotedPrefix(names); err == nil {
+			oldPath, _ = unquotePath(quoted)
+			newPath, _ = parsePatchName(strings.TrimPrefix(names[len(quoted):], " "))
+			return stripComponent(oldPath), newPath
+		}
+	}
+
+	if i := strings.Index(names, ` "`); i >= 0 {
+		newPath, _ = parsePatchName(names[i+1:])
+		return stripComponent(names[:i]), newPath
+	}
+
+	if len(names)%2 == 1 {
+		half := len(names) / 2
+		oldPath, newPath = stripComponent(names[:half]), stripComponent(names[half+1:])
+		if names[half] == ' ' && oldPath == newPath {
+			return oldPath, newPath
+		}
+	}
+
+	oldPath, newPath, _ = strings.Cut(names, " ")
+	return stripComponent(oldPath), stripComponent(newPath)
+}
+
+// parsePatchName returns the path of the name of a "---" or "+++" line,
+// without its prefix and the timestamp following its tab, if any.
+func parsePatchName(name string) (string, error) {
+	if strings.HasPrefix(name, `"`) {
+		quoted, err := strconv.QuotedPrefix(name)
+		if err != nil {
+			return "", err
+		}
+
+		name, err = unquotePath(quoted)
+		if err != nil {
+			return "", err
+		}
+	} else if i := strings.IndexByte(name, '\t'); i >= 0 {
+		name = name[:i]
+	}
+
+	return stripComponent(name), nil
+}
+
+// unquotePath returns the path of a header, unquoting it when it is quoted
+// like git does for the paths with special characters.
+func unquotePath(path string) (string, error) {
+	if !strings.HasPrefix(path, `"`) {
+		return path, nil
+	}
+
+	return strconv.Unquote(path)
+}
+
+// stripComponent removes the first component of the path, the prefix of the
+// names of the patches.
+func stripComponent(path string) string {
+	if _, rest, ok := strings.Cut(path, "/"); ok {
+		return rest
+	}
+
+	return path
+}
+
+// cutPrefix removes prefix from s and reports whether s starts with it.
+func cutPrefix(s *string, prefix string) bool {
+	rest, ok := strings.CutPrefix(*s, prefix)
+	if ok {
+		*s = rest
+	}
+
+	return ok
+}
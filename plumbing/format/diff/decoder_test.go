@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const binaryPatch = `diff --git a/a.bin b/a.bin
+index 8e5da76b24d9a89e507982011ad21f4c191580f6..319a97ece87b279588aa2d40273ab7a8c0899afb 100644
+GIT binary patch
+literal 18
+Zcmc~u&B@7UD9K1IN>wP&FUm>b0sues27dqm
+
+literal 12
+Tcmc~u&B@7UD9<m-N#Ozj9g+k` + "`" + `
+
+diff --git a/b.bin b/b.bin
+new file mode 100644
+index 0000000000000000000000000000000000000000..8352675d67aed6625ece79af41c27fdb4ee2e867
+GIT binary patch
+literal 3
+KcmZQzWC8#H2LJ>B
+
+literal 0
+HcmV?d00001
+
+`
+
+func TestDecode(t *testing.T) {
+	files, err := NewDecoder(strings.NewReader(`From 1234 Mon Sep 17 00:00:00 2001
+Subject: [PATCH] test
+
+---
+ a | 2 +-
+
+diff --git a/a b/a
+index 3e75765..d68dd40 100644
+--- a/a
++++ b/a
+@@ -1,3 +1,3 @@ func main
+ one
+
+-two
++TWO
+\ No newline at end of file
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+diff --git a/old b/new
+similarity index 90%
+rename from old
+rename to new
+index 1111111..2222222
+--- a/old
++++ b/new
+@@ -1 +1 @@
+-x
++y
+diff --git a/src "b/with space"
+similarity index 100%
+copy from src
+copy to with space
+diff --git "a/t\303\251st" "b/t\303\251st"
+deleted file mode 100644
+index 3333333..0000000
+--- "a/t\303\251st"
++++ /dev/null
+@@ -1 +0,0 @@
+-gone
+--
+2.39.5
+`)).Decode()
+	require.NoError(t, err)
+	require.Len(t, files, 5)
+
+	f := files[0]
+	assert.Equal(t, "a", f.OldPath)
+	assert.Equal(t, "a", f.NewPath)
+	assert.Equal(t, "3e75765", f.OldHash)
+	assert.Equal(t, "d68dd40", f.NewHash)
+	assert.Equal(t, filemode.Regular, f.OldMode)
+	assert.Equal(t, filemode.Regular, f.NewMode)
+	require.Len(t, f.Hunks, 1)
+	assert.Equal(t, &Hunk{
+		OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 3,
+		Section: "func main",
+		Lines: []HunkLine{
+			{Op: Equal, Text: "one\n"},
+			{Op: Equal, Text: "\n"},
+			{Op: Delete, Text: "two\n"},
+			{Op: Add, Text: "TWO"},
+		},
+	}, f.Hunks[0])
+
+	f = files[1]
+	assert.Equal(t, "run.sh", f.Path())
+	assert.Equal(t, filemode.Regular, f.OldMode)
+	assert.Equal(t, filemode.Executable, f.NewMode)
+	assert.Empty(t, f.Hunks)
+
+	f = files[2]
+	assert.True(t, f.IsRename())
+	assert.Equal(t, "old", f.OldPath)
+	assert.Equal(t, "new", f.NewPath)
+	assert.Equal(t, 90, f.Similarity)
+	assert.Len(t, f.Hunks, 1)
+
+	f = files[3]
+	assert.True(t, f.Copy)
+	assert.False(t, f.IsRename())
+	assert.Equal(t, "src", f.OldPath)
+	assert.Equal(t, "with space", f.NewPath)
+
+	f = files[4]
+	assert.True(t, f.IsDelete())
+	assert.Equal(t, "tést", f.OldPath)
+	assert.Equal(t, "", f.NewPath)
+	assert.Equal(t, "tést", f.Path())
+	require.Len(t, f.Hunks, 1)
+	assert.Equal(t, []HunkLine{{Op: Delete, Text: "gone\n"}}, f.Hunks[0].Lines)
+}
+
+func TestDecodeBinary(t *testing.T) {
+	files, err := NewDecoder(strings.NewReader(binaryPatch)).Decode()
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	f := files[0]
+	assert.True(t, f.Binary)
+	require.NotNil(t, f.BinaryHunk)
+	assert.Equal(t, BinaryLiteral, f.BinaryHunk.Type)
+	assert.Equal(t, []byte("hello\x00there world\n"), f.BinaryHunk.Data)
+	require.NotNil(t, f.ReverseBinaryHunk)
+	assert.Equal(t, []byte("hello\x00world\n"), f.ReverseBinaryHunk.Data)
+
+	f = files[1]
+	assert.True(t, f.IsNew())
+	assert.Equal(t, "b.bin", f.Path())
+	assert.Equal(t, []byte{0, 1, 2}, f.BinaryHunk.Data)
+	assert.Empty(t, f.ReverseBinaryHunk.Data)
+}
+
+func TestDecodeTraditional(t *testing.T) {
+	files, err := NewDecoder(strings.NewReader(`--- orig/dir/file.txt	2024-01-01 00:00:00.000000000 +0000
++++ new/dir/file.txt	2024-01-02 00:00:00.000000000 +0000
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`)).Decode()
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "dir/file.txt", files[0].OldPath)
+	assert.Equal(t, "dir/file.txt", files[0].NewPath)
+	require.Len(t, files[0].Hunks, 1)
+	assert.Len(t, files[0].Hunks[0].Lines, 3)
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	for _, patch := range []string{
+		"diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -1,2 +1,2 @@\n a\n",
+		"diff --git a/a b/a\n--- a/a\n+++ b/a\n@@ -x +1 @@\n",
+		"diff --git a/a b/a\nGIT binary patch\nliteral 3\n!!!\n\n",
+	} {
+		_, err := NewDecoder(strings.NewReader(patch)).Decode()
+		assert.ErrorIs(t, err, ErrCorruptPatch, patch)
+	}
+}
@@ -0,0 +1,312 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DefaultDirstatLimit is the default minimum share of the changes of the
+// directories shown by DirstatEncoder, in tenths of percent.
+const DefaultDirstatLimit = 30
+
+// DirstatMode is the way DirstatEncoder measures the changes of the files.
+type DirstatMode int
+
+const (
+	// DirstatChanges measures the bytes removed from and added to the
+	// files, the moved lines not counting, the default.
+	DirstatChanges DirstatMode = iota
+	// DirstatLines measures the removed and added lines, the binary files
+	// counting 64 bytes per line.
+	DirstatLines
+	// DirstatFiles counts the changed files.
+	DirstatFiles
+)
+
+// DirstatEncoder encodes the distribution of the changes of a patch among
+// the directories, like `git diff --dirstat`. The directories having at
+// least the limit of the changes are shown with their share, which
+// excludes the changes of their subdirectories already shown unless the
+// encoder is cumulative. The directories whose changes all come from a
+// single subdirectory are not shown.
+//
+// DirstatChanges measures the changes of the binary files in bytes, like
+// the ones of the text files, from their contents when their files are
+// ContentFiles. They are otherwise counted as entirely rewritten, from
+// their sizes when their files are SizedFiles.
+type DirstatEncoder struct {
+	io.Writer
+
+	// mode is the measure of the changes.
+	mode DirstatMode
+	// cumulative counts the changes of the subdirectories shown in their
+	// parents.
+	cumulative bool
+	// limit is the minimum share of the changes of the directories shown,
+	// in tenths of percent.
+	limit int
+}
+
+// NewDirstatEncoder returns a new DirstatEncoder that writes to w.
+func NewDirstatEncoder(w io.Writer) *DirstatEncoder {
+	return &DirstatEncoder{Writer: w, limit: DefaultDirstatLimit}
+}
+
+// SetMode sets the measure of the changes of e and returns e.
+func (e *DirstatEncoder) SetMode(mode DirstatMode) *DirstatEncoder {
+	e.mode = mode
+	return e
+}
+
+// SetCumulative sets whether e counts the changes of the subdirectories
+// shown in their parents, and returns e.
+func (e *DirstatEncoder) SetCumulative(cumulative bool) *DirstatEncoder {
+	e.cumulative = cumulative
+	return e
+}
+
+// SetLimit sets the minimum share of the changes of the directories shown
+// by e, in tenths of percent, and returns e.
+func (e *DirstatEncoder) SetLimit(permille int) *DirstatEncoder {
+	e.limit = permille
+	return e
+}
+
+// dirstatFile is the damage of a file, the measure of its changes.
+type dirstatFile struct {
+	path   string
+	damage int
+}
+
+// Encode encodes the dirstat of patch.
+func (e *DirstatEncoder) Encode(patch Patch) error {
+	var files []dirstatFile
+	if e.mode == DirstatLines {
+		for _, s := range patchStats(patch) {
+			damage := s.added + s.deleted
+			if s.binary {
+				// Binary files count their bytes, 64 per line.
+				damage = (damage + 63) / 64
+			}
+
+			files = append(files, dirstatFile{s.path, damage})
+		}
+	} else {
+		for _, fp := range patch.FilePatches() {
+			f, ok, err := e.fileDamage(fp)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				files = append(files, f)
+			}
+		}
+	}
+
+	total := 0
+	for _, f := range files {
+		total += f.damage
+	}
+
+	// Everything can be renames.
+	if total == 0 {
+		return nil
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	d := &dirstat{
+		sb:         &strings.Builder{},
+		files:      files,
+		total:      total,
+		limit:      e.limit,
+		cumulative: e.cumulative,
+	}
+
+	d.gather("")
+	_, err := io.WriteString(e, d.sb.String())
+	return err
+}
+
+// fileDamage returns the damage of the file patch fp, in the DirstatChanges
+// and DirstatFiles modes.
+func (e *DirstatEncoder) fileDamage(fp FilePatch) (dirstatFile, bool, error) {
+	from, to := fp.Files()
+	var f dirstatFile
+	switch {
+	case to != nil:
+		f.path = to.Path()
+	case from != nil:
+		f.path = from.Path()
+	default:
+		return f, false, nil
+	}
+
+	if from != nil && to != nil && from.Hash() == to.Hash() {
+		return f, true, nil
+	}
+
+	if e.mode == DirstatFiles {
+		f.damage = 1
+		return f, true, nil
+	}
+
+	var copied, added, size int
+	if fp.IsBinary() {
+		size, added = int(fileSize(from)), int(fileSize(to))
+		src, srcOk, err := fileContent(from)
+		if err != nil {
+			return f, false, err
+		}
+
+		dst, dstOk, err := fileContent(to)
+		if err != nil {
+			return f, false, err
+		}
+
+		if srcOk && dstOk {
+			size = len(src)
+			copied, added = countChanges(string(src), string(dst), false)
+		}
+	} else {
+		var src, dst strings.Builder
+		for _, c := range fp.Chunks() {
+			if c.Type() != Add {
+				src.WriteString(c.Content())
+			}
+
+			if c.Type() != Delete {
+				dst.WriteString(c.Content())
+			}
+		}
+
+		size = src.Len()
+		switch {
+		case from == nil:
+			added = dst.Len()
+		case to != nil:
+			copied, added = countChanges(src.String(), dst.String(), true)
+		}
+	}
+
+	// The removed and the added content are both damages to the old file,
+	// which has at least some since its hash changed.
+	f.damage = size - copied + added
+	if f.damage == 0 {
+		f.damage = 1
+	}
+
+	return f, true, nil
+}
+
+// fileContent returns the content of f, ok being false when it is not a
+// ContentFile. A missing file is empty.
+func fileContent(f File) (content []byte, ok bool, err error) {
+	if f == nil {
+		return nil, true, nil
+	}
+
+	cf, ok := f.(ContentFile)
+	if !ok {
+		return nil, false, nil
+	}
+
+	content, err = cf.Content()
+	return content, err == nil, err
+}
+
+// dirstat gathers the damages of the files, sorted by path, by directory.
+type dirstat struct {
+	sb         *strings.Builder
+	files      []dirstatFile
+	total      int
+	limit      int
+	cumulative bool
+}
+
+// gather consumes the files of the directory base, given with its trailing
+// slash, and returns the sum of their damages, writing base when it has
+// enough of them. The damages of a directory written are not counted in its
+// parents unless the dirstat is cumulative.
+func (d *dirstat) gather(base string) int {
+	sum, sources := 0, 0
+	for len(d.files) > 0 && strings.HasPrefix(d.files[0].path, base) {
+		f := d.files[0]
+		if i := strings.IndexByte(f.path[len(base):], '/'); i >= 0 {
+			sum += d.gather(f.path[:len(base)+i+1])
+			sources++
+			continue
+		}
+
+		sum += f.damage
+		sources += 2
+		d.files = d.files[1:]
+	}
+
+	// Neither the top level nor the directories whose changes all come from
+	// a single subdirectory are written.
+	if base == "" || sources == 1 || sum == 0 {
+		return sum
+	}
+
+	permille := sum * 1000 / d.total
+	if permille < d.limit {
+		return sum
+	}
+
+	fmt.Fprintf(d.sb, "%4d.%01d%% %s\n", permille/10, permille%10, base)
+	if d.cumulative {
+		return sum
+	}
+
+	return 0
+}
+
+// spanHashBase is the modulo of the hashes of the spans of the contents.
+const spanHashBase = 107927
+
+// countChanges returns the number of bytes of src copied to dst and of
+// bytes added to dst, their contents being compared by spans of lines of at
+// most 64 bytes, like git. The CRs before the line feeds are ignored in
+// text contents.
+func countChanges(src, dst string, text bool) (copied, added int) {
+	srcSpans, dstSpans := spanHashes(src, text), spanHashes(dst, text)
+	for h, dstCnt := range dstSpans {
+		srcCnt := srcSpans[h]
+		copied += min(srcCnt, dstCnt)
+		added += max(dstCnt-srcCnt, 0)
+	}
+
+	return copied, added
+}
+
+// spanHashes returns the number of bytes of the content in the spans of
+// each hash. The spans end at the line feeds or after 64 bytes, the CRs
+// before the line feeds being ignored in text contents. Like git, the bytes
+// after the last span are not counted.
+func spanHashes(content string, text bool) map[uint32]int {
+	spans := make(map[uint32]int)
+	var accum1, accum2 uint32
+	n := 0
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		if text && c == '\r' && i+1 < len(content) && content[i+1] == '\n' {
+			continue
+		}
+
+		old1 := accum1
+		accum1 = accum1<<7 ^ accum2>>25
+		accum2 = accum2<<7 ^ old1>>25
+		accum1 += uint32(c)
+		if n++; n < 64 && c != '\n' {
+			continue
+		}
+
+		spans[(accum1+accum2*0x61)%spanHashBase] += n
+		accum1, accum2, n = 0, 0, 0
+	}
+
+	return spans
+}
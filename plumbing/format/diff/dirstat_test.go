@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dirstatPatch Patch = testPatch{filePatches: []testFilePatch{
+	{
+		from:   &testFile{mode: filemode.Regular, path: "a/b/one.txt", seed: "a"},
+		to:     &testFile{mode: filemode.Regular, path: "a/b/one.txt", seed: "b"},
+		chunks: []testChunk{{content: "x\n", op: Equal}, {content: "y\n", op: Delete}, {content: "z\n", op: Add}},
+	},
+	{
+		to:     &testFile{mode: filemode.Regular, path: "a/two.txt", seed: "c"},
+		chunks: []testChunk{{content: "1\n2\n3\n", op: Add}},
+	},
+	{
+		from:   &testFile{mode: filemode.Regular, path: "c/three.txt", seed: "d"},
+		chunks: []testChunk{{content: "q\n", op: Delete}},
+	},
+	{
+		from:   &testFile{mode: filemode.Regular, path: "c/old.txt", seed: "e"},
+		to:     &testFile{mode: filemode.Regular, path: "d/new.txt", seed: "e"},
+		chunks: []testChunk{{content: "e\n", op: Equal}},
+	},
+}}
+
+func TestDirstatEncoder(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		mode       DirstatMode
+		cumulative bool
+		limit      int
+		expected   string
+	}{
+		{"changes", DirstatChanges, false, DefaultDirstatLimit, "  33.3% a/b/\n  50.0% a/\n  16.6% c/\n"},
+		{"lines", DirstatLines, false, DefaultDirstatLimit, "  33.3% a/b/\n  50.0% a/\n  16.6% c/\n"},
+		{"files", DirstatFiles, false, DefaultDirstatLimit, "  33.3% a/b/\n  33.3% a/\n  33.3% c/\n"},
+		{"limit", DirstatLines, false, 200, "  33.3% a/b/\n  50.0% a/\n"},
+		{"cumulative lines", DirstatLines, true, 0, "  33.3% a/b/\n  83.3% a/\n  16.6% c/\n"},
+		{"cumulative files", DirstatFiles, true, 0, "  33.3% a/b/\n  66.6% a/\n  33.3% c/\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewDirstatEncoder(&buf).SetMode(tc.mode).SetCumulative(tc.cumulative).SetLimit(tc.limit)
+			require.NoError(t, e.Encode(dirstatPatch))
+			assert.Equal(t, tc.expected, buf.String())
+		})
+	}
+}
+
+func TestDirstatEncoderBinary(t *testing.T) {
+	var buf bytes.Buffer
+	// The binary files are counted by their bytes, like git does.
+	require.NoError(t, NewDirstatEncoder(&buf).SetLimit(0).Encode(sizedPatch))
+	assert.Equal(t, "  69.6% d/\n", buf.String())
+
+	// They are counted as entirely rewritten when their contents are unknown.
+	buf.Reset()
+	var sized filePatches
+	for _, fp := range sizedPatch {
+		if bp, ok := fp.(binaryFilePatch); ok {
+			fp = sizedOnlyFilePatch{bp}
+		}
+
+		sized = append(sized, fp)
+	}
+
+	require.NoError(t, NewDirstatEncoder(&buf).SetLimit(0).Encode(sized))
+	assert.Equal(t, "  90.9% d/\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewDirstatEncoder(&buf).SetMode(DirstatLines).Encode(sizedPatch))
+	assert.Equal(t, "  91.0% d/\n", buf.String())
+}
+
+// sizedOnlyFilePatch is a binaryFilePatch whose files only know their
+// sizes.
+type sizedOnlyFilePatch struct {
+	binaryFilePatch
+}
+
+func (p sizedOnlyFilePatch) Files() (File, File) {
+	from, to := p.binaryFilePatch.Files()
+	if from != nil {
+		from = sizedOnlyFile{from.(SizedFile)}
+	}
+
+	if to != nil {
+		to = sizedOnlyFile{to.(SizedFile)}
+	}
+
+	return from, to
+}
+
+type sizedOnlyFile struct {
+	SizedFile
+}
+
+func TestCountChanges(t *testing.T) {
+	copied, added := countChanges("a\nb\n", "b\na\n", true)
+	assert.Equal(t, 4, copied)
+	assert.Equal(t, 0, added)
+
+	copied, added = countChanges("a\r\nb\n", "a\nc\n", true)
+	assert.Equal(t, 2, copied)
+	assert.Equal(t, 2, added)
+
+	// The bytes after the last line feed are not counted.
+	copied, added = countChanges("a\nb", "a\nc", true)
+	assert.Equal(t, 2, copied)
+	assert.Equal(t, 0, added)
+
+	copied, added = countChanges("a\r\n", "a\n", false)
+	assert.Equal(t, 0, copied)
+	assert.Equal(t, 2, added)
+}
@@ -0,0 +1,115 @@
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// funcLineSize is the maximum length of the function lines of the hunk
+// headers.
+const funcLineSize = 80
+
+var (
+	// ErrInvalidFuncName is returned by ParseFuncName for invalid patterns.
+	ErrInvalidFuncName = errors.New("invalid regexp to look for hunk header")
+)
+
+// Driver is a diff driver, selected for a path by its diff attribute, which
+// customizes the hunk headers and the word diff of its changes, like the
+// diff.<driver> sections of git's config.
+type Driver struct {
+	// FuncName finds the function lines. A nil FuncName uses git's
+	// default.
+	FuncName *FuncName
+	// WordRegex matches the words of the word diff. A nil WordRegex
+	// splits the lines on whitespace.
+	WordRegex *regexp.Regexp
+}
+
+// FuncName finds the function lines, shown in the hunk headers and used to
+// extend the context of the changes to the whole functions.
+type FuncName struct {
+	patterns []funcNamePattern
+}
+
+type funcNamePattern struct {
+	re     *regexp.Regexp
+	negate bool
+}
+
+// ParseFuncName parses the value of the diff.<driver>.xfuncname config
+// option: regular expressions separated by new lines, the first one
+// matching a line deciding whether it is a function line. Expressions
+// starting with '!' reject the lines they match; the last one cannot. The
+// function line shown is the first group of the expression, or the whole
+// match.
+func ParseFuncName(s string) (*FuncName, error) {
+	f := &FuncName{}
+	exprs := strings.Split(s, "\n")
+	for i, expr := range exprs {
+		p := funcNamePattern{}
+		expr, p.negate = strings.CutPrefix(expr, "!")
+		if p.negate && i == len(exprs)-1 {
+			return nil, fmt.Errorf("%w: last expression must not be negated: %s", ErrInvalidFuncName, s)
+		}
+
+		var err error
+		if p.re, err = regexp.Compile(expr); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFuncName, err)
+		}
+
+		f.patterns = append(f.patterns, p)
+	}
+
+	return f, nil
+}
+
+// Match reports whether the line is a function line, returning the text
+// shown in hunk headers. A nil FuncName matches, like git by default, the
+// lines starting with a letter, '_' or '$'.
+func (f *FuncName) Match(line string) (string, bool) {
+	if f == nil {
+		if line == "" || !isFuncNameStart(line[0]) {
+			return "", false
+		}
+
+		return trimFuncLine(line), true
+	}
+
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	for _, p := range f.patterns {
+		m := p.re.FindStringSubmatchIndex(line)
+		if m == nil {
+			continue
+		}
+
+		if p.negate {
+			return "", false
+		}
+
+		if len(m) >= 4 && m[2] >= 0 {
+			return trimFuncLine(line[m[2]:m[3]]), true
+		}
+
+		return trimFuncLine(line[m[0]:m[1]]), true
+	}
+
+	return "", false
+}
+
+func isFuncNameStart(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_' || c == '$'
+}
+
+// trimFuncLine truncates the function line to the size git shows and
+// trims its trailing whitespace.
+func trimFuncLine(line string) string {
+	if len(line) > funcLineSize {
+		line = line[:funcLineSize]
+	}
+
+	return strings.TrimRight(line, " \t\n\r")
+}
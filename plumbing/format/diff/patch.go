@@ -49,6 +49,43 @@ type File interface {
 	Path() string
 }
 
+// SizedFile is a File knowing the size of its content, which the diffstats
+// report for the binary files.
+type SizedFile interface {
+	File
+	// Size returns the size of the content of the file, in bytes.
+	Size() int64
+}
+
+// ContentFile is a File able to return its content, which the binary
+// patches of the UnifiedEncoder are made of.
+type ContentFile interface {
+	File
+	// Content returns the content of the file.
+	Content() ([]byte, error)
+}
+
+// SimilarFilePatch is a FilePatch knowing the similarity of its files, when
+// it is a rename or a copy, which the summaries report.
+type SimilarFilePatch interface {
+	FilePatch
+	// Similarity returns the similarity of the files of a rename or a copy,
+	// from 1 to 100, or 0 when it is unknown.
+	Similarity() int
+	// IsCopy reports whether the patch is a copy, its source being kept.
+	IsCopy() bool
+}
+
+// RewriteFilePatch is a FilePatch knowing the dissimilarity of its files,
+// when it is a complete rewrite of a file, which the unified diffs and the
+// summaries report.
+type RewriteFilePatch interface {
+	FilePatch
+	// Dissimilarity returns the dissimilarity of the files of a rewrite,
+	// from 1 to 100, or 0 when the patch is not a rewrite.
+	Dissimilarity() int
+}
+
 // Chunk represents a portion of a file transformation into another.
 type Chunk interface {
 	// Content contains the portion of the file.
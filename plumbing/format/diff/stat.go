@@ -0,0 +1,601 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultStatWidth is the default width of the diffstat.
+const DefaultStatWidth = 80
+
+// StatEncoder encodes the diffstat of a patch, like `git diff --stat`, its
+// graph scaled to the width of the encoder. Binary files are reported as
+// "Bin", with their sizes when their files are SizedFiles.
+type StatEncoder struct {
+	io.Writer
+
+	// width is the maximum width of the lines.
+	width int
+	// color is the color configuration. The default is no color.
+	color ColorConfig
+}
+
+// NewStatEncoder returns a new StatEncoder that writes to w.
+func NewStatEncoder(w io.Writer) *StatEncoder {
+	return &StatEncoder{Writer: w, width: DefaultStatWidth}
+}
+
+// SetWidth sets the maximum width of the lines of e and returns e.
+func (e *StatEncoder) SetWidth(width int) *StatEncoder {
+	e.width = width
+	return e
+}
+
+// SetColor sets e's color configuration and returns e.
+func (e *StatEncoder) SetColor(colorConfig ColorConfig) *StatEncoder {
+	e.color = colorConfig
+	return e
+}
+
+// fileStat is the stat of a file patch. The added and deleted counts of
+// binary files are the sizes of their new and old contents, when they are
+// known and differ.
+type fileStat struct {
+	// name is the name shown, path the path of the file after the patch.
+	name, path     string
+	added, deleted int
+	binary         bool
+}
+
+// Encode encodes the diffstat of patch, nothing when it has no file
+// patches. The files are ordered by their destination path, see
+// sortedFilePatches.
+func (e *StatEncoder) Encode(patch Patch) error {
+	stats := patchStats(patch)
+	if len(stats) == 0 {
+		return nil
+	}
+
+	maxLen, maxChange, numberWidth, binWidth := 0, 0, 0, 0
+	for _, s := range stats {
+		if l := utf8.RuneCountInString(s.name); l > maxLen {
+			maxLen = l
+		}
+
+		if s.binary {
+			// "Bin XXX -> YYY bytes"
+			w := 14 + decimalWidth(s.added) + decimalWidth(s.deleted)
+			if w > binWidth {
+				binWidth = w
+			}
+
+			numberWidth = 3
+			continue
+		}
+
+		if c := s.added + s.deleted; c > maxChange {
+			maxChange = c
+		}
+	}
+
+	width := e.width
+	if w := decimalWidth(maxChange); w > numberWidth {
+		numberWidth = w
+	}
+
+	// Guarantee 3/8*16==6 for the graph part and 5/8*16==10 for the
+	// filename part.
+	if width < 16+6+numberWidth {
+		width = 16 + 6 + numberWidth
+	}
+
+	graphWidth := maxChange
+	if maxChange+4 <= binWidth {
+		graphWidth = binWidth - 4
+	}
+
+	nameWidth := maxLen
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = width*3/8 - numberWidth - 6
+			if graphWidth < 6 {
+				graphWidth = 6
+			}
+		}
+
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	sb := &strings.Builder{}
+	adds, dels := 0, 0
+	for _, s := range stats {
+		prefix, name := "", s.name
+		length := nameWidth
+		if nameLen := utf8.RuneCountInString(name); nameWidth < nameLen {
+			prefix = "..."
+			length -= 3
+			if length < 0 {
+				length = 0
+			}
+
+			for nameLen > length {
+				_, size := utf8.DecodeRuneInString(name)
+				name = name[size:]
+				nameLen--
+			}
+
+			if i := strings.IndexByte(name, '/'); i >= 0 {
+				name = name[i:]
+			}
+		}
+
+		padding := length - utf8.RuneCountInString(name)
+		if padding < 0 {
+			padding = 0
+		}
+
+		fmt.Fprintf(sb, " %s%s%*s | ", prefix, name, padding, "")
+		if s.binary {
+			fmt.Fprintf(sb, "%*s", numberWidth, "Bin")
+			if s.added != 0 || s.deleted != 0 {
+				fmt.Fprintf(sb, " %s%d%s -> %s%d%s bytes",
+					e.color[Old], s.deleted, e.color.Reset(Old),
+					e.color[New], s.added, e.color.Reset(New))
+			}
+
+			sb.WriteByte('\n')
+			continue
+		}
+
+		adds += s.added
+		dels += s.deleted
+
+		add, del := s.added, s.deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(add+del, graphWidth, maxChange)
+			if total < 2 && add != 0 && del != 0 {
+				total = 2
+			}
+
+			if add < del {
+				add = scaleLinear(add, graphWidth, maxChange)
+				del = total - add
+			} else {
+				del = scaleLinear(del, graphWidth, maxChange)
+				add = total - del
+			}
+		}
+
+		fmt.Fprintf(sb, "%*d", numberWidth, s.added+s.deleted)
+		if s.added+s.deleted != 0 {
+			sb.WriteByte(' ')
+		}
+
+		e.writeGraph(sb, '+', add, New)
+		e.writeGraph(sb, '-', del, Old)
+		sb.WriteByte('\n')
+	}
+
+	writeStatSummary(sb, len(stats), adds, dels)
+	_, err := io.WriteString(e, sb.String())
+	return err
+}
+
+func (e *StatEncoder) writeGraph(sb *strings.Builder, c byte, n int, key ColorKey) {
+	if n <= 0 {
+		return
+	}
+
+	sb.WriteString(e.color[key])
+	sb.WriteString(strings.Repeat(string(c), n))
+	sb.WriteString(e.color.Reset(key))
+}
+
+// writeStatSummary writes the line summarizing the diffstat.
+func writeStatSummary(sb *strings.Builder, files, insertions, deletions int) {
+	if files == 0 {
+		sb.WriteString(" 0 files changed\n")
+		return
+	}
+
+	fmt.Fprintf(sb, " %d %s changed", files, plural(files, "file", "files"))
+	if insertions != 0 || deletions == 0 {
+		fmt.Fprintf(sb, ", %d %s(+)", insertions, plural(insertions, "insertion", "insertions"))
+	}
+
+	if deletions != 0 || insertions == 0 {
+		fmt.Fprintf(sb, ", %d %s(-)", deletions, plural(deletions, "deletion", "deletions"))
+	}
+
+	sb.WriteByte('\n')
+}
+
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+
+	return plural
+}
+
+// scaleLinear scales it to width, making sure that at least one column is
+// used for any change.
+func scaleLinear(it, width, maxChange int) int {
+	if it == 0 {
+		return 0
+	}
+
+	return 1 + it*(width-1)/maxChange
+}
+
+func decimalWidth(n int) int {
+	return len(strconv.Itoa(n))
+}
+
+// patchStats returns the stats of the file patches of patch, in the order
+// of sortedFilePatches.
+func patchStats(patch Patch) []fileStat {
+	var stats []fileStat
+	for _, fp := range sortedFilePatches(patch) {
+		from, to := fp.Files()
+		if from == nil && to == nil {
+			continue
+		}
+
+		s := fileStat{binary: fp.IsBinary()}
+		switch {
+		case from == nil:
+			s.name, s.path = QuotePath(to.Path()), to.Path()
+		case to == nil:
+			s.name, s.path = QuotePath(from.Path()), from.Path()
+		default:
+			s.name, s.path = renameName(from.Path(), to.Path()), to.Path()
+		}
+
+		if s.binary {
+			if from == nil || to == nil || from.Hash() != to.Hash() {
+				s.added, s.deleted = int(fileSize(to)), int(fileSize(from))
+			}
+
+			stats = append(stats, s)
+			continue
+		}
+
+		for _, c := range fp.Chunks() {
+			switch c.Type() {
+			case Add:
+				s.added += countLines(c.Content())
+			case Delete:
+				s.deleted += countLines(c.Content())
+			}
+		}
+
+		stats = append(stats, s)
+	}
+
+	return stats
+}
+
+// sortedFilePatches returns the file patches of patch ordered by their
+// destination path, the deletions by their source path, as git orders the
+// renamed and copied files among the others.
+func sortedFilePatches(patch Patch) []FilePatch {
+	fps := append([]FilePatch(nil), patch.FilePatches()...)
+	sort.SliceStable(fps, func(i, j int) bool {
+		return filePatchPath(fps[i]) < filePatchPath(fps[j])
+	})
+
+	return fps
+}
+
+// filePatchPath returns the destination path of fp, its source path when
+// it is a deletion.
+func filePatchPath(fp FilePatch) string {
+	from, to := fp.Files()
+	switch {
+	case to != nil:
+		return to.Path()
+	case from != nil:
+		return from.Path()
+	}
+
+	return ""
+}
+
+// fileSize returns the size of f, 0 when it is nil or not a SizedFile.
+func fileSize(f File) int64 {
+	if sf, ok := f.(SizedFile); ok {
+		return sf.Size()
+	}
+
+	return 0
+}
+
+func countLines(s string) int {
+	n := strings.Count(s, "\n")
+	if s != "" && !strings.HasSuffix(s, "\n") {
+		n++
+	}
+
+	return n
+}
+
+// renameName returns the name of a file patch in the diffstat, the common
+// prefix and suffix of the paths of a rename being factored out, like
+// "dir/{old => new}/file".
+func renameName(a, b string) string {
+	if a == b {
+		return QuotePath(a)
+	}
+
+	if needsQuoting(a) || needsQuoting(b) {
+		return QuotePath(a) + " => " + QuotePath(b)
+	}
+
+	pfx := 0
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			pfx = i + 1
+		}
+	}
+
+	// The common suffix is searched from the terminating NULs, up to the
+	// slash ending the common prefix when there is one.
+	at := func(s string, i int) int {
+		if i == len(s) {
+			return 0
+		}
+
+		return int(s[i])
+	}
+
+	adjust := 0
+	if pfx > 0 {
+		adjust = 1
+	}
+
+	sfx := 0
+	for i, j := len(a), len(b); pfx-adjust <= i && pfx-adjust <= j && at(a, i) == at(b, j); i, j = i-1, j-1 {
+		if at(a, i) == '/' {
+			sfx = len(a) - i
+		}
+
+		if i == 0 || j == 0 {
+			break
+		}
+	}
+
+	aMid := len(a) - pfx - sfx
+	bMid := len(b) - pfx - sfx
+	if aMid < 0 {
+		aMid = 0
+	}
+
+	if bMid < 0 {
+		bMid = 0
+	}
+
+	var sb strings.Builder
+	if pfx+sfx > 0 {
+		sb.WriteString(a[:pfx])
+		sb.WriteByte('{')
+	}
+
+	sb.WriteString(a[pfx : pfx+aMid])
+	sb.WriteString(" => ")
+	sb.WriteString(b[pfx : pfx+bMid])
+	if pfx+sfx > 0 {
+		sb.WriteByte('}')
+		sb.WriteString(a[len(a)-sfx:])
+	}
+
+	return sb.String()
+}
+
+// needsQuoting reports whether the path is quoted by git, with the default
+// core.quotePath.
+func needsQuoting(path string) bool {
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; c < 0x20 || c == '"' || c == '\\' || c >= 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QuotePath returns the path quoted like git does with the default
+// core.quotePath, when needed.
+func QuotePath(path string) string {
+	if !needsQuoting(path) {
+		return path
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; c {
+		case '\a':
+			sb.WriteString(`\a`)
+		case '\b':
+			sb.WriteString(`\b`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\v':
+			sb.WriteString(`\v`)
+		case '\f':
+			sb.WriteString(`\f`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '"':
+			sb.WriteString(`\"`)
+		case '\\':
+			sb.WriteString(`\\`)
+		default:
+			if c < 0x20 || c >= 0x7f {
+				fmt.Fprintf(&sb, `\%03o`, c)
+			} else {
+				sb.WriteByte(c)
+			}
+		}
+	}
+
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// NumstatEncoder encodes the numbers of added and deleted lines of the files
+// of a patch, like `git diff --numstat`, binary files having "-" instead.
+type NumstatEncoder struct {
+	io.Writer
+}
+
+// NewNumstatEncoder returns a new NumstatEncoder that writes to w.
+func NewNumstatEncoder(w io.Writer) *NumstatEncoder {
+	return &NumstatEncoder{Writer: w}
+}
+
+// Encode encodes the numstat of patch, its files ordered like the diffstat.
+func (e *NumstatEncoder) Encode(patch Patch) error {
+	sb := &strings.Builder{}
+	for _, s := range patchStats(patch) {
+		if s.binary {
+			fmt.Fprintf(sb, "-\t-\t%s\n", s.name)
+			continue
+		}
+
+		fmt.Fprintf(sb, "%d\t%d\t%s\n", s.added, s.deleted, s.name)
+	}
+
+	_, err := io.WriteString(e, sb.String())
+	return err
+}
+
+// ShortstatEncoder encodes the last line of the diffstat of a patch, like
+// `git diff --shortstat`, the counts of changed files and of inserted and
+// deleted lines.
+type ShortstatEncoder struct {
+	io.Writer
+}
+
+// NewShortstatEncoder returns a new ShortstatEncoder that writes to w.
+func NewShortstatEncoder(w io.Writer) *ShortstatEncoder {
+	return &ShortstatEncoder{Writer: w}
+}
+
+// Encode encodes the shortstat of patch, nothing when it has no file
+// patches.
+func (e *ShortstatEncoder) Encode(patch Patch) error {
+	stats := patchStats(patch)
+	if len(stats) == 0 {
+		return nil
+	}
+
+	adds, dels := 0, 0
+	for _, s := range stats {
+		if !s.binary {
+			adds += s.added
+			dels += s.deleted
+		}
+	}
+
+	sb := &strings.Builder{}
+	writeStatSummary(sb, len(stats), adds, dels)
+	_, err := io.WriteString(e, sb.String())
+	return err
+}
+
+// SummaryEncoder encodes the summary of the creations, deletions, renames,
+// copies, rewrites and mode changes of a patch, like `git diff --summary`.
+// The similarity of the renames and copies is shown when known, from the
+// SimilarFilePatches or for the exact ones, and the dissimilarity of the
+// rewrites from the RewriteFilePatches.
+type SummaryEncoder struct {
+	io.Writer
+}
+
+// NewSummaryEncoder returns a new SummaryEncoder that writes to w.
+func NewSummaryEncoder(w io.Writer) *SummaryEncoder {
+	return &SummaryEncoder{Writer: w}
+}
+
+// Encode encodes the summary of patch, its files ordered like the diffstat.
+func (e *SummaryEncoder) Encode(patch Patch) error {
+	sb := &strings.Builder{}
+	for _, fp := range sortedFilePatches(patch) {
+		from, to := fp.Files()
+		switch {
+		case from == nil && to == nil:
+		case from == nil:
+			fmt.Fprintf(sb, " create mode %06o %s\n", uint32(to.Mode()), QuotePath(to.Path()))
+		case to == nil:
+			fmt.Fprintf(sb, " delete mode %06o %s\n", uint32(from.Mode()), QuotePath(from.Path()))
+		default:
+			name := QuotePath(to.Path())
+			if from.Path() != to.Path() {
+				action, similarity := renameSimilarity(fp)
+				fmt.Fprintf(sb, " %s %s", action, renameName(from.Path(), to.Path()))
+				if similarity != 0 {
+					fmt.Fprintf(sb, " (%d%%)", similarity)
+				}
+
+				sb.WriteByte('\n')
+				name = ""
+			} else if dissimilarity := rewriteDissimilarity(fp); dissimilarity != 0 {
+				fmt.Fprintf(sb, " rewrite %s (%d%%)\n", name, dissimilarity)
+				name = ""
+			}
+
+			if from.Mode() != to.Mode() {
+				fmt.Fprintf(sb, " mode change %06o => %06o", uint32(from.Mode()), uint32(to.Mode()))
+				if name != "" {
+					sb.WriteString(" " + name)
+				}
+
+				sb.WriteByte('\n')
+			}
+		}
+	}
+
+	_, err := io.WriteString(e, sb.String())
+	return err
+}
+
+// renameSimilarity returns the action, "rename" or "copy", of the file patch
+// fp of a file whose path changed, and the similarity of its files, known
+// from SimilarFilePatch or for the exact ones, 0 otherwise.
+func renameSimilarity(fp FilePatch) (action string, similarity int) {
+	action = "rename"
+	if sfp, ok := fp.(SimilarFilePatch); ok {
+		similarity = sfp.Similarity()
+		if sfp.IsCopy() {
+			action = "copy"
+		}
+	}
+
+	if from, to := fp.Files(); similarity == 0 && from.Hash() == to.Hash() {
+		similarity = 100
+	}
+
+	return action, similarity
+}
+
+// rewriteDissimilarity returns the dissimilarity of the files of the file
+// patch fp of a rewrite, known from RewriteFilePatch, 0 otherwise.
+func rewriteDissimilarity(fp FilePatch) int {
+	if rfp, ok := fp.(RewriteFilePatch); ok {
+		return rfp.Dissimilarity()
+	}
+
+	return 0
+}
@@ -0,0 +1,299 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var statPatch Patch = testPatch{filePatches: []testFilePatch{
+	{
+		from: &testFile{mode: filemode.Regular, path: "image.png", seed: "a"},
+		to:   &testFile{mode: filemode.Regular, path: "image.png", seed: "b"},
+	},
+	{
+		to:     &testFile{mode: filemode.Executable, path: "run.sh", seed: "run"},
+		chunks: []testChunk{{content: strings.Repeat("x\n", 100), op: Add}},
+	},
+	{
+		from:   &testFile{mode: filemode.Regular, path: "old.txt", seed: "old"},
+		chunks: []testChunk{{content: "a\nb\n", op: Delete}},
+	},
+}}
+
+func TestStatEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewStatEncoder(&buf).Encode(oneChunkPatch))
+	assert.Equal(t, ""+
+		" onechunk.txt | 4 ----\n"+
+		" 1 file changed, 4 deletions(-)\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewStatEncoder(&buf).SetWidth(40).Encode(statPatch))
+	assert.Equal(t, ""+
+		" image.png | Bin\n"+
+		" old.txt   |   2 -\n"+
+		" run.sh    | 100 ++++++++++++++++++++++\n"+
+		" 3 files changed, 100 insertions(+), 2 deletions(-)\n", buf.String())
+}
+
+func TestStatEncoderTruncatesNames(t *testing.T) {
+	p := testPatch{filePatches: []testFilePatch{{
+		to:     &testFile{mode: filemode.Regular, path: "a/very/long/path/to/some/deeply/nested/file.txt", seed: "f"},
+		chunks: []testChunk{{content: "x\n", op: Add}},
+	}}}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewStatEncoder(&buf).SetWidth(30).Encode(p))
+	assert.Equal(t, ""+
+		" .../nested/file.txt    | 1 +\n"+
+		" 1 file changed, 1 insertion(+)\n", buf.String())
+}
+
+func TestSummaryEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewSummaryEncoder(&buf).Encode(statPatch))
+	assert.Equal(t, ""+
+		" delete mode 100644 old.txt\n"+
+		" create mode 100755 run.sh\n", buf.String())
+
+	p := testPatch{filePatches: []testFilePatch{
+		{
+			from:   &testFile{mode: filemode.Regular, path: "run.sh", seed: "a"},
+			to:     &testFile{mode: filemode.Executable, path: "run.sh", seed: "a"},
+			chunks: []testChunk{{content: "a\n", op: Equal}},
+		},
+		{
+			from:   &testFile{mode: filemode.Regular, path: "dir/old.txt", seed: "b"},
+			to:     &testFile{mode: filemode.Regular, path: "dir/new.txt", seed: "b"},
+			chunks: []testChunk{{content: "b\n", op: Equal}},
+		},
+	}}
+
+	buf.Reset()
+	require.NoError(t, NewSummaryEncoder(&buf).Encode(p))
+	assert.Equal(t, ""+
+		" rename dir/{old.txt => new.txt} (100%)\n"+
+		" mode change 100644 => 100755 run.sh\n", buf.String())
+}
+
+// filePatches is a Patch of arbitrary file patches.
+type filePatches []FilePatch
+
+func (p filePatches) FilePatches() []FilePatch { return p }
+func (p filePatches) Message() string          { return "" }
+
+// sizedFile is a testFile knowing its size.
+type sizedFile struct {
+	testFile
+	size int64
+}
+
+func (f *sizedFile) Size() int64 { return f.size }
+
+// binaryFilePatch is the patch of a binary file with known sizes.
+type binaryFilePatch struct {
+	from, to *sizedFile
+}
+
+func (p binaryFilePatch) IsBinary() bool  { return true }
+func (p binaryFilePatch) Chunks() []Chunk { return nil }
+
+func (p binaryFilePatch) Files() (File, File) {
+	switch {
+	case p.from == nil:
+		return nil, p.to
+	case p.to == nil:
+		return p.from, nil
+	}
+
+	return p.from, p.to
+}
+
+// similarFilePatch is a testFilePatch knowing the similarity of its files.
+type similarFilePatch struct {
+	testFilePatch
+	similarity int
+	copy       bool
+}
+
+func (p similarFilePatch) Similarity() int { return p.similarity }
+func (p similarFilePatch) IsCopy() bool    { return p.copy }
+
+// rewriteFilePatch is a testFilePatch knowing the dissimilarity of its
+// files.
+type rewriteFilePatch struct {
+	testFilePatch
+	dissimilarity int
+}
+
+func (p rewriteFilePatch) Dissimilarity() int { return p.dissimilarity }
+
+var sizedPatch = filePatches{
+	binaryFilePatch{
+		from: &sizedFile{testFile{mode: filemode.Regular, path: "d/bin.dat", seed: "\x00" + strings.Repeat("0123456789", 300)[:2999]}, 3000},
+		to:   &sizedFile{testFile{mode: filemode.Regular, path: "d/bin.dat", seed: "\x00" + strings.Repeat("abcdefghij", 200)[:1999]}, 2000},
+	},
+	binaryFilePatch{
+		from: &sizedFile{testFile{mode: filemode.Regular, path: "img.png", seed: "\x00" + strings.Repeat("c", 499)}, 500},
+	},
+	binaryFilePatch{
+		from: &sizedFile{testFile{mode: filemode.Regular, path: "old.png", seed: "d"}, 42},
+		to:   &sizedFile{testFile{mode: filemode.Regular, path: "new.png", seed: "d"}, 42},
+	},
+	testFilePatch{
+		from:   &testFile{mode: filemode.Regular, path: "d/three.txt", seed: "e"},
+		to:     &testFile{mode: filemode.Regular, path: "d/three.txt", seed: "f"},
+		chunks: []testChunk{{content: "a\n", op: Equal}, {content: "b\nc\n", op: Add}},
+	},
+}
+
+func TestStatEncoderBinarySizes(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewStatEncoder(&buf).Encode(sizedPatch))
+	assert.Equal(t, ""+
+		" d/bin.dat          | Bin 3000 -> 2000 bytes\n"+
+		" d/three.txt        |   2 ++\n"+
+		" img.png            | Bin 500 -> 0 bytes\n"+
+		" old.png => new.png | Bin\n"+
+		" 4 files changed, 2 insertions(+)\n", buf.String())
+}
+
+func TestNumstatEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewNumstatEncoder(&buf).Encode(statPatch))
+	assert.Equal(t, ""+
+		"-\t-\timage.png\n"+
+		"0\t2\told.txt\n"+
+		"100\t0\trun.sh\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewNumstatEncoder(&buf).Encode(sizedPatch))
+	assert.Equal(t, ""+
+		"-\t-\td/bin.dat\n"+
+		"2\t0\td/three.txt\n"+
+		"-\t-\timg.png\n"+
+		"-\t-\told.png => new.png\n", buf.String())
+}
+
+func TestShortstatEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, NewShortstatEncoder(&buf).Encode(statPatch))
+	assert.Equal(t, " 3 files changed, 100 insertions(+), 2 deletions(-)\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewShortstatEncoder(&buf).Encode(sizedPatch))
+	assert.Equal(t, " 4 files changed, 2 insertions(+)\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewShortstatEncoder(&buf).Encode(filePatches{}))
+	assert.Equal(t, "", buf.String())
+}
+
+func TestSummaryEncoderSimilarity(t *testing.T) {
+	p := filePatches{
+		similarFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "d/three.txt", seed: "a"},
+				to:     &testFile{mode: filemode.Executable, path: "d/three2.txt", seed: "b"},
+				chunks: []testChunk{{content: "a\n", op: Equal}, {content: "b\n", op: Delete}},
+			},
+			similarity: 89,
+		},
+		similarFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "a.txt", seed: "c"},
+				to:     &testFile{mode: filemode.Regular, path: "b/a.txt", seed: "d"},
+				chunks: []testChunk{{content: "a\n", op: Equal}, {content: "b\n", op: Add}},
+			},
+			similarity: 75,
+			copy:       true,
+		},
+		similarFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "x.txt", seed: "e"},
+				to:     &testFile{mode: filemode.Regular, path: "y.txt", seed: "e"},
+				chunks: []testChunk{{content: "a\n", op: Equal}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewSummaryEncoder(&buf).Encode(p))
+	assert.Equal(t, ""+
+		" copy a.txt => b/a.txt (75%)\n"+
+		" rename d/{three.txt => three2.txt} (89%)\n"+
+		" mode change 100644 => 100755\n"+
+		" rename x.txt => y.txt (100%)\n", buf.String())
+}
+
+func TestStatEncodersRenameOrder(t *testing.T) {
+	// git orders a rename by its destination path, after the added bin.dat.
+	p := filePatches{
+		testFilePatch{
+			from:   &testFile{mode: filemode.Regular, path: "b.txt", seed: "a"},
+			to:     &testFile{mode: filemode.Regular, path: "c.txt", seed: "a"},
+			chunks: []testChunk{{content: "a\n", op: Equal}},
+		},
+		testFilePatch{
+			to:     &testFile{mode: filemode.Regular, path: "bin.dat", seed: "b"},
+			chunks: []testChunk{{content: "data\n", op: Add}},
+		},
+		testFilePatch{
+			from:   &testFile{mode: filemode.Regular, path: "a.txt", seed: "c"},
+			chunks: []testChunk{{content: "one\n", op: Delete}},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewStatEncoder(&buf).Encode(p))
+	assert.Equal(t, ""+
+		" a.txt          | 1 -\n"+
+		" bin.dat        | 1 +\n"+
+		" b.txt => c.txt | 0\n"+
+		" 3 files changed, 1 insertion(+), 1 deletion(-)\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewNumstatEncoder(&buf).Encode(p))
+	assert.Equal(t, ""+
+		"0\t1\ta.txt\n"+
+		"1\t0\tbin.dat\n"+
+		"0\t0\tb.txt => c.txt\n", buf.String())
+
+	buf.Reset()
+	require.NoError(t, NewSummaryEncoder(&buf).Encode(p))
+	assert.Equal(t, ""+
+		" delete mode 100644 a.txt\n"+
+		" create mode 100644 bin.dat\n"+
+		" rename b.txt => c.txt (100%)\n", buf.String())
+}
+
+func TestSummaryEncoderRewrite(t *testing.T) {
+	p := filePatches{
+		rewriteFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "a.txt", seed: "a"},
+				to:     &testFile{mode: filemode.Executable, path: "a.txt", seed: "b"},
+				chunks: []testChunk{{content: "a\n", op: Delete}, {content: "b\n", op: Add}},
+			},
+			dissimilarity: 100,
+		},
+		rewriteFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "b.txt", seed: "c"},
+				to:     &testFile{mode: filemode.Regular, path: "b.txt", seed: "d"},
+				chunks: []testChunk{{content: "a\n", op: Equal}, {content: "b\n", op: Add}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, NewSummaryEncoder(&buf).Encode(p))
+	assert.Equal(t, ""+
+		" rewrite a.txt (100%)\n"+
+		" mode change 100644 => 100755\n", buf.String())
+}
@@ -0,0 +1,126 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+// SubmoduleLog returns the summary of the changes of the submodule at path
+// from the commit from to the commit to, from being zero for a new
+// submodule and to being zero for a deleted one. It is used to encode the
+// changes of the submodules like `git diff --submodule=log`.
+type SubmoduleLog func(path string, from, to plumbing.Hash) (*SubmoduleSummary, error)
+
+// SubmoduleSummary is the summary of the changes of a submodule between two
+// commits.
+type SubmoduleSummary struct {
+	// Commits are the first-parent commits only reachable from one of the
+	// two commits, the newest first.
+	Commits []SubmoduleCommit
+	// NotPresent is set when the commits can not be found in the repository
+	// of the submodule.
+	NotPresent bool
+	// FastForward is set when the old commit is an ancestor of the new one.
+	FastForward bool
+	// Rewind is set when the new commit is an ancestor of the old one.
+	Rewind bool
+}
+
+// SubmoduleCommit is a commit of a SubmoduleSummary.
+type SubmoduleCommit struct {
+	Hash    plumbing.Hash
+	Subject string
+	// Left is set when the commit is only reachable from the old commit.
+	Left bool
+}
+
+// isSubmodulePatch reports whether the file patch is a change of a
+// submodule, a new or a deleted one.
+func isSubmodulePatch(filePatch FilePatch) bool {
+	from, to := filePatch.Files()
+	if from == nil && to == nil {
+		return false
+	}
+
+	return (from == nil || from.Mode() == filemode.Submodule) &&
+		(to == nil || to.Mode() == filemode.Submodule)
+}
+
+// writeSubmoduleSummary writes the summary of the changes of a submodule,
+// see SubmoduleLog.
+func (e *UnifiedEncoder) writeSubmoduleSummary(sb *strings.Builder, filePatch FilePatch) error {
+	var path string
+	fromHash, toHash := plumbing.ZeroHash, plumbing.ZeroHash
+	from, to := filePatch.Files()
+	if from != nil {
+		path, fromHash = from.Path(), from.Hash()
+	}
+
+	if to != nil {
+		path, toHash = to.Path(), to.Hash()
+	}
+
+	var message string
+	summary := &SubmoduleSummary{}
+	switch {
+	case from == nil:
+		message = "(new submodule)"
+	case to == nil:
+		message = "(submodule deleted)"
+	default:
+		var err error
+		summary, err = e.submoduleLog(path, fromHash, toHash)
+		if err != nil {
+			return err
+		}
+
+		if summary.NotPresent {
+			message = "(commits not present)"
+		}
+	}
+
+	sep := "..."
+	if summary.FastForward || summary.Rewind {
+		sep = ".."
+	}
+
+	sb.WriteString(e.color[Meta])
+	fmt.Fprintf(sb, "Submodule %s %s%s%s", path, abbreviate(fromHash), sep, abbreviate(toHash))
+	switch {
+	case message != "":
+		sb.WriteString(" " + message)
+	case summary.Rewind:
+		sb.WriteString(" (rewind):")
+	default:
+		sb.WriteByte(':')
+	}
+
+	sb.WriteString(e.color.Reset(Meta))
+	sb.WriteByte('\n')
+
+	if message != "" {
+		return nil
+	}
+
+	for _, c := range summary.Commits {
+		key, marker := New, '>'
+		if c.Left {
+			key, marker = Old, '<'
+		}
+
+		sb.WriteString(e.color[key])
+		fmt.Fprintf(sb, "  %c %s", marker, c.Subject)
+		sb.WriteString(e.color.Reset(key))
+		sb.WriteByte('\n')
+	}
+
+	return nil
+}
+
+// abbreviate returns the abbreviated form of the hash, as shown by git.
+func abbreviate(h plumbing.Hash) string {
+	return h.String()[:7]
+}
@@ -1,6 +1,8 @@
 package diff
 
 import (
+	"bytes"
+	"compress/zlib"
 	"fmt"
 	"io"
 	"regexp"
@@ -8,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/format/packfile"
+	linediff "github.com/go-git/go-git/v6/utils/diff"
 )
 
 // DefaultContextLines is the default number of context lines.
@@ -29,8 +33,9 @@ var (
 	}
 )
 
-// UnifiedEncoder encodes an unified diff into the provided Writer. It does not
-// support similarity index for renames or sorting hash representations.
+// UnifiedEncoder encodes an unified diff into the provided Writer. The
+// similarity index of the renames and copies, and the dissimilarity index
+// of the rewrites, are shown when known, see SummaryEncoder. It does not support sorting hash representations.
 type UnifiedEncoder struct {
 	io.Writer
 
@@ -44,6 +49,37 @@ type UnifiedEncoder struct {
 
 	// colorConfig is the color configuration. The default is no color.
 	color ColorConfig
+
+	// submoduleLog, when set, summarizes the changes of the submodules
+	// instead of their "Subproject commit" lines.
+	submoduleLog SubmoduleLog
+
+	// abbrev is the length of the hashes of the index lines, zero for the
+	// full hashes.
+	abbrev int
+
+	// interHunkContext is the number of lines between two hunks, on top of
+	// the context lines of both, under which they are merged.
+	interHunkContext int
+
+	// functionContext extends the context to the whole functions around
+	// the changes.
+	functionContext bool
+
+	// lineDiff are the options the lines are diffed again with when they
+	// ignore whitespace.
+	lineDiff *linediff.Options
+
+	// wordDiff is the word diff mode, and wordRegex the regex matching the
+	// words, overriding the one of the drivers.
+	wordDiff  WordDiff
+	wordRegex *regexp.Regexp
+
+	// drivers returns the diff driver of a path, if any.
+	drivers func(path string) *Driver
+
+	// binary writes the changes of the binary files as binary patches.
+	binary bool
 }
 
 // NewUnifiedEncoder returns a new UnifiedEncoder that writes to w.
@@ -74,6 +110,110 @@ func (e *UnifiedEncoder) SetDstPrefix(prefix string) *UnifiedEncoder {
 	return e
 }
 
+// SetSubmoduleLog sets the SubmoduleLog used to summarize the changes of the
+// submodules with the subjects of their commits, like
+// `git diff --submodule=log`, and returns e.
+func (e *UnifiedEncoder) SetSubmoduleLog(l SubmoduleLog) *UnifiedEncoder {
+	e.submoduleLog = l
+	return e
+}
+
+// SetAbbrev sets the length of the abbreviated hashes of the index lines,
+// git abbreviating them to 7 characters unless --full-index is given, and
+// returns e. Zero, the default, writes the full hashes.
+func (e *UnifiedEncoder) SetAbbrev(n int) *UnifiedEncoder {
+	e.abbrev = n
+	return e
+}
+
+// SetInterHunkContext sets the number of lines between two hunks, on top of
+// the context lines of both, under which they are merged, like git's
+// --inter-hunk-context, and returns e.
+func (e *UnifiedEncoder) SetInterHunkContext(n int) *UnifiedEncoder {
+	e.interHunkContext = n
+	return e
+}
+
+// SetFunctionContext sets whether the context of the changes is extended
+// to the whole functions around them, like git's --function-context, and
+// returns e.
+func (e *UnifiedEncoder) SetFunctionContext(functionContext bool) *UnifiedEncoder {
+	e.functionContext = functionContext
+	return e
+}
+
+// SetLineDiff sets the line diff options and returns e. When they ignore
+// whitespace, the lines of the file patches are diffed again with them and
+// the hunks made only of ignored changes are not shown.
+func (e *UnifiedEncoder) SetLineDiff(o *linediff.Options) *UnifiedEncoder {
+	e.lineDiff = o
+	return e
+}
+
+// SetWordDiff sets the word diff mode and returns e. The words are matched
+// by regex, or by the word regex of the drivers when nil.
+func (e *UnifiedEncoder) SetWordDiff(mode WordDiff, regex *regexp.Regexp) *UnifiedEncoder {
+	e.wordDiff = mode
+	e.wordRegex = regex
+	return e
+}
+
+// SetDrivers sets the function returning the diff driver of a path, nil
+// when it has none, and returns e. The driver of the source path of a file
+// patch is used, or else the one of its destination path.
+func (e *UnifiedEncoder) SetDrivers(drivers func(path string) *Driver) *UnifiedEncoder {
+	e.drivers = drivers
+	return e
+}
+
+// SetBinary sets whether the changes of the binary files are written as
+// binary patches git apply can apply, like git's --binary, instead of only
+// reporting the files as different, and returns e. Their index lines have
+// full hashes. Only the changes of ContentFiles have binary patches.
+func (e *UnifiedEncoder) SetBinary(binary bool) *UnifiedEncoder {
+	e.binary = binary
+	return e
+}
+
+// driver returns the diff driver of the file patch.
+func (e *UnifiedEncoder) driver(filePatch FilePatch) *Driver {
+	if e.drivers == nil {
+		return nil
+	}
+
+	from, to := filePatch.Files()
+	if from != nil {
+		if d := e.drivers(from.Path()); d != nil {
+			return d
+		}
+	}
+
+	if to != nil {
+		return e.drivers(to.Path())
+	}
+
+	return nil
+}
+
+// index returns the hashes of an index line, abbreviated unless full.
+func (e *UnifiedEncoder) index(from, to plumbing.Hash, full bool) string {
+	if full {
+		return from.String() + ".." + to.String()
+	}
+
+	return e.abbrevHash(from) + ".." + e.abbrevHash(to)
+}
+
+// abbrevHash returns the hash abbreviated like the ones of the index lines.
+func (e *UnifiedEncoder) abbrevHash(h plumbing.Hash) string {
+	s := h.String()
+	if e.abbrev > 0 && e.abbrev < len(s) {
+		s = s[:e.abbrev]
+	}
+
+	return s
+}
+
 // Encode encodes patch.
 func (e *UnifiedEncoder) Encode(patch Patch) error {
 	sb := &strings.Builder{}
@@ -86,10 +226,16 @@ func (e *UnifiedEncoder) Encode(patch Patch) error {
 	}
 
 	for _, filePatch := range patch.FilePatches() {
-		e.writeFilePatchHeader(sb, filePatch)
-		g := newHunksGenerator(filePatch.Chunks(), e.contextLines)
-		for _, hunk := range g.Generate() {
-			hunk.writeTo(sb, e.color)
+		if e.submoduleLog != nil && isSubmodulePatch(filePatch) {
+			if err := e.writeSubmoduleSummary(sb, filePatch); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := e.writeFilePatch(sb, filePatch); err != nil {
+			return err
 		}
 	}
 
@@ -97,12 +243,67 @@ func (e *UnifiedEncoder) Encode(patch Patch) error {
 	return err
 }
 
-func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch FilePatch) {
+func (e *UnifiedEncoder) writeFilePatch(sb *strings.Builder, filePatch FilePatch) error {
+	driver := e.driver(filePatch)
+	g := newHunksGenerator(filePatch.Chunks(), e.contextLines, e.lineDiff)
+	g.interHunkContext = e.interHunkContext
+	g.functionContext = e.functionContext
+	if driver != nil {
+		g.funcName = driver.FuncName
+	}
+
+	hunks := g.Generate()
+	if len(hunks) == 0 && len(g.edits) != 0 && !hasHeaderChanges(filePatch) {
+		// All the changes are ignored.
+		return nil
+	}
+
+	var binaryPatch []string
+	if e.binary && filePatch.IsBinary() {
+		var err error
+		if binaryPatch, err = newBinaryPatch(filePatch); err != nil {
+			return err
+		}
+	}
+
+	e.writeFilePatchHeader(sb, filePatch, binaryPatch)
+	if e.wordDiff == WordDiffNone {
+		for _, hunk := range hunks {
+			hunk.writeTo(sb, e.color)
+		}
+
+		return nil
+	}
+
+	regex := e.wordRegex
+	if regex == nil && driver != nil {
+		regex = driver.WordRegex
+	}
+
+	w := newWordDiffer(e.wordDiff, regex, e.color)
+	for _, hunk := range hunks {
+		w.writeHunk(sb, hunk)
+	}
+
+	return nil
+}
+
+// hasHeaderChanges reports whether the file patch has changes shown in its
+// header, that is other than content changes.
+func hasHeaderChanges(filePatch FilePatch) bool {
+	from, to := filePatch.Files()
+	return from == nil || to == nil || from.Path() != to.Path() || from.Mode() != to.Mode()
+}
+
+// writeFilePatchHeader writes the header of the file patch, ending with the
+// lines of its binary patch, if any.
+func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch FilePatch, binaryPatch []string) {
 	from, to := filePatch.Files()
 	if from == nil && to == nil {
 		return
 	}
 	isBinary := filePatch.IsBinary()
+	fullIndex := e.binary && isBinary
 
 	var lines []string
 	switch {
@@ -119,37 +320,47 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 			)
 		}
 		if from.Path() != to.Path() {
+			action, similarity := renameSimilarity(filePatch)
+			if similarity != 0 {
+				lines = append(lines, fmt.Sprintf("similarity index %d%%", similarity))
+			}
 			lines = append(lines,
-				fmt.Sprintf("rename from %s", from.Path()),
-				fmt.Sprintf("rename to %s", to.Path()),
+				fmt.Sprintf("%s from %s", action, from.Path()),
+				fmt.Sprintf("%s to %s", action, to.Path()),
 			)
+		} else if dissimilarity := rewriteDissimilarity(filePatch); dissimilarity != 0 {
+			lines = append(lines, fmt.Sprintf("dissimilarity index %d%%", dissimilarity))
 		}
 		if from.Mode() != to.Mode() && !hashEquals {
 			lines = append(lines,
-				fmt.Sprintf("index %s..%s", from.Hash(), to.Hash()),
+				fmt.Sprintf("index %s", e.index(from.Hash(), to.Hash(), fullIndex)),
 			)
 		} else if !hashEquals {
 			lines = append(lines,
-				fmt.Sprintf("index %s..%s %o", from.Hash(), to.Hash(), from.Mode()),
+				fmt.Sprintf("index %s %o", e.index(from.Hash(), to.Hash(), fullIndex), from.Mode()),
 			)
 		}
 		if !hashEquals {
-			lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), e.dstPrefix+to.Path(), isBinary)
+			lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), e.dstPrefix+to.Path(), isBinary, binaryPatch)
 		}
 	case from == nil:
 		lines = append(lines,
 			fmt.Sprintf("diff --git %s %s", e.srcPrefix+to.Path(), e.dstPrefix+to.Path()),
 			fmt.Sprintf("new file mode %o", to.Mode()),
-			fmt.Sprintf("index %s..%s", plumbing.ZeroHash, to.Hash()),
+			fmt.Sprintf("index %s", e.index(plumbing.ZeroHash, to.Hash(), fullIndex)),
 		)
-		lines = e.appendPathLines(lines, "/dev/null", e.dstPrefix+to.Path(), isBinary)
+		if isBinary || len(filePatch.Chunks()) != 0 {
+			lines = e.appendPathLines(lines, "/dev/null", e.dstPrefix+to.Path(), isBinary, binaryPatch)
+		}
 	case to == nil:
 		lines = append(lines,
 			fmt.Sprintf("diff --git %s %s", e.srcPrefix+from.Path(), e.dstPrefix+from.Path()),
 			fmt.Sprintf("deleted file mode %o", from.Mode()),
-			fmt.Sprintf("index %s..%s", from.Hash(), plumbing.ZeroHash),
+			fmt.Sprintf("index %s", e.index(from.Hash(), plumbing.ZeroHash, fullIndex)),
 		)
-		lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), "/dev/null", isBinary)
+		if isBinary || len(filePatch.Chunks()) != 0 {
+			lines = e.appendPathLines(lines, e.srcPrefix+from.Path(), "/dev/null", isBinary, binaryPatch)
+		}
 	}
 
 	sb.WriteString(e.color[Meta])
@@ -162,7 +373,11 @@ func (e *UnifiedEncoder) writeFilePatchHeader(sb *strings.Builder, filePatch Fil
 	sb.WriteByte('\n')
 }
 
-func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string, isBinary bool) []string {
+func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string, isBinary bool, binaryPatch []string) []string {
+	if binaryPatch != nil {
+		return append(lines, binaryPatch...)
+	}
+
 	if isBinary {
 		return append(lines,
 			fmt.Sprintf("Binary files %s and %s differ", fromPath, toPath),
@@ -174,133 +389,401 @@ func (e *UnifiedEncoder) appendPathLines(lines []string, fromPath, toPath string
 	)
 }
 
-type hunksGenerator struct {
-	fromLine, toLine            int
-	ctxLines                    int
-	chunks                      []Chunk
-	current                     *hunk
-	hunks                       []*hunk
-	beforeContext, afterContext []string
+// newBinaryPatch returns the lines of the "GIT binary patch" of the file
+// patch: the hunk changing its old content into the new one, then the one
+// changing it back, each followed by an empty line. It returns nil when the
+// files are not ContentFiles.
+func newBinaryPatch(filePatch FilePatch) ([]string, error) {
+	from, to := filePatch.Files()
+	var contents [2][]byte
+	for i, f := range []File{from, to} {
+		if f == nil {
+			continue
+		}
+
+		cf, ok := f.(ContentFile)
+		if !ok {
+			return nil, nil
+		}
+
+		var err error
+		if contents[i], err = cf.Content(); err != nil {
+			return nil, err
+		}
+	}
+
+	lines := []string{"GIT binary patch"}
+	for _, c := range [][2][]byte{contents, {contents[1], contents[0]}} {
+		hunk, err := newBinaryHunk(c[0], c[1])
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(append(lines, hunk...), "")
+	}
+
+	return lines, nil
 }
 
-func newHunksGenerator(chunks []Chunk, ctxLines int) *hunksGenerator {
-	return &hunksGenerator{
-		chunks:   chunks,
-		ctxLines: ctxLines,
+// newBinaryHunk returns the lines of the binary hunk changing src into dst:
+// the deflated delta between them when smaller than the deflated dst, dst
+// otherwise, like git does.
+func newBinaryHunk(src, dst []byte) ([]string, error) {
+	data, err := deflate(dst)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("literal %d", len(dst))
+	if len(src) != 0 && len(dst) != 0 {
+		delta := packfile.DiffDelta(src, dst)
+		deflated, err := deflate(delta)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(deflated) < len(data) {
+			header, data = fmt.Sprintf("delta %d", len(delta)), deflated
+		}
 	}
+
+	lines := []string{header}
+	for len(data) != 0 {
+		n := min(len(data), 52)
+		lines = append(lines, encodeBinaryLine(data[:n]))
+		data = data[n:]
+	}
+
+	return lines, nil
 }
 
-func (g *hunksGenerator) Generate() []*hunk {
-	for i, chunk := range g.chunks {
-		lines := splitLines(chunk.Content())
-		nLines := len(lines)
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
 
-		switch chunk.Type() {
-		case Equal:
-			g.fromLine += nLines
-			g.toLine += nLines
-			g.processEqualsLines(lines, i)
-		case Delete:
-			if nLines != 0 {
-				g.fromLine++
-			}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
 
-			g.processHunk(i, chunk.Type())
-			g.fromLine += nLines - 1
-			g.current.AddOp(chunk.Type(), lines...)
-		case Add:
-			if nLines != 0 {
-				g.toLine++
+	return buf.Bytes(), nil
+}
+
+// encodeBinaryLine encodes a line of a binary hunk of up to 52 bytes, the
+// reverse of decodeBinaryLine.
+func encodeBinaryLine(data []byte) string {
+	length := byte('A' + len(data) - 1)
+	if len(data) > 26 {
+		length = byte('a' + len(data) - 27)
+	}
+
+	return string(length) + encodeBase85(data)
+}
+
+// encodeBase85 encodes every 4 bytes of data, the last ones padded with
+// zeros, to a group of 5 characters.
+func encodeBase85(data []byte) string {
+	sb := &strings.Builder{}
+	for ; len(data) != 0; data = data[min(len(data), 4):] {
+		var acc uint32
+		for i := 0; i < 4; i++ {
+			acc <<= 8
+			if i < len(data) {
+				acc |= uint32(data[i])
 			}
-			g.processHunk(i, chunk.Type())
-			g.toLine += nLines - 1
-			g.current.AddOp(chunk.Type(), lines...)
 		}
 
-		if i == len(g.chunks)-1 && g.current != nil {
-			g.hunks = append(g.hunks, g.current)
+		var group [5]byte
+		for i := len(group) - 1; i >= 0; i-- {
+			group[i] = base85Alphabet[acc%85]
+			acc /= 85
 		}
+
+		sb.Write(group[:])
 	}
 
-	return g.hunks
+	return sb.String()
 }
 
-func (g *hunksGenerator) processHunk(i int, op Operation) {
-	if g.current != nil {
-		return
+// hunksGenerator splits the changes of a file in hunks, like git's xdiff
+// does.
+type hunksGenerator struct {
+	lines1, lines2   []string
+	edits            []linediff.Edit
+	ctxLines         int
+	interHunkContext int
+	functionContext  bool
+	funcName         *FuncName
+}
+
+// newHunksGenerator returns a hunksGenerator of the changes of the chunks.
+// When the line diff options ignore whitespace, the lines are diffed again
+// with them.
+func newHunksGenerator(chunks []Chunk, ctxLines int, o *linediff.Options) *hunksGenerator {
+	g := &hunksGenerator{ctxLines: ctxLines}
+	var edit *linediff.Edit
+	for _, chunk := range chunks {
+		lines := splitLines(chunk.Content())
+		if len(lines) == 0 {
+			continue
+		}
+
+		if chunk.Type() == Equal {
+			edit = nil
+			g.lines1 = append(g.lines1, lines...)
+			g.lines2 = append(g.lines2, lines...)
+			continue
+		}
+
+		if edit == nil {
+			g.edits = append(g.edits, linediff.Edit{Pos1: len(g.lines1), Pos2: len(g.lines2)})
+			edit = &g.edits[len(g.edits)-1]
+		}
+
+		switch chunk.Type() {
+		case Delete:
+			g.lines1 = append(g.lines1, lines...)
+			edit.Len1 += len(lines)
+		case Add:
+			g.lines2 = append(g.lines2, lines...)
+			edit.Len2 += len(lines)
+		}
 	}
 
-	var ctxPrefix string
-	linesBefore := len(g.beforeContext)
-	if linesBefore > g.ctxLines {
-		ctxPrefix = g.beforeContext[linesBefore-g.ctxLines-1]
-		g.beforeContext = g.beforeContext[linesBefore-g.ctxLines:]
-		linesBefore = g.ctxLines
+	if o != nil && o.Whitespace != 0 {
+		g.edits = linediff.Edits(g.lines1, g.lines2, o)
 	}
 
-	g.current = &hunk{ctxPrefix: strings.TrimSuffix(ctxPrefix, "\n")}
-	g.current.AddOp(Equal, g.beforeContext...)
+	return g
+}
 
-	switch op {
-	case Delete:
-		g.current.fromLine, g.current.toLine =
-			g.addLineNumbers(g.fromLine, g.toLine, linesBefore, i, Add)
-	case Add:
-		g.current.toLine, g.current.fromLine =
-			g.addLineNumbers(g.toLine, g.fromLine, linesBefore, i, Delete)
+// Generate returns the hunks.
+func (g *hunksGenerator) Generate() []*hunk {
+	var hunks []*hunk
+	funcLine, funcLinePrev := "", -1
+	for next := 0; next < len(g.edits); {
+		first, last := g.hunkEdits(next)
+		if first == len(g.edits) {
+			break
+		}
+
+		s1, s2 := g.preContext(next, &first)
+		e1, e2 := g.postContext(&last)
+
+		if l, ok := g.findFuncLine(s1-1, funcLinePrev); ok {
+			funcLine = g.funcNameOf(l)
+		}
+
+		funcLinePrev = s1 - 1
+
+		h := &hunk{ctxPrefix: funcLine}
+		h.AddOp(Equal, g.lines2[s2:g.edits[first].Pos2]...)
+		p1, p2 := g.edits[first].Pos1, g.edits[first].Pos2
+		for _, edit := range g.edits[first : last+1] {
+			n := min(edit.Pos1-p1, edit.Pos2-p2)
+			h.AddOp(Equal, g.lines2[p2:p2+n]...)
+			h.AddOp(Delete, g.lines1[edit.Pos1:edit.Pos1+edit.Len1]...)
+			h.AddOp(Add, g.lines2[edit.Pos2:edit.Pos2+edit.Len2]...)
+			p1, p2 = edit.Pos1+edit.Len1, edit.Pos2+edit.Len2
+		}
+
+		h.AddOp(Equal, g.lines2[p2:e2]...)
+
+		h.fromCount, h.toCount = e1-s1, e2-s2
+		h.fromLine, h.toLine = s1, s2
+		if h.fromCount > 0 {
+			h.fromLine++
+		}
+
+		if h.toCount > 0 {
+			h.toLine++
+		}
+
+		hunks = append(hunks, h)
+		next = last + 1
 	}
 
-	g.beforeContext = nil
+	return hunks
 }
 
-// addLineNumbers obtains the line numbers in a new chunk.
-func (g *hunksGenerator) addLineNumbers(la, lb int, linesBefore int, i int, op Operation) (cla, clb int) {
-	cla = la - linesBefore
-	// we need to search for a reference for the next diff
-	switch {
-	case linesBefore != 0 && g.ctxLines != 0:
-		if lb > g.ctxLines {
-			clb = lb - g.ctxLines + 1
-		} else {
-			clb = 1
+// hunkEdits returns the first and last edits of the hunk starting at the
+// edit next, skipping the ignored edits too far from the others. first is
+// past the edits when there is no hunk left.
+func (g *hunksGenerator) hunkEdits(next int) (first, last int) {
+	edits := g.edits
+	maxCommon := 2*g.ctxLines + g.interHunkContext
+	maxIgnorable := g.ctxLines
+
+	first = next
+	for p := next; p < len(edits) && edits[p].Ignore; p++ {
+		if p+1 == len(edits) || edits[p+1].Pos1-end1(edits[p]) >= maxIgnorable {
+			first = p + 1
 		}
-	case g.ctxLines == 0:
-		clb = lb
-	case i != len(g.chunks)-1:
-		next := g.chunks[i+1]
-		if next.Type() == op || next.Type() == Equal {
-			// this diff will be into this chunk
-			clb = lb + 1
+	}
+
+	if first == len(edits) {
+		return first, first
+	}
+
+	last = first
+	ignored := 0
+loop:
+	for p := first; p+1 < len(edits); p++ {
+		x := edits[p+1]
+		distance := x.Pos1 - end1(edits[p])
+		switch {
+		case distance > maxCommon:
+			break loop
+		case distance < maxIgnorable && (!x.Ignore || last == p):
+			last, ignored = p+1, 0
+		case distance < maxIgnorable:
+			ignored += x.Len2
+		case last != p && x.Pos1+ignored-end1(edits[last]) > maxCommon:
+			break loop
+		case !x.Ignore:
+			last, ignored = p+1, 0
+		default:
+			ignored += x.Len2
 		}
 	}
 
-	return
+	return first, last
 }
 
-func (g *hunksGenerator) processEqualsLines(ls []string, i int) {
-	if g.current == nil {
-		g.beforeContext = append(g.beforeContext, ls...)
-		return
+// preContext returns the start of the hunk whose first edit is first,
+// which is moved back to a previous ignored edit when the function context
+// reaches it.
+func (g *hunksGenerator) preContext(next int, first *int) (s1, s2 int) {
+	for {
+		edit := g.edits[*first]
+		s1, s2 = max(edit.Pos1-g.ctxLines, 0), max(edit.Pos2-g.ctxLines, 0)
+		if !g.functionContext {
+			return s1, s2
+		}
+
+		i1 := edit.Pos1
+		if i1 >= len(g.lines1) {
+			// No context is needed when whole functions are appended.
+			for i2 := edit.Pos2; i2 < len(g.lines2); i2++ {
+				if _, ok := g.funcName.Match(g.lines2[i2]); ok {
+					return s1, s2
+				}
+			}
+
+			i1 = len(g.lines1) - 1
+		}
+
+		fs1, _ := g.findFuncLine(i1, -1)
+		for fs1 > 0 && !isEmptyLine(g.lines1[fs1-1]) && !g.isFuncLine(fs1-1) {
+			fs1--
+		}
+
+		fs1 = max(fs1, 0)
+		if fs1 >= s1 {
+			return s1, s2
+		}
+
+		s2 = max(s2-(s1-fs1), 0)
+		s1 = fs1
+
+		p := next
+		for p != *first && end1(g.edits[p]) <= s1 && end2(g.edits[p]) <= s2 {
+			p++
+		}
+
+		if p == *first {
+			return s1, s2
+		}
+
+		// The context reaches an ignored edit, show it after all.
+		*first = p
 	}
+}
 
-	g.afterContext = append(g.afterContext, ls...)
-	if len(g.afterContext) <= g.ctxLines*2 && i != len(g.chunks)-1 {
-		g.current.AddOp(Equal, g.afterContext...)
-		g.afterContext = nil
-	} else {
-		ctxLines := g.ctxLines
-		if ctxLines > len(g.afterContext) {
-			ctxLines = len(g.afterContext)
+// postContext returns the end of the hunk whose last edit is last, which
+// is extended to the next edits when the function context reaches them.
+func (g *hunksGenerator) postContext(last *int) (e1, e2 int) {
+	n1, n2 := len(g.lines1), len(g.lines2)
+	for {
+		edit := g.edits[*last]
+		lctx := min(g.ctxLines, n1-end1(edit), n2-end2(edit))
+		e1, e2 = end1(edit)+lctx, end2(edit)+lctx
+		if !g.functionContext {
+			return e1, e2
+		}
+
+		fe1, _ := g.findFuncLine(end1(edit), n1)
+		for fe1 > 0 && isEmptyLine(g.lines1[fe1-1]) {
+			fe1--
+		}
+
+		if fe1 < 0 {
+			fe1 = n1
+		}
+
+		if fe1 > e1 {
+			e2 = min(e2+(fe1-e1), n2)
+			e1 = fe1
+		}
+
+		if *last+1 == len(g.edits) {
+			return e1, e2
+		}
+
+		l := min(g.edits[*last+1].Pos1, n1-1)
+		if l-g.ctxLines > e1 {
+			if _, ok := g.findFuncLine(l, e1); ok {
+				return e1, e2
+			}
 		}
-		g.current.AddOp(Equal, g.afterContext[:ctxLines]...)
-		g.hunks = append(g.hunks, g.current)
 
-		g.current = nil
-		g.beforeContext = g.afterContext[ctxLines:]
-		g.afterContext = nil
+		*last++
 	}
 }
 
+// findFuncLine looks for a function line of the old file from the line
+// start, included, to the line limit, excluded. It returns -1 when there
+// is none.
+func (g *hunksGenerator) findFuncLine(start, limit int) (int, bool) {
+	step := 1
+	if start > limit {
+		step = -1
+	}
+
+	for l := start; l != limit && 0 <= l && l < len(g.lines1); l += step {
+		if g.isFuncLine(l) {
+			return l, true
+		}
+	}
+
+	return -1, false
+}
+
+func (g *hunksGenerator) isFuncLine(l int) bool {
+	_, ok := g.funcName.Match(g.lines1[l])
+	return ok
+}
+
+func (g *hunksGenerator) funcNameOf(l int) string {
+	name, _ := g.funcName.Match(g.lines1[l])
+	return name
+}
+
+func end1(e linediff.Edit) int {
+	return e.Pos1 + e.Len1
+}
+
+func end2(e linediff.Edit) int {
+	return e.Pos2 + e.Len2
+}
+
+// isEmptyLine reports whether the line is empty or only made of whitespace.
+func isEmptyLine(line string) bool {
+	return strings.TrimLeft(line, " \t\n\r") == ""
+}
+
 func splitLines(s string) []string {
 	out := splitLinesRegexp.FindAllString(s, -1)
 	if out[len(out)-1] == "" {
@@ -321,6 +804,13 @@ type hunk struct {
 }
 
 func (h *hunk) writeTo(sb *strings.Builder, color ColorConfig) {
+	h.writeHeaderTo(sb, color)
+	for _, op := range h.ops {
+		op.writeTo(sb, color)
+	}
+}
+
+func (h *hunk) writeHeaderTo(sb *strings.Builder, color ColorConfig) {
 	sb.WriteString(color[Frag])
 	sb.WriteString("@@ -")
 
@@ -353,10 +843,6 @@ func (h *hunk) writeTo(sb *strings.Builder, color ColorConfig) {
 	}
 
 	sb.WriteByte('\n')
-
-	for _, op := range h.ops {
-		op.writeTo(sb, color)
-	}
 }
 
 func (h *hunk) AddOp(t Operation, ss ...string) {
@@ -2,11 +2,15 @@ package diff
 
 import (
 	"bytes"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/color"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
+	linediff "github.com/go-git/go-git/v6/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -54,6 +58,127 @@ Binary files a/binary and b/binary differ
 		buffer.String())
 }
 
+func (s *UnifiedEncoderTestSuite) TestBinaryPatch() {
+	old := strings.Repeat("binary\x00content\n", 100)
+	p := testPatch{filePatches: []testFilePatch{{
+		from: &testFile{mode: filemode.Regular, path: "binary", seed: old},
+		to:   &testFile{mode: filemode.Regular, path: "binary", seed: old + "\x00more"},
+	}, {
+		to: &testFile{mode: filemode.Regular, path: "new", seed: "\x00\x01\x02"},
+	}}}
+
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1).SetAbbrev(7).SetBinary(true)
+	s.NoError(e.Encode(p))
+
+	out := buffer.String()
+	s.Contains(out, "diff --git a/binary b/binary\n"+
+		"index ad4108eddb7a8a64d1b1dfc9e4bed0248b06d34d..3e158a003d4005ce1fe93a0cab7eb20bda9191c8 100644\n"+
+		"GIT binary patch\n"+
+		"delta ")
+	s.Contains(out, "diff --git a/new b/new\n"+
+		"new file mode 100644\n"+
+		"index 0000000000000000000000000000000000000000..8352675d67aed6625ece79af41c27fdb4ee2e867\n"+
+		"GIT binary patch\n"+
+		"literal 3\n")
+	s.NotContains(out, "Binary files")
+
+	files, err := NewDecoder(strings.NewReader(out)).Decode()
+	s.Require().NoError(err)
+	s.Require().Len(files, 2)
+	for i, f := range files {
+		var from, to []byte
+		if fp := p.filePatches[i]; fp.from != nil {
+			from = []byte(fp.from.seed)
+		}
+
+		to = []byte(p.filePatches[i].to.seed)
+		s.Require().NotNil(f.BinaryHunk)
+		applied, _, err := f.Apply(from, 0)
+		s.NoError(err)
+		s.Equal(to, applied)
+
+		reverted, _, err := f.Reverse().Apply(to, 0)
+		s.NoError(err)
+		s.Equal(string(from), string(reverted))
+	}
+}
+
+func (s *UnifiedEncoderTestSuite) TestEncodeBase85() {
+	for _, data := range [][]byte{{0}, {1, 2, 3, 4}, []byte("hello\x00there world\n")} {
+		decoded, err := decodeBase85(encodeBase85(data))
+		s.NoError(err)
+		s.Equal(data, decoded[:len(data)])
+
+		decoded, err = decodeBinaryLine(encodeBinaryLine(data))
+		s.NoError(err)
+		s.Equal(data, decoded)
+	}
+}
+
+func (s *UnifiedEncoderTestSuite) TestSimilarity() {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1)
+	p := filePatches{
+		similarFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "a.txt", seed: "a\nb\n"},
+				to:     &testFile{mode: filemode.Regular, path: "b.txt", seed: "a\nc\n"},
+				chunks: []testChunk{{content: "a\n", op: Equal}, {content: "b\n", op: Delete}, {content: "c\n", op: Add}},
+			},
+			similarity: 75,
+			copy:       true,
+		},
+	}
+
+	err := e.Encode(p)
+	s.NoError(err)
+
+	s.Equal(`diff --git a/a.txt b/b.txt
+similarity index 75%
+copy from a.txt
+copy to b.txt
+index 422c2b7ab3b3c668038da977e4e93a5fc623169c..0f7bc766052a5a0ee28a393d51d2370f96d8ceb8 100644
+--- a/a.txt
++++ b/b.txt
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`,
+		buffer.String())
+}
+
+func (s *UnifiedEncoderTestSuite) TestDissimilarity() {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1)
+	p := filePatches{
+		rewriteFilePatch{
+			testFilePatch: testFilePatch{
+				from:   &testFile{mode: filemode.Regular, path: "a.txt", seed: "a\nb\n"},
+				to:     &testFile{mode: filemode.Regular, path: "a.txt", seed: "c\n"},
+				chunks: []testChunk{{content: "a\nb\n", op: Delete}, {content: "c\n", op: Add}},
+			},
+			dissimilarity: 100,
+		},
+	}
+
+	err := e.Encode(p)
+	s.NoError(err)
+
+	s.Equal(`diff --git a/a.txt b/a.txt
+dissimilarity index 100%
+index 422c2b7ab3b3c668038da977e4e93a5fc623169c..f2ad6c76f0115a6ba5b00456a849810e7ec0af20 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1 @@
+-a
+-b
++c
+`,
+		buffer.String())
+}
+
 func (s *UnifiedEncoderTestSuite) TestCustomSrcDstPrefix() {
 	buffer := bytes.NewBuffer(nil)
 	e := NewUnifiedEncoder(buffer, 1).SetSrcPrefix("source/prefix/").SetDstPrefix("dest/prefix/")
@@ -83,6 +208,279 @@ Binary files source/prefix/binary and dest/prefix/binary differ
 		buffer.String())
 }
 
+func (s *UnifiedEncoderTestSuite) TestSubmoduleLog() {
+	from := &testFile{mode: filemode.Submodule, path: "sub", seed: "from"}
+	to := &testFile{mode: filemode.Submodule, path: "sub", seed: "to"}
+	p := testPatch{filePatches: []testFilePatch{
+		{from: from, to: to},
+		{to: &testFile{mode: filemode.Submodule, path: "new", seed: "new"}},
+	}}
+
+	var calls []string
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1).SetSubmoduleLog(func(path string, f, t plumbing.Hash) (*SubmoduleSummary, error) {
+		calls = append(calls, path)
+		s.Equal(from.Hash(), f)
+		s.Equal(to.Hash(), t)
+		return &SubmoduleSummary{Commits: []SubmoduleCommit{
+			{Subject: "second"}, {Subject: "first"}, {Subject: "dropped", Left: true},
+		}}, nil
+	})
+
+	s.NoError(e.Encode(p))
+	s.Equal([]string{"sub"}, calls)
+	s.Equal("Submodule sub "+from.Hash().String()[:7]+"..."+to.Hash().String()[:7]+":\n"+
+		"  > second\n  > first\n  < dropped\n"+
+		"Submodule new 0000000..."+p.filePatches[1].to.Hash().String()[:7]+" (new submodule)\n",
+		buffer.String())
+
+	buffer.Reset()
+	e.SetSubmoduleLog(func(string, plumbing.Hash, plumbing.Hash) (*SubmoduleSummary, error) {
+		return &SubmoduleSummary{Rewind: true, Commits: []SubmoduleCommit{{Subject: "dropped", Left: true}}}, nil
+	})
+
+	s.NoError(e.Encode(testPatch{filePatches: p.filePatches[:1]}))
+	s.Equal("Submodule sub "+from.Hash().String()[:7]+".."+to.Hash().String()[:7]+" (rewind):\n  < dropped\n", buffer.String())
+
+	buffer.Reset()
+	e.SetSubmoduleLog(func(string, plumbing.Hash, plumbing.Hash) (*SubmoduleSummary, error) {
+		return &SubmoduleSummary{NotPresent: true}, nil
+	})
+
+	s.NoError(e.Encode(testPatch{filePatches: p.filePatches[:1]}))
+	s.Equal("Submodule sub "+from.Hash().String()[:7]+"..."+to.Hash().String()[:7]+" (commits not present)\n", buffer.String())
+}
+
+const (
+	testGoFile  = "package main\n\nfunc a() {\n\tx := 1\n\treturn x\n}\n\nfunc b() {\n\ty := 2\n\treturn y\n}\n\nfunc c() {\n\tz := 3\n\treturn z\n}\n"
+	testGoFile2 = "package main\n\nfunc a() {\n\tx  :=  1\n\treturn x\n}\n\nfunc b() {\n\ty := 5\n\n\treturn y\n}\n\nfunc c() {\n\tz := 4\n\treturn z\n}\n"
+)
+
+// testLinesPatch returns the patch of a file, its chunks being the line diff
+// of its contents.
+func testLinesPatch(from, to string) testPatch {
+	var chunks []testChunk
+	for _, d := range linediff.Do(from, to) {
+		op := map[diffmatchpatch.Operation]Operation{
+			diffmatchpatch.DiffEqual:  Equal,
+			diffmatchpatch.DiffDelete: Delete,
+			diffmatchpatch.DiffInsert: Add,
+		}[d.Type]
+		chunks = append(chunks, testChunk{content: d.Text, op: op})
+	}
+
+	return testPatch{filePatches: []testFilePatch{{
+		from:   &testFile{mode: filemode.Regular, path: "a.go", seed: from},
+		to:     &testFile{mode: filemode.Regular, path: "a.go", seed: to},
+		chunks: chunks,
+	}}}
+}
+
+// testHunks encodes the patch with e and returns its hunks.
+func (s *UnifiedEncoderTestSuite) testHunks(e *UnifiedEncoder, buffer *bytes.Buffer, p testPatch) string {
+	buffer.Reset()
+	s.Require().NoError(e.Encode(p))
+	out := buffer.String()
+	return out[strings.LastIndexByte(out[:strings.Index(out, "@@ -")], '\n')+1:]
+}
+
+func (s *UnifiedEncoderTestSuite) TestWhitespace() {
+	funcName, err := ParseFuncName("^func ([a-z]+)")
+	s.Require().NoError(err)
+
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 3).
+		SetLineDiff(&linediff.Options{Whitespace: linediff.IgnoreAllSpace}).
+		SetDrivers(func(path string) *Driver {
+			s.Equal("a.go", path)
+			return &Driver{FuncName: funcName}
+		})
+
+	s.Equal(`@@ -6,11 +6,12 @@ a
+ }
+ 
+ func b() {
+-	y := 2
++	y := 5
++
+ 	return y
+ }
+ 
+ func c() {
+-	z := 3
++	z := 4
+ 	return z
+ }
+`, s.testHunks(e, buffer, testLinesPatch(testGoFile, testGoFile2)))
+
+	e.SetLineDiff(&linediff.Options{Whitespace: linediff.IgnoreBlankLines})
+	buffer.Reset()
+	s.NoError(e.Encode(testLinesPatch(testGoFile, strings.Replace(testGoFile, "\n", "\n\n", 1))))
+	s.Empty(buffer.String())
+}
+
+func (s *UnifiedEncoderTestSuite) TestInterHunkContext() {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1)
+	p := testLinesPatch(testGoFile, testGoFile2)
+
+	s.Equal(`@@ -3,3 +3,3 @@ package main
+ func a() {
+-	x := 1
++	x  :=  1
+ 	return x
+@@ -8,3 +8,4 @@ func a() {
+ func b() {
+-	y := 2
++	y := 5
++
+ 	return y
+@@ -13,3 +14,3 @@ func b() {
+ func c() {
+-	z := 3
++	z := 4
+ 	return z
+`, s.testHunks(e, buffer, p))
+
+	e.SetInterHunkContext(2)
+	s.Equal(`@@ -3,13 +3,14 @@ package main
+ func a() {
+-	x := 1
++	x  :=  1
+ 	return x
+ }
+ 
+ func b() {
+-	y := 2
++	y := 5
++
+ 	return y
+ }
+ 
+ func c() {
+-	z := 3
++	z := 4
+ 	return z
+`, s.testHunks(e, buffer, p))
+}
+
+func (s *UnifiedEncoderTestSuite) TestParseFuncName() {
+	f, err := ParseFuncName("!^func main\n^func ([a-z]+)\n^type .*")
+	s.Require().NoError(err)
+
+	for _, c := range []struct {
+		line, name string
+		ok         bool
+	}{
+		{"func foo() {\n", "foo", true},
+		{"func main() {\n", "", false},
+		{"type T struct {  \r\n", "type T struct {", true},
+		{"var x\n", "", false},
+	} {
+		name, ok := f.Match(c.line)
+		s.Equal(c.ok, ok, c.line)
+		s.Equal(c.name, name, c.line)
+	}
+
+	name, ok := (*FuncName)(nil).Match("$x = " + strings.Repeat("y", 100) + "\n")
+	s.True(ok)
+	s.Len(name, 80)
+
+	_, ok = (*FuncName)(nil).Match("\tx\n")
+	s.False(ok)
+
+	_, err = ParseFuncName("^func\n!^type")
+	s.ErrorIs(err, ErrInvalidFuncName)
+
+	_, err = ParseFuncName("(")
+	s.ErrorIs(err, ErrInvalidFuncName)
+}
+
+func (s *UnifiedEncoderTestSuite) TestFunctionContext() {
+	funcName, err := ParseFuncName("^func ([a-z]+)")
+	s.Require().NoError(err)
+
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 0).SetDrivers(func(string) *Driver {
+		return &Driver{FuncName: funcName}
+	})
+	p := testLinesPatch(testGoFile, strings.Replace(testGoFile, "y := 2", "y := 5", 1))
+
+	s.Equal(`@@ -9 +9 @@ b
+-	y := 2
++	y := 5
+`, s.testHunks(e, buffer, p))
+
+	e.SetFunctionContext(true)
+	s.Equal(`@@ -8,4 +8,4 @@ a
+ func b() {
+-	y := 2
++	y := 5
+ 	return y
+ }
+`, s.testHunks(e, buffer, p))
+}
+
+func (s *UnifiedEncoderTestSuite) TestWordDiff() {
+	buffer := bytes.NewBuffer(nil)
+	e := NewUnifiedEncoder(buffer, 1).SetWordDiff(WordDiffPlain, nil)
+	p := testLinesPatch(testGoFile, testGoFile2)
+
+	s.Equal(`@@ -3,3 +3,3 @@ package main
+func a() {
+	x  :=  1
+	return x
+@@ -8,3 +8,4 @@ func a() {
+func b() {
+	y := [-2-]{+5+}
+
+	return y
+@@ -13,3 +14,3 @@ func b() {
+func c() {
+	z := [-3-]{+4+}
+	return z
+`, s.testHunks(e, buffer, p))
+
+	e.SetWordDiff(WordDiffPorcelain, nil)
+	s.Equal(`@@ -3,3 +3,3 @@ package main
+ func a() {
+~
+ 	x  :=  1
+~
+ 	return x
+~
+@@ -8,3 +8,4 @@ func a() {
+ func b() {
+~
+ 	y := 
+-2
++5
+~
+~
+ 	return y
+~
+@@ -13,3 +14,3 @@ func b() {
+ func c() {
+~
+ 	z := 
+-3
++4
+~
+ 	return z
+~
+`, s.testHunks(e, buffer, p))
+
+	e = NewUnifiedEncoder(buffer, 0).SetWordDiff(WordDiffColor, regexp.MustCompile("[0-9]")).
+		SetColor(NewColorConfig())
+	s.Equal(color.Cyan+"@@ -4 +4 @@"+color.Reset+" func a() {\n"+
+		"\tx  :=  1\n"+
+		color.Cyan+"@@ -9 +9,2 @@"+color.Reset+" func b() {\n"+
+		"\ty := "+color.Red+"2"+color.Reset+color.Green+"5"+color.Reset+"\n\n"+
+		color.Cyan+"@@ -14 +15 @@"+color.Reset+" func c() {\n"+
+		"\tz := "+color.Red+"3"+color.Reset+color.Green+"4"+color.Reset+"\n",
+		s.testHunks(e, buffer, p))
+}
+
 func (s *UnifiedEncoderTestSuite) TestEncode() {
 	for _, f := range fixtures {
 		s.T().Log("executing: ", f.desc)
@@ -261,6 +659,7 @@ new mode 100755
 	desc:    "rename file",
 	context: 1,
 	diff: `diff --git a/test.txt b/test1.txt
+similarity index 100%
 rename from test.txt
 rename to test1.txt
 `,
@@ -321,6 +720,7 @@ index 9daeafb9864cf43055ae93beb0afd6c7d144bfa4..a5bce3fd2565d8f458555a0c6f42d050
 	diff: `diff --git a/test.txt b/test1.txt
 old mode 100644
 new mode 100755
+similarity index 100%
 rename from test.txt
 rename to test1.txt
 `,
@@ -885,7 +1285,7 @@ index 0adddcde4fd38042c354518351820eb06c417c82..553ae669c7a9303cf848fcc749a25692
 index 0adddcde4fd38042c354518351820eb06c417c82..d39ae38aad7ba9447b5e7998b2e4714f26c9218d 100644
 --- a/onechunk.txt
 +++ b/onechunk.txt
-@@ -22,2 +21 @@ X
+@@ -22,2 +22 @@ X
 -Y
 -Z
 \ No newline at end of file
@@ -1063,6 +1463,10 @@ func (t testFile) Path() string {
 	return t.path
 }
 
+func (t testFile) Content() ([]byte, error) {
+	return []byte(t.seed), nil
+}
+
 type testChunk struct {
 	content string
 	op      Operation
@@ -0,0 +1,261 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+
+	linediff "github.com/go-git/go-git/v6/utils/diff"
+)
+
+// WordDiff is a word diff mode, showing the changed words instead of the
+// changed lines, like the --word-diff option of git.
+type WordDiff int
+
+const (
+	// WordDiffNone shows the changed lines, the default.
+	WordDiffNone WordDiff = iota
+	// WordDiffPlain shows the removed words as [-removed-] and the added
+	// ones as {+added+}.
+	WordDiffPlain
+	// WordDiffColor shows the changed words with the colors only.
+	WordDiffColor
+	// WordDiffPorcelain shows the words in lines starting with ' ', '-' or
+	// '+', the line feeds being shown as '~' lines, for scripts.
+	WordDiffPorcelain
+)
+
+// wordStyle is the formatting of words in a WordDiff mode.
+type wordStyle struct {
+	prefix, suffix string
+	color          ColorKey
+}
+
+type wordDiffStyle struct {
+	oldWord, newWord, ctx wordStyle
+	newline               string
+}
+
+var wordDiffStyles = map[WordDiff]*wordDiffStyle{
+	WordDiffPlain: {
+		oldWord: wordStyle{"[-", "-]", Old},
+		newWord: wordStyle{"{+", "+}", New},
+		ctx:     wordStyle{"", "", Context},
+		newline: "\n",
+	},
+	WordDiffColor: {
+		oldWord: wordStyle{"", "", Old},
+		newWord: wordStyle{"", "", New},
+		ctx:     wordStyle{"", "", Context},
+		newline: "\n",
+	},
+	WordDiffPorcelain: {
+		oldWord: wordStyle{"-", "\n", Old},
+		newWord: wordStyle{"+", "\n", New},
+		ctx:     wordStyle{" ", "\n", Context},
+		newline: "~\n",
+	},
+}
+
+// wordDiffer writes the changes of hunks as word diffs, accumulating the
+// removed and added lines of each change and then diffing their words.
+type wordDiffer struct {
+	mode  WordDiff
+	style *wordDiffStyle
+	regex *regexp.Regexp
+	color ColorConfig
+
+	minus, plus strings.Builder
+}
+
+func newWordDiffer(mode WordDiff, regex *regexp.Regexp, color ColorConfig) *wordDiffer {
+	return &wordDiffer{
+		mode:  mode,
+		style: wordDiffStyles[mode],
+		regex: regex,
+		color: color,
+	}
+}
+
+// writeHunk writes the hunk h. Like git, the lines without line feed at
+// the end of the files are given one.
+func (w *wordDiffer) writeHunk(sb *strings.Builder, h *hunk) {
+	h.writeHeaderTo(sb, w.color)
+	for _, o := range h.ops {
+		text := strings.TrimSuffix(o.text, "\n")
+		switch o.t {
+		case Delete:
+			w.minus.WriteString(text)
+			w.minus.WriteByte('\n')
+		case Add:
+			w.plus.WriteString(text)
+			w.plus.WriteByte('\n')
+		default:
+			w.flush(sb)
+			w.writeContext(sb, text)
+		}
+	}
+
+	w.flush(sb)
+}
+
+// writeContext writes a context line, given without its line feed.
+func (w *wordDiffer) writeContext(sb *strings.Builder, text string) {
+	if w.mode == WordDiffPorcelain {
+		text = " " + text
+	}
+
+	sb.WriteString(w.color[Context])
+	sb.WriteString(text)
+	sb.WriteString(w.color.Reset(Context))
+	sb.WriteByte('\n')
+
+	if w.mode == WordDiffPorcelain {
+		sb.WriteString("~\n")
+	}
+}
+
+// word is the range of a word in a text.
+type word struct {
+	begin, end int
+}
+
+// flush writes the accumulated change.
+func (w *wordDiffer) flush(sb *strings.Builder) {
+	minus, plus := w.minus.String(), w.plus.String()
+	w.minus.Reset()
+	w.plus.Reset()
+	if minus == "" && plus == "" {
+		return
+	}
+
+	if plus == "" {
+		w.write(sb, w.style.oldWord, minus)
+		return
+	}
+
+	minusWords, minusLines := w.words(minus)
+	plusWords, plusLines := w.words(plus)
+
+	current := 0
+	for _, e := range linediff.Edits(minusLines, plusLines, &linediff.Options{}) {
+		minusBegin, minusEnd := wordRange(minusWords, e.Pos1, e.Len1)
+		plusBegin, plusEnd := wordRange(plusWords, e.Pos2, e.Len2)
+
+		if current != plusBegin {
+			w.write(sb, w.style.ctx, plus[current:plusBegin])
+		}
+
+		if minusBegin != minusEnd {
+			w.write(sb, w.style.oldWord, minus[minusBegin:minusEnd])
+		}
+
+		if plusBegin != plusEnd {
+			w.write(sb, w.style.newWord, plus[plusBegin:plusEnd])
+		}
+
+		current = plusEnd
+	}
+
+	if current != len(plus) {
+		w.write(sb, w.style.ctx, plus[current:])
+	}
+}
+
+// wordRange returns the range of text covered by the n words from the word
+// pos, or the end of the word before pos when n is zero.
+func wordRange(words []word, pos, n int) (begin, end int) {
+	switch {
+	case n > 0:
+		return words[pos].begin, words[pos+n-1].end
+	case pos > 0:
+		return words[pos-1].end, words[pos-1].end
+	default:
+		return 0, 0
+	}
+}
+
+// words splits the text in words, which are matched by the word regex or
+// separated by whitespace. The words are also returned as lines, to be
+// diffed.
+func (w *wordDiffer) words(text string) ([]word, []string) {
+	var words []word
+	var lines []string
+	for i := 0; i < len(text); {
+		begin, end, ok := w.nextWord(text, i)
+		if !ok {
+			break
+		}
+
+		words = append(words, word{begin, end})
+		lines = append(lines, text[begin:end]+"\n")
+		i = end
+	}
+
+	return words, lines
+}
+
+// nextWord finds the next word of the text from the position i.
+func (w *wordDiffer) nextWord(text string, i int) (begin, end int, ok bool) {
+	if w.regex != nil {
+		for i < len(text) {
+			m := w.regex.FindStringIndex(text[i:])
+			if m == nil {
+				return 0, 0, false
+			}
+
+			begin, end = i+m[0], i+m[1]
+			if lf := strings.IndexByte(text[begin:end], '\n'); lf >= 0 {
+				end = begin + lf
+			}
+
+			if begin != end {
+				return begin, end, true
+			}
+
+			i = begin + 1
+		}
+
+		return 0, 0, false
+	}
+
+	for i < len(text) && isSpace(text[i]) {
+		i++
+	}
+
+	if i >= len(text) {
+		return 0, 0, false
+	}
+
+	end = i + 1
+	for end < len(text) && !isSpace(text[end]) {
+		end++
+	}
+
+	return i, end, true
+}
+
+// write writes the text in the given style, each line feed being written
+// as the newline of the mode.
+func (w *wordDiffer) write(sb *strings.Builder, s wordStyle, text string) {
+	for text != "" {
+		line, rest, hasLF := strings.Cut(text, "\n")
+		if line != "" {
+			sb.WriteString(w.color[s.color])
+			sb.WriteString(s.prefix)
+			sb.WriteString(line)
+			sb.WriteString(s.suffix)
+			sb.WriteString(w.color.Reset(s.color))
+		}
+
+		if !hasLF {
+			return
+		}
+
+		sb.WriteString(w.style.newline)
+		text = rest
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -151,8 +151,9 @@ func (s *MatcherSuite) TestDir_ReadPatterns() {
 	results, _ := m.Match([]string{"vendor", "gopkg.in", "file"}, nil)
 	s.Equal("bar", results["foo"].Value())
 
+	// vendor/.gitattributes has a higher priority than the root one.
 	results, _ = m.Match([]string{"vendor", "github.com", "file"}, nil)
-	s.False(results["foo"].IsUnset())
+	s.True(results["foo"].IsUnset())
 }
 
 func (s *MatcherSuite) TestDir_LoadGlobalPatterns() {
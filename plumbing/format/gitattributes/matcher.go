@@ -44,20 +44,17 @@ func (m *matcher) init() {
 func (m *matcher) Match(path []string, attributes []string) (results map[string]Attribute, matched bool) {
 	results = make(map[string]Attribute, len(attributes))
 
-	n := len(m.stack)
-	for i := n - 1; i >= 0; i-- {
-		if len(attributes) > 0 && len(attributes) == len(results) {
-			return
-		}
-
-		pattern := m.stack[i].Pattern
+	// Rules are applied in increasing order of priority, so later rules
+	// override the attributes set by earlier ones.
+	for _, ma := range m.stack {
+		pattern := ma.Pattern
 		if pattern == nil {
 			continue
 		}
 
 		if match := pattern.Match(path); match {
 			matched = true
-			for _, attr := range m.stack[i].Attributes {
+			for _, attr := range ma.Attributes {
 				if attr.IsSet() {
 					m.expandMacro(attr.Name(), results)
 				}
@@ -65,9 +62,27 @@ func (m *matcher) Match(path []string, attributes []string) (results map[string]
 			}
 		}
 	}
+
+	if len(attributes) > 0 {
+		for name := range results {
+			if !containsString(attributes, name) {
+				delete(results, name)
+			}
+		}
+	}
+
 	return
 }
 
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (m *matcher) expandMacro(name string, results map[string]Attribute) bool {
 	if macro, ok := m.macros[name]; ok {
 		for _, attr := range macro.Attributes {
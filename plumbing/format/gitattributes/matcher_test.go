@@ -25,3 +25,24 @@ func (s *MatcherSuite) TestMatcher_Match() {
 	s.True(results["text"].IsSet())
 	s.Equal("crlf", results["eol"].Value())
 }
+
+func (s *MatcherSuite) TestMatcher_MatchPriority() {
+	lines := []string{
+		"* text=auto",
+		"*.bin -text",
+		"*.txt eol=crlf",
+	}
+
+	ma, err := ReadAttributes(strings.NewReader(strings.Join(lines, "\n")), nil, true)
+	s.NoError(err)
+
+	m := NewMatcher(ma)
+	results, matched := m.Match([]string{"data.bin"}, []string{"text"})
+	s.True(matched)
+	s.Len(results, 1)
+	s.True(results["text"].IsUnset())
+
+	results, _ = m.Match([]string{"notes.txt"}, []string{"text", "eol"})
+	s.Equal("auto", results["text"].Value())
+	s.Equal("crlf", results["eol"].Value())
+}
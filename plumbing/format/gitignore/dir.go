@@ -33,13 +33,7 @@ func readIgnoreFile(fs billy.Filesystem, path []string, ignoreFile string) (ps [
 	if err == nil {
 		defer f.Close()
 
-		scanner := bufio.NewScanner(f)
-		for scanner.Scan() {
-			s := scanner.Text()
-			if !strings.HasPrefix(s, commentPrefix) && len(strings.TrimSpace(s)) > 0 {
-				ps = append(ps, ParsePattern(s, path))
-			}
-		}
+		ps, _ = ReadIgnoreFile(f, path)
 	} else if !os.IsNotExist(err) {
 		return nil, err
 	}
@@ -47,6 +41,21 @@ func readIgnoreFile(fs billy.Filesystem, path []string, ignoreFile string) (ps [
 	return
 }
 
+// ReadIgnoreFile reads the patterns of a gitignore file from r, applying to
+// the files of the directory path.
+func ReadIgnoreFile(r io.Reader, path []string) ([]Pattern, error) {
+	var ps []Pattern
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		s := scanner.Text()
+		if !strings.HasPrefix(s, commentPrefix) && len(strings.TrimSpace(s)) > 0 {
+			ps = append(ps, ParsePattern(s, path))
+		}
+	}
+
+	return ps, scanner.Err()
+}
+
 // ReadPatterns reads the .git/info/exclude and then the gitignore patterns
 // recursively traversing through the directory structure. The result is in
 // the ascending order of priority (last higher).
@@ -5,8 +5,8 @@ import (
 	"bytes"
 	"crypto"
 	"errors"
+	"fmt"
 	"io"
-
 	"strconv"
 	"time"
 
@@ -79,7 +79,17 @@ func (d *Decoder) Decode(idx *Index) error {
 		return err
 	}
 
-	return d.readExtensions(idx)
+	if err := d.readExtensions(idx); err != nil {
+		return err
+	}
+
+	// The extensions of a split index apply to the entries merged with the
+	// shared index, see MergeSharedIndex.
+	if idx.Link == nil {
+		idx.resolveExtensions()
+	}
+
+	return nil
 }
 
 func (d *Decoder) readEntries(idx *Index, count int) error {
@@ -217,9 +227,6 @@ func (d *Decoder) padEntry(idx *Index, e *Entry, read int) error {
 }
 
 func (d *Decoder) readExtensions(idx *Index) error {
-	// TODO: support 'Split index' and 'Untracked cache' extensions, take in
-	// count that they are not supported by jgit or libgit
-
 	var expected []byte
 	var peeked []byte
 	var err error
@@ -279,6 +286,35 @@ func (d *Decoder) readExtension(idx *Index) error {
 		if err := d.Decode(idx.EndOfIndexEntry); err != nil {
 			return err
 		}
+	case bytes.Equal(header[:], untrackedCacheExtSignature):
+		idx.UntrackedCache = &UntrackedCache{}
+		d := &untrackedCacheDecoder{r}
+		if err := d.Decode(idx.UntrackedCache); err != nil {
+			return err
+		}
+	case bytes.Equal(header[:], fsMonitorExtSignature):
+		idx.FSMonitor = &FSMonitor{}
+		d := &fsMonitorDecoder{r}
+		dirty, err := d.Decode(idx.FSMonitor)
+		if err != nil {
+			return err
+		}
+
+		idx.fsMonitorDirty = dirty
+	case bytes.Equal(header[:], linkExtSignature):
+		idx.Link = &Link{}
+		d := &linkDecoder{r}
+		if err := d.Decode(idx.Link); err != nil {
+			return err
+		}
+	case bytes.Equal(header[:], entryOffsetTableExtSignature):
+		idx.EntryOffsetTable = &EntryOffsetTable{}
+		d := &entryOffsetTableDecoder{r}
+		if err := d.Decode(idx.EntryOffsetTable); err != nil {
+			return err
+		}
+	case bytes.Equal(header[:], sparseDirExtSignature):
+		idx.Sparse = true
 	default:
 		// See https://git-scm.com/docs/index-format, which says:
 		// If the first byte is 'A'..'Z' the extension is optional and can be ignored.
@@ -287,12 +323,17 @@ func (d *Decoder) readExtension(idx *Index) error {
 		}
 
 		d := &unknownExtensionDecoder{r}
-		if err := d.Decode(); err != nil {
+		ext := UnknownExtension{Signature: header}
+		if err := d.Decode(&ext); err != nil {
 			return err
 		}
+
+		idx.UnknownExtensions = append(idx.UnknownExtensions, ext)
 	}
 
-	return nil
+	// Skip any trailing data not read by the extension decoders.
+	_, err = io.Copy(io.Discard, r)
+	return err
 }
 
 func (d *Decoder) getExtensionReader() (*bufio.Reader, error) {
@@ -495,17 +536,244 @@ type unknownExtensionDecoder struct {
 	r *bufio.Reader
 }
 
-func (d *unknownExtensionDecoder) Decode() error {
-	var buf [1024]byte
+func (d *unknownExtensionDecoder) Decode(ext *UnknownExtension) error {
+	var err error
+	ext.Data, err = io.ReadAll(d.r)
+	return err
+}
 
-	for {
-		_, err := d.r.Read(buf[:])
-		if err == io.EOF {
-			break
+func readStatData(r io.Reader, sd *StatData) error {
+	var sec, nsec, msec, mnsec uint32
+	if err := binary.Read(r, &sec, &nsec, &msec, &mnsec,
+		&sd.Dev, &sd.Inode, &sd.UID, &sd.GID, &sd.Size); err != nil {
+		return err
+	}
+
+	if sec != 0 || nsec != 0 {
+		sd.CreatedAt = time.Unix(int64(sec), int64(nsec))
+	}
+
+	if msec != 0 || mnsec != 0 {
+		sd.ModifiedAt = time.Unix(int64(msec), int64(mnsec))
+	}
+
+	return nil
+}
+
+type untrackedCacheDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *untrackedCacheDecoder) Decode(c *UntrackedCache) error {
+	n, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return err
+	}
+
+	ident := make([]byte, n)
+	if _, err := io.ReadFull(d.r, ident); err != nil {
+		return err
+	}
+
+	c.Ident = string(ident)
+	if err := readStatData(d.r, &c.InfoExcludeStat); err != nil {
+		return err
+	}
+
+	if err := readStatData(d.r, &c.ExcludesFileStat); err != nil {
+		return err
+	}
+
+	if c.DirFlags, err = binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+
+	if _, err := c.InfoExcludeHash.ReadFrom(d.r); err != nil {
+		return err
+	}
+
+	if _, err := c.ExcludesFileHash.ReadFrom(d.r); err != nil {
+		return err
+	}
+
+	name, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+	if err != nil {
+		return err
+	}
+
+	c.ExcludePerDir = string(name)
+	count, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil || count == 0 {
+		return err
+	}
+
+	var dirs []*UntrackedCacheDir
+	if c.Root, err = d.readDir(&dirs); err != nil {
+		return err
+	}
+
+	if int64(len(dirs)) != count {
+		return fmt.Errorf("untracked cache: %d directories, expected %d", len(dirs), count)
+	}
+
+	return d.readDirData(dirs)
+}
+
+// readDir reads a directory block and the ones of its subdirectories,
+// appending them to dirs in depth-first order.
+func (d *untrackedCacheDecoder) readDir(dirs *[]*UntrackedCacheDir) (*UntrackedCacheDir, error) {
+	untracked, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	subdirs, err := binary.ReadVariableWidthInt(d.r)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+	if err != nil {
+		return nil, err
+	}
+
+	dir := &UntrackedCacheDir{Name: string(name)}
+	*dirs = append(*dirs, dir)
+	for i := int64(0); i < untracked; i++ {
+		name, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+		if err != nil {
+			return nil, err
 		}
+
+		dir.Untracked = append(dir.Untracked, string(name))
+	}
+
+	for i := int64(0); i < subdirs; i++ {
+		sub, err := d.readDir(dirs)
 		if err != nil {
+			return nil, err
+		}
+
+		dir.Dirs = append(dir.Dirs, sub)
+	}
+
+	return dir, nil
+}
+
+// readDirData reads the bitmaps, stat data and hashes of the directories.
+func (d *untrackedCacheDecoder) readDirData(dirs []*UntrackedCacheDir) error {
+	var bitmaps [3][]bool
+	for i := range bitmaps {
+		var err error
+		if bitmaps[i], err = readBitmap(d.r); err != nil {
 			return err
 		}
 	}
+
+	valid, checkOnly, hashValid := bitmaps[0], bitmaps[1], bitmaps[2]
+	for i, dir := range dirs {
+		dir.CheckOnly = i < len(checkOnly) && checkOnly[i]
+		if i < len(valid) && valid[i] {
+			dir.Valid = true
+			if err := readStatData(d.r, &dir.Stat); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, dir := range dirs {
+		if i < len(hashValid) && hashValid[i] {
+			if _, err := dir.ExcludeHash.ReadFrom(d.r); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
+
+type fsMonitorDecoder struct {
+	r *bufio.Reader
+}
+
+// Decode decodes the extension into m, returning the bitmap of the entries
+// not valid.
+func (d *fsMonitorDecoder) Decode(m *FSMonitor) ([]bool, error) {
+	var err error
+	if m.Version, err = binary.ReadUint32(d.r); err != nil {
+		return nil, err
+	}
+
+	switch m.Version {
+	case 1:
+		since, err := binary.ReadUint64(d.r)
+		if err != nil {
+			return nil, err
+		}
+
+		m.Token = strconv.FormatUint(since, 10)
+	case 2:
+		token, err := binary.ReadUntilFromBufioReader(d.r, '\x00')
+		if err != nil {
+			return nil, err
+		}
+
+		m.Token = string(token)
+	default:
+		return nil, fmt.Errorf("fsmonitor: unsupported version %d", m.Version)
+	}
+
+	// The size of the bitmap, in bytes.
+	if _, err := binary.ReadUint32(d.r); err != nil {
+		return nil, err
+	}
+
+	return readBitmap(d.r)
+}
+
+type linkDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *linkDecoder) Decode(l *Link) error {
+	if _, err := l.SharedIndex.ReadFrom(d.r); err != nil {
+		return err
+	}
+
+	// The bitmaps are omitted when empty.
+	if _, err := d.r.Peek(1); err == io.EOF {
+		return nil
+	}
+
+	var err error
+	if l.Delete, err = readBitmap(d.r); err != nil {
+		return err
+	}
+
+	l.Replace, err = readBitmap(d.r)
+	return err
+}
+
+type entryOffsetTableDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *entryOffsetTableDecoder) Decode(t *EntryOffsetTable) error {
+	var err error
+	if t.Version, err = binary.ReadUint32(d.r); err != nil {
+		return err
+	}
+
+	for {
+		var b EntryOffsetBlock
+		if err := binary.Read(d.r, &b.Offset, &b.Count); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+
+			return err
+		}
+
+		t.Blocks = append(t.Blocks, b)
+	}
+}
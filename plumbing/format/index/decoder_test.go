@@ -262,6 +262,10 @@ func (s *IndexSuite) TestDecodeUnknownOptionalExt() {
 	d := NewDecoder(f)
 	err := d.Decode(idx)
 	s.NoError(err)
+	s.Equal([]UnknownExtension{{
+		Signature: [4]byte{'T', 'E', 'S', 'T'},
+		Data:      []byte("testdata"),
+	}}, idx.UnknownExtensions)
 }
 
 func (s *IndexSuite) TestDecodeUnknownMandatoryExt() {
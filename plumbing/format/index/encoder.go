@@ -8,6 +8,7 @@ import (
 	"io"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,14 +30,18 @@ type Encoder struct {
 	w         io.Writer
 	hash      hash.Hash
 	lastEntry *Entry
+	// offset is the number of bytes written.
+	offset *offsetWriter
+	// fullName forces the next name to be written in full in version 4.
+	fullName bool
 }
 
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer) *Encoder {
 	// TODO: Support passing an ObjectFormat (sha256)
 	h := hash.New(crypto.SHA1)
-	mw := io.MultiWriter(w, h)
-	return &Encoder{mw, h, nil}
+	ow := &offsetWriter{w: io.MultiWriter(w, h)}
+	return &Encoder{w: ow, hash: h, offset: ow}
 }
 
 // Encode writes the Index to the stream of the encoder.
@@ -45,11 +50,13 @@ func (e *Encoder) Encode(idx *Index) error {
 }
 
 func (e *Encoder) encode(idx *Index, footer bool) error {
-	// TODO: support extensions
+	// TODO: support the TREE and REUC extensions, they are dropped since
+	// they are not kept up to date when the entries change.
 	if idx.Version > EncodeVersionSupported {
 		return ErrUnsupportedVersion
 	}
 
+	idx.syncUntracked()
 	if err := e.encodeHeader(idx); err != nil {
 		return err
 	}
@@ -58,10 +65,15 @@ func (e *Encoder) encode(idx *Index, footer bool) error {
 		return err
 	}
 
-	if footer {
-		return e.encodeFooter()
+	if !footer {
+		return nil
 	}
-	return nil
+
+	if err := e.encodeExtensions(idx); err != nil {
+		return err
+	}
+
+	return e.encodeFooter()
 }
 
 func (e *Encoder) encodeHeader(idx *Index) error {
@@ -73,9 +85,32 @@ func (e *Encoder) encodeHeader(idx *Index) error {
 }
 
 func (e *Encoder) encodeEntries(idx *Index) error {
-	sort.Sort(byName(idx.Entries))
+	// The entries of a split index are in the order of the shared index, see
+	// SplitIndex.
+	if idx.Link == nil {
+		sort.Sort(byName(idx.Entries))
+	}
+
+	// The entries are split in as many blocks as before.
+	var blockSize int
+	if idx.EntryOffsetTable != nil {
+		n := max(len(idx.EntryOffsetTable.Blocks), 1)
+		blockSize = max((len(idx.Entries)+n-1)/n, 1)
+		idx.EntryOffsetTable.Blocks = nil
+	}
+
+	for i, entry := range idx.Entries {
+		if blockSize > 0 {
+			if i%blockSize == 0 {
+				// Each block must be readable on its own.
+				e.fullName = true
+				idx.EntryOffsetTable.Blocks = append(idx.EntryOffsetTable.Blocks,
+					EntryOffsetBlock{Offset: uint32(e.offset.n)})
+			}
+
+			idx.EntryOffsetTable.Blocks[len(idx.EntryOffsetTable.Blocks)-1].Count++
+		}
 
-	for _, entry := range idx.Entries {
 		if err := e.encodeEntry(idx, entry); err != nil {
 			return err
 		}
@@ -165,7 +200,7 @@ func (e *Encoder) encodeEntryNameV4(entry *Entry) error {
 	l := 0
 	if e.lastEntry != nil {
 		dir := path.Dir(e.lastEntry.Name) + "/"
-		if strings.HasPrefix(entry.Name, dir) {
+		if !e.fullName && strings.HasPrefix(entry.Name, dir) {
 			l = len(e.lastEntry.Name) - len(dir)
 			name = strings.TrimPrefix(entry.Name, dir)
 		} else {
@@ -174,6 +209,7 @@ func (e *Encoder) encodeEntryNameV4(entry *Entry) error {
 	}
 
 	e.lastEntry = entry
+	e.fullName = false
 
 	err := binary.WriteVariableWidthInt(e.w, int64(l))
 	if err != nil {
@@ -206,7 +242,278 @@ func (e *Encoder) encodeRawExtension(signature string, data []byte) error {
 	return nil
 }
 
+func (e *Encoder) encodeExtensions(idx *Index) error {
+	entriesEnd := e.offset.n
+
+	// The hash of the End of Index Entry extension is computed over the
+	// header of the other extensions.
+	headers := hash.New(crypto.SHA1)
+	write := func(signature []byte, encode func(io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			return err
+		}
+
+		if err := binary.Write(headers, signature, uint32(buf.Len())); err != nil {
+			return err
+		}
+
+		return e.encodeRawExtension(string(signature), buf.Bytes())
+	}
+
+	if t := idx.EntryOffsetTable; t != nil {
+		if err := write(entryOffsetTableExtSignature, func(w io.Writer) error {
+			return encodeEntryOffsetTable(w, t)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if l := idx.Link; l != nil {
+		if err := write(linkExtSignature, func(w io.Writer) error {
+			return encodeLink(w, l)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if c := idx.UntrackedCache; c != nil {
+		if err := write(untrackedCacheExtSignature, func(w io.Writer) error {
+			return encodeUntrackedCache(w, c)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m := idx.FSMonitor; m != nil {
+		dirty := idx.fsMonitorDirty
+		if idx.Link == nil {
+			dirty = make([]bool, len(idx.Entries))
+			for i, entry := range idx.Entries {
+				dirty[i] = !entry.FSMonitorValid
+			}
+		}
+
+		if err := write(fsMonitorExtSignature, func(w io.Writer) error {
+			return encodeFSMonitor(w, m, dirty)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if idx.Sparse {
+		if err := write(sparseDirExtSignature, func(io.Writer) error {
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, ext := range idx.UnknownExtensions {
+		if err := write(ext.Signature[:], func(w io.Writer) error {
+			_, err := w.Write(ext.Data)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	// The End of Index Entry extension is only useful to locate the offset
+	// table, and kept when present.
+	if idx.EndOfIndexEntry == nil && idx.EntryOffsetTable == nil {
+		return nil
+	}
+
+	idx.EndOfIndexEntry = &EndOfIndexEntry{Offset: uint32(entriesEnd)}
+	idx.EndOfIndexEntry.Hash.Write(headers.Sum(nil))
+
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, idx.EndOfIndexEntry.Offset, idx.EndOfIndexEntry.Hash.Bytes()); err != nil {
+		return err
+	}
+
+	return e.encodeRawExtension(string(endOfIndexEntryExtSignature), buf.Bytes())
+}
+
+func encodeEntryOffsetTable(w io.Writer, t *EntryOffsetTable) error {
+	version := t.Version
+	if version == 0 {
+		version = 1
+	}
+
+	if err := binary.WriteUint32(w, version); err != nil {
+		return err
+	}
+
+	for _, b := range t.Blocks {
+		if err := binary.Write(w, b.Offset, b.Count); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeLink(w io.Writer, l *Link) error {
+	if _, err := w.Write(l.SharedIndex.Bytes()); err != nil {
+		return err
+	}
+
+	if l.Delete == nil && l.Replace == nil {
+		return nil
+	}
+
+	if err := writeBitmap(w, l.Delete); err != nil {
+		return err
+	}
+
+	return writeBitmap(w, l.Replace)
+}
+
+func encodeStatData(w io.Writer, sd *StatData) error {
+	sec, nsec, err := timeToUint32(&sd.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	msec, mnsec, err := timeToUint32(&sd.ModifiedAt)
+	if err != nil {
+		return err
+	}
+
+	return binary.Write(w, sec, nsec, msec, mnsec, sd.Dev, sd.Inode, sd.UID, sd.GID, sd.Size)
+}
+
+func encodeUntrackedCache(w io.Writer, c *UntrackedCache) error {
+	if err := binary.WriteVariableWidthInt(w, int64(len(c.Ident))); err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, c.Ident); err != nil {
+		return err
+	}
+
+	if err := encodeStatData(w, &c.InfoExcludeStat); err != nil {
+		return err
+	}
+
+	if err := encodeStatData(w, &c.ExcludesFileStat); err != nil {
+		return err
+	}
+
+	if err := binary.Write(w, c.DirFlags, c.InfoExcludeHash.Bytes(), c.ExcludesFileHash.Bytes(),
+		[]byte(c.ExcludePerDir+"\x00")); err != nil {
+		return err
+	}
+
+	var dirs []*UntrackedCacheDir
+	if c.Root != nil {
+		dirs = c.Root.walk(nil)
+	}
+
+	if err := binary.WriteVariableWidthInt(w, int64(len(dirs))); err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		return nil
+	}
+
+	valid := make([]bool, len(dirs))
+	checkOnly := make([]bool, len(dirs))
+	hashValid := make([]bool, len(dirs))
+	for i, d := range dirs {
+		valid[i] = d.Valid
+		checkOnly[i] = d.Valid && d.CheckOnly
+		hashValid[i] = !d.ExcludeHash.IsZero()
+
+		var untracked []string
+		if d.Valid {
+			untracked = d.Untracked
+		}
+
+		if err := binary.WriteVariableWidthInt(w, int64(len(untracked))); err != nil {
+			return err
+		}
+
+		if err := binary.WriteVariableWidthInt(w, int64(len(d.Dirs))); err != nil {
+			return err
+		}
+
+		for _, name := range append([]string{d.Name}, untracked...) {
+			if _, err := io.WriteString(w, name+"\x00"); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, bits := range [][]bool{valid, checkOnly, hashValid} {
+		if err := writeBitmap(w, bits); err != nil {
+			return err
+		}
+	}
+
+	for _, d := range dirs {
+		if d.Valid {
+			if err := encodeStatData(w, &d.Stat); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, d := range dirs {
+		if !d.ExcludeHash.IsZero() {
+			if _, err := w.Write(d.ExcludeHash.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := w.Write([]byte{0})
+	return err
+}
+
+func encodeFSMonitor(w io.Writer, m *FSMonitor, dirty []bool) error {
+	if err := binary.WriteUint32(w, m.Version); err != nil {
+		return err
+	}
+
+	switch m.Version {
+	case 1:
+		since, err := strconv.ParseUint(m.Token, 10, 64)
+		if err != nil {
+			return fmt.Errorf("fsmonitor: invalid version 1 token %q", m.Token)
+		}
+
+		if err := binary.WriteUint64(w, since); err != nil {
+			return err
+		}
+	case 2:
+		if _, err := io.WriteString(w, m.Token+"\x00"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("fsmonitor: unsupported version %d", m.Version)
+	}
+
+	var bitmap bytes.Buffer
+	if err := writeBitmap(&bitmap, dirty); err != nil {
+		return err
+	}
+
+	if err := binary.WriteUint32(w, uint32(bitmap.Len())); err != nil {
+		return err
+	}
+
+	_, err := w.Write(bitmap.Bytes())
+	return err
+}
+
 func (e *Encoder) timeToUint32(t *time.Time) (uint32, uint32, error) {
+	return timeToUint32(t)
+}
+
+func timeToUint32(t *time.Time) (uint32, uint32, error) {
 	if t.IsZero() {
 		return 0, 0, nil
 	}
@@ -233,8 +540,24 @@ func (e *Encoder) encodeFooter() error {
 	return binary.Write(e.w, e.hash.Sum(nil))
 }
 
+// offsetWriter counts the bytes written to w.
+type offsetWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
 type byName []*Entry
 
-func (l byName) Len() int           { return len(l) }
-func (l byName) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
-func (l byName) Less(i, j int) bool { return l[i].Name < l[j].Name }
+func (l byName) Len() int      { return len(l) }
+func (l byName) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+
+// Less orders the entries by name, and by stage for the conflicts.
+func (l byName) Less(i, j int) bool {
+	return l[i].Name < l[j].Name || (l[i].Name == l[j].Name && l[i].Stage < l[j].Stage)
+}
@@ -160,3 +160,128 @@ func TestEncodeWithSkipWorktreeUnsupportedVersion(t *testing.T) {
 	assert.EqualExportedValues(t, idx, output)
 	assert.Equal(t, true, output.Entries[0].SkipWorktree)
 }
+
+func TestEncodeExtensions(t *testing.T) {
+	stat := StatData{
+		CreatedAt:  time.Unix(1700000000, 42),
+		ModifiedAt: time.Unix(1700000001, 84),
+		Dev:        1,
+		Inode:      2,
+		Size:       3,
+	}
+
+	idx := &Index{
+		Version: 4,
+		UntrackedCache: &UntrackedCache{
+			Ident:           "location /tmp/foo\x00system Linux\x00",
+			InfoExcludeStat: stat,
+			DirFlags:        6,
+			InfoExcludeHash: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3"),
+			ExcludePerDir:   ".gitignore",
+			Root: &UntrackedCacheDir{
+				Valid:     true,
+				Stat:      stat,
+				Untracked: []string{"qux", "vendor/"},
+				Dirs: []*UntrackedCacheDir{{
+					Name:        "baz",
+					Valid:       true,
+					CheckOnly:   true,
+					Stat:        stat,
+					Untracked:   []string{"untracked"},
+					ExcludeHash: plumbing.NewHash("9a48f23120e880dfbe41f7c9b7b708e9ee62a492"),
+				}, {
+					Name: "invalid",
+				}},
+			},
+		},
+		FSMonitor: &FSMonitor{Version: 2, Token: "token"},
+		EntryOffsetTable: &EntryOffsetTable{
+			Version: 1,
+			Blocks:  make([]EntryOffsetBlock, 2),
+		},
+		UnknownExtensions: []UnknownExtension{{
+			Signature: [4]byte{'T', 'E', 'S', 'T'},
+			Data:      []byte("testdata"),
+		}},
+	}
+
+	for i, name := range []string{"bar", "baz/bar", "baz/foo", "foo", "qux/bar"} {
+		e := idx.Add(name)
+		e.Size = uint32(i)
+		e.FSMonitorValid = i%2 == 0
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := NewEncoder(buf).Encode(idx)
+	require.NoError(t, err)
+
+	output := &Index{}
+	err = NewDecoder(buf).Decode(output)
+	require.NoError(t, err)
+
+	assert.Equal(t, []EntryOffsetBlock{
+		{Offset: 12, Count: 3},
+		{Offset: idx.EntryOffsetTable.Blocks[1].Offset, Count: 2},
+	}, output.EntryOffsetTable.Blocks)
+	require.NotNil(t, output.EndOfIndexEntry)
+	assert.EqualExportedValues(t, idx, output)
+}
+
+func TestEncodeUntrackedCacheInvalidation(t *testing.T) {
+	idx := &Index{
+		Version: 2,
+		UntrackedCache: &UntrackedCache{
+			Root: &UntrackedCacheDir{
+				Valid:     true,
+				Untracked: []string{"foo"},
+				Dirs: []*UntrackedCacheDir{{
+					Name:      "bar",
+					Valid:     true,
+					Untracked: []string{"baz", "qux"},
+				}},
+			},
+		},
+	}
+	idx.Add("foo")
+	idx.Add("bar/qux")
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).Encode(idx))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+
+	// The directories of the entries added to the index are invalidated.
+	output.Add("bar/baz")
+
+	buf.Reset()
+	require.NoError(t, NewEncoder(buf).Encode(output))
+
+	output = &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+
+	root := output.UntrackedCache.Root
+	assert.True(t, root.Valid)
+	assert.Equal(t, []string{"foo"}, root.Untracked)
+	assert.False(t, root.Dirs[0].Valid)
+	assert.Nil(t, root.Dirs[0].Untracked)
+}
+
+func TestEncodeFSMonitorV1(t *testing.T) {
+	idx := &Index{
+		Version:   2,
+		FSMonitor: &FSMonitor{Version: 1, Token: "1700000000000000000"},
+	}
+	idx.Add("foo").FSMonitorValid = true
+	idx.Add("bar")
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).Encode(idx))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+	assert.EqualExportedValues(t, idx, output)
+
+	idx.FSMonitor.Token = "token"
+	assert.Error(t, NewEncoder(buf).Encode(idx))
+}
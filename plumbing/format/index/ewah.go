@@ -0,0 +1,95 @@
+package index
+
+import (
+	"errors"
+	"io"
+
+	"github.com/go-git/go-git/v6/utils/binary"
+)
+
+// ErrMalformedBitmap is returned when decoding an invalid EWAH bitmap.
+var ErrMalformedBitmap = errors.New("malformed ewah bitmap")
+
+// readBitmap reads an EWAH compressed bitmap, as serialized by git, returning
+// its bits.
+//
+// The bitmap is a sequence of 64-bit words: each marker word holds a run of
+// words of identical bits, followed by a number of literal words.
+func readBitmap(r io.Reader) ([]bool, error) {
+	var size, count uint32
+	if err := binary.Read(r, &size, &count); err != nil {
+		return nil, err
+	}
+
+	words := make([]uint64, count)
+	for i := range words {
+		w, err := binary.ReadUint64(r)
+		if err != nil {
+			return nil, err
+		}
+
+		words[i] = w
+	}
+
+	// The position of the last marker word is only used to append to the
+	// bitmap.
+	if _, err := binary.ReadUint32(r); err != nil {
+		return nil, err
+	}
+
+	bits := make([]bool, size)
+	pos := uint64(0)
+	for i := 0; i < len(words); {
+		marker := words[i]
+		i++
+
+		run := (marker >> 1) & 0xffffffff
+		literals := int(marker >> 33)
+		for end := min(pos+run*64, uint64(size)); pos < end; pos++ {
+			bits[pos] = marker&1 != 0
+		}
+
+		if i+literals > len(words) {
+			return nil, ErrMalformedBitmap
+		}
+
+		for _, w := range words[i : i+literals] {
+			for b := uint64(0); b < 64 && pos < uint64(size); b++ {
+				bits[pos] = w&(1<<b) != 0
+				pos++
+			}
+		}
+
+		i += literals
+	}
+
+	return bits, nil
+}
+
+// writeBitmap writes bits as an EWAH compressed bitmap, as a single marker
+// word followed by literal words.
+func writeBitmap(w io.Writer, bits []bool) error {
+	size := len(bits)
+	for size > 0 && !bits[size-1] {
+		size--
+	}
+
+	literals := make([]uint64, (size+63)/64)
+	for i, set := range bits[:size] {
+		if set {
+			literals[i/64] |= 1 << (i % 64)
+		}
+	}
+
+	if err := binary.Write(w, uint32(size), uint32(len(literals)+1), uint64(len(literals))<<33); err != nil {
+		return err
+	}
+
+	for _, l := range literals {
+		if err := binary.WriteUint64(w, l); err != nil {
+			return err
+		}
+	}
+
+	return binary.WriteUint32(w, 0)
+}
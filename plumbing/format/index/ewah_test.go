@@ -0,0 +1,48 @@
+package index
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/utils/binary"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBitmap(t *testing.T) {
+	// A run of two words of ones, followed by a literal word.
+	buf := bytes.NewBuffer(nil)
+	err := binary.Write(buf, uint32(131), uint32(2), uint64(1)<<33|uint64(2)<<1|1, uint64(0b101), uint32(0))
+	require.NoError(t, err)
+
+	bits, err := readBitmap(buf)
+	require.NoError(t, err)
+	require.Len(t, bits, 131)
+	for i := 0; i < 128; i++ {
+		assert.True(t, bits[i])
+	}
+
+	assert.Equal(t, []bool{true, false, true}, bits[128:])
+}
+
+func TestReadBitmapMalformed(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	err := binary.Write(buf, uint32(64), uint32(1), uint64(1)<<33, uint32(0))
+	require.NoError(t, err)
+
+	_, err = readBitmap(buf)
+	assert.ErrorIs(t, err, ErrMalformedBitmap)
+}
+
+func TestWriteBitmap(t *testing.T) {
+	bits := make([]bool, 200)
+	bits[0], bits[63], bits[64], bits[150] = true, true, true, true
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, writeBitmap(buf, bits))
+
+	got, err := readBitmap(buf)
+	require.NoError(t, err)
+	assert.Equal(t, bits[:151], got)
+	assert.Zero(t, buf.Len())
+}
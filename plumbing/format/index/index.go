@@ -19,10 +19,15 @@ var (
 	// ErrEntryNotFound is returned by Index.Entry, if an entry is not found.
 	ErrEntryNotFound = errors.New("entry not found")
 
-	indexSignature              = []byte{'D', 'I', 'R', 'C'}
-	treeExtSignature            = []byte{'T', 'R', 'E', 'E'}
-	resolveUndoExtSignature     = []byte{'R', 'E', 'U', 'C'}
-	endOfIndexEntryExtSignature = []byte{'E', 'O', 'I', 'E'}
+	indexSignature               = []byte{'D', 'I', 'R', 'C'}
+	treeExtSignature             = []byte{'T', 'R', 'E', 'E'}
+	resolveUndoExtSignature      = []byte{'R', 'E', 'U', 'C'}
+	endOfIndexEntryExtSignature  = []byte{'E', 'O', 'I', 'E'}
+	untrackedCacheExtSignature   = []byte{'U', 'N', 'T', 'R'}
+	fsMonitorExtSignature        = []byte{'F', 'S', 'M', 'N'}
+	linkExtSignature             = []byte{'l', 'i', 'n', 'k'}
+	entryOffsetTableExtSignature = []byte{'I', 'E', 'O', 'T'}
+	sparseDirExtSignature        = []byte{'s', 'd', 'i', 'r'}
 )
 
 // Stage during merge
@@ -54,6 +59,30 @@ type Index struct {
 	ResolveUndo *ResolveUndo
 	// EndOfIndexEntry represents the 'End of Index Entry' extension
 	EndOfIndexEntry *EndOfIndexEntry
+	// UntrackedCache represents the 'Untracked cache' extension
+	UntrackedCache *UntrackedCache
+	// FSMonitor represents the 'File System Monitor cache' extension
+	FSMonitor *FSMonitor
+	// Link represents the 'Split index' extension
+	Link *Link
+	// EntryOffsetTable represents the 'Index Entry Offset Table' extension
+	EntryOffsetTable *EntryOffsetTable
+	// Sparse is set for a sparse index, holding sparse directory entries,
+	// see Expand and Collapse
+	Sparse bool
+	// UnknownExtensions are the optional extensions not supported, kept
+	// untouched when the index is written
+	UnknownExtensions []UnknownExtension
+
+	// shared is the shared index the entries were merged with, see
+	// MergeSharedIndex.
+	shared *Index
+	// fsMonitorDirty is the bitmap of the FSMN extension, applied to the
+	// entries once they are all known.
+	fsMonitorDirty []bool
+	// tracked are the names of the entries when read, used to invalidate
+	// the untracked cache on write.
+	tracked map[string]struct{}
 }
 
 // Add creates a new Entry and returns it. The caller should first check that
@@ -149,6 +178,9 @@ type Entry struct {
 	// IntentToAdd record only the fact that the path will be added later
 	// https://git-scm.com/docs/git-add ("git add -N")
 	IntentToAdd bool
+	// FSMonitorValid is set when the file is known to be unchanged since the
+	// last query to the file system monitor, see FSMonitor
+	FSMonitorValid bool
 }
 
 func (e Entry) String() string {
@@ -213,6 +245,163 @@ type EndOfIndexEntry struct {
 	Hash plumbing.Hash
 }
 
+// StatData is the stat data of a file or directory, as stored in the index.
+type StatData struct {
+	CreatedAt  time.Time
+	ModifiedAt time.Time
+	Dev, Inode uint32
+	UID, GID   uint32
+	Size       uint32
+}
+
+// UntrackedCache is the untracked cache extension, caching the untracked
+// files of the directories of the worktree along with the stat data used to
+// validate it.
+// https://git-scm.com/docs/index-format#_untracked_cache
+type UntrackedCache struct {
+	// Ident describes the environment where the cache can be used, kept as
+	// is.
+	Ident string
+	// InfoExcludeStat and ExcludesFileStat are the stat data of
+	// $GIT_DIR/info/exclude and core.excludesFile.
+	InfoExcludeStat  StatData
+	ExcludesFileStat StatData
+	// DirFlags are the flags of the directory traversal the cache was
+	// computed with.
+	DirFlags uint32
+	// InfoExcludeHash and ExcludesFileHash are the hashes of
+	// $GIT_DIR/info/exclude and core.excludesFile, zero when missing.
+	InfoExcludeHash  plumbing.Hash
+	ExcludesFileHash plumbing.Hash
+	// ExcludePerDir is the name of the per-directory exclude file, usually
+	// .gitignore.
+	ExcludePerDir string
+	// Root is the worktree root directory, nil when nothing is cached.
+	Root *UntrackedCacheDir
+}
+
+// UntrackedCacheDir is a directory of the untracked cache.
+type UntrackedCacheDir struct {
+	// Name is the name of the directory, empty for the root.
+	Name string
+	// Untracked are the untracked files and directories, the latter with a
+	// trailing slash.
+	Untracked []string
+	// Dirs are the cached subdirectories.
+	Dirs []*UntrackedCacheDir
+	// Valid is set when Untracked is up to date with Stat.
+	Valid bool
+	// CheckOnly is set when the directory was only checked for untracked
+	// files, not listed.
+	CheckOnly bool
+	// Stat is the stat data of the directory, set when Valid.
+	Stat StatData
+	// ExcludeHash is the hash of the per-directory exclude file, zero when
+	// missing.
+	ExcludeHash plumbing.Hash
+}
+
+// untrackedShowOtherDirectories is the flag of DirFlags listing untracked
+// directories as a whole.
+const untrackedShowOtherDirectories = 1 << 1
+
+// Invalidate invalidates the cache of the directory of path, after it was
+// added to or removed from the index. The parent directories are invalidated
+// too when the untracked directories are listed as a whole.
+func (c *UntrackedCache) Invalidate(path string) {
+	if c.Root != nil {
+		c.invalidate(c.Root, path)
+	}
+}
+
+func (c *UntrackedCache) invalidate(dir *UntrackedCacheDir, path string) bool {
+	component, rest, found := strings.Cut(path, "/")
+	if found {
+		parents := c.DirFlags&untrackedShowOtherDirectories != 0
+		for _, d := range dir.Dirs {
+			if d.Name == component {
+				parents = c.invalidate(d, rest)
+				break
+			}
+		}
+
+		if parents {
+			dir.invalidate()
+		}
+
+		return parents
+	}
+
+	dir.invalidate()
+	return c.DirFlags&untrackedShowOtherDirectories != 0
+}
+
+// walk appends d and its subdirectories to dirs, in depth-first order.
+func (d *UntrackedCacheDir) walk(dirs []*UntrackedCacheDir) []*UntrackedCacheDir {
+	dirs = append(dirs, d)
+	for _, sub := range d.Dirs {
+		dirs = sub.walk(dirs)
+	}
+
+	return dirs
+}
+
+func (d *UntrackedCacheDir) invalidate() {
+	d.Valid = false
+	d.CheckOnly = false
+	d.Untracked = nil
+}
+
+// FSMonitor is the file system monitor cache extension, recording the token
+// of the last query to the file system monitor. The entries unchanged since
+// then have their FSMonitorValid flag set.
+// https://git-scm.com/docs/index-format#_file_system_monitor_cache
+type FSMonitor struct {
+	// Version is the version of the extension, 1 or 2.
+	Version uint32
+	// Token is the token of the last query: the time of the query in
+	// nanoseconds since the epoch in version 1, and an opaque string given by
+	// the monitor in version 2.
+	Token string
+}
+
+// Link is the split index extension: the index only holds the entries
+// changed since the shared index it links to, stored in a
+// $GIT_DIR/sharedindex.<hash> file. See MergeSharedIndex and SplitIndex.
+// https://git-scm.com/docs/index-format#_split_index
+type Link struct {
+	// SharedIndex is the hash of the shared index.
+	SharedIndex plumbing.Hash
+	// Delete and Replace mark the entries of the shared index deleted and
+	// replaced by the entries of this index, the n-th value being the one of
+	// the n-th entry of the shared index.
+	Delete  []bool
+	Replace []bool
+}
+
+// EntryOffsetTable is the index entry offset table extension, splitting the
+// entries in blocks to load them in parallel.
+// https://git-scm.com/docs/index-format#_index_entry_offset_table
+type EntryOffsetTable struct {
+	Version uint32
+	Blocks  []EntryOffsetBlock
+}
+
+// EntryOffsetBlock is a block of entries of an EntryOffsetTable.
+type EntryOffsetBlock struct {
+	// Offset is the offset of the first entry of the block from the start of
+	// the index file.
+	Offset uint32
+	// Count is the number of entries of the block.
+	Count uint32
+}
+
+// UnknownExtension is an optional extension not supported, kept as is.
+type UnknownExtension struct {
+	Signature [4]byte
+	Data      []byte
+}
+
 // SkipUnless applies patterns in the form of A, A/B, A/B/C
 // to the index to prevent the files from being checked out
 func (i *Index) SkipUnless(patterns []string) {
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+)
+
+// IsSparseDir reports whether the entry is the sparse directory entry of a
+// sparse index, standing for all the files of a directory out of the sparse
+// checkout: its name ends with a slash and its hash is the one of the tree
+// of the directory.
+func (e *Entry) IsSparseDir() bool {
+	return e.Mode == filemode.Dir
+}
+
+// Expand replaces the sparse directory entries of the index by the entries
+// of the files of their tree, returned by files given the directory and the
+// hash of its tree. The files get the skip-worktree bit. The index is kept
+// Sparse, to be collapsed again when written, see Collapse.
+func (i *Index) Expand(files func(dir string, tree plumbing.Hash) ([]*Entry, error)) error {
+	var entries []*Entry
+	for n, e := range i.Entries {
+		if !e.IsSparseDir() {
+			if entries != nil {
+				entries = append(entries, e)
+			}
+
+			continue
+		}
+
+		if entries == nil {
+			entries = append(make([]*Entry, 0, len(i.Entries)), i.Entries[:n]...)
+		}
+
+		fs, err := files(strings.TrimSuffix(e.Name, "/"), e.Hash)
+		if err != nil {
+			return err
+		}
+
+		for _, f := range fs {
+			f.SkipWorktree = true
+		}
+
+		entries = append(entries, fs...)
+	}
+
+	if entries != nil {
+		i.Entries = entries
+	}
+
+	return nil
+}
+
+// Collapse returns a copy of the index where the entries of the directories
+// holding only files with the skip-worktree bit are replaced by a sparse
+// directory entry, the hash of their tree being returned by tree given the
+// directory and its entries. The directories holding unmerged entries or
+// submodules are not collapsed.
+func (i *Index) Collapse(tree func(dir string, entries []*Entry) (plumbing.Hash, error)) (*Index, error) {
+	sorted := slices.Clone(i.Entries)
+	sort.Stable(byName(sorted))
+
+	// collapsible tells whether the directories can be collapsed.
+	collapsible := make(map[string]bool)
+	for _, e := range sorted {
+		ok := e.SkipWorktree && e.Stage == 0 && !e.IntentToAdd &&
+			e.Mode != filemode.Submodule && !e.IsSparseDir()
+		for dir := parentPath(e.Name); dir != ""; dir = parentPath(dir) {
+			if c, found := collapsible[dir]; !found || c {
+				collapsible[dir] = ok
+			}
+		}
+	}
+
+	entries := make([]*Entry, 0, len(sorted))
+	for n := 0; n < len(sorted); {
+		e := sorted[n]
+		dir := collapsedDir(collapsible, e.Name)
+		if dir == "" {
+			entries = append(entries, e)
+			n++
+			continue
+		}
+
+		prefix := dir + "/"
+		end := n + 1
+		for end < len(sorted) && strings.HasPrefix(sorted[end].Name, prefix) {
+			end++
+		}
+
+		h, err := tree(dir, sorted[n:end])
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, &Entry{
+			Name:         prefix,
+			Mode:         filemode.Dir,
+			Hash:         h,
+			SkipWorktree: true,
+		})
+		n = end
+	}
+
+	c := *i
+	c.Entries = entries
+	return &c, nil
+}
+
+// collapsedDir returns the top-most directory of the file at name which can
+// be collapsed, empty when there is none.
+func collapsedDir(collapsible map[string]bool, name string) string {
+	var top string
+	for dir := parentPath(name); dir != ""; dir = parentPath(dir) {
+		if collapsible[dir] {
+			top = dir
+		}
+	}
+
+	return top
+}
+
+func parentPath(name string) string {
+	i := strings.LastIndexByte(name, '/')
+	if i < 0 {
+		return ""
+	}
+
+	return name[:i]
+}
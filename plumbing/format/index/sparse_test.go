@@ -0,0 +1,69 @@
+package index
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSparseIndex(t *testing.T) {
+	idx := &Index{Version: 3, Sparse: true}
+	for _, name := range []string{"a", "d/c", "d/e/f", "g/h", "g/i", "j/k"} {
+		e := idx.Add(name)
+		e.Mode = filemode.Regular
+		e.Hash = plumbing.ComputeHash(plumbing.BlobObject, []byte(name))
+		e.SkipWorktree = name != "a" && name != "g/i"
+	}
+
+	// j/k is unmerged, its directory is not collapsed.
+	idx.Entries[5].Stage = OurMode
+
+	trees := make(map[plumbing.Hash][]*Entry)
+	collapsed, err := idx.Collapse(func(dir string, entries []*Entry) (plumbing.Hash, error) {
+		h := plumbing.ComputeHash(plumbing.TreeObject, []byte(dir))
+		trees[h] = entries
+		return h, nil
+	})
+	require.NoError(t, err)
+
+	var names []string
+	for _, e := range collapsed.Entries {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"a", "d/", "g/h", "g/i", "j/k"}, names)
+	assert.True(t, collapsed.Entries[1].IsSparseDir())
+	assert.True(t, collapsed.Entries[1].SkipWorktree)
+	assert.Len(t, idx.Entries, 6)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).Encode(collapsed))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+	assert.True(t, output.Sparse)
+	require.Len(t, output.Entries, 5)
+	assert.Equal(t, collapsed.Entries[1].Hash, output.Entries[1].Hash)
+
+	require.NoError(t, output.Expand(func(dir string, tree plumbing.Hash) ([]*Entry, error) {
+		assert.Equal(t, "d", dir)
+		var entries []*Entry
+		for _, e := range trees[tree] {
+			entries = append(entries, &Entry{Name: e.Name, Mode: e.Mode, Hash: e.Hash})
+		}
+
+		return entries, nil
+	}))
+
+	names = nil
+	for _, e := range output.Entries {
+		names = append(names, e.Name)
+		assert.Equal(t, e.Name != "a" && e.Name != "g/i", e.SkipWorktree, e.Name)
+		assert.False(t, strings.HasSuffix(e.Name, "/"))
+	}
+	assert.Equal(t, []string{"a", "d/c", "d/e/f", "g/h", "g/i", "j/k"}, names)
+}
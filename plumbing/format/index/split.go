@@ -0,0 +1,218 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrInvalidLink is returned when the split index does not match its shared
+// index.
+var ErrInvalidLink = errors.New("invalid split index link")
+
+type entryKey struct {
+	name  string
+	stage Stage
+}
+
+// MergeSharedIndex merges the entries of the split index i, holding the
+// entries changed since its shared index as described by the Link extension,
+// with the ones of shared. The entries of i are replaced by the merged ones,
+// and Link is kept so the index can be split again when written, see
+// SplitIndex.
+func (i *Index) MergeSharedIndex(shared *Index) error {
+	if i.Link == nil {
+		return fmt.Errorf("%w: not a split index", ErrInvalidLink)
+	}
+
+	entries := make([]*Entry, len(shared.Entries))
+	for n, e := range shared.Entries {
+		c := *e
+		entries[n] = &c
+	}
+
+	// The replacing entries come first, in the order of the shared index,
+	// with an empty name.
+	split := i.Entries
+	replaced := 0
+	for n, replace := range i.Link.Replace {
+		if !replace {
+			continue
+		}
+
+		if n >= len(entries) || replaced >= len(split) || split[replaced].Name != "" {
+			return fmt.Errorf("%w: bad replaced entry %d", ErrInvalidLink, n)
+		}
+
+		e := split[replaced]
+		e.Name = entries[n].Name
+		entries[n] = e
+		replaced++
+	}
+
+	merged := make([]*Entry, 0, len(entries)+len(split)-replaced)
+	positions := make(map[entryKey]int, len(entries))
+	for n, e := range entries {
+		if n < len(i.Link.Delete) && i.Link.Delete[n] {
+			continue
+		}
+
+		positions[entryKey{e.Name, e.Stage}] = len(merged)
+		merged = append(merged, e)
+	}
+
+	if len(i.Link.Delete) > len(entries) {
+		return fmt.Errorf("%w: %d entries deleted out of %d", ErrInvalidLink, len(i.Link.Delete), len(entries))
+	}
+
+	for _, e := range split[replaced:] {
+		if n, ok := positions[entryKey{e.Name, e.Stage}]; ok {
+			merged[n] = e
+			continue
+		}
+
+		merged = append(merged, e)
+	}
+
+	sort.Stable(byName(merged))
+	i.Entries = merged
+	i.shared = shared
+	i.resolveExtensions()
+	return nil
+}
+
+// SharedIndex returns the shared index the entries were merged with, nil if
+// MergeSharedIndex was not called.
+func (i *Index) SharedIndex() *Index {
+	return i.shared
+}
+
+// SplitIndex returns the split index to write instead of i, holding only the
+// entries that differ from the ones of shared, along with the extensions of
+// i. The Link extension of i must be set, giving the hash of shared.
+func (i *Index) SplitIndex(shared *Index) (*Index, error) {
+	if i.Link == nil {
+		return nil, fmt.Errorf("%w: not a split index", ErrInvalidLink)
+	}
+
+	i.syncUntracked()
+	sort.Stable(byName(i.Entries))
+
+	var dirty []bool
+	if i.FSMonitor != nil {
+		dirty = make([]bool, len(i.Entries))
+		for n, e := range i.Entries {
+			dirty[n] = !e.FSMonitorValid
+		}
+	}
+
+	current := make(map[entryKey]*Entry, len(i.Entries))
+	for _, e := range i.Entries {
+		current[entryKey{e.Name, e.Stage}] = e
+	}
+
+	link := &Link{
+		SharedIndex: i.Link.SharedIndex,
+		Delete:      make([]bool, len(shared.Entries)),
+		Replace:     make([]bool, len(shared.Entries)),
+	}
+
+	var replaced []*Entry
+	inShared := make(map[entryKey]struct{}, len(shared.Entries))
+	for n, se := range shared.Entries {
+		k := entryKey{se.Name, se.Stage}
+		inShared[k] = struct{}{}
+
+		e, ok := current[k]
+		switch {
+		case !ok:
+			link.Delete[n] = true
+		case !sameEntry(e, se):
+			link.Replace[n] = true
+			c := *e
+			c.Name = ""
+			replaced = append(replaced, &c)
+		}
+	}
+
+	entries := replaced
+	for _, e := range i.Entries {
+		if _, ok := inShared[entryKey{e.Name, e.Stage}]; !ok {
+			entries = append(entries, e)
+		}
+	}
+
+	return &Index{
+		Version:           i.Version,
+		Entries:           entries,
+		Cache:             i.Cache,
+		ResolveUndo:       i.ResolveUndo,
+		EndOfIndexEntry:   i.EndOfIndexEntry,
+		UntrackedCache:    i.UntrackedCache,
+		FSMonitor:         i.FSMonitor,
+		Link:              link,
+		EntryOffsetTable:  i.EntryOffsetTable,
+		UnknownExtensions: i.UnknownExtensions,
+		fsMonitorDirty:    dirty,
+	}, nil
+}
+
+// sameEntry reports whether a and b hold the same data, regardless of their
+// FSMonitorValid flag.
+func sameEntry(a, b *Entry) bool {
+	return a.Hash == b.Hash &&
+		a.Name == b.Name &&
+		a.CreatedAt.Equal(b.CreatedAt) &&
+		a.ModifiedAt.Equal(b.ModifiedAt) &&
+		a.Dev == b.Dev && a.Inode == b.Inode &&
+		a.Mode == b.Mode &&
+		a.UID == b.UID && a.GID == b.GID &&
+		a.Size == b.Size &&
+		a.Stage == b.Stage &&
+		a.SkipWorktree == b.SkipWorktree &&
+		a.IntentToAdd == b.IntentToAdd
+}
+
+// resolveExtensions applies the extensions depending on the entries, once
+// they are all known.
+func (i *Index) resolveExtensions() {
+	if i.FSMonitor != nil {
+		// The entries not in the bitmap are valid.
+		for n, e := range i.Entries {
+			e.FSMonitorValid = n >= len(i.fsMonitorDirty) || !i.fsMonitorDirty[n]
+		}
+	}
+
+	i.fsMonitorDirty = nil
+	i.tracked = nil
+	i.syncUntracked()
+}
+
+// syncUntracked invalidates the directories of the untracked cache where
+// entries were added or removed since the index was read.
+func (i *Index) syncUntracked() {
+	if i.UntrackedCache == nil {
+		return
+	}
+
+	names := make(map[string]struct{}, len(i.Entries))
+	for _, e := range i.Entries {
+		names[e.Name] = struct{}{}
+	}
+
+	if i.tracked != nil {
+		for name := range names {
+			if _, ok := i.tracked[name]; !ok {
+				i.UntrackedCache.Invalidate(name)
+			}
+		}
+
+		for name := range i.tracked {
+			if _, ok := names[name]; !ok {
+				i.UntrackedCache.Invalidate(name)
+			}
+		}
+	}
+
+	i.tracked = names
+}
@@ -0,0 +1,84 @@
+package index
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitIndex(t *testing.T) {
+	shared := &Index{Version: 2}
+	for _, name := range []string{"bar", "baz", "foo"} {
+		shared.Add(name).Size = 1
+	}
+
+	idx := &Index{
+		Version:   2,
+		FSMonitor: &FSMonitor{Version: 2, Token: "token"},
+		Link:      &Link{SharedIndex: plumbing.NewHash("e25b29c8946e0e192fae2edc1dabf7be71e8ecf3")},
+	}
+	for _, e := range shared.Entries {
+		c := *e
+		c.FSMonitorValid = true
+		idx.Entries = append(idx.Entries, &c)
+	}
+
+	_, err := idx.Remove("baz")
+	require.NoError(t, err)
+	e, err := idx.Entry("foo")
+	require.NoError(t, err)
+	e.Size = 2
+	e.FSMonitorValid = false
+	idx.Add("qux").Size = 3
+
+	split, err := idx.SplitIndex(shared)
+	require.NoError(t, err)
+	assert.Equal(t, []bool{false, true, false}, split.Link.Delete)
+	assert.Equal(t, []bool{false, false, true}, split.Link.Replace)
+	require.Len(t, split.Entries, 2)
+	assert.Equal(t, "", split.Entries[0].Name)
+	assert.Equal(t, "qux", split.Entries[1].Name)
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, NewEncoder(buf).Encode(split))
+
+	output := &Index{}
+	require.NoError(t, NewDecoder(buf).Decode(output))
+	require.NotNil(t, output.Link)
+	assert.Equal(t, idx.Link.SharedIndex, output.Link.SharedIndex)
+	assert.Len(t, output.Entries, 2)
+
+	require.NoError(t, output.MergeSharedIndex(shared))
+	assert.Same(t, shared, output.SharedIndex())
+
+	var names []string
+	for _, e := range output.Entries {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"bar", "foo", "qux"}, names)
+	assert.Equal(t, uint32(2), output.Entries[1].Size)
+	assert.True(t, output.Entries[0].FSMonitorValid)
+	assert.False(t, output.Entries[1].FSMonitorValid)
+	assert.False(t, output.Entries[2].FSMonitorValid)
+
+	// The shared index is left untouched.
+	assert.Len(t, shared.Entries, 3)
+	assert.Equal(t, uint32(1), shared.Entries[2].Size)
+}
+
+func TestMergeSharedIndexInvalidLink(t *testing.T) {
+	shared := &Index{Version: 2}
+	shared.Add("foo")
+
+	idx := &Index{
+		Version: 2,
+		Link:    &Link{Replace: []bool{true}},
+	}
+	idx.Add("foo")
+
+	assert.ErrorIs(t, idx.MergeSharedIndex(shared), ErrInvalidLink)
+	assert.ErrorIs(t, (&Index{}).MergeSharedIndex(shared), ErrInvalidLink)
+}
@@ -0,0 +1,265 @@
+package mbox
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// fromLineRegexp matches the From lines separating the messages of an mbox,
+// ending with a date.
+var fromLineRegexp = regexp.MustCompile(`^From (\S+) .*\d{4}$`)
+
+// Decoder reads the messages of an mbox, like the patches written by git
+// format-patch, as git am does.
+type Decoder struct {
+	r *bufio.Reader
+
+	// line is the From line of the next message, if read.
+	line string
+	eof  bool
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next message, io.EOF being returned when there is none.
+//
+// The author of the message is taken from its From and Date headers, and
+// its subject from the Subject header, without the prefixes in brackets
+// like "[PATCH 1/2]", stored in SubjectPrefix, and the "Re:" ones. The
+// From, Date and Subject lines starting the body, if any, override them.
+// The body is the commit message up to the "---" line, the Diff being the
+// rest of the message.
+func (d *Decoder) Decode() (*Message, error) {
+	lines, err := d.message()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Message{}
+	if len(lines) > 0 {
+		if match := fromLineRegexp.FindStringSubmatch(strings.TrimRight(lines[0], "\r\n")); match != nil {
+			if h, ok := plumbing.FromHex(match[1]); ok {
+				m.Hash = h
+			}
+
+			lines = lines[1:]
+		}
+	}
+
+	headers, lines := readHeaders(lines)
+	body := strings.Join(lines, "")
+	switch strings.ToLower(headers.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+		if err != nil {
+			return nil, err
+		}
+
+		body = string(decoded)
+	case "base64":
+		decoded, err := io.ReadAll(base64.NewDecoder(base64.StdEncoding, strings.NewReader(body)))
+		if err != nil {
+			return nil, err
+		}
+
+		body = string(decoded)
+	}
+
+	m.setHeaders(headers)
+
+	// The headers at the start of the body override the ones of the
+	// message.
+	lines = splitLines(body)
+	if len(lines) > 0 && isInBodyHeader(lines[0]) {
+		inBody, rest := readHeaders(lines)
+		m.setHeaders(inBody)
+		lines = rest
+	}
+
+	var msg []string
+	for i, line := range lines {
+		trimmed := strings.TrimRight(line, " \t\r\n")
+		if trimmed == "---" || strings.HasPrefix(line, "diff -") || strings.HasPrefix(line, "Index: ") {
+			m.Diff = strings.Join(lines[i:], "")
+			break
+		}
+
+		msg = append(msg, line)
+	}
+
+	m.Body = strings.TrimSpace(strings.Join(msg, ""))
+	return m, nil
+}
+
+// message returns the lines of the next message, starting with its From
+// line, if any.
+func (d *Decoder) message() ([]string, error) {
+	if d.eof {
+		return nil, io.EOF
+	}
+
+	var lines []string
+	if d.line != "" {
+		lines = append(lines, d.line)
+		d.line = ""
+	}
+
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		if line != "" && len(lines) > 0 && fromLineRegexp.MatchString(strings.TrimRight(line, "\r\n")) {
+			d.line = line
+			return lines, nil
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+
+		if err == io.EOF {
+			d.eof = true
+			if len(strings.TrimSpace(strings.Join(lines, ""))) == 0 {
+				return nil, io.EOF
+			}
+
+			return lines, nil
+		}
+	}
+}
+
+// setHeaders sets the author, date and subject of m from the headers.
+func (m *Message) setHeaders(headers mail.Header) {
+	if from := headers.Get("From"); from != "" {
+		m.Name, m.Email = parseFrom(from)
+	}
+
+	if date, err := headers.Date(); err == nil {
+		m.Date = date
+	}
+
+	if subject := headers.Get("Subject"); subject != "" {
+		m.SubjectPrefix, m.Subject = splitSubject(decodeHeader(subject))
+	}
+}
+
+// readHeaders reads the headers starting the lines, up to the first empty
+// line, and returns them with the lines following them.
+func readHeaders(lines []string) (mail.Header, []string) {
+	headers := make(mail.Header)
+	var key string
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return headers, lines[i+1:]
+		}
+
+		if (line[0] == ' ' || line[0] == '\t') && key != "" {
+			// A folded header continues the previous one.
+			values := headers[key]
+			values[len(values)-1] += " " + strings.TrimLeft(line, " \t")
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return headers, lines[i:]
+		}
+
+		key = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		headers[key] = append(headers[key], strings.TrimSpace(value))
+	}
+
+	return headers, nil
+}
+
+// isInBodyHeader reports whether the line of the body is a header
+// overriding the ones of the message.
+func isInBodyHeader(line string) bool {
+	for _, name := range []string{"From:", "Date:", "Subject:"} {
+		if strings.HasPrefix(line, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseFrom returns the name and the email of a From header.
+func parseFrom(from string) (name, email string) {
+	if addr, err := mail.ParseAddress(from); err == nil {
+		return addr.Name, addr.Address
+	}
+
+	from = decodeHeader(from)
+	if i := strings.LastIndexByte(from, '<'); i >= 0 {
+		name = strings.Trim(strings.TrimSpace(from[:i]), `"`)
+		email = strings.TrimSuffix(strings.TrimSpace(from[i+1:]), ">")
+		return name, email
+	}
+
+	return "", strings.TrimSpace(from)
+}
+
+// decodeHeader decodes the RFC 2047 encoded words of a header.
+func decodeHeader(value string) string {
+	dec := &mime.WordDecoder{}
+	if decoded, err := dec.DecodeHeader(value); err == nil {
+		return decoded
+	}
+
+	return value
+}
+
+// splitSubject returns the prefixes in brackets of the subject, like
+// "[PATCH 1/2]", and the rest of the subject, without its "Re:" prefixes.
+func splitSubject(subject string) (prefix, rest string) {
+	var prefixes []string
+	rest = strings.TrimSpace(subject)
+	for {
+		switch {
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return strings.Join(prefixes, " "), rest
+			}
+
+			prefixes = append(prefixes, rest[:end+1])
+			rest = strings.TrimSpace(rest[end+1:])
+		case len(rest) >= 3 && strings.EqualFold(rest[:3], "re:"):
+			rest = strings.TrimSpace(rest[3:])
+		default:
+			return strings.Join(prefixes, " "), rest
+		}
+	}
+}
+
+// splitLines splits s in lines, keeping their line feed.
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			return append(lines, s)
+		}
+
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+
+	return lines
+}
@@ -0,0 +1,89 @@
+package mbox
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	messages := []*Message{
+		{
+			Hash:          plumbing.NewHash("c55030ff6a67aa1924021ca88180f4b568924c44"),
+			Name:          "Doe, John",
+			Email:         "j@d.e",
+			Date:          testDate,
+			SubjectPrefix: "[PATCH 1/2]",
+			Subject:       "short",
+			Body:          "body\n\nmore",
+			Diff:          "---\n a | 1 +\ndiff --git a/a b/a\n",
+			Signature:     "2.39.5",
+		},
+		{
+			Hash:          plumbing.NewHash("e107e2832bb52df43b9e8792d29858c1a52c7cb5"),
+			Name:          "Ünïcödé Name With A Very Long Family Name That Goes On And On",
+			Email:         "u@x.y",
+			Date:          testDate,
+			SubjectPrefix: "[PATCH 2/2]",
+			Subject:       "Sübject with ümlauts that is rather long so that the encoded words must be split over lines",
+			Diff:          "---\ndiff --git a/b b/b\n",
+		},
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	for _, m := range messages {
+		require.NoError(t, e.Encode(m))
+	}
+
+	d := NewDecoder(&buf)
+	for _, want := range messages {
+		m, err := d.Decode()
+		require.NoError(t, err)
+		assert.Equal(t, want.Hash, m.Hash)
+		assert.Equal(t, want.Name, m.Name)
+		assert.Equal(t, want.Email, m.Email)
+		assert.True(t, want.Date.Equal(m.Date))
+		assert.Equal(t, want.SubjectPrefix, m.SubjectPrefix)
+		assert.Equal(t, want.Subject, m.Subject)
+		assert.Equal(t, want.Body, m.Body)
+		assert.True(t, strings.HasPrefix(m.Diff, want.Diff), m.Diff)
+	}
+
+	_, err := d.Decode()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestDecodeInBodyHeaders(t *testing.T) {
+	m, err := NewDecoder(strings.NewReader("" +
+		"From: Sender <s@x.y>\n" +
+		"Date: Fri, 8 Apr 2005 00:13:13 +0200\n" +
+		"Subject: Re: [PATCH] forwarded\n" +
+		"Content-Transfer-Encoding: quoted-printable\n" +
+		"\n" +
+		"From: Author <a@x.y>\n" +
+		"Subject: the real =\n" +
+		"subject\n" +
+		"\n" +
+		"B=C3=B6dy\n" +
+		"diff --git a/a b/a\n")).Decode()
+	require.NoError(t, err)
+	assert.Equal(t, plumbing.ZeroHash, m.Hash)
+	assert.Equal(t, "Author", m.Name)
+	assert.Equal(t, "a@x.y", m.Email)
+	assert.True(t, testDate.Equal(m.Date))
+	assert.Equal(t, "", m.SubjectPrefix)
+	assert.Equal(t, "the real subject", m.Subject)
+	assert.Equal(t, "Bödy", m.Body)
+	assert.Equal(t, "diff --git a/a b/a\n", m.Diff)
+}
+
+func TestDecodeEmpty(t *testing.T) {
+	_, err := NewDecoder(strings.NewReader("\n")).Decode()
+	assert.ErrorIs(t, err, io.EOF)
+}
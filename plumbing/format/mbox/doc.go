@@ -0,0 +1,3 @@
+// Package mbox implements the encoding of the email messages of patches,
+// as written by git format-patch, in the mbox format.
+package mbox
@@ -0,0 +1,335 @@
+package mbox
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// fromLineDate is the fixed date of the From lines separating the messages,
+// as written by git.
+const fromLineDate = "Mon Sep 17 00:00:00 2001"
+
+// maxLineLength is the length the headers are wrapped at.
+const maxLineLength = 78
+
+// maxEncodedLength is the maximum length of the lines of the headers encoded
+// with RFC 2047.
+const maxEncodedLength = 76
+
+// Message is an email message of a patch.
+type Message struct {
+	// Hash is the hash of the commit of the patch, written in the From
+	// line. It is zero for a cover letter.
+	Hash plumbing.Hash
+	// Name, Email and Date are the author of the patch and its date.
+	Name  string
+	Email string
+	Date  time.Time
+	// SubjectPrefix is written before the subject, like "[PATCH 1/2]".
+	SubjectPrefix string
+	// Subject is the subject of the message.
+	Subject string
+	// Body is the body of the message, the commit message without its
+	// subject. Its trailing whitespace is removed.
+	Body string
+	// Diff is written after the body, like the diffstat and the patch.
+	Diff string
+	// Signature, when not empty, is written after a signature separator at
+	// the end of the message.
+	Signature string
+	// EightBit forces the MIME headers declaring the 8bit UTF-8 content,
+	// otherwise written when the subject or the body are not ASCII.
+	EightBit bool
+	// RawFrom writes the name of the From header as is, like git does for
+	// cover letters, instead of encoding or quoting it.
+	RawFrom bool
+}
+
+// Encoder writes messages in the mbox format.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the message, starting with its From line.
+func (e *Encoder) Encode(m *Message) error {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "From %s %s\n", m.Hash, fromLineDate)
+	if m.RawFrom {
+		fmt.Fprintf(sb, "From: %s <%s>\n", m.Name, m.Email)
+	} else {
+		writeFrom(sb, m.Name, m.Email)
+	}
+	fmt.Fprintf(sb, "Date: %s\n", m.Date.Format("Mon, 2 Jan 2006 15:04:05 -0700"))
+
+	sb.WriteString("Subject: ")
+	if m.SubjectPrefix != "" {
+		sb.WriteString(m.SubjectPrefix + " ")
+	}
+
+	if needsRFC2047(m.Subject) {
+		writeRFC2047(sb, m.Subject, false)
+	} else {
+		writeWrapped(sb, m.Subject, -lastLineLength(sb), 1, maxLineLength)
+	}
+
+	sb.WriteByte('\n')
+	if m.EightBit || !isASCII(m.Subject) || !isASCII(m.Body) {
+		sb.WriteString("MIME-Version: 1.0\n" +
+			"Content-Type: text/plain; charset=UTF-8\n" +
+			"Content-Transfer-Encoding: 8bit\n")
+	}
+
+	sb.WriteByte('\n')
+	if body := strings.TrimRight(m.Body, " \t\n\r\v\f"); body != "" {
+		sb.WriteString(body)
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteString(m.Diff)
+	if m.Signature != "" {
+		fmt.Fprintf(sb, "-- \n%s\n\n", m.Signature)
+	}
+
+	_, err := io.WriteString(e.w, sb.String())
+	return err
+}
+
+// writeFrom writes the From header, the name being encoded or quoted when
+// needed.
+func writeFrom(sb *strings.Builder, name, email string) {
+	maxLength := maxLineLength
+	sb.WriteString("From: ")
+	switch {
+	case needsRFC2047(name):
+		writeRFC2047(sb, name, true)
+		maxLength = maxEncodedLength
+	case needsRFC822Quoting(name):
+		writeWrapped(sb, rfc822Quote(name), -6, 1, maxLength)
+	default:
+		writeWrapped(sb, name, -6, 1, maxLength)
+	}
+
+	if maxLength < lastLineLength(sb)+len(" <")+len(email)+len(">") {
+		sb.WriteByte('\n')
+	}
+
+	fmt.Fprintf(sb, " <%s>\n", email)
+}
+
+// needsRFC2047 reports whether s is encoded in the headers.
+func needsRFC2047(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if nonASCII(c) || c == '\n' {
+			return true
+		}
+
+		if c == '=' && i+1 < len(s) && s[i+1] == '?' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeRFC2047 writes s as RFC 2047 encoded words, wrapping the lines.
+// address is set for the phrases of the address headers, where fewer
+// characters are allowed.
+func writeRFC2047(sb *strings.Builder, s string, address bool) {
+	const encoding = "UTF-8"
+	lineLen := lastLineLength(sb)
+	sb.WriteString("=?" + encoding + "?q?")
+	lineLen += len(encoding) + 5
+	for len(s) > 0 {
+		_, size := utf8.DecodeRuneInString(s)
+		special := size > 1 || isRFC2047Special(s[0], address)
+		encodedLen := 1
+		if special {
+			encodedLen = 3 * size
+		}
+
+		if lineLen+encodedLen+2 > maxEncodedLength {
+			// It does not fit with the trailing "?=", the line is broken.
+			sb.WriteString("?=\n =?" + encoding + "?q?")
+			lineLen = len(encoding) + 5 + 1
+		}
+
+		for i := 0; i < size; i++ {
+			if special {
+				fmt.Fprintf(sb, "=%02X", s[i])
+			} else {
+				sb.WriteByte(s[i])
+			}
+		}
+
+		lineLen += encodedLen
+		s = s[size:]
+	}
+
+	sb.WriteString("?=")
+}
+
+func isRFC2047Special(c byte, address bool) bool {
+	if nonASCII(c) || c < 0x20 || c == 0x7f {
+		return true
+	}
+
+	if c == ' ' || c == '=' || c == '?' || c == '_' {
+		return true
+	}
+
+	if !address {
+		return false
+	}
+
+	return !(isAlnum(c) || c == '!' || c == '*' || c == '+' || c == '-' || c == '/')
+}
+
+func needsRFC822Quoting(s string) bool {
+	return strings.ContainsAny(s, `()<>[]:;@,."\`)
+}
+
+func rfc822Quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			sb.WriteByte('\\')
+		}
+
+		sb.WriteByte(s[i])
+	}
+
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// writeWrapped writes text wrapped at width, like git does for the
+// headers. A negative indent1 is the length of the text already on the
+// current line, the continuation lines are indented by indent2.
+func writeWrapped(sb *strings.Builder, text string, indent1, indent2, width int) {
+	bol, space := 0, -1
+	w, indent := indent1, indent1
+	if indent < 0 {
+		w = -indent
+		space = 0
+	}
+
+	i := 0
+	for {
+		var c byte
+		if i < len(text) {
+			c = text[i]
+		}
+
+		if i == len(text) || isSpace(c) {
+			if w <= width || space < 0 {
+				start := bol
+				if i == len(text) && i == start {
+					return
+				}
+
+				if space >= 0 {
+					start = space
+				} else {
+					sb.WriteString(strings.Repeat(" ", indent))
+				}
+
+				sb.WriteString(text[start:i])
+				if i == len(text) {
+					return
+				}
+
+				space = i
+				switch c {
+				case '\t':
+					w |= 0x07
+				case '\n':
+					space++
+					if space < len(text) && text[space] == '\n' {
+						sb.WriteByte('\n')
+						i, bol, space, w, indent = newLine(sb, text, space, indent2)
+						continue
+					}
+
+					if space >= len(text) || !isAlnum(text[space]) {
+						i, bol, space, w, indent = newLine(sb, text, space, indent2)
+						continue
+					}
+
+					sb.WriteByte(' ')
+				}
+
+				w++
+				i++
+			} else {
+				i, bol, space, w, indent = newLine(sb, text, space, indent2)
+			}
+
+			continue
+		}
+
+		_, size := utf8.DecodeRuneInString(text[i:])
+		w++
+		i += size
+	}
+}
+
+// newLine breaks the line of writeWrapped at space, returning the new state.
+func newLine(sb *strings.Builder, text string, space, indent2 int) (i, bol, newSpace, w, indent int) {
+	sb.WriteByte('\n')
+	i = space
+	if space < len(text) && isSpace(text[space]) {
+		i++
+	}
+
+	return i, i, -1, indent2, indent2
+}
+
+// lastLineLength returns the length of the last line of sb.
+func lastLineLength(sb *strings.Builder) int {
+	s := sb.String()
+	return len(s) - strings.LastIndexByte(s, '\n') - 1
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if nonASCII(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// nonASCII reports whether c is not ASCII, escape being considered as such
+// like git does.
+func nonASCII(c byte) bool {
+	return c >= 0x80 || c == 0x1b
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// WrapText returns text wrapped at width like git does, the first line
+// being indented by indent1 and the others by indent2.
+func WrapText(text string, indent1, indent2, width int) string {
+	var sb strings.Builder
+	writeWrapped(&sb, text, indent1, indent2, width)
+	return sb.String()
+}
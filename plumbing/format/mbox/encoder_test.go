@@ -0,0 +1,90 @@
+package mbox
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDate = time.Unix(1112911993, 0).In(time.FixedZone("", 2*3600))
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).Encode(&Message{
+		Hash:          plumbing.NewHash("c55030ff6a67aa1924021ca88180f4b568924c44"),
+		Name:          "Doe, John",
+		Email:         "j@d.e",
+		Date:          testDate,
+		SubjectPrefix: "[PATCH 1/2]",
+		Subject:       "short",
+		Body:          "body\n\n",
+		Diff:          "---\ndiff\n",
+		Signature:     "2.39.5",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ""+
+		"From c55030ff6a67aa1924021ca88180f4b568924c44 Mon Sep 17 00:00:00 2001\n"+
+		"From: \"Doe, John\" <j@d.e>\n"+
+		"Date: Fri, 8 Apr 2005 00:13:13 +0200\n"+
+		"Subject: [PATCH 1/2] short\n"+
+		"\n"+
+		"body\n"+
+		"---\n"+
+		"diff\n"+
+		"-- \n"+
+		"2.39.5\n"+
+		"\n", buf.String())
+}
+
+func TestEncodeNonASCII(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).Encode(&Message{
+		Hash:          plumbing.NewHash("e107e2832bb52df43b9e8792d29858c1a52c7cb5"),
+		Name:          "Ünïcödé Name With A Very Long Family Name That Goes On And On",
+		Email:         "u@x.y",
+		Date:          testDate,
+		SubjectPrefix: "[PATCH]",
+		Subject:       "Sübject with ümlauts that is rather long so that the encoded words must be split over lines",
+		Body:          "Bödy",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, ""+
+		"From e107e2832bb52df43b9e8792d29858c1a52c7cb5 Mon Sep 17 00:00:00 2001\n"+
+		"From: =?UTF-8?q?=C3=9Cn=C3=AFc=C3=B6d=C3=A9=20Name=20With=20A=20Very=20Lon?=\n"+
+		" =?UTF-8?q?g=20Family=20Name=20That=20Goes=20On=20And=20On?= <u@x.y>\n"+
+		"Date: Fri, 8 Apr 2005 00:13:13 +0200\n"+
+		"Subject: [PATCH] =?UTF-8?q?S=C3=BCbject=20with=20=C3=BCmlauts=20that=20is?=\n"+
+		" =?UTF-8?q?=20rather=20long=20so=20that=20the=20encoded=20words=20must=20b?=\n"+
+		" =?UTF-8?q?e=20split=20over=20lines?=\n"+
+		"MIME-Version: 1.0\n"+
+		"Content-Type: text/plain; charset=UTF-8\n"+
+		"Content-Transfer-Encoding: 8bit\n"+
+		"\n"+
+		"Bödy\n", buf.String())
+}
+
+func TestEncodeRawFrom(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewEncoder(&buf).Encode(&Message{
+		Name:     "Zoë Sender",
+		Email:    "z@s.t",
+		Date:     testDate,
+		Subject:  "*** SUBJECT HERE ***",
+		EightBit: true,
+		RawFrom:  true,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "\nFrom: Zoë Sender <z@s.t>\n")
+	assert.Contains(t, buf.String(), "\nSubject: *** SUBJECT HERE ***\nMIME-Version: 1.0\n")
+}
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t,
+		"  Add a script with a rather long subject line that should be wrapped by\n    format-patch",
+		WrapText("Add a script with a rather long subject line that should be wrapped by format-patch", 2, 4, 76))
+	assert.Equal(t, "short", WrapText("short", 0, 0, 76))
+}
@@ -0,0 +1,284 @@
+// Package sparsecheckout implements the sparse-checkout file of git, listing
+// the files of the index checked out in the worktree, and the matching of
+// paths to its patterns, in cone and non-cone mode.
+// https://git-scm.com/docs/git-sparse-checkout
+package sparsecheckout
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+)
+
+const (
+	commentPrefix = "#"
+	// rootFiles and rootDirs are the first patterns of the cone mode,
+	// including the files of the root directory but not its subdirectories.
+	rootFiles = "/*"
+	rootDirs  = "!/*/"
+	globChars = `*?[\`
+)
+
+// Decode reads the patterns of a sparse-checkout file, skipping the blank
+// lines and the comments.
+func Decode(r io.Reader) ([]string, error) {
+	var patterns []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, commentPrefix) {
+			continue
+		}
+
+		patterns = append(patterns, line)
+	}
+
+	return patterns, s.Err()
+}
+
+// Encode writes the patterns of a sparse-checkout file, one per line.
+func Encode(w io.Writer, patterns []string) error {
+	bw := bufio.NewWriter(w)
+	for _, p := range patterns {
+		if _, err := fmt.Fprintln(bw, p); err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// ConePatterns returns the patterns of the cone mode including the files of
+// the directories dirs and of their subdirectories, along with the files of
+// the root directory and of the leading directories of dirs.
+func ConePatterns(dirs []string) []string {
+	recursive := normalizeDirs(dirs)
+	parents := make(map[string]struct{})
+	for _, d := range recursive {
+		for p := parentDir(d); p != ""; p = parentDir(p) {
+			parents[p] = struct{}{}
+		}
+	}
+
+	sorted := make([]string, 0, len(parents))
+	for p := range parents {
+		sorted = append(sorted, p)
+	}
+
+	sort.Strings(sorted)
+
+	patterns := []string{rootFiles, rootDirs}
+	for _, p := range sorted {
+		p = escape(p)
+		patterns = append(patterns, "/"+p+"/", "!/"+p+"/*/")
+	}
+
+	for _, d := range recursive {
+		patterns = append(patterns, "/"+escape(d)+"/")
+	}
+
+	return patterns
+}
+
+// ConeDirs returns the directories whose files are included by patterns in
+// cone mode, see ConePatterns. It returns false when patterns are not cone
+// patterns.
+func ConeDirs(patterns []string) ([]string, bool) {
+	if len(patterns) < 2 || patterns[0] != rootFiles || patterns[1] != rootDirs {
+		return nil, false
+	}
+
+	var dirs []string
+	for i := 2; i < len(patterns); i++ {
+		p := patterns[i]
+		if !strings.HasPrefix(p, "/") || !strings.HasSuffix(p, "/") || len(p) < 3 {
+			return nil, false
+		}
+
+		dir, ok := unescape(p[1 : len(p)-1])
+		if !ok {
+			return nil, false
+		}
+
+		// A parent directory is followed by the exclusion of its
+		// subdirectories.
+		if i+1 < len(patterns) && patterns[i+1] == "!"+p+"*/" {
+			i++
+			continue
+		}
+
+		dirs = append(dirs, dir)
+	}
+
+	return dirs, true
+}
+
+// Matcher tells whether the files are in the sparse checkout.
+type Matcher interface {
+	// Match reports whether the file at path, relative to the root of the
+	// worktree, is checked out.
+	Match(path string) bool
+}
+
+// NewMatcher returns a Matcher of patterns. In cone mode, patterns which are
+// not cone patterns are matched as non-cone patterns, as git does.
+func NewMatcher(patterns []string, cone bool) Matcher {
+	if cone {
+		if dirs, ok := ConeDirs(patterns); ok {
+			return NewConeMatcher(dirs)
+		}
+	}
+
+	ps := make([]gitignore.Pattern, 0, len(patterns))
+	for _, p := range patterns {
+		ps = append(ps, gitignore.ParsePattern(p, nil))
+	}
+
+	return &patternMatcher{patterns: ps}
+}
+
+// NewConeMatcher returns the Matcher of the cone mode including the files of
+// dirs, see ConePatterns.
+func NewConeMatcher(dirs []string) Matcher {
+	m := &coneMatcher{
+		recursive: make(map[string]struct{}),
+		parents:   make(map[string]struct{}),
+	}
+
+	for _, d := range normalizeDirs(dirs) {
+		m.recursive[d] = struct{}{}
+		for p := parentDir(d); p != ""; p = parentDir(p) {
+			m.parents[p] = struct{}{}
+		}
+	}
+
+	return m
+}
+
+type coneMatcher struct {
+	// recursive are the directories whose files and subdirectories are
+	// included, and parents their leading directories, whose files only
+	// are included.
+	recursive map[string]struct{}
+	parents   map[string]struct{}
+}
+
+func (m *coneMatcher) Match(path string) bool {
+	dir := parentDir(path)
+	if dir == "" {
+		return true
+	}
+
+	if _, ok := m.parents[dir]; ok {
+		return true
+	}
+
+	for ; dir != ""; dir = parentDir(dir) {
+		if _, ok := m.recursive[dir]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+type patternMatcher struct {
+	patterns []gitignore.Pattern
+}
+
+// Match matches the file at path and then its leading directories, the
+// last pattern matching the first of them deciding whether it is included.
+func (m *patternMatcher) Match(path string) bool {
+	parts := strings.Split(path, "/")
+	for n := len(parts); n > 0; n-- {
+		isDir := n < len(parts)
+		for i := len(m.patterns) - 1; i >= 0; i-- {
+			switch m.patterns[i].Match(parts[:n], isDir) {
+			case gitignore.Exclude:
+				return true
+			case gitignore.Include:
+				return false
+			}
+		}
+	}
+
+	return false
+}
+
+// normalizeDirs returns the sorted directories of dirs without the ones in
+// another one.
+func normalizeDirs(dirs []string) []string {
+	var clean []string
+	for _, d := range dirs {
+		d = path.Clean("/" + strings.ReplaceAll(d, "\\", "/"))[1:]
+		if d != "" {
+			clean = append(clean, d)
+		}
+	}
+
+	sort.Strings(clean)
+
+	var res []string
+	for _, d := range clean {
+		if n := len(res); n > 0 && (res[n-1] == d || strings.HasPrefix(d, res[n-1]+"/")) {
+			continue
+		}
+
+		res = append(res, d)
+	}
+
+	return res
+}
+
+func escape(dir string) string {
+	if !strings.ContainsAny(dir, globChars) {
+		return dir
+	}
+
+	var b strings.Builder
+	for _, r := range dir {
+		if strings.ContainsRune(globChars, r) {
+			b.WriteByte('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// unescape returns the directory of an escaped pattern, false when it holds
+// unescaped glob characters.
+func unescape(p string) (string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '\\' && i+1 < len(p) {
+			i++
+			b.WriteByte(p[i])
+			continue
+		}
+
+		if strings.IndexByte(globChars, c) >= 0 {
+			return "", false
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String(), true
+}
+
+func parentDir(p string) string {
+	i := strings.LastIndexByte(p, '/')
+	if i < 0 {
+		return ""
+	}
+
+	return p[:i]
+}
@@ -0,0 +1,71 @@
+package sparsecheckout
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConePatterns(t *testing.T) {
+	patterns := ConePatterns([]string{"a/b/c", "d", "a/b/c/e", "f[1]/"})
+	assert.Equal(t, []string{
+		"/*", "!/*/",
+		"/a/", "!/a/*/",
+		"/a/b/", "!/a/b/*/",
+		"/a/b/c/", "/d/", `/f\[1]/`,
+	}, patterns)
+
+	dirs, ok := ConeDirs(patterns)
+	require.True(t, ok)
+	assert.Equal(t, []string{"a/b/c", "d", "f[1]"}, dirs)
+
+	_, ok = ConeDirs([]string{"/*", "!/*/", "/a*/"})
+	assert.False(t, ok)
+	_, ok = ConeDirs([]string{"*.go"})
+	assert.False(t, ok)
+}
+
+func TestConeMatcher(t *testing.T) {
+	m := NewMatcher(ConePatterns([]string{"a/b"}), true)
+	for path, expected := range map[string]bool{
+		"README":   true,
+		"a/file":   true,
+		"a/b/file": true,
+		"a/b/c/d":  true,
+		"a/c/file": false,
+		"b/file":   false,
+		"ab/file":  false,
+	} {
+		assert.Equal(t, expected, m.Match(path), path)
+	}
+}
+
+func TestPatternMatcher(t *testing.T) {
+	m := NewMatcher([]string{"/*", "!/*/", "*.go", "/docs/", "!/docs/internal/"}, false)
+	for path, expected := range map[string]bool{
+		"README":            true,
+		"a/file":            false,
+		"a/main.go":         true,
+		"docs/index.md":     true,
+		"docs/internal/foo": false,
+	} {
+		assert.Equal(t, expected, m.Match(path), path)
+	}
+
+	// Non-cone patterns are matched as such in cone mode.
+	m = NewMatcher([]string{"*.go"}, true)
+	assert.True(t, m.Match("a/main.go"))
+	assert.False(t, m.Match("README"))
+}
+
+func TestDecodeEncode(t *testing.T) {
+	patterns, err := Decode(bytes.NewBufferString("# comment\n/*\n\n!/*/  \n/a/\n"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/*", "!/*/", "/a/"}, patterns)
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, patterns))
+	assert.Equal(t, "/*\n!/*/\n/a/\n", buf.String())
+}
@@ -0,0 +1,480 @@
+package lfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+// MediaType is the media type of the requests and responses of the batch API.
+const MediaType = "application/vnd.git-lfs+json"
+
+// Operations of the batch API.
+const (
+	OperationDownload = "download"
+	OperationUpload   = "upload"
+)
+
+const (
+	basicTransfer = "basic"
+	verifyAction  = "verify"
+)
+
+// ErrUnsupportedEndpoint is returned when no LFS server can be derived from
+// the URL of a remote.
+var ErrUnsupportedEndpoint = errors.New("lfs: unsupported remote endpoint")
+
+// AuthMethod sets the credentials of the requests sent to a LFS server. It
+// is implemented by the auth methods of the http transport.
+type AuthMethod interface {
+	SetAuth(r *http.Request)
+}
+
+// HeaderAuth sets the given headers on the requests, like the ones returned
+// by git-lfs-authenticate.
+type HeaderAuth map[string]string
+
+// SetAuth implements AuthMethod.
+func (a HeaderAuth) SetAuth(r *http.Request) {
+	for k, v := range a {
+		r.Header.Set(k, v)
+	}
+}
+
+// Endpoint returns the URL of the LFS server of the remote with the given
+// URL, following git-lfs: https://host/repo.git/info/lfs. SSH remotes are
+// mapped to their https counterpart.
+func Endpoint(remoteURL string) (string, error) {
+	ep, err := transport.NewEndpoint(remoteURL)
+	if err != nil {
+		return "", err
+	}
+
+	scheme := ep.Protocol
+	switch scheme {
+	case "http", "https":
+	case "ssh", "git":
+		scheme = "https"
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedEndpoint, remoteURL)
+	}
+
+	host := ep.Host
+	if ep.Port != 0 && scheme == ep.Protocol {
+		host = fmt.Sprintf("%s:%d", host, ep.Port)
+	}
+
+	p := strings.TrimSuffix(ep.Path, "/")
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	if !strings.HasSuffix(p, ".git") {
+		p += ".git"
+	}
+
+	return fmt.Sprintf("%s://%s%s/info/lfs", scheme, host, p), nil
+}
+
+// AuthenticateCommand is the command run on the SSH remotes to get the URL
+// of their LFS server and the credentials to use with it.
+const AuthenticateCommand = "git-lfs-authenticate"
+
+// Authenticate runs git-lfs-authenticate for the operation on the SSH server
+// of ep. The returned action holds the URL of the LFS server and the headers
+// authenticating the requests to it.
+func Authenticate(ctx context.Context, ep *transport.Endpoint, auth transport.AuthMethod, operation string) (a *Action, err error) {
+	cmd, err := ssh.NewCommand(ctx, AuthenticateCommand, ep, auth, operation)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(cmd, &err)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	a = &Action{}
+	if err := json.NewDecoder(stdout).Decode(a); err != nil {
+		return nil, fmt.Errorf("lfs: invalid %s response: %w", AuthenticateCommand, err)
+	}
+
+	return a, nil
+}
+
+// ObjectError is the error of a single object in a batch response.
+type ObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ObjectError) Error() string {
+	return fmt.Sprintf("lfs: object error %d: %s", e.Code, e.Message)
+}
+
+// Action is a transfer to perform for an object.
+type Action struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header,omitempty"`
+	ExpiresIn int               `json:"expires_in,omitempty"`
+	ExpiresAt string            `json:"expires_at,omitempty"`
+}
+
+// BatchObject is an object of a batch request or response.
+type BatchObject struct {
+	OID           string             `json:"oid"`
+	Size          int64              `json:"size"`
+	Authenticated bool               `json:"authenticated,omitempty"`
+	Actions       map[string]*Action `json:"actions,omitempty"`
+	Error         *ObjectError       `json:"error,omitempty"`
+}
+
+// Ref is the reference a batch request is made for.
+type Ref struct {
+	Name string `json:"name"`
+}
+
+// BatchRequest is the body of a request to the batch API.
+type BatchRequest struct {
+	Operation string         `json:"operation"`
+	Transfers []string       `json:"transfers,omitempty"`
+	Ref       *Ref           `json:"ref,omitempty"`
+	Objects   []*BatchObject `json:"objects"`
+	HashAlgo  string         `json:"hash_algo,omitempty"`
+}
+
+// BatchResponse is the body of a response of the batch API.
+type BatchResponse struct {
+	Transfer string         `json:"transfer,omitempty"`
+	Objects  []*BatchObject `json:"objects"`
+	HashAlgo string         `json:"hash_algo,omitempty"`
+}
+
+// Client is a client of the batch API of a LFS server, using the basic
+// transfer adapter.
+type Client struct {
+	// Endpoint is the URL of the LFS server, see Endpoint.
+	Endpoint string
+	// Auth, when set, authenticates the requests to the server.
+	Auth AuthMethod
+	// Client is the http client used, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// NewClient returns a Client for the given endpoint. auth is used when it is
+// an auth method of the http transport, and ignored otherwise.
+func NewClient(endpoint string, auth transport.AuthMethod) *Client {
+	c := &Client{Endpoint: strings.TrimSuffix(endpoint, "/")}
+	if a, ok := auth.(AuthMethod); ok {
+		c.Auth = a
+	}
+
+	return c
+}
+
+// Batch requests the actions for the objects of the given operation.
+func (c *Client) Batch(ctx context.Context, operation string, pointers []*Pointer) (*BatchResponse, error) {
+	req := &BatchRequest{
+		Operation: operation,
+		Transfers: []string{basicTransfer},
+		HashAlgo:  oidType,
+	}
+
+	for _, p := range pointers {
+		req.Objects = append(req.Objects, &BatchObject{OID: p.OID, Size: p.Size})
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+"/objects/batch", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	hreq.Header.Set("Accept", MediaType)
+	hreq.Header.Set("Content-Type", MediaType)
+	if c.Auth != nil {
+		c.Auth.SetAuth(hreq)
+	}
+
+	res, err := c.do(hreq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+
+	var resp BatchResponse
+	if err := json.NewDecoder(res.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("lfs: invalid batch response: %w", err)
+	}
+
+	if resp.Transfer != "" && resp.Transfer != basicTransfer {
+		return nil, fmt.Errorf("lfs: unsupported transfer adapter %q", resp.Transfer)
+	}
+
+	return &resp, nil
+}
+
+// Download downloads the objects missing from s. Every one of them must be
+// in the response of the server, with a download action or an error.
+func (c *Client) Download(ctx context.Context, s *Storage, pointers []*Pointer) error {
+	var missing []*Pointer
+	requested := make(map[string]*Pointer)
+	for _, p := range pointers {
+		if !s.Has(p) {
+			missing = append(missing, p)
+			requested[p.OID] = p
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	resp, err := c.Batch(ctx, OperationDownload, missing)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range resp.Objects {
+		p, ok := requested[o.OID]
+		if !ok {
+			continue
+		}
+
+		if o.Error != nil {
+			return fmt.Errorf("%w: %s", o.Error, o.OID)
+		}
+
+		action := o.Actions[OperationDownload]
+		if action == nil {
+			return fmt.Errorf("lfs: no download action for %s", o.OID)
+		}
+
+		if err := c.download(ctx, s, p, action); err != nil {
+			return err
+		}
+
+		delete(requested, o.OID)
+	}
+
+	for _, p := range missing {
+		if _, ok := requested[p.OID]; ok {
+			return fmt.Errorf("lfs: %s missing from the batch response", p.OID)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) download(ctx context.Context, s *Storage, p *Pointer, action *Action) (err error) {
+	req, err := c.newActionRequest(ctx, http.MethodGet, action, nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(res.Body, &err)
+	return s.Write(p, res.Body)
+}
+
+// Upload uploads the objects of s the server does not have yet.
+func (c *Client) Upload(ctx context.Context, s *Storage, pointers []*Pointer) error {
+	if len(pointers) == 0 {
+		return nil
+	}
+
+	for _, p := range pointers {
+		if !s.Has(p) {
+			return fmt.Errorf("%w: %s", ErrObjectNotFound, p.OID)
+		}
+	}
+
+	resp, err := c.Batch(ctx, OperationUpload, pointers)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range resp.Objects {
+		if o.Error != nil {
+			return fmt.Errorf("%w: %s", o.Error, o.OID)
+		}
+
+		// Objects without upload action are already in the server.
+		action := o.Actions[OperationUpload]
+		if action == nil {
+			continue
+		}
+
+		p := &Pointer{OID: o.OID, Size: o.Size}
+		if err := c.upload(ctx, s, p, action); err != nil {
+			return err
+		}
+
+		if verify := o.Actions[verifyAction]; verify != nil {
+			if err := c.verify(ctx, p, verify); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) upload(ctx context.Context, s *Storage, p *Pointer, action *Action) error {
+	f, err := s.Open(p)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	req, err := c.newActionRequest(ctx, http.MethodPut, action, f)
+	if err != nil {
+		return err
+	}
+
+	req.ContentLength = p.Size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (c *Client) verify(ctx context.Context, p *Pointer, action *Action) error {
+	body, err := json.Marshal(&BatchObject{OID: p.OID, Size: p.Size})
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newActionRequest(ctx, http.MethodPost, action, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", MediaType)
+	req.Header.Set("Content-Type", MediaType)
+
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+
+	return res.Body.Close()
+}
+
+func (c *Client) newActionRequest(ctx context.Context, method string, action *Action, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, action.Href, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range action.Header {
+		req.Header.Set(k, v)
+	}
+
+	// Actions usually carry their own credentials.
+	if c.Auth != nil && req.Header.Get("Authorization") == "" && c.isEndpointURL(req.URL) {
+		c.Auth.SetAuth(req)
+	}
+
+	return req, nil
+}
+
+// isEndpointURL reports whether u is the endpoint of c, or below it: the
+// credentials of the endpoint are only sent to the same scheme, host and
+// port, and to the paths under the one of the endpoint.
+func (c *Client) isEndpointURL(u *url.URL) bool {
+	ep, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return false
+	}
+
+	if !strings.EqualFold(u.Scheme, ep.Scheme) ||
+		!strings.EqualFold(u.Hostname(), ep.Hostname()) ||
+		urlPort(u) != urlPort(ep) {
+		return false
+	}
+
+	prefix := strings.TrimSuffix(ep.Path, "/")
+	return u.Path == prefix || strings.HasPrefix(u.Path, prefix+"/")
+}
+
+// urlPort returns the port of u, the default one of its scheme when it has
+// none.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	}
+
+	return ""
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	trace.HTTP.Printf("lfs: requesting %s %s", req.Method, req.URL)
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode >= http.StatusOK && res.StatusCode < http.StatusMultipleChoices {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+
+	var msg struct {
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(io.LimitReader(res.Body, 64*1024)).Decode(&msg)
+
+	switch res.StatusCode {
+	case http.StatusUnauthorized:
+		return nil, fmt.Errorf("%w: %s %s", transport.ErrAuthenticationRequired, req.Method, req.URL)
+	case http.StatusForbidden:
+		return nil, fmt.Errorf("%w: %s %s", transport.ErrAuthorizationFailed, req.Method, req.URL)
+	}
+
+	return nil, fmt.Errorf("lfs: %s %s: %s %s", req.Method, req.URL, res.Status, msg.Message)
+}
@@ -0,0 +1,175 @@
+package lfs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gliderssh "github.com/gliderlabs/ssh"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	stdssh "golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
+)
+
+func TestEndpoint(t *testing.T) {
+	for url, expected := range map[string]string{
+		"https://example.com/foo/bar":        "https://example.com/foo/bar.git/info/lfs",
+		"https://example.com/foo/bar.git":    "https://example.com/foo/bar.git/info/lfs",
+		"http://example.com:8080/foo.git/":   "http://example.com:8080/foo.git/info/lfs",
+		"git@example.com:foo/bar.git":        "https://example.com/foo/bar.git/info/lfs",
+		"ssh://git@example.com:2222/foo/bar": "https://example.com/foo/bar.git/info/lfs",
+		"git://example.com/foo/bar.git":      "https://example.com/foo/bar.git/info/lfs",
+	} {
+		endpoint, err := Endpoint(url)
+		require.NoError(t, err, url)
+		assert.Equal(t, expected, endpoint, url)
+	}
+
+	_, err := Endpoint("/tmp/foo")
+	assert.ErrorIs(t, err, ErrUnsupportedEndpoint)
+}
+
+func TestClientUploadDownload(t *testing.T) {
+	server := NewStorage(memfs.New())
+	srv := httptest.NewServer(NewServer(server))
+	defer srv.Close()
+
+	local := NewStorage(memfs.New())
+	foo, err := local.Store(strings.NewReader("foo"))
+	require.NoError(t, err)
+	bar, err := local.Store(strings.NewReader("bar"))
+	require.NoError(t, err)
+
+	c := NewClient(srv.URL+"/repo.git/info/lfs", nil)
+	ctx := context.Background()
+	require.NoError(t, c.Upload(ctx, local, []*Pointer{foo, bar}))
+	assert.True(t, server.Has(foo))
+	assert.True(t, server.Has(bar))
+
+	// Objects already in the server are not uploaded again.
+	require.NoError(t, c.Upload(ctx, local, []*Pointer{foo}))
+
+	other := NewStorage(memfs.New())
+	require.NoError(t, c.Download(ctx, other, []*Pointer{foo, bar}))
+	assert.True(t, other.Has(foo))
+	assert.True(t, other.Has(bar))
+
+	missing := &Pointer{OID: testOID, Size: 1}
+	err = c.Download(ctx, other, []*Pointer{missing})
+	var oerr *ObjectError
+	require.ErrorAs(t, err, &oerr)
+	assert.Equal(t, http.StatusNotFound, oerr.Code)
+
+	err = c.Upload(ctx, local, []*Pointer{missing})
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+}
+
+type testAuth struct{}
+
+func (testAuth) Name() string   { return "test" }
+func (testAuth) String() string { return "test" }
+
+func (testAuth) SetAuth(r *http.Request) {
+	r.SetBasicAuth("user", "secret")
+}
+
+func TestClientAuth(t *testing.T) {
+	server := NewServer(NewStorage(memfs.New()))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "user" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		server.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	local := NewStorage(memfs.New())
+	p, err := local.Store(strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	err = NewClient(srv.URL, nil).Upload(ctx, local, []*Pointer{p})
+	assert.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+
+	require.NoError(t, NewClient(srv.URL, testAuth{}).Upload(ctx, local, []*Pointer{p}))
+}
+
+func TestClientDownloadMissingObject(t *testing.T) {
+	// The server answers without the requested object.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", MediaType)
+		fmt.Fprint(w, `{"transfer":"basic","objects":[]}`)
+	}))
+	defer srv.Close()
+
+	p := &Pointer{OID: testOID, Size: 1}
+	err := NewClient(srv.URL, nil).Download(context.Background(), NewStorage(memfs.New()), []*Pointer{p})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), testOID)
+}
+
+func TestClientActionAuth(t *testing.T) {
+	c := NewClient("https://lfs.example.com/repo.git/info/lfs", testAuth{})
+	for href, expected := range map[string]bool{
+		"https://lfs.example.com/repo.git/info/lfs/objects/x":     true,
+		"https://LFS.example.com:443/repo.git/info/lfs/x":         true,
+		"https://lfs.example.com.evil.net/repo.git/info/lfs/x":    false,
+		"https://lfs.example.com:8443/repo.git/info/lfs/x":        false,
+		"http://lfs.example.com/repo.git/info/lfs/x":              false,
+		"https://lfs.example.com/repo.git/info/lfsx/x":            false,
+		"https://evil.net/?https://lfs.example.com/repo.git/info": false,
+	} {
+		req, err := c.newActionRequest(context.Background(), http.MethodGet, &Action{Href: href}, nil)
+		require.NoError(t, err, href)
+		_, _, ok := req.BasicAuth()
+		assert.Equal(t, expected, ok, href)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	// The host key algorithms are looked up in the known hosts, even when
+	// the host key is not checked.
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, nil, 0o600))
+	t.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	commands := make(chan []string, 1)
+	srv := &gliderssh.Server{Handler: func(s gliderssh.Session) {
+		commands <- s.Command()
+		io.WriteString(s, `{"href":"https://example.com/foo.git/info/lfs","header":{"Authorization":"RemoteAuth token"},"expires_in":3600}`)
+	}}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	ep, err := transport.NewEndpoint(fmt.Sprintf("ssh://git@%s/foo.git", l.Addr()))
+	require.NoError(t, err)
+
+	auth := &ssh.Password{User: "git"}
+	auth.HostKeyCallback = stdssh.InsecureIgnoreHostKey()
+	a, err := Authenticate(context.Background(), ep, auth, OperationUpload)
+	require.NoError(t, err)
+	assert.Equal(t, []string{AuthenticateCommand, "/foo.git", OperationUpload}, <-commands)
+	assert.Equal(t, "https://example.com/foo.git/info/lfs", a.Href)
+	assert.Equal(t, 3600, a.ExpiresIn)
+
+	req, err := http.NewRequest(http.MethodGet, a.Href, nil)
+	require.NoError(t, err)
+	HeaderAuth(a.Header).SetAuth(req)
+	assert.Equal(t, "RemoteAuth token", req.Header.Get("Authorization"))
+}
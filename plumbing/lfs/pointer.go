@@ -0,0 +1,140 @@
+// Package lfs implements Git LFS: the pointer files stored in place of large
+// files, the local storage of their content and the batch API used to
+// transfer it from and to a LFS server.
+//
+// See https://github.com/git-lfs/git-lfs/tree/main/docs/spec.md and
+// https://github.com/git-lfs/git-lfs/blob/main/docs/api/batch.md.
+package lfs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version is the version of the pointer file format.
+	Version = "https://git-lfs.github.com/spec/v1"
+	// legacyVersion is accepted when parsing pointers.
+	legacyVersion = "https://hawser.github.com/spec/v1"
+
+	// MaxPointerSize is the maximum size of a pointer file. Larger blobs
+	// are never pointers.
+	MaxPointerSize = 1024
+
+	oidType = "sha256"
+)
+
+// FilterName is the name of the filter driver handling LFS files, as set by
+// the `filter=lfs` attribute.
+const FilterName = "lfs"
+
+// ErrInvalidPointer is returned when parsing content which is not a valid
+// pointer file.
+var ErrInvalidPointer = errors.New("lfs: invalid pointer")
+
+// Pointer references the content of a LFS file by its SHA-256 and size.
+type Pointer struct {
+	// OID is the hex encoded SHA-256 of the content.
+	OID  string
+	Size int64
+}
+
+// NewPointer returns the Pointer of the content read from r.
+func NewPointer(r io.Reader) (*Pointer, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Pointer{OID: hex.EncodeToString(h.Sum(nil)), Size: n}, nil
+}
+
+// ParsePointer parses the content of a pointer file.
+func ParsePointer(b []byte) (*Pointer, error) {
+	if len(b) >= MaxPointerSize || !bytes.HasPrefix(b, []byte("version ")) {
+		return nil, ErrInvalidPointer
+	}
+
+	var p Pointer
+	var version string
+	var hasOID, hasSize bool
+	var last string
+	for i, line := range strings.Split(strings.TrimSuffix(string(b), "\n"), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("%w: malformed line %q", ErrInvalidPointer, line)
+		}
+
+		// Keys other than version must be sorted.
+		if i > 1 && key <= last {
+			return nil, fmt.Errorf("%w: unsorted key %q", ErrInvalidPointer, key)
+		}
+		last = key
+
+		switch key {
+		case "version":
+			version = value
+		case "oid":
+			algo, oid, _ := strings.Cut(value, ":")
+			if algo != oidType || !validOID(oid) {
+				return nil, fmt.Errorf("%w: invalid oid %q", ErrInvalidPointer, value)
+			}
+
+			p.OID, hasOID = oid, true
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || size < 0 {
+				return nil, fmt.Errorf("%w: invalid size %q", ErrInvalidPointer, value)
+			}
+
+			p.Size, hasSize = size, true
+		}
+	}
+
+	if version != Version && version != legacyVersion {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrInvalidPointer, version)
+	}
+
+	if !hasOID || !hasSize {
+		return nil, fmt.Errorf("%w: missing oid or size", ErrInvalidPointer)
+	}
+
+	return &p, nil
+}
+
+// IsPointer reports whether b is a pointer file.
+func IsPointer(b []byte) bool {
+	_, err := ParsePointer(b)
+	return err == nil
+}
+
+// Bytes returns the content of the pointer file.
+func (p *Pointer) Bytes() []byte {
+	return []byte(p.String())
+}
+
+// String returns the content of the pointer file.
+func (p *Pointer) String() string {
+	return fmt.Sprintf("version %s\noid %s:%s\nsize %d\n", Version, oidType, p.OID, p.Size)
+}
+
+func validOID(oid string) bool {
+	if len(oid) != sha256.Size*2 {
+		return false
+	}
+
+	for _, c := range oid {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,61 @@
+package lfs
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOID = "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+
+func TestParsePointer(t *testing.T) {
+	p, err := ParsePointer([]byte("version https://git-lfs.github.com/spec/v1\n" +
+		"oid sha256:" + testOID + "\n" +
+		"size 12345\n"))
+	require.NoError(t, err)
+	assert.Equal(t, &Pointer{OID: testOID, Size: 12345}, p)
+
+	// Unknown keys are allowed when sorted.
+	p, err = ParsePointer([]byte("version https://hawser.github.com/spec/v1\n" +
+		"ext-0-foo sha256:" + testOID + "\n" +
+		"oid sha256:" + testOID + "\n" +
+		"size 0\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), p.Size)
+}
+
+func TestParsePointerInvalid(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"foo\n",
+		"version https://git-lfs.github.com/spec/v2\noid sha256:" + testOID + "\nsize 1\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 1\n",
+		"version https://git-lfs.github.com/spec/v1\noid md5:" + testOID + "\nsize 1\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize -1\n",
+		"version https://git-lfs.github.com/spec/v1\nsize 1\noid sha256:" + testOID + "\n",
+		"version https://git-lfs.github.com/spec/v1\noid sha256:" + testOID + "\nsize 1\n" +
+			strings.Repeat("x", MaxPointerSize),
+	} {
+		_, err := ParsePointer([]byte(content))
+		assert.ErrorIs(t, err, ErrInvalidPointer, content)
+		assert.False(t, IsPointer([]byte(content)))
+	}
+}
+
+func TestNewPointer(t *testing.T) {
+	p, err := NewPointer(strings.NewReader("hello world\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447", p.OID)
+	assert.Equal(t, int64(12), p.Size)
+
+	assert.Equal(t, "version https://git-lfs.github.com/spec/v1\n"+
+		"oid sha256:a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447\n"+
+		"size 12\n", p.String())
+
+	parsed, err := ParsePointer(p.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, p, parsed)
+}
@@ -0,0 +1,142 @@
+package lfs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Server is a minimal LFS server implementing the batch API and the basic
+// transfer adapter on top of a Storage. It is meant as a local stand-in for
+// a real LFS server, e.g. in tests, and does no authentication.
+//
+// It serves the LFS endpoint at any path: <endpoint>/objects/batch,
+// <endpoint>/objects/<oid> and <endpoint>/verify.
+type Server struct {
+	s *Storage
+}
+
+// NewServer returns a Server storing the objects in s.
+func NewServer(s *Storage) *Server {
+	return &Server{s: s}
+}
+
+// ServeHTTP implements http.Handler.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/objects/batch"):
+		srv.batch(w, r, strings.TrimSuffix(r.URL.Path, "/objects/batch"))
+	case r.Method == http.MethodPost && strings.HasSuffix(r.URL.Path, "/verify"):
+		srv.verify(w, r)
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/objects/"):
+		srv.download(w, r)
+	case r.Method == http.MethodPut && strings.Contains(r.URL.Path, "/objects/"):
+		srv.upload(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (srv *Server) batch(w http.ResponseWriter, r *http.Request, endpoint string) {
+	var req BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if req.Operation != OperationDownload && req.Operation != OperationUpload {
+		writeError(w, http.StatusUnprocessableEntity, "invalid operation")
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	base := fmt.Sprintf("%s://%s%s", scheme, r.Host, endpoint)
+
+	resp := &BatchResponse{Transfer: basicTransfer, HashAlgo: oidType}
+	for _, o := range req.Objects {
+		obj := &BatchObject{OID: o.OID, Size: o.Size}
+		resp.Objects = append(resp.Objects, obj)
+
+		p := &Pointer{OID: o.OID, Size: o.Size}
+		if !validOID(o.OID) {
+			obj.Error = &ObjectError{Code: http.StatusUnprocessableEntity, Message: "invalid oid"}
+			continue
+		}
+
+		href := fmt.Sprintf("%s/objects/%s", base, o.OID)
+		switch {
+		case req.Operation == OperationDownload && !srv.s.Has(p):
+			obj.Error = &ObjectError{Code: http.StatusNotFound, Message: "object does not exist"}
+		case req.Operation == OperationDownload:
+			obj.Actions = map[string]*Action{OperationDownload: {Href: href}}
+		case !srv.s.Has(p):
+			obj.Actions = map[string]*Action{
+				OperationUpload: {Href: href},
+				verifyAction:    {Href: base + "/verify"},
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", MediaType)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func (srv *Server) download(w http.ResponseWriter, r *http.Request) {
+	oid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	f, err := srv.s.Open(&Pointer{OID: oid})
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	defer f.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = io.Copy(w, f)
+}
+
+func (srv *Server) upload(w http.ResponseWriter, r *http.Request) {
+	oid := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+
+	var err error
+	if r.ContentLength < 0 {
+		var p *Pointer
+		if p, err = srv.s.Store(r.Body); err == nil && p.OID != oid {
+			err = ErrObjectMismatch
+		}
+	} else {
+		err = srv.s.Write(&Pointer{OID: oid, Size: r.ContentLength}, r.Body)
+	}
+
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (srv *Server) verify(w http.ResponseWriter, r *http.Request) {
+	var o BatchObject
+	if err := json.NewDecoder(r.Body).Decode(&o); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	if !srv.s.Has(&Pointer{OID: o.OID, Size: o.Size}) {
+		writeError(w, http.StatusNotFound, "object does not exist")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"message": msg})
+}
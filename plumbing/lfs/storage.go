@@ -0,0 +1,130 @@
+package lfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/go-git/go-billy/v5"
+)
+
+// ErrObjectNotFound is returned when the content of a pointer is not stored.
+var ErrObjectNotFound = errors.New("lfs: object not found")
+
+// ErrObjectMismatch is returned when a content does not match its pointer.
+var ErrObjectMismatch = errors.New("lfs: object does not match its pointer")
+
+// Path is the path of the LFS directory in the git directory.
+const Path = "lfs"
+
+const (
+	objectsPath = "objects"
+	tmpPath     = "tmp"
+)
+
+// Storage stores the content of LFS files by OID, using the layout of the
+// .git/lfs directory: objects/ab/cd/abcd....
+type Storage struct {
+	fs billy.Filesystem
+}
+
+// NewStorage returns a Storage keeping the objects in fs, usually the lfs
+// directory of the git directory.
+func NewStorage(fs billy.Filesystem) *Storage {
+	return &Storage{fs: fs}
+}
+
+func (s *Storage) path(oid string) string {
+	return path.Join(objectsPath, oid[0:2], oid[2:4], oid)
+}
+
+// Has reports whether the content of p is stored.
+func (s *Storage) Has(p *Pointer) bool {
+	if !validOID(p.OID) {
+		return false
+	}
+
+	fi, err := s.fs.Stat(s.path(p.OID))
+	return err == nil && fi.Size() == p.Size
+}
+
+// Open returns a reader of the content of p.
+func (s *Storage) Open(p *Pointer) (io.ReadCloser, error) {
+	if !validOID(p.OID) {
+		return nil, fmt.Errorf("%w: invalid oid %q", ErrInvalidPointer, p.OID)
+	}
+
+	f, err := s.fs.Open(s.path(p.OID))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, p.OID)
+	}
+
+	return f, err
+}
+
+// Store stores the content read from r and returns its Pointer.
+func (s *Storage) Store(r io.Reader) (p *Pointer, err error) {
+	if err := s.fs.MkdirAll(tmpPath, 0o755); err != nil {
+		return nil, err
+	}
+
+	tmp, err := s.fs.TempFile(tmpPath, "object")
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			_ = s.fs.Remove(tmp.Name())
+		}
+	}()
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	p = &Pointer{OID: hex.EncodeToString(h.Sum(nil)), Size: n}
+	return p, s.commit(tmp.Name(), p)
+}
+
+// Write stores the content of p read from r, checking that it matches p.
+func (s *Storage) Write(p *Pointer, r io.Reader) (err error) {
+	if !validOID(p.OID) {
+		return fmt.Errorf("%w: invalid oid %q", ErrInvalidPointer, p.OID)
+	}
+
+	got, err := s.Store(r)
+	if err != nil {
+		return err
+	}
+
+	if *got != *p {
+		return fmt.Errorf("%w: expected %s (%d bytes), got %s (%d bytes)",
+			ErrObjectMismatch, p.OID, p.Size, got.OID, got.Size)
+	}
+
+	return nil
+}
+
+func (s *Storage) commit(tmp string, p *Pointer) error {
+	if s.Has(p) {
+		return s.fs.Remove(tmp)
+	}
+
+	dst := s.path(p.OID)
+	if err := s.fs.MkdirAll(path.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	return s.fs.Rename(tmp, dst)
+}
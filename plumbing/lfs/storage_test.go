@@ -0,0 +1,63 @@
+package lfs
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	fs := memfs.New()
+	s := NewStorage(fs)
+
+	p, err := s.Store(strings.NewReader("hello world\n"))
+	require.NoError(t, err)
+	assert.True(t, s.Has(p))
+	assert.False(t, s.Has(&Pointer{OID: p.OID, Size: 1}))
+
+	_, err = fs.Stat("objects/a9/48/" + p.OID)
+	require.NoError(t, err)
+
+	r, err := s.Open(p)
+	require.NoError(t, err)
+	content, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	assert.Equal(t, "hello world\n", string(content))
+
+	// Storing the same content again is a no-op.
+	_, err = s.Store(strings.NewReader("hello world\n"))
+	require.NoError(t, err)
+
+	tmp, err := fs.ReadDir(tmpPath)
+	require.NoError(t, err)
+	assert.Empty(t, tmp)
+}
+
+func TestStorageNotFound(t *testing.T) {
+	s := NewStorage(memfs.New())
+
+	_, err := s.Open(&Pointer{OID: testOID, Size: 1})
+	assert.ErrorIs(t, err, ErrObjectNotFound)
+
+	_, err = s.Open(&Pointer{OID: "../../foo", Size: 1})
+	assert.ErrorIs(t, err, ErrInvalidPointer)
+}
+
+func TestStorageWrite(t *testing.T) {
+	s := NewStorage(memfs.New())
+
+	p, err := NewPointer(strings.NewReader("foo"))
+	require.NoError(t, err)
+
+	err = s.Write(p, strings.NewReader("bar"))
+	assert.ErrorIs(t, err, ErrObjectMismatch)
+	assert.False(t, s.Has(p))
+
+	require.NoError(t, s.Write(p, strings.NewReader("foo")))
+	assert.True(t, s.Has(p))
+}
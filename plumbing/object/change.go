@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/go-git/go-git/v6/utils/diff"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 )
 
@@ -16,12 +17,28 @@ import (
 type Change struct {
 	From ChangeEntry
 	To   ChangeEntry
+
+	// lineDiff are the options of the line diff of the patch, see
+	// DiffTreeOptions.
+	lineDiff *diff.Options
+	// binary tells whether the files are binary, see DiffTreeOptions.
+	binary func(path string) (binary, ok bool)
+	// similarity is the similarity of the files of a detected rename or
+	// copy, from 1 to 100, 0 for the other changes.
+	similarity int
+	// copy tells whether the change is a detected copy, its source being
+	// kept.
+	copy bool
+	// dissimilarity is the dissimilarity of the files of a modification
+	// detected as a rewrite, from 1 to 100, 0 for the other changes.
+	dissimilarity int
 }
 
 var empty ChangeEntry
 
 // Action returns the kind of action represented by the change, an
-// insertion, a deletion or a modification.
+// insertion, a deletion, a modification or a copy. The renames are
+// modifications whose files have different names.
 func (c *Change) Action() (merkletrie.Action, error) {
 	if c.From == empty && c.To == empty {
 		return merkletrie.Action(0),
@@ -36,9 +53,26 @@ func (c *Change) Action() (merkletrie.Action, error) {
 		return merkletrie.Delete, nil
 	}
 
+	if c.copy {
+		return merkletrie.Copy, nil
+	}
+
 	return merkletrie.Modify, nil
 }
 
+// Similarity returns the similarity of the files of a detected rename or
+// copy, from 1 to 100, or 0 for the other changes.
+func (c *Change) Similarity() int {
+	return c.similarity
+}
+
+// Dissimilarity returns the dissimilarity of the files of a modification
+// detected as a rewrite, see DiffTreeOptions.BreakRewrites, from 1 to 100,
+// or 0 for the other changes.
+func (c *Change) Dissimilarity() int {
+	return c.dissimilarity
+}
+
 // Files returns the files before and after a change.
 // For insertions from will be nil. For deletions to will be nil.
 func (c *Change) Files() (from, to *File, err error) {
@@ -47,7 +81,7 @@ func (c *Change) Files() (from, to *File, err error) {
 		return
 	}
 
-	if action == merkletrie.Insert || action == merkletrie.Modify {
+	if action != merkletrie.Delete {
 		to, err = c.To.Tree.TreeEntryFile(&c.To.TreeEntry)
 		if !c.To.TreeEntry.Mode.IsFile() {
 			return nil, nil, nil
@@ -58,7 +92,7 @@ func (c *Change) Files() (from, to *File, err error) {
 		}
 	}
 
-	if action == merkletrie.Delete || action == merkletrie.Modify {
+	if action != merkletrie.Insert {
 		from, err = c.From.Tree.TreeEntryFile(&c.From.TreeEntry)
 		if !c.From.TreeEntry.Mode.IsFile() {
 			return nil, nil, nil
@@ -115,7 +149,8 @@ type ChangeEntry struct {
 
 // Changes represents a collection of changes between two git trees.
 // Implements sort.Interface lexicographically over the path of the
-// changed files.
+// changed files, and then over their new path, for the copies of a file,
+// the deletions coming last.
 type Changes []*Change
 
 func (c Changes) Len() int {
@@ -127,7 +162,16 @@ func (c Changes) Swap(i, j int) {
 }
 
 func (c Changes) Less(i, j int) bool {
-	return strings.Compare(c[i].name(), c[j].name()) < 0
+	if cmp := strings.Compare(c[i].name(), c[j].name()); cmp != 0 {
+		return cmp < 0
+	}
+
+	// The deletions come last.
+	if c[i].To.Name == "" || c[j].To.Name == "" {
+		return c[j].To.Name == "" && c[i].To.Name != ""
+	}
+
+	return c[i].To.Name < c[j].To.Name
 }
 
 func (c Changes) String() string {
@@ -0,0 +1,360 @@
+package object
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/utils/diff"
+)
+
+// ErrNotMerge is returned when a combined diff is asked for a commit with
+// less than two parents.
+var ErrNotMerge = errors.New("commit is not a merge")
+
+// CombinedPatch returns the combined diff of the merge commit against all
+// its parents, like `git diff -c`, or `git diff --cc` when dense. Only the
+// files which differ from all the parents are shown, the renames not being
+// detected.
+func (c *Commit) CombinedPatch(dense bool) (*CombinedPatch, error) {
+	return c.CombinedPatchContext(context.Background(), dense)
+}
+
+// CombinedPatchContext returns the combined diff of the merge commit against
+// all its parents, like CombinedPatch. Error will be return if context
+// expires. Provided context must be non-nil.
+func (c *Commit) CombinedPatchContext(ctx context.Context, dense bool) (*CombinedPatch, error) {
+	if c.NumParents() < 2 {
+		return nil, ErrNotMerge
+	}
+
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	// The changes of the paths, from each parent.
+	var paths []string
+	changes := make([]map[string]*Change, c.NumParents())
+	for i := range c.ParentHashes {
+		parent, err := c.Parent(i)
+		if err != nil {
+			return nil, err
+		}
+
+		parentTree, err := parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+
+		parentChanges, err := DiffTreeContext(ctx, parentTree, tree)
+		if err != nil {
+			return nil, err
+		}
+
+		changes[i] = make(map[string]*Change, len(parentChanges))
+		for _, ch := range parentChanges {
+			path := ch.To.Name
+			if path == "" {
+				path = ch.From.Name
+			}
+
+			if i == 0 {
+				paths = append(paths, path)
+			}
+
+			changes[i][path] = ch
+		}
+	}
+
+	patch := &CombinedPatch{dense: dense}
+	for _, path := range paths {
+		select {
+		case <-ctx.Done():
+			return nil, ErrCanceled
+		default:
+		}
+
+		pathChanges := make([]*Change, len(changes))
+		for i := range changes {
+			pathChanges[i] = changes[i][path]
+			if pathChanges[i] == nil {
+				break
+			}
+		}
+
+		if pathChanges[len(pathChanges)-1] == nil {
+			continue
+		}
+
+		fp, err := newCombinedFilePatch(pathChanges)
+		if err != nil {
+			return nil, err
+		}
+
+		patch.filePatches = append(patch.filePatches, fp)
+	}
+
+	return patch, nil
+}
+
+// newCombinedFilePatch returns the combined diff of the changes of a file
+// from each parent.
+func newCombinedFilePatch(changes []*Change) (*combinedFilePatch, error) {
+	fp := &combinedFilePatch{
+		parents: make([]ChangeEntry, len(changes)),
+		result:  changes[0].To,
+	}
+
+	resultContent, isBinary, err := changeEntryContent(fp.result)
+	if err != nil {
+		return nil, err
+	}
+
+	fp.binary = isBinary
+	fp.sizes = make([]int64, len(changes)+1)
+	fp.sizes[len(changes)] = int64(len(resultContent))
+	parentContents := make([]string, len(changes))
+	for i, ch := range changes {
+		fp.parents[i] = ch.From
+		parentContents[i], isBinary, err = changeEntryContent(ch.From)
+		if err != nil {
+			return nil, err
+		}
+
+		fp.binary = fp.binary || isBinary
+		fp.sizes[i] = int64(len(parentContents[i]))
+	}
+
+	if fp.binary {
+		return fp, nil
+	}
+
+	result := splitCombinedLines(resultContent)
+	fp.lines = make([]fdiff.CombinedLine, len(result)+1)
+	for i := range fp.lines {
+		fp.lines[i].Added = make([]bool, len(changes))
+		if i < len(result) {
+			fp.lines[i].Content = strings.TrimSuffix(result[i], "\n")
+		}
+	}
+
+	for p, content := range parentContents {
+		parent := splitCombinedLines(content)
+		for _, e := range diff.Edits(parent, result, nil) {
+			for i := e.Pos2; i < e.Pos2+e.Len2; i++ {
+				fp.lines[i].Added[p] = true
+			}
+
+			// The lines removed are lost before the first line added, or
+			// the line following them.
+			line := &fp.lines[e.Pos2]
+			line.Lost = coalesceLost(line.Lost, parent[e.Pos1:e.Pos1+e.Len1], p, len(changes))
+		}
+	}
+
+	return fp, nil
+}
+
+// coalesceLost merges the lines lost from the parent p into the lines lost
+// from the previous parents, following their longest common subsequence like
+// git does.
+func coalesceLost(base []fdiff.CombinedLostLine, lost []string, p, parents int) []fdiff.CombinedLostLine {
+	newLost := func(line string) fdiff.CombinedLostLine {
+		l := fdiff.CombinedLostLine{
+			Content: strings.TrimSuffix(line, "\n"),
+			Parents: make([]bool, parents),
+		}
+
+		l.Parents[p] = true
+		return l
+	}
+
+	if len(base) == 0 {
+		for _, line := range lost {
+			base = append(base, newLost(line))
+		}
+
+		return base
+	}
+
+	const (
+		fromBase = iota
+		fromNew
+		fromBoth
+	)
+
+	lcs := make([][]int, len(base)+1)
+	directions := make([][]int, len(base)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(lost)+1)
+		directions[i] = make([]int, len(lost)+1)
+	}
+
+	for j := 1; j <= len(lost); j++ {
+		directions[0][j] = fromNew
+	}
+
+	for i := 1; i <= len(base); i++ {
+		for j := 1; j <= len(lost); j++ {
+			switch {
+			case base[i-1].Content == strings.TrimSuffix(lost[j-1], "\n"):
+				lcs[i][j] = lcs[i-1][j-1] + 1
+				directions[i][j] = fromBoth
+			case lcs[i][j-1] >= lcs[i-1][j]:
+				lcs[i][j] = lcs[i][j-1]
+				directions[i][j] = fromNew
+			default:
+				lcs[i][j] = lcs[i-1][j]
+				directions[i][j] = fromBase
+			}
+		}
+	}
+
+	// Walk back from the ends, the new lines going after the base line
+	// they are reached from.
+	merged := make([]fdiff.CombinedLostLine, 0, len(base)+len(lost))
+	for i, j := len(base), len(lost); i != 0 || j != 0; {
+		switch directions[i][j] {
+		case fromBoth:
+			base[i-1].Parents[p] = true
+			merged = append(merged, base[i-1])
+			i--
+			j--
+		case fromNew:
+			merged = append(merged, newLost(lost[j-1]))
+			j--
+		default:
+			merged = append(merged, base[i-1])
+			i--
+		}
+	}
+
+	for i, j := 0, len(merged)-1; i < j; i, j = i+1, j-1 {
+		merged[i], merged[j] = merged[j], merged[i]
+	}
+
+	return merged
+}
+
+// splitCombinedLines splits the content into lines, keeping their line
+// feeds.
+func splitCombinedLines(content string) []string {
+	var lines []string
+	for content != "" {
+		i := strings.IndexByte(content, '\n')
+		if i < 0 {
+			return append(lines, content)
+		}
+
+		lines = append(lines, content[:i+1])
+		content = content[i+1:]
+	}
+
+	return lines
+}
+
+// FirstParentPatch returns the Patch of the commit against its first parent,
+// or against an empty tree for a root commit, like `git show
+// --first-parent`, showing a merge as the changes it brings to its mainline.
+func (c *Commit) FirstParentPatch() (*Patch, error) {
+	return c.FirstParentPatchContext(context.Background())
+}
+
+// FirstParentPatchContext returns the Patch of the commit against its first
+// parent, like FirstParentPatch. Error will be return if context expires.
+// Provided context must be non-nil.
+func (c *Commit) FirstParentPatchContext(ctx context.Context) (*Patch, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	parentTree := &Tree{}
+	if c.NumParents() != 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+
+		parentTree, err = parent.Tree()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return parentTree.PatchContext(ctx, tree)
+}
+
+// CombinedPatch is an implementation of fdiff.CombinedPatch interface
+type CombinedPatch struct {
+	message     string
+	dense       bool
+	filePatches []fdiff.CombinedFilePatch
+}
+
+func (p *CombinedPatch) FilePatches() []fdiff.CombinedFilePatch {
+	return p.filePatches
+}
+
+func (p *CombinedPatch) Message() string {
+	return p.message
+}
+
+func (p *CombinedPatch) Dense() bool {
+	return p.dense
+}
+
+func (p *CombinedPatch) Encode(w io.Writer) error {
+	ue := fdiff.NewUnifiedEncoder(w, fdiff.DefaultContextLines)
+
+	return ue.EncodeCombined(p)
+}
+
+func (p *CombinedPatch) String() string {
+	buf := bytes.NewBuffer(nil)
+	err := p.Encode(buf)
+	if err != nil {
+		return fmt.Sprintf("malformed patch: %s", err.Error())
+	}
+
+	return buf.String()
+}
+
+// combinedFilePatch is an implementation of fdiff.CombinedFilePatch
+// interface
+type combinedFilePatch struct {
+	parents []ChangeEntry
+	result  ChangeEntry
+	// sizes are the sizes of the files of the parents, then of the result.
+	sizes  []int64
+	binary bool
+	lines  []fdiff.CombinedLine
+}
+
+func (fp *combinedFilePatch) Files() (parents []fdiff.File, result fdiff.File) {
+	parents = make([]fdiff.File, len(fp.parents))
+	for i, e := range fp.parents {
+		if f := (&changeEntryWrapper{e, fp.sizes[i]}); !f.Empty() {
+			parents[i] = f
+		}
+	}
+
+	if f := (&changeEntryWrapper{fp.result, fp.sizes[len(fp.parents)]}); !f.Empty() {
+		result = f
+	}
+
+	return parents, result
+}
+
+func (fp *combinedFilePatch) IsBinary() bool {
+	return fp.binary
+}
+
+func (fp *combinedFilePatch) Lines() []fdiff.CombinedLine {
+	return fp.lines
+}
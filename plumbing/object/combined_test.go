@@ -0,0 +1,155 @@
+package object_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+)
+
+type CombinedPatchSuite struct {
+	suite.Suite
+	r                  *git.Repository
+	base, ours, theirs plumbing.Hash
+	merge              plumbing.Hash
+}
+
+func TestCombinedPatchSuite(t *testing.T) {
+	suite.Run(t, new(CombinedPatchSuite))
+}
+
+// SetupTest writes a merge whose main.c resolves the conflict of the
+// parents, taking side.txt from the second parent and adding new.txt.
+func (s *CombinedPatchSuite) SetupTest() {
+	cm := &git.CommitOptions{
+		Author: &object.Signature{Name: "Foo", Email: "foo@example.local", When: time.Now()},
+	}
+
+	fs := memfs.New()
+	r, err := git.Init(memory.NewStorage(), git.WithWorkTree(fs))
+	s.Require().NoError(err)
+	s.r = r
+
+	w, err := r.Worktree()
+	s.Require().NoError(err)
+
+	commit := func(files map[string]string, parents ...plumbing.Hash) plumbing.Hash {
+		for name, content := range files {
+			s.Require().NoError(util.WriteFile(fs, name, []byte(content), 0o644))
+			_, err := w.Add(name)
+			s.Require().NoError(err)
+		}
+
+		opts := *cm
+		opts.Parents = parents
+		h, err := w.Commit("commit\n", &opts)
+		s.Require().NoError(err)
+		return h
+	}
+
+	s.base = commit(map[string]string{
+		"main.c":   "int main() {\n\treturn 0;\n}\n",
+		"side.txt": "side\n",
+	})
+	s.ours = commit(map[string]string{"main.c": "int main() {\n\treturn 2;\n}\n"}, s.base)
+	s.Require().NoError(w.Checkout(&git.CheckoutOptions{Hash: s.base}))
+	s.theirs = commit(map[string]string{
+		"main.c":   "int main() {\n\treturn 1;\n}\n",
+		"side.txt": "side\nchanged\n",
+	}, s.base)
+	s.merge = commit(map[string]string{
+		"main.c":   "int main() {\n\treturn 3;\n}\n",
+		"new.txt":  "new\n",
+		"side.txt": "side\nchanged\n",
+	}, s.ours, s.theirs)
+}
+
+func (s *CombinedPatchSuite) commit(h plumbing.Hash) *object.Commit {
+	c, err := s.r.CommitObject(h)
+	s.Require().NoError(err)
+	return c
+}
+
+func (s *CombinedPatchSuite) TestCombinedPatch() {
+	patch, err := s.commit(s.merge).CombinedPatch(false)
+	s.NoError(err)
+	s.False(patch.Dense())
+	s.Len(patch.FilePatches(), 2)
+	s.Equal(""+
+		"diff --combined main.c\n"+
+		"index 88ce1e4678838377391da4d391b48c762d7a1911,25f0d28be292a7bb2045ff0811e1a5fb8873145b..29a838329fee85b70964998116aa3e55098c837f\n"+
+		"--- a/main.c\n"+
+		"+++ b/main.c\n"+
+		"@@@ -1,3 -1,3 +1,3 @@@\n"+
+		"  int main() {\n"+
+		"- \treturn 2;\n"+
+		" -\treturn 1;\n"+
+		"++\treturn 3;\n"+
+		"  }\n"+
+		"diff --combined new.txt\n"+
+		"index 0000000000000000000000000000000000000000,0000000000000000000000000000000000000000..3e757656cf36eca53338e520d134963a44f793f8\n"+
+		"new file mode 100644\n"+
+		"--- /dev/null\n"+
+		"+++ b/new.txt\n"+
+		"@@@ -1,0 -1,0 +1,1 @@@\n"+
+		"++new\n", patch.String())
+}
+
+func (s *CombinedPatchSuite) TestCombinedPatch_Dense() {
+	patch, err := s.commit(s.merge).CombinedPatchContext(context.Background(), true)
+	s.NoError(err)
+	s.True(patch.Dense())
+	s.Len(patch.FilePatches(), 2)
+
+	parents, result := patch.FilePatches()[0].Files()
+	s.Len(parents, 2)
+	s.Equal("main.c", parents[0].Path())
+	s.Equal("main.c", parents[1].Path())
+	s.Equal("main.c", result.Path())
+
+	parents, result = patch.FilePatches()[1].Files()
+	s.Nil(parents[0])
+	s.Nil(parents[1])
+	s.Equal("new.txt", result.Path())
+}
+
+func (s *CombinedPatchSuite) TestCombinedPatch_NotMerge() {
+	_, err := s.commit(s.ours).CombinedPatch(true)
+	s.ErrorIs(err, object.ErrNotMerge)
+}
+
+func (s *CombinedPatchSuite) TestCombinedPatch_Cancel() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := s.commit(s.merge).CombinedPatchContext(ctx, false)
+	s.ErrorIs(err, object.ErrCanceled)
+}
+
+func (s *CombinedPatchSuite) TestFirstParentPatch() {
+	patch, err := s.commit(s.merge).FirstParentPatch()
+	s.NoError(err)
+
+	stats := patch.Stats()
+	s.Len(stats, 3)
+	s.Equal("main.c", stats[0].Name)
+	s.Equal("new.txt", stats[1].Name)
+	s.Equal("side.txt", stats[2].Name)
+	s.Equal(1, stats[2].Addition)
+
+	patch, err = s.commit(s.base).FirstParentPatchContext(context.Background())
+	s.NoError(err)
+
+	stats = patch.Stats()
+	s.Len(stats, 2)
+	s.Equal(3, stats[0].Addition)
+	s.Equal(1, stats[1].Addition)
+}
@@ -3,6 +3,7 @@ package object
 import (
 	"bytes"
 	"context"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -136,6 +137,10 @@ func (c *Commit) NumParents() int {
 
 var ErrParentNotFound = errors.New("commit parent not found")
 
+// ErrNoMergeTag is returned by MergeTagObject when the commit has no mergetag
+// header.
+var ErrNoMergeTag = errors.New("commit has no mergetag header")
+
 // Parent returns the ith parent of a commit.
 func (c *Commit) Parent(i int) (*Commit, error) {
 	if len(c.ParentHashes) == 0 || i > len(c.ParentHashes)-1 {
@@ -431,6 +436,44 @@ func (c *Commit) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature, nil)
 }
 
+// VerifyX509 performs X.509 (CMS) verification of the commit signature, as
+// produced with gpg.format=x509 by tools like gpgsm, smimesign or gitsign.
+// The signer certificate chain is validated with opts, at the current time
+// when opts.CurrentTime is zero; the verifier of NewX509VerifierAtSigningTime
+// of the git package validates it at the signing time instead. The signer
+// certificate is returned on success.
+func (c *Commit) VerifyX509(opts x509.VerifyOptions) (*x509.Certificate, error) {
+	encoded := &plumbing.MemoryObject{}
+	// Encode commit components, excluding signature and get a reader object.
+	if err := c.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+
+	return verifyX509([]byte(c.PGPSignature), encoded, opts)
+}
+
+// MergeTagObject decodes the tag embedded in the mergetag header, which git
+// records when merging a signed tag. The returned tag is not associated with
+// any storer, so only its own fields are usable.
+func (c *Commit) MergeTagObject() (*Tag, error) {
+	if c.MergeTag == "" {
+		return nil, ErrNoMergeTag
+	}
+
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.TagObject)
+	if _, err := obj.Write([]byte(c.MergeTag)); err != nil {
+		return nil, err
+	}
+
+	t := &Tag{}
+	if err := t.Decode(obj); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
 // Less defines a compare function to determine which commit is 'earlier' by:
 // - First use Committer.When
 // - If Committer.When are equal then use Author.When
@@ -289,6 +289,30 @@ change
 	}
 }
 
+func (s *SuiteCommit) TestMergeTagObject() {
+	_, err := s.Commit.MergeTagObject()
+	s.ErrorIs(err, ErrNoMergeTag)
+
+	commit := &Commit{MergeTag: `object f000000000000000000000000000000000000000
+type commit
+tag change
+tagger Foo <foo@example.local> 1695827841 -0400
+
+change
+-----BEGIN PGP SIGNATURE-----
+
+iQEzBAABCAAdFiEE
+-----END PGP SIGNATURE-----
+`}
+
+	tag, err := commit.MergeTagObject()
+	s.NoError(err)
+	s.Equal("change", tag.Name)
+	s.Equal(plumbing.NewHash("f000000000000000000000000000000000000000"), tag.Target)
+	s.Equal("change\n", tag.Message)
+	s.True(strings.HasPrefix(tag.PGPSignature, "-----BEGIN PGP SIGNATURE-----"))
+}
+
 func (s *SuiteCommit) TestFile() {
 	file, err := s.Commit.File("CHANGELOG")
 	s.NoError(err)
@@ -525,6 +549,28 @@ func (s *SuiteCommit) TestPatchCancel() {
 
 }
 
+func (s *SuiteCommit) TestCombinedPatch() {
+	// The merge takes each file from one of the parents.
+	patch, err := s.Commit.CombinedPatch(true)
+	s.NoError(err)
+	s.Len(patch.FilePatches(), 0)
+	s.Equal("", patch.String())
+
+	patch, err = s.Commit.CombinedPatch(false)
+	s.NoError(err)
+	s.Len(patch.FilePatches(), 0)
+}
+
+func (s *SuiteCommit) TestFirstParentPatch() {
+	patch, err := s.Commit.FirstParentPatch()
+	s.NoError(err)
+
+	stats := patch.Stats()
+	s.Len(stats, 1)
+	s.Equal("CHANGELOG", stats[0].Name)
+	s.Equal(1, stats[0].Addition)
+}
+
 func (s *SuiteCommit) TestMalformedHeader() {
 	encoded := &plumbing.MemoryObject{}
 	decoded := &Commit{}
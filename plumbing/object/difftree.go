@@ -3,7 +3,9 @@ package object
 import (
 	"bytes"
 	"context"
+	"io"
 
+	"github.com/go-git/go-git/v6/utils/diff"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/go-git/go-git/v6/utils/merkletrie/noder"
 )
@@ -11,7 +13,7 @@ import (
 // DiffTree compares the content and mode of the blobs found via two
 // tree objects.
 // DiffTree does not perform rename detection, use DiffTreeWithOptions
-// instead to detect renames.
+// instead to detect renames and copies.
 func DiffTree(a, b *Tree) (Changes, error) {
 	return DiffTreeContext(context.Background(), a, b)
 }
@@ -42,6 +44,42 @@ type DiffTreeOptions struct {
 	// OnlyExactRenames performs only detection of exact renames and will not perform
 	// any detection of renames based on file similarity.
 	OnlyExactRenames bool
+	// DetectCopies detects the copies of files, like `git diff -C`, their
+	// sources being the deleted and modified files. The copies are
+	// detected with the RenameScore and RenameLimit of the renames, and
+	// imply the detection of renames.
+	DetectCopies bool
+	// FindCopiesHarder also takes the unmodified files of the first tree
+	// as sources of the copies, like `git diff --find-copies-harder`. When
+	// there are too many files for the RenameLimit, only the deleted and
+	// modified files are taken. It is only supported by
+	// DiffTreeWithOptions, which knows the unmodified files.
+	FindCopiesHarder bool
+	// BreakRewrites breaks the modifications rewriting most of a file into
+	// a deletion and an insertion, like `git diff -B`, so their old file
+	// can be the source of a rename or a copy, and their new file the
+	// destination of a rename. The modifications not detected as renames
+	// are shown as rewrites when dissimilar enough.
+	BreakRewrites bool
+	// BreakScore is the dissimilarity of the files of a modification, from
+	// 0 to 100, above which it is broken, the n of `-B<n>/<m>`. It defaults
+	// to 50 when 0.
+	BreakScore uint
+	// RewriteScore is the dissimilarity of the files of a broken
+	// modification, from 0 to 100, from which it is a rewrite, the m of
+	// `-B<n>/<m>`. It defaults to 60 when 0.
+	RewriteScore uint
+	// LineDiff are the options of the line diff of the patches of the
+	// changes, like the diff algorithm. When nil, diff.DefaultOptions are
+	// used.
+	LineDiff *diff.Options
+	// PathFilter, when set, limits the changes to the paths it accepts,
+	// before the renames are detected.
+	PathFilter func(path string) bool
+	// Binary, when set, tells whether the file at the given path is binary
+	// in the patches of the changes, like the diff attribute of git. Its
+	// content decides when ok is false.
+	Binary func(path string) (binary, ok bool)
 }
 
 // DefaultDiffTreeOptions are the default and recommended options for the
@@ -90,9 +128,78 @@ func DiffTreeWithOptions(
 		opts = new(DiffTreeOptions)
 	}
 
-	if opts.DetectRenames {
-		return DetectRenames(changes, opts)
+	if opts.PathFilter != nil {
+		changes = filterChanges(changes, opts.PathFilter)
+	}
+
+	if opts.DetectRenames || opts.DetectCopies || opts.BreakRewrites {
+		detector, err := newRenameDetector(changes, opts, opts.DetectRenames || opts.DetectCopies)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.DetectCopies && opts.FindCopiesHarder && a != nil {
+			detector.unmodified, err = unmodifiedFiles(a, changes, opts.PathFilter)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		changes, err = detector.detect()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, c := range changes {
+		c.lineDiff = opts.LineDiff
+		c.binary = opts.Binary
 	}
 
 	return changes, nil
 }
+
+// unmodifiedFiles returns the files of the tree t without changes, accepted
+// by filter when not nil, as deletions so they can be the sources of the
+// copies.
+func unmodifiedFiles(t *Tree, changes Changes, filter func(path string) bool) ([]*Change, error) {
+	changed := make(map[string]bool, len(changes))
+	for _, c := range changes {
+		changed[c.From.Name] = true
+	}
+
+	w := NewTreeWalker(t, true, nil)
+	defer w.Close()
+
+	var files []*Change
+	for {
+		name, entry, err := w.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !entry.Mode.IsFile() || changed[name] || filter != nil && !filter(name) {
+			continue
+		}
+
+		files = append(files, &Change{From: ChangeEntry{Name: name, Tree: w.Tree(), TreeEntry: entry}})
+	}
+
+	return files, nil
+}
+
+// filterChanges returns the changes of a path accepted by filter.
+func filterChanges(changes Changes, filter func(path string) bool) Changes {
+	filtered := changes[:0]
+	for _, c := range changes {
+		if c.From.Name != "" && filter(c.From.Name) || c.To.Name != "" && filter(c.To.Name) {
+			filtered = append(filtered, c)
+		}
+	}
+
+	return filtered
+}
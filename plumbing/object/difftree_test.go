@@ -1,6 +1,7 @@
 package object
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"testing"
@@ -9,10 +10,12 @@ import (
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/cache"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
 	"github.com/go-git/go-git/v6/plumbing/format/packfile"
 	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/utils/diff"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/stretchr/testify/suite"
 )
@@ -376,3 +379,52 @@ func (s *DiffTreeSuite) TestIssue279() {
 	}
 	s.NotEqual(bb.Hash(), b.Hash())
 }
+
+func (s *DiffTreeSuite) TestDiffTreeLineDiff() {
+	sto := memory.NewStorage()
+	tree := func(content string) *Tree {
+		blob := sto.NewEncodedObject()
+		blob.SetType(plumbing.BlobObject)
+		w, err := blob.Writer()
+		s.Require().NoError(err)
+		_, err = w.Write([]byte(content))
+		s.Require().NoError(err)
+		s.Require().NoError(w.Close())
+		h, err := sto.SetEncodedObject(blob)
+		s.Require().NoError(err)
+
+		t := &Tree{Entries: []TreeEntry{{Name: "f", Mode: filemode.Regular, Hash: h}}}
+		obj := sto.NewEncodedObject()
+		s.Require().NoError(t.Encode(obj))
+		h, err = sto.SetEncodedObject(obj)
+		s.Require().NoError(err)
+
+		t, err = GetTree(sto, h)
+		s.Require().NoError(err)
+		return t
+	}
+
+	from := tree("f\n\t\ty\n\na\n")
+	to := tree("\n\n\t\ty\nf\nfunc f() {\n\n\t\ty\n")
+
+	for alg, ops := range map[diff.Algorithm][]fdiff.Operation{
+		diff.Myers:     {fdiff.Delete, fdiff.Add, fdiff.Equal, fdiff.Add, fdiff.Equal, fdiff.Delete, fdiff.Add},
+		diff.Histogram: {fdiff.Delete, fdiff.Equal, fdiff.Delete, fdiff.Add},
+	} {
+		changes, err := DiffTreeWithOptions(context.Background(), from, to, &DiffTreeOptions{
+			LineDiff: &diff.Options{Algorithm: alg},
+		})
+		s.Require().NoError(err)
+
+		p, err := changes.Patch()
+		s.Require().NoError(err)
+		s.Require().Len(p.FilePatches(), 1)
+
+		var obtained []fdiff.Operation
+		for _, c := range p.FilePatches()[0].Chunks() {
+			obtained = append(obtained, c.Type())
+		}
+
+		s.Equal(ops, obtained, alg.String())
+	}
+}
@@ -47,25 +47,57 @@ func getPatchContext(ctx context.Context, message string, changes ...*Change) (*
 }
 
 func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, error) {
+	if c.From.TreeEntry.Mode == filemode.Submodule || c.To.TreeEntry.Mode == filemode.Submodule {
+		return submoduleFilePatch(c)
+	}
+
 	from, to, err := c.Files()
 	if err != nil {
 		return nil, err
 	}
-	fromContent, fIsBinary, err := fileContent(from)
+	fromContent, fIsBinary, err := c.fileContent(c.From.Name, from)
 	if err != nil {
 		return nil, err
 	}
 
-	toContent, tIsBinary, err := fileContent(to)
+	toContent, tIsBinary, err := c.fileContent(c.To.Name, to)
 	if err != nil {
 		return nil, err
 	}
 
+	p := &textFilePatch{
+		from:          c.From,
+		to:            c.To,
+		similarity:    c.similarity,
+		copy:          c.copy,
+		dissimilarity: c.dissimilarity,
+	}
+	if from != nil {
+		p.fromSize = from.Size
+	}
+
+	if to != nil {
+		p.toSize = to.Size
+	}
+
 	if fIsBinary || tIsBinary {
-		return &textFilePatch{from: c.From, to: c.To}, nil
+		p.binary = true
+		return p, nil
+	}
+
+	// A rewrite deletes all the lines of the file and adds all the new
+	// ones, instead of mixing them with the few lines that match.
+	if c.dissimilarity != 0 {
+		for _, chunk := range []*textChunk{{fromContent, fdiff.Delete}, {toContent, fdiff.Add}} {
+			if chunk.content != "" {
+				p.chunks = append(p.chunks, chunk)
+			}
+		}
+
+		return p, nil
 	}
 
-	diffs := diff.Do(fromContent, toContent)
+	diffs := diff.DoWithOptions(fromContent, toContent, c.lineDiff)
 
 	var chunks []fdiff.Chunk
 	for _, d := range diffs {
@@ -88,12 +120,73 @@ func filePatchWithContext(ctx context.Context, c *Change) (fdiff.FilePatch, erro
 		chunks = append(chunks, &textChunk{d.Text, op})
 	}
 
-	return &textFilePatch{
-		chunks: chunks,
-		from:   c.From,
-		to:     c.To,
-	}, nil
+	p.chunks = chunks
+	return p, nil
+}
+
+// submoduleFilePatch returns the patch of a change of a submodule, its
+// commit being rendered as a "Subproject commit <hash>" line like git does.
+func submoduleFilePatch(c *Change) (fdiff.FilePatch, error) {
+	p := &textFilePatch{from: c.From, to: c.To, similarity: c.similarity, copy: c.copy}
+	for _, side := range []struct {
+		entry ChangeEntry
+		op    fdiff.Operation
+	}{{c.From, fdiff.Delete}, {c.To, fdiff.Add}} {
+		content, isBinary, err := changeEntryContent(side.entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if isBinary {
+			return &textFilePatch{from: c.From, to: c.To, binary: true}, nil
+		}
+
+		if content != "" {
+			p.chunks = append(p.chunks, &textChunk{content, side.op})
+		}
+	}
+
+	return p, nil
+}
+
+// changeEntryContent returns the content of a side of a change, the
+// "Subproject commit <hash>" line of a submodule.
+func changeEntryContent(e ChangeEntry) (content string, isBinary bool, err error) {
+	switch {
+	case e.Name == "":
+		return "", false, nil
+	case e.TreeEntry.Mode == filemode.Submodule:
+		return fmt.Sprintf("Subproject commit %s\n", e.TreeEntry.Hash), false, nil
+	case !e.TreeEntry.Mode.IsFile():
+		return "", false, nil
+	}
+
+	f, err := e.Tree.TreeEntryFile(&e.TreeEntry)
+	if err != nil {
+		return "", false, err
+	}
+
+	return fileContent(f)
+}
+
+// fileContent returns the content of the file f of the change at path,
+// unless it is binary as told by the binary option of the change or by its
+// content.
+func (c *Change) fileContent(path string, f *File) (content string, isBinary bool, err error) {
+	if f == nil || c.binary == nil {
+		return fileContent(f)
+	}
+
+	if isBinary, ok := c.binary(path); ok {
+		if isBinary {
+			return "", true, nil
+		}
+
+		content, err = f.Contents()
+		return content, false, err
+	}
 
+	return fileContent(f)
 }
 
 func fileContent(f *File) (content string, isBinary bool, err error) {
@@ -145,13 +238,15 @@ func (p *Patch) String() string {
 	return buf.String()
 }
 
-// changeEntryWrapper is an implementation of fdiff.File interface
+// changeEntryWrapper is an implementation of fdiff.SizedFile and
+// fdiff.ContentFile interfaces
 type changeEntryWrapper struct {
-	ce ChangeEntry
+	ce   ChangeEntry
+	size int64
 }
 
 func (f *changeEntryWrapper) Hash() plumbing.Hash {
-	if !f.ce.TreeEntry.Mode.IsFile() {
+	if !f.isPatchable() {
 		return plumbing.ZeroHash
 	}
 
@@ -162,26 +257,61 @@ func (f *changeEntryWrapper) Mode() filemode.FileMode {
 	return f.ce.TreeEntry.Mode
 }
 func (f *changeEntryWrapper) Path() string {
-	if !f.ce.TreeEntry.Mode.IsFile() {
+	if !f.isPatchable() {
 		return ""
 	}
 
 	return f.ce.Name
 }
 
+func (f *changeEntryWrapper) Size() int64 {
+	return f.size
+}
+
+func (f *changeEntryWrapper) Content() ([]byte, error) {
+	if !f.ce.TreeEntry.Mode.IsFile() {
+		return nil, nil
+	}
+
+	file, err := f.ce.Tree.TreeEntryFile(&f.ce.TreeEntry)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
 func (f *changeEntryWrapper) Empty() bool {
-	return !f.ce.TreeEntry.Mode.IsFile()
+	return !f.isPatchable()
 }
 
-// textFilePatch is an implementation of fdiff.FilePatch interface
+// isPatchable reports whether the entry has a content in a patch: the files
+// and the submodules.
+func (f *changeEntryWrapper) isPatchable() bool {
+	return f.ce.TreeEntry.Mode.IsFile() || f.ce.TreeEntry.Mode == filemode.Submodule
+}
+
+// textFilePatch is an implementation of the fdiff.SimilarFilePatch and
+// fdiff.RewriteFilePatch interfaces
 type textFilePatch struct {
-	chunks   []fdiff.Chunk
-	from, to ChangeEntry
+	chunks           []fdiff.Chunk
+	from, to         ChangeEntry
+	fromSize, toSize int64
+	similarity       int
+	copy             bool
+	dissimilarity    int
+	binary           bool
 }
 
 func (tf *textFilePatch) Files() (from fdiff.File, to fdiff.File) {
-	f := &changeEntryWrapper{tf.from}
-	t := &changeEntryWrapper{tf.to}
+	f := &changeEntryWrapper{tf.from, tf.fromSize}
+	t := &changeEntryWrapper{tf.to, tf.toSize}
 
 	if !f.Empty() {
 		from = f
@@ -195,13 +325,25 @@ func (tf *textFilePatch) Files() (from fdiff.File, to fdiff.File) {
 }
 
 func (tf *textFilePatch) IsBinary() bool {
-	return len(tf.chunks) == 0
+	return tf.binary
 }
 
 func (tf *textFilePatch) Chunks() []fdiff.Chunk {
 	return tf.chunks
 }
 
+func (tf *textFilePatch) Similarity() int {
+	return tf.similarity
+}
+
+func (tf *textFilePatch) IsCopy() bool {
+	return tf.copy
+}
+
+func (tf *textFilePatch) Dissimilarity() int {
+	return tf.dissimilarity
+}
+
 // textChunk is an implementation of fdiff.Chunk interface
 type textChunk struct {
 	content string
@@ -290,7 +432,7 @@ func getFileStatsFromFilePatches(filePatches []fdiff.FilePatch) FileStats {
 	var fileStats FileStats
 
 	for _, fp := range filePatches {
-		// ignore empty patches (binary files, submodule refs updates)
+		// ignore empty patches (binary files)
 		if len(fp.Chunks()) == 0 {
 			continue
 		}
@@ -160,3 +160,48 @@ func (s *PatchSuite) TestFileStatsString() {
 		s.Equal(tc.expected, printStat(tc.input))
 	}
 }
+
+func (s *PatchSuite) TestSubmodulePatch() {
+	storer := filesystem.NewStorage(
+		fixtures.ByURL("https://github.com/git-fixtures/submodule.git").One().DotGit(), cache.NewObjectLRUDefault())
+
+	commit, err := GetCommit(storer, plumbing.NewHash("b685400c1f9316f350965a5993d350bc746b0bf4"))
+	s.NoError(err)
+
+	tree, err := commit.Tree()
+	s.NoError(err)
+
+	e, err := tree.entry("basic")
+	s.NoError(err)
+
+	to := *e
+	to.Hash = plumbing.NewHash("b029517f6300c2da0f4b651b8642506cd6aaf45d")
+
+	p, err := getPatch("", &Change{
+		From: ChangeEntry{Name: "basic", Tree: tree, TreeEntry: *e},
+		To:   ChangeEntry{Name: "basic", Tree: tree, TreeEntry: to},
+	})
+	s.NoError(err)
+	s.Equal(`diff --git a/basic b/basic
+index `+e.Hash.String()+`..b029517f6300c2da0f4b651b8642506cd6aaf45d 160000
+--- a/basic
++++ b/basic
+@@ -1 +1 @@
+-Subproject commit `+e.Hash.String()+`
++Subproject commit b029517f6300c2da0f4b651b8642506cd6aaf45d
+`, p.String())
+	s.Equal(FileStats{{Name: "basic", Addition: 1, Deletion: 1}}, p.Stats())
+
+	p, err = getPatch("", &Change{
+		To: ChangeEntry{Name: "basic", Tree: tree, TreeEntry: *e},
+	})
+	s.NoError(err)
+	s.Equal(`diff --git a/basic b/basic
+new file mode 160000
+index `+plumbing.ZeroHash.String()+`..`+e.Hash.String()+`
+--- /dev/null
++++ b/basic
+@@ -0,0 +1 @@
++Subproject commit `+e.Hash.String()+`
+`, p.String())
+}
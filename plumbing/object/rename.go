@@ -15,6 +15,9 @@ import (
 // DetectRenames detects the renames in the given changes on two trees with
 // the given options. It will return the given changes grouping additions and
 // deletions into modifications when possible.
+// The copies and the rewrites are also detected when the options ask for
+// them, the unmodified files being sources of the copies only with
+// DiffTreeWithOptions.
 // If options is nil, the default diff tree options will be used.
 func DetectRenames(
 	changes Changes,
@@ -24,10 +27,37 @@ func DetectRenames(
 		opts = DefaultDiffTreeOptions
 	}
 
+	detector, err := newRenameDetector(changes, opts, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return detector.detect()
+}
+
+// newRenameDetector returns a renameDetector of the given changes, detecting
+// the renames when renames is true, and the copies and the rewrites when the
+// options ask for them.
+func newRenameDetector(changes Changes, opts *DiffTreeOptions, renames bool) (*renameDetector, error) {
 	detector := &renameDetector{
-		renameScore: int(opts.RenameScore),
-		renameLimit: int(opts.RenameLimit),
-		onlyExact:   opts.OnlyExactRenames,
+		renames:      renames,
+		renameScore:  int(opts.RenameScore),
+		renameLimit:  int(opts.RenameLimit),
+		onlyExact:    opts.OnlyExactRenames,
+		copies:       renames && opts.DetectCopies,
+		breakScore:   defaultBreakScore,
+		rewriteScore: defaultRewriteScore,
+	}
+
+	if opts.BreakRewrites {
+		detector.broken = make(map[*Change]*Change)
+		if opts.BreakScore != 0 {
+			detector.breakScore = int(opts.BreakScore)
+		}
+
+		if opts.RewriteScore != 0 {
+			detector.rewriteScore = int(opts.RewriteScore)
+		}
 	}
 
 	for _, c := range changes {
@@ -46,19 +76,44 @@ func DetectRenames(
 		}
 	}
 
-	return detector.detect()
+	return detector, nil
 }
 
+const (
+	// defaultBreakScore is the default dissimilarity above which the
+	// modifications are broken, like git.
+	defaultBreakScore = 50
+	// defaultRewriteScore is the default dissimilarity from which the
+	// broken modifications are rewrites, like git.
+	defaultRewriteScore = 60
+	// minBreakSize is the size of the largest file of a modification under
+	// which it is not broken, like git.
+	minBreakSize = 400
+)
+
 // renameDetector will detect and resolve renames in a set of changes.
 // see: https://github.com/eclipse/jgit/blob/master/org.eclipse.jgit/src/org/eclipse/jgit/diff/RenameDetector.java
 type renameDetector struct {
 	added    []*Change
 	deleted  []*Change
 	modified []*Change
-
-	renameScore int
-	renameLimit int
-	onlyExact   bool
+	// unmodified are the unmodified files, as deletions, which are
+	// sources of the copies with FindCopiesHarder.
+	unmodified []*Change
+	// sources are the deletions of the kept files which are sources of
+	// the copies: the old files of the modifications.
+	sources []*Change
+	// broken are the broken modifications by the insertion of their new
+	// file, see breakModifications.
+	broken map[*Change]*Change
+
+	renames      bool
+	renameScore  int
+	renameLimit  int
+	onlyExact    bool
+	copies       bool
+	breakScore   int
+	rewriteScore int
 }
 
 // detectExactRenames detects matches files that were deleted with files that
@@ -86,7 +141,7 @@ func (d *renameDetector) detectExactRenames() {
 
 		if len(deleted) == 1 {
 			if sameMode(c, deleted[0]) {
-				d.modified = append(d.modified, &Change{From: deleted[0].From, To: c.To})
+				d.modified = append(d.modified, &Change{From: deleted[0].From, To: c.To, similarity: 100})
 				delete(deletes, hash)
 			} else {
 				addedLeft = append(addedLeft, c)
@@ -94,7 +149,7 @@ func (d *renameDetector) detectExactRenames() {
 		} else if len(deleted) > 1 {
 			bestMatch := bestNameMatch(c, deleted)
 			if bestMatch != nil && sameMode(c, bestMatch) {
-				d.modified = append(d.modified, &Change{From: bestMatch.From, To: c.To})
+				d.modified = append(d.modified, &Change{From: bestMatch.From, To: c.To, similarity: 100})
 				delete(deletes, hash)
 
 				var newDeletes = make([]*Change, 0, len(deleted)-1)
@@ -118,7 +173,7 @@ func (d *renameDetector) detectExactRenames() {
 			deleted := deleted[0]
 			bestMatch := bestNameMatch(deleted, added)
 			if bestMatch != nil && sameMode(deleted, bestMatch) {
-				d.modified = append(d.modified, &Change{From: deleted.From, To: bestMatch.To})
+				d.modified = append(d.modified, &Change{From: deleted.From, To: bestMatch.To, similarity: 100})
 				delete(deletes, hash)
 
 				for _, c := range added {
@@ -171,7 +226,7 @@ func (d *renameDetector) detectExactRenames() {
 
 				usedAdds[add] = struct{}{}
 				usedDeletes[del] = struct{}{}
-				d.modified = append(d.modified, &Change{From: del.From, To: add.To})
+				d.modified = append(d.modified, &Change{From: del.From, To: add.To, similarity: 100})
 				added[matrix[i].added] = nil
 				deleted[matrix[i].deleted] = nil
 			}
@@ -230,7 +285,7 @@ func (d *renameDetector) detectContentRenames() error {
 			continue
 		}
 
-		renames = append(renames, &Change{From: src.From, To: dst.To})
+		renames = append(renames, &Change{From: src.From, To: dst.To, similarity: pair.score})
 
 		// Claim destination and source as matched
 		dsts[pair.added] = nil
@@ -245,7 +300,19 @@ func (d *renameDetector) detectContentRenames() error {
 }
 
 func (d *renameDetector) detect() (Changes, error) {
-	if len(d.added) > 0 && len(d.deleted) > 0 {
+	if d.broken != nil {
+		if err := d.breakModifications(); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.copies {
+		for _, c := range d.modified {
+			d.sources = append(d.sources, &Change{From: c.From})
+		}
+	}
+
+	if d.renames && len(d.added) > 0 && len(d.deleted) > 0 {
 		d.detectExactRenames()
 
 		if !d.onlyExact {
@@ -255,6 +322,14 @@ func (d *renameDetector) detect() (Changes, error) {
 		}
 	}
 
+	if d.renames && len(d.added) > 0 && (d.copies || len(d.sources) > 0) {
+		if err := d.detectCopies(); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mergeBroken()
+
 	result := make(Changes, 0, len(d.added)+len(d.deleted)+len(d.modified))
 	result = append(result, d.added...)
 	result = append(result, d.deleted...)
@@ -265,6 +340,243 @@ func (d *renameDetector) detect() (Changes, error) {
 	return result, nil
 }
 
+// breakModifications breaks the modifications of the regular files whose
+// files are dissimilar enough, see shouldBreak, into their old file, a
+// source of the copies, and the insertion of their new file, a destination
+// of the renames. They are merged back by mergeBroken unless their new
+// file was renamed.
+func (d *renameDetector) breakModifications() error {
+	modified := d.modified[:0]
+	for _, c := range d.modified {
+		dissimilarity, broken, err := shouldBreak(c, d.breakScore)
+		if err != nil {
+			return err
+		}
+
+		if !broken {
+			modified = append(modified, c)
+			continue
+		}
+
+		rewrite := *c
+		if dissimilarity >= d.rewriteScore {
+			rewrite.dissimilarity = dissimilarity
+		}
+
+		added := &Change{To: c.To}
+		d.broken[added] = &rewrite
+		d.added = append(d.added, added)
+		d.sources = append(d.sources, &Change{From: c.From})
+	}
+
+	d.modified = modified
+	return nil
+}
+
+// mergeBroken merges back the broken modifications whose new file is not
+// the destination of a rename or a copy, the others being replaced by it.
+func (d *renameDetector) mergeBroken() {
+	if len(d.broken) == 0 {
+		return
+	}
+
+	added := d.added[:0]
+	for _, c := range d.added {
+		if m, ok := d.broken[c]; ok {
+			d.modified = append(d.modified, m)
+			continue
+		}
+
+		added = append(added, c)
+	}
+
+	d.added = added
+}
+
+// shouldBreak returns the dissimilarity of the files of the modification c,
+// from 0 to 100, the share of the old file which was removed, and whether
+// it is broken with the given break score. Like git, the modifications of
+// small files and the ones mostly removing content are not broken.
+func shouldBreak(c *Change, breakScore int) (dissimilarity int, broken bool, err error) {
+	if c.From.Name != c.To.Name || changeHash(c) == c.From.TreeEntry.Hash ||
+		c.From.TreeEntry.Mode != filemode.Regular || c.To.TreeEntry.Mode != filemode.Regular {
+		return 0, false, nil
+	}
+
+	from, to, err := c.Files()
+	if err != nil {
+		return 0, false, err
+	}
+
+	if max(int(from.Size), int(to.Size)) < minBreakSize || from.Size == 0 {
+		return 0, false, nil
+	}
+
+	fromIndex, err := fileSimilarityIndex(from)
+	if err == errIndexFull {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	toIndex, err := fileSimilarityIndex(to)
+	if err == errIndexFull {
+		return 0, false, nil
+	}
+
+	if err != nil {
+		return 0, false, err
+	}
+
+	fromSize, toSize := uint64(from.Size), uint64(to.Size)
+	copied := fromIndex.common(toIndex)
+	added := toIndex.hashed - copied
+	if copied > fromSize {
+		copied = fromSize
+	}
+
+	if toSize < added+copied {
+		added = 0
+		if copied < toSize {
+			added = toSize - copied
+		}
+	}
+
+	removed := fromSize - copied
+	dissimilarity = int(removed * 100 / fromSize)
+	if dissimilarity > breakScore {
+		return dissimilarity, true, nil
+	}
+
+	largest := fromSize
+	if toSize > largest {
+		largest = toSize
+	}
+
+	if (removed+added)*100/largest < uint64(breakScore) {
+		return dissimilarity, false, nil
+	}
+
+	// Removing a lot without adding much is not a rewrite.
+	if fromSize*uint64(breakScore) < removed*100 && added*20 < removed && added*20 < copied {
+		return dissimilarity, false, nil
+	}
+
+	return dissimilarity, true, nil
+}
+
+// detectCopies detects the copies of the sources in the added files, by
+// their hash first and then by the similarity of their content. The sources
+// are the old files of the modifications, the files renamed and deleted
+// when copies are detected, and the unmodified files with
+// FindCopiesHarder. The copies of a renamed file are resolved like git,
+// see resolveCopies.
+func (d *renameDetector) detectCopies() error {
+	srcs := d.sources
+	if d.copies {
+		for _, c := range d.modified {
+			if c.From.Name != c.To.Name && !c.copy {
+				srcs = append(srcs, &Change{From: c.From})
+			}
+		}
+	}
+
+	srcs = append(srcs, d.unmodified...)
+	if d.renameLimit > 0 && max(len(srcs), len(d.added)) > d.renameLimit {
+		srcs = srcs[:len(srcs)-len(d.unmodified)]
+	}
+
+	if len(srcs) == 0 {
+		return nil
+	}
+
+	bySrcHash := groupChangesByHash(srcs)
+	dsts := make([]*Change, 0, len(d.added))
+	for _, c := range d.added {
+		var candidates []*Change
+		for _, src := range bySrcHash[changeHash(c)] {
+			if sameMode(c, src) {
+				candidates = append(candidates, src)
+			}
+		}
+
+		if len(candidates) > 0 {
+			src := bestNameMatch(c, candidates)
+			if src == nil {
+				src = candidates[0]
+			}
+
+			d.modified = append(d.modified, &Change{From: src.From, To: c.To, similarity: 100, copy: true})
+			continue
+		}
+
+		dsts = append(dsts, c)
+	}
+
+	d.added = dsts
+	if d.onlyExact || len(dsts) == 0 ||
+		d.renameLimit > 0 && max(len(srcs), len(dsts)) > d.renameLimit {
+		d.resolveCopies()
+		return nil
+	}
+
+	matrix, err := buildSimilarityMatrix(srcs, dsts, d.renameScore)
+	if err != nil {
+		return err
+	}
+
+	// The sources can be copied several times, unlike the ones of the
+	// renames, but the new file of a broken modification is not a copy of
+	// its old file.
+	for i := len(matrix) - 1; i >= 0; i-- {
+		pair := matrix[i]
+		dst := dsts[pair.added]
+		if dst == nil || srcs[pair.deleted].From.Name == dst.To.Name {
+			continue
+		}
+
+		d.modified = append(d.modified, &Change{From: srcs[pair.deleted].From, To: dst.To, similarity: pair.score, copy: true})
+		dsts[pair.added] = nil
+	}
+
+	d.added = compactChanges(dsts)
+	d.resolveCopies()
+	return nil
+}
+
+// resolveCopies makes the last of the renames and the copies of a renamed
+// file, in the order of their paths, the rename and the others the copies,
+// like git.
+func (d *renameDetector) resolveCopies() {
+	uses := make(map[string][]*Change)
+	for _, c := range d.modified {
+		if c.From.Name != c.To.Name {
+			uses[c.From.Name] = append(uses[c.From.Name], c)
+		}
+	}
+
+	for _, cs := range uses {
+		renamed := false
+		last := cs[0]
+		for _, c := range cs {
+			renamed = renamed || !c.copy
+			if c.To.Name > last.To.Name {
+				last = c
+			}
+		}
+
+		if !renamed {
+			continue
+		}
+
+		for _, c := range cs {
+			c.copy = c != last
+		}
+	}
+}
+
 func bestNameMatch(change *Change, changes []*Change) *Change {
 	var best *Change
 	var bestScore int
@@ -1,13 +1,16 @@
 package object
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
 	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -158,6 +161,27 @@ func (s *RenameSuite) TestContentRename_OnePair() {
 	assertRename(s, changes[1], changes[0], result[0])
 }
 
+func (s *RenameSuite) TestContentRename_PatchSimilarity() {
+	changes := Changes{
+		makeAdd(s, makeFile(s, pathQ, filemode.Regular, "foo\nbar\nbaz\nblarg\n")),
+		makeDelete(s, makeFile(s, pathA, filemode.Regular, "foo\nbar\nbaz\nblah\n")),
+	}
+
+	result := detectRenames(s, changes, nil, 1)
+	patch, err := result[0].Patch()
+	s.NoError(err)
+	s.Len(patch.FilePatches(), 1)
+
+	fp, ok := patch.FilePatches()[0].(fdiff.SimilarFilePatch)
+	s.True(ok)
+	s.Equal(result[0].similarity, fp.Similarity())
+	s.False(fp.IsCopy())
+
+	from, to := fp.Files()
+	s.Equal(int64(17), from.(fdiff.SizedFile).Size())
+	s.Equal(int64(18), to.(fdiff.SizedFile).Size())
+}
+
 func (s *RenameSuite) TestContentRename_OneRenameTwoUnrelatedFiles() {
 	changes := Changes{
 		makeAdd(s, makeFile(s, pathA, filemode.Regular, "foo\nbar\nbaz\nblarg\n")),
@@ -301,6 +325,148 @@ func (s *RenameSuite) TestRenameExactManyAddsManyDeletesNoGaps() {
 	}
 }
 
+func (s *RenameSuite) TestCopies_FromModifiedFile() {
+	c1 := makeChange(s,
+		makeFile(s, pathH, filemode.Regular, "foo\nbar\nbaz\nblah\n"),
+		makeFile(s, pathH, filemode.Regular, "foo\n"),
+	)
+	c2 := makeAdd(s, makeFile(s, pathA, filemode.Regular, "foo\nbar\nbaz\nblah\n"))
+	c3 := makeAdd(s, makeFile(s, pathQ, filemode.Regular, "foo\nbar\nbaz\nblarg\n"))
+
+	result := detectRenames(s, Changes{c1, c2, c3}, nil, 3)
+	s.Equal(c2, result[0])
+	s.Equal(c1, result[1])
+	s.Equal(c3, result[2])
+
+	result = detectRenames(s, Changes{c1, c2, c3}, &DiffTreeOptions{RenameScore: 50, DetectCopies: true}, 3)
+	assertCopy(s, c1, c2, result[0])
+	s.Equal(100, result[0].Similarity())
+	s.Equal(c1, result[1])
+	assertCopy(s, c1, c3, result[2])
+	s.Less(result[2].Similarity(), 100)
+}
+
+func (s *RenameSuite) TestCopies_OfRenamedFile() {
+	c1 := makeDelete(s, makeFile(s, pathQ, filemode.Regular, "foo"))
+	c2 := makeAdd(s, makeFile(s, pathA, filemode.Regular, "foo"))
+	c3 := makeAdd(s, makeFile(s, pathH, filemode.Regular, "foo"))
+
+	result := detectRenames(s, Changes{c1, c2, c3}, nil, 2)
+	s.Equal(c3, result[0])
+	assertRename(s, c1, c2, result[1])
+
+	// Like git, the last of them by path is the rename.
+	result = detectRenames(s, Changes{c1, c2, c3}, &DiffTreeOptions{DetectCopies: true}, 2)
+	assertCopy(s, c1, c2, result[0])
+	assertRename(s, c1, c3, result[1])
+	action, err := result[1].Action()
+	s.NoError(err)
+	s.Equal(merkletrie.Modify, action)
+}
+
+func (s *RenameSuite) TestCopies_OnlyExact() {
+	c1 := makeChange(s,
+		makeFile(s, pathH, filemode.Regular, "foo\nbar\nbaz\nblah\n"),
+		makeFile(s, pathH, filemode.Regular, "foo\n"),
+	)
+	c2 := makeAdd(s, makeFile(s, pathA, filemode.Regular, "foo\nbar\nbaz\nblarg\n"))
+
+	result := detectRenames(s, Changes{c1, c2}, &DiffTreeOptions{DetectCopies: true, OnlyExactRenames: true}, 2)
+	s.Equal(c2, result[0])
+	s.Equal(c1, result[1])
+}
+
+func (s *RenameSuite) TestBreakRewrites_Rewrite() {
+	c1 := makeChange(s,
+		makeFile(s, pathA, filemode.Regular, lines("old", 40)),
+		makeFile(s, pathA, filemode.Regular, lines("new", 40)),
+	)
+	c2 := makeChange(s,
+		makeFile(s, pathH, filemode.Regular, lines("old", 40)),
+		makeFile(s, pathH, filemode.Regular, lines("old", 40)+"new\n"),
+	)
+	c3 := makeChange(s,
+		makeFile(s, pathQ, filemode.Regular, "old\n"),
+		makeFile(s, pathQ, filemode.Regular, "new\n"),
+	)
+
+	result := detectRenames(s, Changes{c1, c2, c3}, &DiffTreeOptions{BreakRewrites: true}, 3)
+	s.Equal(c1.From, result[0].From)
+	s.Equal(c1.To, result[0].To)
+	s.Equal(100, result[0].Dissimilarity())
+	s.Equal(0, c1.Dissimilarity())
+	s.Equal(0, result[1].Dissimilarity())
+	s.Equal(0, result[2].Dissimilarity())
+
+	p, err := result[0].Patch()
+	s.NoError(err)
+	chunks := p.FilePatches()[0].Chunks()
+	s.Len(chunks, 2)
+	s.Equal(fdiff.Delete, chunks[0].Type())
+	s.Equal(fdiff.Add, chunks[1].Type())
+}
+
+func (s *RenameSuite) TestBreakRewrites_RewriteScore() {
+	c1 := makeChange(s,
+		makeFile(s, pathA, filemode.Regular, lines("old", 20)+lines("same", 20)),
+		makeFile(s, pathA, filemode.Regular, lines("new", 20)+lines("same", 20)),
+	)
+
+	result := detectRenames(s, Changes{c1}, &DiffTreeOptions{BreakRewrites: true}, 1)
+	s.Equal(0, result[0].Dissimilarity())
+
+	result = detectRenames(s, Changes{c1}, &DiffTreeOptions{BreakRewrites: true, BreakScore: 40, RewriteScore: 40}, 1)
+	s.Equal(49, result[0].Dissimilarity())
+}
+
+func (s *RenameSuite) TestBreakRewrites_CopySource() {
+	c1 := makeChange(s,
+		makeFile(s, pathA, filemode.Regular, lines("old", 40)),
+		makeFile(s, pathA, filemode.Regular, lines("new", 40)),
+	)
+	c2 := makeAdd(s, makeFile(s, pathH, filemode.Regular, lines("old", 40)))
+
+	result := detectRenames(s, Changes{c1, c2}, nil, 2)
+	s.Equal(c1, result[0])
+	s.Equal(c2, result[1])
+
+	result = detectRenames(s, Changes{c1, c2}, &DiffTreeOptions{BreakRewrites: true}, 2)
+	s.Equal(c1.To, result[0].To)
+	s.Equal(100, result[0].Dissimilarity())
+	assertCopy(s, c1, c2, result[1])
+}
+
+func (s *RenameSuite) TestBreakRewrites_RenameDestination() {
+	c1 := makeChange(s,
+		makeFile(s, pathA, filemode.Regular, lines("old", 40)),
+		makeFile(s, pathA, filemode.Regular, lines("new", 40)),
+	)
+	c2 := makeDelete(s, makeFile(s, pathQ, filemode.Regular, lines("new", 40)))
+
+	result := detectRenames(s, Changes{c1, c2}, &DiffTreeOptions{BreakRewrites: true}, 1)
+	assertRename(s, c2, c1, result[0])
+}
+
+func lines(prefix string, n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "%s line number %d of the file\n", prefix, i)
+	}
+
+	return sb.String()
+}
+
+func assertCopy(s *RenameSuite, from, to *Change, copy *Change) {
+	s.Equal(from.From, copy.From)
+	s.Equal(to.To, copy.To)
+	s.GreaterOrEqual(copy.Similarity(), 1)
+	s.LessOrEqual(copy.Similarity(), 100)
+
+	action, err := copy.Action()
+	s.NoError(err)
+	s.Equal(merkletrie.Copy, action)
+}
+
 func detectRenames(s *RenameSuite, changes Changes, opts *DiffTreeOptions, expectedResults int) Changes {
 	result, err := DetectRenames(changes, opts)
 	s.NoError(err)
@@ -309,7 +475,10 @@ func detectRenames(s *RenameSuite, changes Changes, opts *DiffTreeOptions, expec
 }
 
 func assertRename(s *RenameSuite, from, to *Change, rename *Change) {
-	s.Equal(rename, &Change{From: from.From, To: to.To})
+	s.Equal(from.From, rename.From)
+	s.Equal(to.To, rename.To)
+	s.GreaterOrEqual(rename.similarity, 1)
+	s.LessOrEqual(rename.similarity, 100)
 }
 
 type SimilarityIndexSuite struct {
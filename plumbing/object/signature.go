@@ -1,12 +1,28 @@
 package object
 
-import "bytes"
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"io"
+
+	"github.com/go-git/go-git/v6/internal/cms"
+	"github.com/go-git/go-git/v6/plumbing"
+)
+
+// ErrNotX509Signature is returned when verifying an X.509 signature on an
+// object that is unsigned or signed with a different format.
+var ErrNotX509Signature = errors.New("object is not signed with an X.509 signature")
 
 const (
-	signatureTypeUnknown signatureType = iota
-	signatureTypeOpenPGP
-	signatureTypeX509
-	signatureTypeSSH
+	// SignatureTypeUnknown is used for missing or unrecognized signatures.
+	SignatureTypeUnknown SignatureType = iota
+	// SignatureTypeOpenPGP is an OpenPGP signature (gpg.format=openpgp).
+	SignatureTypeOpenPGP
+	// SignatureTypeX509 is a CMS signature (gpg.format=x509).
+	SignatureTypeX509
+	// SignatureTypeSSH is an SSH signature (gpg.format=ssh).
+	SignatureTypeSSH
 )
 
 var (
@@ -30,22 +46,42 @@ var (
 
 var (
 	// knownSignatureFormats is a map of known signature formats, indexed by
-	// their signatureType.
-	knownSignatureFormats = map[signatureType]signatureFormat{
-		signatureTypeOpenPGP: openPGPSignatureFormat,
-		signatureTypeX509:    x509SignatureFormat,
-		signatureTypeSSH:     sshSignatureFormat,
+	// their SignatureType.
+	knownSignatureFormats = map[SignatureType]signatureFormat{
+		SignatureTypeOpenPGP: openPGPSignatureFormat,
+		SignatureTypeX509:    x509SignatureFormat,
+		SignatureTypeSSH:     sshSignatureFormat,
 	}
 )
 
-// signatureType represents the type of the signature.
-type signatureType int8
+// SignatureType represents the type of the signature.
+type SignatureType int8
+
+// String returns the name of the type, as used by the gpg.format option.
+func (t SignatureType) String() string {
+	switch t {
+	case SignatureTypeOpenPGP:
+		return "openpgp"
+	case SignatureTypeX509:
+		return "x509"
+	case SignatureTypeSSH:
+		return "ssh"
+	}
+	return "unknown"
+}
+
+// DetectSignatureType returns the type of the last signature block found
+// in the given bytes.
+func DetectSignatureType(b []byte) SignatureType {
+	_, t := parseSignedBytes(b)
+	return t
+}
 
 // signatureFormat represents the beginning of a signature.
 type signatureFormat [][]byte
 
 // typeForSignature returns the type of the signature based on its format.
-func typeForSignature(b []byte) signatureType {
+func typeForSignature(b []byte) SignatureType {
 	for t, i := range knownSignatureFormats {
 		for _, begin := range i {
 			if bytes.HasPrefix(b, begin) {
@@ -53,7 +89,7 @@ func typeForSignature(b []byte) signatureType {
 			}
 		}
 	}
-	return signatureTypeUnknown
+	return SignatureTypeUnknown
 }
 
 // parseSignedBytes returns the position of the last signature block found in
@@ -82,12 +118,12 @@ func typeForSignature(b []byte) signatureType {
 //
 // This logic is on par with git's gpg-interface.c:parse_signed_buffer().
 // https://github.com/git/git/blob/7c2ef319c52c4997256f5807564523dfd4acdfc7/gpg-interface.c#L668
-func parseSignedBytes(b []byte) (int, signatureType) {
+func parseSignedBytes(b []byte) (int, SignatureType) {
 	var n, match = 0, -1
-	var t signatureType
+	var t SignatureType
 	for n < len(b) {
 		var i = b[n:]
-		if st := typeForSignature(i); st != signatureTypeUnknown {
+		if st := typeForSignature(i); st != SignatureTypeUnknown {
 			match = n
 			t = st
 		}
@@ -100,3 +136,32 @@ func parseSignedBytes(b []byte) (int, signatureType) {
 	}
 	return match, t
 }
+
+// verifyX509 checks the CMS detached signature over the encoded payload.
+func verifyX509(signature []byte, payload plumbing.EncodedObject, opts x509.VerifyOptions) (*x509.Certificate, error) {
+	if typeForSignature(signature) != SignatureTypeX509 {
+		return nil, ErrNotX509Signature
+	}
+
+	sd, err := cms.Parse(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := payload.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := sd.Verify(content, opts); err != nil {
+		return nil, err
+	}
+
+	return sd.Signer, nil
+}
@@ -9,7 +9,7 @@ func Test_typeForSignature(t *testing.T) {
 	tests := []struct {
 		name string
 		b    []byte
-		want signatureType
+		want SignatureType
 	}{
 		{
 			name: "known signature format (PGP)",
@@ -20,7 +20,7 @@ iHUEABYKAB0WIQTMqU0ycQ3f6g3PMoWMmmmF4LuV8QUCYGebVwAKCRCMmmmF4LuV
 TssDKHUR2taa53bQYjkZQBpvvwOrLgc=
 =YQUf
 -----END PGP SIGNATURE-----`),
-			want: signatureTypeOpenPGP,
+			want: SignatureTypeOpenPGP,
 		},
 		{
 			name: "known signature format (SSH)",
@@ -30,7 +30,7 @@ U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgij/EfHS8tCjolj5uEANXgKzFfp
 AAAAQIYHMhSVV9L2xwJuV8eWMLjThya8yXgCHDzw3p01D19KirrabW0veiichPB5m+Ihtr
 MKEQruIQWJb+8HVXwssA4=
 -----END SSH SIGNATURE-----`),
-			want: signatureTypeSSH,
+			want: SignatureTypeSSH,
 		},
 		{
 			name: "known signature format (X509) CERTIFICATE",
@@ -43,7 +43,7 @@ AlNFMQ4wDAYDVQQIDAVUZXhhczEOMAwGA1UEBwwFVGV4YXMxDjAMBgNVBAoMBVRl
 eGFzMQ4wDAYDVQQLDAVUZXhhczEYMBYGA1UEAwwPVGV4YXMgQ2VydGlmaWNhdGUw
 ggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQDQZ9Z3Z9Z3Z9Z3Z9Z3Z9Z3
 -----END CERTIFICATE-----`),
-			want: signatureTypeX509,
+			want: SignatureTypeX509,
 		},
 		{
 			name: "known signature format (x509) SIGNED MESSAGE",
@@ -56,14 +56,14 @@ AlNFMQ4wDAYDVQQIDAVUZXhhczEOMAwGA1UEBwwFVGV4YXMxDjAMBgNVBAoMBVRl
 eGFzMQ4wDAYDVQQLDAVUZXhhczEYMBYGA1UEAwwPVGV4YXMgQ2VydGlmaWNhdGUw
 ggEiMA0GCSqGSIb3DQEBAQUAA4IBDwAwggEKAoIBAQDQZ9Z3Z9Z3Z9Z3Z9Z3Z9Z3
 -----END SIGNED MESSAGE-----`),
-			want: signatureTypeX509,
+			want: SignatureTypeX509,
 		},
 		{
 			name: "unknown signature format",
 			b: []byte(`-----BEGIN ARBITRARY SIGNATURE-----
 U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgij/EfHS8tCjolj5uEANXgKzFfp
 -----END UNKNOWN SIGNATURE-----`),
-			want: signatureTypeUnknown,
+			want: SignatureTypeUnknown,
 		},
 	}
 	for _, tt := range tests {
@@ -80,7 +80,7 @@ func Test_parseSignedBytes(t *testing.T) {
 		name          string
 		b             []byte
 		wantSignature []byte
-		wantType      signatureType
+		wantType      SignatureType
 	}{
 		{
 			name: "detects signature and type",
@@ -113,7 +113,7 @@ pAe1/EFuhv2UDLucAiWM8iDZIcw8iN0OYMOGUmnk0WuGIo7dzLeqMGY+ND5n5Z8J
 sZC//k6m
 =VhHy
 -----END PGP SIGNATURE-----`),
-			wantType: signatureTypeOpenPGP,
+			wantType: SignatureTypeOpenPGP,
 		},
 		{
 			name: "last signature for multiple signatures",
@@ -144,7 +144,7 @@ U1NIU0lHAAAAAQAAADMAAAALc3NoLWVkMjU1MTkAAAAgij/EfHS8tCjolj5uEANXgKzFfp
 AAAAQIYHMhSVV9L2xwJuV8eWMLjThya8yXgCHDzw3p01D19KirrabW0veiichPB5m+Ihtr
 MKEQruIQWJb+8HVXwssA4=
 -----END SSH SIGNATURE-----`),
-			wantType: signatureTypeSSH,
+			wantType: SignatureTypeSSH,
 		},
 		{
 			name: "signature with trailing data",
@@ -166,13 +166,13 @@ MKEQruIQWJb+8HVXwssA4=
 -----END SSH SIGNATURE-----
 
 signed tag`),
-			wantType: signatureTypeSSH,
+			wantType: SignatureTypeSSH,
 		},
 		{
 			name:          "data without signature",
 			b:             []byte(`Some message`),
 			wantSignature: []byte(``),
-			wantType:      signatureTypeUnknown,
+			wantType:      SignatureTypeUnknown,
 		},
 	}
 	for _, tt := range tests {
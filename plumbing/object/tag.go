@@ -2,6 +2,7 @@ package object
 
 import (
 	"bytes"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"strings"
@@ -280,6 +281,22 @@ func (t *Tag) Verify(armoredKeyRing string) (*openpgp.Entity, error) {
 	return openpgp.CheckArmoredDetachedSignature(keyring, er, signature, nil)
 }
 
+// VerifyX509 performs X.509 (CMS) verification of the tag signature, as
+// produced with gpg.format=x509 by tools like gpgsm, smimesign or gitsign.
+// The signer certificate chain is validated with opts, at the current time
+// when opts.CurrentTime is zero; the verifier of NewX509VerifierAtSigningTime
+// of the git package validates it at the signing time instead. The signer
+// certificate is returned on success.
+func (t *Tag) VerifyX509(opts x509.VerifyOptions) (*x509.Certificate, error) {
+	encoded := &plumbing.MemoryObject{}
+	// Encode tag components, excluding signature and get a reader object.
+	if err := t.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+
+	return verifyX509([]byte(t.PGPSignature), encoded, opts)
+}
+
 // TagIter provides an iterator for a set of tags.
 type TagIter struct {
 	storer.EncodedObjectIter
@@ -0,0 +1,98 @@
+package credential
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTimeout is the time the credentials are kept by a CacheHelper,
+// as git-credential-cache does.
+const DefaultCacheTimeout = 900 * time.Second
+
+// CacheHelper is an in-process implementation of git-credential-cache,
+// keeping the credentials in memory for a limited time.
+type CacheHelper struct {
+	// Timeout is the time the credentials are kept after being stored.
+	Timeout time.Duration
+
+	mu      sync.Mutex
+	entries []*cacheEntry
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	c       *Credential
+	expires time.Time
+}
+
+// NewCacheHelper returns an empty CacheHelper with the given timeout.
+func NewCacheHelper(timeout time.Duration) *CacheHelper {
+	return &CacheHelper{Timeout: timeout, now: time.Now}
+}
+
+// Get implements Helper.
+func (h *CacheHelper) Get(_ context.Context, c *Credential) (*Credential, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire()
+	for _, e := range h.entries {
+		if c.matches(e.c) {
+			got := *c
+			got.Username, got.Password = e.c.Username, e.c.Password
+			return &got, nil
+		}
+	}
+
+	return c, nil
+}
+
+// Store implements Helper.
+func (h *CacheHelper) Store(_ context.Context, c *Credential) error {
+	if !c.Complete() {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(&Credential{Protocol: c.Protocol, Host: c.Host, Path: c.Path, Username: c.Username})
+
+	stored := *c
+	stored.Quit = false
+	h.entries = append(h.entries, &cacheEntry{c: &stored, expires: h.now().Add(h.Timeout)})
+	return nil
+}
+
+// Erase implements Helper.
+func (h *CacheHelper) Erase(_ context.Context, c *Credential) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(c)
+	return nil
+}
+
+func (h *CacheHelper) remove(c *Credential) {
+	kept := h.entries[:0]
+	for _, e := range h.entries {
+		if !c.matches(e.c) || (c.Password != "" && c.Password != e.c.Password) {
+			kept = append(kept, e)
+		}
+	}
+
+	h.entries = kept
+}
+
+func (h *CacheHelper) expire() {
+	now := h.now()
+	kept := h.entries[:0]
+	for _, e := range h.entries {
+		if now.Before(e.expires) {
+			kept = append(kept, e)
+		}
+	}
+
+	h.entries = kept
+}
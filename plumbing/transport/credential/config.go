@@ -0,0 +1,247 @@
+package credential
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v6/config"
+	format "github.com/go-git/go-git/v6/plumbing/format/config"
+)
+
+const (
+	section        = "credential"
+	helperKey      = "helper"
+	usernameKey    = "username"
+	useHTTPPathKey = "useHttpPath"
+)
+
+// Config is the credential configuration of a remote, read from the
+// credential section and the credential.<url> sections matching it.
+type Config struct {
+	// Helpers are the credential.helper values, in order.
+	Helpers []string
+	// Username is the default username, from credential.username.
+	Username string
+	// UseHTTPPath is set when the path of the URL identifies the
+	// credentials, from credential.useHttpPath.
+	UseHTTPPath bool
+}
+
+// NewConfig returns the credential configuration of the remote u from cfgs,
+// in increasing order of precedence. The credential section is read before the
+// credential.<url> sections of a file, and an empty credential.helper value
+// clears the helpers read before.
+func NewConfig(u *url.URL, cfgs ...*format.Config) *Config {
+	c := &Config{}
+	for _, cfg := range cfgs {
+		if cfg == nil || !cfg.HasSection(section) {
+			continue
+		}
+
+		s := cfg.Section(section)
+		c.apply(s.Options)
+		for _, ss := range s.Subsections {
+			if matchURL(ss.Name, u) {
+				c.apply(ss.Options)
+			}
+		}
+	}
+
+	return c
+}
+
+func (c *Config) apply(opts format.Options) {
+	for _, o := range opts {
+		switch {
+		case o.IsKey(helperKey) && o.Value == "":
+			c.Helpers = nil
+		case o.IsKey(helperKey):
+			c.Helpers = append(c.Helpers, o.Value)
+		case o.IsKey(usernameKey):
+			c.Username = o.Value
+		case o.IsKey(useHTTPPathKey):
+			c.UseHTTPPath, _ = config.ParseBool(o.Value, o.Value == "")
+		}
+	}
+}
+
+// Helper returns the helpers configured, nil when there is none. The helpers
+// registered with Register are run in process, the other ones as external
+// commands, see NewExecHelper.
+func (c *Config) Helper() (Helper, error) {
+	if len(c.Helpers) == 0 {
+		return nil, nil
+	}
+
+	var hs Helpers
+	for _, v := range c.Helpers {
+		h, err := newHelper(v)
+		if err != nil {
+			return nil, err
+		}
+
+		hs = append(hs, h)
+	}
+
+	return hs, nil
+}
+
+// Credential returns the credential to ask the helpers for, for the remote u.
+func (c *Config) Credential(u *url.URL) *Credential {
+	cred := FromURL(u, c.UseHTTPPath)
+	if cred.Username == "" {
+		cred.Username = c.Username
+	}
+
+	return cred
+}
+
+// matchURL reports whether the credential.<url> pattern applies to u: the
+// protocol and the host must be equal, the host of the pattern may start
+// with a `*.` wildcard, and its path and username must match when set.
+func matchURL(pattern string, u *url.URL) bool {
+	p, err := url.Parse(pattern)
+	if err != nil || p.Scheme == "" {
+		return false
+	}
+
+	if !strings.EqualFold(p.Scheme, u.Scheme) {
+		return false
+	}
+
+	if p.User != nil && (u.User == nil || p.User.Username() != u.User.Username()) {
+		return false
+	}
+
+	if !matchHost(p.Hostname(), u.Hostname()) || p.Port() != u.Port() {
+		return false
+	}
+
+	path := strings.Trim(p.Path, "/")
+	if path == "" {
+		return true
+	}
+
+	target := strings.Trim(u.Path, "/")
+	return target == path || strings.HasPrefix(target, path+"/")
+}
+
+func matchHost(pattern, host string) bool {
+	if rest, ok := strings.CutPrefix(pattern, "*."); ok {
+		_, domain, ok := strings.Cut(host, ".")
+		return ok && strings.EqualFold(rest, domain)
+	}
+
+	return strings.EqualFold(pattern, host)
+}
+
+// HelperFunc returns an in-process helper given the arguments of its
+// credential.helper value.
+type HelperFunc func(args []string) (Helper, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]HelperFunc{
+		"store": newStoreFromArgs,
+		"cache": newCacheFromArgs,
+	}
+)
+
+// Register registers an in-process helper, used instead of the
+// git-credential-<name> command when credential.helper is name. The store
+// and cache helpers are registered by default.
+func Register(name string, f HelperFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[name] = f
+}
+
+// Unregister unregisters the in-process helper name, running the
+// git-credential-<name> command instead.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, name)
+}
+
+func newHelper(v string) (Helper, error) {
+	args := strings.Fields(v)
+	if len(args) > 0 {
+		registryMu.RLock()
+		f, ok := registry[args[0]]
+		registryMu.RUnlock()
+		if ok {
+			return f(args[1:])
+		}
+	}
+
+	return NewExecHelper(v), nil
+}
+
+func newStoreFromArgs(args []string) (Helper, error) {
+	var file string
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--file" && i+1 < len(args):
+			i++
+			file = args[i]
+		case strings.HasPrefix(args[i], "--file="):
+			file = strings.TrimPrefix(args[i], "--file=")
+		default:
+			return nil, fmt.Errorf("credential: store: unknown argument %q", args[i])
+		}
+	}
+
+	return NewStoreHelper(file)
+}
+
+var (
+	cachesMu sync.Mutex
+	// caches are the cache helpers of the process, by socket, shared by the
+	// remotes as the git-credential-cache daemon is.
+	caches = map[string]*CacheHelper{}
+)
+
+func newCacheFromArgs(args []string) (Helper, error) {
+	timeout := DefaultCacheTimeout
+	var socket string
+	for i := 0; i < len(args); i++ {
+		arg, value, hasValue := strings.Cut(args[i], "=")
+		if !hasValue && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+
+		switch arg {
+		case "--timeout":
+			secs, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("credential: cache: invalid timeout %q", value)
+			}
+
+			timeout = time.Duration(secs) * time.Second
+		case "--socket":
+			socket = value
+		default:
+			return nil, fmt.Errorf("credential: cache: unknown argument %q", args[i])
+		}
+	}
+
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+
+	h, ok := caches[socket]
+	if !ok {
+		h = NewCacheHelper(timeout)
+		caches[socket] = h
+	}
+
+	h.mu.Lock()
+	h.Timeout = timeout
+	h.mu.Unlock()
+	return h, nil
+}
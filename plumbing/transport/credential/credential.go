@@ -0,0 +1,225 @@
+// Package credential implements the git credential helper protocol, used to
+// get the credentials of a remote from helpers such as the OS keychain, see
+// https://git-scm.com/docs/gitcredentials.
+package credential
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+var (
+	// ErrNotFound is returned by Fill when no helper has the credentials.
+	ErrNotFound = errors.New("credential: credentials not found")
+	// ErrInvalidValue is returned when encoding a credential with a value
+	// containing a newline or a NUL byte.
+	ErrInvalidValue = errors.New("credential: invalid value")
+)
+
+// Credential is a set of credentials along with the remote they apply to, as
+// exchanged with the helpers.
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+	// Quit is set by a helper to stop the lookup.
+	Quit bool
+}
+
+// FromURL returns the credential describing u, with the username and the
+// password of u if any. The path is only kept when useHTTPPath is set.
+func FromURL(u *url.URL, useHTTPPath bool) *Credential {
+	c := &Credential{
+		Protocol: u.Scheme,
+		Host:     u.Host,
+	}
+
+	if useHTTPPath {
+		c.Path = strings.TrimPrefix(u.Path, "/")
+	}
+
+	if u.User != nil {
+		c.Username = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+
+	return c
+}
+
+// URL returns the URL of the remote of the credential, with its username.
+func (c *Credential) URL() *url.URL {
+	u := &url.URL{Scheme: c.Protocol, Host: c.Host, Path: "/" + c.Path}
+	if c.Username != "" {
+		u.User = url.User(c.Username)
+	}
+
+	return u
+}
+
+// Complete reports whether both the username and the password are known.
+func (c *Credential) Complete() bool {
+	return c.Username != "" && c.Password != ""
+}
+
+// Encode writes the credential in the format of the helper protocol, a line
+// per attribute.
+func (c *Credential) Encode(w io.Writer) error {
+	for _, attr := range []struct{ key, value string }{
+		{"protocol", c.Protocol},
+		{"host", c.Host},
+		{"path", c.Path},
+		{"username", c.Username},
+		{"password", c.Password},
+	} {
+		if attr.value == "" {
+			continue
+		}
+
+		if strings.ContainsAny(attr.value, "\n\x00") {
+			return fmt.Errorf("%w: %s", ErrInvalidValue, attr.key)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", attr.key, attr.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Decode reads a credential in the format of the helper protocol, until a
+// blank line or the end of r. The attributes read replace the ones of c,
+// unknown ones are ignored.
+func (c *Credential) Decode(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("credential: invalid line %q", line)
+		}
+
+		switch key {
+		case "protocol":
+			c.Protocol = value
+		case "host":
+			c.Host = value
+		case "path":
+			c.Path = value
+		case "username":
+			c.Username = value
+		case "password":
+			c.Password = value
+		case "url":
+			u, err := url.Parse(value)
+			if err != nil {
+				return fmt.Errorf("credential: invalid url %q: %w", value, err)
+			}
+
+			*c = *FromURL(u, u.Path != "" && u.Path != "/")
+		case "quit":
+			c.Quit = value == "1" || strings.EqualFold(value, "true")
+		}
+	}
+
+	return s.Err()
+}
+
+// matches reports whether the credential stored applies to the query c: the
+// attributes set in c must be equal in stored.
+func (c *Credential) matches(stored *Credential) bool {
+	return (c.Protocol == "" || c.Protocol == stored.Protocol) &&
+		(c.Host == "" || c.Host == stored.Host) &&
+		(c.Path == "" || c.Path == stored.Path) &&
+		(c.Username == "" || c.Username == stored.Username)
+}
+
+// Helper is a credential helper, storing the credentials of remotes.
+type Helper interface {
+	// Get returns the credentials matching c, completing the attributes of
+	// c it knows. The returned credential is c when it knows none.
+	Get(ctx context.Context, c *Credential) (*Credential, error)
+	// Store stores the credentials c, which were accepted by the remote.
+	Store(ctx context.Context, c *Credential) error
+	// Erase removes the credentials matching c, which were rejected by the
+	// remote.
+	Erase(ctx context.Context, c *Credential) error
+}
+
+// Helpers is a list of helpers, asked in order.
+type Helpers []Helper
+
+// Get asks the helpers in order for the credentials matching c, until they
+// are complete or a helper sets Quit. Like git, the helpers failing are
+// skipped.
+func (hs Helpers) Get(ctx context.Context, c *Credential) (*Credential, error) {
+	for _, h := range hs {
+		if c.Complete() {
+			break
+		}
+
+		got, err := h.Get(ctx, c)
+		if err != nil {
+			trace.General.Printf("warning: %s", err)
+			continue
+		}
+
+		c = got
+		if c.Quit {
+			break
+		}
+	}
+
+	return c, nil
+}
+
+// Store stores c in every helper.
+func (hs Helpers) Store(ctx context.Context, c *Credential) error {
+	for _, h := range hs {
+		if err := h.Store(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Erase erases c from every helper.
+func (hs Helpers) Erase(ctx context.Context, c *Credential) error {
+	for _, h := range hs {
+		if err := h.Erase(ctx, c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Fill asks h for the credentials matching c, like git credential fill,
+// returning ErrNotFound when they are not complete.
+func Fill(ctx context.Context, h Helper, c *Credential) (*Credential, error) {
+	query := *c
+	got, err := h.Get(ctx, &query)
+	if err != nil {
+		return nil, err
+	}
+
+	if !got.Complete() {
+		return nil, fmt.Errorf("%w for %s", ErrNotFound, c.URL().Redacted())
+	}
+
+	return got, nil
+}
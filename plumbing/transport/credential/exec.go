@@ -0,0 +1,95 @@
+package credential
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ExecHelper is a helper run as an external command, as configured in
+// credential.helper.
+type ExecHelper struct {
+	// Command is the command of the helper, a shell snippet, run with the
+	// action as argument.
+	Command string
+	// Env is the environment of the command, the one of the current process
+	// when nil.
+	Env []string
+}
+
+// NewExecHelper returns the helper configured by the credential.helper value
+// v: a shell snippet when starting with `!`, the path of a command when
+// absolute, and the name of a git-credential-<name> command otherwise. The
+// value may contain arguments.
+//
+// The git-credential-<name> command is run directly when found in the PATH.
+// Otherwise it is run as `git credential-<name>`, which requires a git binary
+// to look it up in the exec path of git, where the helpers shipped with git
+// are installed.
+func NewExecHelper(v string) *ExecHelper {
+	switch {
+	case strings.HasPrefix(v, "!"):
+		v = v[1:]
+	case filepath.IsAbs(v):
+	default:
+		name, _, _ := strings.Cut(v, " ")
+		if _, err := exec.LookPath("git-credential-" + name); err == nil {
+			v = "git-credential-" + v
+		} else {
+			v = "git credential-" + v
+		}
+	}
+
+	return &ExecHelper{Command: v}
+}
+
+// Get implements Helper.
+func (h *ExecHelper) Get(ctx context.Context, c *Credential) (*Credential, error) {
+	out, err := h.run(ctx, "get", c)
+	if err != nil {
+		return nil, err
+	}
+
+	got := *c
+	if err := got.Decode(bytes.NewReader(out)); err != nil {
+		return nil, err
+	}
+
+	return &got, nil
+}
+
+// Store implements Helper.
+func (h *ExecHelper) Store(ctx context.Context, c *Credential) error {
+	_, err := h.run(ctx, "store", c)
+	return err
+}
+
+// Erase implements Helper.
+func (h *ExecHelper) Erase(ctx context.Context, c *Credential) error {
+	_, err := h.run(ctx, "erase", c)
+	return err
+}
+
+func (h *ExecHelper) run(ctx context.Context, action string, c *Credential) ([]byte, error) {
+	var in bytes.Buffer
+	if err := c.Encode(&in); err != nil {
+		return nil, err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", h.Command+` "$@"`, h.Command, action)
+	cmd.Env = h.Env
+	cmd.Stdin = &in
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("credential helper %q %s: %w: %s", h.Command, action, err,
+			strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,164 @@
+package credential
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StoreHelper is an in-process implementation of git-credential-store,
+// storing the credentials in plain text files, a URL per line.
+type StoreHelper struct {
+	// Files are the files read, in order. The credentials are stored in the
+	// first one.
+	Files []string
+}
+
+// NewStoreHelper returns a StoreHelper using file, or the default files of
+// git-credential-store when empty: ~/.git-credentials and
+// $XDG_CONFIG_HOME/git/credentials.
+func NewStoreHelper(file string) (*StoreHelper, error) {
+	if file != "" {
+		return &StoreHelper{Files: []string{file}}, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	return &StoreHelper{Files: []string{
+		filepath.Join(home, ".git-credentials"),
+		filepath.Join(xdg, "git", "credentials"),
+	}}, nil
+}
+
+// Get implements Helper.
+func (h *StoreHelper) Get(_ context.Context, c *Credential) (*Credential, error) {
+	for _, file := range h.Files {
+		stored, err := readStore(file)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range stored {
+			if c.matches(s) {
+				got := *c
+				got.Username, got.Password = s.Username, s.Password
+				return &got, nil
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// Store implements Helper, replacing the credentials of the same remote and
+// username in the first file.
+func (h *StoreHelper) Store(_ context.Context, c *Credential) error {
+	if !c.Complete() || len(h.Files) == 0 {
+		return nil
+	}
+
+	file := h.Files[0]
+	stored, err := readStore(file)
+	if err != nil {
+		return err
+	}
+
+	query := &Credential{Protocol: c.Protocol, Host: c.Host, Path: c.Path, Username: c.Username}
+	kept := []*Credential{c}
+	for _, s := range stored {
+		if !query.matches(s) || s.Path != c.Path {
+			kept = append(kept, s)
+		}
+	}
+
+	return writeStore(file, kept)
+}
+
+// Erase implements Helper, removing the matching credentials from every file.
+func (h *StoreHelper) Erase(_ context.Context, c *Credential) error {
+	for _, file := range h.Files {
+		stored, err := readStore(file)
+		if err != nil {
+			return err
+		}
+
+		var kept []*Credential
+		for _, s := range stored {
+			if !c.matches(s) || (c.Password != "" && c.Password != s.Password) {
+				kept = append(kept, s)
+			}
+		}
+
+		if len(kept) == len(stored) {
+			continue
+		}
+
+		if err := writeStore(file, kept); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func readStore(file string) ([]*Credential, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var stored []*Credential
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		// Invalid lines are ignored, as git does.
+		u, err := url.Parse(line)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.User == nil {
+			continue
+		}
+
+		stored = append(stored, FromURL(u, true))
+	}
+
+	return stored, s.Err()
+}
+
+func writeStore(file string, stored []*Credential) error {
+	var buf bytes.Buffer
+	for _, c := range stored {
+		u := c.URL()
+		u.User = url.UserPassword(c.Username, c.Password)
+		if c.Path == "" {
+			u.Path = ""
+		}
+
+		buf.WriteString(u.String())
+		buf.WriteByte('\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, buf.Bytes(), 0o600)
+}
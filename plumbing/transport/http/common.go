@@ -218,6 +218,12 @@ func (s *HTTPSession) IsSmart() bool {
 	return s.isSmart && !s.useDumb
 }
 
+// Client returns the http client of the session, configured with the TLS
+// and proxy options of its endpoint.
+func (s *HTTPSession) Client() *http.Client {
+	return s.client
+}
+
 var _ transport.Session = (*HTTPSession)(nil)
 
 func transportWithInsecureTLS(transport *http.Transport) {
@@ -347,17 +353,19 @@ func (s *HTTPSession) Handshake(ctx context.Context, service transport.Service,
 	}
 
 	s.svc = service
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, err
-	}
-
 	if len(params) > 0 {
 		s.gitProtocol = strings.Join(params, ":")
 	}
 
-	applyHeaders(req, service.String(), s.ep, s.auth, s.gitProtocol, !s.useDumb)
-	res, err := doRequest(s.client, req)
+	res, err := s.doAuthRequest(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		applyHeaders(req, service.String(), s.ep, s.auth, s.gitProtocol, !s.useDumb)
+		return req, nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -556,13 +564,17 @@ func (r *requester) Close() (err error) {
 	defer r.reqBuf.Reset()
 
 	url := fmt.Sprintf("%s/%s", r.ep.String(), r.service)
-	r.req, err = http.NewRequestWithContext(r.ctx, http.MethodPost, url, &r.reqBuf)
-	if err != nil {
-		return err
-	}
+	r.res, err = r.doAuthRequest(r.ctx, func() (*http.Request, error) {
+		// The body is read again when the request is retried.
+		req, err := http.NewRequestWithContext(r.ctx, http.MethodPost, url, bytes.NewReader(r.reqBuf.Bytes()))
+		if err != nil {
+			return nil, err
+		}
 
-	applyHeaders(r.req, r.service, r.ep, r.auth, r.gitProtocol, r.IsSmart())
-	r.res, err = doRequest(r.client, r.req)
+		applyHeaders(req, r.service, r.ep, r.auth, r.gitProtocol, r.IsSmart())
+		r.req = req
+		return req, nil
+	})
 	if err != nil {
 		return err
 	}
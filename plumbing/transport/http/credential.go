@@ -0,0 +1,157 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+)
+
+// CredentialHelperAuth implements an http.AuthMethod getting the credentials
+// from a credential helper when the server requires authentication, as git
+// does. The credentials are stored by the helper once accepted by the
+// server, and erased when rejected.
+type CredentialHelperAuth struct {
+	Helper credential.Helper
+	// Config is the credential configuration of the remote, giving the
+	// default username and whether the path identifies the credentials.
+	Config *credential.Config
+
+	mu       sync.Mutex
+	cred     *credential.Credential
+	approved bool
+}
+
+// NewCredentialHelperAuth returns a CredentialHelperAuth using the helpers of
+// cfg, or nil when it has none.
+func NewCredentialHelperAuth(cfg *credential.Config) (*CredentialHelperAuth, error) {
+	h, err := cfg.Helper()
+	if err != nil || h == nil {
+		return nil, err
+	}
+
+	return &CredentialHelperAuth{Helper: h, Config: cfg}, nil
+}
+
+// SetAuth sets the credentials got from the helper, if any.
+func (a *CredentialHelperAuth) SetAuth(r *http.Request) {
+	if a == nil {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cred != nil {
+		r.SetBasicAuth(a.cred.Username, a.cred.Password)
+	}
+}
+
+// Name is name of the auth
+func (a *CredentialHelperAuth) Name() string {
+	return "http-credential-helper"
+}
+
+func (a *CredentialHelperAuth) String() string {
+	return a.Name()
+}
+
+// fill asks the helper for the credentials of the remote u.
+func (a *CredentialHelperAuth) fill(ctx context.Context, u *url.URL) error {
+	cfg := a.Config
+	if cfg == nil {
+		cfg = &credential.Config{}
+	}
+
+	c, err := credential.Fill(ctx, a.Helper, cfg.Credential(u))
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.cred, a.approved = c, false
+	return nil
+}
+
+// report reports the outcome of a request sent with the credentials of the
+// helper: they are stored once accepted, and erased when rejected.
+func (a *CredentialHelperAuth) report(ctx context.Context, err error) error {
+	a.mu.Lock()
+	c, approved := a.cred, a.approved
+	switch {
+	case c == nil:
+	case err == nil:
+		a.approved = true
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		a.cred = nil
+	}
+	a.mu.Unlock()
+
+	switch {
+	case c == nil:
+		return nil
+	case err == nil && !approved:
+		return a.Helper.Store(ctx, c)
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		return a.Helper.Erase(ctx, c)
+	}
+
+	return nil
+}
+
+// doAuthRequest performs the request built by newRequest, asking the
+// credential helper of the session, if any, for credentials and retrying
+// when the server requires authentication.
+func (s *HTTPSession) doAuthRequest(ctx context.Context, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := doRequest(s.client, req)
+	a, ok := s.auth.(*CredentialHelperAuth)
+	if !ok {
+		return res, err
+	}
+
+	if errors.Is(err, transport.ErrAuthenticationRequired) {
+		// Credentials rejected by the server are erased before asking
+		// again, as git does.
+		if rerr := a.report(ctx, err); rerr != nil {
+			return res, rerr
+		}
+
+		u, uerr := url.Parse(s.ep.String())
+		if uerr != nil {
+			return res, uerr
+		}
+
+		if ferr := a.fill(ctx, u); ferr != nil {
+			return res, fmt.Errorf("%w: %w", err, ferr)
+		}
+
+		closeBody(res)
+		if req, err = newRequest(); err != nil {
+			return nil, err
+		}
+
+		res, err = doRequest(s.client, req)
+	}
+
+	if rerr := a.report(ctx, err); rerr != nil && err == nil {
+		return res, rerr
+	}
+
+	return res, err
+}
+
+func closeBody(res *http.Response) {
+	if res != nil && res.Body != nil {
+		_ = res.Body.Close()
+	}
+}
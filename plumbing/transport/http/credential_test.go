@@ -0,0 +1,120 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fixtures "github.com/go-git/go-git-fixtures/v5"
+	"github.com/go-git/go-git/v6/internal/transport/test"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/plumbing/transport/credential"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingHelper struct {
+	password string
+	stored   []*credential.Credential
+	erased   []*credential.Credential
+}
+
+func (h *recordingHelper) Get(_ context.Context, c *credential.Credential) (*credential.Credential, error) {
+	got := *c
+	got.Username, got.Password = "john", h.password
+	return &got, nil
+}
+
+func (h *recordingHelper) Store(_ context.Context, c *credential.Credential) error {
+	h.stored = append(h.stored, c)
+	return nil
+}
+
+func (h *recordingHelper) Erase(_ context.Context, c *credential.Credential) error {
+	h.erased = append(h.erased, c)
+	return nil
+}
+
+func setupAuthServer(t *testing.T) *transport.Endpoint {
+	base := t.TempDir()
+	test.PrepareRepository(t, fixtures.Basic().One(), base, "basic.git")
+
+	out, err := exec.Command("git", "--exec-path").CombinedOutput()
+	require.NoError(t, err)
+
+	backend := &cgi.Handler{
+		Path: filepath.Join(strings.TrimSpace(string(out)), "git-http-backend"),
+		Env:  []string{"GIT_HTTP_EXPORT_ALL=true", fmt.Sprintf("GIT_PROJECT_ROOT=%s", base)},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "john" || password != "secret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="git"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		backend.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	ep, err := transport.NewEndpoint(server.URL + "/basic.git")
+	require.NoError(t, err)
+	return ep
+}
+
+func TestCredentialHelperAuth(t *testing.T) {
+	ep := setupAuthServer(t)
+	helper := &recordingHelper{password: "secret"}
+	auth := &CredentialHelperAuth{Helper: helper}
+
+	sess, err := DefaultTransport.NewSession(memory.NewStorage(), ep, auth)
+	require.NoError(t, err)
+
+	conn, err := sess.Handshake(context.Background(), transport.UploadPackService)
+	require.NoError(t, err)
+
+	refs, err := conn.GetRemoteRefs(context.Background())
+	require.NoError(t, err)
+	require.NotEmpty(t, refs)
+
+	require.Len(t, helper.stored, 1)
+	require.Equal(t, &credential.Credential{
+		Protocol: "http",
+		Host:     ep.Host + fmt.Sprintf(":%d", ep.Port),
+		Username: "john",
+		Password: "secret",
+	}, helper.stored[0])
+	require.Empty(t, helper.erased)
+}
+
+func TestCredentialHelperAuthRejected(t *testing.T) {
+	ep := setupAuthServer(t)
+	helper := &recordingHelper{password: "wrong"}
+	auth := &CredentialHelperAuth{Helper: helper}
+
+	sess, err := DefaultTransport.NewSession(memory.NewStorage(), ep, auth)
+	require.NoError(t, err)
+
+	_, err = sess.Handshake(context.Background(), transport.UploadPackService)
+	require.ErrorIs(t, err, transport.ErrAuthenticationRequired)
+	require.Empty(t, helper.stored)
+	require.Len(t, helper.erased, 1)
+	require.Equal(t, "wrong", helper.erased[0].Password)
+}
+
+func TestNewCredentialHelperAuth(t *testing.T) {
+	auth, err := NewCredentialHelperAuth(&credential.Config{})
+	require.NoError(t, err)
+	require.Nil(t, auth)
+
+	auth, err = NewCredentialHelperAuth(&credential.Config{Helpers: []string{"osxkeychain"}})
+	require.NoError(t, err)
+	require.Equal(t, "http-credential-helper", auth.Name())
+}
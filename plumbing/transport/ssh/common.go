@@ -52,29 +52,46 @@ type runner struct {
 }
 
 func (r *runner) Command(ctx context.Context, cmd string, ep *transport.Endpoint, auth transport.AuthMethod, params ...string) (transport.Command, error) {
-	c := &command{command: cmd, endpoint: ep, config: r.config}
+	c, err := r.command(ctx, cmd, ep, auth, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if gitProtocol := strings.Join(params, ":"); gitProtocol != "" {
+		c.Session.Setenv("GIT_PROTOCOL", gitProtocol)
+	}
+
+	return c, nil
+}
+
+func (r *runner) command(ctx context.Context, cmd string, ep *transport.Endpoint, auth transport.AuthMethod, args []string) (*command, error) {
+	c := &command{command: cmd, args: args, endpoint: ep, config: r.config}
 	if auth != nil {
 		if err := c.setAuth(auth); err != nil {
 			return nil, err
 		}
 	}
 
-	gitProtocol := strings.Join(params, ":")
 	if err := c.connect(ctx); err != nil {
 		return nil, err
 	}
 
-	if gitProtocol != "" {
-		c.Session.Setenv("GIT_PROTOCOL", gitProtocol)
-	}
-
 	return c, nil
 }
 
+// NewCommand returns a Command running cmd on the SSH server of the
+// endpoint, the path of the endpoint followed by args being its arguments.
+// It runs the commands other than the git services, like
+// git-lfs-authenticate.
+func NewCommand(ctx context.Context, cmd string, ep *transport.Endpoint, auth transport.AuthMethod, args ...string) (transport.Command, error) {
+	return (&runner{}).command(ctx, cmd, ep, auth, args)
+}
+
 type command struct {
 	*ssh.Session
 	connected bool
 	command   string
+	args      []string
 	endpoint  *transport.Endpoint
 	client    *ssh.Client
 	auth      AuthMethod
@@ -92,7 +109,7 @@ func (c *command) setAuth(auth transport.AuthMethod) error {
 }
 
 func (c *command) Start() error {
-	cmd := endpointToCommand(c.command, c.endpoint)
+	cmd := endpointToCommand(c.command, c.endpoint, c.args...)
 	return c.Session.Start(cmd)
 }
 
@@ -271,8 +288,13 @@ func (c *command) setAuthFromEndpoint() error {
 	return err
 }
 
-func endpointToCommand(cmd string, ep *transport.Endpoint) string {
-	return fmt.Sprintf("%s '%s'", cmd, ep.Path)
+func endpointToCommand(cmd string, ep *transport.Endpoint, args ...string) string {
+	cmd = fmt.Sprintf("%s '%s'", cmd, ep.Path)
+	for _, arg := range args {
+		cmd += fmt.Sprintf(" '%s'", arg)
+	}
+
+	return cmd
 }
 
 func overrideConfig(overrides *ssh.ClientConfig, c *ssh.ClientConfig) {
@@ -2,6 +2,11 @@ package ssh
 
 import (
 	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/go-git/go-git/v6/plumbing/transport"
@@ -173,6 +178,38 @@ func TestIssue70Suite(t *testing.T) {
 	require.NoError(t, cmd.Close())
 }
 
+func TestNewCommand(t *testing.T) {
+	// The host key algorithms are looked up in the known hosts, even when
+	// the host key is not checked.
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	require.NoError(t, os.WriteFile(knownHosts, nil, 0o600))
+	t.Setenv("SSH_KNOWN_HOSTS", knownHosts)
+
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	server := &ssh.Server{Handler: func(s ssh.Session) {
+		io.WriteString(s, strings.Join(s.Command(), "|"))
+	}}
+	go server.Serve(l)
+	defer server.Close()
+
+	auth := &Password{User: "git"}
+	auth.HostKeyCallback = stdssh.InsecureIgnoreHostKey()
+	ep := newEndpoint(t, "base", l.Addr().(*net.TCPAddr).Port, "foo.git")
+	cmd, err := NewCommand(context.TODO(), "git-lfs-authenticate", ep, auth, "download")
+	require.NoError(t, err)
+
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+
+	out, err := io.ReadAll(stdout)
+	require.NoError(t, err)
+	require.Equal(t, "git-lfs-authenticate|/base/foo.git|download", string(out))
+	require.NoError(t, cmd.Close())
+}
+
 func (s *SuiteCommon) TestInvalidSocks5Proxy() {
 	st := memory.NewStorage()
 	ep, err := transport.NewEndpoint("git@github.com:foo/bar.git")
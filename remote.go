@@ -107,7 +107,12 @@ func (r *Remote) PushContext(ctx context.Context, o *PushOptions) (err error) {
 		return err
 	}
 
-	s, err := c.NewSession(r.s, ep, o.Auth)
+	auth, err := r.authMethod(ep, o.Auth, o.UseCredentialHelpers)
+	if err != nil {
+		return err
+	}
+
+	s, err := c.NewSession(r.s, ep, auth)
 	if err != nil {
 		return err
 	}
@@ -223,6 +228,10 @@ func (r *Remote) sendPack(ctx context.Context, conn transport.Connection, remote
 		}
 	}
 
+	if err := r.pushLFS(ctx, hashesToPush, o); err != nil {
+		return err
+	}
+
 	if err := pushHashes(ctx, conn, r.s, cmds, hashesToPush, allDelete, o); err != nil {
 		return err
 	}
@@ -374,7 +383,12 @@ func (r *Remote) fetch(ctx context.Context, o *FetchOptions) (sto storer.Referen
 		return nil, err
 	}
 
-	sess, err := c.NewSession(r.s, ep, o.Auth)
+	auth, err := r.authMethod(ep, o.Auth, o.UseCredentialHelpers)
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := c.NewSession(r.s, ep, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -1246,7 +1260,12 @@ func (r *Remote) list(ctx context.Context, o *ListOptions) (rfs []*plumbing.Refe
 		return nil, err
 	}
 
-	s, err := c.NewSession(r.s, ep, o.Auth)
+	auth, err := r.authMethod(ep, o.Auth, o.UseCredentialHelpers)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := c.NewSession(r.s, ep, auth)
 	if err != nil {
 		return nil, err
 	}
@@ -31,6 +31,7 @@ import (
 	"github.com/go-git/go-git/v6/storage"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
+	"github.com/go-git/go-git/v6/utils/diff"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/trace"
 )
@@ -608,14 +609,19 @@ func (r *Repository) SetConfig(cfg *config.Config) error {
 
 // ConfigScoped returns the repository config, merged with requested scope and
 // lower. For example if, config.GlobalScope is given the local and global config
-// are returned merged in one config value.
+// are returned merged in one config value. The worktree config and the config
+// set through the environment override it, see ConfigView.
 func (r *Repository) ConfigScoped(scope config.Scope) (*config.Config, error) {
 	// TODO(mcuadros): v6, add this as ConfigOptions.Scoped
 
-	var err error
+	o, err := r.includeOptions(scope)
+	if err != nil {
+		return nil, err
+	}
+
 	system := config.NewConfig()
 	if scope >= config.SystemScope {
-		system, err = config.LoadConfig(config.SystemScope)
+		system, err = config.LoadConfigWithIncludes(config.SystemScope, o)
 		if err != nil {
 			return nil, err
 		}
@@ -623,13 +629,18 @@ func (r *Repository) ConfigScoped(scope config.Scope) (*config.Config, error) {
 
 	global := config.NewConfig()
 	if scope >= config.GlobalScope {
-		global, err = config.LoadConfig(config.GlobalScope)
+		global, err = config.LoadConfigWithIncludes(config.GlobalScope, o)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	local, err := r.Storer.Config()
+	local, err := r.localConfig(o)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err = r.overlayConfig(local, o)
 	if err != nil {
 		return nil, err
 	}
@@ -639,6 +650,102 @@ func (r *Repository) ConfigScoped(scope config.Scope) (*config.Config, error) {
 	return local, nil
 }
 
+// lineDiffOptions returns the options of the line diffs set by the
+// diff.algorithm and diff.indentHeuristic config options.
+func (r *Repository) lineDiffOptions() (*diff.Options, error) {
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &diff.Options{
+		Algorithm:       diff.Myers,
+		IndentHeuristic: !cfg.Diff.NoIndentHeuristic,
+	}
+
+	if cfg.Diff.Algorithm != "" {
+		o.Algorithm, err = diff.ParseAlgorithm(cfg.Diff.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return o, nil
+}
+
+// includeOptions returns the options used to expand the includes of the
+// config files of the given scope, against which the includeIf conditions
+// are evaluated.
+func (r *Repository) includeOptions(scope config.Scope) (*formatcfg.IncludeOptions, error) {
+	o := &formatcfg.IncludeOptions{}
+	if fs, ok := r.Storer.(interface{ Filesystem() billy.Filesystem }); ok {
+		if root := fs.Filesystem().Root(); root != "" {
+			o.GitDir = root
+		}
+	}
+
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err == nil && head.Type() == plumbing.SymbolicReference && head.Target().IsBranch() {
+		o.Branch = head.Target().Short()
+	}
+
+	// The hasconfig conditions are evaluated against the remotes of every
+	// scope, read without includes.
+	local, err := r.Storer.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	cfgs := []*config.Config{local}
+	for s := config.GlobalScope; s <= scope && s <= config.SystemScope; s++ {
+		cfg, err := config.LoadConfigWithIncludes(s, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		cfgs = append(cfgs, cfg)
+	}
+
+	for _, cfg := range cfgs {
+		for _, rc := range cfg.Remotes {
+			o.RemoteURLs = append(o.RemoteURLs, rc.URLs...)
+		}
+	}
+
+	return o, nil
+}
+
+// localConfig returns the config of the repository, expanding the includes
+// of the config file when it is stored in the OS filesystem.
+func (r *Repository) localConfig(o *formatcfg.IncludeOptions) (*config.Config, error) {
+	fs, ok := r.Storer.(interface{ Filesystem() billy.Filesystem })
+	if !ok || o.GitDir == "" {
+		return r.Storer.Config()
+	}
+
+	path := filepath.Join(o.GitDir, "config")
+	f, err := os.Open(path)
+	if err != nil {
+		return r.Storer.Config()
+	}
+
+	defer f.Close()
+
+	// Only read the file directly if it is the one of the storage.
+	ofi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if fi, err := fs.Filesystem().Stat("config"); err != nil || !os.SameFile(ofi, fi) {
+		return r.Storer.Config()
+	}
+
+	lo := *o
+	lo.Path = path
+	return config.ReadConfigWithIncludes(f, &lo)
+}
+
 // Remote return a remote if exists
 func (r *Repository) Remote(name string) (*Remote, error) {
 	cfg, err := r.Config()
@@ -829,7 +936,14 @@ func (r *Repository) createTagObject(name string, hash plumbing.Hash, opts *Crea
 		Target:     hash,
 	}
 
-	if opts.SignKey != nil {
+	if opts.Signer != nil {
+		sig, err := signObject(opts.Signer, tag)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tag.PGPSignature = string(sig)
+	} else if opts.SignKey != nil {
 		sig, err := r.buildTagSignature(tag, opts.SignKey)
 		if err != nil {
 			return plumbing.ZeroHash, err
@@ -969,16 +1083,17 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 	}
 
 	ref, err := r.fetchAndUpdateReferences(ctx, &FetchOptions{
-		RefSpecs:        c.Fetch,
-		Depth:           o.Depth,
-		Auth:            o.Auth,
-		Progress:        o.Progress,
-		Tags:            o.Tags,
-		RemoteName:      o.RemoteName,
-		InsecureSkipTLS: o.InsecureSkipTLS,
-		CABundle:        o.CABundle,
-		ProxyOptions:    o.ProxyOptions,
-		Filter:          o.Filter,
+		RefSpecs:             c.Fetch,
+		Depth:                o.Depth,
+		Auth:                 o.Auth,
+		UseCredentialHelpers: o.UseCredentialHelpers,
+		Progress:             o.Progress,
+		Tags:                 o.Tags,
+		RemoteName:           o.RemoteName,
+		InsecureSkipTLS:      o.InsecureSkipTLS,
+		CABundle:             o.CABundle,
+		ProxyOptions:         o.ProxyOptions,
+		Filter:               o.Filter,
 	}, o.ReferenceName)
 	if err != nil {
 		return err
@@ -995,9 +1110,21 @@ func (r *Repository) clone(ctx context.Context, o *CloneOptions) error {
 			return err
 		}
 
+		if err := r.fetchLFS(ctx, &FetchLFSOptions{
+			RemoteName:      o.RemoteName,
+			Auth:            o.Auth,
+			Commit:          head.Hash(),
+			InsecureSkipTLS: o.InsecureSkipTLS,
+			CABundle:        o.CABundle,
+			ProxyOptions:    o.ProxyOptions,
+		}); err != nil {
+			return err
+		}
+
 		if err := w.Reset(&ResetOptions{
 			Mode:   MergeReset,
 			Commit: head.Hash(),
+			Auth:   o.Auth,
 		}); err != nil {
 			return err
 		}
@@ -1253,7 +1380,30 @@ func (r *Repository) FetchContext(ctx context.Context, o *FetchOptions) error {
 		return err
 	}
 
-	return remote.FetchContext(ctx, o)
+	mode, err := r.recurseSubmodules(o.RecurseSubmodules, false)
+	if err != nil {
+		return err
+	}
+
+	if mode != config.RecurseSubmodulesYes && mode != config.RecurseSubmodulesOnDemand {
+		return remote.FetchContext(ctx, o)
+	}
+
+	known, err := r.referenceHashes()
+	if err != nil {
+		return err
+	}
+
+	err = remote.FetchContext(ctx, o)
+	if err != nil && !errors.Is(err, NoErrAlreadyUpToDate) {
+		return err
+	}
+
+	if err := r.fetchSubmodules(ctx, o, mode, known); err != nil {
+		return err
+	}
+
+	return err
 }
 
 // Push performs a push to the remote. Returns NoErrAlreadyUpToDate if
@@ -1280,6 +1430,17 @@ func (r *Repository) PushContext(ctx context.Context, o *PushOptions) error {
 		return err
 	}
 
+	mode, err := r.recurseSubmodules(o.RecurseSubmodules, true)
+	if err != nil {
+		return err
+	}
+
+	if mode == config.RecurseSubmodulesCheck || mode == config.RecurseSubmodulesOnDemand {
+		if err := r.checkSubmodulesPushed(ctx, o, mode); err != nil {
+			return err
+		}
+	}
+
 	return remote.PushContext(ctx, o)
 }
 
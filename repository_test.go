@@ -2455,6 +2455,57 @@ func (s *RepositorySuite) TestConfigScoped() {
 	s.NotEqual("", cfg.User.Email)
 }
 
+func (s *RepositorySuite) TestConfigScopedIncludeIf() {
+	home := s.T().TempDir()
+	s.T().Setenv("HOME", home)
+	s.T().Setenv("XDG_CONFIG_HOME", "")
+
+	dir := filepath.Join(s.T().TempDir(), "work", "project")
+	r, err := PlainInit(dir, false)
+	s.NoError(err)
+
+	_, err = r.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"https://example.com/foo/bar"}})
+	s.NoError(err)
+
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(`[user]
+	name = personal
+	email = personal@example.com
+[includeIf "gitdir:work/"]
+	path = .gitconfig-work
+[includeIf "onbranch:release/"]
+	path = .gitconfig-release
+[includeIf "hasconfig:remote.*.url:https://example.com/**"]
+	path = .gitconfig-example
+`), 0o644))
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig-work"), []byte("[user]\n\temail = work@example.com\n"), 0o644))
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig-release"), []byte("[user]\n\tname = release\n"), 0o644))
+	s.NoError(os.WriteFile(filepath.Join(home, ".gitconfig-example"), []byte("[init]\n\tdefaultBranch = trunk\n"), 0o644))
+
+	// The local config includes a file relative to the git directory.
+	s.NoError(os.WriteFile(filepath.Join(dir, GitDirName, "local.inc"), []byte("[core]\n\tautocrlf = input\n"), 0o644))
+	cfg, err := r.Config()
+	s.NoError(err)
+	cfg.Raw.Section("include").SetOption("path", "local.inc")
+	s.NoError(r.SetConfig(cfg))
+
+	cfg, err = r.ConfigScoped(config.GlobalScope)
+	s.NoError(err)
+	s.Equal("personal", cfg.User.Name)
+	s.Equal("work@example.com", cfg.User.Email)
+	s.Equal("trunk", cfg.Init.DefaultBranch)
+	s.Equal("input", cfg.Core.AutoCRLF)
+
+	// The included values are not written back to the local config.
+	local, err := r.Config()
+	s.NoError(err)
+	s.Equal("", local.Core.AutoCRLF)
+
+	s.NoError(r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/release/1.0")))
+	cfg, err = r.ConfigScoped(config.GlobalScope)
+	s.NoError(err)
+	s.Equal("release", cfg.User.Name)
+}
+
 func (s *RepositorySuite) TestCommit() {
 	r, _ := Init(memory.NewStorage())
 	err := r.clone(context.Background(), &CloneOptions{
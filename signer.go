@@ -1,8 +1,12 @@
 package git
 
 import (
+	"crypto"
+	"crypto/x509"
 	"io"
+	"time"
 
+	"github.com/go-git/go-git/v6/internal/cms"
 	"github.com/go-git/go-git/v6/plumbing"
 )
 
@@ -31,3 +35,28 @@ func signObject(signer Signer, obj signableObject) ([]byte, error) {
 
 	return signer.Sign(r)
 }
+
+// x509Signer signs objects with X.509 certificates, producing detached CMS
+// signatures compatible with gpg.format=x509.
+type x509Signer struct {
+	key   crypto.Signer
+	chain []*x509.Certificate
+}
+
+// NewX509Signer returns a Signer that produces X.509 (CMS) signatures, as
+// git does with gpg.format=x509 through gpgsm, smimesign or gitsign. The
+// first certificate in chain must be the certificate of key, and any
+// following ones are intermediates embedded in the signature.
+func NewX509Signer(key crypto.Signer, chain ...*x509.Certificate) Signer {
+	return &x509Signer{key: key, chain: chain}
+}
+
+// Sign implements the Signer interface.
+func (s *x509Signer) Sign(message io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+
+	return cms.Sign(content, s.key, s.chain, time.Now())
+}
@@ -1,14 +1,24 @@
 package git
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"math/big"
+	"strings"
+	"testing"
 	"time"
 
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type b64signer struct{}
@@ -54,3 +64,68 @@ func ExampleSigner() {
 	fmt.Println(obj.PGPSignature)
 	// Output: dHJlZSA0YjgyNWRjNjQyY2I2ZWI5YTA2MGU1NGJmOGQ2OTI4OGZiZWU0OTA0CmF1dGhvciBKb2huIERvZSA8am9obkBleGFtcGxlLmNvbT4gMTIzNCArMDAwMApjb21taXR0ZXIgSm9obiBEb2UgPGpvaG5AZXhhbXBsZS5jb20+IDEyMzQgKzAwMDAKCmV4YW1wbGUgY29tbWl0
 }
+
+func TestX509Signer(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: "John Doe"},
+		EmailAddresses: []string{"john@example.com"},
+		NotBefore:      time.Now().Add(-time.Minute),
+		NotAfter:       time.Now().Add(time.Hour),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		IsCA:           true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	repo, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+	w, err := repo.Worktree()
+	require.NoError(t, err)
+
+	signer := NewX509Signer(key, cert)
+	author := &object.Signature{Name: "John Doe", Email: "john@example.com", When: time.Now()}
+	hash, err := w.Commit("signed commit", &CommitOptions{
+		Author:            author,
+		Signer:            signer,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+
+	commit, err := repo.CommitObject(hash)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(commit.PGPSignature, "-----BEGIN SIGNED MESSAGE-----"))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	signerCert, err := commit.VerifyX509(x509.VerifyOptions{Roots: roots})
+	require.NoError(t, err)
+	assert.Equal(t, cert.Raw, signerCert.Raw)
+
+	_, err = commit.VerifyX509(x509.VerifyOptions{Roots: x509.NewCertPool()})
+	assert.Error(t, err)
+
+	commit.Message = "tampered"
+	_, err = commit.VerifyX509(x509.VerifyOptions{Roots: roots})
+	assert.Error(t, err)
+
+	ref, err := repo.CreateTag("v1.0.0", hash, &CreateTagOptions{
+		Tagger:  author,
+		Message: "signed tag",
+		Signer:  signer,
+	})
+	require.NoError(t, err)
+
+	tag, err := repo.TagObject(ref.Hash())
+	require.NoError(t, err)
+	_, err = tag.VerifyX509(x509.VerifyOptions{Roots: roots})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,321 @@
+package git
+
+import (
+	"errors"
+	"os"
+
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/sparsecheckout"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/utils/ioutil"
+	"github.com/go-git/go-git/v6/utils/merkletrie/filesystem"
+)
+
+const sparseCheckoutPath = "info/sparse-checkout"
+
+var (
+	// ErrSparseCheckoutNotSupported is returned when the storage of the
+	// repository has no filesystem to hold the sparse-checkout file.
+	ErrSparseCheckoutNotSupported = errors.New("sparse checkout: storage not supported")
+	// ErrSparseCheckoutDisabled is returned by the operations requiring the
+	// sparse checkout to be enabled.
+	ErrSparseCheckoutDisabled = errors.New("sparse checkout is not enabled")
+)
+
+// SparseCheckoutSet enables the sparse checkout of the worktree, writing
+// its patterns to the sparse-checkout file, and updates the worktree to
+// check out only the files they include.
+func (w *Worktree) SparseCheckoutSet(o *SparseCheckoutOptions) error {
+	if err := o.Validate(); err != nil {
+		return err
+	}
+
+	patterns := o.Patterns
+	if !o.NoCone {
+		patterns = sparsecheckout.ConePatterns(patterns)
+	}
+
+	if err := w.r.setSparseCheckoutPatterns(patterns); err != nil {
+		return err
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.SparseCheckout = true
+	cfg.Core.SparseCheckoutCone = !o.NoCone
+	cfg.Index.Sparse = o.SparseIndex
+	if err := w.r.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	return w.SparseCheckoutReapply()
+}
+
+// SparseCheckoutAdd adds patterns to the sparse checkout of the worktree,
+// directories in cone mode, and updates the worktree.
+func (w *Worktree) SparseCheckoutAdd(patterns ...string) error {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	if !cfg.Core.SparseCheckout {
+		return ErrSparseCheckoutDisabled
+	}
+
+	current, err := w.r.sparseCheckoutPatterns()
+	if err != nil {
+		return err
+	}
+
+	if dirs, ok := sparsecheckout.ConeDirs(current); ok && cfg.Core.SparseCheckoutCone {
+		patterns = sparsecheckout.ConePatterns(append(dirs, patterns...))
+	} else {
+		patterns = append(current, patterns...)
+	}
+
+	if err := w.r.setSparseCheckoutPatterns(patterns); err != nil {
+		return err
+	}
+
+	return w.SparseCheckoutReapply()
+}
+
+// SparseCheckoutList returns the patterns of the sparse checkout of the
+// worktree, its directories in cone mode.
+func (w *Worktree) SparseCheckoutList() ([]string, error) {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.Core.SparseCheckout {
+		return nil, ErrSparseCheckoutDisabled
+	}
+
+	patterns, err := w.r.sparseCheckoutPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	if dirs, ok := sparsecheckout.ConeDirs(patterns); ok && cfg.Core.SparseCheckoutCone {
+		return dirs, nil
+	}
+
+	return patterns, nil
+}
+
+// SparseCheckoutReapply updates the worktree to the patterns of its sparse
+// checkout: the files they include are checked out and the other ones are
+// removed, unless they have changes, and get the skip-worktree bit.
+func (w *Worktree) SparseCheckoutReapply() error {
+	m, sparse, err := w.sparseCheckoutMatcher()
+	if err != nil {
+		return err
+	}
+
+	if m == nil {
+		return ErrSparseCheckoutDisabled
+	}
+
+	return w.updateSparseCheckout(m, sparse)
+}
+
+// SparseCheckoutDisable disables the sparse checkout of the worktree,
+// checking out all the files of the index.
+func (w *Worktree) SparseCheckoutDisable() error {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	if err := w.updateSparseCheckout(nil, false); err != nil {
+		return err
+	}
+
+	cfg.Core.SparseCheckout = false
+	cfg.Index.Sparse = false
+	return w.r.SetConfig(cfg)
+}
+
+// sparseCheckoutMatcher returns the Matcher of the sparse checkout of the
+// worktree, nil when disabled, and whether the index is a sparse index.
+func (w *Worktree) sparseCheckoutMatcher() (sparsecheckout.Matcher, bool, error) {
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !cfg.Core.SparseCheckout {
+		return nil, false, nil
+	}
+
+	patterns, err := w.r.sparseCheckoutPatterns()
+	if errors.Is(err, ErrSparseCheckoutNotSupported) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	cone := cfg.Core.SparseCheckoutCone
+	return sparsecheckout.NewMatcher(patterns, cone), cone && cfg.Index.Sparse, nil
+}
+
+// updateSparseCheckout sets the skip-worktree bit of the entries of the
+// index excluded by m, all of them being included when nil, and updates the
+// worktree accordingly.
+func (w *Worktree) updateSparseCheckout(m sparsecheckout.Matcher, sparse bool) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range idx.Entries {
+		if !sparseCheckoutEntry(e) {
+			continue
+		}
+
+		skip := m != nil && !m.Match(e.Name)
+		switch {
+		case skip && !e.SkipWorktree:
+			removed, err := w.removeSparseFile(e, conv)
+			if err != nil {
+				return err
+			}
+
+			// The files with changes are left in the worktree.
+			e.SkipWorktree = removed
+		case !skip && e.SkipWorktree:
+			if err := w.checkoutSparseFile(e, conv); err != nil {
+				return err
+			}
+
+			e.SkipWorktree = false
+		}
+	}
+
+	setSparseIndex(idx, sparse)
+	return w.r.Storer.SetIndex(idx)
+}
+
+// applySparseCheckout sets the skip-worktree bit of the entries of the index
+// excluded by m, without updating the worktree.
+func applySparseCheckout(idx *index.Index, m sparsecheckout.Matcher, sparse bool) {
+	for _, e := range idx.Entries {
+		if sparseCheckoutEntry(e) {
+			e.SkipWorktree = !m.Match(e.Name)
+		}
+	}
+
+	setSparseIndex(idx, sparse)
+}
+
+// setSparseIndex sets whether the index is a sparse index, upgrading it to
+// the version 3 required by the skip-worktree bit.
+func setSparseIndex(idx *index.Index, sparse bool) {
+	idx.Sparse = sparse
+	if idx.Version >= 3 {
+		return
+	}
+
+	for _, e := range idx.Entries {
+		if e.SkipWorktree {
+			idx.Version = 3
+			return
+		}
+	}
+}
+
+// sparseCheckoutEntry reports whether the entry is subject to the sparse
+// checkout: the unmerged entries and the submodules are always checked out.
+func sparseCheckoutEntry(e *index.Entry) bool {
+	return e.Stage == 0 && e.Mode != filemode.Submodule && !e.IntentToAdd
+}
+
+// removeSparseFile removes the file of the entry from the worktree, if it
+// has no changes, and reports whether it is not in the worktree anymore.
+func (w *Worktree) removeSparseFile(e *index.Entry, conv *contentConverter) (bool, error) {
+	fi, err := w.Filesystem.Lstat(e.Name)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	if fi.IsDir() || filesystem.HashFile(w.Filesystem, e.Name, fi, conv.filterOptions()) != e.Hash {
+		return false, nil
+	}
+
+	return true, rmFileAndDirsIfEmpty(w.Filesystem, e.Name)
+}
+
+// checkoutSparseFile checks out the file of the entry, unless present in
+// the worktree, and updates its stat data.
+func (w *Worktree) checkoutSparseFile(e *index.Entry, conv *contentConverter) error {
+	if _, err := w.Filesystem.Lstat(e.Name); err == nil {
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	blob, err := object.GetBlob(w.r.Storer, e.Hash)
+	if err != nil {
+		return err
+	}
+
+	if err := w.checkoutFile(object.NewFile(e.Name, e.Mode, blob), conv); err != nil {
+		return err
+	}
+
+	return w.doUpdateFileToIndex(e, e.Name, e.Hash)
+}
+
+// sparseCheckoutPatterns returns the patterns of the sparse-checkout file,
+// none when missing.
+func (r *Repository) sparseCheckoutPatterns() (patterns []string, err error) {
+	fs, ok := r.configFilesystem()
+	if !ok {
+		return nil, ErrSparseCheckoutNotSupported
+	}
+
+	f, err := fs.Open(sparseCheckoutPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return sparsecheckout.Decode(f)
+}
+
+// setSparseCheckoutPatterns writes the patterns of the sparse-checkout file.
+func (r *Repository) setSparseCheckoutPatterns(patterns []string) (err error) {
+	fs, ok := r.configFilesystem()
+	if !ok {
+		return ErrSparseCheckoutNotSupported
+	}
+
+	f, err := fs.Create(sparseCheckoutPath)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+	return sparsecheckout.Encode(f, patterns)
+}
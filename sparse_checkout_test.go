@@ -0,0 +1,140 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assertWorktreeFiles(t *testing.T, dir string, expected map[string]bool) {
+	t.Helper()
+
+	for name, exists := range expected {
+		_, err := os.Lstat(filepath.Join(dir, name))
+		assert.Equal(t, exists, err == nil, name)
+	}
+}
+
+func assertSkipWorktree(t *testing.T, w *Worktree, expected map[string]bool) {
+	t.Helper()
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+
+	skipped := make(map[string]bool)
+	for _, e := range idx.Entries {
+		skipped[e.Name] = e.SkipWorktree
+	}
+	assert.Equal(t, expected, skipped)
+}
+
+func TestSparseCheckoutCone(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"README": "readme", "a/x": "x", "a/b/y": "y", "c/z": "z",
+	})
+
+	require.NoError(t, w.SparseCheckoutSet(&SparseCheckoutOptions{Patterns: []string{"a/b"}, SparseIndex: true}))
+	assertWorktreeFiles(t, dir, map[string]bool{"README": true, "a/x": true, "a/b/y": true, "c/z": false, "c": false})
+	assertSkipWorktree(t, w, map[string]bool{"README": false, "a/x": false, "a/b/y": false, "c/z": true})
+
+	cfg, err := w.r.Config()
+	require.NoError(t, err)
+	assert.True(t, cfg.Core.SparseCheckout)
+	assert.True(t, cfg.Core.SparseCheckoutCone)
+	assert.True(t, cfg.Index.Sparse)
+
+	content, err := os.ReadFile(filepath.Join(dir, GitDirName, "info", "sparse-checkout"))
+	require.NoError(t, err)
+	assert.Equal(t, "/*\n!/*/\n/a/\n!/a/*/\n/a/b/\n", string(content))
+
+	dirs, err := w.SparseCheckoutList()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/b"}, dirs)
+
+	st, err := w.StatusWithOptions(StatusOptions{Strategy: Preload})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+
+	require.NoError(t, w.SparseCheckoutAdd("c"))
+	assertWorktreeFiles(t, dir, map[string]bool{"c/z": true})
+	assertSkipWorktree(t, w, map[string]bool{"README": false, "a/x": false, "a/b/y": false, "c/z": false})
+
+	dirs, err = w.SparseCheckoutList()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/b", "c"}, dirs)
+
+	// The files with changes are left in the worktree.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c", "z"), []byte("changed"), 0o644))
+	require.NoError(t, w.SparseCheckoutSet(&SparseCheckoutOptions{Patterns: []string{"d"}}))
+	assertWorktreeFiles(t, dir, map[string]bool{"a/x": false, "a/b/y": false, "c/z": true})
+	assertSkipWorktree(t, w, map[string]bool{"README": false, "a/x": true, "a/b/y": true, "c/z": false})
+
+	require.NoError(t, w.SparseCheckoutDisable())
+	assertWorktreeFiles(t, dir, map[string]bool{"README": true, "a/x": true, "a/b/y": true, "c/z": true})
+	assertSkipWorktree(t, w, map[string]bool{"README": false, "a/x": false, "a/b/y": false, "c/z": false})
+
+	cfg, err = w.r.Config()
+	require.NoError(t, err)
+	assert.False(t, cfg.Core.SparseCheckout)
+	assert.False(t, cfg.Index.Sparse)
+
+	_, err = w.SparseCheckoutList()
+	assert.ErrorIs(t, err, ErrSparseCheckoutDisabled)
+}
+
+func TestSparseCheckoutNoCone(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"README": "readme", "a/main.go": "main", "a/x": "x", "docs/index.md": "index",
+	})
+
+	err := w.SparseCheckoutSet(&SparseCheckoutOptions{Patterns: []string{"*.go"}, NoCone: true, SparseIndex: true})
+	assert.ErrorIs(t, err, ErrSparseIndexNoCone)
+
+	require.NoError(t, w.SparseCheckoutSet(&SparseCheckoutOptions{Patterns: []string{"*.go"}, NoCone: true}))
+	assertWorktreeFiles(t, dir, map[string]bool{"README": false, "a/main.go": true, "a/x": false, "docs/index.md": false})
+
+	require.NoError(t, w.SparseCheckoutAdd("/docs/"))
+	assertWorktreeFiles(t, dir, map[string]bool{"docs/index.md": true})
+
+	patterns, err := w.SparseCheckoutList()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"*.go", "/docs/"}, patterns)
+}
+
+func TestSparseCheckoutPersistsOnCheckout(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{"README": "readme", "a/x": "x", "c/z": "z"})
+
+	head, err := w.r.Head()
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c", "w"), []byte("w"), 0o644))
+	_, err = w.Add("c/w")
+	require.NoError(t, err)
+	_, err = w.Commit("add c/w", &CommitOptions{
+		Author: &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, w.SparseCheckoutSet(&SparseCheckoutOptions{Patterns: []string{"a"}, SparseIndex: true}))
+	assertWorktreeFiles(t, dir, map[string]bool{"a/x": true, "c/z": false, "c/w": false})
+
+	require.NoError(t, w.Checkout(&CheckoutOptions{Hash: head.Hash()}))
+	assertWorktreeFiles(t, dir, map[string]bool{"README": true, "a/x": true, "c/z": false, "c/w": false})
+	assertSkipWorktree(t, w, map[string]bool{"README": false, "a/x": false, "c/z": true})
+
+	require.NoError(t, w.Checkout(&CheckoutOptions{Branch: head.Name()}))
+	assertWorktreeFiles(t, dir, map[string]bool{"a/x": true, "c/z": false, "c/w": false})
+	assertSkipWorktree(t, w, map[string]bool{"README": false, "a/x": false, "c/z": true, "c/w": true})
+
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+	assertWorktreeFiles(t, dir, map[string]bool{"c/z": false, "c/w": false})
+
+	st, err := w.StatusWithOptions(StatusOptions{Strategy: Preload})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+}
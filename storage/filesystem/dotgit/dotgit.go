@@ -44,6 +44,8 @@ const (
 
 	tmpPackedRefsPrefix = "._packed-refs"
 
+	sharedIndexPrefix = "sharedindex."
+
 	packPrefix = "pack-"
 	packExt    = ".pack"
 	idxExt     = ".idx"
@@ -193,6 +195,12 @@ func (d *DotGit) Index() (billy.File, error) {
 	return d.fs.Open(indexPath)
 }
 
+// SharedIndex returns a file pointer for read to the shared index h of a
+// split index
+func (d *DotGit) SharedIndex(h plumbing.Hash) (billy.File, error) {
+	return d.fs.Open(sharedIndexPrefix + h.String())
+}
+
 // ShallowWriter returns a file pointer for write to the shallow file
 func (d *DotGit) ShallowWriter() (billy.File, error) {
 	return d.fs.Create(shallowPath)
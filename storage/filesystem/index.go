@@ -2,18 +2,58 @@ package filesystem
 
 import (
 	"bufio"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage/filesystem/dotgit"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
+const indexPath = "index"
+
+// ErrSparseIndexNoObjects is returned when a sparse index is read or written
+// by an IndexStorage without object storage, holding the trees of its
+// sparse directories.
+var ErrSparseIndexNoObjects = errors.New("sparse index requires an object storage")
+
 type IndexStorage struct {
-	dir *dotgit.DotGit
+	dir     *dotgit.DotGit
+	objects storer.EncodedObjectStorer
 }
 
 func (s *IndexStorage) SetIndex(idx *index.Index) (err error) {
+	// A sparse index is written with the directories out of the sparse
+	// checkout collapsed, it is expanded when read.
+	if idx.Sparse {
+		if idx, err = idx.Collapse(s.writeTree); err != nil {
+			return err
+		}
+	}
+
+	// A split index is written as the entries changed since its shared
+	// index, which is kept as is.
+	if idx.Link != nil {
+		shared := idx.SharedIndex()
+		if shared == nil {
+			if shared, err = s.sharedIndex(idx.Link.SharedIndex); err != nil {
+				return err
+			}
+		}
+
+		if idx, err = idx.SplitIndex(shared); err != nil {
+			return err
+		}
+	}
+
 	f, err := s.dir.IndexWriter()
 	if err != nil {
 		return err
@@ -49,6 +89,218 @@ func (s *IndexStorage) Index() (i *index.Index, err error) {
 	defer ioutil.CheckClose(f, &err)
 
 	d := index.NewDecoder(f)
-	err = d.Decode(idx)
+	if err = d.Decode(idx); err != nil {
+		return idx, err
+	}
+
+	if idx.Link != nil {
+		shared, err := s.sharedIndex(idx.Link.SharedIndex)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := idx.MergeSharedIndex(shared); err != nil {
+			return nil, err
+		}
+	}
+
+	if idx.Sparse {
+		if err := idx.Expand(s.treeFiles); err != nil {
+			return nil, err
+		}
+	}
+
+	return idx, nil
+}
+
+// IndexModTime returns the modification time of the index file, used to
+// detect the entries racily clean, whose file may have changed without
+// changing its stat data. The zero time is returned when there is no index.
+func (s *IndexStorage) IndexModTime() (time.Time, error) {
+	fi, err := s.dir.Fs().Stat(indexPath)
+	if os.IsNotExist(err) {
+		return time.Time{}, nil
+	}
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return fi.ModTime(), nil
+}
+
+func (s *IndexStorage) sharedIndex(h plumbing.Hash) (idx *index.Index, err error) {
+	f, err := s.dir.SharedIndex(h)
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	idx = &index.Index{}
+	err = index.NewDecoder(f).Decode(idx)
 	return idx, err
 }
+
+// treeFiles returns the entries of the files of the tree of the directory
+// dir, for the expansion of a sparse index.
+func (s *IndexStorage) treeFiles(dir string, h plumbing.Hash) ([]*index.Entry, error) {
+	if s.objects == nil {
+		return nil, ErrSparseIndexNoObjects
+	}
+
+	entries, err := s.readTree(h)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*index.Entry
+	for _, e := range entries {
+		name := dir + "/" + e.name
+		if e.mode != filemode.Dir {
+			files = append(files, &index.Entry{Name: name, Mode: e.mode, Hash: e.hash})
+			continue
+		}
+
+		sub, err := s.treeFiles(name, e.hash)
+		if err != nil {
+			return nil, err
+		}
+
+		files = append(files, sub...)
+	}
+
+	return files, nil
+}
+
+// writeTree writes the tree of the directory dir holding entries, for the
+// collapse of a sparse index.
+func (s *IndexStorage) writeTree(dir string, entries []*index.Entry) (plumbing.Hash, error) {
+	if s.objects == nil {
+		return plumbing.ZeroHash, ErrSparseIndexNoObjects
+	}
+
+	var tree []treeEntry
+	prefix := dir + "/"
+	for n := 0; n < len(entries); {
+		name := strings.TrimPrefix(entries[n].Name, prefix)
+		sub, _, isDir := strings.Cut(name, "/")
+		if !isDir {
+			e := entries[n]
+			tree = append(tree, treeEntry{name: name, mode: e.Mode, hash: e.Hash})
+			n++
+			continue
+		}
+
+		end := n + 1
+		for end < len(entries) && strings.HasPrefix(entries[end].Name, prefix+sub+"/") {
+			end++
+		}
+
+		h, err := s.writeTree(prefix+sub, entries[n:end])
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+
+		tree = append(tree, treeEntry{name: sub, mode: filemode.Dir, hash: h})
+		n = end
+	}
+
+	// The directories are sorted as if their name ended with a slash.
+	sort.Slice(tree, func(i, j int) bool {
+		return tree[i].key() < tree[j].key()
+	})
+
+	return s.storeTree(tree)
+}
+
+// treeEntry is an entry of a tree object, the storage not depending on the
+// object package.
+type treeEntry struct {
+	name string
+	mode filemode.FileMode
+	hash plumbing.Hash
+}
+
+func (e treeEntry) key() string {
+	if e.mode == filemode.Dir {
+		return e.name + "/"
+	}
+
+	return e.name
+}
+
+func (s *IndexStorage) readTree(h plumbing.Hash) (entries []treeEntry, err error) {
+	o, err := s.objects.EncodedObject(plumbing.TreeObject, h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	br := bufio.NewReader(r)
+	for {
+		mode, err := br.ReadString(' ')
+		if err == io.EOF {
+			return entries, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		e := treeEntry{}
+		if e.mode, err = filemode.New(mode[:len(mode)-1]); err != nil {
+			return nil, err
+		}
+
+		name, err := br.ReadString(0)
+		if err != nil {
+			return nil, err
+		}
+
+		e.name = name[:len(name)-1]
+		if _, err := e.hash.ReadFrom(br); err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, e)
+	}
+}
+
+func (s *IndexStorage) storeTree(entries []treeEntry) (h plumbing.Hash, err error) {
+	o := s.objects.NewEncodedObject()
+	o.SetType(plumbing.TreeObject)
+	w, err := o.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		fmt.Fprintf(bw, "%o %s\x00", e.mode, e.name)
+		if _, err := e.hash.WriteTo(bw); err != nil {
+			w.Close()
+			return plumbing.ZeroHash, err
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		w.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if s.objects.HasEncodedObject(o.Hash()) == nil {
+		return o.Hash(), nil
+	}
+
+	return s.objects.SetEncodedObject(o)
+}
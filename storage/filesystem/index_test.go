@@ -0,0 +1,161 @@
+package filesystem_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/storage/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_GLOBAL=/dev/null",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=foo", "GIT_AUTHOR_EMAIL=foo@foo.foo",
+		"GIT_COMMITTER_NAME=foo", "GIT_COMMITTER_EMAIL=foo@foo.foo",
+	)
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+func TestIndexSplitIndexAndExtensions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	for _, name := range []string{"a", "b", "d/c", "d/e/f"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+	}
+
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	runGit(t, dir, "config", "index.recordOffsetTable", "true")
+	runGit(t, dir, "config", "index.threads", "2")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "d", "untracked"), nil, 0o644))
+	runGit(t, dir, "update-index", "--split-index", "--untracked-cache")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a"), []byte("changed"), 0o644))
+	runGit(t, dir, "add", "a")
+	runGit(t, dir, "status", "--porcelain")
+
+	sto := filesystem.NewStorage(osfs.New(filepath.Join(dir, ".git")), cache.NewObjectLRUDefault())
+	idx, err := sto.Index()
+	require.NoError(t, err)
+
+	require.NotNil(t, idx.Link)
+	require.NotNil(t, idx.SharedIndex())
+	require.NotNil(t, idx.UntrackedCache)
+	require.NotNil(t, idx.UntrackedCache.Root)
+
+	var names []string
+	for _, e := range idx.Entries {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "d/c", "d/e/f"}, names)
+
+	e, err := idx.Entry("a")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(len("changed")), e.Size)
+
+	_, err = idx.Remove("b")
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(dir, "b")))
+
+	shared, err := filepath.Glob(filepath.Join(dir, ".git", "sharedindex.*"))
+	require.NoError(t, err)
+	require.Len(t, shared, 1)
+
+	require.NoError(t, sto.SetIndex(idx))
+
+	// The shared index is kept, git reads the split index.
+	after, err := filepath.Glob(filepath.Join(dir, ".git", "sharedindex.*"))
+	require.NoError(t, err)
+	assert.Equal(t, shared, after)
+
+	assert.Equal(t, "a\nd/c\nd/e/f\n", runGit(t, dir, "ls-files"))
+	assert.Equal(t, "M  a\nD  b\n?? d/untracked\n", runGit(t, dir, "status", "--porcelain"))
+
+	idx, err = sto.Index()
+	require.NoError(t, err)
+	assert.Len(t, idx.Entries, 3)
+	assert.NotNil(t, idx.UntrackedCache)
+	assert.NotNil(t, idx.Link)
+}
+
+func TestIndexKeepsUnknownExtensions(t *testing.T) {
+	fs := osfs.New(t.TempDir())
+	sto := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+
+	idx := &index.Index{
+		Version: 2,
+		Entries: []*index.Entry{{Name: "foo"}},
+		UnknownExtensions: []index.UnknownExtension{{
+			Signature: [4]byte{'T', 'E', 'S', 'T'},
+			Data:      []byte("data"),
+		}},
+	}
+	require.NoError(t, sto.SetIndex(idx))
+
+	got, err := sto.Index()
+	require.NoError(t, err)
+	assert.Equal(t, idx.UnknownExtensions, got.UnknownExtensions)
+	assert.Equal(t, "foo", got.Entries[0].Name)
+}
+
+func TestIndexSparseIndex(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	for _, name := range []string{"a", "d/c", "d/e/f", "g/h"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+	}
+
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+	runGit(t, dir, "sparse-checkout", "set", "--cone", "--sparse-index", "g")
+
+	sto := filesystem.NewStorage(osfs.New(filepath.Join(dir, ".git")), cache.NewObjectLRUDefault())
+	idx, err := sto.Index()
+	require.NoError(t, err)
+	assert.True(t, idx.Sparse)
+
+	skipped := make(map[string]bool)
+	for _, e := range idx.Entries {
+		skipped[e.Name] = e.SkipWorktree
+	}
+	assert.Equal(t, map[string]bool{"a": false, "d/c": true, "d/e/f": true, "g/h": false}, skipped)
+
+	require.NoError(t, sto.SetIndex(idx))
+	assert.Equal(t, "a\nd/\ng/h\n", runGit(t, dir, "ls-files", "--sparse"))
+	assert.Equal(t, "", runGit(t, dir, "status", "--porcelain"))
+
+	// The directory holding a file out of the sparse checkout is collapsed.
+	e, err := idx.Entry("g/h")
+	require.NoError(t, err)
+	e.SkipWorktree = true
+	require.NoError(t, os.RemoveAll(filepath.Join(dir, "g")))
+	require.NoError(t, sto.SetIndex(idx))
+	assert.Equal(t, "a\nd/\ng/\n", runGit(t, dir, "ls-files", "--sparse"))
+	assert.Equal(t, "a\nd/c\nd/e/f\ng/h\n", runGit(t, dir, "ls-files"))
+}
@@ -61,7 +61,7 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 		c = cache.NewObjectLRUDefault()
 	}
 
-	return &Storage{
+	s := &Storage{
 		fs:  fs,
 		dir: dir,
 
@@ -72,6 +72,10 @@ func NewStorageWithOptions(fs billy.Filesystem, c cache.Object, ops Options) *St
 		ConfigStorage:    ConfigStorage{dir: dir},
 		ModuleStorage:    ModuleStorage{dir: dir},
 	}
+
+	// The trees of the sparse directories of a sparse index are objects.
+	s.IndexStorage.objects = s
+	return s
 }
 
 // Filesystem returns the underlying filesystem
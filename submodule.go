@@ -5,18 +5,34 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"path"
+	"sort"
+	"strings"
 
 	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/ioutil"
 )
 
 var (
 	ErrSubmoduleAlreadyInitialized = errors.New("submodule already initialized")
 	ErrSubmoduleNotInitialized     = errors.New("submodule not initialized")
+	ErrSubmoduleAlreadyExists      = errors.New("submodule already exists")
+	ErrSubmoduleModified           = errors.New("submodule contains local modifications")
+	ErrSubmoduleGitDirExists       = errors.New("submodule git directory already exists")
+	// ErrSubmoduleAbsorbNotSupported is returned when the git directory of a
+	// submodule can not be absorbed in the storage of the repository.
+	ErrSubmoduleAbsorbNotSupported = errors.New("submodule absorb: storage not supported")
 )
 
 // Submodule a submodule allows you to keep another Git repository in a
@@ -53,19 +69,26 @@ func (s *Submodule) Init() error {
 	return s.w.r.Storer.SetConfig(cfg)
 }
 
-// Status returns the status of the submodule.
+// Status returns the status of the submodule, including the changes of its
+// worktree not ignored by its ignore option.
 func (s *Submodule) Status() (*SubmoduleStatus, error) {
 	idx, err := s.w.r.Storer.Index()
 	if err != nil {
 		return nil, err
 	}
 
-	return s.status(idx)
+	status, err := s.status(idx)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, s.contentStatus(status, "")
 }
 
 func (s *Submodule) status(idx *index.Index) (*SubmoduleStatus, error) {
 	status := &SubmoduleStatus{
-		Path: s.c.Path,
+		Path:   s.c.Path,
+		Ignore: s.c.Ignore,
 	}
 
 	e, err := idx.Entry(s.c.Path)
@@ -98,6 +121,148 @@ func (s *Submodule) status(idx *index.Index) (*SubmoduleStatus, error) {
 	return status, err
 }
 
+// contentStatus sets the modified and the untracked content of the status,
+// unless ignored, from the status of the worktree of the submodule. The
+// ignore option of the submodule is overridden by ignore, when set.
+func (s *Submodule) contentStatus(status *SubmoduleStatus, ignore config.SubmoduleIgnore) error {
+	if ignore != "" {
+		status.Ignore = ignore
+	}
+
+	if status.Current.IsZero() || status.Ignore == config.SubmoduleIgnoreAll ||
+		status.Ignore == config.SubmoduleIgnoreDirty {
+		return nil
+	}
+
+	r, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	st, err := w.StatusWithOptions(StatusOptions{IgnoreSubmodules: ignore})
+	if err != nil {
+		return err
+	}
+
+	for _, fs := range st {
+		switch {
+		case fs.Worktree == Untracked:
+			status.UntrackedContent = status.Ignore != config.SubmoduleIgnoreUntracked
+		case fs.Staging != Unmodified || fs.Worktree != Unmodified:
+			status.ModifiedContent = true
+		}
+	}
+
+	return nil
+}
+
+// SubmoduleLog returns the summary of the changes of the submodule at path
+// from the commit from to the commit to, read from its repository. It can be
+// set as the SubmoduleLog of a diff.UnifiedEncoder to encode the changes of
+// the submodules like `git diff --submodule=log`.
+func (w *Worktree) SubmoduleLog(path string, from, to plumbing.Hash) (*fdiff.SubmoduleSummary, error) {
+	summary := &fdiff.SubmoduleSummary{NotPresent: true}
+	r, err := w.submoduleRepository(path)
+	if errors.Is(err, ErrSubmoduleNotFound) || errors.Is(err, ErrSubmoduleNotInitialized) ||
+		errors.Is(err, ErrRepositoryNotExists) {
+		return summary, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	left, err := r.CommitObject(from)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return summary, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := r.CommitObject(to)
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		return summary, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	bases, err := left.MergeBase(right)
+	if err != nil {
+		return nil, err
+	}
+
+	// The commits reachable from the merge bases are not shown.
+	excluded := make(map[plumbing.Hash]bool)
+	for _, b := range bases {
+		summary.FastForward = summary.FastForward || b.Hash == from
+		summary.Rewind = summary.Rewind || b.Hash == to
+		err := object.NewCommitPreorderIter(b, excluded, nil).ForEach(func(c *object.Commit) error {
+			excluded[c.Hash] = true
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var commits []*object.Commit
+	isLeft := make(map[plumbing.Hash]bool)
+	for _, side := range []*object.Commit{right, left} {
+		for c := side; c != nil && !excluded[c.Hash]; {
+			excluded[c.Hash] = true
+			isLeft[c.Hash] = side == left
+			commits = append(commits, c)
+			if c.NumParents() == 0 {
+				break
+			}
+
+			if c, err = c.Parent(0); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(commits, func(i, j int) bool {
+		return commits[i].Committer.When.After(commits[j].Committer.When)
+	})
+
+	summary.NotPresent = false
+	for _, c := range commits {
+		subject, _, _ := strings.Cut(c.Message, "\n")
+		summary.Commits = append(summary.Commits, fdiff.SubmoduleCommit{
+			Hash: c.Hash, Subject: subject, Left: isLeft[c.Hash],
+		})
+	}
+
+	return summary, nil
+}
+
+// submoduleRepository opens the repository of the submodule at path.
+func (w *Worktree) submoduleRepository(path string) (*Repository, error) {
+	l, err := w.Submodules()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range l {
+		if s.c.Path == path {
+			return s.open()
+		}
+	}
+
+	return nil, ErrSubmoduleNotFound
+}
+
 // Repository returns the Repository represented by this submodule
 func (s *Submodule) Repository() (*Repository, error) {
 	if !s.initialized {
@@ -133,24 +298,14 @@ func (s *Submodule) Repository() (*Repository, error) {
 		return nil, err
 	}
 
-	moduleEndpoint, err := transport.NewEndpoint(s.c.URL)
+	url, err := s.w.resolveSubmoduleURL(s.c.URL)
 	if err != nil {
 		return nil, err
 	}
 
-	if !path.IsAbs(moduleEndpoint.Path) && moduleEndpoint.Protocol == "file" {
-		remotes, err := s.w.r.Remotes()
-		if err != nil {
-			return nil, err
-		}
-
-		rootEndpoint, err := transport.NewEndpoint(remotes[0].c.URLs[0])
-		if err != nil {
-			return nil, err
-		}
-
-		rootEndpoint.Path = path.Join(rootEndpoint.Path, moduleEndpoint.Path)
-		*moduleEndpoint = *rootEndpoint
+	moduleEndpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return nil, err
 	}
 
 	_, err = r.CreateRemote(&config.RemoteConfig{
@@ -161,6 +316,251 @@ func (s *Submodule) Repository() (*Repository, error) {
 	return r, err
 }
 
+// Deinit unregisters the submodule, as done by `git submodule deinit`: its
+// section is removed from the config and its worktree is emptied, keeping its
+// repository in the modules of the repository. ErrSubmoduleModified is
+// returned if the worktree holds local modifications, unless forced.
+func (s *Submodule) Deinit(o *SubmoduleDeinitOptions) error {
+	if !s.initialized {
+		return ErrSubmoduleNotInitialized
+	}
+
+	if o == nil {
+		o = &SubmoduleDeinitOptions{}
+	}
+
+	if !o.Force {
+		if err := s.checkUnmodified(); err != nil {
+			return err
+		}
+	}
+
+	// A git directory embedded in the worktree is moved away first, the
+	// history of the submodule not being lost with its worktree.
+	if err := s.AbsorbGitDir(); err != nil {
+		return err
+	}
+
+	if err := util.RemoveAll(s.w.Filesystem, s.c.Path); err != nil {
+		return err
+	}
+
+	if err := s.w.Filesystem.MkdirAll(s.c.Path, 0o755); err != nil {
+		return err
+	}
+
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	delete(cfg.Submodules, s.c.Name)
+	if err := s.w.r.Storer.SetConfig(cfg); err != nil {
+		return err
+	}
+
+	s.initialized = false
+	return nil
+}
+
+func (s *Submodule) checkUnmodified() error {
+	r, err := s.open()
+	if err == ErrRepositoryNotExists {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	status, err := w.Status()
+	if err != nil {
+		return err
+	}
+
+	if !status.IsClean() {
+		return ErrSubmoduleModified
+	}
+
+	return nil
+}
+
+// Sync sets the URL of the submodule in the config of the repository, and of
+// the default remote of the submodule, to the one of the .gitmodules file, as
+// done by `git submodule sync`.
+func (s *Submodule) Sync() error {
+	if !s.initialized {
+		return ErrSubmoduleNotInitialized
+	}
+
+	m, err := s.w.readGitmodulesFile()
+	if err != nil {
+		return err
+	}
+
+	if m == nil || m.Submodules[s.c.Name] == nil {
+		return ErrSubmoduleNotFound
+	}
+
+	url, err := s.w.resolveSubmoduleURL(m.Submodules[s.c.Name].URL)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := s.w.r.Config()
+	if err != nil {
+		return err
+	}
+
+	if c, ok := cfg.Submodules[s.c.Name]; ok {
+		c.URL = url
+		if err := s.w.r.Storer.SetConfig(cfg); err != nil {
+			return err
+		}
+	}
+
+	s.c.URL = url
+
+	r, err := s.open()
+	if err == ErrRepositoryNotExists {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	rcfg, err := r.Config()
+	if err != nil {
+		return err
+	}
+
+	remote, ok := rcfg.Remotes[DefaultRemoteName]
+	if !ok {
+		return nil
+	}
+
+	remote.URLs = []string{url}
+	return r.Storer.SetConfig(rcfg)
+}
+
+// SetURL sets the URL of the submodule in the .gitmodules file and, if the
+// submodule is initialized, synchronizes it, see Sync.
+func (s *Submodule) SetURL(url string) error {
+	if err := s.w.updateGitmodulesFile(s.c.Name, func(c *config.Submodule) {
+		c.URL = url
+	}); err != nil {
+		return err
+	}
+
+	if !s.initialized {
+		s.c.URL = url
+		return nil
+	}
+
+	return s.Sync()
+}
+
+// SetBranch sets the branch tracked by the submodule in the .gitmodules file,
+// an empty branch removing it.
+func (s *Submodule) SetBranch(branch string) error {
+	if err := s.w.updateGitmodulesFile(s.c.Name, func(c *config.Submodule) {
+		c.Branch = branch
+	}); err != nil {
+		return err
+	}
+
+	s.c.Branch = branch
+	return nil
+}
+
+// AbsorbGitDir moves the git directory embedded in the worktree of the
+// submodule to the modules of the repository, replacing it by a .git file,
+// as done by `git submodule absorbgitdirs`. Nothing is done when the git
+// directory of the submodule is already absorbed.
+func (s *Submodule) AbsorbGitDir() error {
+	worktree, err := s.w.Filesystem.Chroot(s.c.Path)
+	if err != nil {
+		return err
+	}
+
+	fi, err := worktree.Lstat(GitDirName)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !fi.IsDir() {
+		return nil
+	}
+
+	storer, err := s.w.r.Storer.Module(s.c.Name)
+	if err != nil {
+		return err
+	}
+
+	fs, ok := storer.(interface{ Filesystem() billy.Filesystem })
+	if !ok {
+		return ErrSubmoduleAbsorbNotSupported
+	}
+
+	if _, err := storer.Reference(plumbing.HEAD); err == nil {
+		return ErrSubmoduleGitDirExists
+	}
+
+	if err := copyDir(worktree, GitDirName, fs.Filesystem(), ""); err != nil {
+		return err
+	}
+
+	if err := util.RemoveAll(worktree, GitDirName); err != nil {
+		return err
+	}
+
+	if err := createDotGitFile(worktree, fs.Filesystem()); err != nil {
+		return err
+	}
+
+	// The storage of the module is opened again, once filled.
+	if storer, err = s.w.r.Storer.Module(s.c.Name); err != nil {
+		return err
+	}
+
+	r, err := Open(storer, worktree)
+	if err != nil {
+		return err
+	}
+
+	return setConfigWorktree(r, worktree, fs.Filesystem())
+}
+
+// open opens the repository of the submodule, ErrRepositoryNotExists being
+// returned when it is not cloned.
+func (s *Submodule) open() (*Repository, error) {
+	if !s.initialized {
+		return nil, ErrSubmoduleNotInitialized
+	}
+
+	storer, err := s.w.r.Storer.Module(s.c.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := s.w.Filesystem.Chroot(s.c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Open(storer, worktree)
+}
+
 // Update the registered submodule to match what the superproject expects, the
 // submodule should be initialized first calling the Init method or setting in
 // the options SubmoduleUpdateOptions.Init equals true
@@ -315,6 +715,84 @@ func (s Submodules) UpdateContext(ctx context.Context, o *SubmoduleUpdateOptions
 	return nil
 }
 
+// Sync synchronizes the URL of the initialized submodules in this list, see
+// Submodule.Sync.
+func (s Submodules) Sync() error {
+	for _, sub := range s {
+		if !sub.initialized {
+			continue
+		}
+
+		if err := sub.Sync(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AbsorbGitDirs absorbs the git directories of the submodules in this list,
+// see Submodule.AbsorbGitDir.
+func (s Submodules) AbsorbGitDirs() error {
+	for _, sub := range s {
+		if err := sub.AbsorbGitDir(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ForEach calls fn with each submodule in this list checked out and its
+// repository, as done by `git submodule foreach`, along with their nested
+// submodules until the given recursivity is reached. The iteration stops
+// when fn returns an error, storer.ErrStop stopping it without error.
+func (s Submodules) ForEach(recursivity SubmoduleRecursivity, fn func(*Submodule, *Repository) error) error {
+	err := s.forEach(recursivity, fn)
+	if err == storer.ErrStop {
+		return nil
+	}
+
+	return err
+}
+
+func (s Submodules) forEach(recursivity SubmoduleRecursivity, fn func(*Submodule, *Repository) error) error {
+	for _, sub := range s {
+		r, err := sub.open()
+		if err == ErrSubmoduleNotInitialized || err == ErrRepositoryNotExists {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(sub, r); err != nil {
+			return err
+		}
+
+		if recursivity == NoRecurseSubmodules {
+			continue
+		}
+
+		w, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+
+		l, err := w.Submodules()
+		if err != nil {
+			return err
+		}
+
+		if err := l.forEach(recursivity-1, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // Status returns the status of the submodules.
 func (s Submodules) Status() (SubmodulesStatus, error) {
 	var list SubmodulesStatus
@@ -335,6 +813,10 @@ func (s Submodules) Status() (SubmodulesStatus, error) {
 			return nil, err
 		}
 
+		if err := sub.contentStatus(status, ""); err != nil {
+			return nil, err
+		}
+
 		list = append(list, status)
 	}
 
@@ -360,6 +842,14 @@ type SubmoduleStatus struct {
 	Current  plumbing.Hash
 	Expected plumbing.Hash
 	Branch   plumbing.ReferenceName
+	// ModifiedContent is set when the worktree of the submodule has changes
+	// to its tracked files, not computed when ignored.
+	ModifiedContent bool
+	// UntrackedContent is set when the worktree of the submodule has
+	// untracked files, not computed when ignored.
+	UntrackedContent bool
+	// Ignore defines which changes of the submodule are ignored.
+	Ignore config.SubmoduleIgnore
 }
 
 // IsClean is the HEAD of the submodule is equals to the expected commit
@@ -367,6 +857,22 @@ func (s *SubmoduleStatus) IsClean() bool {
 	return s.Current == s.Expected
 }
 
+// HasNewCommits reports whether the HEAD of the submodule differs from the
+// expected commit.
+func (s *SubmoduleStatus) HasNewCommits() bool {
+	return !s.Current.IsZero() && s.Current != s.Expected
+}
+
+// IsModified reports whether the submodule has changes not ignored: new
+// commits, modified content or untracked content.
+func (s *SubmoduleStatus) IsModified() bool {
+	if s.Ignore == config.SubmoduleIgnoreAll {
+		return false
+	}
+
+	return s.HasNewCommits() || s.ModifiedContent || s.UntrackedContent
+}
+
 // String is equivalent to `git submodule status <submodule>`
 //
 // This will print the SHA-1 of the currently checked out commit for a
@@ -396,3 +902,239 @@ func (s *SubmoduleStatus) String() string {
 
 	return fmt.Sprintf("%c%s %s%s", status, s.Expected, s.Path, extra)
 }
+
+// AddSubmodule adds the repository at url as a submodule at path, as done by
+// `git submodule add`: the repository is cloned in the modules of the
+// repository, the submodule is recorded in the .gitmodules file and
+// initialized, and its gitlink and the .gitmodules file are staged.
+func (w *Worktree) AddSubmodule(url, path string, o *SubmoduleAddOptions) (*Submodule, error) {
+	return w.AddSubmoduleContext(context.Background(), url, path, o)
+}
+
+// AddSubmoduleContext adds the repository at url as a submodule at path, see
+// AddSubmodule.
+//
+// The provided Context must be non-nil. If the context expires before the
+// operation is complete, an error is returned. The context only affects the
+// transport operations.
+func (w *Worktree) AddSubmoduleContext(ctx context.Context, url, path string, o *SubmoduleAddOptions) (*Submodule, error) {
+	if o == nil {
+		o = &SubmoduleAddOptions{}
+	}
+
+	c := &config.Submodule{Name: o.Name, Path: path, URL: url, Branch: o.Branch}
+	if c.Name == "" {
+		c.Name = path
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range idx.Entries {
+		if e.Name == path || strings.HasPrefix(e.Name, path+"/") {
+			return nil, ErrSubmoduleAlreadyExists
+		}
+	}
+
+	m, err := w.readGitmodulesFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		m = config.NewModules()
+	}
+
+	if _, ok := m.Submodules[c.Name]; ok {
+		return nil, ErrSubmoduleAlreadyExists
+	}
+
+	resolved, err := w.resolveSubmoduleURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	storer, err := w.r.Storer.Module(c.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	worktree, err := w.Filesystem.Chroot(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var ref plumbing.ReferenceName
+	if o.Branch != "" {
+		ref = plumbing.NewBranchReferenceName(o.Branch)
+	}
+
+	r, err := CloneContext(ctx, storer, worktree, &CloneOptions{
+		URL:           resolved,
+		ReferenceName: ref,
+		Depth:         o.Depth,
+		Auth:          o.Auth,
+		Progress:      o.Progress,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+
+	m.Submodules[c.Name] = c
+	if err := w.writeGitmodulesFile(m); err != nil {
+		return nil, err
+	}
+
+	e := idx.Add(path)
+	e.Mode = filemode.Submodule
+	e.Hash = head.Hash()
+	if err := w.r.Storer.SetIndex(idx); err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Add(gitmodulesFile); err != nil {
+		return nil, err
+	}
+
+	cfg, err := w.r.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	fromConfig := &config.Submodule{Name: c.Name, URL: resolved}
+	cfg.Submodules[c.Name] = fromConfig
+	if err := w.r.Storer.SetConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	return w.newSubmodule(c, fromConfig), nil
+}
+
+// resolveSubmoduleURL returns the URL of a submodule, a relative URL, starting
+// with ./ or ../, being resolved against the one of the default remote of the
+// repository, or against its worktree when it has no remote. Any other URL is
+// returned unchanged.
+func (w *Worktree) resolveSubmoduleURL(url string) (string, error) {
+	if !strings.HasPrefix(url, "./") && !strings.HasPrefix(url, "../") {
+		return url, nil
+	}
+
+	base := w.Filesystem.Root()
+	remote, err := w.r.Remote(DefaultRemoteName)
+	if err == ErrRemoteNotFound {
+		var remotes []*Remote
+		if remotes, err = w.r.Remotes(); err == nil && len(remotes) > 0 {
+			remote = remotes[0]
+		}
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if remote != nil && len(remote.c.URLs) > 0 {
+		base = remote.c.URLs[0]
+	}
+
+	root, err := transport.NewEndpoint(base)
+	if err != nil {
+		return "", err
+	}
+
+	root.Path = path.Join(root.Path, url)
+	return root.String(), nil
+}
+
+// updateGitmodulesFile updates the submodule name of the .gitmodules file.
+func (w *Worktree) updateGitmodulesFile(name string, update func(*config.Submodule)) error {
+	m, err := w.readGitmodulesFile()
+	if err != nil {
+		return err
+	}
+
+	if m == nil || m.Submodules[name] == nil {
+		return ErrSubmoduleNotFound
+	}
+
+	update(m.Submodules[name])
+	return w.writeGitmodulesFile(m)
+}
+
+func (w *Worktree) writeGitmodulesFile(m *config.Modules) error {
+	if w.isSymlink(gitmodulesFile) {
+		return ErrGitModulesSymlink
+	}
+
+	b, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(w.Filesystem, gitmodulesFile, b, 0o644)
+}
+
+// copyDir copies the directory from of src, along with its content, to the
+// directory to of dst.
+func copyDir(src billy.Filesystem, from string, dst billy.Filesystem, to string) error {
+	fis, err := src.ReadDir(from)
+	if err != nil {
+		return err
+	}
+
+	if err := dst.MkdirAll(to, 0o755); err != nil {
+		return err
+	}
+
+	for _, fi := range fis {
+		srcPath := src.Join(from, fi.Name())
+		dstPath := dst.Join(to, fi.Name())
+
+		switch {
+		case fi.IsDir():
+			err = copyDir(src, srcPath, dst, dstPath)
+		case fi.Mode()&os.ModeSymlink != 0:
+			var target string
+			if target, err = src.Readlink(srcPath); err == nil {
+				err = dst.Symlink(target, dstPath)
+			}
+		default:
+			err = copyFile(src, srcPath, dst, dstPath, fi.Mode())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src billy.Filesystem, from string, dst billy.Filesystem, to string, mode os.FileMode) (err error) {
+	r, err := src.Open(from)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(r, &err)
+
+	w, err := dst.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+
+	_, err = io.Copy(w, r)
+	return err
+}
@@ -0,0 +1,381 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+)
+
+// ErrSubmoduleNotPushed is returned by a push checking the submodules when
+// commits recorded for them are not on a remote of their repository.
+var ErrSubmoduleNotPushed = errors.New("submodule commits not pushed")
+
+// recurseSubmodules returns the recursion mode of the submodules: the one
+// of the options when set, the one of the config otherwise.
+func (r *Repository) recurseSubmodules(mode config.RecurseSubmodules, push bool) (config.RecurseSubmodules, error) {
+	if mode != "" {
+		return mode, nil
+	}
+
+	cfg, err := r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return "", err
+	}
+
+	if push {
+		return cfg.Push.RecurseSubmodules, nil
+	}
+
+	return cfg.Fetch.RecurseSubmodules, nil
+}
+
+// referenceHashes returns the hashes of the references of the repository.
+func (r *Repository) referenceHashes() (map[plumbing.Hash]bool, error) {
+	refs, err := r.References()
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[plumbing.Hash]bool)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			hashes[ref.Hash()] = true
+		}
+
+		return nil
+	})
+
+	return hashes, err
+}
+
+// fetchSubmodules fetches the submodules after a fetch of the repository:
+// all the populated ones with RecurseSubmodulesYes, only the ones missing
+// commits recorded by the fetched commits with RecurseSubmodulesOnDemand.
+// known are the hashes of the references before the fetch.
+func (r *Repository) fetchSubmodules(ctx context.Context, o *FetchOptions, mode config.RecurseSubmodules, known map[plumbing.Hash]bool) error {
+	w, err := r.Worktree()
+	if errors.Is(err, ErrIsBareRepository) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	subs, err := w.Submodules()
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+
+	var recorded map[string][]plumbing.Hash
+	if mode == config.RecurseSubmodulesOnDemand {
+		current, err := r.referenceHashes()
+		if err != nil {
+			return err
+		}
+
+		var tips []plumbing.Hash
+		for h := range current {
+			if !known[h] {
+				tips = append(tips, h)
+			}
+		}
+
+		recorded, err = r.recordedSubmoduleCommits(subs, tips, known)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, s := range subs {
+		sr, err := s.open()
+		if errors.Is(err, ErrSubmoduleNotInitialized) || errors.Is(err, ErrRepositoryNotExists) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if mode == config.RecurseSubmodulesOnDemand {
+			if missing := missingCommits(sr, recorded[s.c.Path]); len(missing) == 0 {
+				continue
+			}
+		}
+
+		err = sr.FetchContext(ctx, &FetchOptions{
+			Auth:              o.Auth,
+			Progress:          o.Progress,
+			InsecureSkipTLS:   o.InsecureSkipTLS,
+			CABundle:          o.CABundle,
+			ProxyOptions:      o.ProxyOptions,
+			RecurseSubmodules: mode,
+		})
+
+		if err != nil && !errors.Is(err, NoErrAlreadyUpToDate) {
+			return fmt.Errorf("fetching submodule %s: %w", s.c.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// checkSubmodulesPushed checks, before a push, that the commits recorded
+// for the submodules by the pushed commits are on a remote of their
+// repository, pushing the submodules first with RecurseSubmodulesOnDemand.
+func (r *Repository) checkSubmodulesPushed(ctx context.Context, o *PushOptions, mode config.RecurseSubmodules) error {
+	w, err := r.Worktree()
+	if errors.Is(err, ErrIsBareRepository) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	subs, err := w.Submodules()
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+
+	tips, known, err := r.pushedCommits(o)
+	if err != nil {
+		return err
+	}
+
+	recorded, err := r.recordedSubmoduleCommits(subs, tips, known)
+	if err != nil {
+		return err
+	}
+
+	var unpushed []string
+	for _, s := range subs {
+		if len(recorded[s.c.Path]) == 0 {
+			continue
+		}
+
+		sr, err := s.open()
+		if errors.Is(err, ErrSubmoduleNotInitialized) || errors.Is(err, ErrRepositoryNotExists) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		pushed, err := submoduleCommitsPushed(sr, recorded[s.c.Path])
+		if err != nil {
+			return err
+		}
+
+		if !pushed && mode == config.RecurseSubmodulesOnDemand {
+			err := sr.PushContext(ctx, &PushOptions{
+				Auth:              o.Auth,
+				Progress:          o.Progress,
+				InsecureSkipTLS:   o.InsecureSkipTLS,
+				CABundle:          o.CABundle,
+				ProxyOptions:      o.ProxyOptions,
+				RecurseSubmodules: mode,
+			})
+
+			if err != nil && !errors.Is(err, NoErrAlreadyUpToDate) {
+				return fmt.Errorf("pushing submodule %s: %w", s.c.Path, err)
+			}
+
+			if pushed, err = submoduleCommitsPushed(sr, recorded[s.c.Path]); err != nil {
+				return err
+			}
+		}
+
+		if !pushed {
+			unpushed = append(unpushed, s.c.Path)
+		}
+	}
+
+	if len(unpushed) != 0 {
+		sort.Strings(unpushed)
+		return fmt.Errorf("%w: %s", ErrSubmoduleNotPushed, strings.Join(unpushed, ", "))
+	}
+
+	return nil
+}
+
+// pushedCommits returns the local commits pushed by the refspecs of the
+// options, and the ones known to be on the remote.
+func (r *Repository) pushedCommits(o *PushOptions) (tips []plumbing.Hash, known map[plumbing.Hash]bool, err error) {
+	refs, err := r.References()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	known = make(map[plumbing.Hash]bool)
+	remotePrefix := plumbing.NewRemoteReferenceName(o.RemoteName, "").String()
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		if strings.HasPrefix(ref.Name().String(), remotePrefix) {
+			known[ref.Hash()] = true
+			return nil
+		}
+
+		for _, rs := range o.RefSpecs {
+			if !rs.IsDelete() && rs.Match(ref.Name()) {
+				tips = append(tips, ref.Hash())
+				break
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, rs := range o.RefSpecs {
+		if !rs.IsDelete() && !rs.IsWildcard() && plumbing.IsHash(rs.Src()) {
+			tips = append(tips, plumbing.NewHash(rs.Src()))
+		}
+	}
+
+	return tips, known, nil
+}
+
+// recordedSubmoduleCommits returns the commits recorded for the submodules,
+// by path, by the commits reachable from tips and not from the known ones.
+func (r *Repository) recordedSubmoduleCommits(subs Submodules, tips []plumbing.Hash, known map[plumbing.Hash]bool) (map[string][]plumbing.Hash, error) {
+	recorded := make(map[string][]plumbing.Hash)
+	seen := make(map[plumbing.Hash]bool)
+	isValid := object.CommitFilter(func(c *object.Commit) bool { return !known[c.Hash] })
+	isLimit := object.CommitFilter(func(c *object.Commit) bool { return known[c.Hash] })
+	for _, tip := range tips {
+		c, err := r.CommitObject(tip)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			// Not a commit, like an annotated tag.
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = object.NewFilterCommitIter(c, &isValid, &isLimit).ForEach(func(c *object.Commit) error {
+			if seen[c.Hash] {
+				return nil
+			}
+
+			seen[c.Hash] = true
+			tree, err := c.Tree()
+			if err != nil {
+				return err
+			}
+
+			for _, s := range subs {
+				e, err := tree.FindEntry(s.c.Path)
+				if errors.Is(err, object.ErrEntryNotFound) || errors.Is(err, object.ErrDirectoryNotFound) {
+					continue
+				}
+
+				if err != nil {
+					return err
+				}
+
+				if e.Mode == filemode.Submodule {
+					recorded[s.c.Path] = append(recorded[s.c.Path], e.Hash)
+				}
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return recorded, nil
+}
+
+// missingCommits returns the commits not found in the repository.
+func missingCommits(r *Repository, commits []plumbing.Hash) []plumbing.Hash {
+	var missing []plumbing.Hash
+	for _, h := range commits {
+		if _, err := r.Storer.EncodedObject(plumbing.CommitObject, h); err != nil {
+			missing = append(missing, h)
+		}
+	}
+
+	return missing
+}
+
+// submoduleCommitsPushed reports whether the commits are reachable from the
+// remote references of the repository of a submodule, the ones it does not
+// have being ignored like git does.
+func submoduleCommitsPushed(r *Repository, commits []plumbing.Hash) (bool, error) {
+	wanted := make(map[plumbing.Hash]bool)
+	missing := missingCommits(r, commits)
+	for _, h := range commits {
+		wanted[h] = true
+	}
+
+	for _, h := range missing {
+		delete(wanted, h)
+	}
+
+	if len(wanted) == 0 {
+		return true, nil
+	}
+
+	refs, err := r.References()
+	if err != nil {
+		return false, err
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || !ref.Name().IsRemote() {
+			return nil
+		}
+
+		c, err := r.CommitObject(ref.Hash())
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			delete(wanted, c.Hash)
+			if len(wanted) == 0 {
+				return storer.ErrStop
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+
+		if len(wanted) == 0 {
+			return storer.ErrStop
+		}
+
+		return nil
+	})
+
+	return len(wanted) == 0, err
+}
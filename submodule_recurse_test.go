@@ -0,0 +1,124 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchRecurseSubmodules(t *testing.T) {
+	upstream, dir, libDir := newSubmoduleTestRepository(t)
+	_, err := upstream.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+	commitAll(t, upstream)
+
+	clone, err := PlainClone(t.TempDir(), &CloneOptions{URL: dir, RecurseSubmodules: DefaultSubmoduleRecursionDepth})
+	require.NoError(t, err)
+	w, err := clone.Worktree()
+	require.NoError(t, err)
+
+	// A new commit of lib is recorded upstream.
+	lib, err := PlainOpen(libDir)
+	require.NoError(t, err)
+	lw, err := lib.Worktree()
+	require.NoError(t, err)
+	libHead := commitTestFile(t, lw, "new.go", "package lib", "add new")
+
+	sw := newSubmoduleWorktree(t, upstream, "lib")
+	require.NoError(t, sw.r.Fetch(&FetchOptions{}))
+	require.NoError(t, sw.Checkout(&CheckoutOptions{Hash: libHead}))
+	commitAll(t, upstream)
+
+	sub := newSubmoduleWorktree(t, w, "lib")
+	require.NoError(t, clone.Fetch(&FetchOptions{RecurseSubmodules: config.RecurseSubmodulesNo}))
+	_, err = sub.r.CommitObject(libHead)
+	assert.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+
+	// Nothing new, the submodules are not fetched.
+	err = clone.Fetch(&FetchOptions{RecurseSubmodules: config.RecurseSubmodulesOnDemand})
+	assert.ErrorIs(t, err, NoErrAlreadyUpToDate)
+	_, err = sub.r.CommitObject(libHead)
+	assert.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+
+	// The config applies when the options do not set it.
+	cfg, err := clone.Config()
+	require.NoError(t, err)
+	cfg.Fetch.RecurseSubmodules = config.RecurseSubmodulesYes
+	require.NoError(t, clone.SetConfig(cfg))
+
+	err = clone.Fetch(&FetchOptions{})
+	assert.ErrorIs(t, err, NoErrAlreadyUpToDate)
+	_, err = newSubmoduleWorktree(t, w, "lib").r.CommitObject(libHead)
+	assert.NoError(t, err)
+}
+
+func TestFetchRecurseSubmodulesOnDemandNewCommits(t *testing.T) {
+	upstream, dir, libDir := newSubmoduleTestRepository(t)
+	_, err := upstream.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+	commitAll(t, upstream)
+
+	clone, err := PlainClone(t.TempDir(), &CloneOptions{URL: dir, RecurseSubmodules: DefaultSubmoduleRecursionDepth})
+	require.NoError(t, err)
+	w, err := clone.Worktree()
+	require.NoError(t, err)
+
+	lib, err := PlainOpen(libDir)
+	require.NoError(t, err)
+	lw, err := lib.Worktree()
+	require.NoError(t, err)
+	libHead := commitTestFile(t, lw, "new.go", "package lib", "add new")
+
+	sw := newSubmoduleWorktree(t, upstream, "lib")
+	require.NoError(t, sw.r.Fetch(&FetchOptions{}))
+	require.NoError(t, sw.Checkout(&CheckoutOptions{Hash: libHead}))
+	commitAll(t, upstream)
+
+	require.NoError(t, clone.Fetch(&FetchOptions{RecurseSubmodules: config.RecurseSubmodulesOnDemand}))
+	_, err = newSubmoduleWorktree(t, w, "lib").r.CommitObject(libHead)
+	assert.NoError(t, err)
+}
+
+func TestPushRecurseSubmodules(t *testing.T) {
+	w, _, libDir := newSubmoduleTestRepository(t)
+	_, err := w.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+	commitAll(t, w)
+
+	remoteDir := t.TempDir()
+	_, err = PlainInit(remoteDir, true)
+	require.NoError(t, err)
+	_, err = w.r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{remoteDir}})
+	require.NoError(t, err)
+
+	// The commit of lib is on its remote.
+	require.NoError(t, w.r.Push(&PushOptions{RecurseSubmodules: config.RecurseSubmodulesCheck}))
+
+	sw := newSubmoduleWorktree(t, w, "lib")
+	require.NoError(t, sw.Checkout(&CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	libHead := commitTestFile(t, sw, "new.go", "package lib", "add new")
+	commitAll(t, w)
+
+	err = w.r.Push(&PushOptions{RecurseSubmodules: config.RecurseSubmodulesCheck})
+	assert.ErrorIs(t, err, ErrSubmoduleNotPushed)
+	assert.ErrorContains(t, err, "lib")
+
+	cfg, err := w.r.Config()
+	require.NoError(t, err)
+	cfg.Push.RecurseSubmodules = config.RecurseSubmodulesOnDemand
+	require.NoError(t, w.r.SetConfig(cfg))
+
+	require.NoError(t, w.r.Push(&PushOptions{}))
+
+	lib, err := PlainOpen(libDir)
+	require.NoError(t, err)
+	ref, err := lib.Reference(plumbing.NewBranchReferenceName("feature"), false)
+	require.NoError(t, err)
+	assert.Equal(t, libHead, ref.Hash())
+
+	err = w.r.Push(&PushOptions{RecurseSubmodules: config.RecurseSubmodulesCheck})
+	assert.ErrorIs(t, err, NoErrAlreadyUpToDate)
+}
@@ -1,13 +1,24 @@
 package git
 
 import (
+	"bytes"
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
 	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
 	fixtures "github.com/go-git/go-git-fixtures/v5"
@@ -274,3 +285,421 @@ func (s *SubmoduleSuite) TestSubmoduleParseScp() {
 	_, err := submodule.Repository()
 	s.NoError(err)
 }
+
+func newSubmoduleTestRepository(t *testing.T) (*Worktree, string, string) {
+	t.Helper()
+
+	_, libDir := newStatusTestRepository(t, map[string]string{"lib.go": "package lib"})
+	w, dir := newStatusTestRepository(t, map[string]string{"README": "readme"})
+	return w, dir, libDir
+}
+
+func TestAddSubmodule(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+
+	sub, err := w.AddSubmodule(libDir, "vendor/lib", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "vendor/lib", sub.Config().Name)
+	assert.Equal(t, "vendor/lib", sub.Config().Path)
+
+	content, err := os.ReadFile(filepath.Join(dir, "vendor", "lib", "lib.go"))
+	require.NoError(t, err)
+	assert.Equal(t, "package lib", string(content))
+
+	dotGit, err := os.ReadFile(filepath.Join(dir, "vendor", "lib", GitDirName))
+	require.NoError(t, err)
+	assert.Equal(t, "gitdir: ../../.git/modules/vendor/lib\n", string(dotGit))
+
+	gitmodules, err := os.ReadFile(filepath.Join(dir, gitmodulesFile))
+	require.NoError(t, err)
+	assert.Equal(t, "[submodule \"vendor/lib\"]\n\tpath = vendor/lib\n\turl = "+libDir+"\n", string(gitmodules))
+
+	lib, err := PlainOpen(libDir)
+	require.NoError(t, err)
+	libHead, err := lib.Head()
+	require.NoError(t, err)
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	e, err := idx.Entry("vendor/lib")
+	require.NoError(t, err)
+	assert.Equal(t, filemode.Submodule, e.Mode)
+	assert.Equal(t, libHead.Hash(), e.Hash)
+
+	st, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Added, st.File("vendor/lib").Staging)
+	assert.Equal(t, Added, st.File(gitmodulesFile).Staging)
+
+	cfg, err := w.r.Config()
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Submodules["vendor/lib"])
+	assert.Equal(t, libDir, cfg.Submodules["vendor/lib"].URL)
+
+	status, err := sub.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean())
+
+	_, err = w.AddSubmodule(libDir, "vendor/lib", nil)
+	assert.ErrorIs(t, err, ErrSubmoduleAlreadyExists)
+}
+
+func TestAddSubmoduleRelativeURL(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+
+	_, err := w.r.CreateRemote(&config.RemoteConfig{Name: DefaultRemoteName, URLs: []string{dir}})
+	require.NoError(t, err)
+
+	url, err := w.resolveSubmoduleURL("../" + filepath.Base(libDir))
+	require.NoError(t, err)
+	assert.Equal(t, "file://"+filepath.ToSlash(libDir), url)
+
+	url, err = w.resolveSubmoduleURL("./" + filepath.Base(libDir))
+	require.NoError(t, err)
+	assert.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, filepath.Base(libDir))), url)
+
+	url, err = w.resolveSubmoduleURL("git@github.com:foo/bar.git")
+	require.NoError(t, err)
+	assert.Equal(t, "git@github.com:foo/bar.git", url)
+
+	url, err = w.resolveSubmoduleURL("foo")
+	require.NoError(t, err)
+	assert.Equal(t, "foo", url)
+}
+
+func TestSubmoduleSetURLAndSync(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+
+	sub, err := w.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+
+	url := "https://example.com/lib.git"
+	require.NoError(t, sub.SetURL(url))
+	assert.Equal(t, url, sub.Config().URL)
+
+	m, err := w.readGitmodulesFile()
+	require.NoError(t, err)
+	assert.Equal(t, url, m.Submodules["lib"].URL)
+
+	cfg, err := w.r.Config()
+	require.NoError(t, err)
+	assert.Equal(t, url, cfg.Submodules["lib"].URL)
+
+	r, err := sub.Repository()
+	require.NoError(t, err)
+	remote, err := r.Remote(DefaultRemoteName)
+	require.NoError(t, err)
+	assert.Equal(t, []string{url}, remote.Config().URLs)
+
+	require.NoError(t, sub.SetBranch("dev"))
+	gitmodules, err := os.ReadFile(filepath.Join(dir, gitmodulesFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(gitmodules), "branch = dev")
+
+	require.NoError(t, sub.SetBranch(""))
+	gitmodules, err = os.ReadFile(filepath.Join(dir, gitmodulesFile))
+	require.NoError(t, err)
+	assert.NotContains(t, string(gitmodules), "branch")
+}
+
+func TestSubmoduleDeinit(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+
+	sub, err := w.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "lib.go"), []byte("changed"), 0o644))
+	err = sub.Deinit(nil)
+	assert.ErrorIs(t, err, ErrSubmoduleModified)
+
+	require.NoError(t, sub.Deinit(&SubmoduleDeinitOptions{Force: true}))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "lib"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = os.Stat(filepath.Join(dir, GitDirName, "modules", "lib", "HEAD"))
+	assert.NoError(t, err)
+
+	cfg, err := w.r.Config()
+	require.NoError(t, err)
+	assert.NotContains(t, cfg.Submodules, "lib")
+
+	sub, err = w.Submodule("lib")
+	require.NoError(t, err)
+	assert.False(t, sub.initialized)
+	assert.ErrorIs(t, sub.Deinit(nil), ErrSubmoduleNotInitialized)
+}
+
+func TestSubmoduleDeinitEmbeddedGitDir(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+
+	lib, err := PlainClone(filepath.Join(dir, "lib"), &CloneOptions{URL: libDir})
+	require.NoError(t, err)
+	head, err := lib.Head()
+	require.NoError(t, err)
+
+	m := config.NewModules()
+	m.Submodules["lib"] = &config.Submodule{Name: "lib", Path: "lib", URL: libDir}
+	require.NoError(t, w.writeGitmodulesFile(m))
+
+	sub, err := w.Submodule("lib")
+	require.NoError(t, err)
+	require.NoError(t, sub.Init())
+	require.NoError(t, sub.Deinit(nil))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "lib"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	r, err := PlainOpen(filepath.Join(dir, GitDirName, "modules", "lib"))
+	require.NoError(t, err)
+	commit, err := r.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash(), commit.Hash)
+}
+
+func TestSubmoduleAbsorbGitDir(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+
+	lib, err := PlainClone(filepath.Join(dir, "lib"), &CloneOptions{URL: libDir})
+	require.NoError(t, err)
+	head, err := lib.Head()
+	require.NoError(t, err)
+
+	m := config.NewModules()
+	m.Submodules["lib"] = &config.Submodule{Name: "lib", Path: "lib", URL: libDir}
+	require.NoError(t, w.writeGitmodulesFile(m))
+
+	sub, err := w.Submodule("lib")
+	require.NoError(t, err)
+	require.NoError(t, sub.AbsorbGitDir())
+
+	dotGit, err := os.ReadFile(filepath.Join(dir, "lib", GitDirName))
+	require.NoError(t, err)
+	assert.Equal(t, "gitdir: ../.git/modules/lib\n", string(dotGit))
+
+	r, err := PlainOpenWithOptions(filepath.Join(dir, "lib"), &PlainOpenOptions{EnableDotGitCommonDir: true})
+	require.NoError(t, err)
+	absorbed, err := r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash(), absorbed.Hash())
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	assert.Equal(t, "../../../lib", cfg.Core.Worktree)
+
+	rw, err := r.Worktree()
+	require.NoError(t, err)
+	st, err := rw.Status()
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+
+	// Absorbing again does nothing.
+	require.NoError(t, sub.AbsorbGitDir())
+}
+
+func TestSubmodulesForEach(t *testing.T) {
+	_, libDir := newStatusTestRepository(t, map[string]string{"lib.go": "package lib"})
+	app, appDir := newStatusTestRepository(t, map[string]string{"main.go": "package main"})
+	_, err := app.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+	_, err = app.Commit("add lib", &CommitOptions{
+		Author: &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	w, _ := newStatusTestRepository(t, map[string]string{"README": "readme"})
+	_, err = w.AddSubmodule(appDir, "app", nil)
+	require.NoError(t, err)
+	_, err = w.AddSubmodule(libDir, "other", nil)
+	require.NoError(t, err)
+
+	subs, err := w.Submodules()
+	require.NoError(t, err)
+
+	var paths []string
+	collect := func(s *Submodule, r *Repository) error {
+		paths = append(paths, s.Config().Path)
+		return nil
+	}
+
+	require.NoError(t, subs.ForEach(DefaultSubmoduleRecursionDepth, collect))
+	assert.ElementsMatch(t, []string{"app", "other"}, paths)
+
+	app2, err := w.Submodule("app")
+	require.NoError(t, err)
+	r, err := app2.Repository()
+	require.NoError(t, err)
+	aw, err := r.Worktree()
+	require.NoError(t, err)
+	nested, err := aw.Submodules()
+	require.NoError(t, err)
+	require.NoError(t, nested.Update(&SubmoduleUpdateOptions{Init: true}))
+
+	paths = nil
+	require.NoError(t, subs.ForEach(DefaultSubmoduleRecursionDepth, collect))
+	assert.ElementsMatch(t, []string{"app", "lib", "other"}, paths)
+
+	paths = nil
+	require.NoError(t, subs.ForEach(NoRecurseSubmodules, collect))
+	assert.ElementsMatch(t, []string{"app", "other"}, paths)
+
+	paths = nil
+	require.NoError(t, subs.ForEach(DefaultSubmoduleRecursionDepth, func(s *Submodule, r *Repository) error {
+		paths = append(paths, s.Config().Path)
+		return storer.ErrStop
+	}))
+	assert.Len(t, paths, 1)
+}
+
+func commitTestFile(t *testing.T, w *Worktree, name, content, msg string) plumbing.Hash {
+	t.Helper()
+
+	require.NoError(t, util.WriteFile(w.Filesystem, name, []byte(content), 0o644))
+	_, err := w.Add(name)
+	require.NoError(t, err)
+
+	h, err := w.Commit(msg, &CommitOptions{
+		Author: &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return h
+}
+
+func newSubmoduleWorktree(t *testing.T, w *Worktree, path string) *Worktree {
+	t.Helper()
+
+	sub, err := w.Submodule(path)
+	require.NoError(t, err)
+	r, err := sub.Repository()
+	require.NoError(t, err)
+	sw, err := r.Worktree()
+	require.NoError(t, err)
+	return sw
+}
+
+func TestSubmoduleStatusContent(t *testing.T) {
+	w, dir, libDir := newSubmoduleTestRepository(t)
+	_, err := w.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+	commitAll(t, w)
+
+	st, err := w.StatusWithOptions(StatusOptions{Strategy: Preload})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "new.go"), []byte("new"), 0o644))
+
+	sub, err := w.Submodule("lib")
+	require.NoError(t, err)
+	status, err := sub.Status()
+	require.NoError(t, err)
+	assert.True(t, status.UntrackedContent)
+	assert.False(t, status.ModifiedContent)
+	assert.False(t, status.HasNewCommits())
+	assert.True(t, status.IsModified())
+
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload})
+	require.NoError(t, err)
+	assert.Equal(t, Modified, st.File("lib").Worktree)
+
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload, IgnoreSubmodules: config.SubmoduleIgnoreUntracked})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lib", "lib.go"), []byte("package changed"), 0o644))
+	status, err = sub.Status()
+	require.NoError(t, err)
+	assert.True(t, status.ModifiedContent)
+
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload, IgnoreSubmodules: config.SubmoduleIgnoreDirty})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+
+	// The new commits are only ignored with all.
+	sw := newSubmoduleWorktree(t, w, "lib")
+	commitTestFile(t, sw, "lib.go", "package changed", "change lib")
+
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload, IgnoreSubmodules: config.SubmoduleIgnoreDirty})
+	require.NoError(t, err)
+	assert.Equal(t, Modified, st.File("lib").Worktree)
+
+	// The ignore option of .gitmodules applies when not set in the config.
+	gitmodules, err := os.ReadFile(filepath.Join(dir, gitmodulesFile))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, gitmodulesFile), append(gitmodules, "\tignore = all\n"...), 0o644))
+	commitAll(t, w)
+	commitTestFile(t, sw, "lib.go", "package lib", "revert lib")
+
+	sub, err = w.Submodule("lib")
+	require.NoError(t, err)
+	status, err = sub.Status()
+	require.NoError(t, err)
+	assert.Equal(t, config.SubmoduleIgnoreAll, status.Ignore)
+	assert.True(t, status.HasNewCommits())
+	assert.False(t, status.IsModified())
+
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+}
+
+func TestSubmoduleLog(t *testing.T) {
+	w, _, libDir := newSubmoduleTestRepository(t)
+	_, err := w.AddSubmodule(libDir, "lib", nil)
+	require.NoError(t, err)
+	from := commitAll(t, w)
+
+	sw := newSubmoduleWorktree(t, w, "lib")
+	base, err := sw.r.Head()
+	require.NoError(t, err)
+	commitTestFile(t, sw, "a.go", "a", "add a")
+	commitTestFile(t, sw, "b.go", "b", "add b\n\nwith a body")
+	to := commitAll(t, w)
+
+	summary, err := w.SubmoduleLog("lib", base.Hash(), mustHead(t, sw.r))
+	require.NoError(t, err)
+	assert.True(t, summary.FastForward)
+	require.Len(t, summary.Commits, 2)
+	assert.Equal(t, "add b", summary.Commits[0].Subject)
+	assert.Equal(t, "add a", summary.Commits[1].Subject)
+
+	fromCommit, err := w.r.CommitObject(from)
+	require.NoError(t, err)
+	toCommit, err := w.r.CommitObject(to)
+	require.NoError(t, err)
+	patch, err := fromCommit.Patch(toCommit)
+	require.NoError(t, err)
+
+	head := mustHead(t, sw.r)
+	assert.Equal(t, "diff --git a/lib b/lib\n"+
+		"index "+base.Hash().String()+".."+head.String()+" 160000\n"+
+		"--- a/lib\n+++ b/lib\n@@ -1 +1 @@\n"+
+		"-Subproject commit "+base.Hash().String()+"\n"+
+		"+Subproject commit "+head.String()+"\n", patch.String())
+
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, fdiff.NewUnifiedEncoder(buf, fdiff.DefaultContextLines).SetSubmoduleLog(w.SubmoduleLog).Encode(patch))
+	assert.Equal(t, "Submodule lib "+base.Hash().String()[:7]+".."+head.String()[:7]+":\n"+
+		"  > add b\n  > add a\n", buf.String())
+
+	// Rewinding shows the commits dropped.
+	summary, err = w.SubmoduleLog("lib", head, base.Hash())
+	require.NoError(t, err)
+	assert.True(t, summary.Rewind)
+	require.Len(t, summary.Commits, 2)
+	assert.True(t, summary.Commits[0].Left)
+
+	summary, err = w.SubmoduleLog("lib", head, plumbing.NewHash("0123456789012345678901234567890123456789"))
+	require.NoError(t, err)
+	assert.True(t, summary.NotPresent)
+}
+
+func mustHead(t *testing.T, r *Repository) plumbing.Hash {
+	t.Helper()
+
+	head, err := r.Head()
+	require.NoError(t, err)
+	return head.Hash()
+}
@@ -0,0 +1,163 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v6/utils/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+func TestParseAlgorithm(t *testing.T) {
+	for name, expected := range map[string]diff.Algorithm{
+		"myers":     diff.Myers,
+		"default":   diff.Myers,
+		"minimal":   diff.Minimal,
+		"Patience":  diff.Patience,
+		"histogram": diff.Histogram,
+	} {
+		alg, err := diff.ParseAlgorithm(name)
+		require.NoError(t, err)
+		assert.Equal(t, expected, alg, name)
+	}
+
+	_, err := diff.ParseAlgorithm("foo")
+	assert.ErrorIs(t, err, diff.ErrUnknownAlgorithm)
+	assert.Equal(t, "histogram", diff.Histogram.String())
+}
+
+// The expected diffs are the ones of git diff with the same options.
+func TestDoWithOptionsAlgorithms(t *testing.T) {
+	src := "f\n\t\ty\n\na\n"
+	dst := "\n\n\t\ty\nf\nfunc f() {\n\n\t\ty\n"
+
+	for _, tc := range []struct {
+		alg      diff.Algorithm
+		expected []diffmatchpatch.Diff
+	}{{
+		alg: diff.Myers,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffDelete, Text: "f\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "\n\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "\t\ty\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "f\nfunc f() {\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "\n"},
+			{Type: diffmatchpatch.DiffDelete, Text: "a\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "\t\ty\n"},
+		},
+	}, {
+		alg: diff.Patience,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffInsert, Text: "\n\n\t\ty\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "f\n"},
+			{Type: diffmatchpatch.DiffDelete, Text: "\t\ty\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "func f() {\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "\n"},
+			{Type: diffmatchpatch.DiffDelete, Text: "a\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "\t\ty\n"},
+		},
+	}, {
+		alg: diff.Histogram,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffDelete, Text: "f\n\t\ty\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "\n"},
+			{Type: diffmatchpatch.DiffDelete, Text: "a\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "\n\t\ty\nf\nfunc f() {\n\n\t\ty\n"},
+		},
+	}} {
+		diffs := diff.DoWithOptions(src, dst, &diff.Options{Algorithm: tc.alg, IndentHeuristic: true})
+		assert.Equal(t, tc.expected, diffs, tc.alg.String())
+		assert.Equal(t, src, diff.Src(diffs))
+		assert.Equal(t, dst, diff.Dst(diffs))
+	}
+}
+
+func TestDoWithOptionsIndentHeuristic(t *testing.T) {
+	src := "}\na\nfunc f() {\n\treturn\n\treturn\n\tx\n{\n"
+	dst := "a\nfunc f() {\n\treturn\n\tx\n{\n"
+
+	diffs := diff.DoWithOptions(src, dst, &diff.Options{IndentHeuristic: true})
+	assert.Equal(t, []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffDelete, Text: "}\n"},
+		{Type: diffmatchpatch.DiffEqual, Text: "a\nfunc f() {\n"},
+		{Type: diffmatchpatch.DiffDelete, Text: "\treturn\n"},
+		{Type: diffmatchpatch.DiffEqual, Text: "\treturn\n\tx\n{\n"},
+	}, diffs)
+
+	diffs = diff.DoWithOptions(src, dst, &diff.Options{})
+	assert.Equal(t, []diffmatchpatch.Diff{
+		{Type: diffmatchpatch.DiffDelete, Text: "}\n"},
+		{Type: diffmatchpatch.DiffEqual, Text: "a\nfunc f() {\n\treturn\n"},
+		{Type: diffmatchpatch.DiffDelete, Text: "\treturn\n"},
+		{Type: diffmatchpatch.DiffEqual, Text: "\tx\n{\n"},
+	}, diffs)
+}
+
+func TestDoWithOptionsMinimal(t *testing.T) {
+	src := "a\nb\nc\nd\ne\n"
+	dst := "a\nc\nd\nb\ne\n"
+
+	diffs := diff.DoWithOptions(src, dst, &diff.Options{Algorithm: diff.Minimal})
+	assert.Equal(t, src, diff.Src(diffs))
+	assert.Equal(t, dst, diff.Dst(diffs))
+	assert.Len(t, diffs, 5)
+}
+
+func TestDoWithOptionsWhitespace(t *testing.T) {
+	src := "a b\nc\r\nd \n"
+	dst := "a  b\nc\nd\n"
+
+	for _, tc := range []struct {
+		ws       diff.Whitespace
+		expected []diffmatchpatch.Diff
+	}{{
+		ws: diff.IgnoreAllSpace,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffEqual, Text: dst},
+		},
+	}, {
+		ws: diff.IgnoreSpaceChange,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffEqual, Text: dst},
+		},
+	}, {
+		ws: diff.IgnoreSpaceAtEOL,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffDelete, Text: "a b\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "a  b\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "c\nd\n"},
+		},
+	}, {
+		ws: diff.IgnoreCRAtEOL,
+		expected: []diffmatchpatch.Diff{
+			{Type: diffmatchpatch.DiffDelete, Text: "a b\n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "a  b\n"},
+			{Type: diffmatchpatch.DiffEqual, Text: "c\n"},
+			{Type: diffmatchpatch.DiffDelete, Text: "d \n"},
+			{Type: diffmatchpatch.DiffInsert, Text: "d\n"},
+		},
+	}} {
+		diffs := diff.DoWithOptions(src, dst, &diff.Options{Whitespace: tc.ws})
+		assert.Equal(t, tc.expected, diffs)
+		assert.Equal(t, dst, diff.Dst(diffs))
+	}
+}
+
+func TestEdits(t *testing.T) {
+	src := []string{"a\n", "b\n", "c\n", "d\n"}
+	dst := []string{"a\n", "\n", "b\n", "x\n", "d\n"}
+
+	assert.Equal(t, []diff.Edit{
+		{Pos1: 1, Len1: 0, Pos2: 1, Len2: 1},
+		{Pos1: 2, Len1: 1, Pos2: 3, Len2: 1},
+	}, diff.Edits(src, dst, nil))
+
+	assert.Equal(t, []diff.Edit{
+		{Pos1: 1, Len1: 0, Pos2: 1, Len2: 1, Ignore: true},
+		{Pos1: 2, Len1: 1, Pos2: 3, Len2: 1},
+	}, diff.Edits(src, dst, &diff.Options{Whitespace: diff.IgnoreBlankLines}))
+
+	assert.Nil(t, diff.Edits(src, src, nil))
+}
@@ -0,0 +1,315 @@
+package diff
+
+const (
+	// maxIndent and maxBlanks bound the measures of the indent heuristic.
+	maxIndent = 200
+	maxBlanks = 20
+	// indentHeuristicMaxSliding is the maximum number of positions of a
+	// group of changes tried by the indent heuristic.
+	indentHeuristicMaxSliding = 100
+
+	// The weights of the indent heuristic, tuned by git on real world
+	// repositories.
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+)
+
+// group is a group of changed lines, from start to end exclusive, possibly
+// empty.
+type group struct {
+	start, end int
+}
+
+func (f *xfile) groupInit() group {
+	g := group{}
+	for f.changed(g.end) {
+		g.end++
+	}
+
+	return g
+}
+
+// next moves g to the next group, reporting whether there is one.
+func (f *xfile) next(g *group) bool {
+	if g.end == len(f.recs) {
+		return false
+	}
+
+	g.start = g.end + 1
+	for g.end = g.start; f.changed(g.end); g.end++ {
+	}
+
+	return true
+}
+
+// previous moves g to the previous group, reporting whether there is one.
+func (f *xfile) previous(g *group) bool {
+	if g.start == 0 {
+		return false
+	}
+
+	g.end = g.start - 1
+	for g.start = g.end; f.changed(g.start - 1); g.start-- {
+	}
+
+	return true
+}
+
+// slideDown moves g down by one line if possible, merging it with the
+// next group when they touch.
+func (f *xfile) slideDown(g *group) bool {
+	if g.end < len(f.recs) && f.ha[g.start] == f.ha[g.end] {
+		f.setChanged(g.start, false)
+		f.setChanged(g.end, true)
+		g.start++
+		g.end++
+		for f.changed(g.end) {
+			g.end++
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// slideUp moves g up by one line if possible, merging it with the previous
+// group when they touch.
+func (f *xfile) slideUp(g *group) bool {
+	if g.start > 0 && f.ha[g.start-1] == f.ha[g.end-1] {
+		g.start--
+		g.end--
+		f.setChanged(g.start, true)
+		f.setChanged(g.end, false)
+		for f.changed(g.start - 1) {
+			g.start--
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// compact moves the groups of changes of f, which can slide when they
+// start and end with the same line, to merge them with other groups, to
+// align them with the groups of the other file o, or to the position the
+// indent heuristic prefers.
+func (f *xfile) compact(o *xfile, indentHeuristic bool) {
+	g, og := f.groupInit(), o.groupInit()
+	for {
+		if g.end != g.start {
+			var earliestEnd, endMatchingOther int
+			for {
+				size := g.end - g.start
+				endMatchingOther = -1
+
+				for f.slideUp(&g) {
+					o.previous(&og)
+				}
+
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+
+				for f.slideDown(&g) {
+					o.next(&og)
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+
+				if size == g.end-g.start {
+					break
+				}
+			}
+
+			switch {
+			case g.end == earliestEnd:
+				// The group cannot slide.
+			case endMatchingOther != -1:
+				for og.end == og.start {
+					f.slideUp(&g)
+					o.previous(&og)
+				}
+			case indentHeuristic:
+				size := g.end - g.start
+				shift := max(earliestEnd, g.end-size-1, g.end-indentHeuristicMaxSliding)
+				bestShift := -1
+				var best splitScore
+				for ; shift <= g.end; shift++ {
+					var score splitScore
+					score.add(f.measureSplit(shift))
+					score.add(f.measureSplit(shift - size))
+					if bestShift == -1 || score.cmp(best) <= 0 {
+						best = score
+						bestShift = shift
+					}
+				}
+
+				for g.end > bestShift {
+					f.slideUp(&g)
+					o.previous(&og)
+				}
+			}
+		}
+
+		if !f.next(&g) {
+			break
+		}
+
+		o.next(&og)
+	}
+}
+
+// splitMeasurement describes the lines around a split between two lines.
+type splitMeasurement struct {
+	endOfFile  bool
+	indent     int
+	preBlank   int
+	preIndent  int
+	postBlank  int
+	postIndent int
+}
+
+// splitScore is the badness of a split, the lower the better.
+type splitScore struct {
+	effectiveIndent int
+	penalty         int
+}
+
+// indent returns the indentation width of the line, -1 for a blank line.
+func indent(line string) int {
+	ret := 0
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case ' ':
+			ret++
+		case '\t':
+			ret += 8 - ret%8
+		case '\n', '\r':
+		default:
+			return ret
+		}
+
+		if ret >= maxIndent {
+			return maxIndent
+		}
+	}
+
+	return -1
+}
+
+// measureSplit measures the split before the line split.
+func (f *xfile) measureSplit(split int) splitMeasurement {
+	var m splitMeasurement
+	if split >= len(f.recs) {
+		m.endOfFile = true
+		m.indent = -1
+	} else {
+		m.indent = indent(f.recs[split])
+	}
+
+	m.preIndent = -1
+	for i := split - 1; i >= 0; i-- {
+		m.preIndent = indent(f.recs[i])
+		if m.preIndent != -1 {
+			break
+		}
+
+		m.preBlank++
+		if m.preBlank == maxBlanks {
+			m.preIndent = 0
+			break
+		}
+	}
+
+	m.postIndent = -1
+	for i := split + 1; i < len(f.recs); i++ {
+		m.postIndent = indent(f.recs[i])
+		if m.postIndent != -1 {
+			break
+		}
+
+		m.postBlank++
+		if m.postBlank == maxBlanks {
+			m.postIndent = 0
+			break
+		}
+	}
+
+	return m
+}
+
+// add adds the badness of the split m to s.
+func (s *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 {
+		s.penalty += startOfFilePenalty
+	}
+
+	if m.endOfFile {
+		s.penalty += endOfFilePenalty
+	}
+
+	postBlank := 0
+	if m.indent == -1 {
+		postBlank = 1 + m.postBlank
+	}
+
+	totalBlank := m.preBlank + postBlank
+	s.penalty += totalBlankWeight * totalBlank
+	s.penalty += postBlankWeight * postBlank
+
+	indent := m.indent
+	if indent == -1 {
+		indent = m.postIndent
+	}
+
+	anyBlanks := totalBlank != 0
+	s.effectiveIndent += indent
+
+	switch {
+	case indent == -1, m.preIndent == -1, indent == m.preIndent:
+	case indent > m.preIndent:
+		s.penalty += pick(anyBlanks, relativeIndentWithBlankPenalty, relativeIndentPenalty)
+	case m.postIndent != -1 && m.postIndent > indent:
+		// An outdented line followed by a more indented one starts a
+		// block.
+		s.penalty += pick(anyBlanks, relativeOutdentWithBlankPenalty, relativeOutdentPenalty)
+	default:
+		// Otherwise it likely ends a block.
+		s.penalty += pick(anyBlanks, relativeDedentWithBlankPenalty, relativeDedentPenalty)
+	}
+}
+
+// cmp compares the scores, returning a negative value when s is better
+// than o.
+func (s splitScore) cmp(o splitScore) int {
+	cmpIndents := 0
+	switch {
+	case s.effectiveIndent > o.effectiveIndent:
+		cmpIndents = 1
+	case s.effectiveIndent < o.effectiveIndent:
+		cmpIndents = -1
+	}
+
+	return indentWeight*cmpIndents + (s.penalty - o.penalty)
+}
+
+func pick(cond bool, a, b int) int {
+	if cond {
+		return a
+	}
+
+	return b
+}
@@ -1,25 +1,176 @@
 // Package diff implements line oriented diffs, similar to the ancient
 // Unix diff command.
 //
-// The current implementation is just a wrapper around Sergi's
-// go-diff/diffmatchpatch library, which is a go port of Neil
-// Fraser's google-diff-match-patch code
+// The diffs are computed by a port of git's xdiff, offering the Myers,
+// minimal, patience and histogram algorithms and git's indent heuristic,
+// so that they give the same hunks as git diff. DoWithTimeout is a wrapper
+// around Sergi's go-diff/diffmatchpatch library, which is a go port of Neil
+// Fraser's google-diff-match-patch code.
 package diff
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/sergi/go-diff/diffmatchpatch"
 )
 
+// ErrUnknownAlgorithm is returned by ParseAlgorithm for an unknown diff
+// algorithm.
+var ErrUnknownAlgorithm = errors.New("unknown diff algorithm")
+
+// Algorithm is a line diff algorithm.
+type Algorithm int
+
+const (
+	// Myers is the Myers algorithm, with the heuristics git uses to cut
+	// the search in large diffs. It is git's default algorithm.
+	Myers Algorithm = iota
+	// Minimal is the Myers algorithm, always finding the smallest diff.
+	Minimal
+	// Patience is the patience algorithm, which matches the lines unique
+	// in both files first.
+	Patience
+	// Histogram is the histogram algorithm, an extension of the patience
+	// algorithm to the lines which occur the least.
+	Histogram
+)
+
+// String returns the name of the algorithm, as used by git.
+func (a Algorithm) String() string {
+	switch a {
+	case Myers:
+		return "myers"
+	case Minimal:
+		return "minimal"
+	case Patience:
+		return "patience"
+	case Histogram:
+		return "histogram"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+// ParseAlgorithm parses the name of an algorithm as given to the
+// diff.algorithm config option and the --diff-algorithm flag of git.
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch strings.ToLower(name) {
+	case "myers", "default":
+		return Myers, nil
+	case "minimal":
+		return Minimal, nil
+	case "patience":
+		return Patience, nil
+	case "histogram":
+		return Histogram, nil
+	default:
+		return 0, fmt.Errorf("%w: %s", ErrUnknownAlgorithm, name)
+	}
+}
+
+// Options describes how a diff is computed.
+type Options struct {
+	// Algorithm is the diff algorithm.
+	Algorithm Algorithm
+	// IndentHeuristic shifts the groups of added or deleted lines which
+	// can slide to the position that is the easiest to read, based on
+	// the indentation and the blank lines around, like git does by
+	// default.
+	IndentHeuristic bool
+	// Whitespace are the whitespace differences ignored when comparing
+	// lines.
+	Whitespace Whitespace
+}
+
+// Whitespace are flags ignoring whitespace differences between lines.
+type Whitespace uint
+
+const (
+	// IgnoreSpaceAtEOL ignores the whitespace at the end of the lines,
+	// like --ignore-space-at-eol.
+	IgnoreSpaceAtEOL Whitespace = 1 << iota
+	// IgnoreSpaceChange ignores changes in the amount of whitespace, like
+	// --ignore-space-change.
+	IgnoreSpaceChange
+	// IgnoreAllSpace ignores the whitespace when comparing lines, like
+	// --ignore-all-space.
+	IgnoreAllSpace
+	// IgnoreCRAtEOL ignores the carriage returns at the end of the lines,
+	// like --ignore-cr-at-eol.
+	IgnoreCRAtEOL
+	// IgnoreBlankLines ignores the changes whose lines are all blank, like
+	// --ignore-blank-lines. It does not change the comparison of lines but
+	// marks the Edits to ignore.
+	IgnoreBlankLines
+)
+
+// compareFlags are the flags changing the comparison of lines.
+const compareFlags = IgnoreSpaceAtEOL | IgnoreSpaceChange | IgnoreAllSpace | IgnoreCRAtEOL
+
+// Edit is a change turning the source lines into the destination lines:
+// Len1 lines of the source from the line Pos1 are replaced by Len2 lines of
+// the destination from the line Pos2, lines being numbered from zero.
+type Edit struct {
+	Pos1, Len1 int
+	Pos2, Len2 int
+	// Ignore is set when the lines of the change are all blank and the
+	// IgnoreBlankLines flag is set.
+	Ignore bool
+}
+
+// DefaultOptions are the default options of git diff.
+var DefaultOptions = &Options{
+	Algorithm:       Myers,
+	IndentHeuristic: true,
+}
+
 // Do computes the (line oriented) modifications needed to turn the src
-// string into the dst string. The underlying algorithm is Meyers,
-// its complexity is O(N*d) where N is min(lines(src), lines(dst)) and d
-// is the size of the diff.
+// string into the dst string, with the DefaultOptions.
 func Do(src, dst string) (diffs []diffmatchpatch.Diff) {
-	// the default timeout is time.Second which may be too small under heavy load
-	return DoWithTimeout(src, dst, time.Hour)
+	return DoWithOptions(src, dst, DefaultOptions)
+}
+
+// DoWithOptions computes the (line oriented) modifications needed to turn
+// the src string into the dst string, with the given options. A nil o
+// means the DefaultOptions. When whitespace is ignored, the equal lines are
+// the ones of dst, like git shows them.
+func DoWithOptions(src, dst string, o *Options) (diffs []diffmatchpatch.Diff) {
+	if o == nil {
+		o = DefaultOptions
+	}
+
+	return diffEnv(splitLines(src), splitLines(dst), o).diffs()
+}
+
+// Edits computes the changes needed to turn the src lines into the dst
+// lines, with the given options. A nil o means the DefaultOptions. The
+// lines are compared with their line feed, if any.
+func Edits(src, dst []string, o *Options) []Edit {
+	if o == nil {
+		o = DefaultOptions
+	}
+
+	e := diffEnv(src, dst, o)
+	edits := e.edits()
+	if o.Whitespace&IgnoreBlankLines != 0 {
+		for i := range edits {
+			edits[i].Ignore = e.blank(edits[i])
+		}
+	}
+
+	return edits
+}
+
+func diffEnv(src, dst []string, o *Options) *xenv {
+	e := prepareEnv(src, dst, o.Algorithm, o.Whitespace)
+	e.do(o)
+	e.f1.compact(&e.f2, o.IndentHeuristic)
+	e.f2.compact(&e.f1, o.IndentHeuristic)
+	return e
 }
 
 // DoWithTimeout computes the (line oriented) modifications needed to turn the src
@@ -28,7 +179,8 @@ func Do(src, dst string) (diffs []diffmatchpatch.Diff) {
 // is exceeded, the parts of the strings which were not considered are turned into
 // a bulk delete+insert and the half-baked suboptimal result is returned at once.
 // The underlying algorithm is Meyers, its complexity is O(N*d) where N is
-// min(lines(src), lines(dst)) and d is the size of the diff.
+// min(lines(src), lines(dst)) and d is the size of the diff. Unlike Do, it
+// is computed by the diffmatchpatch library.
 func DoWithTimeout(src, dst string, timeout time.Duration) (diffs []diffmatchpatch.Diff) {
 	dmp := diffmatchpatch.New()
 	dmp.DiffTimeout = timeout
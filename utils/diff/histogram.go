@@ -0,0 +1,197 @@
+package diff
+
+// maxChainLength is the number of occurrences of a line after which it is
+// not considered by the histogram algorithm.
+const maxChainLength = 64
+
+// histogramRecord is the occurrences of a line in a range of the first
+// file.
+type histogramRecord struct {
+	// ptr is the first occurrence and cnt the number of occurrences.
+	ptr, cnt int
+}
+
+// histogramIndex indexes the lines of a range of the first file.
+type histogramIndex struct {
+	e       *xenv
+	records map[int]*histogramRecord
+	// lineMap and nextPtrs are the record and the next occurrence of the
+	// lines of the range, offset by ptrShift.
+	lineMap   []*histogramRecord
+	nextPtrs  []int
+	ptrShift  int
+	cnt       int
+	hasCommon bool
+}
+
+// region is a range of common lines, 1-based and inclusive.
+type region struct {
+	begin1, end1 int
+	begin2, end2 int
+}
+
+// histogram diffs the given ranges of lines, 1-based, recursively matching
+// the longest common range of lines which occur the least, and falling
+// back to the Myers algorithm when they all occur too often.
+func (e *xenv) histogram(line1, count1, line2, count2 int) {
+	for {
+		if count1 <= 0 && count2 <= 0 {
+			return
+		}
+
+		if count1 == 0 {
+			for ; count2 > 0; count2-- {
+				e.f2.setChanged(line2-1, true)
+				line2++
+			}
+
+			return
+		}
+
+		if count2 == 0 {
+			for ; count1 > 0; count1-- {
+				e.f1.setChanged(line1-1, true)
+				line1++
+			}
+
+			return
+		}
+
+		var lcs region
+		if e.findLCS(&lcs, line1, count1, line2, count2) {
+			e.fallBack(line1, count1, line2, count2)
+			return
+		}
+
+		if lcs.begin1 == 0 && lcs.begin2 == 0 {
+			for i := 0; i < count1; i++ {
+				e.f1.setChanged(line1-1+i, true)
+			}
+
+			for i := 0; i < count2; i++ {
+				e.f2.setChanged(line2-1+i, true)
+			}
+
+			return
+		}
+
+		e.histogram(line1, lcs.begin1-line1, line2, lcs.begin2-line2)
+
+		end1, end2 := line1+count1-1, line2+count2-1
+		count1, line1 = end1-lcs.end1, lcs.end1+1
+		count2, line2 = end2-lcs.end2, lcs.end2+1
+	}
+}
+
+// findLCS finds the longest common range of lines with the fewest
+// occurrences. It reports whether the Myers algorithm should be used
+// instead, when the common lines all occur too often.
+func (e *xenv) findLCS(lcs *region, line1, count1, line2, count2 int) bool {
+	index := &histogramIndex{
+		e:        e,
+		records:  make(map[int]*histogramRecord),
+		lineMap:  make([]*histogramRecord, count1),
+		nextPtrs: make([]int, count1),
+		ptrShift: line1,
+	}
+
+	index.scanA(line1, count1)
+	index.cnt = maxChainLength + 1
+
+	for bPtr := line2; bPtr <= line2+count2-1; {
+		bPtr = index.tryLCS(lcs, bPtr, line1, count1, line2, count2)
+	}
+
+	return index.hasCommon && maxChainLength < index.cnt
+}
+
+// scanA indexes the lines of the range of the first file.
+func (i *histogramIndex) scanA(line1, count1 int) {
+	for ptr := line1 + count1 - 1; line1 <= ptr; ptr-- {
+		ha := i.e.f1.ha[ptr-1]
+		if rec, ok := i.records[ha]; ok {
+			i.nextPtrs[ptr-i.ptrShift] = rec.ptr
+			rec.ptr = ptr
+			rec.cnt++
+			i.lineMap[ptr-i.ptrShift] = rec
+			continue
+		}
+
+		rec := &histogramRecord{ptr: ptr, cnt: 1}
+		i.records[ha] = rec
+		i.lineMap[ptr-i.ptrShift] = rec
+	}
+}
+
+// tryLCS extends the occurrences of the line bPtr of the second file to
+// common ranges, keeping the best one in lcs. It returns the next line of
+// the second file to try.
+func (i *histogramIndex) tryLCS(lcs *region, bPtr, line1, count1, line2, count2 int) int {
+	bNext := bPtr + 1
+	ha1, ha2 := i.e.f1.ha, i.e.f2.ha
+	cmp := func(l1, l2 int) bool { return ha1[l1-1] == ha2[l2-1] }
+
+	rec, ok := i.records[ha2[bPtr-1]]
+	if !ok {
+		return bNext
+	}
+
+	if rec.cnt > i.cnt {
+		i.hasCommon = true
+		return bNext
+	}
+
+	i.hasCommon = true
+	end1, end2 := line1+count1-1, line2+count2-1
+	for as := rec.ptr; ; {
+		np := i.nextPtrs[as-i.ptrShift]
+		bs := bPtr
+		ae, be := as, bs
+		rc := rec.cnt
+
+		for line1 < as && line2 < bs && cmp(as-1, bs-1) {
+			as--
+			bs--
+			if 1 < rc {
+				rc = min(rc, i.lineMap[as-i.ptrShift].cnt)
+			}
+		}
+
+		for ae < end1 && be < end2 && cmp(ae+1, be+1) {
+			ae++
+			be++
+			if 1 < rc {
+				rc = min(rc, i.lineMap[ae-i.ptrShift].cnt)
+			}
+		}
+
+		if bNext <= be {
+			bNext = be + 1
+		}
+
+		if lcs.end1-lcs.begin1 < ae-as || rc < i.cnt {
+			lcs.begin1, lcs.begin2 = as, bs
+			lcs.end1, lcs.end2 = ae, be
+			i.cnt = rc
+		}
+
+		if np == 0 {
+			break
+		}
+
+		for np <= ae {
+			np = i.nextPtrs[np-i.ptrShift]
+			if np == 0 {
+				break
+			}
+		}
+
+		if np == 0 {
+			break
+		}
+
+		as = np
+	}
+
+	return bNext
+}
@@ -0,0 +1,142 @@
+package diff
+
+import (
+	"strings"
+)
+
+// conflictMarkerSize is the length of the conflict markers, like git.
+const conflictMarkerSize = 7
+
+// Merge merges the changes turning base into ours and into theirs, line by
+// line, like git merge-file. The changes overlapping or touching each other
+// conflict unless they are the same: the merged content then holds both
+// versions between conflict markers, labeled with ourLabel and theirLabel,
+// their common lines being left out of the markers.
+func Merge(base, ours, theirs, ourLabel, theirLabel string) (merged string, conflicts bool) {
+	baseLines := splitLines(base)
+	ourLines, theirLines := splitLines(ours), splitLines(theirs)
+	ourEdits := Edits(baseLines, ourLines, nil)
+	theirEdits := Edits(baseLines, theirLines, nil)
+
+	sb := &strings.Builder{}
+	pos, i, j := 0, 0, 0
+	for i < len(ourEdits) || j < len(theirEdits) {
+		switch {
+		case j == len(theirEdits) || (i < len(ourEdits) && editEnd(ourEdits[i]) < theirEdits[j].Pos1):
+			writeLines(sb, baseLines[pos:ourEdits[i].Pos1])
+			writeLines(sb, sideLines(baseLines, ourLines, ourEdits[i:i+1], ourEdits[i].Pos1, editEnd(ourEdits[i])))
+			pos = editEnd(ourEdits[i])
+			i++
+			continue
+		case i == len(ourEdits) || editEnd(theirEdits[j]) < ourEdits[i].Pos1:
+			writeLines(sb, baseLines[pos:theirEdits[j].Pos1])
+			writeLines(sb, sideLines(baseLines, theirLines, theirEdits[j:j+1], theirEdits[j].Pos1, editEnd(theirEdits[j])))
+			pos = editEnd(theirEdits[j])
+			j++
+			continue
+		}
+
+		// The changes overlapping with each other are merged in a single
+		// region of base.
+		start := min(ourEdits[i].Pos1, theirEdits[j].Pos1)
+		end := max(editEnd(ourEdits[i]), editEnd(theirEdits[j]))
+		i0, j0 := i, j
+		i, j = i+1, j+1
+		for {
+			if i < len(ourEdits) && ourEdits[i].Pos1 <= end {
+				end = max(end, editEnd(ourEdits[i]))
+				i++
+			} else if j < len(theirEdits) && theirEdits[j].Pos1 <= end {
+				end = max(end, editEnd(theirEdits[j]))
+				j++
+			} else {
+				break
+			}
+		}
+
+		writeLines(sb, baseLines[pos:start])
+		pos = end
+		ourSide := sideLines(baseLines, ourLines, ourEdits[i0:i], start, end)
+		theirSide := sideLines(baseLines, theirLines, theirEdits[j0:j], start, end)
+		if equalLines(ourSide, theirSide) {
+			writeLines(sb, ourSide)
+			continue
+		}
+
+		// The common lines of both sides are not part of the conflict.
+		prefix := 0
+		for prefix < len(ourSide) && prefix < len(theirSide) && ourSide[prefix] == theirSide[prefix] {
+			prefix++
+		}
+
+		suffix := 0
+		for suffix < len(ourSide)-prefix && suffix < len(theirSide)-prefix &&
+			ourSide[len(ourSide)-1-suffix] == theirSide[len(theirSide)-1-suffix] {
+			suffix++
+		}
+
+		conflicts = true
+		writeLines(sb, ourSide[:prefix])
+		writeMarker(sb, '<', ourLabel)
+		writeLines(sb, ourSide[prefix:len(ourSide)-suffix])
+		writeMarker(sb, '=', "")
+		writeLines(sb, theirSide[prefix:len(theirSide)-suffix])
+		writeMarker(sb, '>', theirLabel)
+		writeLines(sb, ourSide[len(ourSide)-suffix:])
+	}
+
+	writeLines(sb, baseLines[pos:])
+	return sb.String(), conflicts
+}
+
+func editEnd(e Edit) int {
+	return e.Pos1 + e.Len1
+}
+
+// sideLines returns the lines of a side replacing the lines of base from
+// start to end, given the edits of the side within them.
+func sideLines(base, side []string, edits []Edit, start, end int) []string {
+	var lines []string
+	pos := start
+	for _, e := range edits {
+		lines = append(lines, base[pos:e.Pos1]...)
+		lines = append(lines, side[e.Pos2:e.Pos2+e.Len2]...)
+		pos = editEnd(e)
+	}
+
+	return append(lines, base[pos:end]...)
+}
+
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeLines(sb *strings.Builder, lines []string) {
+	for _, l := range lines {
+		sb.WriteString(l)
+	}
+}
+
+// writeMarker writes a conflict marker, on its own line.
+func writeMarker(sb *strings.Builder, c byte, label string) {
+	if s := sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		sb.WriteByte('\n')
+	}
+
+	sb.WriteString(strings.Repeat(string(c), conflictMarkerSize))
+	if label != "" {
+		sb.WriteString(" " + label)
+	}
+
+	sb.WriteByte('\n')
+}
@@ -0,0 +1,62 @@
+package diff_test
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v6/utils/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	base := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	for _, tc := range []struct {
+		name, ours, theirs, merged string
+		conflicts                  bool
+	}{
+		{
+			name:   "disjoint",
+			ours:   "one\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			theirs: "1\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+			merged: "one\n2\n3\n4\n5\n6\n7\n8\nnine\n",
+		},
+		{
+			name:   "same change",
+			ours:   "1\n2\n3\nfour\n5\n6\n7\n8\n9\n",
+			theirs: "1\n2\n3\nfour\n5\n6\n7\n8\n9\n",
+			merged: "1\n2\n3\nfour\n5\n6\n7\n8\n9\n",
+		},
+		{
+			name:   "one side",
+			ours:   base,
+			theirs: "1\n2\n3\n4\n5\n6\n9\n",
+			merged: "1\n2\n3\n4\n5\n6\n9\n",
+		},
+		{
+			name:      "conflict",
+			ours:      "1\n2\n3\nFOUR\n5\n6\n7\n8\n9\n",
+			theirs:    "1\n2\n3\nfour\n5\n6\n7\n8\n9\n",
+			merged:    "1\n2\n3\n<<<<<<< ours\nFOUR\n=======\nfour\n>>>>>>> theirs\n5\n6\n7\n8\n9\n",
+			conflicts: true,
+		},
+		{
+			name:      "adjacent",
+			ours:      "1\n2\n3\nfour\n5\n6\n7\n8\n9\n",
+			theirs:    "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			merged:    "1\n2\n3\n<<<<<<< ours\nfour\n5\n=======\n4\nfive\n>>>>>>> theirs\n6\n7\n8\n9\n",
+			conflicts: true,
+		},
+		{
+			name:      "common lines",
+			ours:      "1\n2\nx\nthree\ny\n4\n5\n6\n7\n8\n9\n",
+			theirs:    "1\n2\nx\nTHREE\ny\n4\n5\n6\n7\n8\n9\n",
+			merged:    "1\n2\nx\n<<<<<<< ours\nthree\n=======\nTHREE\n>>>>>>> theirs\ny\n4\n5\n6\n7\n8\n9\n",
+			conflicts: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			merged, conflicts := diff.Merge(base, tc.ours, tc.theirs, "ours", "theirs")
+			assert.Equal(t, tc.merged, merged)
+			assert.Equal(t, tc.conflicts, conflicts)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package diff
+
+import "math"
+
+const (
+	// maxCostMin is the minimum edit cost after which the search of the
+	// middle snake gives up and takes the furthest reaching path.
+	maxCostMin = 256
+	// heurMinCost is the edit cost after which good snakes are looked for.
+	heurMinCost = 256
+	// snakeCnt is the length of a snake to be considered good.
+	snakeCnt = 20
+	// kHeur is the factor of the edit cost over which a diagonal is
+	// considered interesting.
+	kHeur = 4
+)
+
+// myersEnv holds the state of the Myers algorithm.
+type myersEnv struct {
+	ha1, ha2 []int
+	// kvdf and kvdb are the furthest reaching forward and backward paths
+	// of the diagonals, offset by off.
+	kvdf, kvdb []int
+	off        int
+	mxcost     int
+}
+
+// split is a split point of the comparison of two ranges of lines.
+type split struct {
+	i1, i2       int
+	minLo, minHi bool
+}
+
+// myers diffs the lines left by cleanupRecords with the Myers algorithm.
+// When minimal is false, heuristics cut the search in large diffs.
+func (e *xenv) myers(minimal bool) {
+	n1, n2 := len(e.f1.rha), len(e.f2.rha)
+	ndiags := n1 + n2 + 3
+	kvd := make([]int, 2*ndiags)
+	m := &myersEnv{
+		ha1:    e.f1.rha,
+		ha2:    e.f2.rha,
+		kvdf:   kvd[:ndiags],
+		kvdb:   kvd[ndiags:],
+		off:    n2 + 1,
+		mxcost: max(bogoSqrt(ndiags), maxCostMin),
+	}
+
+	m.compare(e, 0, n1, 0, n2, minimal)
+}
+
+// compare compares the given ranges of lines, recursively splitting them
+// on their middle snake.
+func (m *myersEnv) compare(e *xenv, off1, lim1, off2, lim2 int, needMin bool) {
+	ha1, ha2 := m.ha1, m.ha2
+	for off1 < lim1 && off2 < lim2 && ha1[off1] == ha2[off2] {
+		off1++
+		off2++
+	}
+
+	for off1 < lim1 && off2 < lim2 && ha1[lim1-1] == ha2[lim2-1] {
+		lim1--
+		lim2--
+	}
+
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			e.f2.setChanged(e.f2.rindex[off2], true)
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			e.f1.setChanged(e.f1.rindex[off1], true)
+		}
+	default:
+		spl := m.split(off1, lim1, off2, lim2, needMin)
+		m.compare(e, off1, spl.i1, off2, spl.i2, spl.minLo)
+		m.compare(e, spl.i1, lim1, spl.i2, lim2, spl.minHi)
+	}
+}
+
+// split finds the middle snake of the given ranges, or a good enough split
+// point when the search gets too costly.
+func (m *myersEnv) split(off1, lim1, off2, lim2 int, needMin bool) split {
+	ha1, ha2 := m.ha1, m.ha2
+	kvdf := func(d int) *int { return &m.kvdf[d+m.off] }
+	kvdb := func(d int) *int { return &m.kvdb[d+m.off] }
+
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+
+	*kvdf(fmid) = off1
+	*kvdb(bmid) = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+
+		if fmin > dmin {
+			fmin--
+			*kvdf(fmin - 1) = -1
+		} else {
+			fmin++
+		}
+
+		if fmax < dmax {
+			fmax++
+			*kvdf(fmax + 1) = -1
+		} else {
+			fmax--
+		}
+
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if *kvdf(d - 1) >= *kvdf(d + 1) {
+				i1 = *kvdf(d - 1) + 1
+			} else {
+				i1 = *kvdf(d + 1)
+			}
+
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && ha1[i1] == ha2[i2] {
+				i1++
+				i2++
+			}
+
+			if i1-prev1 > snakeCnt {
+				gotSnake = true
+			}
+
+			*kvdf(d) = i1
+			if odd && bmin <= d && d <= bmax && *kvdb(d) <= i1 {
+				return split{i1: i1, i2: i2, minLo: true, minHi: true}
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			*kvdb(bmin - 1) = math.MaxInt
+		} else {
+			bmin++
+		}
+
+		if bmax < dmax {
+			bmax++
+			*kvdb(bmax + 1) = math.MaxInt
+		} else {
+			bmax--
+		}
+
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if *kvdb(d - 1) < *kvdb(d + 1) {
+				i1 = *kvdb(d - 1)
+			} else {
+				i1 = *kvdb(d + 1) - 1
+			}
+
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && ha1[i1-1] == ha2[i2-1] {
+				i1--
+				i2--
+			}
+
+			if prev1-i1 > snakeCnt {
+				gotSnake = true
+			}
+
+			*kvdb(d) = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= *kvdf(d) {
+				return split{i1: i1, i2: i2, minLo: true, minHi: true}
+			}
+		}
+
+		if needMin {
+			continue
+		}
+
+		// If the edit cost is above the heuristic trigger and a good
+		// snake was found, look for a diagonal that went far enough,
+		// measured by its distance from the corner penalized by its
+		// distance from the middle diagonal.
+		if gotSnake && ec > heurMinCost {
+			var spl split
+			best := 0
+			for d := fmax; d >= fmin; d -= 2 {
+				dd := d - fmid
+				if dd < 0 {
+					dd = -dd
+				}
+
+				i1 := *kvdf(d)
+				i2 := i1 - d
+				v := (i1 - off1) + (i2 - off2) - dd
+				if v > kHeur*ec && v > best &&
+					off1+snakeCnt <= i1 && i1 < lim1 &&
+					off2+snakeCnt <= i2 && i2 < lim2 {
+					for k := 1; ha1[i1-k] == ha2[i2-k]; k++ {
+						if k == snakeCnt {
+							best = v
+							spl.i1, spl.i2 = i1, i2
+							break
+						}
+					}
+				}
+			}
+
+			if best > 0 {
+				spl.minLo, spl.minHi = true, false
+				return spl
+			}
+
+			for d := bmax; d >= bmin; d -= 2 {
+				dd := d - bmid
+				if dd < 0 {
+					dd = -dd
+				}
+
+				i1 := *kvdb(d)
+				i2 := i1 - d
+				v := (lim1 - i1) + (lim2 - i2) - dd
+				if v > kHeur*ec && v > best &&
+					off1 < i1 && i1 <= lim1-snakeCnt &&
+					off2 < i2 && i2 <= lim2-snakeCnt {
+					for k := 0; ha1[i1+k] == ha2[i2+k]; k++ {
+						if k == snakeCnt-1 {
+							best = v
+							spl.i1, spl.i2 = i1, i2
+							break
+						}
+					}
+				}
+			}
+
+			if best > 0 {
+				spl.minLo, spl.minHi = false, true
+				return spl
+			}
+		}
+
+		// Enough is enough: take the furthest reaching path.
+		if ec >= m.mxcost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := min(*kvdf(d), lim1)
+				i2 := i1 - d
+				if lim2 < i2 {
+					i1, i2 = lim2+d, lim2
+				}
+
+				if fbest < i1+i2 {
+					fbest, fbest1 = i1+i2, i1
+				}
+			}
+
+			bbest, bbest1 := math.MaxInt, math.MaxInt
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := max(off1, *kvdb(d))
+				i2 := i1 - d
+				if i2 < off2 {
+					i1, i2 = off2+d, off2
+				}
+
+				if i1+i2 < bbest {
+					bbest, bbest1 = i1+i2, i1
+				}
+			}
+
+			if (lim1+lim2)-bbest < fbest-(off1+off2) {
+				return split{i1: fbest1, i2: fbest - fbest1, minLo: true}
+			}
+
+			return split{i1: bbest1, i2: bbest - bbest1, minHi: true}
+		}
+	}
+}
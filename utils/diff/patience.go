@@ -0,0 +1,202 @@
+package diff
+
+// nonUnique marks the entries of lines which are not unique in one of the
+// files.
+const nonUnique = -1
+
+// patienceEntry is a line of the first file, with the line matching it in
+// the second file. Lines are 1-based, 0 meaning no line.
+type patienceEntry struct {
+	line1, line2   int
+	next, previous *patienceEntry
+}
+
+// patienceMap indexes the lines of ranges of both files.
+type patienceMap struct {
+	entries     map[int]*patienceEntry
+	first, last *patienceEntry
+	nr          int
+	hasMatches  bool
+}
+
+// patience diffs the given ranges of lines, 1-based, recursively matching
+// the lines which are unique in both ranges, and falling back to the Myers
+// algorithm when there are none.
+func (e *xenv) patience(line1, count1, line2, count2 int) {
+	if count1 == 0 {
+		for ; count2 > 0; count2-- {
+			e.f2.setChanged(line2-1, true)
+			line2++
+		}
+
+		return
+	}
+
+	if count2 == 0 {
+		for ; count1 > 0; count1-- {
+			e.f1.setChanged(line1-1, true)
+			line1++
+		}
+
+		return
+	}
+
+	m := e.fillPatienceMap(line1, count1, line2, count2)
+	if !m.hasMatches {
+		for i := 0; i < count1; i++ {
+			e.f1.setChanged(line1-1+i, true)
+		}
+
+		for i := 0; i < count2; i++ {
+			e.f2.setChanged(line2-1+i, true)
+		}
+
+		return
+	}
+
+	if first := m.longestCommonSequence(); first != nil {
+		e.walkCommonSequence(first, line1, count1, line2, count2)
+	} else {
+		e.fallBack(line1, count1, line2, count2)
+	}
+}
+
+// fillPatienceMap indexes the lines of the ranges. It is done for every
+// recursion, as lines may be unique in a range but not in the whole file.
+func (e *xenv) fillPatienceMap(line1, count1, line2, count2 int) *patienceMap {
+	m := &patienceMap{entries: make(map[int]*patienceEntry, count1)}
+	for i := 0; i < count1; i++ {
+		line := line1 + i
+		ha := e.f1.ha[line-1]
+		if entry, ok := m.entries[ha]; ok {
+			entry.line2 = nonUnique
+			continue
+		}
+
+		entry := &patienceEntry{line1: line}
+		m.entries[ha] = entry
+		if m.first == nil {
+			m.first = entry
+		}
+
+		if m.last != nil {
+			m.last.next = entry
+			entry.previous = m.last
+		}
+
+		m.last = entry
+		m.nr++
+	}
+
+	for i := 0; i < count2; i++ {
+		line := line2 + i
+		entry, ok := m.entries[e.f2.ha[line-1]]
+		if !ok {
+			continue
+		}
+
+		m.hasMatches = true
+		if entry.line2 != 0 {
+			entry.line2 = nonUnique
+		} else {
+			entry.line2 = line
+		}
+	}
+
+	return m
+}
+
+// longestCommonSequence returns the first entry of the longest sequence
+// of unique common lines in the same order in both files, the entries
+// being chained by their next field.
+func (m *patienceMap) longestCommonSequence() *patienceEntry {
+	sequence := make([]*patienceEntry, m.nr)
+	longest := 0
+	for entry := m.first; entry != nil; entry = entry.next {
+		if entry.line2 == 0 || entry.line2 == nonUnique {
+			continue
+		}
+
+		// Find the longest sequence with a smaller last line2.
+		left, right := -1, longest
+		for left+1 < right {
+			middle := left + (right-left)/2
+			if sequence[middle].line2 > entry.line2 {
+				right = middle
+			} else {
+				left = middle
+			}
+		}
+
+		i := left
+		if i < 0 {
+			entry.previous = nil
+		} else {
+			entry.previous = sequence[i]
+		}
+
+		i++
+		sequence[i] = entry
+		if i == longest {
+			longest++
+		}
+	}
+
+	if longest == 0 {
+		return nil
+	}
+
+	entry := sequence[longest-1]
+	entry.next = nil
+	for entry.previous != nil {
+		entry.previous.next = entry
+		entry = entry.previous
+	}
+
+	return entry
+}
+
+// walkCommonSequence diffs the ranges between the lines of the common
+// sequence, extending them with the equal lines around.
+func (e *xenv) walkCommonSequence(first *patienceEntry, line1, count1, line2, count2 int) {
+	end1, end2 := line1+count1, line2+count2
+	match := func(l1, l2 int) bool {
+		return e.f1.ha[l1-1] == e.f2.ha[l2-1]
+	}
+
+	for {
+		var next1, next2 int
+		if first != nil {
+			next1, next2 = first.line1, first.line2
+			for next1 > line1 && next2 > line2 && match(next1-1, next2-1) {
+				next1--
+				next2--
+			}
+		} else {
+			next1, next2 = end1, end2
+		}
+
+		for line1 < next1 && line2 < next2 && match(line1, line2) {
+			line1++
+			line2++
+		}
+
+		if next1 > line1 || next2 > line2 {
+			e.patience(line1, next1-line1, line2, next2-line2)
+		}
+
+		if first == nil {
+			return
+		}
+
+		for first.next != nil &&
+			first.next.line1 == first.line1+1 &&
+			first.next.line2 == first.line2+1 {
+			first = first.next
+		}
+
+		line1 = first.line1 + 1
+		line2 = first.line2 + 1
+		first = first.next
+	}
+}
@@ -0,0 +1,390 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// The line diff engine is a port of git's xdiff, so that the same inputs
+// give the same hunks as git diff.
+
+const (
+	// maxEqLimit bounds the number of occurrences after which a line is
+	// considered to have multiple matches by cleanupRecords.
+	maxEqLimit = 1024
+	// simScanWindow limits the scan of cleanMatch around a line.
+	simScanWindow = 100
+	// kpDisRun is the ratio of multiple matches in a run of discarded
+	// lines over which a line is discarded too.
+	kpDisRun = 4
+)
+
+// xfile is one side of a diff.
+type xfile struct {
+	// recs are the lines of the file, with their line feed.
+	recs []string
+	// ha are the classes of the lines, equal lines sharing a class.
+	ha []int
+	// rchg marks the changed lines. It is offset by one, so that the
+	// lines before the first one and after the last one are unchanged.
+	rchg []bool
+	// dstart and dend are the first and last lines which may differ,
+	// the others being common to both files.
+	dstart, dend int
+	// rindex are the lines left to be compared after the cleanup of the
+	// records, and rha their classes.
+	rindex []int
+	rha    []int
+}
+
+func (f *xfile) changed(i int) bool {
+	return f.rchg[i+1]
+}
+
+func (f *xfile) setChanged(i int, v bool) {
+	f.rchg[i+1] = v
+}
+
+// xenv is the environment of a diff between two files.
+type xenv struct {
+	f1, f2 xfile
+	ws     Whitespace
+}
+
+// prepareEnv classifies the lines of both sides. For the Myers algorithms,
+// the common lines at the ends and the lines without match are set apart
+// too, which speeds up the comparison.
+func prepareEnv(lines1, lines2 []string, alg Algorithm, ws Whitespace) *xenv {
+	e := &xenv{
+		ws: ws,
+		f1: xfile{recs: lines1, ha: make([]int, len(lines1)), rchg: make([]bool, len(lines1)+2)},
+		f2: xfile{recs: lines2, ha: make([]int, len(lines2)), rchg: make([]bool, len(lines2)+2)},
+	}
+
+	classes := make(map[string]int)
+	var count1, count2 []int
+	classify := func(f *xfile, count *[]int) {
+		for i, l := range f.recs {
+			key := ws.key(l)
+			c, ok := classes[key]
+			if !ok {
+				c = len(classes)
+				classes[key] = c
+				count1 = append(count1, 0)
+				count2 = append(count2, 0)
+			}
+
+			f.ha[i] = c
+			(*count)[c]++
+		}
+
+		f.dstart, f.dend = 0, len(f.recs)-1
+	}
+
+	classify(&e.f1, &count1)
+	classify(&e.f2, &count2)
+
+	if alg != Patience && alg != Histogram {
+		e.trimEnds()
+		e.cleanupRecords(count1, count2)
+	}
+
+	return e
+}
+
+// trimEnds sets apart the lines common to the start and the end of both
+// files.
+func (e *xenv) trimEnds() {
+	ha1, ha2 := e.f1.ha, e.f2.ha
+	lim := min(len(ha1), len(ha2))
+
+	i := 0
+	for ; i < lim && ha1[i] == ha2[i]; i++ {
+	}
+
+	e.f1.dstart, e.f2.dstart = i, i
+
+	lim -= i
+	j := 0
+	for ; j < lim && ha1[len(ha1)-1-j] == ha2[len(ha2)-1-j]; j++ {
+	}
+
+	e.f1.dend = len(ha1) - j - 1
+	e.f2.dend = len(ha2) - j - 1
+}
+
+// cleanupRecords marks as changed the lines without match in the other
+// file, and the lines with many matches in the middle of such lines, the
+// other lines being left to the comparison.
+func (e *xenv) cleanupRecords(count1, count2 []int) {
+	dis1 := discards(&e.f1, count2)
+	dis2 := discards(&e.f2, count1)
+	keep(&e.f1, dis1)
+	keep(&e.f2, dis2)
+}
+
+// discards returns, for the lines of f, 0 when they have no match in the
+// other file, 1 when they have some and 2 when they have many.
+func discards(f *xfile, other []int) []byte {
+	dis := make([]byte, len(f.recs)+1)
+	mlim := min(bogoSqrt(len(f.recs)), maxEqLimit)
+	for i := f.dstart; i <= f.dend; i++ {
+		switch nm := other[f.ha[i]]; {
+		case nm == 0:
+			dis[i] = 0
+		case nm >= mlim:
+			dis[i] = 2
+		default:
+			dis[i] = 1
+		}
+	}
+
+	return dis
+}
+
+func keep(f *xfile, dis []byte) {
+	for i := f.dstart; i <= f.dend; i++ {
+		if dis[i] == 1 || (dis[i] == 2 && !cleanMatch(dis, i, f.dstart, f.dend)) {
+			f.rindex = append(f.rindex, i)
+			f.rha = append(f.rha, f.ha[i])
+		} else {
+			f.setChanged(i, true)
+		}
+	}
+}
+
+// cleanMatch reports whether the line i, which has many matches, is in the
+// middle of a run of lines without match and should be discarded.
+func cleanMatch(dis []byte, i, s, e int) bool {
+	if i-s > simScanWindow {
+		s = i - simScanWindow
+	}
+
+	if e-i > simScanWindow {
+		e = i + simScanWindow
+	}
+
+	rdis0, rpdis0 := 0, 1
+	for r := 1; i-r >= s; r++ {
+		if dis[i-r] == 0 {
+			rdis0++
+		} else if dis[i-r] == 2 {
+			rpdis0++
+		} else {
+			break
+		}
+	}
+
+	if rdis0 == 0 {
+		return false
+	}
+
+	rdis1, rpdis1 := 0, 1
+	for r := 1; i+r <= e; r++ {
+		if dis[i+r] == 0 {
+			rdis1++
+		} else if dis[i+r] == 2 {
+			rpdis1++
+		} else {
+			break
+		}
+	}
+
+	if rdis1 == 0 {
+		return false
+	}
+
+	rdis1 += rdis0
+	rpdis1 += rpdis0
+	return rpdis1*kpDisRun < rpdis1+rdis1
+}
+
+// bogoSqrt approximates the square root of n.
+func bogoSqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+
+	return i
+}
+
+// do computes the changed lines of both files.
+func (e *xenv) do(o *Options) {
+	switch o.Algorithm {
+	case Patience:
+		e.patience(1, len(e.f1.recs), 1, len(e.f2.recs))
+	case Histogram:
+		e.histogram(1, len(e.f1.recs), 1, len(e.f2.recs))
+	default:
+		e.myers(o.Algorithm == Minimal)
+	}
+}
+
+// fallBack diffs the given ranges of lines, 1-based, with the Myers
+// algorithm, like the patience and histogram algorithms do when they find
+// no unique common line.
+func (e *xenv) fallBack(line1, count1, line2, count2 int) {
+	sub := prepareEnv(e.f1.recs[line1-1:line1-1+count1], e.f2.recs[line2-1:line2-1+count2], Myers, e.ws)
+	sub.myers(false)
+	copy(e.f1.rchg[line1:line1+count1], sub.f1.rchg[1:count1+1])
+	copy(e.f2.rchg[line2:line2+count2], sub.f2.rchg[1:count2+1])
+}
+
+// edits returns the changes, in order.
+func (e *xenv) edits() []Edit {
+	var edits []Edit
+	n1, n2 := len(e.f1.recs), len(e.f2.recs)
+	for i1, i2 := 0, 0; i1 < n1 || i2 < n2; {
+		if (i1 < n1 && e.f1.changed(i1)) || (i2 < n2 && e.f2.changed(i2)) {
+			edit := Edit{Pos1: i1, Pos2: i2}
+			for ; i1 < n1 && e.f1.changed(i1); i1++ {
+			}
+
+			for ; i2 < n2 && e.f2.changed(i2); i2++ {
+			}
+
+			edit.Len1, edit.Len2 = i1-edit.Pos1, i2-edit.Pos2
+			edits = append(edits, edit)
+			continue
+		}
+
+		if i1 == n1 || i2 == n2 {
+			// Unmatched unchanged lines, which cannot happen unless the
+			// changes are inconsistent: report them as changed.
+			edits = append(edits, Edit{Pos1: i1, Len1: n1 - i1, Pos2: i2, Len2: n2 - i2})
+			break
+		}
+
+		i1++
+		i2++
+	}
+
+	return edits
+}
+
+// blank reports whether the lines of the edit are all blank.
+func (e *xenv) blank(edit Edit) bool {
+	for _, l := range e.f1.recs[edit.Pos1 : edit.Pos1+edit.Len1] {
+		if !e.ws.blank(l) {
+			return false
+		}
+	}
+
+	for _, l := range e.f2.recs[edit.Pos2 : edit.Pos2+edit.Len2] {
+		if !e.ws.blank(l) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffs returns the changes as runs of equal, deleted and inserted lines.
+func (e *xenv) diffs() []diffmatchpatch.Diff {
+	diffs := []diffmatchpatch.Diff{}
+	emit := func(op diffmatchpatch.Operation, lines []string) {
+		if len(lines) > 0 {
+			diffs = append(diffs, diffmatchpatch.Diff{Type: op, Text: strings.Join(lines, "")})
+		}
+	}
+
+	i2 := 0
+	for _, edit := range e.edits() {
+		emit(diffmatchpatch.DiffEqual, e.f2.recs[i2:edit.Pos2])
+		emit(diffmatchpatch.DiffDelete, e.f1.recs[edit.Pos1:edit.Pos1+edit.Len1])
+		emit(diffmatchpatch.DiffInsert, e.f2.recs[edit.Pos2:edit.Pos2+edit.Len2])
+		i2 = edit.Pos2 + edit.Len2
+	}
+
+	emit(diffmatchpatch.DiffEqual, e.f2.recs[i2:])
+	return diffs
+}
+
+// key returns the key of the line for its comparison with the whitespace
+// flags, equal lines having the same key.
+func (w Whitespace) key(line string) string {
+	switch {
+	case w&IgnoreAllSpace != 0:
+		b := make([]byte, 0, len(line))
+		for i := 0; i < len(line); i++ {
+			if !isSpace(line[i]) {
+				b = append(b, line[i])
+			}
+		}
+
+		return string(b)
+	case w&IgnoreSpaceChange != 0:
+		b := make([]byte, 0, len(line))
+		for i := 0; i < len(line); {
+			if !isSpace(line[i]) {
+				b = append(b, line[i])
+				i++
+				continue
+			}
+
+			for i < len(line) && isSpace(line[i]) {
+				i++
+			}
+
+			if i < len(line) {
+				b = append(b, ' ')
+			}
+		}
+
+		return string(b)
+	case w&IgnoreSpaceAtEOL != 0:
+		return strings.TrimRight(line, " \t\n\r")
+	case w&IgnoreCRAtEOL != 0:
+		if l, ok := strings.CutSuffix(line, "\n"); ok {
+			return strings.TrimSuffix(l, "\r")
+		}
+	}
+
+	return line
+}
+
+// blank reports whether the line is blank: empty, or only made of
+// whitespace when whitespace is ignored.
+func (w Whitespace) blank(line string) bool {
+	if w&compareFlags == 0 {
+		return len(line) <= 1
+	}
+
+	for i := 0; i < len(line); i++ {
+		if !isSpace(line[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isSpace reports whether c is whitespace, as defined by git which does
+// not consider vertical tabs and form feeds as such.
+func isSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r':
+		return true
+	}
+
+	return false
+}
+
+// splitLines splits s in lines, keeping their line feed.
+func splitLines(s string) []string {
+	var lines []string
+	for len(s) > 0 {
+		i := strings.IndexByte(s, '\n')
+		if i < 0 {
+			lines = append(lines, s)
+			break
+		}
+
+		lines = append(lines, s[:i+1])
+		s = s[i+1:]
+	}
+
+	return lines
+}
@@ -22,6 +22,9 @@ const (
 	Insert
 	Delete
 	Modify
+	// Copy is the action of the copies of files, which the merkletrie diffs
+	// do not report, but the tree diffs detecting copies do.
+	Copy
 )
 
 // String returns the action as a human readable text.
@@ -33,6 +36,8 @@ func (a Action) String() string {
 		return "Delete"
 	case Modify:
 		return "Modify"
+	case Copy:
+		return "Copy"
 	default:
 		panic(fmt.Sprintf("unsupported action: %d", a))
 	}
@@ -26,6 +26,9 @@ func (s *ChangeSuite) TestActionString() {
 
 	action = merkletrie.Modify
 	s.Equal("Modify", action.String())
+
+	action = merkletrie.Copy
+	s.Equal("Copy", action.String())
 }
 
 func (s *ChangeSuite) TestUnsupportedAction() {
@@ -25,6 +25,7 @@ var ignore = map[string]bool{
 type node struct {
 	fs         billy.Filesystem
 	submodules map[string]plumbing.Hash
+	options    *Options
 
 	path     string
 	hash     []byte
@@ -43,7 +44,43 @@ func NewRootNode(
 	fs billy.Filesystem,
 	submodules map[string]plumbing.Hash,
 ) noder.Noder {
-	return &node{fs: fs, submodules: submodules, isDir: true}
+	return NewRootNodeWithOptions(fs, submodules, Options{})
+}
+
+// ContentFilter converts the content of a file in the worktree to the
+// content git stores as a blob for it.
+type ContentFilter func(content []byte) ([]byte, error)
+
+// Options contains configuration for the nodes of the filesystem.
+type Options struct {
+	// Filter, when set, returns the ContentFilter to apply to the regular
+	// file at path before hashing it, or nil to hash the file as is. It
+	// allows the hashes to match the blobs stored by git after applying
+	// end-of-line conversions or clean filters.
+	Filter func(path string) ContentFilter
+}
+
+// NewRootNodeWithOptions returns the root node based on a given
+// billy.Filesystem and options. See NewRootNode for the meaning of
+// submodules.
+func NewRootNodeWithOptions(
+	fs billy.Filesystem,
+	submodules map[string]plumbing.Hash,
+	options Options,
+) noder.Noder {
+	return &node{fs: fs, submodules: submodules, options: &options, isDir: true}
+}
+
+// HashFile returns the hash of the blob of the regular file, or symbolic
+// link, at path in fs, as computed by the nodes: fi is the result of its
+// Lstat. The zero hash is returned when the file can not be read.
+func HashFile(fs billy.Filesystem, path string, fi os.FileInfo, options Options) plumbing.Hash {
+	n := &node{fs: fs, options: &options, path: path, mode: fi.Mode(), size: fi.Size()}
+	if n.mode&os.ModeSymlink != 0 {
+		return n.doCalculateHashForSymlink()
+	}
+
+	return n.doCalculateHashForRegular()
 }
 
 // Hash the hash of a filesystem is the result of concatenating the computed
@@ -134,6 +171,7 @@ func (n *node) newChildNode(file os.FileInfo) (*node, error) {
 	node := &node{
 		fs:         n.fs,
 		submodules: n.submodules,
+		options:    n.options,
 
 		path:  path,
 		isDir: file.IsDir(),
@@ -179,6 +217,12 @@ func (n *node) doCalculateHashForRegular() plumbing.Hash {
 
 	defer f.Close()
 
+	if n.options.Filter != nil {
+		if filter := n.options.Filter(n.path); filter != nil {
+			return n.doCalculateHashForFiltered(f, filter)
+		}
+	}
+
 	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, n.size)
 	if _, err := io.Copy(h, f); err != nil {
 		return plumbing.ZeroHash
@@ -187,6 +231,25 @@ func (n *node) doCalculateHashForRegular() plumbing.Hash {
 	return h.Sum()
 }
 
+func (n *node) doCalculateHashForFiltered(r io.Reader, filter ContentFilter) plumbing.Hash {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	content, err = filter(content)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+
+	h := plumbing.NewHasher(format.SHA1, plumbing.BlobObject, int64(len(content)))
+	if _, err := h.Write(content); err != nil {
+		return plumbing.ZeroHash
+	}
+
+	return h.Sum()
+}
+
 func (n *node) doCalculateHashForSymlink() plumbing.Hash {
 	target, err := n.fs.Readlink(n.path)
 	if err != nil {
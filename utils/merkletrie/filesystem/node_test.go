@@ -89,6 +89,37 @@ func (s *NoderSuite) TestDiffChangeContent() {
 	s.Len(ch, 1)
 }
 
+func (s *NoderSuite) TestDiffFilter() {
+	fsA := memfs.New()
+	WriteFile(fsA, "foo", []byte("foo\r\nbar\r\n"), 0644)
+	WriteFile(fsA, "qux", []byte("qux\r\n"), 0644)
+
+	fsB := memfs.New()
+	WriteFile(fsB, "foo", []byte("foo\nbar\n"), 0644)
+	WriteFile(fsB, "qux", []byte("qux\n"), 0644)
+
+	crlf := func(content []byte) ([]byte, error) {
+		return bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), nil
+	}
+
+	ch, err := merkletrie.DiffTree(
+		NewRootNodeWithOptions(fsA, nil, Options{
+			Filter: func(path string) ContentFilter {
+				if path == "foo" {
+					return crlf
+				}
+				return nil
+			},
+		}),
+		NewRootNode(fsB, nil),
+		IsEquals,
+	)
+
+	s.NoError(err)
+	s.Len(ch, 1)
+	s.Equal("qux", ch[0].To.String())
+}
+
 func (s *NoderSuite) TestDiffSymlinkDirOnA() {
 	fsA := memfs.New()
 	WriteFile(fsA, "qux/qux", []byte("foo"), 0644)
@@ -23,7 +23,20 @@ type node struct {
 }
 
 // NewRootNode returns the root node of a computed tree from a index.Index,
+// the entries with the skip-worktree bit being skipped, as done when the
+// index is compared with the worktree.
 func NewRootNode(idx *index.Index) noder.Noder {
+	return newRootNode(idx, true)
+}
+
+// NewRootNodeWithSkipWorktree returns the root node of a computed tree from a
+// index.Index, including the entries with the skip-worktree bit, as done when
+// the index is compared with a tree.
+func NewRootNodeWithSkipWorktree(idx *index.Index) noder.Noder {
+	return newRootNode(idx, false)
+}
+
+func newRootNode(idx *index.Index, skipWorktree bool) noder.Noder {
 	const rootNode = ""
 
 	m := map[string]*node{rootNode: {isDir: true}}
@@ -48,7 +61,7 @@ func NewRootNode(idx *index.Index) noder.Noder {
 				continue
 			}
 
-			n := &node{path: fullpath, skip: e.SkipWorktree}
+			n := &node{path: fullpath, skip: skipWorktree && e.SkipWorktree}
 			if fullpath == e.Name {
 				n.entry = e
 			} else {
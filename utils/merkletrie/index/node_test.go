@@ -90,6 +90,28 @@ func (s *NoderSuite) TestDiffSkipIssue1455() {
 	s.Equal(a, merkletrie.Insert)
 }
 
+func (s *NoderSuite) TestDiffWithSkipWorktree() {
+	indexA := &index.Index{
+		Entries: []*index.Entry{
+			{
+				Name:         path.Join("bar", "baz", "bar"),
+				Hash:         plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d"),
+				SkipWorktree: true,
+			},
+			{
+				Name: path.Join("bar", "biz", "bat"),
+				Hash: plumbing.NewHash("8ab686eafeb1f44702738c8b0f24f2567c36da6d"),
+			},
+		},
+	}
+
+	indexB := &index.Index{}
+
+	ch, err := merkletrie.DiffTree(NewRootNode(indexB), NewRootNodeWithSkipWorktree(indexA), isEquals)
+	s.NoError(err)
+	s.Len(ch, 2)
+}
+
 func (s *NoderSuite) TestDiffDir() {
 	indexA := &index.Index{
 		Entries: []*index.Entry{{
@@ -0,0 +1,663 @@
+package git
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/internal/cms"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+)
+
+var (
+	// ErrUnsupportedSignatureType is returned by a Verifier when given a
+	// signature in a format it does not handle.
+	ErrUnsupportedSignatureType = errors.New("unsupported signature type")
+	// ErrVerificationPolicy is wrapped by the errors returned by
+	// Repository.VerifyCommits when a commit does not satisfy the policy.
+	ErrVerificationPolicy = errors.New("signature verification policy violated")
+)
+
+// VerificationStatus is the outcome of a signature verification, following
+// the statuses reported by git's %G? log format.
+type VerificationStatus int8
+
+const (
+	// VerificationUnsigned means the object carries no signature.
+	VerificationUnsigned VerificationStatus = iota
+	// VerificationGood is a valid signature made by a known key.
+	VerificationGood
+	// VerificationBad is a signature that does not match the object.
+	VerificationBad
+	// VerificationExpired is a valid signature made by an expired key or
+	// certificate, or a signature that has itself expired.
+	VerificationExpired
+	// VerificationRevoked is a valid signature made by a revoked key.
+	VerificationRevoked
+	// VerificationUnknownKey is a signature made by a key that is not known
+	// to the verifier, so it cannot be checked.
+	VerificationUnknownKey
+)
+
+// String returns a human readable representation of the status.
+func (s VerificationStatus) String() string {
+	switch s {
+	case VerificationUnsigned:
+		return "unsigned"
+	case VerificationGood:
+		return "good"
+	case VerificationBad:
+		return "bad"
+	case VerificationExpired:
+		return "expired"
+	case VerificationRevoked:
+		return "revoked"
+	case VerificationUnknownKey:
+		return "unknown key"
+	}
+	return "unknown"
+}
+
+// TrustLevel is the trust placed in the key that made a signature, with the
+// same levels as git's gpg.minTrustLevel.
+type TrustLevel int8
+
+// Trust levels, from lowest to highest.
+const (
+	TrustUndefined TrustLevel = iota
+	TrustNever
+	TrustMarginal
+	TrustFully
+	TrustUltimate
+)
+
+// String returns the name of the trust level as used by git.
+func (t TrustLevel) String() string {
+	switch t {
+	case TrustNever:
+		return "never"
+	case TrustMarginal:
+		return "marginal"
+	case TrustFully:
+		return "fully"
+	case TrustUltimate:
+		return "ultimate"
+	}
+	return "undefined"
+}
+
+// VerificationResult describes the signature of an object.
+type VerificationResult struct {
+	// Type is the format of the signature.
+	Type object.SignatureType
+	// Status is the outcome of the verification.
+	Status VerificationStatus
+	// KeyID identifies the key that made the signature: the OpenPGP key ID,
+	// the SHA-256 fingerprint of the X.509 certificate or the SHA-256
+	// fingerprint of the SSH key, as reported by ssh-keygen.
+	KeyID string
+	// Signer is the identity bound to the key: the primary OpenPGP user ID,
+	// the e-mail, URI or subject of the X.509 certificate, or the SSH
+	// principal.
+	Signer string
+	// TrustLevel is the trust the verifier places in the key.
+	TrustLevel TrustLevel
+	// Err holds the underlying error when Status is not VerificationGood.
+	Err error
+}
+
+// Verifier is an interface for verifying signatures of git objects.
+// signature is the armored signature found in the object and message is a
+// reader containing the encoded object without its signature.
+//
+// Implementors should report cryptographic outcomes through the Status of
+// the result, return ErrUnsupportedSignatureType for formats they do not
+// handle, and any other error when the signature cannot be processed.
+// See https://git-scm.com/docs/gitformat-signature for more information.
+type Verifier interface {
+	Verify(signature []byte, message io.Reader) (*VerificationResult, error)
+}
+
+func verifyObject(v Verifier, signature string, obj signableObject) (*VerificationResult, error) {
+	if signature == "" {
+		return &VerificationResult{Status: VerificationUnsigned}, nil
+	}
+
+	encoded := &plumbing.MemoryObject{}
+	if err := obj.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	return v.Verify([]byte(signature), r)
+}
+
+// VerifyCommit verifies the signature of a commit with v.
+func VerifyCommit(v Verifier, c *object.Commit) (*VerificationResult, error) {
+	return verifyObject(v, c.PGPSignature, c)
+}
+
+// VerifyTag verifies the signature of an annotated tag with v.
+func VerifyTag(v Verifier, t *object.Tag) (*VerificationResult, error) {
+	return verifyObject(v, t.PGPSignature, t)
+}
+
+// VerifyMergeTag verifies the signature of the tag embedded in the mergetag
+// header of a merge commit with v, as git does with `git verify-commit` on
+// merges of signed tags.
+func VerifyMergeTag(v Verifier, c *object.Commit) (*VerificationResult, error) {
+	t, err := c.MergeTagObject()
+	if err != nil {
+		return nil, err
+	}
+
+	return VerifyTag(v, t)
+}
+
+type multiVerifier []Verifier
+
+// NewMultiVerifier returns a Verifier that delegates to the first of the
+// given verifiers that supports the type of each signature. It is used to
+// combine backends for the different signature formats.
+func NewMultiVerifier(verifiers ...Verifier) Verifier {
+	return multiVerifier(verifiers)
+}
+
+// Verify implements the Verifier interface.
+func (m multiVerifier) Verify(signature []byte, message io.Reader) (*VerificationResult, error) {
+	// The message must be buffered, as each backend consumes it.
+	content, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range m {
+		res, err := v.Verify(signature, bytes.NewReader(content))
+		if errors.Is(err, ErrUnsupportedSignatureType) {
+			continue
+		}
+
+		return res, err
+	}
+
+	return nil, ErrUnsupportedSignatureType
+}
+
+type openPGPVerifier struct {
+	keyring openpgp.KeyRing
+}
+
+// NewOpenPGPVerifier returns a Verifier for OpenPGP signatures made by keys
+// in keyring. The keyring is the trust anchor, so good signatures are
+// reported with TrustFully.
+func NewOpenPGPVerifier(keyring openpgp.KeyRing) Verifier {
+	return &openPGPVerifier{keyring: keyring}
+}
+
+// Verify implements the Verifier interface.
+func (v *openPGPVerifier) Verify(signature []byte, message io.Reader) (*VerificationResult, error) {
+	if object.DetectSignatureType(signature) != object.SignatureTypeOpenPGP {
+		return nil, ErrUnsupportedSignatureType
+	}
+
+	block, err := armor.Decode(bytes.NewReader(signature))
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(block.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &VerificationResult{Type: object.SignatureTypeOpenPGP}
+	p, err := packet.Read(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if sig, ok := p.(*packet.Signature); ok && sig.IssuerKeyId != nil {
+		res.KeyID = fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	}
+
+	_, entity, err := openpgp.VerifyDetachedSignature(v.keyring, message, bytes.NewReader(body), nil)
+	if entity != nil {
+		if id := entity.PrimaryIdentity(); id != nil {
+			res.Signer = id.Name
+		}
+		res.TrustLevel = TrustFully
+	}
+
+	res.Err = err
+	switch {
+	case err == nil:
+		res.Status = VerificationGood
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		res.Status = VerificationUnknownKey
+		res.TrustLevel = TrustUndefined
+	case errors.Is(err, pgperrors.ErrKeyExpired), errors.Is(err, pgperrors.ErrSignatureExpired):
+		res.Status = VerificationExpired
+	case errors.Is(err, pgperrors.ErrKeyRevoked):
+		res.Status = VerificationRevoked
+	default:
+		res.Status = VerificationBad
+	}
+
+	return res, nil
+}
+
+type x509Verifier struct {
+	opts           x509.VerifyOptions
+	useSigningTime bool
+}
+
+// NewX509Verifier returns a Verifier for X.509 (CMS) signatures, validating
+// the certificate chain of the signer with opts. When opts.CurrentTime is
+// zero, chains are validated at the current time. Good signatures are
+// reported with TrustFully.
+func NewX509Verifier(opts x509.VerifyOptions) Verifier {
+	return &x509Verifier{opts: opts}
+}
+
+// NewX509VerifierAtSigningTime is like NewX509Verifier, but when
+// opts.CurrentTime is zero, chains are validated at the signing time
+// recorded in the signature, so signatures made with short-lived
+// certificates remain good after they expire. The signing time is chosen
+// by the signer, so it must only be used with trusted signers.
+func NewX509VerifierAtSigningTime(opts x509.VerifyOptions) Verifier {
+	return &x509Verifier{opts: opts, useSigningTime: true}
+}
+
+// Verify implements the Verifier interface.
+func (v *x509Verifier) Verify(signature []byte, message io.Reader) (*VerificationResult, error) {
+	if object.DetectSignatureType(signature) != object.SignatureTypeX509 {
+		return nil, ErrUnsupportedSignatureType
+	}
+
+	sd, err := cms.Parse(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := sha256.Sum256(sd.Signer.Raw)
+	res := &VerificationResult{
+		Type:   object.SignatureTypeX509,
+		KeyID:  strings.ToUpper(hex.EncodeToString(fingerprint[:])),
+		Signer: certificateIdentity(sd.Signer),
+	}
+
+	if v.useSigningTime {
+		_, err = sd.VerifyAtSigningTime(content, v.opts)
+	} else {
+		_, err = sd.Verify(content, v.opts)
+	}
+
+	res.Err = err
+
+	var invalid x509.CertificateInvalidError
+	var unknown x509.UnknownAuthorityError
+	switch {
+	case err == nil:
+		res.Status = VerificationGood
+		res.TrustLevel = TrustFully
+	case errors.As(err, &invalid) && invalid.Reason == x509.Expired:
+		res.Status = VerificationExpired
+	case errors.As(err, &unknown):
+		res.Status = VerificationUnknownKey
+	default:
+		res.Status = VerificationBad
+	}
+
+	return res, nil
+}
+
+func certificateIdentity(c *x509.Certificate) string {
+	if len(c.EmailAddresses) != 0 {
+		return c.EmailAddresses[0]
+	}
+	if len(c.URIs) != 0 {
+		return c.URIs[0].String()
+	}
+	return c.Subject.String()
+}
+
+const (
+	sshSigMagic     = "SSHSIG"
+	sshSigNamespace = "git"
+	sshSigPEMType   = "SSH SIGNATURE"
+)
+
+type sshVerifier struct {
+	allowed map[string][]ssh.PublicKey
+}
+
+// NewSSHVerifier returns a Verifier for SSH signatures, the equivalent of
+// git's gpg.ssh.allowedSignersFile: allowed maps each principal to the keys
+// it may sign with. Good signatures are reported with TrustFully.
+func NewSSHVerifier(allowed map[string][]ssh.PublicKey) Verifier {
+	return &sshVerifier{allowed: allowed}
+}
+
+// Verify implements the Verifier interface.
+func (v *sshVerifier) Verify(signature []byte, message io.Reader) (*VerificationResult, error) {
+	if object.DetectSignatureType(signature) != object.SignatureTypeSSH {
+		return nil, ErrUnsupportedSignatureType
+	}
+
+	block, _ := pem.Decode(signature)
+	if block == nil || block.Type != sshSigPEMType {
+		return nil, errors.New("invalid SSH signature armor")
+	}
+
+	blob := block.Bytes
+	if !bytes.HasPrefix(blob, []byte(sshSigMagic)) {
+		return nil, errors.New("invalid SSH signature magic")
+	}
+
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(blob[len(sshSigMagic):], &sig); err != nil {
+		return nil, err
+	}
+
+	pub, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &VerificationResult{
+		Type:  object.SignatureTypeSSH,
+		KeyID: ssh.FingerprintSHA256(pub),
+	}
+
+	for principal, keys := range v.allowed {
+		for _, k := range keys {
+			if bytes.Equal(k.Marshal(), pub.Marshal()) {
+				res.Signer = principal
+			}
+		}
+	}
+
+	if res.Signer == "" {
+		res.Status = VerificationUnknownKey
+		return res, nil
+	}
+
+	res.Err = verifySSHSignature(pub, sig.Signature, sig.Namespace, sig.HashAlgorithm, message)
+	if res.Err != nil {
+		res.Status = VerificationBad
+		return res, nil
+	}
+
+	res.Status = VerificationGood
+	res.TrustLevel = TrustFully
+	return res, nil
+}
+
+func verifySSHSignature(pub ssh.PublicKey, blob []byte, namespace, hashAlgorithm string, message io.Reader) error {
+	if namespace != sshSigNamespace {
+		return fmt.Errorf("unexpected SSH signature namespace %q", namespace)
+	}
+
+	var h interface {
+		io.Writer
+		Sum([]byte) []byte
+	}
+	switch hashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported SSH signature hash %q", hashAlgorithm)
+	}
+
+	if _, err := io.Copy(h, message); err != nil {
+		return err
+	}
+
+	signed := append([]byte(sshSigMagic), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, "", hashAlgorithm, h.Sum(nil)})...)
+
+	sig := &ssh.Signature{}
+	if err := ssh.Unmarshal(blob, sig); err != nil {
+		return err
+	}
+
+	return pub.Verify(signed, sig)
+}
+
+// VerificationPolicy describes the signatures accepted by
+// Repository.VerifyCommits.
+type VerificationPolicy struct {
+	// Verifier is used to verify each signature.
+	Verifier Verifier
+	// AllowedKeys restricts the accepted signatures to those made by the
+	// given key IDs, as reported in VerificationResult.KeyID. When empty,
+	// any key the Verifier considers good is accepted.
+	AllowedKeys []string
+	// MinTrustLevel is the minimum trust level of accepted signatures, the
+	// equivalent of git's gpg.minTrustLevel.
+	MinTrustLevel TrustLevel
+	// VerifyMergeTags requires the tags embedded in the mergetag headers of
+	// merge commits to satisfy the policy too.
+	VerifyMergeTags bool
+}
+
+// Check returns nil if the result satisfies the policy, or an error wrapping
+// ErrVerificationPolicy describing why it does not.
+func (p *VerificationPolicy) Check(res *VerificationResult) error {
+	if res.Status != VerificationGood {
+		return fmt.Errorf("%w: signature is %s", ErrVerificationPolicy, res.Status)
+	}
+
+	if res.TrustLevel < p.MinTrustLevel {
+		return fmt.Errorf("%w: key %s has trust level %s, %s required",
+			ErrVerificationPolicy, res.KeyID, res.TrustLevel, p.MinTrustLevel)
+	}
+
+	if len(p.AllowedKeys) == 0 {
+		return nil
+	}
+
+	for _, k := range p.AllowedKeys {
+		if strings.EqualFold(k, res.KeyID) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: key %s is not allowed", ErrVerificationPolicy, res.KeyID)
+}
+
+// CommitVerificationError is returned by Repository.VerifyCommits for the
+// first commit that does not satisfy the policy.
+type CommitVerificationError struct {
+	// Commit is the hash of the offending commit.
+	Commit plumbing.Hash
+	// MergeTag is set when the failing signature is the one of the tag
+	// embedded in the mergetag header of the commit.
+	MergeTag bool
+	// Result is the verification result of the signature.
+	Result *VerificationResult
+	// Err is the reason the policy was not satisfied.
+	Err error
+}
+
+func (e *CommitVerificationError) Error() string {
+	if e.MergeTag {
+		return fmt.Sprintf("commit %s: mergetag: %s", e.Commit, e.Err)
+	}
+	return fmt.Sprintf("commit %s: %s", e.Commit, e.Err)
+}
+
+func (e *CommitVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// VerifyCommits checks that every commit in rng is signed as required by
+// policy. rng is either a single revision, meaning all the commits reachable
+// from it, a range "A..B" of the commits reachable from B but not from A, or
+// a symmetric range "A...B" of the commits reachable from either A or B but
+// not from both, as accepted by `git rev-list`. It returns a
+// *CommitVerificationError for the first commit violating the policy.
+func (r *Repository) VerifyCommits(rng string, policy *VerificationPolicy) error {
+	if i := strings.Index(rng, "..."); i >= 0 {
+		return r.verifySymmetricRange(rng[:i], rng[i+3:], policy)
+	}
+
+	from, to := "", rng
+	if i := strings.Index(rng, ".."); i >= 0 {
+		from, to = rng[:i], rng[i+2:]
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	if from != "" {
+		var err error
+		if seen, err = r.reachableCommits(from); err != nil {
+			return err
+		}
+	}
+
+	head, err := r.revisionCommit(to)
+	if err != nil {
+		return err
+	}
+
+	return object.NewCommitPreorderIter(head, seen, nil).ForEach(func(c *object.Commit) error {
+		return verifyCommitPolicy(c, policy)
+	})
+}
+
+// verifySymmetricRange checks the commits reachable from either a or b but
+// not from both, an empty side meaning HEAD.
+func (r *Repository) verifySymmetricRange(a, b string, policy *VerificationPolicy) error {
+	if a == "" {
+		a = "HEAD"
+	}
+	if b == "" {
+		b = "HEAD"
+	}
+
+	fromA, err := r.reachableCommits(a)
+	if err != nil {
+		return err
+	}
+
+	fromB, err := r.reachableCommits(b)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	for h := range fromA {
+		if fromB[h] {
+			seen[h] = true
+		}
+	}
+
+	for _, rev := range []string{a, b} {
+		c, err := r.revisionCommit(rev)
+		if err != nil {
+			return err
+		}
+
+		// The commits are added to seen as they are walked, so the ones
+		// reachable from both sides are only checked once.
+		err = object.NewCommitPreorderIter(c, seen, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return verifyCommitPolicy(c, policy)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// revisionCommit returns the commit of the revision.
+func (r *Repository) revisionCommit(rev string) (*object.Commit, error) {
+	h, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+
+	return r.CommitObject(*h)
+}
+
+// reachableCommits returns the hashes of the commits reachable from the
+// revision.
+func (r *Repository) reachableCommits(rev string) (map[plumbing.Hash]bool, error) {
+	c, err := r.revisionCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	reachable := make(map[plumbing.Hash]bool)
+	err = object.NewCommitPreorderIter(c, nil, nil).ForEach(func(c *object.Commit) error {
+		reachable[c.Hash] = true
+		return nil
+	})
+
+	return reachable, err
+}
+
+func verifyCommitPolicy(c *object.Commit, policy *VerificationPolicy) error {
+	res, err := VerifyCommit(policy.Verifier, c)
+	if err != nil {
+		return err
+	}
+	if err := policy.Check(res); err != nil {
+		return &CommitVerificationError{Commit: c.Hash, Result: res, Err: err}
+	}
+
+	if !policy.VerifyMergeTags || c.MergeTag == "" {
+		return nil
+	}
+
+	res, err = VerifyMergeTag(policy.Verifier, c)
+	if err != nil {
+		return err
+	}
+	if err := policy.Check(res); err != nil {
+		return &CommitVerificationError{Commit: c.Hash, MergeTag: true, Result: res, Err: err}
+	}
+
+	return nil
+}
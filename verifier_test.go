@@ -0,0 +1,259 @@
+package git
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+// sshTestSigner produces SSH signatures in the format of `ssh-keygen -Y sign`.
+type sshTestSigner struct {
+	signer ssh.Signer
+}
+
+func (s sshTestSigner) Sign(message io.Reader) ([]byte, error) {
+	h := sha512.New()
+	if _, err := io.Copy(h, message); err != nil {
+		return nil, err
+	}
+
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace, Reserved, HashAlgorithm string
+		Hash                               []byte
+	}{"git", "", "sha512", h.Sum(nil)})...)
+
+	sig, err := s.signer.Sign(rand.Reader, signed)
+	if err != nil {
+		return nil, err
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version                            uint32
+		PublicKey                          []byte
+		Namespace, Reserved, HashAlgorithm string
+		Signature                          []byte
+	}{1, s.signer.PublicKey().Marshal(), "git", "", "sha512", ssh.Marshal(sig)})...)
+
+	return pem.EncodeToMemory(&pem.Block{Type: "SSH SIGNATURE", Bytes: blob}), nil
+}
+
+func newTestSSHSigner(t *testing.T) sshTestSigner {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+	return sshTestSigner{signer: signer}
+}
+
+func newTestX509Signer(t *testing.T) (Signer, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "go-git"},
+		EmailAddresses:        []string{"go-git@example.com"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return NewX509Signer(key, cert), cert
+}
+
+func newTestCommit(t *testing.T, r *Repository, msg string, signer Signer) plumbing.Hash {
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	h, err := w.Commit(msg, &CommitOptions{
+		Author:            &object.Signature{Name: "go-git", Email: "go-git@example.com", When: time.Now()},
+		Signer:            signer,
+		AllowEmptyCommits: true,
+	})
+	require.NoError(t, err)
+	return h
+}
+
+func TestVerifierBackends(t *testing.T) {
+	entity, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	require.NoError(t, err)
+	sshSigner := newTestSSHSigner(t)
+	x509Signer, cert := newTestX509Signer(t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	verifier := NewMultiVerifier(
+		NewOpenPGPVerifier(openpgp.EntityList{entity}),
+		NewX509Verifier(x509.VerifyOptions{Roots: roots}),
+		NewSSHVerifier(map[string][]ssh.PublicKey{
+			"go-git@example.com": {sshSigner.signer.PublicKey()},
+		}),
+	)
+
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+
+	tests := []struct {
+		signer Signer
+		typ    object.SignatureType
+		keyID  string
+		ident  string
+	}{
+		{&gpgSigner{key: entity}, object.SignatureTypeOpenPGP, entity.PrimaryKey.KeyIdString(), "go-git <go-git@example.com>"},
+		{x509Signer, object.SignatureTypeX509, "", "go-git@example.com"},
+		{sshSigner, object.SignatureTypeSSH, ssh.FingerprintSHA256(sshSigner.signer.PublicKey()), "go-git@example.com"},
+	}
+
+	for _, tc := range tests {
+		c, err := r.CommitObject(newTestCommit(t, r, "signed", tc.signer))
+		require.NoError(t, err)
+
+		res, err := VerifyCommit(verifier, c)
+		require.NoError(t, err)
+		assert.Equal(t, VerificationGood, res.Status, tc.typ.String())
+		assert.Equal(t, tc.typ, res.Type)
+		assert.Equal(t, TrustFully, res.TrustLevel)
+		assert.Equal(t, tc.ident, res.Signer)
+		if tc.keyID != "" {
+			assert.Equal(t, tc.keyID, res.KeyID)
+		}
+
+		c.Message = "tampered"
+		res, err = VerifyCommit(verifier, c)
+		require.NoError(t, err)
+		assert.Equal(t, VerificationBad, res.Status, tc.typ.String())
+	}
+}
+
+func TestVerifierUnknownKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	require.NoError(t, err)
+
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+
+	c, err := r.CommitObject(newTestCommit(t, r, "signed", &gpgSigner{key: entity}))
+	require.NoError(t, err)
+
+	res, err := VerifyCommit(NewOpenPGPVerifier(openpgp.EntityList{other}), c)
+	require.NoError(t, err)
+	assert.Equal(t, VerificationUnknownKey, res.Status)
+	assert.Equal(t, entity.PrimaryKey.KeyIdString(), res.KeyID)
+
+	_, err = VerifyCommit(NewSSHVerifier(nil), c)
+	assert.ErrorIs(t, err, ErrUnsupportedSignatureType)
+}
+
+func TestVerifyMergeTag(t *testing.T) {
+	entity, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	require.NoError(t, err)
+
+	tag := &object.Tag{
+		Name:       "v1.0.0",
+		Tagger:     object.Signature{Name: "go-git", Email: "go-git@example.com", When: time.Unix(1700000000, 0).UTC()},
+		Message:    "release\n",
+		TargetType: plumbing.CommitObject,
+		Target:     plumbing.NewHash("f000000000000000000000000000000000000000"),
+	}
+	sig, err := signObject(&gpgSigner{key: entity}, tag)
+	require.NoError(t, err)
+	tag.PGPSignature = string(sig)
+
+	obj := &plumbing.MemoryObject{}
+	require.NoError(t, tag.Encode(obj))
+	rd, err := obj.Reader()
+	require.NoError(t, err)
+	raw, err := io.ReadAll(rd)
+	require.NoError(t, err)
+
+	commit := &object.Commit{MergeTag: string(raw)}
+	res, err := VerifyMergeTag(NewOpenPGPVerifier(openpgp.EntityList{entity}), commit)
+	require.NoError(t, err)
+	assert.Equal(t, VerificationGood, res.Status)
+}
+
+func TestRepositoryVerifyCommits(t *testing.T) {
+	entity, err := openpgp.NewEntity("go-git", "", "go-git@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	require.NoError(t, err)
+
+	r, err := Init(memory.NewStorage(), WithWorkTree(memfs.New()))
+	require.NoError(t, err)
+
+	unsigned := newTestCommit(t, r, "unsigned", nil)
+	first := newTestCommit(t, r, "first", &gpgSigner{key: entity})
+	second := newTestCommit(t, r, "second", &gpgSigner{key: entity})
+
+	policy := &VerificationPolicy{
+		Verifier:      NewOpenPGPVerifier(openpgp.EntityList{entity, other}),
+		MinTrustLevel: TrustFully,
+	}
+
+	assert.NoError(t, r.VerifyCommits(unsigned.String()+"..HEAD", policy))
+
+	err = r.VerifyCommits("HEAD", policy)
+	var verr *CommitVerificationError
+	require.ErrorAs(t, err, &verr)
+	assert.ErrorIs(t, err, ErrVerificationPolicy)
+	assert.Equal(t, unsigned, verr.Commit)
+	assert.Equal(t, VerificationUnsigned, verr.Result.Status)
+
+	policy.AllowedKeys = []string{other.PrimaryKey.KeyIdString()}
+	err = r.VerifyCommits(first.String()+"..", policy)
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, second, verr.Commit)
+
+	policy.AllowedKeys = []string{entity.PrimaryKey.KeyIdString()}
+	assert.NoError(t, r.VerifyCommits(first.String()+"..", policy))
+
+	// Symmetric ranges: the commits of either side, but not of both.
+	assert.NoError(t, r.VerifyCommits(unsigned.String()+"..."+second.String(), policy))
+	assert.NoError(t, r.VerifyCommits(second.String()+"..."+unsigned.String(), policy))
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, w.Checkout(&CheckoutOptions{Hash: first}))
+	side := newTestCommit(t, r, "side", nil)
+
+	assert.NoError(t, r.VerifyCommits(side.String()+".."+second.String(), policy))
+	err = r.VerifyCommits(second.String()+"..."+side.String(), policy)
+	require.ErrorAs(t, err, &verr)
+	assert.Equal(t, side, verr.Commit)
+}
+
+func TestVerificationPolicyCheck(t *testing.T) {
+	policy := &VerificationPolicy{MinTrustLevel: TrustMarginal}
+
+	assert.NoError(t, policy.Check(&VerificationResult{Status: VerificationGood, TrustLevel: TrustFully}))
+	assert.ErrorIs(t, policy.Check(&VerificationResult{Status: VerificationGood, TrustLevel: TrustNever}), ErrVerificationPolicy)
+	assert.ErrorIs(t, policy.Check(&VerificationResult{Status: VerificationExpired, TrustLevel: TrustFully}), ErrVerificationPolicy)
+	assert.ErrorContains(t, policy.Check(&VerificationResult{Status: VerificationBad}), "signature is bad")
+}
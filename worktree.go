@@ -20,6 +20,7 @@ import (
 	"github.com/go-git/go-git/v6/plumbing/format/index"
 	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/plumbing/transport"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/go-git/go-git/v6/utils/sync"
@@ -75,15 +76,16 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 	}
 
 	fetchHead, err := remote.fetch(ctx, &FetchOptions{
-		RemoteName:      o.RemoteName,
-		RemoteURL:       o.RemoteURL,
-		Depth:           o.Depth,
-		Auth:            o.Auth,
-		Progress:        o.Progress,
-		Force:           o.Force,
-		InsecureSkipTLS: o.InsecureSkipTLS,
-		CABundle:        o.CABundle,
-		ProxyOptions:    o.ProxyOptions,
+		RemoteName:           o.RemoteName,
+		RemoteURL:            o.RemoteURL,
+		Depth:                o.Depth,
+		Auth:                 o.Auth,
+		UseCredentialHelpers: o.UseCredentialHelpers,
+		Progress:             o.Progress,
+		Force:                o.Force,
+		InsecureSkipTLS:      o.InsecureSkipTLS,
+		CABundle:             o.CABundle,
+		ProxyOptions:         o.ProxyOptions,
 	})
 
 	updated := true
@@ -131,6 +133,17 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 		return err
 	}
 
+	if err := w.r.fetchLFS(ctx, &FetchLFSOptions{
+		RemoteName:      o.RemoteName,
+		Auth:            o.Auth,
+		Commit:          ref.Hash(),
+		InsecureSkipTLS: o.InsecureSkipTLS,
+		CABundle:        o.CABundle,
+		ProxyOptions:    o.ProxyOptions,
+	}); err != nil {
+		return err
+	}
+
 	if err := w.updateHEAD(ref.Hash()); err != nil {
 		return err
 	}
@@ -138,6 +151,7 @@ func (w *Worktree) PullContext(ctx context.Context, o *PullOptions) error {
 	if err := w.Reset(&ResetOptions{
 		Mode:   MergeReset,
 		Commit: ref.Hash(),
+		Auth:   o.Auth,
 	}); err != nil {
 		return err
 	}
@@ -182,6 +196,7 @@ func (w *Worktree) Checkout(opts *CheckoutOptions) error {
 		Commit:     c,
 		Mode:       MergeReset,
 		SparseDirs: opts.SparseCheckoutDirectories,
+		Auth:       opts.Auth,
 	}
 	if opts.Force {
 		ro.Mode = HardReset
@@ -330,13 +345,13 @@ func (w *Worktree) Reset(opts *ResetOptions) error {
 	}
 
 	if opts.Mode == MergeReset && len(removedFiles) > 0 {
-		if err := w.resetWorktree(t, removedFiles); err != nil {
+		if err := w.resetWorktree(t, removedFiles, opts.Auth); err != nil {
 			return err
 		}
 	}
 
 	if opts.Mode == HardReset {
-		if err := w.resetWorktree(t, opts.Files); err != nil {
+		if err := w.resetWorktree(t, opts.Files, opts.Auth); err != nil {
 			return err
 		}
 	}
@@ -458,6 +473,15 @@ func (w *Worktree) resetIndex(t *object.Tree, dirs []string, files []string) ([]
 
 	if len(dirs) > 0 {
 		idx.SkipUnless(dirs)
+	} else {
+		m, sparse, err := w.sparseCheckoutMatcher()
+		if err != nil {
+			return nil, err
+		}
+
+		if m != nil {
+			applySparseCheckout(idx, m, sparse)
+		}
 	}
 
 	return removedFiles, w.r.Storer.SetIndex(idx)
@@ -474,7 +498,9 @@ func inFiles(files []string, v string) bool {
 	return false
 }
 
-func (w *Worktree) resetWorktree(t *object.Tree, files []string) error {
+// resetWorktree checks out the files of t, auth being used to download the
+// missing LFS objects.
+func (w *Worktree) resetWorktree(t *object.Tree, files []string, auth transport.AuthMethod) error {
 	changes, err := w.diffStagingWithWorktree(true, false)
 	if err != nil {
 		return err
@@ -486,6 +512,17 @@ func (w *Worktree) resetWorktree(t *object.Tree, files []string) error {
 	}
 	b := newIndexBuilder(idx)
 
+	conv, err := w.newContentConverter(nil, t)
+	if err != nil {
+		return err
+	}
+
+	if conv != nil {
+		conv.lfsAuth = auth
+	}
+
+	defer conv.close()
+
 	for _, ch := range changes {
 		if err := w.validChange(ch); err != nil {
 			return err
@@ -509,7 +546,7 @@ func (w *Worktree) resetWorktree(t *object.Tree, files []string) error {
 			}
 		}
 
-		if err := w.checkoutChange(ch, t, b); err != nil {
+		if err := w.checkoutChange(ch, t, conv, b); err != nil {
 			return err
 		}
 	}
@@ -620,7 +657,7 @@ func (w *Worktree) validChange(ch merkletrie.Change) error {
 	return nil
 }
 
-func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *indexBuilder) error {
+func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, conv *contentConverter, idx *indexBuilder) error {
 	a, err := ch.Action()
 	if err != nil {
 		return err
@@ -647,7 +684,7 @@ func (w *Worktree) checkoutChange(ch merkletrie.Change, t *object.Tree, idx *ind
 		return w.checkoutChangeSubmodule(name, a, e, idx)
 	}
 
-	return w.checkoutChangeRegularFile(name, a, t, e, idx)
+	return w.checkoutChangeRegularFile(name, a, t, e, conv, idx)
 }
 
 func (w *Worktree) containsUnstagedChanges() (bool, error) {
@@ -733,6 +770,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	a merkletrie.Action,
 	t *object.Tree,
 	e *object.TreeEntry,
+	conv *contentConverter,
 	idx *indexBuilder,
 ) error {
 	switch a {
@@ -752,7 +790,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 			return err
 		}
 
-		if err := w.checkoutFile(f); err != nil {
+		if err := w.checkoutFile(f, conv); err != nil {
 			return err
 		}
 
@@ -762,7 +800,7 @@ func (w *Worktree) checkoutChangeRegularFile(name string,
 	return nil
 }
 
-func (w *Worktree) checkoutFile(f *object.File) (err error) {
+func (w *Worktree) checkoutFile(f *object.File, conv *contentConverter) (err error) {
 	mode, err := f.Mode.ToOSFileMode()
 	if err != nil {
 		return
@@ -772,6 +810,10 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 		return w.checkoutFileSymlink(f)
 	}
 
+	if conv != nil {
+		return w.checkoutConvertedFile(f, mode, conv)
+	}
+
 	from, err := f.Reader()
 	if err != nil {
 		return
@@ -791,6 +833,20 @@ func (w *Worktree) checkoutFile(f *object.File) (err error) {
 	return
 }
 
+func (w *Worktree) checkoutConvertedFile(f *object.File, mode os.FileMode, conv *contentConverter) error {
+	content, err := f.Contents()
+	if err != nil {
+		return err
+	}
+
+	converted, err := conv.toWorktree(f.Name, []byte(content))
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(w.Filesystem, f.Name, converted, mode.Perm())
+}
+
 func (w *Worktree) checkoutFileSymlink(f *object.File) (err error) {
 	// https://github.com/git/git/commit/10ecfa76491e4923988337b2e2243b05376b40de
 	if strings.EqualFold(f.Name, gitmodulesFile) {
@@ -928,6 +984,10 @@ func (w *Worktree) newSubmodule(fromModules, fromConfig *config.Submodule) *Subm
 
 	m.c = fromConfig
 	m.c.Path = fromModules.Path
+	if m.c.Ignore == "" {
+		m.c.Ignore = fromModules.Ignore
+	}
+
 	return m
 }
 
@@ -1020,148 +1080,6 @@ func (w *Worktree) doClean(status Status, opts *CleanOptions, dir string, files
 	return nil
 }
 
-// GrepResult is structure of a grep result.
-type GrepResult struct {
-	// FileName is the name of file which contains match.
-	FileName string
-	// LineNumber is the line number of a file at which a match was found.
-	LineNumber int
-	// Content is the content of the file at the matching line.
-	Content string
-	// TreeName is the name of the tree (reference name/commit hash) at
-	// which the match was performed.
-	TreeName string
-}
-
-func (gr GrepResult) String() string {
-	return fmt.Sprintf("%s:%s:%d:%s", gr.TreeName, gr.FileName, gr.LineNumber, gr.Content)
-}
-
-// Grep performs grep on a repository.
-func (r *Repository) Grep(opts *GrepOptions) ([]GrepResult, error) {
-	if err := opts.validate(r); err != nil {
-		return nil, err
-	}
-
-	// Obtain commit hash from options (CommitHash or ReferenceName).
-	var commitHash plumbing.Hash
-	// treeName contains the value of TreeName in GrepResult.
-	var treeName string
-
-	if opts.ReferenceName != "" {
-		ref, err := r.Reference(opts.ReferenceName, true)
-		if err != nil {
-			return nil, err
-		}
-		commitHash = ref.Hash()
-		treeName = opts.ReferenceName.String()
-	} else if !opts.CommitHash.IsZero() {
-		commitHash = opts.CommitHash
-		treeName = opts.CommitHash.String()
-	}
-
-	// Obtain a tree from the commit hash and get a tracked files iterator from
-	// the tree.
-	tree, err := r.getTreeFromCommitHash(commitHash)
-	if err != nil {
-		return nil, err
-	}
-	fileiter := tree.Files()
-
-	return findMatchInFiles(fileiter, treeName, opts)
-}
-
-// Grep performs grep on a worktree.
-func (w *Worktree) Grep(opts *GrepOptions) ([]GrepResult, error) {
-	return w.r.Grep(opts)
-}
-
-// findMatchInFiles takes a FileIter, worktree name and GrepOptions, and
-// returns a slice of GrepResult containing the result of regex pattern matching
-// in content of all the files.
-func findMatchInFiles(fileiter *object.FileIter, treeName string, opts *GrepOptions) ([]GrepResult, error) {
-	var results []GrepResult
-
-	err := fileiter.ForEach(func(file *object.File) error {
-		var fileInPathSpec bool
-
-		// When no pathspecs are provided, search all the files.
-		if len(opts.PathSpecs) == 0 {
-			fileInPathSpec = true
-		}
-
-		// Check if the file name matches with the pathspec. Break out of the
-		// loop once a match is found.
-		for _, pathSpec := range opts.PathSpecs {
-			if pathSpec != nil && pathSpec.MatchString(file.Name) {
-				fileInPathSpec = true
-				break
-			}
-		}
-
-		// If the file does not match with any of the pathspec, skip it.
-		if !fileInPathSpec {
-			return nil
-		}
-
-		grepResults, err := findMatchInFile(file, treeName, opts)
-		if err != nil {
-			return err
-		}
-		results = append(results, grepResults...)
-
-		return nil
-	})
-
-	return results, err
-}
-
-// findMatchInFile takes a single File, worktree name and GrepOptions,
-// and returns a slice of GrepResult containing the result of regex pattern
-// matching in the given file.
-func findMatchInFile(file *object.File, treeName string, opts *GrepOptions) ([]GrepResult, error) {
-	var grepResults []GrepResult
-
-	content, err := file.Contents()
-	if err != nil {
-		return grepResults, err
-	}
-
-	// Split the file content and parse line-by-line.
-	contentByLine := strings.Split(content, "\n")
-	for lineNum, cnt := range contentByLine {
-		addToResult := false
-
-		// Match the patterns and content. Break out of the loop once a
-		// match is found.
-		for _, pattern := range opts.Patterns {
-			if pattern != nil && pattern.MatchString(cnt) {
-				// Add to result only if invert match is not enabled.
-				if !opts.InvertMatch {
-					addToResult = true
-					break
-				}
-			} else if opts.InvertMatch {
-				// If matching fails, and invert match is enabled, add to
-				// results.
-				addToResult = true
-				break
-			}
-		}
-
-		if addToResult {
-			grepResults = append(grepResults, GrepResult{
-				FileName:   file.Name,
-				LineNumber: lineNum + 1,
-				Content:    cnt,
-				TreeName:   treeName,
-			})
-		}
-	}
-
-	return grepResults, nil
-}
-
 // will walk up the directory tree removing all encountered empty
 // directories, not just the one containing this file
 func rmFileAndDirsIfEmpty(fs billy.Filesystem, name string) error {
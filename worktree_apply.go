@@ -0,0 +1,627 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/format/mbox"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
+	"github.com/go-git/go-git/v6/utils/diff"
+)
+
+var (
+	// ErrApplyFileExists is returned when a patch creates a file that
+	// already exists.
+	ErrApplyFileExists = errors.New("file already exists")
+	// ErrApplyFileMissing is returned when a patch changes a file that does
+	// not exist.
+	ErrApplyFileMissing = errors.New("file does not exist")
+	// ErrApplyIndexMismatch is returned when a patch is applied to the
+	// worktree and the index, and the file of the worktree does not match
+	// the index.
+	ErrApplyIndexMismatch = errors.New("file does not match the index")
+	// ErrApplyRemovalLeavesContent is returned when a patch deleting a file
+	// does not remove all of its content.
+	ErrApplyRemovalLeavesContent = errors.New("removal patch leaves file contents")
+	// ErrApplyConflicts is returned when a patch is applied with a
+	// three-way merge leaving conflicts.
+	ErrApplyConflicts = errors.New("patch applied with conflicts")
+	// ErrApplyBeyondSymlink is returned when a patch changes a file through
+	// a symbolic link.
+	ErrApplyBeyondSymlink = errors.New("path is beyond a symbolic link")
+	// ErrAmNoPatch is returned by Worktree.Am when a message has no patch.
+	ErrAmNoPatch = errors.New("message without patch")
+)
+
+// ApplyFileResult tells how the patch of a file was applied.
+type ApplyFileResult struct {
+	// Path is the path of the file after the patch, or before it when it
+	// is deleted.
+	Path string
+	// Hunks are the results of the hunks of the patch of a text file.
+	Hunks []fdiff.HunkResult
+	// Merged is set when the patch was applied with a three-way merge, and
+	// Conflict when this merge left conflicts.
+	Merged, Conflict bool
+}
+
+// applyImage is the state of a file as it is being patched.
+type applyImage struct {
+	content []byte
+	mode    filemode.FileMode
+	exists  bool
+	// stages are the blobs of the base, ours and theirs versions of the
+	// file merged with conflicts.
+	stages []plumbing.Hash
+}
+
+// applier applies the patches of files to the images of the files read from
+// the worktree or the index.
+type applier struct {
+	w    *Worktree
+	opts *ApplyOptions
+	idx  *index.Index
+	conv *contentConverter
+
+	images map[string]*applyImage
+	// paths are the paths of the images changed, in order.
+	paths []string
+}
+
+// Apply applies a patch to the worktree, or to the index, like git apply.
+// The patch is read in the unified diff format extended by git, as written
+// by git diff and git format-patch, with the modes, renames, copies and
+// binary patches of the files.
+//
+// Either the whole patch applies or nothing is written, unless Reject is
+// set. The results of the files are returned along with the errors, which
+// tell which hunks do not apply with the fdiff.ApplyErrors.
+func (w *Worktree) Apply(patch io.Reader, opts *ApplyOptions) ([]ApplyFileResult, error) {
+	if opts == nil {
+		opts = &ApplyOptions{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	files, err := fdiff.NewDecoder(patch).Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	return w.apply(files, opts)
+}
+
+func (w *Worktree) apply(files []*fdiff.FileDiff, opts *ApplyOptions) ([]ApplyFileResult, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conv.close()
+
+	a := &applier{
+		w:      w,
+		opts:   opts,
+		idx:    idx,
+		conv:   conv,
+		images: make(map[string]*applyImage),
+	}
+
+	// The paths are checked before anything is read, a patch being unable
+	// to reach the git directory or the outside of the worktree.
+	for _, f := range files {
+		for _, path := range []string{f.OldPath, f.NewPath} {
+			if path == "" {
+				continue
+			}
+
+			if err := validPath(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var results []ApplyFileResult
+	var errs []error
+	fatal, conflicts := false, false
+	for _, f := range files {
+		if opts.Reverse {
+			f = f.Reverse()
+		}
+
+		result, err := a.applyFile(f)
+		if err != nil {
+			// The hunks that do not apply only leave their file unpatched
+			// when they are rejected.
+			var applyErr *fdiff.ApplyError
+			fatal = fatal || !opts.Reject || !errors.As(err, &applyErr)
+			errs = append(errs, err)
+		}
+
+		conflicts = conflicts || result.Conflict
+		results = append(results, result)
+	}
+
+	if fatal {
+		return results, errors.Join(errs...)
+	}
+
+	if !opts.Check {
+		if err := a.write(); err != nil {
+			return results, err
+		}
+	}
+
+	if conflicts {
+		errs = append(errs, ErrApplyConflicts)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+// applyFile applies the patch of a file to its image.
+func (a *applier) applyFile(f *fdiff.FileDiff) (ApplyFileResult, error) {
+	result := ApplyFileResult{Path: f.Path()}
+	for _, path := range []string{f.OldPath, f.NewPath} {
+		if path == "" {
+			continue
+		}
+
+		if err := a.checkSymlinks(path); err != nil {
+			return result, err
+		}
+	}
+
+	src := &applyImage{mode: filemode.Regular}
+	if !f.IsNew() {
+		var err error
+		if src, err = a.image(f.OldPath); err != nil {
+			return result, err
+		}
+
+		if !src.exists {
+			return result, fmt.Errorf("%s: %w", f.OldPath, ErrApplyFileMissing)
+		}
+	}
+
+	if !f.IsDelete() && (f.IsNew() || f.NewPath != f.OldPath) {
+		dst, err := a.image(f.NewPath)
+		if err != nil {
+			return result, err
+		}
+
+		if dst.exists {
+			return result, fmt.Errorf("%s: %w", f.NewPath, ErrApplyFileExists)
+		}
+	}
+
+	dst := &applyImage{mode: src.mode, exists: true}
+	if f.NewMode != filemode.Empty {
+		dst.mode = f.NewMode
+	}
+
+	var err error
+	dst.content, result.Hunks, err = a.applyContent(f, src.content)
+	var applyErr *fdiff.ApplyError
+	if err != nil && a.opts.ThreeWay && errors.As(err, &applyErr) {
+		var mergeErr error
+		if dst.content, dst.stages, mergeErr = a.merge(f, src.content); mergeErr == nil {
+			result.Hunks, err = nil, nil
+			result.Merged, result.Conflict = true, dst.stages != nil
+		}
+	}
+
+	if err != nil && !(a.opts.Reject && errors.As(err, &applyErr)) {
+		return result, err
+	}
+
+	if f.IsDelete() {
+		if len(dst.content) != 0 {
+			return result, fmt.Errorf("%s: %w", f.OldPath, ErrApplyRemovalLeavesContent)
+		}
+
+		a.set(f.OldPath, &applyImage{})
+		return result, err
+	}
+
+	if f.IsRename() {
+		a.set(f.OldPath, &applyImage{})
+	}
+
+	a.set(f.NewPath, dst)
+	return result, err
+}
+
+// applyContent applies the patch of a file to the content of its old file.
+// The binary patches without data are applied when the new blob is
+// available in the repository.
+func (a *applier) applyContent(f *fdiff.FileDiff, src []byte) ([]byte, []fdiff.HunkResult, error) {
+	if !f.Binary || f.BinaryHunk != nil {
+		return f.Apply(src, a.opts.Fuzz)
+	}
+
+	// The content of src is checked like the one of the binary hunks.
+	preimage := strings.Trim(f.OldHash, "0") == "" ||
+		strings.HasPrefix(plumbing.ComputeHash(plumbing.BlobObject, src).String(), f.OldHash)
+	if h, ok := plumbing.FromHex(f.NewHash); ok && preimage && len(f.NewHash) == h.HexSize() {
+		if content, err := a.blob(h); err == nil {
+			return content, nil, nil
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%w: %s", fdiff.ErrBinaryNoData, f.Path())
+}
+
+// merge applies the patch of a file to the blob of its index line, and
+// merges the changes with the ones of src. The stages of the file are
+// returned when the merge has conflicts.
+func (a *applier) merge(f *fdiff.FileDiff, src []byte) ([]byte, []plumbing.Hash, error) {
+	if f.IsNew() || f.Binary {
+		return nil, nil, ErrApplyConflicts
+	}
+
+	var base []byte
+	var baseHash plumbing.Hash
+	for _, h := range a.w.r.resolveHashPrefix(f.OldHash) {
+		content, err := a.blob(h)
+		if err == nil {
+			base, baseHash = content, h
+			break
+		}
+	}
+
+	if baseHash.IsZero() {
+		return nil, nil, plumbing.ErrObjectNotFound
+	}
+
+	theirs, _, err := f.Apply(base, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged, conflicts := diff.Merge(string(base), string(src), string(theirs), "ours", "theirs")
+	if !conflicts {
+		return []byte(merged), nil, nil
+	}
+
+	ours, err := storeBlob(a.w.r.Storer, src)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	theirsHash, err := storeBlob(a.w.r.Storer, theirs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return []byte(merged), []plumbing.Hash{baseHash, ours, theirsHash}, nil
+}
+
+// image returns the image of the file at path, read from the index or the
+// worktree when it was not patched yet.
+func (a *applier) image(path string) (*applyImage, error) {
+	if img, ok := a.images[path]; ok {
+		return img, nil
+	}
+
+	var e *index.Entry
+	for _, entry := range a.idx.Entries {
+		if entry.Name == path && entry.Stage == 0 {
+			e = entry
+			break
+		}
+	}
+
+	img := &applyImage{}
+	if !a.opts.Cached && !a.opts.Index {
+		return img, a.readWorktree(path, img)
+	}
+
+	if e != nil {
+		content, err := a.blob(e.Hash)
+		if err != nil {
+			return nil, err
+		}
+
+		img.content, img.mode, img.exists = content, e.Mode, true
+	}
+
+	if a.opts.Cached {
+		return img, nil
+	}
+
+	// The worktree must match the index when both are patched.
+	wt := &applyImage{}
+	if err := a.readWorktree(path, wt); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case e == nil && wt.exists:
+		return nil, fmt.Errorf("%s: %w", path, ErrApplyFileExists)
+	case e != nil && (!wt.exists || wt.mode != e.Mode ||
+		plumbing.ComputeHash(plumbing.BlobObject, wt.content) != e.Hash):
+		return nil, fmt.Errorf("%s: %w", path, ErrApplyIndexMismatch)
+	}
+
+	return img, nil
+}
+
+// checkSymlinks returns ErrApplyBeyondSymlink when a leading directory of
+// path is a symbolic link, in the patched files, or in the index or the
+// worktree the patch is applied to.
+func (a *applier) checkSymlinks(path string) error {
+	for i := 0; i < len(path); i++ {
+		if path[i] != '/' {
+			continue
+		}
+
+		dir := path[:i]
+		if img, ok := a.images[dir]; ok {
+			if img.exists && img.mode == filemode.Symlink {
+				return fmt.Errorf("%s: %w", path, ErrApplyBeyondSymlink)
+			}
+
+			continue
+		}
+
+		if a.opts.Cached || a.opts.Index {
+			for _, e := range a.idx.Entries {
+				if e.Name == dir && e.Mode == filemode.Symlink {
+					return fmt.Errorf("%s: %w", path, ErrApplyBeyondSymlink)
+				}
+			}
+		}
+
+		if a.opts.Cached {
+			continue
+		}
+
+		fi, err := a.w.Filesystem.Lstat(dir)
+		if err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s: %w", path, ErrApplyBeyondSymlink)
+		}
+	}
+
+	return nil
+}
+
+// readWorktree reads the file at path from the worktree into img, its
+// content being converted like when it is added to the index.
+func (a *applier) readWorktree(path string, img *applyImage) error {
+	fi, err := a.w.Filesystem.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if img.mode, err = filemode.NewFromOSFileMode(fi.Mode()); err != nil {
+		return err
+	}
+
+	img.exists = true
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := a.w.Filesystem.Readlink(path)
+		img.content = []byte(target)
+		return err
+	}
+
+	content, err := util.ReadFile(a.w.Filesystem, path)
+	if err != nil {
+		return err
+	}
+
+	img.content, err = a.conv.quiet().toGit(path, content, false)
+	return err
+}
+
+// set sets the image of the file at path.
+func (a *applier) set(path string, img *applyImage) {
+	if _, ok := a.images[path]; !ok {
+		a.paths = append(a.paths, path)
+	}
+
+	a.images[path] = img
+}
+
+func (a *applier) blob(h plumbing.Hash) ([]byte, error) {
+	b, err := a.w.r.BlobObject(h)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := b.Reader()
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// write writes the images of the patched files to the worktree and the
+// index.
+func (a *applier) write() error {
+	for _, path := range a.paths {
+		img := a.images[path]
+		if !a.opts.Cached {
+			if err := a.writeWorktree(path, img); err != nil {
+				return err
+			}
+		}
+
+		if a.opts.Cached || a.opts.Index {
+			if err := a.writeIndex(path, img); err != nil {
+				return err
+			}
+		}
+	}
+
+	if a.opts.Cached || a.opts.Index {
+		return a.w.r.Storer.SetIndex(a.idx)
+	}
+
+	return nil
+}
+
+// writeWorktree writes the file at path to the worktree.
+func (a *applier) writeWorktree(path string, img *applyImage) error {
+	if _, err := a.w.Filesystem.Lstat(path); err == nil {
+		if err := a.w.Filesystem.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	if !img.exists {
+		return rmFileAndDirsIfEmpty(a.w.Filesystem, path)
+	}
+
+	if img.mode == filemode.Symlink {
+		return a.w.Filesystem.Symlink(string(img.content), path)
+	}
+
+	mode, err := img.mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+
+	content, err := a.conv.toWorktree(path, img.content)
+	if err != nil {
+		return err
+	}
+
+	return util.WriteFile(a.w.Filesystem, path, content, mode.Perm())
+}
+
+// writeIndex writes the entries of the file at path to the index, with the
+// stat data of the worktree when it was written too.
+func (a *applier) writeIndex(path string, img *applyImage) error {
+	for {
+		if _, err := a.idx.Remove(path); err != nil {
+			break
+		}
+	}
+
+	if !img.exists {
+		return nil
+	}
+
+	if img.stages != nil {
+		for i, h := range img.stages {
+			e := a.idx.Add(path)
+			e.Hash, e.Mode, e.Stage = h, img.mode, index.Stage(i+1)
+		}
+
+		return nil
+	}
+
+	h, err := storeBlob(a.w.r.Storer, img.content)
+	if err != nil {
+		return err
+	}
+
+	if a.opts.Cached {
+		e := a.idx.Add(path)
+		e.Hash, e.Mode, e.Size = h, img.mode, uint32(len(img.content))
+		return nil
+	}
+
+	return a.w.doAddFileToIndex(a.idx, path, h)
+}
+
+// storeBlob writes the blob of content to s.
+func storeBlob(s storer.EncodedObjectStorer, content []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+	wr, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := wr.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if err := wr.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
+}
+
+// Am applies the patches of the messages of a mailbox, as written by git
+// format-patch, to the worktree and the index, and commits each of them
+// with the author, the date and the message of its email, like git am. The
+// hashes of the commits are returned.
+//
+// Am stops at the first patch that does not apply, the commits of the
+// previous ones being kept. With ThreeWay, the conflicts of the patch are
+// left in the worktree and the index.
+func (w *Worktree) Am(mailbox io.Reader, opts *AmOptions) ([]plumbing.Hash, error) {
+	if opts == nil {
+		opts = &AmOptions{}
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	var commits []plumbing.Hash
+	d := mbox.NewDecoder(mailbox)
+	for {
+		m, err := d.Decode()
+		if err == io.EOF {
+			return commits, nil
+		}
+
+		if err != nil {
+			return commits, err
+		}
+
+		files, err := fdiff.NewDecoder(strings.NewReader(m.Diff)).Decode()
+		if err != nil {
+			return commits, fmt.Errorf("%s: %w", m.Subject, err)
+		}
+
+		if len(files) == 0 {
+			return commits, fmt.Errorf("%s: %w", m.Subject, ErrAmNoPatch)
+		}
+
+		if _, err := w.apply(files, &ApplyOptions{Index: true, ThreeWay: opts.ThreeWay}); err != nil {
+			return commits, fmt.Errorf("%s: %w", m.Subject, err)
+		}
+
+		msg := m.Subject + "\n"
+		if m.Body != "" {
+			msg += "\n" + m.Body + "\n"
+		}
+
+		author := &object.Signature{Name: m.Name, Email: m.Email, When: m.Date}
+		h, err := w.Commit(msg, &CommitOptions{Author: author, Committer: opts.Committer})
+		if err != nil {
+			return commits, err
+		}
+
+		commits = append(commits, h)
+	}
+}
@@ -0,0 +1,404 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const applyTestPatch = `diff --git a/a.txt b/a.txt
+index 1191247..4a0c5d7 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,3 +1,3 @@
+ 1
+-2
++two
+ 3
+diff --git a/new.txt b/new.txt
+new file mode 100755
+index 0000000..7898192
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++a
+diff --git a/old.txt b/moved.txt
+similarity index 100%
+rename from old.txt
+rename to moved.txt
+`
+
+// indexBlob returns the blob of the file at path in the index, at stage 0.
+func indexBlob(t *testing.T, w *Worktree, path string) string {
+	t.Helper()
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+
+	e, err := idx.Entry(path)
+	if err == index.ErrEntryNotFound {
+		return ""
+	}
+
+	require.NoError(t, err)
+	blob, err := w.r.BlobObject(e.Hash)
+	require.NoError(t, err)
+	r, err := blob.Reader()
+	require.NoError(t, err)
+	defer r.Close()
+
+	var buf bytes.Buffer
+	_, err = buf.ReadFrom(r)
+	require.NoError(t, err)
+	return buf.String()
+}
+
+// worktreeFile returns the content of the file at path in the worktree, or
+// an empty string when it does not exist.
+func worktreeFile(t *testing.T, dir, path string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(filepath.Join(dir, path))
+	if os.IsNotExist(err) {
+		return ""
+	}
+
+	require.NoError(t, err)
+	return string(content)
+}
+
+func newApplyTestRepository(t *testing.T) (*Worktree, string) {
+	return newStatusTestRepository(t, map[string]string{
+		"a.txt":   "1\n2\n3\n",
+		"old.txt": "old\n",
+	})
+}
+
+func TestWorktreeApply(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+
+	results, err := w.Apply(strings.NewReader(applyTestPatch), nil)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, "a.txt", results[0].Path)
+	assert.Equal(t, []fdiff.HunkResult{{Line: 1}}, results[0].Hunks)
+	assert.Equal(t, "moved.txt", results[2].Path)
+
+	assert.Equal(t, "1\ntwo\n3\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "a\n", worktreeFile(t, dir, "new.txt"))
+	assert.Equal(t, "old\n", worktreeFile(t, dir, "moved.txt"))
+	assert.Equal(t, "", worktreeFile(t, dir, "old.txt"))
+
+	fi, err := os.Stat(filepath.Join(dir, "new.txt"))
+	require.NoError(t, err)
+	assert.NotZero(t, fi.Mode()&0o100)
+
+	// The index is left untouched.
+	assert.Equal(t, "1\n2\n3\n", indexBlob(t, w, "a.txt"))
+	assert.Equal(t, "", indexBlob(t, w, "new.txt"))
+	assert.Equal(t, "old\n", indexBlob(t, w, "old.txt"))
+}
+
+func TestWorktreeApplyIndex(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+
+	_, err := w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Index: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1\ntwo\n3\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "1\ntwo\n3\n", indexBlob(t, w, "a.txt"))
+	assert.Equal(t, "a\n", indexBlob(t, w, "new.txt"))
+	assert.Equal(t, "old\n", indexBlob(t, w, "moved.txt"))
+	assert.Equal(t, "", indexBlob(t, w, "old.txt"))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	for path, s := range status {
+		assert.Equal(t, Unmodified, s.Worktree, path)
+	}
+}
+
+func TestWorktreeApplyIndexMismatch(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("1\n2\n3\n4\n"), 0o644))
+
+	_, err := w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Index: true})
+	assert.ErrorIs(t, err, ErrApplyIndexMismatch)
+	assert.Equal(t, "1\n2\n3\n4\n", worktreeFile(t, dir, "a.txt"))
+}
+
+func TestWorktreeApplyCached(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+
+	_, err := w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Cached: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1\ntwo\n3\n", indexBlob(t, w, "a.txt"))
+	assert.Equal(t, "a\n", indexBlob(t, w, "new.txt"))
+	assert.Equal(t, "old\n", indexBlob(t, w, "moved.txt"))
+
+	// The worktree is left untouched.
+	assert.Equal(t, "1\n2\n3\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "", worktreeFile(t, dir, "new.txt"))
+	assert.Equal(t, "old\n", worktreeFile(t, dir, "old.txt"))
+}
+
+func TestWorktreeApplyCheck(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+
+	_, err := w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Index: true, Check: true})
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "", worktreeFile(t, dir, "new.txt"))
+	assert.Equal(t, "1\n2\n3\n", indexBlob(t, w, "a.txt"))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("b\n"), 0o644))
+	_, err = w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Check: true})
+	assert.ErrorIs(t, err, ErrApplyFileExists)
+}
+
+func TestWorktreeApplyReverse(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+
+	_, err := w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Index: true})
+	require.NoError(t, err)
+	_, err = w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Index: true, Reverse: true})
+	require.NoError(t, err)
+
+	assert.Equal(t, "1\n2\n3\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "old\n", worktreeFile(t, dir, "old.txt"))
+	assert.Equal(t, "", worktreeFile(t, dir, "new.txt"))
+	assert.Equal(t, "", worktreeFile(t, dir, "moved.txt"))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+}
+
+func TestWorktreeApplyReject(t *testing.T) {
+	w, dir := newApplyTestRepository(t)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("1\nTWO\n3\n"), 0o644))
+
+	// Nothing is written when a hunk does not apply.
+	results, err := w.Apply(strings.NewReader(applyTestPatch), nil)
+	var applyErr *fdiff.ApplyError
+	require.ErrorAs(t, err, &applyErr)
+	assert.Equal(t, "a.txt", applyErr.Path)
+	assert.Equal(t, []*fdiff.HunkError{{Hunk: 1, Line: 1}}, applyErr.Hunks)
+	require.Len(t, results, 3)
+	assert.Equal(t, 1, results[0].Hunks[0].Line)
+	assert.Equal(t, applyErr.Hunks[0], results[0].Hunks[0].Err)
+	assert.Equal(t, "", worktreeFile(t, dir, "new.txt"))
+
+	// The other files are patched with Reject.
+	_, err = w.Apply(strings.NewReader(applyTestPatch), &ApplyOptions{Reject: true})
+	require.ErrorIs(t, err, fdiff.ErrHunkFailed)
+	assert.Equal(t, "1\nTWO\n3\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "a\n", worktreeFile(t, dir, "new.txt"))
+	assert.Equal(t, "old\n", worktreeFile(t, dir, "moved.txt"))
+}
+
+func TestWorktreeApplyThreeWay(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt": "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+	})
+
+	base := indexBlob(t, w, "a.txt")
+	theirs := strings.Replace(base, "2\n", "two\n", 1)
+	var patch bytes.Buffer
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte(theirs), 0o644))
+	p, err := w.Diff(nil)
+	require.NoError(t, err)
+	require.NoError(t, fdiff.NewUnifiedEncoder(&patch, 3).Encode(p))
+
+	// The change of the patch is merged with the ones of the file.
+	ours := strings.Replace(base, "5\n", "five\n", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte(ours), 0o644))
+	_, err = w.Add("a.txt")
+	require.NoError(t, err)
+
+	_, err = w.Apply(bytes.NewReader(patch.Bytes()), nil)
+	require.ErrorIs(t, err, fdiff.ErrHunkFailed)
+
+	results, err := w.Apply(bytes.NewReader(patch.Bytes()), &ApplyOptions{ThreeWay: true})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Merged)
+	assert.False(t, results[0].Conflict)
+	assert.Equal(t, "1\ntwo\n3\n4\nfive\n6\n7\n8\n9\n", worktreeFile(t, dir, "a.txt"))
+	assert.Equal(t, "1\ntwo\n3\n4\nfive\n6\n7\n8\n9\n", indexBlob(t, w, "a.txt"))
+
+	// The conflicting changes are left in the worktree and the index.
+	ours = strings.Replace(base, "2\n", "TWO\n", 1)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte(ours), 0o644))
+	_, err = w.Add("a.txt")
+	require.NoError(t, err)
+
+	results, err = w.Apply(bytes.NewReader(patch.Bytes()), &ApplyOptions{ThreeWay: true})
+	require.ErrorIs(t, err, ErrApplyConflicts)
+	assert.True(t, results[0].Conflict)
+	assert.Equal(t, "1\n<<<<<<< ours\nTWO\n=======\ntwo\n>>>>>>> theirs\n3\n4\n5\n6\n7\n8\n9\n", worktreeFile(t, dir, "a.txt"))
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	var stages []index.Stage
+	for _, e := range idx.Entries {
+		if e.Name == "a.txt" {
+			stages = append(stages, e.Stage)
+		}
+	}
+
+	assert.Equal(t, []index.Stage{index.AncestorMode, index.OurMode, index.TheirMode}, stages)
+}
+
+func TestWorktreeAm(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt": "1\n2\n3\n",
+	})
+
+	base, err := w.r.Head()
+	require.NoError(t, err)
+
+	when := time.Unix(1112911993, 0).In(time.FixedZone("", 2*3600))
+	author := &object.Signature{Name: "Doe, John", Email: "j@d.e", When: when}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("1\ntwo\n3\n"), 0o644))
+	_, err = w.Add("a.txt")
+	require.NoError(t, err)
+	first, err := w.Commit("first\n\nWith a body.\n", &CommitOptions{Author: author})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b\n"), 0o644))
+	_, err = w.Add("b.txt")
+	require.NoError(t, err)
+	second, err := w.Commit("second\n", &CommitOptions{Author: author})
+	require.NoError(t, err)
+
+	var mbox bytes.Buffer
+	require.NoError(t, w.r.FormatPatch(&mbox, &FormatPatchOptions{Upstream: base.Hash()}))
+
+	require.NoError(t, w.Reset(&ResetOptions{Commit: base.Hash(), Mode: HardReset}))
+
+	committer := &object.Signature{Name: "bar", Email: "bar@bar.bar", When: time.Now()}
+	commits, err := w.Am(&mbox, &AmOptions{Committer: committer})
+	require.NoError(t, err)
+	require.Len(t, commits, 2)
+
+	for i, want := range []plumbing.Hash{first, second} {
+		expected, err := w.r.CommitObject(want)
+		require.NoError(t, err)
+		c, err := w.r.CommitObject(commits[i])
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.TreeHash, c.TreeHash)
+		assert.Equal(t, expected.Message, c.Message)
+		assert.Equal(t, expected.Author.Name, c.Author.Name)
+		assert.Equal(t, expected.Author.Email, c.Author.Email)
+		assert.True(t, expected.Author.When.Equal(c.Author.When))
+		assert.Equal(t, "bar", c.Committer.Name)
+	}
+
+	head, err := w.r.Head()
+	require.NoError(t, err)
+	assert.Equal(t, commits[1], head.Hash())
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+}
+
+// newFilePatch returns the patch creating the file at path.
+func newFilePatch(path string) string {
+	return "diff --git a/" + path + " b/" + path + "\n" +
+		"new file mode 100755\n" +
+		"--- /dev/null\n" +
+		"+++ b/" + path + "\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+echo pwned\n"
+}
+
+// newSymlinkApplyTestRepository returns a repository whose committed link
+// is a symbolic link to the returned directory, outside of the worktree.
+func newSymlinkApplyTestRepository(t *testing.T) (w *Worktree, dir, outside string) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links are not supported")
+	}
+
+	w, dir = newApplyTestRepository(t)
+	outside = t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	_, err := w.Add("link")
+	require.NoError(t, err)
+	_, err = w.Commit("link\n", &CommitOptions{Author: &object.Signature{Name: "a", Email: "a@b.c", When: time.Now()}})
+	require.NoError(t, err)
+	return w, dir, outside
+}
+
+func TestWorktreeApplyInvalidPaths(t *testing.T) {
+	w, dir, outside := newSymlinkApplyTestRepository(t)
+
+	for _, opts := range []*ApplyOptions{nil, {Index: true}, {Cached: true}} {
+		_, err := w.Apply(strings.NewReader(newFilePatch(".git/hooks/pre-commit")), opts)
+		assert.Error(t, err)
+		_, err = os.Stat(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+		assert.True(t, os.IsNotExist(err))
+
+		_, err = w.Apply(strings.NewReader(newFilePatch("../x")), opts)
+		assert.Error(t, err)
+		_, err = os.Stat(filepath.Join(dir, "..", "x"))
+		assert.True(t, os.IsNotExist(err))
+
+		_, err = w.Apply(strings.NewReader(newFilePatch("link/x")), opts)
+		assert.ErrorIs(t, err, ErrApplyBeyondSymlink)
+		_, err = os.Stat(filepath.Join(outside, "x"))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	// Nor through a link created by the patch itself.
+	patch := "diff --git a/other b/other\n" +
+		"new file mode 120000\n" +
+		"--- /dev/null\n" +
+		"+++ b/other\n" +
+		"@@ -0,0 +1 @@\n" +
+		"+" + outside + "\n" +
+		"\\ No newline at end of file\n" + newFilePatch("other/x")
+	_, err := w.Apply(strings.NewReader(patch), nil)
+	assert.ErrorIs(t, err, ErrApplyBeyondSymlink)
+	_, err = os.Lstat(filepath.Join(dir, "other"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWorktreeAmInvalidPaths(t *testing.T) {
+	w, dir, outside := newSymlinkApplyTestRepository(t)
+
+	for _, path := range []string{".git/hooks/pre-commit", "../x", "link/x"} {
+		mbox := "From: a <a@b.c>\nSubject: [PATCH] hook\n\nbody\n---\n" + newFilePatch(path)
+		commits, err := w.Am(strings.NewReader(mbox), nil)
+		assert.Error(t, err, path)
+		assert.Empty(t, commits)
+	}
+
+	_, err := os.Stat(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(dir, "..", "x"))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(outside, "x"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWorktreeAmNoPatch(t *testing.T) {
+	w, _ := newApplyTestRepository(t)
+
+	_, err := w.Am(strings.NewReader("From: a <a@b.c>\nSubject: [PATCH] empty\n\nbody\n"), nil)
+	assert.ErrorIs(t, err, ErrAmNoPatch)
+}
@@ -108,12 +108,19 @@ func (w *Worktree) autoAddModifiedAndDeleted() error {
 		return err
 	}
 
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer conv.close()
+
 	for path, fs := range s {
 		if fs.Worktree != Modified && fs.Worktree != Deleted {
 			continue
 		}
 
-		if _, _, err := w.doAddFile(idx, s, path, nil); err != nil {
+		if _, _, err := w.doAddFile(idx, conv, s, path, nil); err != nil {
 			return err
 		}
 
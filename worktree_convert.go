@@ -0,0 +1,367 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/convert"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/lfs"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/utils/merkletrie/filesystem"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+const gitattributesFile = ".gitattributes"
+
+// convertAttributes are the attributes driving the content conversions.
+var convertAttributes = []string{
+	convert.TextAttr, convert.EOLAttr, convert.CRLFAttr, convert.FilterAttr,
+}
+
+// contentConverter applies the conversions configured through gitattributes
+// and the config to the content of the files moving between the worktree and
+// the object database: the filter drivers and the end-of-line conversion.
+type contentConverter struct {
+	w       *Worktree
+	idx     *index.Index
+	cfg     convert.Config
+	matcher gitattributes.Matcher
+
+	// drivers are the filter drivers of the config, processes the
+	// long-running ones started so far and dir their working directory.
+	drivers   map[string]*config.Filter
+	processes map[string]*convert.ProcessFilter
+	dir       string
+
+	// mu guards processes and the reads of the staged blobs, so that the
+	// converter can be used concurrently, the rest being read-only. It is
+	// shared by the copies of the converter.
+	mu *sync.Mutex
+
+	// hashOnly is set when the content is converted only to be hashed.
+	hashOnly bool
+	// lfsAuth authenticates the downloads of the missing LFS objects.
+	lfsAuth transport.AuthMethod
+}
+
+// newContentConverter returns the contentConverter of the worktree, or nil
+// when no conversion is configured. idx is the index used to look up the
+// staged version of the files, it can be nil. The attributes are read from
+// the .gitattributes files of t when given, as done on checkout, otherwise
+// from the ones in the worktree.
+func (w *Worktree) newContentConverter(idx *index.Index, t *object.Tree) (*contentConverter, error) {
+	cfg, err := w.r.ConfigScoped(config.SystemScope)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []gitattributes.MatchAttribute
+	if t != nil {
+		patterns, err = readTreeAttributes(t)
+	} else {
+		patterns, err = gitattributes.ReadPatterns(w.Filesystem, nil)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	c := &contentConverter{
+		w:         w,
+		idx:       idx,
+		cfg:       convert.NewConfig(cfg.Core.AutoCRLF, cfg.Core.EOL, cfg.Core.SafeCRLF),
+		drivers:   cfg.Filters,
+		processes: make(map[string]*convert.ProcessFilter),
+		mu:        &sync.Mutex{},
+	}
+
+	if len(patterns) == 0 && c.cfg.AutoCRLF == convert.AutoCRLFFalse {
+		return nil, nil
+	}
+
+	c.matcher = gitattributes.NewMatcher(patterns)
+	c.cfg.Warn = func(err error) {
+		trace.General.Printf("warning: %s", err)
+	}
+
+	if w.Filesystem != nil {
+		if root := w.Filesystem.Root(); root != "" {
+			if fi, err := os.Stat(root); err == nil && fi.IsDir() {
+				c.dir = root
+			}
+		}
+	}
+
+	return c, nil
+}
+
+// close stops the long-running filter processes.
+func (c *contentConverter) close() {
+	if c == nil {
+		return
+	}
+
+	for name, p := range c.processes {
+		if err := p.Close(); err != nil {
+			trace.General.Printf("filter %q: %s", name, err)
+		}
+	}
+}
+
+// readTreeAttributes reads the patterns of the .gitattributes files of t, in
+// ascending order of priority. Only the trees and the .gitattributes blobs
+// are read.
+func readTreeAttributes(t *object.Tree) ([]gitattributes.MatchAttribute, error) {
+	type dirTree struct {
+		tree   *object.Tree
+		domain []string
+	}
+
+	// The trees are walked breadth first, the deeper files having the
+	// higher priority.
+	var patterns []gitattributes.MatchAttribute
+	queue := []dirTree{{tree: t}}
+	for len(queue) != 0 {
+		dir := queue[0]
+		queue = queue[1:]
+
+		for i := range dir.tree.Entries {
+			e := &dir.tree.Entries[i]
+			switch {
+			case e.Mode == filemode.Dir:
+				sub, err := dir.tree.Tree(e.Name)
+				if err != nil {
+					return nil, err
+				}
+
+				domain := append(append([]string(nil), dir.domain...), e.Name)
+				queue = append(queue, dirTree{tree: sub, domain: domain})
+			case e.Name == gitattributesFile && e.Mode.IsFile():
+				f, err := dir.tree.TreeEntryFile(e)
+				if err != nil {
+					return nil, err
+				}
+
+				content, err := f.Contents()
+				if err != nil {
+					return nil, err
+				}
+
+				ps, err := gitattributes.ReadAttributes(strings.NewReader(content), dir.domain, dir.domain == nil)
+				if err != nil {
+					return nil, err
+				}
+
+				patterns = append(patterns, ps...)
+			}
+		}
+	}
+
+	return patterns, nil
+}
+
+func (c *contentConverter) attributes(path string) (action convert.CRLFAction, driver string) {
+	attrs, _ := c.matcher.Match(strings.Split(filepath.ToSlash(path), "/"), convertAttributes)
+	if a := attrs[convert.FilterAttr]; a != nil && a.IsValueSet() {
+		driver = a.Value()
+	}
+
+	return c.cfg.Action(attrs), driver
+}
+
+// driver returns the filter driver with the given name, as registered with
+// convert.RegisterFilter or configured by filter.<name> options, and whether
+// it is required. The returned filter is nil when there is no command for
+// the given direction. The lfs driver is implemented natively, unless one is
+// registered.
+func (c *contentConverter) driver(name string, smudge bool) (f convert.Filter, required bool) {
+	d := c.drivers[name]
+	if d != nil {
+		required = d.Required
+	}
+
+	if f, ok := convert.LookupFilter(name); ok {
+		return f, required
+	}
+
+	if name == lfs.FilterName {
+		if s, ok := lfsStorage(c.w.r.Storer); ok {
+			return &lfsFilter{r: c.w.r, s: s, auth: c.lfsAuth, hashOnly: c.hashOnly}, required
+		}
+	}
+
+	switch {
+	case d == nil:
+		return nil, false
+	case d.Process != "":
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		p, ok := c.processes[name]
+		if !ok {
+			p = convert.NewProcessFilter(d.Process, c.dir)
+			c.processes[name] = p
+		}
+
+		return p, required
+	case smudge && d.Smudge == "", !smudge && d.Clean == "":
+		return nil, required
+	}
+
+	return &convert.CommandFilter{CleanCommand: d.Clean, SmudgeCommand: d.Smudge, Dir: c.dir}, required
+}
+
+// applyFilter runs the clean, or smudge, command of the filter driver name.
+// Failures of drivers that are not required leave the content untouched.
+func (c *contentConverter) applyFilter(path, name string, content []byte, smudge bool) ([]byte, error) {
+	if name == "" {
+		return content, nil
+	}
+
+	f, required := c.driver(name, smudge)
+	if f == nil {
+		if required {
+			return nil, fmt.Errorf("%w: %s: missing command of required filter %q",
+				convert.ErrFilterFailed, path, name)
+		}
+
+		return content, nil
+	}
+
+	var out []byte
+	var err error
+	if smudge {
+		out, err = f.Smudge(filepath.ToSlash(path), content)
+	} else {
+		out, err = f.Clean(filepath.ToSlash(path), content)
+	}
+
+	if err != nil {
+		if required {
+			return nil, err
+		}
+
+		trace.General.Printf("warning: %s", err)
+		return content, nil
+	}
+
+	return out, nil
+}
+
+// toGit converts the content of the worktree file at path to the content of
+// its blob, running the clean filter before the end-of-line conversion. When
+// renormalize is set, the line endings of the staged version of the file are
+// ignored.
+func (c *contentConverter) toGit(path string, content []byte, renormalize bool) ([]byte, error) {
+	if c == nil {
+		return content, nil
+	}
+
+	action, driver := c.attributes(path)
+	content, err := c.applyFilter(path, driver, content, false)
+	if err != nil || action == convert.CRLFBinary {
+		return content, err
+	}
+
+	var indexHasCRLF bool
+	if !renormalize && bytes.Contains(content, []byte("\r\n")) {
+		indexHasCRLF = c.indexHasCRLF(path)
+	}
+
+	return c.cfg.ToGit(path, content, action, indexHasCRLF, renormalize)
+}
+
+// toWorktree converts the content of the blob of path to the content written
+// to the worktree, running the smudge filter after the end-of-line
+// conversion.
+func (c *contentConverter) toWorktree(path string, content []byte) ([]byte, error) {
+	if c == nil {
+		return content, nil
+	}
+
+	action, driver := c.attributes(path)
+	return c.applyFilter(path, driver, c.cfg.ToWorktree(content, action), true)
+}
+
+func (c *contentConverter) indexHasCRLF(path string) bool {
+	if c.idx == nil {
+		return false
+	}
+
+	e, err := c.idx.Entry(filepath.ToSlash(path))
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	obj, err := c.w.r.Storer.EncodedObject(plumbing.BlobObject, e.Hash)
+	if err != nil {
+		return false
+	}
+
+	r, err := obj.Reader()
+	if err != nil {
+		return false
+	}
+
+	defer r.Close()
+
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return false
+	}
+
+	return convert.HasCRLF(content)
+}
+
+// filter returns the filesystem.ContentFilter used to hash the worktree file
+// at path when comparing it with the index, or nil when the file is hashed
+// as is. Round trip warnings are not reported while hashing.
+func (c *contentConverter) filter(path string) filesystem.ContentFilter {
+	if action, driver := c.attributes(path); action == convert.CRLFBinary && driver == "" {
+		return nil
+	}
+
+	return func(content []byte) ([]byte, error) {
+		return c.toGit(path, content, false)
+	}
+}
+
+// filterOptions returns the filesystem.Options used to compare the worktree
+// with the index.
+func (c *contentConverter) filterOptions() filesystem.Options {
+	if c == nil {
+		return filesystem.Options{}
+	}
+
+	return filesystem.Options{Filter: c.quiet().filter}
+}
+
+// quiet returns a copy of c converting the content only to hash or diff
+// it: the round trip warnings are not reported and the LFS objects are not
+// stored.
+func (c *contentConverter) quiet() *contentConverter {
+	if c == nil {
+		return nil
+	}
+
+	quiet := *c
+	quiet.cfg.SafeCRLF = convert.SafeCRLFFalse
+	quiet.cfg.Warn = nil
+	quiet.hashOnly = true
+
+	return &quiet
+}
@@ -0,0 +1,255 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/convert"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage/memory"
+)
+
+func newConvertTestWorktree(t *testing.T, autocrlf, safecrlf string) (*Repository, *Worktree, billy.Filesystem) {
+	fs := memfs.New()
+	r, err := Init(memory.NewStorage(), WithWorkTree(fs))
+	require.NoError(t, err)
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Core.AutoCRLF = autocrlf
+	cfg.Core.SafeCRLF = safecrlf
+	require.NoError(t, r.SetConfig(cfg))
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+	return r, w, fs
+}
+
+func stagedContent(t *testing.T, r *Repository, path string) string {
+	idx, err := r.Storer.Index()
+	require.NoError(t, err)
+	e, err := idx.Entry(path)
+	require.NoError(t, err)
+
+	obj, err := r.Storer.EncodedObject(plumbing.BlobObject, e.Hash)
+	require.NoError(t, err)
+	rd, err := obj.Reader()
+	require.NoError(t, err)
+	defer rd.Close()
+	content, err := io.ReadAll(rd)
+	require.NoError(t, err)
+	return string(content)
+}
+
+func commitAll(t *testing.T, w *Worktree) plumbing.Hash {
+	h, err := w.Commit("test", &CommitOptions{
+		All:    true,
+		Author: &object.Signature{Name: "go-git", Email: "go-git@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	return h
+}
+
+func TestWorktreeAutoCRLF(t *testing.T) {
+	r, w, fs := newConvertTestWorktree(t, "true", "false")
+
+	require.NoError(t, util.WriteFile(fs, ".gitattributes", []byte("*.dat -text\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("foo\r\nbar\r\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "b.dat", []byte("foo\r\nbar\r\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "c.bin", []byte("foo\r\n\x00"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+
+	assert.Equal(t, "foo\nbar\n", stagedContent(t, r, "a.txt"))
+	assert.Equal(t, "foo\r\nbar\r\n", stagedContent(t, r, "b.dat"))
+	assert.Equal(t, "foo\r\n\x00", stagedContent(t, r, "c.bin"))
+
+	commitAll(t, w)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+
+	require.NoError(t, fs.Remove("a.txt"))
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+
+	content, err := util.ReadFile(fs, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "foo\r\nbar\r\n", string(content))
+
+	status, err = w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+}
+
+func TestWorktreeCheckoutEOLAttribute(t *testing.T) {
+	_, w, fs := newConvertTestWorktree(t, "", "")
+
+	require.NoError(t, util.WriteFile(fs, ".gitattributes", []byte("*.txt text eol=crlf\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("foo\nbar\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	commit := commitAll(t, w)
+
+	// A fresh checkout reads the attributes from the tree being checked out.
+	require.NoError(t, fs.Remove(".gitattributes"))
+	require.NoError(t, fs.Remove("a.txt"))
+	require.NoError(t, w.Checkout(&CheckoutOptions{Hash: commit, Force: true}))
+
+	content, err := util.ReadFile(fs, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "foo\r\nbar\r\n", string(content))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+}
+
+func TestReadTreeAttributes(t *testing.T) {
+	r, w, fs := newConvertTestWorktree(t, "", "")
+
+	require.NoError(t, util.WriteFile(fs, ".gitattributes", []byte("*.txt text eol=crlf\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "sub/.gitattributes", []byte("*.txt -text\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("a\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "sub/deep/b.txt", []byte("b\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "other/c.txt", []byte("c\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+
+	c, err := r.CommitObject(commitAll(t, w))
+	require.NoError(t, err)
+	tree, err := c.Tree()
+	require.NoError(t, err)
+
+	patterns, err := readTreeAttributes(tree)
+	require.NoError(t, err)
+	require.Len(t, patterns, 2)
+
+	// The attributes of the deeper files take precedence.
+	matcher := gitattributes.NewMatcher(patterns)
+	for path, text := range map[string]bool{
+		"a.txt":          true,
+		"other/c.txt":    true,
+		"sub/deep/b.txt": false,
+	} {
+		attrs, _ := matcher.Match(strings.Split(path, "/"), []string{convert.TextAttr})
+		require.NotNil(t, attrs[convert.TextAttr], path)
+		assert.Equal(t, text, attrs[convert.TextAttr].IsSet(), path)
+	}
+}
+
+func TestWorktreeSafeCRLF(t *testing.T) {
+	_, w, fs := newConvertTestWorktree(t, "input", "true")
+
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("foo\r\n"), 0o644))
+	_, err := w.Add("a.txt")
+
+	var serr *convert.SafeCRLFError
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, "a.txt", serr.Path)
+}
+
+func TestWorktreeAddRenormalize(t *testing.T) {
+	r, w, fs := newConvertTestWorktree(t, "", "")
+
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("foo\r\nbar\r\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	commitAll(t, w)
+	assert.Equal(t, "foo\r\nbar\r\n", stagedContent(t, r, "a.txt"))
+
+	// Files with CRLF in the index are not converted by text=auto.
+	require.NoError(t, util.WriteFile(fs, ".gitattributes", []byte("* text=auto\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	assert.Equal(t, "foo\r\nbar\r\n", stagedContent(t, r, "a.txt"))
+
+	require.NoError(t, w.AddWithOptions(&AddOptions{Renormalize: true}))
+	assert.Equal(t, "foo\nbar\n", stagedContent(t, r, "a.txt"))
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Modified, status.File("a.txt").Staging)
+	assert.Equal(t, Unmodified, status.File("a.txt").Worktree)
+}
+
+type upperFilter struct{}
+
+func (upperFilter) Clean(_ string, content []byte) ([]byte, error) {
+	return bytes.ToUpper(content), nil
+}
+
+func (upperFilter) Smudge(_ string, content []byte) ([]byte, error) {
+	return bytes.ToLower(content), nil
+}
+
+func TestWorktreeRegisteredFilter(t *testing.T) {
+	convert.RegisterFilter("upper", upperFilter{})
+	defer convert.UnregisterFilter("upper")
+
+	r, w, fs := newConvertTestWorktree(t, "", "")
+
+	require.NoError(t, util.WriteFile(fs, ".gitattributes", []byte("*.txt filter=upper\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("foo\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	assert.Equal(t, "FOO\n", stagedContent(t, r, "a.txt"))
+	commitAll(t, w)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+
+	require.NoError(t, fs.Remove("a.txt"))
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+
+	content, err := util.ReadFile(fs, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "foo\n", string(content))
+}
+
+func TestWorktreeCommandFilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("filter commands require a POSIX shell")
+	}
+
+	r, w, fs := newConvertTestWorktree(t, "", "")
+
+	cfg, err := r.Config()
+	require.NoError(t, err)
+	cfg.Filters["rot13"] = &config.Filter{
+		Name:   "rot13",
+		Clean:  "tr a-zA-Z n-za-mN-ZA-M",
+		Smudge: "tr a-zA-Z n-za-mN-ZA-M",
+	}
+	cfg.Filters["missing"] = &config.Filter{Name: "missing", Required: true}
+	require.NoError(t, r.SetConfig(cfg))
+
+	require.NoError(t, util.WriteFile(fs, ".gitattributes", []byte("*.txt filter=rot13\n*.req filter=missing\n"), 0o644))
+	require.NoError(t, util.WriteFile(fs, "a.txt", []byte("hello\n"), 0o644))
+	require.NoError(t, w.AddWithOptions(&AddOptions{All: true}))
+	assert.Equal(t, "uryyb\n", stagedContent(t, r, "a.txt"))
+	commitAll(t, w)
+
+	status, err := w.Status()
+	require.NoError(t, err)
+	assert.True(t, status.IsClean(), status.String())
+
+	require.NoError(t, fs.Remove("a.txt"))
+	require.NoError(t, w.Reset(&ResetOptions{Mode: HardReset}))
+
+	content, err := util.ReadFile(fs, "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", string(content))
+
+	require.NoError(t, util.WriteFile(fs, "a.req", []byte("foo\n"), 0o644))
+	_, err = w.Add("a.req")
+	assert.ErrorIs(t, err, convert.ErrFilterFailed)
+}
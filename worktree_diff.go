@@ -0,0 +1,219 @@
+package git
+
+import (
+	"context"
+	"os"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/storage"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/go-git/go-git/v6/storage/transactional"
+)
+
+// Diff returns the patch of the changes between the worktree, the index and
+// the trees, like `git diff`: the changes of the worktree not staged in the
+// index by default, see DiffOptions.
+//
+// Only the tracked files of the worktree are compared, the intent-to-add
+// entries of the index being new files of the worktree. The files are
+// binary as told by their diff attribute, or by their content. The
+// submodules are compared by their commit, the changes of their worktree
+// being ignored. The unmerged files are left out.
+func (w *Worktree) Diff(opts *DiffOptions) (*object.Patch, error) {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+
+	if err := opts.Validate(w.r); err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	// The trees of the index and of the worktree are only written to a
+	// temporary storage.
+	s := transactional.NewStorage(w.r.Storer, memory.NewStorage())
+
+	var from, to *object.Tree
+	if opts.Cached {
+		to, err = w.indexTree(s, idx)
+		if err == nil {
+			from, err = w.headTree(s, opts.Tree)
+		}
+	} else {
+		to, err = w.worktreeTree(s, idx)
+		from = opts.Tree
+		if err == nil && from == nil {
+			from, err = w.indexTree(s, idx)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	patterns, err := gitattributes.ReadPatterns(w.Filesystem, nil)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	changes, err := object.DiffTreeWithOptions(context.Background(), from, to, &object.DiffTreeOptions{
+		DetectRenames:    opts.DetectRenames,
+		RenameScore:      opts.RenameScore,
+		RenameLimit:      opts.RenameLimit,
+		DetectCopies:     opts.DetectCopies,
+		FindCopiesHarder: opts.FindCopiesHarder,
+		BreakRewrites:    opts.BreakRewrites,
+		BreakScore:       opts.BreakScore,
+		RewriteScore:     opts.RewriteScore,
+		LineDiff:         opts.LineDiff,
+		PathFilter:       newPathspec(opts.Paths).match,
+		Binary:           diffBinary(patterns),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes.Patch()
+}
+
+// headTree returns t, or the tree of HEAD when nil, an empty tree when
+// there is no HEAD.
+func (w *Worktree) headTree(s storage.Storer, t *object.Tree) (*object.Tree, error) {
+	if t != nil {
+		return t, nil
+	}
+
+	head, err := w.r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return buildDiffTree(s, nil)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := w.r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Tree()
+}
+
+// indexTree writes the tree of the merged entries of the index to s, the
+// intent-to-add entries being left out.
+func (w *Worktree) indexTree(s storage.Storer, idx *index.Index) (*object.Tree, error) {
+	var entries []*index.Entry
+	for _, e := range idx.Entries {
+		if e.Stage == 0 && !e.IntentToAdd {
+			entries = append(entries, e)
+		}
+	}
+
+	return buildDiffTree(s, entries)
+}
+
+// worktreeTree writes the tree of the files of the worktree tracked by the
+// merged entries of the index to s, along with the blobs of the files
+// changed since they were staged.
+func (w *Worktree) worktreeTree(s storage.Storer, idx *index.Index) (*object.Tree, error) {
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conv.close()
+
+	r, err := w.newIndexRefresh(idx, conv, &StatusOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := r.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make(map[string]StatusCode, len(changes))
+	for _, ch := range changes {
+		codes[ch.name] = ch.code
+	}
+
+	// The intent-to-add entries of empty files are unchanged and hold the
+	// hash of the empty blob, which may not be stored.
+	empty := s.NewEncodedObject()
+	empty.SetType(plumbing.BlobObject)
+	if _, err := s.SetEncodedObject(empty); err != nil {
+		return nil, err
+	}
+
+	conv = conv.quiet()
+	var entries []*index.Entry
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			continue
+		}
+
+		code, changed := codes[e.Name]
+		switch {
+		case !changed:
+			entries = append(entries, e)
+		case code == Modified:
+			we, err := w.worktreeEntry(s, conv, e, r.submodules)
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, we)
+		}
+	}
+
+	return buildDiffTree(s, entries)
+}
+
+// worktreeEntry returns the entry of the file of e in the worktree, its
+// blob being written to s.
+func (w *Worktree) worktreeEntry(s storage.Storer, conv *contentConverter, e *index.Entry, submodules map[string]*SubmoduleStatus) (*index.Entry, error) {
+	fi, err := w.Filesystem.Lstat(e.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	we := &index.Entry{Name: e.Name, Mode: filemode.Submodule, Hash: e.Hash}
+	if fi.IsDir() {
+		if st, ok := submodules[e.Name]; ok && !st.Current.IsZero() {
+			we.Hash = st.Current
+		}
+
+		return we, nil
+	}
+
+	if we.Mode, err = filemode.NewFromOSFileMode(fi.Mode()); err != nil {
+		return nil, err
+	}
+
+	if we.Hash, err = w.copyFileToStorage(s, e.Name, conv, false); err != nil {
+		return nil, err
+	}
+
+	return we, nil
+}
+
+// buildDiffTree writes the tree of the entries to s.
+func buildDiffTree(s storage.Storer, entries []*index.Entry) (*object.Tree, error) {
+	h := &buildTreeHelper{s: s}
+	hash, err := h.BuildTree(&index.Index{Entries: entries}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return object.GetTree(s, hash)
+}
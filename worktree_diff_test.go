@@ -0,0 +1,268 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	fdiff "github.com/go-git/go-git/v6/plumbing/format/diff"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// diffPaths returns the paths of the files of the patch, as "from -> to".
+func diffPaths(t *testing.T, p *object.Patch) []string {
+	t.Helper()
+
+	var paths []string
+	for _, fp := range p.FilePatches() {
+		from, to := fp.Files()
+		name := func(f fdiff.File) string {
+			if f == nil {
+				return ""
+			}
+
+			return f.Path()
+		}
+
+		paths = append(paths, name(from)+" -> "+name(to))
+	}
+
+	return paths
+}
+
+func TestWorktreeDiff(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt":     "a\n",
+		"b.txt":     "b\n",
+		"c.txt":     "c\n",
+		"src/d.txt": "d\n",
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\nmore\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("staged\n"), 0o644))
+	_, err := w.Add("b.txt")
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(filepath.Join(dir, "c.txt")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("u\n"), 0o644))
+
+	p, err := w.Diff(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt -> a.txt", "c.txt -> "}, diffPaths(t, p))
+
+	var buf bytes.Buffer
+	require.NoError(t, fdiff.NewUnifiedEncoder(&buf, 3).Encode(p))
+	assert.Equal(t, `diff --git a/a.txt b/a.txt
+index 78981922613b2afb6025042ff6bd878ac1994e85..`+plumbing.ComputeHash(plumbing.BlobObject, []byte("a\nmore\n")).String()+` 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1 +1,2 @@
+ a
++more
+diff --git a/c.txt b/c.txt
+deleted file mode 100644
+index f2ad6c76f0115a6ba5b00456a849810e7ec0af20..0000000000000000000000000000000000000000
+--- a/c.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-c
+`, buf.String())
+
+	p, err = w.Diff(&DiffOptions{Cached: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b.txt -> b.txt"}, diffPaths(t, p))
+
+	head, err := w.r.Head()
+	require.NoError(t, err)
+	commit, err := w.r.CommitObject(head.Hash())
+	require.NoError(t, err)
+	tree, err := commit.Tree()
+	require.NoError(t, err)
+
+	p, err = w.Diff(&DiffOptions{Tree: tree})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt -> a.txt", "b.txt -> b.txt", "c.txt -> "}, diffPaths(t, p))
+
+	// Nothing is written to the storage of the repository.
+	_, err = w.r.Storer.EncodedObject(plumbing.BlobObject, plumbing.ComputeHash(plumbing.BlobObject, []byte("a\nmore\n")))
+	assert.ErrorIs(t, err, plumbing.ErrObjectNotFound)
+}
+
+func TestWorktreeDiffPaths(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt":     "a\n",
+		"a.go":      "package a\n",
+		"src/b.txt": "b\n",
+		"src/c.go":  "package c\n",
+	})
+
+	for _, name := range []string{"a.txt", "a.go", "src/b.txt", "src/c.go"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("changed\n"), 0o644))
+	}
+
+	for _, tc := range []struct {
+		paths []string
+		want  []string
+	}{
+		{[]string{"src"}, []string{"src/b.txt -> src/b.txt", "src/c.go -> src/c.go"}},
+		{[]string{"*.txt"}, []string{"a.txt -> a.txt", "src/b.txt -> src/b.txt"}},
+		{[]string{"a.go", "src/c.go"}, []string{"a.go -> a.go", "src/c.go -> src/c.go"}},
+		{[]string{":!src"}, []string{"a.go -> a.go", "a.txt -> a.txt"}},
+		{[]string{"src", ":(exclude)*.go"}, []string{"src/b.txt -> src/b.txt"}},
+		{[]string{"missing"}, nil},
+	} {
+		p, err := w.Diff(&DiffOptions{Paths: tc.paths})
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, diffPaths(t, p), tc.paths)
+	}
+}
+
+func TestWorktreeDiffBinaryAttribute(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		".gitattributes": "*.dat -diff\n*.bin diff\n",
+		"a.dat":          "a\n",
+		"b.bin":          "b\x00\n",
+		"c.txt":          "c\x00\n",
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.dat"), []byte("aa\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.bin"), []byte("bb\x00\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("cc\x00\n"), 0o644))
+
+	p, err := w.Diff(nil)
+	require.NoError(t, err)
+
+	binary := make(map[string]bool)
+	for _, fp := range p.FilePatches() {
+		_, to := fp.Files()
+		binary[to.Path()] = fp.IsBinary()
+	}
+
+	assert.Equal(t, map[string]bool{"a.dat": true, "b.bin": false, "c.txt": true}, binary)
+}
+
+func TestWorktreeDiffRenames(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt": "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\nten\n"), 0o644))
+	_, err := w.Remove("a.txt")
+	require.NoError(t, err)
+	_, err = w.Add("b.txt")
+	require.NoError(t, err)
+
+	p, err := w.Diff(&DiffOptions{Cached: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt -> ", " -> b.txt"}, diffPaths(t, p))
+
+	p, err = w.Diff(&DiffOptions{Cached: true, DetectRenames: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt -> b.txt"}, diffPaths(t, p))
+
+	p, err = w.Diff(&DiffOptions{Cached: true, DetectRenames: true, RenameScore: 95})
+	require.NoError(t, err)
+	assert.Len(t, p.FilePatches(), 2)
+
+	_, err = w.Diff(&DiffOptions{RenameScore: 101})
+	assert.ErrorIs(t, err, ErrDiffRenameScore)
+}
+
+func TestWorktreeDiffCopies(t *testing.T) {
+	content := strings.Repeat("line of the file\n", 10) + "end\n"
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt": content,
+		"b.txt": "b\n",
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "copy.txt"), []byte(content), 0o644))
+	_, err := w.Add("copy.txt")
+	require.NoError(t, err)
+
+	p, err := w.Diff(&DiffOptions{Cached: true, DetectCopies: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{" -> copy.txt"}, diffPaths(t, p))
+
+	p, err = w.Diff(&DiffOptions{Cached: true, DetectCopies: true, FindCopiesHarder: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.txt -> copy.txt"}, diffPaths(t, p))
+
+	var buf bytes.Buffer
+	require.NoError(t, fdiff.NewSummaryEncoder(&buf).Encode(p))
+	assert.Equal(t, " copy a.txt => copy.txt (100%)\n", buf.String())
+
+	// Beyond the rename limit, the unmodified files are not sources.
+	cfg, err := w.r.Config()
+	require.NoError(t, err)
+	cfg.Diff.RenameLimit = 1
+	require.NoError(t, w.r.SetConfig(cfg))
+
+	p, err = w.Diff(&DiffOptions{Cached: true, DetectCopies: true, FindCopiesHarder: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{" -> copy.txt"}, diffPaths(t, p))
+}
+
+func TestWorktreeDiffBreakRewrites(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{
+		"a.txt": strings.Repeat("old line of the file\n", 30),
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte(strings.Repeat("new line of the file\n", 30)), 0o644))
+
+	p, err := w.Diff(&DiffOptions{BreakRewrites: true})
+	require.NoError(t, err)
+	require.Len(t, p.FilePatches(), 1)
+	assert.Equal(t, 100, p.FilePatches()[0].(fdiff.RewriteFilePatch).Dissimilarity())
+
+	var buf bytes.Buffer
+	require.NoError(t, fdiff.NewSummaryEncoder(&buf).Encode(p))
+	assert.Equal(t, " rewrite a.txt (100%)\n", buf.String())
+
+	_, err = w.Diff(&DiffOptions{BreakRewrites: true, RewriteScore: 101})
+	assert.ErrorIs(t, err, ErrDiffBreakScore)
+}
+
+func TestWorktreeDiffIntentToAdd(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{"a.txt": "a\n"})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0o644))
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	for _, name := range []string{"empty.txt", "new.txt"} {
+		e := idx.Add(name)
+		e.Hash = plumbing.ComputeHash(plumbing.BlobObject, nil)
+		e.Mode = 0o100644
+		e.IntentToAdd = true
+	}
+
+	idx.Version = 3
+	require.NoError(t, w.r.Storer.SetIndex(idx))
+
+	p, err := w.Diff(nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{" -> empty.txt", " -> new.txt"}, diffPaths(t, p))
+
+	var buf bytes.Buffer
+	require.NoError(t, fdiff.NewUnifiedEncoder(&buf, 3).Encode(p))
+	assert.Equal(t, `diff --git a/empty.txt b/empty.txt
+new file mode 100644
+index 0000000000000000000000000000000000000000..e69de29bb2d1d6434b8b29ae775ad8c2e48c5391
+diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000000000000000000000000000000000000..3e757656cf36eca53338e520d134963a44f793f8
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++new
+`, buf.String())
+
+	p, err = w.Diff(&DiffOptions{Cached: true})
+	require.NoError(t, err)
+	assert.Empty(t, p.FilePatches())
+}
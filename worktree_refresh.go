@@ -0,0 +1,387 @@
+package git
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+	"github.com/go-git/go-git/v6/utils/merkletrie/filesystem"
+	"github.com/go-git/go-git/v6/utils/trace"
+)
+
+// emptyBlobHash is the hash of the empty blob.
+var emptyBlobHash = plumbing.ComputeHash(plumbing.BlobObject, nil)
+
+// worktreeChange is a change of a file of the worktree compared to the index.
+type worktreeChange struct {
+	name string
+	code StatusCode
+}
+
+// indexModTimer is implemented by the index storages able to tell the
+// modification time of the index, see filesystem.IndexStorage.
+type indexModTimer interface {
+	IndexModTime() (time.Time, error)
+}
+
+// indexRefresh compares the worktree with the index the way git does: the
+// files whose stat data match the one recorded in the index are considered
+// unchanged without reading them, the other ones are hashed in parallel.
+// The directories unchanged since the untracked cache of the index was
+// computed are not read when listing the untracked files.
+type indexRefresh struct {
+	w    *Worktree
+	idx  *index.Index
+	opts *StatusOptions
+
+	// filter are the options used to hash the files.
+	filter filesystem.Options
+	// submodules are the status of the submodules, by path.
+	submodules map[string]*SubmoduleStatus
+	// indexTime is the modification time of the index, zero when unknown.
+	// The entries modified since are racily clean: their file may have
+	// changed within the timestamp granularity of the file system.
+	indexTime time.Time
+	// start is the time the refresh started.
+	start time.Time
+
+	// monitor are the changes reported by the FSMonitor, nil when there is
+	// none, and token the token of the query.
+	monitor *fsMonitorChanges
+	token   string
+
+	// leading caches whether the leading directories are real directories.
+	leading map[string]bool
+	// clean are the entries found unchanged, by name.
+	clean map[string]*index.Entry
+	// refreshed are the entries found unchanged after hashing them, with
+	// their current stat data.
+	refreshed []refreshedEntry
+	// validated are the directories of the untracked cache found up to date.
+	validated map[*index.UntrackedCacheDir]struct{}
+}
+
+type refreshedEntry struct {
+	entry *index.Entry
+	fi    os.FileInfo
+}
+
+// hashCandidate is a file whose stat data do not match its entry.
+type hashCandidate struct {
+	entry *index.Entry
+	fi    os.FileInfo
+	hash  plumbing.Hash
+}
+
+func (w *Worktree) newIndexRefresh(idx *index.Index, conv *contentConverter, o *StatusOptions) (*indexRefresh, error) {
+	r := &indexRefresh{
+		w:       w,
+		idx:     idx,
+		opts:    o,
+		filter:  conv.filterOptions(),
+		start:   time.Now(),
+		clean:   make(map[string]*index.Entry),
+		leading: make(map[string]bool),
+	}
+
+	if s, ok := w.r.Storer.(indexModTimer); ok {
+		t, err := s.IndexModTime()
+		if err != nil {
+			return nil, err
+		}
+
+		r.indexTime = t
+	}
+
+	for _, e := range idx.Entries {
+		if e.Mode == filemode.Submodule {
+			submodules, err := w.submodulesContentStatus(o.IgnoreSubmodules)
+			if err != nil {
+				return nil, err
+			}
+
+			r.submodules = submodules
+			break
+		}
+	}
+
+	if o.FSMonitor != nil {
+		r.queryMonitor()
+	}
+
+	return r, nil
+}
+
+// queryMonitor queries the FSMonitor for the changes since the token of the
+// index. All the files are checked when it fails.
+func (r *indexRefresh) queryMonitor() {
+	var token string
+	if r.idx.FSMonitor != nil {
+		token = r.idx.FSMonitor.Token
+	}
+
+	newToken, paths, err := r.opts.FSMonitor.Query(token)
+	if err != nil {
+		trace.General.Printf("warning: %s", err)
+		return
+	}
+
+	r.monitor = newFSMonitorChanges(token, paths)
+	r.token = newToken
+}
+
+// entries returns the changes of the files of the index.
+func (r *indexRefresh) entries() ([]worktreeChange, error) {
+	var changes []worktreeChange
+	var candidates []*hashCandidate
+	seen := make(map[string]struct{}, len(r.idx.Entries))
+	for _, e := range r.idx.Entries {
+		// Only the first stage of the unmerged files is compared.
+		if _, ok := seen[e.Name]; ok {
+			continue
+		}
+
+		seen[e.Name] = struct{}{}
+		if e.SkipWorktree {
+			continue
+		}
+
+		if r.monitor != nil && e.FSMonitorValid && !r.monitor.changed(e.Name) {
+			r.clean[e.Name] = e
+			continue
+		}
+
+		fi, err := r.lstat(e.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		code, candidate := r.compare(e, fi)
+		switch {
+		case code != Unmodified:
+			changes = append(changes, worktreeChange{name: e.Name, code: code})
+		case candidate:
+			candidates = append(candidates, &hashCandidate{entry: e, fi: fi})
+		case e.Mode != filemode.Submodule:
+			r.clean[e.Name] = e
+		}
+	}
+
+	r.hash(candidates)
+	for _, c := range candidates {
+		if c.hash != c.entry.Hash {
+			changes = append(changes, worktreeChange{name: c.entry.Name, code: Modified})
+			continue
+		}
+
+		r.clean[c.entry.Name] = c.entry
+		r.refreshed = append(r.refreshed, refreshedEntry{entry: c.entry, fi: c.fi})
+	}
+
+	return changes, nil
+}
+
+// compare compares the entry e with the Lstat of its file, nil when missing,
+// returning whether the file must be hashed when its stat data are not
+// enough to tell.
+func (r *indexRefresh) compare(e *index.Entry, fi os.FileInfo) (code StatusCode, candidate bool) {
+	if fi == nil {
+		return Deleted, false
+	}
+
+	if e.Mode == filemode.Submodule {
+		if !fi.IsDir() {
+			return Modified, false
+		}
+
+		if st, ok := r.submodules[e.Name]; ok && st.IsModified() {
+			return Modified, false
+		}
+
+		return Unmodified, false
+	}
+
+	if fi.IsDir() {
+		// The files of the directory are untracked.
+		return Deleted, false
+	}
+
+	mode, err := filemode.NewFromOSFileMode(fi.Mode())
+	if err != nil || mode != e.Mode {
+		return Modified, false
+	}
+
+	return Unmodified, !r.statClean(e, fi)
+}
+
+// statClean reports whether the stat data of fi match the ones of e, which
+// is not racily clean, so that its file can be considered unchanged.
+func (r *indexRefresh) statClean(e *index.Entry, fi os.FileInfo) bool {
+	if e.IntentToAdd || !trustStat(fi) || r.racy(e.ModifiedAt) {
+		return false
+	}
+
+	// The size of the racily clean entries is smudged when the index is
+	// written, see update.
+	if e.Size == 0 && e.Hash != emptyBlobHash {
+		return false
+	}
+
+	st := statEntry(fi)
+	return e.ModifiedAt.Equal(st.ModifiedAt) &&
+		e.CreatedAt.Equal(st.CreatedAt) &&
+		e.Size == st.Size &&
+		e.Dev == st.Dev && e.Inode == st.Inode &&
+		e.UID == st.UID && e.GID == st.GID
+}
+
+// racy reports whether a file modified at t may have changed since the
+// index was written without changing its modification time.
+func (r *indexRefresh) racy(t time.Time) bool {
+	return r.indexTime.IsZero() || !t.Before(r.indexTime)
+}
+
+// trustStat reports whether fi holds the stat data of the file system,
+// which are only emulated by some billy.Filesystem implementations.
+func trustStat(fi os.FileInfo) bool {
+	return fillSystemInfo != nil && fi.Sys() != nil
+}
+
+// statEntry returns an entry holding the stat data of fi.
+func statEntry(fi os.FileInfo) *index.Entry {
+	e := &index.Entry{
+		ModifiedAt: fi.ModTime(),
+		Size:       uint32(fi.Size()),
+	}
+
+	if fillSystemInfo != nil {
+		fillSystemInfo(e, fi.Sys())
+	}
+
+	return e
+}
+
+// lstat returns the result of Lstat on the file at name, nil when it is
+// missing or when one of its leading directories is not a directory, like
+// a symbolic link.
+func (r *indexRefresh) lstat(name string) (os.FileInfo, error) {
+	if dir := parentDir(name); dir != "" && !r.isDir(dir) {
+		return nil, nil
+	}
+
+	fi, err := r.w.Filesystem.Lstat(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return fi, err
+}
+
+func (r *indexRefresh) isDir(dir string) bool {
+	if ok, found := r.leading[dir]; found {
+		return ok
+	}
+
+	ok := true
+	if parent := parentDir(dir); parent != "" {
+		ok = r.isDir(parent)
+	}
+
+	if ok {
+		fi, err := r.w.Filesystem.Lstat(dir)
+		ok = err == nil && fi.IsDir()
+	}
+
+	r.leading[dir] = ok
+	return ok
+}
+
+// hash hashes the files of the candidates in parallel.
+func (r *indexRefresh) hash(candidates []*hashCandidate) {
+	workers := r.opts.HashWorkers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan *hashCandidate)
+	var wg sync.WaitGroup
+	for i := 0; i < min(workers, len(candidates)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range jobs {
+				c.hash = filesystem.HashFile(r.w.Filesystem, c.entry.Name, c.fi, r.filter)
+			}
+		}()
+	}
+
+	for _, c := range candidates {
+		jobs <- c
+	}
+
+	close(jobs)
+	wg.Wait()
+}
+
+// update updates the index with the result of the refresh, returning
+// whether it changed: the stat data of the files found unchanged after
+// hashing them are recorded, so they are not hashed again, and so is the
+// state of the FSMonitor.
+func (r *indexRefresh) update() bool {
+	var updated bool
+
+	// The files modified during the refresh are left racily clean, they may
+	// change again within the timestamp granularity of the file system.
+	limit := r.start.Truncate(time.Second)
+	refreshed := make(map[*index.Entry]struct{}, len(r.refreshed))
+	for _, re := range r.refreshed {
+		mtime := re.fi.ModTime()
+		if !trustStat(re.fi) || !mtime.Before(limit) {
+			continue
+		}
+
+		st := statEntry(re.fi)
+		e := re.entry
+		e.ModifiedAt, e.CreatedAt = st.ModifiedAt, st.CreatedAt
+		e.Size = st.Size
+		e.Dev, e.Inode = st.Dev, st.Inode
+		e.UID, e.GID = st.UID, st.GID
+		refreshed[e] = struct{}{}
+		updated = true
+	}
+
+	// The racily clean entries would look clean once the index is written
+	// after them, their size is smudged so their file is hashed next time,
+	// as git does.
+	for _, e := range r.idx.Entries {
+		if r.indexTime.IsZero() {
+			// The entries are never trusted.
+			break
+		}
+
+		if _, ok := refreshed[e]; ok || e.Size == 0 || e.SkipWorktree || e.Mode == filemode.Submodule {
+			continue
+		}
+
+		if r.racy(e.ModifiedAt) || !e.ModifiedAt.Before(limit) {
+			e.Size = 0
+			updated = true
+		}
+	}
+
+	if r.monitor == nil {
+		return updated
+	}
+
+	for _, e := range r.idx.Entries {
+		_, e.FSMonitorValid = r.clean[e.Name]
+	}
+
+	r.idx.FSMonitor = &index.FSMonitor{Version: 2, Token: r.token}
+	r.invalidateUntrackedCache()
+	return true
+}
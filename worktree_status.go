@@ -10,11 +10,13 @@ import (
 	"strings"
 
 	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v6/config"
 	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/go-git/go-git/v6/plumbing/filemode"
 	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
 	"github.com/go-git/go-git/v6/plumbing/format/index"
 	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/go-git/go-git/v6/plumbing/storer"
 	"github.com/go-git/go-git/v6/utils/ioutil"
 	"github.com/go-git/go-git/v6/utils/merkletrie"
 	"github.com/go-git/go-git/v6/utils/merkletrie/filesystem"
@@ -42,6 +44,21 @@ func (w *Worktree) Status() (Status, error) {
 // StatusOptions defines the options for Worktree.StatusWithOptions().
 type StatusOptions struct {
 	Strategy StatusStrategy
+	// RefreshIndex writes the index back with the stat data of the files
+	// found unchanged after hashing them, so they are not hashed again by
+	// the next status, like `git update-index --refresh`. The state of the
+	// FSMonitor is recorded as well.
+	RefreshIndex bool
+	// FSMonitor, when set, is queried for the files changed since the
+	// previous status so the other ones are not checked. The previous
+	// status must have been run with RefreshIndex to record its result.
+	FSMonitor FSMonitor
+	// HashWorkers is the number of files hashed in parallel, the number of
+	// CPUs when zero.
+	HashWorkers int
+	// IgnoreSubmodules overrides the changes of the submodules ignored by
+	// the status, set by the ignore option of each submodule otherwise.
+	IgnoreSubmodules config.SubmoduleIgnore
 }
 
 // StatusWithOptions returns the working tree status.
@@ -57,11 +74,11 @@ func (w *Worktree) StatusWithOptions(o StatusOptions) (Status, error) {
 		hash = ref.Hash()
 	}
 
-	return w.status(o.Strategy, hash)
+	return w.status(&o, hash)
 }
 
-func (w *Worktree) status(ss StatusStrategy, commit plumbing.Hash) (Status, error) {
-	s, err := ss.new(w)
+func (w *Worktree) status(o *StatusOptions, commit plumbing.Hash) (Status, error) {
+	s, err := o.Strategy.new(w)
 	if err != nil {
 		return nil, err
 	}
@@ -90,36 +107,72 @@ func (w *Worktree) status(ss StatusStrategy, commit plumbing.Hash) (Status, erro
 		}
 	}
 
-	right, err := w.diffStagingWithWorktree(false, true)
+	right, err := w.statusWorktree(o)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, ch := range right {
-		a, err := ch.Action()
-		if err != nil {
-			return nil, err
+		fs := s.File(ch.name)
+		if ch.code == Untracked {
+			fs.Worktree = Untracked
+			fs.Staging = Untracked
+			continue
 		}
 
-		fs := s.File(nameFromAction(&ch))
 		if fs.Staging == Untracked {
 			fs.Staging = Unmodified
 		}
 
-		switch a {
-		case merkletrie.Delete:
-			fs.Worktree = Deleted
-		case merkletrie.Insert:
-			fs.Worktree = Untracked
-			fs.Staging = Untracked
-		case merkletrie.Modify:
-			fs.Worktree = Modified
-		}
+		fs.Worktree = ch.code
 	}
 
 	return s, nil
 }
 
+// statusWorktree returns the changes of the worktree compared to the index,
+// see indexRefresh.
+func (w *Worktree) statusWorktree(o *StatusOptions) ([]worktreeChange, error) {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conv.close()
+
+	r, err := w.newIndexRefresh(idx, conv, o)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := r.entries()
+	if err != nil {
+		return nil, err
+	}
+
+	untracked, err := r.untracked()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range untracked {
+		changes = append(changes, worktreeChange{name: name, code: Untracked})
+	}
+
+	if o.RefreshIndex && r.update() {
+		if err := w.r.Storer.SetIndex(idx); err != nil {
+			return nil, err
+		}
+	}
+
+	return changes, nil
+}
+
 func nameFromAction(ch *merkletrie.Change) string {
 	name := ch.To.String()
 	if name == "" {
@@ -141,7 +194,14 @@ func (w *Worktree) diffStagingWithWorktree(reverse, excludeIgnoredChanges bool)
 		return nil, err
 	}
 
-	to := filesystem.NewRootNode(w.Filesystem, submodules)
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conv.close()
+
+	to := filesystem.NewRootNodeWithOptions(w.Filesystem, submodules, conv.filterOptions())
 
 	var c merkletrie.Changes
 	if reverse {
@@ -206,12 +266,17 @@ func (w *Worktree) getSubmodulesStatus() (map[string]plumbing.Hash, error) {
 		return nil, err
 	}
 
-	status, err := sub.Status()
+	idx, err := w.r.Storer.Index()
 	if err != nil {
 		return nil, err
 	}
 
-	for _, s := range status {
+	for _, sub := range sub {
+		s, err := sub.status(idx)
+		if err != nil {
+			return nil, err
+		}
+
 		if s.Current.IsZero() {
 			o[s.Path] = s.Expected
 			continue
@@ -223,6 +288,37 @@ func (w *Worktree) getSubmodulesStatus() (map[string]plumbing.Hash, error) {
 	return o, nil
 }
 
+// submodulesContentStatus returns the status of the submodules by path,
+// including the changes of their worktree unless ignored, see
+// StatusOptions.IgnoreSubmodules.
+func (w *Worktree) submodulesContentStatus(ignore config.SubmoduleIgnore) (map[string]*SubmoduleStatus, error) {
+	sub, err := w.Submodules()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	o := make(map[string]*SubmoduleStatus, len(sub))
+	for _, sub := range sub {
+		s, err := sub.status(idx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := sub.contentStatus(s, ignore); err != nil {
+			return nil, err
+		}
+
+		o[s.Path] = s
+	}
+
+	return o, nil
+}
+
 func (w *Worktree) diffCommitWithStaging(commit plumbing.Hash, reverse bool) (merkletrie.Changes, error) {
 	var t *object.Tree
 	if !commit.IsZero() {
@@ -251,7 +347,7 @@ func (w *Worktree) diffTreeWithStaging(t *object.Tree, reverse bool) (merkletrie
 		return nil, err
 	}
 
-	to := mindex.NewRootNode(idx)
+	to := mindex.NewRootNodeWithSkipWorktree(idx)
 
 	if reverse {
 		return merkletrie.DiffTree(to, from, diffTreeIsEquals)
@@ -290,7 +386,7 @@ func (w *Worktree) Add(path string) (plumbing.Hash, error) {
 	return w.doAdd(path, make([]gitignore.Pattern, 0), false)
 }
 
-func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string, ignorePattern []gitignore.Pattern) (added bool, err error) {
+func (w *Worktree) doAddDirectory(idx *index.Index, conv *contentConverter, s Status, directory string, ignorePattern []gitignore.Pattern) (added bool, err error) {
 	if len(ignorePattern) > 0 {
 		m := gitignore.NewMatcher(ignorePattern)
 		matchPath := strings.Split(directory, string(os.PathSeparator))
@@ -308,7 +404,7 @@ func (w *Worktree) doAddDirectory(idx *index.Index, s Status, directory string,
 		}
 
 		var a bool
-		a, _, err = w.doAddFile(idx, s, name, ignorePattern)
+		a, _, err = w.doAddFile(idx, conv, s, name, ignorePattern)
 		if err != nil {
 			return
 		}
@@ -336,6 +432,10 @@ func (w *Worktree) AddWithOptions(opts *AddOptions) error {
 		return err
 	}
 
+	if opts.Renormalize {
+		return w.renormalize(opts.Path)
+	}
+
 	if opts.All {
 		_, err := w.doAdd(".", w.Excludes, false)
 		return err
@@ -370,12 +470,19 @@ func (w *Worktree) doAdd(path string, ignorePattern []gitignore.Pattern, skipSta
 		}
 	}
 
+	conv, err2 := w.newContentConverter(idx, nil)
+	if err2 != nil {
+		return plumbing.ZeroHash, err2
+	}
+
+	defer conv.close()
+
 	path = filepath.Clean(path)
 
 	if err != nil || !fi.IsDir() {
-		added, h, err = w.doAddFile(idx, s, path, ignorePattern)
+		added, h, err = w.doAddFile(idx, conv, s, path, ignorePattern)
 	} else {
-		added, err = w.doAddDirectory(idx, s, path, ignorePattern)
+		added, err = w.doAddDirectory(idx, conv, s, path, ignorePattern)
 	}
 
 	if err != nil {
@@ -389,6 +496,64 @@ func (w *Worktree) doAdd(path string, ignorePattern []gitignore.Pattern, skipSta
 	return h, w.r.Storer.SetIndex(idx)
 }
 
+// renormalize stores again the tracked files under path, applying the
+// end-of-line conversions regardless of the line endings of the files in the
+// index, as `git add --renormalize` does.
+func (w *Worktree) renormalize(path string) error {
+	idx, err := w.r.Storer.Index()
+	if err != nil {
+		return err
+	}
+
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil || conv == nil {
+		return err
+	}
+
+	defer conv.close()
+
+	directory := "."
+	if path != "" {
+		directory = filepath.ToSlash(filepath.Clean(path))
+	}
+
+	var changed bool
+	for _, e := range idx.Entries {
+		if e.Mode != filemode.Regular && e.Mode != filemode.Executable {
+			continue
+		}
+
+		if e.Name != directory && !isPathInDirectory(e.Name, directory) {
+			continue
+		}
+
+		h, err := w.copyFileToStorage(w.r.Storer, e.Name, conv, true)
+		if os.IsNotExist(err) {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if h == e.Hash {
+			continue
+		}
+
+		if err := w.doUpdateFileToIndex(e, e.Name, h); err != nil {
+			return err
+		}
+
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return w.r.Storer.SetIndex(idx)
+}
+
 // AddGlob adds all paths, matching pattern, to the index. If pattern matches a
 // directory path, all directory contents are added to the index recursively. No
 // error is returned if all matching paths are already staged in index.
@@ -413,6 +578,13 @@ func (w *Worktree) AddGlob(pattern string) error {
 		return err
 	}
 
+	conv, err := w.newContentConverter(idx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer conv.close()
+
 	var saveIndex bool
 	for _, file := range files {
 		fi, err := w.Filesystem.Lstat(file)
@@ -422,9 +594,9 @@ func (w *Worktree) AddGlob(pattern string) error {
 
 		var added bool
 		if fi.IsDir() {
-			added, err = w.doAddDirectory(idx, s, file, make([]gitignore.Pattern, 0))
+			added, err = w.doAddDirectory(idx, conv, s, file, make([]gitignore.Pattern, 0))
 		} else {
-			added, _, err = w.doAddFile(idx, s, file, make([]gitignore.Pattern, 0))
+			added, _, err = w.doAddFile(idx, conv, s, file, make([]gitignore.Pattern, 0))
 		}
 
 		if err != nil {
@@ -446,7 +618,8 @@ func (w *Worktree) AddGlob(pattern string) error {
 // doAddFile create a new blob from path and update the index, added is true if
 // the file added is different from the index.
 // if s status is nil will skip the status check and update the index anyway
-func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePattern []gitignore.Pattern) (added bool, h plumbing.Hash, err error) {
+// conv converts the content of the file before storing it, it can be nil.
+func (w *Worktree) doAddFile(idx *index.Index, conv *contentConverter, s Status, path string, ignorePattern []gitignore.Pattern) (added bool, h plumbing.Hash, err error) {
 	if s != nil && s.File(path).Worktree == Unmodified {
 		return false, h, nil
 	}
@@ -459,7 +632,11 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePatt
 		}
 	}
 
-	h, err = w.copyFileToStorage(path)
+	if e, err := idx.Entry(filepath.ToSlash(path)); err == nil && e.Mode == filemode.Submodule {
+		return w.addSubmoduleToIndex(e)
+	}
+
+	h, err = w.copyFileToStorage(w.r.Storer, path, conv, false)
 	if err != nil {
 		if os.IsNotExist(err) {
 			added = true
@@ -476,13 +653,45 @@ func (w *Worktree) doAddFile(idx *index.Index, s Status, path string, ignorePatt
 	return true, h, err
 }
 
-func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error) {
+// addSubmoduleToIndex stages the commit checked out in the submodule of the
+// entry, if any, like git does for the gitlinks.
+func (w *Worktree) addSubmoduleToIndex(e *index.Entry) (added bool, h plumbing.Hash, err error) {
+	r, err := w.submoduleRepository(e.Name)
+	if errors.Is(err, ErrSubmoduleNotFound) || errors.Is(err, ErrSubmoduleNotInitialized) ||
+		errors.Is(err, ErrRepositoryNotExists) {
+		return false, e.Hash, nil
+	}
+
+	if err != nil {
+		return false, h, err
+	}
+
+	head, err := r.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return false, e.Hash, nil
+	}
+
+	if err != nil || head.Hash() == e.Hash {
+		return false, e.Hash, err
+	}
+
+	e.Hash = head.Hash()
+	return true, e.Hash, nil
+}
+
+// copyFileToStorage writes the content of the file at path, converted by
+// conv, as a blob of s.
+func (w *Worktree) copyFileToStorage(s storer.EncodedObjectStorer, path string, conv *contentConverter, renormalize bool) (hash plumbing.Hash, err error) {
 	fi, err := w.Filesystem.Lstat(path)
 	if err != nil {
 		return plumbing.ZeroHash, err
 	}
 
-	obj := w.r.Storer.NewEncodedObject()
+	if conv != nil && fi.Mode().IsRegular() {
+		return w.copyConvertedFileToStorage(s, path, conv, renormalize)
+	}
+
+	obj := s.NewEncodedObject()
 	obj.SetType(plumbing.BlobObject)
 	obj.SetSize(fi.Size())
 
@@ -503,7 +712,39 @@ func (w *Worktree) copyFileToStorage(path string) (hash plumbing.Hash, err error
 		return plumbing.ZeroHash, err
 	}
 
-	return w.r.Storer.SetEncodedObject(obj)
+	return s.SetEncodedObject(obj)
+}
+
+func (w *Worktree) copyConvertedFileToStorage(s storer.EncodedObjectStorer, path string, conv *contentConverter, renormalize bool) (plumbing.Hash, error) {
+	content, err := util.ReadFile(w.Filesystem, path)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	content, err = conv.toGit(path, content, renormalize)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	obj.SetSize(int64(len(content)))
+
+	writer, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return plumbing.ZeroHash, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return s.SetEncodedObject(obj)
 }
 
 func (w *Worktree) fillEncodedObjectFromFile(dst io.Writer, path string, _ os.FileInfo) (err error) {
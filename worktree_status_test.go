@@ -1,13 +1,22 @@
 package git
 
 import (
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/go-git/go-billy/v5/osfs"
 	"github.com/go-git/go-git/v6/plumbing/cache"
+	"github.com/go-git/go-git/v6/plumbing/convert"
+	"github.com/go-git/go-git/v6/plumbing/object"
 	"github.com/go-git/go-git/v6/storage/filesystem"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -87,3 +96,320 @@ func TestIndexEntrySizeUpdatedForNonRegularFiles(t *testing.T) {
 	// Check whether the index was updated with the two new line breaks.
 	assert.Equal(t, uint32(len(content)+2), idx.Entries[0].Size)
 }
+
+// fakeFSMonitor is an FSMonitor reporting the paths it is given.
+type fakeFSMonitor struct {
+	token   string
+	paths   []string
+	queries []string
+}
+
+func (m *fakeFSMonitor) Query(token string) (string, []string, error) {
+	m.queries = append(m.queries, token)
+	return m.token, m.paths, nil
+}
+
+func newStatusTestRepository(t *testing.T, files map[string]string) (*Worktree, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	r, err := PlainInit(dir, false)
+	require.NoError(t, err)
+
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		_, err = w.Add(name)
+		require.NoError(t, err)
+	}
+
+	_, err = w.Commit("init", &CommitOptions{
+		Author: &object.Signature{Name: "foo", Email: "foo@foo.foo", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	return w, dir
+}
+
+func TestStatusRefreshIndex(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stat data are not recorded on windows")
+	}
+
+	w, dir := newStatusTestRepository(t, map[string]string{"foo": "foo", "bar/baz": "baz"})
+
+	// Touched but unchanged, the file is hashed and its stat data refreshed.
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "foo"), past, past))
+	// Modified keeping its size.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar", "baz"), []byte("qux"), 0o644))
+
+	st, err := w.StatusWithOptions(StatusOptions{Strategy: Preload, RefreshIndex: true, HashWorkers: 2})
+	require.NoError(t, err)
+	assert.Equal(t, Unmodified, st.File("foo").Worktree)
+	assert.Equal(t, Modified, st.File("bar/baz").Worktree)
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	e, err := idx.Entry("foo")
+	require.NoError(t, err)
+	assert.True(t, past.Equal(e.ModifiedAt))
+
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload})
+	require.NoError(t, err)
+	assert.Equal(t, Unmodified, st.File("foo").Worktree)
+	assert.Equal(t, Modified, st.File("bar/baz").Worktree)
+}
+
+// parallelFilter is a clean filter reporting whether its calls run in
+// parallel, each call waiting a bit for another one to start.
+type parallelFilter struct {
+	running  atomic.Int32
+	parallel atomic.Bool
+}
+
+func (f *parallelFilter) Clean(_ string, content []byte) ([]byte, error) {
+	f.running.Add(1)
+	defer f.running.Add(-1)
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); {
+		if f.running.Load() > 1 {
+			f.parallel.Store(true)
+			break
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+
+	return content, nil
+}
+
+func (f *parallelFilter) Smudge(_ string, content []byte) ([]byte, error) {
+	return content, nil
+}
+
+func TestStatusRefreshIndexFilterParallel(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stat data are not recorded on windows")
+	}
+
+	w, dir := newStatusTestRepository(t, map[string]string{
+		".gitattributes": "*.txt filter=parallel\n",
+		"a.txt":          "a",
+		"b.txt":          "b",
+	})
+
+	f := &parallelFilter{}
+	convert.RegisterFilter("parallel", f)
+	defer convert.UnregisterFilter("parallel")
+
+	// Touched, the files are hashed by the workers.
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), past, past))
+	}
+
+	st, err := w.StatusWithOptions(StatusOptions{Strategy: Preload, RefreshIndex: true, HashWorkers: 2})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+	assert.True(t, f.parallel.Load())
+}
+
+// countingFilter is a clean filter counting its calls per path, its state
+// being guarded as required of the filters called concurrently.
+type countingFilter struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (f *countingFilter) Clean(path string, content []byte) ([]byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[path]++
+	return content, nil
+}
+
+func (f *countingFilter) Smudge(_ string, content []byte) ([]byte, error) {
+	return content, nil
+}
+
+func TestStatusRefreshIndexStatefulFilter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stat data are not recorded on windows")
+	}
+
+	files := map[string]string{".gitattributes": "*.txt filter=counting\n"}
+	for i := 0; i < 16; i++ {
+		files[fmt.Sprintf("%02d.txt", i)] = strconv.Itoa(i)
+	}
+
+	w, dir := newStatusTestRepository(t, files)
+
+	f := &countingFilter{calls: map[string]int{}}
+	convert.RegisterFilter("counting", f)
+	defer convert.UnregisterFilter("counting")
+
+	// Touched, the files are hashed by the workers, run with -race.
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	for name := range files {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), past, past))
+	}
+
+	st, err := w.StatusWithOptions(StatusOptions{Strategy: Preload, RefreshIndex: true, HashWorkers: 4})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean(), st.String())
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Len(t, f.calls, 16)
+	for i := 0; i < 16; i++ {
+		assert.Positive(t, f.calls[fmt.Sprintf("%02d.txt", i)])
+	}
+}
+
+func TestStatusRefreshIndexRacilyClean(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("stat data are not recorded on windows")
+	}
+
+	w, dir := newStatusTestRepository(t, map[string]string{"foo": "foo"})
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	e, err := idx.Entry("foo")
+	require.NoError(t, err)
+	mtime := e.ModifiedAt
+
+	// The entry is racily clean when the index is not written after it: the
+	// file may have changed within the same timestamp.
+	past := mtime.Add(-time.Hour)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, GitDirName, "index"), past, past))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("bar"), 0o644))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "foo"), mtime, mtime))
+
+	st, err := w.StatusWithOptions(StatusOptions{RefreshIndex: true})
+	require.NoError(t, err)
+	assert.Equal(t, Modified, st.File("foo").Worktree)
+
+	// Its size is smudged so it is not trusted once the index is written.
+	idx, err = w.r.Storer.Index()
+	require.NoError(t, err)
+	e, err = idx.Entry("foo")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), e.Size)
+
+	st, err = w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Modified, st.File("foo").Worktree)
+}
+
+func TestStatusFSMonitor(t *testing.T) {
+	w, dir := newStatusTestRepository(t, map[string]string{"foo": "foo", "bar/baz": "baz"})
+
+	m := &fakeFSMonitor{token: "1", paths: []string{"/"}}
+	st, err := w.StatusWithOptions(StatusOptions{FSMonitor: m, RefreshIndex: true})
+	require.NoError(t, err)
+	assert.True(t, st.IsClean())
+
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	require.NotNil(t, idx.FSMonitor)
+	assert.Equal(t, "1", idx.FSMonitor.Token)
+	for _, e := range idx.Entries {
+		assert.True(t, e.FSMonitorValid, e.Name)
+	}
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo"), []byte("qux"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar", "baz"), []byte("qux"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar", "new"), nil, 0o644))
+
+	// The monitor missed the change of foo, which is not checked.
+	m.token, m.paths = "2", []string{"bar/baz", "bar/new"}
+	st, err = w.StatusWithOptions(StatusOptions{Strategy: Preload, FSMonitor: m, RefreshIndex: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "1"}, m.queries)
+	assert.Equal(t, Unmodified, st.File("foo").Worktree)
+	assert.Equal(t, Modified, st.File("bar/baz").Worktree)
+	assert.Equal(t, Untracked, st.File("bar/new").Worktree)
+
+	idx, err = w.r.Storer.Index()
+	require.NoError(t, err)
+	assert.Equal(t, "2", idx.FSMonitor.Token)
+	e, err := idx.Entry("bar/baz")
+	require.NoError(t, err)
+	assert.False(t, e.FSMonitorValid)
+
+	// Without the monitor every file is checked.
+	st, err = w.Status()
+	require.NoError(t, err)
+	assert.Equal(t, Modified, st.File("foo").Worktree)
+}
+
+func TestHookFSMonitor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the hook is run by sh")
+	}
+
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "hook")
+	require.NoError(t, os.WriteFile(hook,
+		[]byte("#!/bin/sh\nprintf 'token-%s\\0%s\\0bar/\\0' \"$2\" \"$1\"\n"), 0o755))
+
+	m := &HookFSMonitor{Command: hook, Dir: dir}
+	token, paths, err := m.Query("1")
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, []string{"2", "bar/"}, paths)
+
+	m.Command = "false"
+	_, _, err = m.Query("1")
+	assert.Error(t, err)
+}
+
+func TestStatusUntrackedCache(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	w, dir := newStatusTestRepository(t, map[string]string{"foo": "foo", "bar/baz": "baz", ".gitignore": "*.log\n"})
+	for _, name := range []string{"qux", "bar/qux", "bar/qux.log", "new/qux"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, nil, 0o644))
+	}
+
+	cmd := exec.Command("git", "-c", "core.untrackedCache=true", "status", "--porcelain")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	// The unchanged directories are not read, what the cache says is used.
+	idx, err := w.r.Storer.Index()
+	require.NoError(t, err)
+	require.NotNil(t, idx.UntrackedCache)
+	require.NotNil(t, idx.UntrackedCache.Root)
+	require.True(t, idx.UntrackedCache.Root.Valid)
+	idx.UntrackedCache.Root.Untracked = append(idx.UntrackedCache.Root.Untracked, "cached")
+	require.NoError(t, w.r.Storer.SetIndex(idx))
+
+	st, err := w.Status()
+	require.NoError(t, err)
+	assert.Len(t, st, 4)
+	for _, name := range []string{"qux", "bar/qux", "new/qux", "cached"} {
+		assert.Equal(t, Untracked, st.File(name).Worktree, name)
+	}
+
+	// The changed directories are read.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar", "other"), nil, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new", "other"), nil, 0o644))
+
+	st, err = w.Status()
+	require.NoError(t, err)
+	assert.Len(t, st, 6)
+	assert.Equal(t, Untracked, st.File("bar/other").Worktree)
+	assert.Equal(t, Untracked, st.File("new/other").Worktree)
+}
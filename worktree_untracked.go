@@ -0,0 +1,316 @@
+package git
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v6/plumbing/format/index"
+)
+
+const (
+	infoExcludePath  = GitDirName + "/info/exclude"
+	gitignoreName    = ".gitignore"
+	dirShowIgnored   = 1 << 0
+	untrackedCacheID = "Location "
+)
+
+// untrackedWalk lists the untracked files of the worktree which are not
+// ignored, reading only the directories changed since the untracked cache
+// of the index was computed when it can be used.
+type untrackedWalk struct {
+	r *indexRefresh
+
+	// files are the files of the index, and gitlinks the submodules.
+	files    map[string]struct{}
+	gitlinks map[string]struct{}
+	// dirs are the names of the subdirectories holding files of the index,
+	// by directory.
+	dirs map[string][]string
+
+	// validated are the directories of the untracked cache found up to date.
+	validated map[*index.UntrackedCacheDir]struct{}
+	untracked []string
+}
+
+// untracked returns the untracked files of the worktree.
+func (r *indexRefresh) untracked() ([]string, error) {
+	u := &untrackedWalk{
+		r:         r,
+		files:     make(map[string]struct{}, len(r.idx.Entries)),
+		gitlinks:  make(map[string]struct{}),
+		dirs:      make(map[string][]string),
+		validated: make(map[*index.UntrackedCacheDir]struct{}),
+	}
+
+	seen := make(map[string]struct{})
+	for _, e := range r.idx.Entries {
+		u.files[e.Name] = struct{}{}
+		if e.Mode == filemode.Submodule {
+			u.gitlinks[e.Name] = struct{}{}
+		}
+
+		for dir := parentDir(e.Name); dir != ""; dir = parentDir(dir) {
+			if _, ok := seen[dir]; ok {
+				break
+			}
+
+			seen[dir] = struct{}{}
+			parent := parentDir(dir)
+			u.dirs[parent] = append(u.dirs[parent], path.Base(dir))
+		}
+	}
+
+	content, found := u.readFile(infoExcludePath)
+	patterns, _ := gitignore.ReadIgnoreFile(bytes.NewReader(content), nil)
+
+	var cache *index.UntrackedCacheDir
+	if u.useCache(content, found) {
+		cache = r.idx.UntrackedCache.Root
+	}
+
+	r.validated = u.validated
+	if err := u.walk("", patterns, cache); err != nil {
+		return nil, err
+	}
+
+	return u.untracked, nil
+}
+
+// useCache reports whether the untracked cache of the index was computed
+// with the same exclude files, content being the one of info/exclude.
+func (u *untrackedWalk) useCache(content []byte, found bool) bool {
+	c := u.r.idx.UntrackedCache
+	if c == nil || c.Root == nil || c.ExcludePerDir != gitignoreName ||
+		c.DirFlags&dirShowIgnored != 0 || !c.ExcludesFileHash.IsZero() {
+		return false
+	}
+
+	if c.InfoExcludeHash != excludeFileHash(content, found) {
+		return false
+	}
+
+	ident := untrackedCacheID + u.r.w.Filesystem.Root() + ","
+	return strings.Contains(c.Ident, ident)
+}
+
+// walk lists the untracked files of dir, given the patterns of the parent
+// directories and the cache of the directory, nil when not cached.
+func (u *untrackedWalk) walk(dir string, patterns []gitignore.Pattern, cache *index.UntrackedCacheDir) error {
+	content, found := u.readFile(path.Join(dir, gitignoreName))
+	if found {
+		ps, _ := gitignore.ReadIgnoreFile(bytes.NewReader(content), splitPath(dir))
+		patterns = append(slices.Clip(patterns), ps...)
+	}
+
+	// The cache of the whole subtree depends on the exclude file.
+	if cache != nil && cache.ExcludeHash != excludeFileHash(content, found) {
+		cache = nil
+	}
+
+	m := gitignore.NewMatcher(append(slices.Clip(patterns), u.r.w.Excludes...))
+	if cache != nil && u.valid(dir, cache) {
+		u.validated[cache] = struct{}{}
+		return u.walkCached(dir, patterns, m, cache)
+	}
+
+	fis, err := u.r.w.Filesystem.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range fis {
+		name := fi.Name()
+		if name == GitDirName || fi.Mode()&os.ModeSocket != 0 {
+			continue
+		}
+
+		p := path.Join(dir, name)
+		if !fi.IsDir() {
+			u.addFile(p, m)
+			continue
+		}
+
+		if _, ok := u.gitlinks[p]; ok || m.Match(splitPath(p), true) {
+			continue
+		}
+
+		if err := u.walk(p, patterns, childCache(cache, name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// walkCached lists the untracked files of dir from its cache, visiting the
+// subdirectories which may have changed.
+func (u *untrackedWalk) walkCached(dir string, patterns []gitignore.Pattern, m gitignore.Matcher, cache *index.UntrackedCacheDir) error {
+	visited := make(map[string]struct{})
+	for _, name := range cache.Untracked {
+		name, isDir := strings.CutSuffix(name, "/")
+		p := path.Join(dir, name)
+		if !isDir {
+			u.addFile(p, m)
+			continue
+		}
+
+		// The untracked directories are listed as a whole.
+		visited[name] = struct{}{}
+		if !m.Match(splitPath(p), true) {
+			if err := u.walk(p, patterns, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, sub := range cache.Dirs {
+		if _, ok := visited[sub.Name]; ok {
+			continue
+		}
+
+		visited[sub.Name] = struct{}{}
+		if err := u.walkSubdir(dir, sub.Name, patterns, m, sub); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range u.dirs[dir] {
+		if _, ok := visited[name]; ok {
+			continue
+		}
+
+		if err := u.walkSubdir(dir, name, patterns, m, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (u *untrackedWalk) walkSubdir(dir, name string, patterns []gitignore.Pattern, m gitignore.Matcher, cache *index.UntrackedCacheDir) error {
+	p := path.Join(dir, name)
+	if _, ok := u.gitlinks[p]; ok || m.Match(splitPath(p), true) {
+		return nil
+	}
+
+	return u.walk(p, patterns, cache)
+}
+
+func (u *untrackedWalk) addFile(p string, m gitignore.Matcher) {
+	if _, ok := u.files[p]; ok || m.Match(splitPath(p), false) {
+		return
+	}
+
+	u.untracked = append(u.untracked, p)
+}
+
+// valid reports whether the cache of dir is up to date: the directory is
+// unchanged according to the FSMonitor or to its stat data.
+func (u *untrackedWalk) valid(dir string, cache *index.UntrackedCacheDir) bool {
+	if !cache.Valid || cache.CheckOnly {
+		return false
+	}
+
+	if m := u.r.monitor; m != nil && !m.all {
+		return !m.dirChanged(dir)
+	}
+
+	fi, err := u.r.w.Filesystem.Lstat(dir)
+	if err != nil || !fi.IsDir() || !trustStat(fi) {
+		return false
+	}
+
+	s := cache.Stat
+	if u.r.racy(s.ModifiedAt) {
+		return false
+	}
+
+	st := statEntry(fi)
+	return s.ModifiedAt.Equal(st.ModifiedAt) &&
+		s.CreatedAt.Equal(st.CreatedAt) &&
+		s.Size == st.Size &&
+		s.Dev == st.Dev && s.Inode == st.Inode &&
+		s.UID == st.UID && s.GID == st.GID
+}
+
+// readFile returns the content of the file at name and whether it exists.
+func (u *untrackedWalk) readFile(name string) ([]byte, bool) {
+	f, err := u.r.w.Filesystem.Open(name)
+	if err != nil {
+		return nil, false
+	}
+
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false
+	}
+
+	return content, true
+}
+
+// invalidateUntrackedCache invalidates the directories of the untracked
+// cache which were not found up to date, so they are not trusted on the
+// word of the FSMonitor afterwards.
+func (r *indexRefresh) invalidateUntrackedCache() {
+	c := r.idx.UntrackedCache
+	if c == nil || c.Root == nil {
+		return
+	}
+
+	dirs := []*index.UntrackedCacheDir{c.Root}
+	for len(dirs) > 0 {
+		d := dirs[len(dirs)-1]
+		dirs = append(dirs[:len(dirs)-1], d.Dirs...)
+		if _, ok := r.validated[d]; ok {
+			continue
+		}
+
+		d.Valid = false
+		d.CheckOnly = false
+		d.Untracked = nil
+	}
+}
+
+func childCache(cache *index.UntrackedCacheDir, name string) *index.UntrackedCacheDir {
+	if cache == nil {
+		return nil
+	}
+
+	for _, d := range cache.Dirs {
+		if d.Name == name {
+			return d
+		}
+	}
+
+	return nil
+}
+
+// excludeFileHash returns the hash of the exclude file content, zero when
+// missing, as recorded by the untracked cache.
+func excludeFileHash(content []byte, found bool) plumbing.Hash {
+	if !found {
+		return plumbing.ZeroHash
+	}
+
+	return plumbing.ComputeHash(plumbing.BlobObject, content)
+}
+
+func splitPath(p string) []string {
+	if p == "" {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}